@@ -0,0 +1,187 @@
+package tc
+
+import (
+	"fmt"
+
+	"github.com/florianl/go-tc/core"
+)
+
+// ActionList is the ordered list of actions of a filter. The kernel runs the
+// actions in this order. The type of their nested attribute is the position
+// in the list and is numbered from 1 on every marshaling, so the list does not
+// need to be renumbered after an edit. Index refers to an action instance of
+// the kernel and is kept as it is.
+type ActionList []*Action
+
+// ActionsOf returns the actions of the filter obj. The list can be edited
+// without changing obj.
+func ActionsOf(obj *Object) ActionList {
+	if obj == nil || objectType(obj) != "filter" {
+		return nil
+	}
+	actions := filterActions(obj)
+	if len(actions) == 0 {
+		return nil
+	}
+	return append(ActionList{}, actions...)
+}
+
+// Append adds actions to the end of l.
+func (l *ActionList) Append(actions ...*Action) {
+	*l = append(*l, actions...)
+}
+
+// InsertAt inserts actions in front of the action at i. With i equal to the
+// length of l, actions are appended.
+func (l *ActionList) InsertAt(i int, actions ...*Action) error {
+	if i < 0 || i > len(*l) {
+		return fmt.Errorf("insert at %d of %d actions: %w", i, len(*l), ErrInvalidArg)
+	}
+	edited := make(ActionList, 0, len(*l)+len(actions))
+	edited = append(edited, (*l)[:i]...)
+	edited = append(edited, actions...)
+	*l = append(edited, (*l)[i:]...)
+	return nil
+}
+
+// Remove removes the action at i.
+func (l *ActionList) Remove(i int) error {
+	if i < 0 || i >= len(*l) {
+		return fmt.Errorf("remove %d of %d actions: %w", i, len(*l), ErrInvalidArg)
+	}
+	edited := make(ActionList, 0, len(*l)-1)
+	edited = append(edited, (*l)[:i]...)
+	*l = append(edited, (*l)[i+1:]...)
+	return nil
+}
+
+// RemoveKind removes all actions of kind, like mirred, and returns their
+// number.
+func (l *ActionList) RemoveKind(kind string) int {
+	edited := make(ActionList, 0, len(*l))
+	for _, action := range *l {
+		if action != nil && action.Kind == kind {
+			continue
+		}
+		edited = append(edited, action)
+	}
+	removed := len(*l) - len(edited)
+	*l = edited
+	return removed
+}
+
+// Move moves the action at from to the position to. The other actions keep
+// their order.
+func (l *ActionList) Move(from, to int) error {
+	if from < 0 || from >= len(*l) || to < 0 || to >= len(*l) {
+		return fmt.Errorf("move %d to %d of %d actions: %w", from, to, len(*l), ErrInvalidArg)
+	}
+	action := (*l)[from]
+	if err := l.Remove(from); err != nil {
+		return err
+	}
+	return l.InsertAt(to, action)
+}
+
+// ReplaceActions replaces the filter info with a copy, whose actions are
+// actions. Everything else of info, like the match of flower or u32, is sent
+// unchanged, as the kernel builds the filter from the request. The statistics
+// of info and actions are not sent. Handle and priority of info must be set,
+// so that the existing filter is replaced instead of a new one being added.
+func (f *Filter) ReplaceActions(info *Object, actions []*Action) error {
+	if info == nil {
+		return ErrNoArg
+	}
+	if prio, _ := core.SplitFilterInfo(info.Info); info.Handle == 0 || prio == 0 {
+		return fmt.Errorf("%s: Handle and priority of the filter are required: %w", info.Kind, ErrNoArg)
+	}
+	replaced := planObject(info)
+	edited := make([]*Action, 0, len(actions))
+	for _, action := range actions {
+		if action == nil {
+			return fmt.Errorf("%s: Action: %w", info.Kind, ErrNoArg)
+		}
+		c := *action
+		c.Stats, c.UsedHwStats, c.InHwCount = nil, nil, nil
+		edited = append(edited, &c)
+	}
+	if err := setFilterActions(&replaced, edited); err != nil {
+		return err
+	}
+	return f.Replace(&replaced)
+}
+
+// setFilterActions sets the actions of the filter obj. No actions remove
+// all actions of obj.
+func setFilterActions(obj *Object, actions []*Action) error {
+	var list *[]*Action
+	if len(actions) > 0 {
+		list = &actions
+	}
+	switch obj.Kind {
+	case "bpf", "cgroup":
+		if len(actions) > 1 {
+			return fmt.Errorf("%s: takes a single action but got %d: %w", obj.Kind, len(actions), ErrInvalidArg)
+		}
+		var action *Action
+		if len(actions) == 1 {
+			action = actions[0]
+		}
+		if obj.Kind == "bpf" && obj.BPF != nil {
+			obj.BPF.Action = action
+			return nil
+		}
+		if obj.Kind == "cgroup" && obj.Cgroup != nil {
+			obj.Cgroup.Action = action
+			return nil
+		}
+	case "basic":
+		if obj.Basic != nil {
+			obj.Basic.Actions = list
+			return nil
+		}
+	case "flow":
+		if obj.Flow != nil {
+			obj.Flow.Actions = list
+			return nil
+		}
+	case "flower":
+		if obj.Flower != nil {
+			obj.Flower.Actions = list
+			return nil
+		}
+	case "fw":
+		if obj.Fw != nil {
+			obj.Fw.Actions = list
+			return nil
+		}
+	case "matchall":
+		if obj.Matchall != nil {
+			obj.Matchall.Actions = list
+			return nil
+		}
+	case "route4":
+		if obj.Route4 != nil {
+			obj.Route4.Actions = list
+			return nil
+		}
+	case "rsvp":
+		if obj.Rsvp != nil {
+			obj.Rsvp.Actions = list
+			return nil
+		}
+	case "tcindex":
+		if obj.TcIndex != nil {
+			obj.TcIndex.Actions = list
+			return nil
+		}
+	case "u32":
+		if obj.U32 != nil {
+			obj.U32.Actions = list
+			return nil
+		}
+	default:
+		return fmt.Errorf("%s: filter without actions: %w", obj.Kind, ErrInvalidArg)
+	}
+	return fmt.Errorf("%s: options of the filter: %w", obj.Kind, ErrNoArg)
+}
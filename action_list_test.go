@@ -0,0 +1,243 @@
+package tc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nltest"
+)
+
+func TestActionList(t *testing.T) {
+	gact := func() *Action { return &Action{Kind: "gact", Gact: &Gact{Parms: &GactParms{Action: ActOk}}} }
+	mirred := func() *Action {
+		return &Action{Kind: "mirred", Mirred: &Mirred{Parms: &MirredParam{Index: 42, Action: ActStolen}}}
+	}
+	police := func() *Action {
+		return &Action{Kind: "police", Police: &Police{AvRate: uint32Ptr(1337), Result: uint32Ptr(ActOk)}}
+	}
+
+	tests := map[string]struct {
+		edit  func(l *ActionList) error
+		kinds []string
+		err   error
+	}{
+		"append police": {
+			edit:  func(l *ActionList) error { l.Append(police()); return nil },
+			kinds: []string{"gact", "mirred", "police"},
+		},
+		"insert first": {
+			edit:  func(l *ActionList) error { return l.InsertAt(0, police(), police()) },
+			kinds: []string{"police", "police", "gact", "mirred"},
+		},
+		"insert at end": {
+			edit:  func(l *ActionList) error { return l.InsertAt(2, police()) },
+			kinds: []string{"gact", "mirred", "police"},
+		},
+		"insert out of range": {
+			edit: func(l *ActionList) error { return l.InsertAt(3, police()) },
+			err:  ErrInvalidArg,
+		},
+		"remove first": {
+			edit:  func(l *ActionList) error { return l.Remove(0) },
+			kinds: []string{"mirred"},
+		},
+		"remove out of range": {
+			edit: func(l *ActionList) error { return l.Remove(2) },
+			err:  ErrInvalidArg,
+		},
+		"remove mirred": {
+			edit: func(l *ActionList) error {
+				if n := l.RemoveKind("mirred"); n != 1 {
+					return errors.New("mirred not removed")
+				}
+				return nil
+			},
+			kinds: []string{"gact"},
+		},
+		"move to front": {
+			edit:  func(l *ActionList) error { l.Append(police()); return l.Move(2, 0) },
+			kinds: []string{"police", "gact", "mirred"},
+		},
+		"move to end": {
+			edit:  func(l *ActionList) error { l.Append(police()); return l.Move(0, 2) },
+			kinds: []string{"mirred", "police", "gact"},
+		},
+		"move out of range": {
+			edit: func(l *ActionList) error { return l.Move(0, 2) },
+			err:  ErrInvalidArg,
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			orig := &[]*Action{gact(), mirred()}
+			filter := Object{Msg{Ifindex: 42, Handle: 1, Parent: HandleIngress, Info: core.BuildFilterInfo(1, core.EthPAll)},
+				Attribute{Kind: "matchall", Matchall: &Matchall{Actions: orig}}}
+			list := ActionsOf(&filter)
+			err := testcase.edit(&list)
+			if !errors.Is(err, testcase.err) {
+				t.Fatalf("expected error %v but got %v", testcase.err, err)
+			}
+			if testcase.err != nil {
+				return
+			}
+			if len(*filter.Matchall.Actions) != 2 || (*orig)[0].Kind != "gact" || (*orig)[1].Kind != "mirred" {
+				t.Fatalf("the edit changed the actions of the filter")
+			}
+
+			var request []byte
+			tcSocket := &Tc{
+				con: nltest.Dial(func(req []netlink.Message) ([]netlink.Message, error) {
+					if req[0].Header.Type == unix.RTM_NEWTFILTER {
+						request = req[0].Data
+					}
+					return []netlink.Message{{Header: netlink.Header{Type: netlink.Error}, Data: make([]byte, 4)}}, nil
+				}),
+			}
+			defer tcSocket.Close()
+			if err := tcSocket.Filter().ReplaceActions(&filter, list); err != nil {
+				t.Fatalf("could not replace actions: %v", err)
+			}
+
+			types, kinds := requestedActions(t, request)
+			var wantTypes []uint16
+			for i := range testcase.kinds {
+				wantTypes = append(wantTypes, uint16(i+1))
+			}
+			if diff := cmp.Diff(wantTypes, types); diff != "" {
+				t.Fatalf("nested attribute types missmatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(testcase.kinds, kinds); diff != "" {
+				t.Fatalf("action kinds missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReplaceActions(t *testing.T) {
+	msg := Msg{Family: unix.AF_UNSPEC, Ifindex: 42, Handle: 1, Parent: HandleIngress,
+		Info: core.BuildFilterInfo(1, core.EthPAll)}
+	gact := &Action{Kind: "gact", Index: 7, Gact: &Gact{Parms: &GactParms{Action: ActShot}},
+		Stats: &GenStats{Basic: &GenBasic{Bytes: 1}}}
+
+	tests := map[string]struct {
+		filter  Object
+		actions []*Action
+		want    *Object
+		err     error
+	}{
+		"flower": {
+			filter: Object{msg, Attribute{Kind: "flower", Stats: &Stats{Bytes: 42}, Flower: &Flower{
+				ClassID: uint32Ptr(0x10001), Flags: uint32Ptr(SkipHw)}}},
+			actions: []*Action{gact},
+			want: &Object{msg, Attribute{Kind: "flower", Priority: 1, Protocol: core.EthPAll, Flower: &Flower{
+				ClassID: uint32Ptr(0x10001), Flags: uint32Ptr(SkipHw), Actions: &[]*Action{{Kind: "gact", Index: 7,
+					Gact: &Gact{Parms: &GactParms{Action: ActShot}}}}}}},
+		},
+		"remove all": {
+			filter: Object{msg, Attribute{Kind: "u32", U32: &U32{ClassID: uint32Ptr(0x10001), Sel: &U32Sel{Flags: U32Terminal},
+				Actions: &[]*Action{gact}}}},
+			want: &Object{msg, Attribute{Kind: "u32", Priority: 1, Protocol: core.EthPAll,
+				U32: &U32{ClassID: uint32Ptr(0x10001), Sel: &U32Sel{Flags: U32Terminal}}}},
+		},
+		"bpf": {
+			filter:  Object{msg, Attribute{Kind: "bpf", BPF: &Bpf{ClassID: uint32Ptr(0x10001)}}},
+			actions: []*Action{gact, gact},
+			err:     ErrInvalidArg,
+		},
+		"without handle": {
+			filter: Object{Msg{Ifindex: 42, Parent: HandleIngress, Info: core.BuildFilterInfo(1, core.EthPAll)},
+				Attribute{Kind: "matchall", Matchall: &Matchall{}}},
+			err: ErrNoArg,
+		},
+		"without options": {
+			filter: Object{msg, Attribute{Kind: "matchall"}},
+			err:    ErrNoArg,
+		},
+		"nil action": {
+			filter:  Object{msg, Attribute{Kind: "matchall", Matchall: &Matchall{}}},
+			actions: []*Action{nil},
+			err:     ErrNoArg,
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			var request netlink.Message
+			tcSocket := &Tc{
+				con: nltest.Dial(func(req []netlink.Message) ([]netlink.Message, error) {
+					if req[0].Header.Type == unix.RTM_NEWTFILTER {
+						request = req[0]
+						if req[0].Header.Flags&netlink.Excl != 0 {
+							t.Fatalf("the filter was added instead of replaced")
+						}
+					}
+					return []netlink.Message{{Header: netlink.Header{Type: netlink.Error}, Data: make([]byte, 4)}}, nil
+				}),
+			}
+			defer tcSocket.Close()
+			err := tcSocket.Filter().ReplaceActions(&testcase.filter, testcase.actions)
+			if !errors.Is(err, testcase.err) {
+				t.Fatalf("expected error %v but got %v", testcase.err, err)
+			}
+			if testcase.err != nil {
+				return
+			}
+			got, err := UnmarshalObject(request)
+			if err != nil {
+				t.Fatalf("could not decode request: %v", err)
+			}
+			if diff := cmp.Diff(testcase.want, got); diff != "" {
+				t.Fatalf("request missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// requestedActions returns the types of the nested attributes of the actions
+// of the matchall filter request data and the kinds of the actions.
+func requestedActions(t *testing.T, data []byte) ([]uint16, []string) {
+	t.Helper()
+	_, attrs, err := unmarshalTcmsg(data)
+	if err != nil {
+		t.Fatalf("could not decode Msg: %v", err)
+	}
+	var types []uint16
+	var kinds []string
+	ad, err := newDecoder(attrs)
+	if err != nil {
+		t.Fatalf("could not decode attributes: %v", err)
+	}
+	for ad.Next() {
+		if ad.Type() != tcaOptions {
+			continue
+		}
+		ad.Nested(func(od *netlink.AttributeDecoder) error {
+			for od.Next() {
+				if od.Type() != tcaMatchallAct {
+					continue
+				}
+				od.Nested(func(actions *netlink.AttributeDecoder) error {
+					for actions.Next() {
+						types = append(types, actions.Type())
+						action := &Action{}
+						if err := unmarshalAction(actions.Bytes(), action); err != nil {
+							return err
+						}
+						kinds = append(kinds, action.Kind)
+					}
+					return nil
+				})
+			}
+			return nil
+		})
+	}
+	if err := ad.Err(); err != nil {
+		t.Fatalf("could not decode options: %v", err)
+	}
+	return types, kinds
+}
@@ -1,8 +1,6 @@
 package tc
 
 import (
-	"fmt"
-
 	"github.com/florianl/go-tc/internal/unix"
 	"github.com/mdlayher/netlink"
 )
@@ -104,7 +102,11 @@ func (a *Actions) Get(actions []*Action) ([]*Action, error) {
 
 	for _, msg := range msgs {
 		// The first 4 bytes contain tcaMsg - which is skipped here.
-		if err := unmarshalRoot(msg.Data[4:], &results); err != nil {
+		attrs, err := unmarshalStructAttrs(msg.Data, &tcaMsg{})
+		if err != nil {
+			return results, err
+		}
+		if err := unmarshalRoot(attrs, &results); err != nil {
 			return results, err
 		}
 	}
@@ -133,7 +135,7 @@ const (
 )
 
 func unmarshalRoot(data []byte, actions *[]*Action) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -152,7 +154,7 @@ func unmarshalRoot(data []byte, actions *[]*Action) error {
 		case tcaRootExtWarnMsg:
 			_ = ad.String()
 		default:
-			return fmt.Errorf("unmarshalRoot()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("actions", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
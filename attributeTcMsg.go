@@ -1,24 +1,28 @@
 package tc
 
-import (
-	"encoding/binary"
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 func extractTcmsgAttributes(action int, data []byte, info *Attribute) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	return extractTcmsg(action, data, info, false)
+}
+
+// extractTcmsg decodes the attributes of a tcmsg. If lazyStats is set, the
+// statistics are kept undecoded in info.RawStats.
+func extractTcmsg(action int, data []byte, info *Attribute, lazyStats bool) error {
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
 	var options []byte
 	var xStats []byte
 	var multiError error
+	if lazyStats {
+		info.RawStats = &RawStats{}
+	}
 	for ad.Next() {
 		switch ad.Type() {
 		case tcaKind:
-			info.Kind = ad.String()
+			info.Kind = kindString(ad.Bytes())
 		case tcaOptions:
 			// the evaluation of this field depends on tcaKind.
 			// there is no guarantee, that kind is known at this moment,
@@ -27,18 +31,30 @@ func extractTcmsgAttributes(action int, data []byte, info *Attribute) error {
 		case tcaChain:
 			info.Chain = uint32Ptr(ad.Uint32())
 		case tcaXstats:
+			if lazyStats {
+				info.RawStats.XStats = ad.Bytes()
+				break
+			}
 			// the evaluation of this field depends on tcaKind.
 			// there is no guarantee, that kind is know at this moment,
 			// so we save it for later
 			xStats = ad.Bytes()
 		case tcaStats:
+			if lazyStats {
+				info.RawStats.Stats = ad.Bytes()
+				break
+			}
 			tcstats := &Stats{}
 			err := unmarshalStruct(ad.Bytes(), tcstats)
 			multiError = concatError(multiError, err)
 			info.Stats = tcstats
 		case tcaStats2:
+			if lazyStats {
+				info.RawStats.Stats2 = ad.Bytes()
+				break
+			}
 			tcstats2 := &Stats2{}
-			err := unmarshalStruct(ad.Bytes(), tcstats2)
+			err := unmarshalGenStats(ad.Bytes(), tcstats2)
 			multiError = concatError(multiError, err)
 			info.Stats2 = tcstats2
 		case tcaHwOffload:
@@ -57,13 +73,13 @@ func extractTcmsgAttributes(action int, data []byte, info *Attribute) error {
 		case tcaExtWarnMsg:
 			info.ExtWarnMsg = ad.String()
 		default:
-			return fmt.Errorf("extractTcmsgAttributes()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("tcmsg", ad.Type(), ad.Bytes()))
 
 		}
 	}
 
-	if err = concatError(multiError, ad.Err()); err != nil {
-		return err
+	if err := ad.Err(); err != nil {
+		return concatError(multiError, err)
 	}
 
 	if len(options) > 0 {
@@ -75,6 +91,11 @@ func extractTcmsgAttributes(action int, data []byte, info *Attribute) error {
 		multiError = concatError(multiError, err)
 	}
 
+	if len(xStats) == 0 && info.Stats2 != nil {
+		// Kernels, that do not emit the legacy TCA_XSTATS, provide
+		// the same payload as TCA_STATS_APP within TCA_STATS2.
+		xStats = info.Stats2.App
+	}
 	if len(xStats) > 0 {
 		tcxstats := &XStats{}
 		err := extractXStats(xStats, tcxstats, info.Kind)
@@ -84,6 +105,95 @@ func extractTcmsgAttributes(action int, data []byte, info *Attribute) error {
 	return multiError
 }
 
+// DecodeStats decodes Stats and Stats2 from RawStats. It does nothing, if
+// the statistics were decoded with the object.
+func (a *Attribute) DecodeStats() error {
+	if a.RawStats == nil {
+		return nil
+	}
+	var multiError error
+	if len(a.RawStats.Stats) > 0 {
+		tcstats := &Stats{}
+		multiError = concatError(multiError, unmarshalStruct(a.RawStats.Stats, tcstats))
+		a.Stats = tcstats
+	}
+	if len(a.RawStats.Stats2) > 0 {
+		tcstats2 := &Stats2{}
+		multiError = concatError(multiError, unmarshalGenStats(a.RawStats.Stats2, tcstats2))
+		a.Stats2 = tcstats2
+	}
+	return multiError
+}
+
+// DecodeXStats decodes XStats from RawStats. Like for eagerly decoded
+// objects, TCA_STATS_APP of Stats2 is used, if the kernel did not emit
+// TCA_XSTATS. It does nothing, if the statistics were decoded with the
+// object.
+func (a *Attribute) DecodeXStats() error {
+	if a.RawStats == nil {
+		return nil
+	}
+	xStats := a.RawStats.XStats
+	if len(xStats) == 0 {
+		stats2 := a.Stats2
+		if stats2 == nil && len(a.RawStats.Stats2) > 0 {
+			stats2 = &Stats2{}
+			if err := unmarshalGenStats(a.RawStats.Stats2, stats2); err != nil {
+				return err
+			}
+		}
+		if stats2 != nil {
+			xStats = stats2.App
+		}
+	}
+	if len(xStats) == 0 {
+		return nil
+	}
+	tcxstats := &XStats{}
+	err := extractXStats(xStats, tcxstats, a.Kind)
+	a.XStats = tcxstats
+	return err
+}
+
+// extractTcmsgStats decodes only the statistics of data and skips all other
+// attributes.
+func extractTcmsgStats(data []byte) (*Stats2, *XStats, error) {
+	ad, err := newDecoder(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	var kind string
+	var xStats []byte
+	var stats2 *Stats2
+	var multiError error
+	for ad.Next() {
+		switch ad.Type() {
+		case tcaKind:
+			kind = kindString(ad.Bytes())
+		case tcaXstats:
+			xStats = ad.Bytes()
+		case tcaStats2:
+			stats2 = &Stats2{}
+			multiError = concatError(multiError, unmarshalGenStats(ad.Bytes(), stats2))
+		}
+	}
+	if err := concatError(multiError, ad.Err()); err != nil {
+		return nil, nil, err
+	}
+
+	if len(xStats) == 0 && stats2 != nil {
+		xStats = stats2.App
+	}
+	var xstats *XStats
+	if len(xStats) > 0 {
+		xstats = &XStats{}
+		if err := extractXStats(xStats, xstats, kind); err != nil {
+			return nil, nil, err
+		}
+	}
+	return stats2, xstats, nil
+}
+
 func hasQOpt(kind string) bool {
 	classful := map[string]bool{
 		"hfsc": true,
@@ -121,6 +231,15 @@ func extractQOpt(data []byte, tc *Attribute, kind string) error {
 }
 
 func extractTCAOptions(data []byte, tc *Attribute, kind string) error {
+	if optionlessKind(kind) {
+		// Empty options of these kinds are left out. Options with a payload
+		// are only sent by kernels, that added options for the kind.
+		if len(data) != 0 {
+			return &optionsError{fmt.Errorf("%s: options with %d bytes for a kind without options: %w",
+				kind, len(data), ErrUnknownAttribute)}
+		}
+		return nil
+	}
 	var multiError error
 	switch kind {
 	case "choke":
@@ -225,10 +344,6 @@ func extractTCAOptions(data []byte, tc *Attribute, kind string) error {
 		err := unmarshalStruct(data, limit)
 		multiError = concatError(multiError, err)
 		tc.Bfifo = limit
-	case "clsact":
-		return extractClsact(data)
-	case "ingress":
-		return extractIngress(data)
 	case "qfq":
 		info := &Qfq{}
 		err := unmarshalQfq(data, info)
@@ -322,6 +437,17 @@ func extractTCAOptions(data []byte, tc *Attribute, kind string) error {
 }
 
 func extractXStats(data []byte, tc *XStats, kind string) error {
+	tc.Kind = kind
+	tc.Raw = data
+	if fn, ok := lookupXStats(kind); ok {
+		custom, err := fn(data)
+		if err != nil {
+			return fmt.Errorf("extractXStats(): %s: %w", kind, err)
+		}
+		tc.Custom = custom
+		return nil
+	}
+
 	var multiError error
 	switch kind {
 	case "sfb":
@@ -346,7 +472,9 @@ func extractXStats(data []byte, tc *XStats, kind string) error {
 		tc.Choke = info
 	case "htb":
 		info := &HtbXStats{}
-		err := unmarshalStruct(data, info)
+		// HTB reports its statistics on class dumps. Payloads, that are
+		// padded beyond tc_htb_xstats, are decoded in the same way.
+		err := unmarshalStruct(padStruct(data, info), info)
 		multiError = concatError(multiError, err)
 		tc.Htb = info
 	case "cbq":
@@ -378,9 +506,7 @@ func extractXStats(data []byte, tc *XStats, kind string) error {
 		info := &FqQdStats{}
 		// Pad out data to size of our FqQdStats struct to handle
 		// unmarshalling data from older kernel versions with smaller structs
-		qd := make([]byte, binary.Size(info))
-		copy(qd, data)
-		err := unmarshalStruct(qd, info)
+		err := unmarshalStruct(padStruct(data, info), info)
 		multiError = concatError(multiError, err)
 		tc.Fq = info
 	case "hfsc":
@@ -389,27 +515,11 @@ func extractXStats(data []byte, tc *XStats, kind string) error {
 		multiError = concatError(multiError, err)
 		tc.Hfsc = info
 	default:
-		return fmt.Errorf("extractXStats(): unsupported kind: %s", kind)
+		// Unknown kinds are kept in Raw only.
 	}
 	return multiError
 }
 
-func extractClsact(data []byte) error {
-	// Clsact is parameterless - so we expect to options
-	if len(data) != 0 {
-		return fmt.Errorf("clsact is parameterless: %w", ErrInvalidArg)
-	}
-	return nil
-}
-
-func extractIngress(data []byte) error {
-	// Ingress is parameterless - so we expect to options
-	if len(data) != 0 {
-		return fmt.Errorf("extractIngress()\t%v", data)
-	}
-	return nil
-}
-
 const (
 	tcaUnspec = iota
 	tcaKind
@@ -155,6 +155,41 @@ func generateQfq(t *testing.T) []byte {
 	return data
 }
 
+// generateFqCodelClass returns the dump of a single flow of fq_codel, like
+// `tc -s class show dev eth0` reports it, together with its xstats.
+func generateFqCodelClass(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+	options := []tcOption{}
+	options = append(options, tcOption{Interpretation: vtString, Type: tcaKind, Data: "fq_codel"})
+	xstats, err := marshalXStats(XStats{FqCodel: &FqCodelXStats{Type: tcaFqCodelXStatsClass,
+		Cl: &FqCodelClStats{Deficit: -42, LDelay: 1000, Count: 2, LastCount: 1, Dropping: 1, DropNext: -5}}})
+	if err != nil {
+		t.Fatalf("could not generate xstats: %v", err)
+	}
+	options = append(options, tcOption{Interpretation: vtBytes, Type: tcaXstats, Data: xstats})
+
+	data, err := marshalAttributes(options)
+	if err != nil {
+		t.Fatalf("could not generate test data: %v", err)
+	}
+	return data, xstats
+}
+
+// generateOptionless returns a qdisc of kind with the options data, like
+// linux/net/sched/sch_ingress.c:ingress_dump() sends an empty TCA_OPTIONS.
+func generateOptionless(t *testing.T, kind string, data []byte) []byte {
+	t.Helper()
+	options := []tcOption{
+		{Interpretation: vtString, Type: tcaKind, Data: kind},
+		{Interpretation: vtBytes, Type: tcaOptions, Data: data},
+	}
+	data, err := marshalAttributes(options)
+	if err != nil {
+		t.Fatalf("could not generate test data: %v", err)
+	}
+	return data
+}
+
 func generateUnknown(t *testing.T) []byte {
 	t.Helper()
 	options := []tcOption{}
@@ -167,7 +202,30 @@ func generateUnknown(t *testing.T) []byte {
 	return data
 }
 
+// generatePaddedKind returns a pfifo qdisc, whose kind is padded with NULs
+// after the terminating one, as some kernels report it.
+func generatePaddedKind(t *testing.T) []byte {
+	t.Helper()
+	pfifo, _ := marshalStruct(&FifoOpt{Limit: 123})
+	data, err := marshalAttributes([]tcOption{
+		{Interpretation: vtBytes, Type: tcaKind, Data: []byte("pfifo\x00\x00\x00")},
+		{Interpretation: vtBytes, Type: tcaOptions, Data: pfifo},
+	})
+	if err != nil {
+		t.Fatalf("could not generate test data: %v", err)
+	}
+	return data
+}
+
 func TestExtractTcmsgAttributes(t *testing.T) {
+	htbXStats := HtbXStats{Lends: 0x02, Borrows: 0x03, Giants: 0x04, Tokens: 0x05, CTokens: 0x06}
+	htbRawXStats, err := marshalStruct(&htbXStats)
+	if err != nil {
+		t.Fatalf("could not marshal xstats: %v", err)
+	}
+
+	fqCodelClass, fqCodelClassXStats := generateFqCodelClass(t)
+
 	tests := map[string]struct {
 		input    []byte
 		expected *Attribute
@@ -181,13 +239,26 @@ func TestExtractTcmsgAttributes(t *testing.T) {
 		}},
 		"htb": {input: generateHtb(t), expected: &Attribute{
 			Kind:   "htb",
-			XStats: &XStats{Htb: &HtbXStats{Lends: 0x02, Borrows: 0x03, Giants: 0x04, Tokens: 0x05, CTokens: 0x06}},
+			XStats: &XStats{Htb: &htbXStats, Kind: "htb", Raw: htbRawXStats},
 			Htb:    &Htb{DirectQlen: uint32Ptr(0x7b), Rate64: uint64Ptr(0xea), Ceil64: uint64Ptr(0x0159)},
 		}},
+		"fq_codel class": {input: fqCodelClass, expected: &Attribute{
+			Kind: "fq_codel",
+			XStats: &XStats{Kind: "fq_codel", Raw: fqCodelClassXStats,
+				FqCodel: &FqCodelXStats{Type: tcaFqCodelXStatsClass, Cl: &FqCodelClStats{
+					Deficit: -42, LDelay: 1000, Count: 2, LastCount: 1, Dropping: 1, DropNext: -5}}},
+		}},
 		"pfifo": {input: generatePfifo(t), expected: &Attribute{
 			Kind:  "pfifo",
 			Pfifo: &FifoOpt{Limit: 123}, Stats: &Stats{Bytes: 123, Packets: 321, Drops: 0, Overlimits: 42},
 		}},
+		"ingress": {input: generateOptionless(t, "ingress", []byte{}), expected: &Attribute{Kind: "ingress"}},
+		"mq":      {input: generateOptionless(t, "mq", []byte{}), expected: &Attribute{Kind: "mq"}},
+		"teql0":   {input: generateOptionless(t, "teql0", []byte{}), expected: &Attribute{Kind: "teql0"}},
+		"ingress+options": {input: generateOptionless(t, "ingress", []byte{0x8, 0x0, 0x1, 0x0, 0x2a, 0x0, 0x0, 0x0}),
+			err: ErrUnknownAttribute},
+		"mq+options": {input: generateOptionless(t, "mq", []byte{0x8, 0x0, 0x1, 0x0, 0x2a, 0x0, 0x0, 0x0}),
+			err: ErrUnknownAttribute},
 		"clsact+stab": {input: generateClsactStab(t), expected: &Attribute{
 			Kind: "clsact",
 			Stab: &Stab{Base: &SizeSpec{CellLog: 0x2a, LinkLayer: 0x01, MTU: 0x05d4}},
@@ -208,6 +279,10 @@ func TestExtractTcmsgAttributes(t *testing.T) {
 			Kind: "qfq",
 			Qfq:  &Qfq{Weight: uint32Ptr(1), Lmax: uint32Ptr(2)},
 		}},
+		"padded kind": {input: generatePaddedKind(t), expected: &Attribute{
+			Kind:  "pfifo",
+			Pfifo: &FifoOpt{Limit: 123},
+		}},
 	}
 
 	for name, testcase := range tests {
@@ -239,6 +314,7 @@ func TestExtractTCAOptions(t *testing.T) {
 	}{
 		"clsact":         {kind: "clsact", expected: &Attribute{}},
 		"clsactWithData": {kind: "clsact", data: []byte{0xde, 0xad, 0xc0, 0xde}, err: ErrInvalidArg},
+		"clsactUnknown":  {kind: "clsact", data: []byte{0xde, 0xad, 0xc0, 0xde}, err: ErrUnknownAttribute},
 		"ingress":        {kind: "ingress", expected: &Attribute{}},
 		"unknown":        {kind: "unknown", err: ErrUnknownKind},
 		"tbf":            {kind: "tbf", expected: &Attribute{Tbf: &Tbf{}}},
@@ -288,7 +364,7 @@ func TestFilterAttribute(t *testing.T) {
 		}}},
 		"fw":     {val: &Attribute{Kind: "fw", Fw: &Fw{ClassID: uint32Ptr(12), InDev: stringPtr("lo"), Mask: uint32Ptr(0xFFFF)}}},
 		"route4": {val: &Attribute{Kind: "route4", Route4: &Route4{ClassID: uint32Ptr(0xFFFF), To: uint32Ptr(2), From: uint32Ptr(3), IIf: uint32Ptr(4)}}},
-		"rsvp":   {val: &Attribute{Kind: "rsvp", Rsvp: &Rsvp{ClassID: uint32Ptr(42), Police: &Police{AvRate: uint32Ptr(1337), Result: uint32Ptr(12)}}}},
+		"rsvp":   {val: &Attribute{Kind: "rsvp", Rsvp: &Rsvp{ClassID: uint32Ptr(42), Police: &Police{AvRate: uint32Ptr(1337), Result: uint32Ptr(12), Conform: policyActionPtr(12)}}}},
 		"u32":    {val: &Attribute{Kind: "u32", U32: &U32{ClassID: uint32Ptr(0xFFFF), Mark: &U32Mark{Val: 0x55, Mask: 0xAA, Success: 0x1}}}},
 	}
 
@@ -361,7 +437,7 @@ func TestQdiscAttribute(t *testing.T) {
 		"hfsc":         {val: &Attribute{Kind: "hfsc", HfscQOpt: &HfscQOpt{DefCls: 42}}},
 		"hhf":          {val: &Attribute{Kind: "hhf", Hhf: &Hhf{BacklogLimit: uint32Ptr(1), Quantum: uint32Ptr(2), HHFlowsLimit: uint32Ptr(3), ResetTimeout: uint32Ptr(4), AdmitBytes: uint32Ptr(5), EVICTTimeout: uint32Ptr(6), NonHHWeight: uint32Ptr(7)}}},
 		"htb":          {val: &Attribute{Kind: "htb", Htb: &Htb{Init: &HtbGlob{Version: 0x3, Rate2Quantum: 0xa, Defcls: 0x30}}}},
-		"mqprio":       {val: &Attribute{Kind: "mqprio", MqPrio: &MqPrio{Opt: &MqPrioQopt{}, Mode: uint16Ptr(1), Shaper: uint16Ptr(2), MinRate64: uint64Ptr(3), MaxRate64: uint64Ptr(4)}}},
+		"mqprio":       {val: &Attribute{Kind: "mqprio", MqPrio: &MqPrio{Opt: &MqPrioQopt{NumTc: 1}, Mode: uint16Ptr(1), Shaper: uint16Ptr(2), MinRate64: uint64Ptr(3), MaxRate64: uint64Ptr(4)}}},
 		"pie":          {val: &Attribute{Kind: "pie", Pie: &Pie{Target: uint32Ptr(1), Limit: uint32Ptr(2), TUpdate: uint32Ptr(3), Alpha: uint32Ptr(4), Beta: uint32Ptr(5), ECN: uint32Ptr(6), Bytemode: uint32Ptr(7)}}},
 		"qfq":          {val: &Attribute{Kind: "qfq"}},
 		"red":          {val: &Attribute{Kind: "red", Red: &Red{MaxP: uint32Ptr(2), Parms: &RedQOpt{QthMin: 2, QthMax: 4}}}},
@@ -440,3 +516,148 @@ func TestClassAttribute(t *testing.T) {
 		})
 	}
 }
+
+func TestExtractXStatsPlacement(t *testing.T) {
+	codel := CodelXStats{MaxPacket: 1514, Count: 2, LDelay: 42}
+	codelData, err := marshalXStats(XStats{Codel: &codel})
+	if err != nil {
+		t.Fatalf("could not marshal codel xstats: %v", err)
+	}
+	otherData, err := marshalXStats(XStats{Codel: &CodelXStats{MaxPacket: 1}})
+	if err != nil {
+		t.Fatalf("could not marshal codel xstats: %v", err)
+	}
+	fqCodel := FqCodelXStats{Type: tcaFqCodelXStatsQdisc, Qd: &FqCodelQdStats{MaxPacket: 1514, NewFlowCount: 3}}
+	fqCodelData, err := marshalXStats(XStats{FqCodel: &fqCodel})
+	if err != nil {
+		t.Fatalf("could not marshal fq_codel xstats: %v", err)
+	}
+
+	tests := map[string]struct {
+		kind   string
+		legacy []byte
+		app    []byte
+		want   XStats
+	}{
+		"codel TCA_XSTATS": {kind: "codel", legacy: codelData,
+			want: XStats{Kind: "codel", Raw: codelData, Codel: &codel}},
+		"codel TCA_STATS_APP": {kind: "codel", app: codelData,
+			want: XStats{Kind: "codel", Raw: codelData, Codel: &codel}},
+		"codel both": {kind: "codel", legacy: codelData, app: otherData,
+			want: XStats{Kind: "codel", Raw: codelData, Codel: &codel}},
+		"fq_codel TCA_STATS_APP": {kind: "fq_codel", app: fqCodelData,
+			want: XStats{Kind: "fq_codel", Raw: fqCodelData, FqCodel: &fqCodel}},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			stats2, err := marshalGenStats(&Stats2{Basic: &GenBasic{Bytes: 1}, App: testcase.app})
+			if err != nil {
+				t.Fatalf("could not marshal stats2: %v", err)
+			}
+			options := []tcOption{
+				{Interpretation: vtString, Type: tcaKind, Data: testcase.kind},
+				{Interpretation: vtBytes, Type: tcaStats2, Data: stats2},
+			}
+			if testcase.legacy != nil {
+				options = append(options, tcOption{Interpretation: vtBytes, Type: tcaXstats, Data: testcase.legacy})
+			}
+			data, err := marshalAttributes(options)
+			if err != nil {
+				t.Fatalf("could not marshal attributes: %v", err)
+			}
+
+			attr := Attribute{}
+			if err := extractTcmsgAttributes(actionQdisc, data, &attr); err != nil {
+				t.Fatalf("could not extract attributes: %v", err)
+			}
+			if diff := cmp.Diff(&testcase.want, attr.XStats); diff != "" {
+				t.Fatalf("XStats missmatch (-want +got):\n%s", diff)
+			}
+
+			_, xstats, err := extractTcmsgStats(data)
+			if err != nil {
+				t.Fatalf("could not extract stats: %v", err)
+			}
+			if diff := cmp.Diff(&testcase.want, xstats); diff != "" {
+				t.Fatalf("XStats of extractTcmsgStats missmatch (-want +got):\n%s", diff)
+			}
+
+			lazy := Attribute{}
+			if err := extractTcmsg(actionQdisc, data, &lazy, true); err != nil {
+				t.Fatalf("could not extract attributes lazily: %v", err)
+			}
+			if err := lazy.DecodeXStats(); err != nil {
+				t.Fatalf("could not decode xstats: %v", err)
+			}
+			if diff := cmp.Diff(&testcase.want, lazy.XStats); diff != "" {
+				t.Fatalf("XStats of DecodeXStats missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLazyStats(t *testing.T) {
+	stats2, err := marshalGenStats(&Stats2{
+		Basic: &GenBasic{Bytes: 1 << 40, Packets: 1 << 20},
+		Queue: &GenQueue{QueueLen: 3, Drops: 42},
+	})
+	if err != nil {
+		t.Fatalf("could not marshal stats2: %v", err)
+	}
+	data, err := marshalAttributes([]tcOption{
+		{Interpretation: vtBytes, Type: tcaStats2, Data: stats2},
+	})
+	if err != nil {
+		t.Fatalf("could not marshal attributes: %v", err)
+	}
+	tests := map[string][]byte{
+		"htb":   append(generateHtb(t), data...),
+		"pfifo": append(generatePfifo(t), data...),
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			eager := Attribute{}
+			if err := extractTcmsgAttributes(actionQdisc, data, &eager); err != nil {
+				t.Fatalf("could not extract attributes: %v", err)
+			}
+
+			lazy := Attribute{}
+			if err := extractTcmsg(actionQdisc, data, &lazy, true); err != nil {
+				t.Fatalf("could not extract attributes lazily: %v", err)
+			}
+			if lazy.Stats != nil || lazy.Stats2 != nil || lazy.XStats != nil {
+				t.Fatalf("statistics were decoded eagerly")
+			}
+			if lazy.RawStats == nil || len(lazy.RawStats.Stats2) == 0 {
+				t.Fatalf("raw statistics are missing: %v", lazy.RawStats)
+			}
+			if err := lazy.DecodeStats(); err != nil {
+				t.Fatalf("could not decode stats: %v", err)
+			}
+			if err := lazy.DecodeXStats(); err != nil {
+				t.Fatalf("could not decode xstats: %v", err)
+			}
+			lazy.RawStats = nil
+			if diff := cmp.Diff(eager, lazy); diff != "" {
+				t.Fatalf("attribute missmatch (-eager +lazy):\n%s", diff)
+			}
+
+			// Objects, that were decoded eagerly, are left untouched.
+			if err := eager.DecodeStats(); err != nil {
+				t.Fatalf("could not decode stats: %v", err)
+			}
+			if err := eager.DecodeXStats(); err != nil {
+				t.Fatalf("could not decode xstats: %v", err)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		attr := Attribute{RawStats: &RawStats{Stats: []byte{0x1}}}
+		if err := attr.DecodeStats(); err == nil {
+			t.Fatalf("expected an error for truncated stats")
+		}
+	})
+}
@@ -20,7 +20,7 @@ func TestChain(t *testing.T) {
 		Family:  unix.AF_UNSPEC,
 		Ifindex: 1337,
 		Handle:  core.BuildHandle(0xFFFF, 0x0000),
-		Parent:  0xFFFFFFF1,
+		Parent:  HandleIngress,
 		Info:    0,
 	}
 
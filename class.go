@@ -3,6 +3,7 @@ package tc
 import (
 	"fmt"
 
+	"github.com/florianl/go-tc/core"
 	"github.com/florianl/go-tc/internal/unix"
 	"github.com/mdlayher/netlink"
 )
@@ -22,11 +23,17 @@ func (c *Class) Add(info *Object) error {
 	if info == nil {
 		return ErrNoArg
 	}
+	if err := c.validateClass(info); err != nil {
+		return err
+	}
 	options, err := validateClassObject(unix.RTM_NEWTCLASS, info)
 	if err != nil {
 		return err
 	}
-	return c.action(unix.RTM_NEWTCLASS, netlink.Create|netlink.Excl, &info.Msg, options)
+	if err := c.action(unix.RTM_NEWTCLASS, netlink.Create|netlink.Excl, &info.Msg, options); err != nil {
+		return err
+	}
+	return c.verifyWrite(unix.RTM_GETTCLASS, info)
 }
 
 // Replace add/remove a class. If the node does not exist yet it is created
@@ -34,11 +41,17 @@ func (c *Class) Replace(info *Object) error {
 	if info == nil {
 		return ErrNoArg
 	}
+	if err := c.validateClass(info); err != nil {
+		return err
+	}
 	options, err := validateClassObject(unix.RTM_NEWTCLASS, info)
 	if err != nil {
 		return err
 	}
-	return c.action(unix.RTM_NEWTCLASS, netlink.Create, &info.Msg, options)
+	if err := c.action(unix.RTM_NEWTCLASS, netlink.Create, &info.Msg, options); err != nil {
+		return err
+	}
+	return c.verifyWrite(unix.RTM_GETTCLASS, info)
 }
 
 // Delete removes a class
@@ -46,7 +59,7 @@ func (c *Class) Delete(info *Object) error {
 	if info == nil {
 		return ErrNoArg
 	}
-	options, err := validateClassObject(unix.RTM_DELTCLASS, info)
+	options, err := validateDeleteObject(unix.RTM_DELTCLASS, info)
 	if err != nil {
 		return err
 	}
@@ -61,11 +74,81 @@ func (c *Class) Get(i *Msg) ([]Object, error) {
 	return c.get(unix.RTM_GETTCLASS, i)
 }
 
+// Walk calls fn for every class, that matches i, as soon as it is received.
+// The Object passed to fn is reused for the next class, so use Object.Copy
+// to retain it. If fn returns an error, Walk stops and returns it.
+func (c *Class) Walk(i *Msg, fn func(*Object) error) error {
+	if i == nil {
+		return ErrNoArg
+	}
+	return c.walk(unix.RTM_GETTCLASS, i, fn)
+}
+
+// Children fetches the direct children of the class or qdisc parent of
+// ifindex. parent is passed to the kernel, which only dumps the classes of
+// the qdisc of parent, and the classes, that are no direct children of
+// parent, are dropped from the reply. Parent of the returned classes is set
+// from the reply of the kernel.
+func (c *Class) Children(ifindex, parent uint32) ([]Object, error) {
+	if ifindex == 0 {
+		return nil, ErrInvalidDev
+	}
+	if parent == 0 || parent == HandleRoot {
+		return nil, fmt.Errorf("parent %x: %w", parent, ErrInvalidArg)
+	}
+	var children []Object
+	err := c.walk(unix.RTM_GETTCLASS, &Msg{
+		Family:  unix.AF_UNSPEC,
+		Ifindex: ifindex,
+		Parent:  parent,
+	}, func(obj *Object) error {
+		if obj.Ifindex == ifindex && isChildClass(obj, parent) {
+			children = append(children, *obj)
+		}
+		return nil
+	})
+	return children, err
+}
+
+// isChildClass reports whether class is a direct child of the class or
+// qdisc parent.
+func isChildClass(class *Object, parent uint32) bool {
+	maj, min := core.SplitHandle(parent)
+	if classMaj, _ := core.SplitHandle(class.Handle); classMaj != maj || class.Handle == parent {
+		return false
+	}
+	if min != 0 {
+		return class.Parent == parent
+	}
+	// Top level classes refer to root or the qdisc itself as parent.
+	return class.Parent == HandleRoot || class.Parent == parent
+}
+
+// Stats fetches the statistics of the class classid of ifindex. Unlike Get,
+// only this class is requested and only its statistics are decoded, which
+// makes it suitable for frequent polling.
+// Classes, that are offloaded to hardware, report their hardware counters
+// in Stats2.BasicHw without further request.
+// If the class does not exist, an error wrapping syscall.ENOENT is returned.
+func (c *Class) Stats(ifindex, classid uint32) (*Stats2, *XStats, error) {
+	if ifindex == 0 {
+		return nil, nil, ErrInvalidDev
+	}
+	return c.getStats(unix.RTM_GETTCLASS, &Msg{
+		Family:  unix.AF_UNSPEC,
+		Ifindex: ifindex,
+		Handle:  classid,
+	})
+}
+
 func validateClassObject(action int, info *Object) ([]tcOption, error) {
 	options := []tcOption{}
 	if info.Ifindex == 0 {
 		return options, ErrInvalidDev
 	}
+	if err := validateKind(info.Kind); err != nil {
+		return options, err
+	}
 
 	// TODO: improve logic and check combinations
 	var data []byte
@@ -74,7 +157,11 @@ func validateClassObject(action int, info *Object) ([]tcOption, error) {
 	case "hfsc":
 		data, err = marshalHfsc(info.Hfsc)
 	case "qfq":
-		data, err = marshalQfq(info.Qfq)
+		// The kernel requires TCA_OPTIONS for qfq classes, but uses its
+		// defaults for weight and maxpkt, if they are left out.
+		if info.Qfq != nil {
+			data, err = marshalQfq(info.Qfq)
+		}
 	case "htb":
 		data, err = marshalHtb(info.Htb)
 	case "dsmark":
@@ -85,9 +172,9 @@ func validateClassObject(action int, info *Object) ([]tcOption, error) {
 		}
 	}
 	if err != nil {
-		return options, err
+		return options, withKind(info.Kind, err)
 	}
-	if len(data) < 1 && !isDelAction(action) {
+	if len(data) < 1 && !isDelAction(action) && info.Kind != "qfq" {
 		return options, ErrNoArg
 	}
 	options = append(options, tcOption{Interpretation: vtBytes, Type: tcaOptions, Data: data})
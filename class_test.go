@@ -2,10 +2,16 @@ package tc
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"syscall"
 	"testing"
 
 	"github.com/florianl/go-tc/core"
 	"github.com/florianl/go-tc/internal/unix"
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nltest"
 )
 
 func TestClass(t *testing.T) {
@@ -131,3 +137,294 @@ func TestClass(t *testing.T) {
 		})
 	}
 }
+
+// statsConn returns a connection, that answers single object requests with
+// the attributes returned by reply.
+func statsConn(t *testing.T, reply func(Msg) ([]tcOption, error)) (*Tc, func()) {
+	t.Helper()
+
+	c := &Tc{
+		con: nltest.Dial(func(req []netlink.Message) ([]netlink.Message, error) {
+			if len(req) == 0 {
+				return []netlink.Message{}, nil
+			}
+			if req[0].Header.Flags&netlink.Dump != 0 {
+				t.Fatalf("expected a request for a single object, got flags %v", req[0].Header.Flags)
+			}
+			msg, _, err := unmarshalTcmsg(req[0].Data)
+			if err != nil {
+				t.Fatalf("could not decode request: %v", err)
+			}
+			attrs, err := reply(msg)
+			if err != nil {
+				return nil, err
+			}
+			data, err := marshalAttributes(attrs)
+			if err != nil {
+				t.Fatalf("could not encode attributes: %v", err)
+			}
+			tcmsg, err := marshalStruct(&msg)
+			if err != nil {
+				t.Fatalf("could not encode Msg: %v", err)
+			}
+			return []netlink.Message{{
+				Header: netlink.Header{
+					Type:     unix.RTM_NEWTCLASS,
+					Sequence: req[0].Header.Sequence,
+					PID:      req[0].Header.PID,
+				},
+				Data: append(tcmsg, data...),
+			}}, nil
+		}),
+	}
+
+	return c, func() {
+		if err := c.Close(); err != nil {
+			t.Fatalf("failed to close: %v", err)
+		}
+	}
+}
+
+func TestValidateQfqClass(t *testing.T) {
+	weight, err := marshalAttributes([]tcOption{{Interpretation: vtUint32, Type: tcaQfqWeight, Data: uint32(10)}})
+	if err != nil {
+		t.Fatalf("could not encode options: %v", err)
+	}
+	tests := map[string]struct {
+		qfq     *Qfq
+		options []byte
+	}{
+		"without options": {},
+		"empty options":   {qfq: &Qfq{}, options: []byte{}},
+		"weight":          {qfq: &Qfq{Weight: uint32Ptr(10)}, options: weight},
+	}
+	for name, testcase := range tests {
+		testcase := testcase
+		t.Run(name, func(t *testing.T) {
+			class := Object{Msg{Family: unix.AF_UNSPEC, Ifindex: 42, Handle: 0x10001, Parent: 0x10000},
+				Attribute{Kind: "qfq", Qfq: testcase.qfq}}
+			// Validate and the encoding of Add agree on qfq classes.
+			if err := class.Validate(); err != nil {
+				t.Fatalf("unexpected validation error: %v", err)
+			}
+			options, err := validateClassObject(unix.RTM_NEWTCLASS, &class)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			want := []tcOption{
+				{Interpretation: vtBytes, Type: tcaOptions, Data: testcase.options},
+				{Interpretation: vtString, Type: tcaKind, Data: "qfq"},
+			}
+			if diff := cmp.Diff(want, options); diff != "" {
+				t.Fatalf("options missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestClassStats(t *testing.T) {
+	stats2 := Stats2{Basic: &GenBasic{Bytes: 1500, Packets: 1}, Queue: &GenQueue{Drops: 3},
+		BasicHw: &GenBasic{Bytes: 1000, Packets: 1}}
+	stats2Data, err := marshalGenStats(&stats2)
+	if err != nil {
+		t.Fatalf("could not encode stats2: %v", err)
+	}
+	xstats, err := marshalXStats(XStats{Htb: &HtbXStats{Lends: 1, Borrows: 2}})
+	if err != nil {
+		t.Fatalf("could not encode xstats: %v", err)
+	}
+	htb, err := marshalHtb(&Htb{Rate64: uint64Ptr(1 << 32)})
+	if err != nil {
+		t.Fatalf("could not encode htb: %v", err)
+	}
+
+	tcSocket, done := statsConn(t, func(msg Msg) ([]tcOption, error) {
+		if msg.Handle != core.BuildHandle(0x1, 0x10) {
+			return nil, syscall.ENOENT
+		}
+		if msg.Ifindex != 42 {
+			t.Fatalf("expected request for ifindex 42 but got %d", msg.Ifindex)
+		}
+		return []tcOption{
+			{Interpretation: vtString, Type: tcaKind, Data: "htb"},
+			{Interpretation: vtBytes, Type: tcaOptions, Data: htb},
+			{Interpretation: vtBytes, Type: tcaStats2, Data: stats2Data},
+			{Interpretation: vtBytes, Type: tcaXstats, Data: xstats},
+		}, nil
+	})
+	defer done()
+
+	gotStats2, gotXStats, err := tcSocket.Class().Stats(42, core.BuildHandle(0x1, 0x10))
+	if err != nil {
+		t.Fatalf("could not get stats: %v", err)
+	}
+	if diff := cmp.Diff(&stats2, gotStats2); diff != "" {
+		t.Fatalf("Stats2 missmatch (-want +got):\n%s", diff)
+	}
+	wantXStats := &XStats{Kind: "htb", Raw: xstats, Htb: &HtbXStats{Lends: 1, Borrows: 2}}
+	if diff := cmp.Diff(wantXStats, gotXStats); diff != "" {
+		t.Fatalf("XStats missmatch (-want +got):\n%s", diff)
+	}
+
+	if _, _, err := tcSocket.Class().Stats(42, core.BuildHandle(0x1, 0x20)); !errors.Is(err, syscall.ENOENT) {
+		t.Fatalf("expected ENOENT but got %v", err)
+	}
+	if _, _, err := tcSocket.Class().Stats(0, core.BuildHandle(0x1, 0x10)); !errors.Is(err, ErrInvalidDev) {
+		t.Fatalf("expected ErrInvalidDev but got %v", err)
+	}
+}
+
+func TestClassGetXStats(t *testing.T) {
+	htbXStats := HtbXStats{Lends: 7, Borrows: 8, Tokens: -42, CTokens: 42}
+	xstats, err := marshalXStats(XStats{Htb: &htbXStats})
+	if err != nil {
+		t.Fatalf("could not encode xstats: %v", err)
+	}
+	htb, err := marshalHtb(&Htb{Parms: &HtbOpt{Rate: RateSpec{Rate: 125000}, Ceil: RateSpec{Rate: 125000}}})
+	if err != nil {
+		t.Fatalf("could not encode htb: %v", err)
+	}
+	attrs, err := marshalAttributes([]tcOption{
+		{Interpretation: vtString, Type: tcaKind, Data: "htb"},
+		{Interpretation: vtBytes, Type: tcaOptions, Data: htb},
+		{Interpretation: vtBytes, Type: tcaXstats, Data: xstats},
+	})
+	if err != nil {
+		t.Fatalf("could not encode attributes: %v", err)
+	}
+	msg := Msg{Family: unix.AF_UNSPEC, Ifindex: 42, Handle: core.BuildHandle(0x1, 0x10), Parent: core.BuildHandle(0x1, 0x0)}
+	tcmsg, err := marshalStruct(&msg)
+	if err != nil {
+		t.Fatalf("could not encode Msg: %v", err)
+	}
+
+	tcSocket := &Tc{
+		con: nltest.Dial(func(req []netlink.Message) ([]netlink.Message, error) {
+			return []netlink.Message{{
+				Header: netlink.Header{
+					Type:     unix.RTM_NEWTCLASS,
+					Sequence: req[0].Header.Sequence,
+					PID:      req[0].Header.PID,
+				},
+				Data: append(tcmsg, attrs...),
+			}}, nil
+		}),
+	}
+	defer tcSocket.Close()
+
+	classes, err := tcSocket.Class().Get(&Msg{Family: unix.AF_UNSPEC, Ifindex: 42})
+	if err != nil {
+		t.Fatalf("could not get classes: %v", err)
+	}
+	if len(classes) != 1 {
+		t.Fatalf("expected one class but got %d", len(classes))
+	}
+	if classes[0].XStats == nil {
+		t.Fatalf("expected xstats on class dump")
+	}
+	if diff := cmp.Diff(&htbXStats, classes[0].XStats.Htb); diff != "" {
+		t.Fatalf("HtbXStats missmatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestClassChildren(t *testing.T) {
+	// qdisc 1: with the classes 1:1 > 1:10, 1:20 > 1:100 and the classes of
+	// qdisc 2: and of another device.
+	classes := []Msg{
+		{Ifindex: 42, Handle: 0x10001, Parent: HandleRoot},
+		{Ifindex: 42, Handle: 0x10010, Parent: 0x10001},
+		{Ifindex: 42, Handle: 0x10020, Parent: 0x10001},
+		{Ifindex: 42, Handle: 0x10100, Parent: 0x10010},
+		{Ifindex: 42, Handle: 0x20001, Parent: 0x20000},
+		{Ifindex: 43, Handle: 0x10002, Parent: HandleRoot},
+	}
+
+	conn := func(t *testing.T, filtering bool, parent uint32) *Tc {
+		return &Tc{con: nltest.Dial(func(req []netlink.Message) ([]netlink.Message, error) {
+			msg, _, err := unmarshalTcmsg(req[0].Data)
+			if err != nil {
+				t.Fatalf("could not decode Msg: %v", err)
+			}
+			if msg.Parent != parent {
+				t.Fatalf("expected parent %x in the request but got %x", parent, msg.Parent)
+			}
+			var msgs []netlink.Message
+			for _, class := range classes {
+				maj, _ := core.SplitHandle(class.Handle)
+				if filtering {
+					// like tc_dump_tclass_qdisc()
+					if pmaj, _ := core.SplitHandle(msg.Parent); class.Ifindex != msg.Ifindex || maj != pmaj {
+						continue
+					}
+				}
+				data, err := marshalStruct(&class)
+				if err != nil {
+					t.Fatalf("could not encode Msg: %v", err)
+				}
+				attrs, err := marshalAttributes([]tcOption{{Interpretation: vtString, Type: tcaKind, Data: "htb"}})
+				if err != nil {
+					t.Fatalf("could not encode attributes: %v", err)
+				}
+				msgs = append(msgs, netlink.Message{Data: append(data, attrs...)})
+			}
+			if len(msgs) == 0 {
+				return nil, io.EOF
+			}
+			return msgs, nil
+		})}
+	}
+
+	tests := map[string]struct {
+		parent uint32
+		want   []uint32
+		err    error
+	}{
+		"qdisc":         {parent: 0x10000, want: []uint32{0x10001}},
+		"class":         {parent: 0x10001, want: []uint32{0x10010, 0x10020}},
+		"second level":  {parent: 0x10010, want: []uint32{0x10100}},
+		"leaf":          {parent: 0x10100},
+		"other qdisc":   {parent: 0x20000, want: []uint32{0x20001}},
+		"missing":       {parent: 0x30000},
+		"root":          {parent: HandleRoot, err: ErrInvalidArg},
+		"without class": {err: ErrInvalidArg},
+	}
+
+	for _, filtering := range []bool{true, false} {
+		for name, testcase := range tests {
+			t.Run(fmt.Sprintf("%s filtered by kernel %v", name, filtering), func(t *testing.T) {
+				tcSocket := conn(t, filtering, testcase.parent)
+				defer tcSocket.Close()
+
+				children, err := tcSocket.Class().Children(42, testcase.parent)
+				if testcase.err != nil {
+					if !errors.Is(err, testcase.err) {
+						t.Fatalf("expected error %v but got %v", testcase.err, err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				var got []uint32
+				for _, child := range children {
+					got = append(got, child.Handle)
+					// Parent is taken from the reply, not from the request.
+					for _, class := range classes {
+						if class.Ifindex == child.Ifindex && class.Handle == child.Handle && class.Parent != child.Parent {
+							t.Fatalf("expected parent %x of %x but got %x", class.Parent, child.Handle, child.Parent)
+						}
+					}
+				}
+				if diff := cmp.Diff(testcase.want, got); diff != "" {
+					t.Fatalf("children missmatch (-want +got):\n%s", diff)
+				}
+			})
+		}
+	}
+
+	tcSocket := conn(t, true, 0)
+	defer tcSocket.Close()
+	if _, err := tcSocket.Class().Children(0, 0x10000); !errors.Is(err, ErrInvalidDev) {
+		t.Fatalf("expected ErrInvalidDev but got %v", err)
+	}
+}
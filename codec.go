@@ -0,0 +1,178 @@
+package tc
+
+import (
+	"fmt"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+	"github.com/mdlayher/netlink"
+)
+
+// Operation is a change of a traffic control object, that is encoded by
+// MarshalObject.
+type Operation int
+
+// Operations, that correspond to the methods of Qdisc, Class, Filter and Chain.
+const (
+	OpQdiscAdd Operation = iota
+	OpQdiscReplace
+	OpQdiscLink
+	OpQdiscChange
+	OpQdiscDelete
+	OpClassAdd
+	OpClassReplace
+	OpClassDelete
+	OpFilterAdd
+	OpFilterReplace
+	OpFilterDelete
+	OpChainAdd
+	OpChainDelete
+	OpFilterFlushAll
+)
+
+type operation struct {
+	action   int
+	flags    netlink.HeaderFlags
+	validate func(action int, info *Object) ([]tcOption, error)
+}
+
+var operations = map[Operation]operation{
+	OpQdiscAdd:      {unix.RTM_NEWQDISC, netlink.Create | netlink.Excl, validateQdiscObject},
+	OpQdiscReplace:  {unix.RTM_NEWQDISC, netlink.Create | netlink.Replace, validateQdiscObject},
+	OpQdiscLink:     {unix.RTM_NEWQDISC, netlink.Replace, validateQdiscObject},
+	OpQdiscChange:   {unix.RTM_NEWQDISC, netlink.HeaderFlags(0), validateQdiscObject},
+	OpQdiscDelete:   {unix.RTM_DELQDISC, netlink.HeaderFlags(0), validateDeleteObject},
+	OpClassAdd:      {unix.RTM_NEWTCLASS, netlink.Create | netlink.Excl, validateClassObject},
+	OpClassReplace:  {unix.RTM_NEWTCLASS, netlink.Create, validateClassObject},
+	OpClassDelete:   {unix.RTM_DELTCLASS, netlink.HeaderFlags(0), validateDeleteObject},
+	OpFilterAdd:     {unix.RTM_NEWTFILTER, netlink.Create | netlink.Excl, validateFilterObject},
+	OpFilterReplace: {unix.RTM_NEWTFILTER, netlink.Create, validateFilterObject},
+	OpFilterDelete:  {unix.RTM_DELTFILTER, netlink.HeaderFlags(0), validateDeleteObject},
+	OpChainAdd:      {unix.RTM_NEWCHAIN, netlink.Create | netlink.Excl, validateFilterObject},
+	OpChainDelete:   {unix.RTM_DELCHAIN, netlink.HeaderFlags(0), validateFilterObject},
+
+	OpFilterFlushAll: {unix.RTM_DELTFILTER, netlink.HeaderFlags(0), validateFlushObject},
+}
+
+// MarshalObject returns the netlink request, that performs op on info, as it
+// would be sent by the corresponding method of Qdisc, Class, Filter or Chain.
+// It does not need a socket. Unlike these methods, it does not validate info
+// with Object.Validate().
+func MarshalObject(op Operation, info *Object) (netlink.Message, error) {
+	if info == nil {
+		return netlink.Message{}, ErrNoArg
+	}
+	o, ok := operations[op]
+	if !ok {
+		return netlink.Message{}, fmt.Errorf("operation %d: %w", op, ErrInvalidArg)
+	}
+	options, err := o.validate(o.action, info)
+	if err != nil {
+		return netlink.Message{}, err
+	}
+	return marshalMessage(o.action, o.flags, requestMsg(o.action, &info.Msg), options)
+}
+
+// UnmarshalObject decodes a qdisc, class, filter or chain message, like it
+// is received from the kernel or captured with nlmon. The type of the message
+// is required, as qdiscs and classes of the same kind encode their options
+// differently. It does not need a socket.
+func UnmarshalObject(msg netlink.Message) (*Object, error) {
+	tcmsg, attrs, err := unmarshalTcmsg(msg.Data)
+	if err != nil {
+		return nil, err
+	}
+	obj := &Object{Msg: tcmsg}
+	if err := extractTcmsgAttributes(int(msg.Header.Type), attrs, &obj.Attribute); err != nil {
+		return nil, err
+	}
+	decodeInfo(int(msg.Header.Type), obj)
+	return obj, nil
+}
+
+// replyValidation returns the encoding of the options of the objects, that
+// are sent by the kernel in messages of msgType.
+var replyValidation = map[netlink.HeaderType]operation{
+	unix.RTM_NEWQDISC:   {unix.RTM_NEWQDISC, 0, validateQdiscObject},
+	unix.RTM_DELQDISC:   {unix.RTM_NEWQDISC, 0, validateQdiscObject},
+	unix.RTM_NEWTCLASS:  {unix.RTM_NEWTCLASS, 0, validateClassObject},
+	unix.RTM_DELTCLASS:  {unix.RTM_NEWTCLASS, 0, validateClassObject},
+	unix.RTM_NEWTFILTER: {unix.RTM_NEWTFILTER, 0, validateFilterObject},
+	unix.RTM_DELTFILTER: {unix.RTM_NEWTFILTER, 0, validateFilterObject},
+	unix.RTM_NEWCHAIN:   {unix.RTM_NEWCHAIN, 0, validateChainReply},
+	unix.RTM_DELCHAIN:   {unix.RTM_NEWCHAIN, 0, validateChainReply},
+}
+
+// validateChainReply returns the options of a chain. Only chains with a
+// template have a kind.
+func validateChainReply(action int, info *Object) ([]tcOption, error) {
+	if info.Kind != "" {
+		return validateFilterObject(action, info)
+	}
+	if info.Ifindex == 0 {
+		return []tcOption{}, ErrInvalidDev
+	}
+	return []tcOption{{Interpretation: vtUint32, Type: tcaChain, Data: uint32Value(info.Chain)}}, nil
+}
+
+// MarshalReply returns the message of type msgType, like RTM_NEWQDISC, that
+// the kernel sends for info in a dump or as event. Unlike MarshalObject, it
+// encodes Stats, Stats2, XStats, HwOffload and the Refcnt of qdiscs, so that
+// UnmarshalObject returns info again. It is meant for fakes of the kernel,
+// like the one of package tctest.
+func MarshalReply(msgType netlink.HeaderType, info *Object) (netlink.Message, error) {
+	if info == nil {
+		return netlink.Message{}, ErrNoArg
+	}
+	o, ok := replyValidation[msgType]
+	if !ok {
+		return netlink.Message{}, fmt.Errorf("message type %d: %w", msgType, ErrInvalidArg)
+	}
+	// The statistics are refused in requests.
+	tmp := *info
+	tmp.Stats, tmp.Stats2, tmp.XStats = nil, nil, nil
+	options, err := o.validate(o.action, &tmp)
+	if err != nil {
+		return netlink.Message{}, err
+	}
+	if info.HwOffload != nil {
+		options = append(options, tcOption{Interpretation: vtUint8, Type: tcaHwOffload, Data: *info.HwOffload})
+	}
+	if info.Stats != nil {
+		data, err := marshalStruct(info.Stats)
+		if err != nil {
+			return netlink.Message{}, err
+		}
+		options = append(options, tcOption{Interpretation: vtBytes, Type: tcaStats, Data: data})
+	}
+	if info.Stats2 != nil {
+		data, err := marshalGenStats(info.Stats2)
+		if err != nil {
+			return netlink.Message{}, err
+		}
+		options = append(options, tcOption{Interpretation: vtBytes, Type: tcaStats2, Data: data})
+	}
+	if info.XStats != nil {
+		data, err := marshalXStats(*info.XStats)
+		if err != nil {
+			return netlink.Message{}, err
+		}
+		if len(data) > 0 {
+			options = append(options, tcOption{Interpretation: vtBytes, Type: tcaXstats, Data: data})
+		}
+	}
+	tcmsg := info.Msg
+	switch {
+	case isQdiscMsg(int(msgType)) && info.Refcnt != 0:
+		tcmsg.Info = info.Refcnt
+	case isFilterMsg(int(msgType)) && tcmsg.Info == 0:
+		tcmsg.Info = core.BuildFilterInfo(info.Priority, info.Protocol)
+	}
+	msg, err := marshalMessage(int(msgType), 0, &tcmsg, options)
+	if err != nil {
+		return netlink.Message{}, err
+	}
+	// Replies and events are no requests.
+	msg.Header.Flags = 0
+	return msg, nil
+}
@@ -0,0 +1,208 @@
+package tc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/mdlayher/netlink"
+)
+
+func TestMarshalObject(t *testing.T) {
+	tests := map[string]struct {
+		op    Operation
+		obj   Object
+		want  *Object
+		typ   netlink.HeaderType
+		flags netlink.HeaderFlags
+	}{
+		"qdisc": {
+			op: OpQdiscReplace,
+			obj: Object{
+				Msg{Family: unix.AF_UNSPEC, Ifindex: 42, Handle: core.BuildHandle(0x1, 0x0), Parent: HandleRoot},
+				Attribute{Kind: "fq_codel", FqCodel: &FqCodel{Target: uint32Ptr(5000), ECN: uint32Ptr(1)}},
+			},
+			typ:   unix.RTM_NEWQDISC,
+			flags: netlink.Request | netlink.Acknowledge | netlink.Create | netlink.Replace,
+		},
+		"class": {
+			op: OpClassAdd,
+			obj: Object{
+				Msg{Family: unix.AF_UNSPEC, Ifindex: 42, Handle: core.BuildHandle(0x1, 0x10), Parent: core.BuildHandle(0x1, 0x0)},
+				Attribute{Kind: "htb", Htb: &Htb{Parms: &HtbOpt{Buffer: 0xa, Quantum: 0x14}, Rate64: uint64Ptr(1000)}},
+			},
+			typ:   unix.RTM_NEWTCLASS,
+			flags: netlink.Request | netlink.Acknowledge | netlink.Create | netlink.Excl,
+		},
+		"filter": {
+			op: OpFilterReplace,
+			obj: Object{
+				Msg{Family: unix.AF_UNSPEC, Ifindex: 42, Handle: 0x1, Parent: core.BuildHandle(0x1, 0x0), Info: core.BuildFilterInfo(1, core.EthPAll)},
+				Attribute{Kind: "matchall", Priority: 1, Protocol: core.EthPAll, Matchall: &Matchall{ClassID: uint32Ptr(0x10010)}},
+			},
+			typ:   unix.RTM_NEWTFILTER,
+			flags: netlink.Request | netlink.Acknowledge | netlink.Create,
+		},
+		"delete filter": {
+			op: OpFilterDelete,
+			obj: Object{
+				Msg{Family: unix.AF_UNSPEC, Ifindex: 42, Handle: 0x1, Parent: core.BuildHandle(0x1, 0x0), Info: core.BuildFilterInfo(1, core.EthPAll)},
+				Attribute{Kind: "matchall", Priority: 1, Protocol: core.EthPAll, Chain: uint32Ptr(2), Matchall: &Matchall{ClassID: uint32Ptr(0x10010)}},
+			},
+			// Only the attributes, that identify the filter, are sent.
+			want: &Object{
+				Msg{Family: unix.AF_UNSPEC, Ifindex: 42, Handle: 0x1, Parent: core.BuildHandle(0x1, 0x0), Info: core.BuildFilterInfo(1, core.EthPAll)},
+				Attribute{Kind: "matchall", Priority: 1, Protocol: core.EthPAll, Chain: uint32Ptr(2)},
+			},
+			typ:   unix.RTM_DELTFILTER,
+			flags: netlink.Request | netlink.Acknowledge,
+		},
+		"delete qdisc": {
+			op: OpQdiscDelete,
+			obj: Object{
+				Msg{Family: unix.AF_UNSPEC, Ifindex: 42, Handle: core.BuildHandle(0x1, 0x0), Parent: HandleRoot},
+				Attribute{Kind: "fq_codel", FqCodel: &FqCodel{Target: uint32Ptr(5000)}},
+			},
+			want: &Object{
+				Msg{Family: unix.AF_UNSPEC, Ifindex: 42, Handle: core.BuildHandle(0x1, 0x0), Parent: HandleRoot},
+				Attribute{Kind: "fq_codel"},
+			},
+			typ:   unix.RTM_DELQDISC,
+			flags: netlink.Request | netlink.Acknowledge,
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			msg, err := MarshalObject(testcase.op, &testcase.obj)
+			if err != nil {
+				t.Fatalf("could not marshal object: %v", err)
+			}
+			if msg.Header.Type != testcase.typ || msg.Header.Flags != testcase.flags {
+				t.Fatalf("expected type %d with flags %v but got type %d with flags %v",
+					testcase.typ, testcase.flags, msg.Header.Type, msg.Header.Flags)
+			}
+			obj, err := UnmarshalObject(msg)
+			if err != nil {
+				t.Fatalf("could not unmarshal object: %v", err)
+			}
+			want := testcase.want
+			if want == nil {
+				want = &testcase.obj
+			}
+			if diff := cmp.Diff(want, obj); diff != "" {
+				t.Fatalf("object missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("nil", func(t *testing.T) {
+		if _, err := MarshalObject(OpQdiscAdd, nil); !errors.Is(err, ErrNoArg) {
+			t.Fatalf("expected ErrNoArg but got %v", err)
+		}
+	})
+	t.Run("unknown operation", func(t *testing.T) {
+		if _, err := MarshalObject(Operation(-1), &Object{Msg{Ifindex: 42}, Attribute{Kind: "clsact"}}); !errors.Is(err, ErrInvalidArg) {
+			t.Fatalf("expected ErrInvalidArg but got %v", err)
+		}
+	})
+	t.Run("without device", func(t *testing.T) {
+		if _, err := MarshalObject(OpQdiscAdd, &Object{Attribute: Attribute{Kind: "clsact"}}); !errors.Is(err, ErrInvalidDev) {
+			t.Fatalf("expected ErrInvalidDev but got %v", err)
+		}
+	})
+	t.Run("short message", func(t *testing.T) {
+		if _, err := UnmarshalObject(netlink.Message{Data: make([]byte, 10)}); err == nil {
+			t.Fatalf("expected error but got nil")
+		}
+	})
+}
+
+func TestMarshalReply(t *testing.T) {
+	stats2 := &Stats2{Basic: &GenBasic{Bytes: 42, Packets: 1}, Queue: &GenQueue{QueueLen: 1, Overlimits: 42},
+		Pkt64: uint64Ptr(1)}
+	tests := map[string]struct {
+		typ netlink.HeaderType
+		obj Object
+	}{
+		"qdisc": {
+			typ: unix.RTM_NEWQDISC,
+			obj: Object{
+				Msg{Family: unix.AF_UNSPEC, Ifindex: 42, Handle: core.BuildHandle(0x1, 0x0), Parent: HandleRoot},
+				Attribute{Kind: "fq", Fq: &Fq{PLimit: uint32Ptr(100)}, HwOffload: uint8Ptr(0),
+					Stats: &Stats{Bytes: 32, Packets: 1, Qlen: 1}, Stats2: stats2,
+					XStats: &XStats{Fq: &FqQdStats{GcFlows: 73}}},
+			},
+		},
+		"shared qdisc": {
+			typ: unix.RTM_NEWQDISC,
+			obj: Object{
+				Msg{Family: unix.AF_UNSPEC, Ifindex: 42, Parent: HandleRoot, Info: 2},
+				Attribute{Kind: "fq_codel", FqCodel: &FqCodel{Limit: uint32Ptr(10240)}, Refcnt: 2},
+			},
+		},
+		"deleted class": {
+			typ: unix.RTM_DELTCLASS,
+			obj: Object{
+				Msg{Family: unix.AF_UNSPEC, Ifindex: 42, Handle: core.BuildHandle(0x1, 0x10), Parent: core.BuildHandle(0x1, 0x0)},
+				Attribute{Kind: "htb", Htb: &Htb{Parms: &HtbOpt{Buffer: 0xa, Quantum: 0x14}},
+					Stats2: stats2, XStats: &XStats{Htb: &HtbXStats{Lends: 1, Borrows: 2}}},
+			},
+		},
+		"filter": {
+			typ: unix.RTM_NEWTFILTER,
+			obj: Object{
+				Msg{Family: unix.AF_UNSPEC, Ifindex: 42, Handle: 0x1, Parent: core.BuildHandle(0x1, 0x0), Info: core.BuildFilterInfo(1, core.EthPAll)},
+				Attribute{Kind: "matchall", Priority: 1, Protocol: core.EthPAll, Chain: uint32Ptr(0), Matchall: &Matchall{ClassID: uint32Ptr(0x10010)},
+					Stats2: stats2},
+			},
+		},
+		"chain": {
+			typ: unix.RTM_NEWCHAIN,
+			obj: Object{
+				Msg{Family: unix.AF_UNSPEC, Ifindex: 42, Parent: core.BuildHandle(0x1, 0x0)},
+				Attribute{Chain: uint32Ptr(7)},
+			},
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			msg, err := MarshalReply(testcase.typ, &testcase.obj)
+			if err != nil {
+				t.Fatalf("could not marshal reply: %v", err)
+			}
+			if msg.Header.Type != testcase.typ || msg.Header.Flags != 0 {
+				t.Fatalf("expected type %d without flags but got type %d with flags %v",
+					testcase.typ, msg.Header.Type, msg.Header.Flags)
+			}
+			obj, err := UnmarshalObject(msg)
+			if err != nil {
+				t.Fatalf("could not unmarshal reply: %v", err)
+			}
+			// The undecoded XStats are kept as well.
+			if diff := cmp.Diff(&testcase.obj, obj, cmpopts.IgnoreFields(XStats{}, "Kind", "Raw")); diff != "" {
+				t.Fatalf("object missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("nil", func(t *testing.T) {
+		if _, err := MarshalReply(unix.RTM_NEWQDISC, nil); !errors.Is(err, ErrNoArg) {
+			t.Fatalf("expected ErrNoArg but got %v", err)
+		}
+	})
+	t.Run("request type", func(t *testing.T) {
+		if _, err := MarshalReply(unix.RTM_GETQDISC, &Object{Msg{Ifindex: 42}, Attribute{Kind: "clsact"}}); !errors.Is(err, ErrInvalidArg) {
+			t.Fatalf("expected ErrInvalidArg but got %v", err)
+		}
+	})
+	t.Run("without device", func(t *testing.T) {
+		if _, err := MarshalReply(unix.RTM_NEWCHAIN, &Object{Attribute: Attribute{Chain: uint32Ptr(1)}}); !errors.Is(err, ErrInvalidDev) {
+			t.Fatalf("expected ErrInvalidDev but got %v", err)
+		}
+	})
+}
@@ -0,0 +1,723 @@
+package tc
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+
+	"github.com/florianl/go-tc/core"
+)
+
+// Flags of classifiers from include/uapi/linux/pkt_cls.h
+const (
+	clsFlagsSkipHw = 1 << 0
+	clsFlagsSkipSw = 1 << 1
+)
+
+// interfaceName returns the name of the interface with the given index.
+// It is a variable, so tests can render commands without the interface.
+var interfaceName = func(ifindex uint32) (string, error) {
+	iface, err := net.InterfaceByIndex(int(ifindex))
+	if err != nil {
+		return "", err
+	}
+	return iface.Name, nil
+}
+
+// ipProtocols contains the names of IP protocols, as they are used by iproute2/tc/f_flower.c.
+var ipProtocols = map[uint8]string{
+	1:   "icmp",
+	6:   "tcp",
+	17:  "udp",
+	58:  "icmpv6",
+	132: "sctp",
+}
+
+// Command returns the iproute2 invocation, that performs op on obj, e.g.
+// "tc qdisc add dev eth0 root handle 1: htb default 30". Supported values for
+// op are add, replace, change and del.
+// Kinds and options, that can not be expressed as arguments to tc, result in
+// ErrNotImplemented instead of an incomplete command.
+func Command(obj *Object, op string) (string, error) {
+	if obj == nil {
+		return "", ErrNoArg
+	}
+	var del bool
+	switch op {
+	case "add", "replace", "change":
+		if err := obj.Validate(); err != nil {
+			return "", err
+		}
+	case "del", "delete":
+		if obj.Ifindex == 0 {
+			return "", ErrInvalidDev
+		}
+		del = true
+	default:
+		return "", fmt.Errorf("operation %q: %w", op, ErrInvalidArg)
+	}
+	if obj.Stab != nil {
+		return "", fmt.Errorf("%s: rendering of Stab is not supported: %w", obj.Kind, ErrNotImplemented)
+	}
+
+	typ := objectType(obj)
+	args := []string{"tc", typ, op}
+	if block, ok := obj.Block(); ok {
+		// Only filters are attached to shared blocks.
+		if typ != "filter" {
+			return "", fmt.Errorf("%s %s: block %d: %w", typ, obj.Kind, block, ErrInvalidDev)
+		}
+		args = append(args, "block", fmt.Sprintf("%d", block))
+	} else {
+		dev, err := interfaceName(obj.Ifindex)
+		if err != nil {
+			return "", fmt.Errorf("could not get name of interface %d: %w", obj.Ifindex, err)
+		}
+		args = append(args, "dev", dev)
+	}
+
+	var err error
+	switch typ {
+	case "filter":
+		args, err = commandFilter(args, obj, del)
+	case "class":
+		args, err = commandClass(args, obj, del)
+	default:
+		args, err = commandQdisc(args, obj, del)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(args, " "), nil
+}
+
+func commandQdisc(args []string, obj *Object, del bool) ([]string, error) {
+	if obj.IngressBlock != nil {
+		args = append(args, fmt.Sprintf("ingress_block %d", *obj.IngressBlock))
+	}
+	if obj.EgressBlock != nil {
+		args = append(args, fmt.Sprintf("egress_block %d", *obj.EgressBlock))
+	}
+	switch obj.Kind {
+	case "clsact", "ingress":
+		// parent and handle of these qdiscs are implied by the kind
+		return append(args, obj.Kind), nil
+	}
+
+	args = append(args, commandParent(obj.Parent))
+	if obj.Handle != 0 {
+		args = append(args, "handle", core.FormatHandle(obj.Handle))
+	}
+	if del {
+		return args, nil
+	}
+	args = append(args, obj.Kind)
+
+	opts, err := commandQdiscOptions(&obj.Attribute)
+	return append(args, opts...), err
+}
+
+func commandClass(args []string, obj *Object, del bool) ([]string, error) {
+	args = append(args, commandParent(obj.Parent), "classid", core.FormatHandle(obj.Handle))
+	if del {
+		return args, nil
+	}
+	args = append(args, obj.Kind)
+
+	opts, err := commandClassOptions(&obj.Attribute)
+	return append(args, opts...), err
+}
+
+func commandFilter(args []string, obj *Object, del bool) ([]string, error) {
+	_, block := obj.Block()
+	switch {
+	case block:
+		// The parent holds the index of the block.
+	case obj.Parent == HandleClsactIngress:
+		args = append(args, "ingress")
+	case obj.Parent == HandleClsactEgress:
+		args = append(args, "egress")
+	default:
+		args = append(args, commandParent(obj.Parent))
+	}
+	if obj.Handle != 0 {
+		handle := fmt.Sprintf("%#x", obj.Handle)
+		if obj.Kind == "u32" {
+			handle = formatU32Handle(obj.Handle)
+		}
+		args = append(args, "handle", handle)
+	}
+	prio, protocol := core.SplitFilterInfo(obj.Info)
+	if protocol != 0 {
+		args = append(args, "protocol", core.FormatProtocol(protocol))
+	}
+	if prio != 0 {
+		args = append(args, fmt.Sprintf("prio %d", prio))
+	}
+	if obj.Chain != nil {
+		args = append(args, fmt.Sprintf("chain %d", *obj.Chain))
+	}
+	args = append(args, obj.Kind)
+	if del {
+		return args, nil
+	}
+
+	var opts []string
+	var err error
+	switch obj.Kind {
+	case "u32":
+		opts, err = commandU32(obj.U32)
+	case "flower":
+		opts, err = commandFlower(obj.Flower, protocol)
+	default:
+		err = fmt.Errorf("%s: %w", obj.Kind, ErrNotImplemented)
+	}
+	return append(args, opts...), err
+}
+
+func commandParent(parent uint32) string {
+	if parent == HandleRoot {
+		return "root"
+	}
+	return "parent " + tcHandle(parent)
+}
+
+// commandSupported returns ErrNotImplemented, if a field of the struct opts
+// points to, that is not part of fields, is set.
+func commandSupported(kind string, opts interface{}, fields ...string) error {
+	v := reflect.ValueOf(opts).Elem()
+	t := v.Type()
+next:
+	for i := 0; i < v.NumField(); i++ {
+		if v.Field(i).IsZero() {
+			continue
+		}
+		for _, field := range fields {
+			if t.Field(i).Name == field {
+				continue next
+			}
+		}
+		return fmt.Errorf("%s: rendering of %s.%s is not supported: %w",
+			kind, t.Name(), t.Field(i).Name, ErrNotImplemented)
+	}
+	return nil
+}
+
+// commandRate returns rate, given in bytes per second, in the largest unit,
+// that represents it without loss.
+func commandRate(rate uint64) string {
+	bits := rate * 8
+	for _, unit := range []struct {
+		name  string
+		value uint64
+	}{{"Gbit", 1000000000}, {"Mbit", 1000000}, {"Kbit", 1000}} {
+		if bits != 0 && bits%unit.value == 0 {
+			return fmt.Sprintf("%d%s", bits/unit.value, unit.name)
+		}
+	}
+	return fmt.Sprintf("%dbit", bits)
+}
+
+// commandTime returns a time, given in microseconds, in the largest unit,
+// that represents it without loss.
+func commandTime(us uint32) string {
+	switch {
+	case us != 0 && us%1000000 == 0:
+		return fmt.Sprintf("%ds", us/1000000)
+	case us != 0 && us%1000 == 0:
+		return fmt.Sprintf("%dms", us/1000)
+	}
+	return fmt.Sprintf("%dus", us)
+}
+
+// commandRateSpec returns the link layer arguments of rate. CellLog and
+// CellAlign are derived by tc from these arguments.
+func commandRateSpec(rate RateSpec) []string {
+	var opts []string
+	if rate.Overhead != 0 {
+		opts = append(opts, fmt.Sprintf("overhead %d", rate.Overhead))
+	}
+	if rate.Mpu != 0 {
+		opts = append(opts, fmt.Sprintf("mpu %d", rate.Mpu))
+	}
+	if Linklayer(rate.Linklayer) == LinklayerAtm {
+		opts = append(opts, "linklayer atm")
+	}
+	return opts
+}
+
+// formatU32Handle implements iproute2/tc/f_u32.c:sprint_u32_handle().
+func formatU32Handle(handle uint32) string {
+	htid, hash, node := SplitU32Handle(handle)
+
+	var b strings.Builder
+	if htid != 0 {
+		fmt.Fprintf(&b, "%x:", htid)
+	}
+	if hash != 0 {
+		fmt.Fprintf(&b, "%x", hash)
+	}
+	if node != 0 {
+		fmt.Fprintf(&b, ":%x", node)
+	}
+	return b.String()
+}
+
+func commandOnOff(v *uint32, on, off string) string {
+	if *v != 0 {
+		return on
+	}
+	return off
+}
+
+func commandQdiscOptions(attr *Attribute) ([]string, error) {
+	var opts []string
+	add := func(format string, a ...interface{}) {
+		opts = append(opts, fmt.Sprintf(format, a...))
+	}
+
+	switch attr.Kind {
+	case "fq_codel":
+		fq := attr.FqCodel
+		if err := commandSupported(attr.Kind, fq, "Target", "Limit", "Interval", "ECN", "Flows",
+			"Quantum", "CEThreshold", "DropBatchSize", "MemoryLimit"); err != nil {
+			return nil, err
+		}
+		if fq.Limit != nil {
+			add("limit %d", *fq.Limit)
+		}
+		if fq.Flows != nil {
+			add("flows %d", *fq.Flows)
+		}
+		if fq.Quantum != nil {
+			add("quantum %d", *fq.Quantum)
+		}
+		if fq.Target != nil {
+			add("target %s", commandTime(*fq.Target))
+		}
+		if fq.Interval != nil {
+			add("interval %s", commandTime(*fq.Interval))
+		}
+		if fq.CEThreshold != nil {
+			add("ce_threshold %s", commandTime(*fq.CEThreshold))
+		}
+		if fq.DropBatchSize != nil {
+			add("drop_batch %d", *fq.DropBatchSize)
+		}
+		if fq.MemoryLimit != nil {
+			add("memory_limit %d", *fq.MemoryLimit)
+		}
+		if fq.ECN != nil {
+			add(commandOnOff(fq.ECN, "ecn", "noecn"))
+		}
+	case "codel":
+		codel := attr.Codel
+		if err := commandSupported(attr.Kind, codel, "Target", "Limit", "Interval", "ECN", "CEThreshold"); err != nil {
+			return nil, err
+		}
+		if codel.Limit != nil {
+			add("limit %d", *codel.Limit)
+		}
+		if codel.Target != nil {
+			add("target %s", commandTime(*codel.Target))
+		}
+		if codel.Interval != nil {
+			add("interval %s", commandTime(*codel.Interval))
+		}
+		if codel.CEThreshold != nil {
+			add("ce_threshold %s", commandTime(*codel.CEThreshold))
+		}
+		if codel.ECN != nil {
+			add(commandOnOff(codel.ECN, "ecn", "noecn"))
+		}
+	case "pie":
+		pie := attr.Pie
+		if pie.Limit != nil {
+			add("limit %d", *pie.Limit)
+		}
+		if pie.Target != nil {
+			add("target %s", commandTime(*pie.Target))
+		}
+		if pie.TUpdate != nil {
+			add("tupdate %s", commandTime(*pie.TUpdate))
+		}
+		if pie.Alpha != nil {
+			add("alpha %d", *pie.Alpha)
+		}
+		if pie.Beta != nil {
+			add("beta %d", *pie.Beta)
+		}
+		if pie.ECN != nil {
+			add(commandOnOff(pie.ECN, "ecn", "noecn"))
+		}
+		if pie.Bytemode != nil {
+			add(commandOnOff(pie.Bytemode, "bytemode", "nobytemode"))
+		}
+		if pie.DqRateEstimator != nil && *pie.DqRateEstimator != 0 {
+			add("dq_rate_estimator")
+		}
+	case "pfifo", "bfifo":
+		fifo := attr.Pfifo
+		if attr.Kind == "bfifo" {
+			fifo = attr.Bfifo
+		}
+		add("limit %d", fifo.Limit)
+	case "tbf":
+		tbf := attr.Tbf
+		if err := commandSupported(attr.Kind, tbf, "Parms", "Burst"); err != nil {
+			return nil, err
+		}
+		rate := uint64(tbf.Parms.Rate.Rate)
+		burst := core.XmitSize(rate, tbf.Parms.Buffer)
+		if tbf.Burst != nil {
+			burst = *tbf.Burst
+		}
+		add("rate %s burst %d limit %d", commandRate(rate), burst, tbf.Parms.Limit)
+		if tbf.Parms.PeakRate.Rate != 0 {
+			add("peakrate %s mtu %d", commandRate(uint64(tbf.Parms.PeakRate.Rate)), tbf.Parms.Mtu)
+		}
+		opts = append(opts, commandRateSpec(tbf.Parms.Rate)...)
+	case "htb":
+		htb := attr.Htb
+		if err := commandSupported(attr.Kind, htb, "Init", "DirectQlen", "Offload"); err != nil {
+			return nil, err
+		}
+		if htb.Init != nil {
+			if err := commandSupported(attr.Kind, htb.Init, "Version", "Rate2Quantum", "Defcls", "DirectPkts"); err != nil {
+				return nil, err
+			}
+			if htb.Init.Defcls != 0 {
+				add("default %x", htb.Init.Defcls)
+			}
+			if htb.Init.Rate2Quantum != 0 && htb.Init.Rate2Quantum != 10 {
+				add("r2q %d", htb.Init.Rate2Quantum)
+			}
+		}
+		if htb.DirectQlen != nil {
+			add("direct_qlen %d", *htb.DirectQlen)
+		}
+		if htb.Offload != nil && *htb.Offload {
+			add("offload")
+		}
+	case "netem":
+		netem := attr.Netem
+		if err := commandSupported(attr.Kind, netem, "Qopt", "Ecn", "Rate", "Rate64", "Latency64", "Jitter64"); err != nil {
+			return nil, err
+		}
+		if err := commandSupported(attr.Kind, &netem.Qopt, "Latency", "Limit", "Loss", "Duplicate", "Jitter"); err != nil {
+			return nil, err
+		}
+		if netem.Qopt.Limit != 0 {
+			add("limit %d", netem.Qopt.Limit)
+		}
+		latency := core.Tick2Time(netem.Qopt.Latency)
+		if netem.Latency64 != nil {
+			latency = uint32(*netem.Latency64 / 1000)
+		}
+		jitter := core.Tick2Time(netem.Qopt.Jitter)
+		if netem.Jitter64 != nil {
+			jitter = uint32(*netem.Jitter64 / 1000)
+		}
+		if jitter != 0 {
+			add("delay %s %s", commandTime(latency), commandTime(jitter))
+		} else if latency != 0 {
+			add("delay %s", commandTime(latency))
+		}
+		if netem.Qopt.Loss != 0 {
+			add("loss %.6g%%", PercentageOf(netem.Qopt.Loss))
+		}
+		if netem.Qopt.Duplicate != 0 {
+			add("duplicate %.6g%%", PercentageOf(netem.Qopt.Duplicate))
+		}
+		if netem.Ecn != nil && *netem.Ecn != 0 {
+			add("ecn")
+		}
+		if netem.Rate != nil || netem.Rate64 != nil {
+			var rate uint64
+			if netem.Rate != nil {
+				if err := commandSupported(attr.Kind, netem.Rate, "Rate"); err != nil {
+					return nil, err
+				}
+				rate = uint64(netem.Rate.Rate)
+			}
+			if netem.Rate64 != nil {
+				rate = *netem.Rate64
+			}
+			add("rate %s", commandRate(rate))
+		}
+	case "sfq":
+		sfq := attr.Sfq
+		if err := commandSupported(attr.Kind, sfq, "V0", "Depth", "Headdrop"); err != nil {
+			return nil, err
+		}
+		if sfq.V0.Limit != 0 {
+			add("limit %d", sfq.V0.Limit)
+		}
+		if sfq.V0.PerturbPeriod != 0 {
+			add("perturb %d", sfq.V0.PerturbPeriod)
+		}
+		if sfq.V0.Quantum != 0 {
+			add("quantum %d", sfq.V0.Quantum)
+		}
+		if sfq.V0.Divisor != 0 {
+			add("divisor %d", sfq.V0.Divisor)
+		}
+		if sfq.V0.Flows != 0 {
+			add("flows %d", sfq.V0.Flows)
+		}
+		if sfq.Depth != 0 {
+			add("depth %d", sfq.Depth)
+		}
+		if sfq.Headdrop != 0 {
+			add("headdrop")
+		}
+	case "prio":
+		add("bands %d priomap %s", attr.Prio.Bands, attr.Prio.PrioMap)
+	case "pfifo_fast":
+		// tc does not accept options for pfifo_fast
+		if attr.Prio != nil && (attr.Prio.Bands != 3 || attr.Prio.PrioMap != DefaultPriomap()) {
+			return nil, fmt.Errorf("%s: rendering of non-default Prio is not supported: %w",
+				attr.Kind, ErrNotImplemented)
+		}
+	case "drr":
+		if err := commandSupported(attr.Kind, attr.Drr); err != nil {
+			return nil, err
+		}
+	case "qfq":
+		if attr.Qfq != nil {
+			if err := commandSupported(attr.Kind, attr.Qfq); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%s: %w", attr.Kind, ErrNotImplemented)
+	}
+	return opts, nil
+}
+
+func commandClassOptions(attr *Attribute) ([]string, error) {
+	var opts []string
+	add := func(format string, a ...interface{}) {
+		opts = append(opts, fmt.Sprintf(format, a...))
+	}
+
+	switch attr.Kind {
+	case "htb":
+		htb := attr.Htb
+		// The rate tables are calculated by tc from rate and ceil.
+		if err := commandSupported(attr.Kind, htb, "Parms", "Rtab", "Ctab", "Rate64", "Ceil64"); err != nil {
+			return nil, err
+		}
+		parms := htb.Parms
+		if parms == nil {
+			return nil, fmt.Errorf("%s: Htb.Parms is required: %w", attr.Kind, ErrNoArg)
+		}
+		rate := uint64(parms.Rate.Rate)
+		if htb.Rate64 != nil {
+			rate = *htb.Rate64
+		}
+		ceil := uint64(parms.Ceil.Rate)
+		if htb.Ceil64 != nil {
+			ceil = *htb.Ceil64
+		}
+		add("rate %s", commandRate(rate))
+		if ceil != 0 {
+			add("ceil %s", commandRate(ceil))
+		}
+		add("burst %d", core.XmitSize(rate, parms.Buffer))
+		if ceil != 0 {
+			add("cburst %d", core.XmitSize(ceil, parms.Cbuffer))
+		}
+		if parms.Prio != 0 {
+			add("prio %d", parms.Prio)
+		}
+		if parms.Quantum != 0 {
+			add("quantum %d", parms.Quantum)
+		}
+		opts = append(opts, commandRateSpec(parms.Rate)...)
+	case "drr":
+		if attr.Drr.Quantum != nil {
+			add("quantum %d", *attr.Drr.Quantum)
+		}
+	case "qfq":
+		// Without options, the kernel uses its defaults for weight and maxpkt.
+		if attr.Qfq == nil {
+			break
+		}
+		if attr.Qfq.Weight != nil {
+			add("weight %d", *attr.Qfq.Weight)
+		}
+		if attr.Qfq.Lmax != nil {
+			add("maxpkt %d", *attr.Qfq.Lmax)
+		}
+	default:
+		return nil, fmt.Errorf("%s: %w", attr.Kind, ErrNotImplemented)
+	}
+	return opts, nil
+}
+
+func commandClsFlags(kind string, flags *uint32) ([]string, error) {
+	if flags == nil {
+		return nil, nil
+	}
+	if *flags&^(clsFlagsSkipHw|clsFlagsSkipSw) != 0 {
+		return nil, fmt.Errorf("%s: rendering of flags %#x is not supported: %w", kind, *flags, ErrNotImplemented)
+	}
+	var opts []string
+	if *flags&clsFlagsSkipHw != 0 {
+		opts = append(opts, "skip_hw")
+	}
+	if *flags&clsFlagsSkipSw != 0 {
+		opts = append(opts, "skip_sw")
+	}
+	return opts, nil
+}
+
+func commandU32(u32 *U32) ([]string, error) {
+	if err := commandSupported("u32", u32, "ClassID", "Hash", "Link", "Divisor", "Sel", "InDev", "Flags"); err != nil {
+		return nil, err
+	}
+	var opts []string
+	add := func(format string, a ...interface{}) {
+		opts = append(opts, fmt.Sprintf(format, a...))
+	}
+
+	if u32.Divisor != nil {
+		add("divisor %d", *u32.Divisor)
+	}
+	if u32.Hash != nil {
+		add("ht %s", formatU32Handle(*u32.Hash))
+	}
+	if sel := u32.Sel; sel != nil {
+		// tc sets NKeys from the number of matches.
+		if err := commandSupported("u32", sel, "Flags", "NKeys", "Hoff", "Hmask", "Keys"); err != nil {
+			return nil, err
+		}
+		if sel.Flags&^U32Terminal != 0 {
+			return nil, fmt.Errorf("u32: rendering of selector flags %#x is not supported: %w",
+				sel.Flags, ErrNotImplemented)
+		}
+		for _, key := range sel.Keys {
+			if key.OffMask != 0 {
+				return nil, fmt.Errorf("u32: rendering of U32Key.OffMask is not supported: %w", ErrNotImplemented)
+			}
+			add("match u32 0x%08x 0x%08x at %d", ntohl(key.Val), ntohl(key.Mask), int32(key.Off))
+		}
+		if sel.Hmask != 0 {
+			add("hashkey mask 0x%08x at %d", sel.Hmask, sel.Hoff)
+		}
+	}
+	if u32.Link != nil {
+		add("link %s", formatU32Handle(*u32.Link))
+	}
+	if u32.ClassID != nil {
+		add("classid %s", core.FormatHandle(*u32.ClassID))
+	}
+	if u32.InDev != nil {
+		add("indev %s", *u32.InDev)
+	}
+	flags, err := commandClsFlags("u32", u32.Flags)
+	return append(opts, flags...), err
+}
+
+func commandFlower(flower *Flower, protocol uint16) ([]string, error) {
+	if err := commandSupported("flower", flower, "ClassID", "Indev", "KeyEthDst", "KeyEthDstMask",
+		"KeyEthSrc", "KeyEthSrcMask", "KeyEthType", "KeyIPProto", "KeyIPv4Src", "KeyIPv4SrcMask",
+		"KeyIPv4Dst", "KeyIPv4DstMask", "KeyTCPSrc", "KeyTCPDst", "KeyUDPSrc", "KeyUDPDst", "Flags",
+		"KeyVlanID", "KeyVlanPrio", "KeyVlanEthType"); err != nil {
+		return nil, err
+	}
+	var opts []string
+	add := func(format string, a ...interface{}) {
+		opts = append(opts, fmt.Sprintf(format, a...))
+	}
+
+	// tc derives the ethernet type from the protocol of the filter.
+	if flower.KeyEthType != nil && *flower.KeyEthType != protocol {
+		return nil, fmt.Errorf("flower: rendering of KeyEthType %#04x, that differs from the protocol, is not supported: %w",
+			*flower.KeyEthType, ErrNotImplemented)
+	}
+
+	if flower.ClassID != nil {
+		add("classid %s", core.FormatHandle(*flower.ClassID))
+	}
+	if flower.Indev != nil {
+		add("indev %s", *flower.Indev)
+	}
+	if flower.KeyVlanID != nil {
+		add("vlan_id %d", *flower.KeyVlanID)
+	}
+	if flower.KeyVlanPrio != nil {
+		add("vlan_prio %d", *flower.KeyVlanPrio)
+	}
+	if flower.KeyVlanEthType != nil {
+		add("vlan_ethtype %s", core.FormatProtocol(*flower.KeyVlanEthType))
+	}
+	for _, mac := range []struct {
+		name       string
+		addr, mask *net.HardwareAddr
+	}{
+		{"dst_mac", flower.KeyEthDst, flower.KeyEthDstMask},
+		{"src_mac", flower.KeyEthSrc, flower.KeyEthSrcMask},
+	} {
+		if mac.addr == nil {
+			continue
+		}
+		if mac.mask != nil {
+			add("%s %s/%s", mac.name, *mac.addr, *mac.mask)
+		} else {
+			add("%s %s", mac.name, *mac.addr)
+		}
+	}
+	if flower.KeyIPProto != nil {
+		name, ok := ipProtocols[*flower.KeyIPProto]
+		if !ok {
+			name = fmt.Sprintf("%d", *flower.KeyIPProto)
+		}
+		add("ip_proto %s", name)
+	}
+	for _, ip := range []struct {
+		name       string
+		addr, mask *net.IP
+	}{
+		{"dst_ip", flower.KeyIPv4Dst, flower.KeyIPv4DstMask},
+		{"src_ip", flower.KeyIPv4Src, flower.KeyIPv4SrcMask},
+	} {
+		if ip.addr == nil {
+			continue
+		}
+		if ip.mask == nil {
+			add("%s %s", ip.name, *ip.addr)
+			continue
+		}
+		ones, bits := net.IPMask(ip.mask.To4()).Size()
+		if bits == 0 {
+			return nil, fmt.Errorf("flower: rendering of non-contiguous mask %s is not supported: %w",
+				*ip.mask, ErrNotImplemented)
+		}
+		add("%s %s/%d", ip.name, *ip.addr, ones)
+	}
+	for _, port := range []struct {
+		name  string
+		proto uint8
+		value *uint16
+	}{
+		{"dst_port", 6, flower.KeyTCPDst},
+		{"src_port", 6, flower.KeyTCPSrc},
+		{"dst_port", 17, flower.KeyUDPDst},
+		{"src_port", 17, flower.KeyUDPSrc},
+	} {
+		if port.value == nil {
+			continue
+		}
+		// tc interprets ports according to ip_proto.
+		if flower.KeyIPProto == nil || *flower.KeyIPProto != port.proto {
+			return nil, fmt.Errorf("flower: rendering of %s without ip_proto %s is not supported: %w",
+				port.name, ipProtocols[port.proto], ErrNotImplemented)
+		}
+		add("%s %d", port.name, *port.value)
+	}
+	flags, err := commandClsFlags("flower", flower.Flags)
+	return append(opts, flags...), err
+}
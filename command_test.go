@@ -0,0 +1,317 @@
+package tc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/florianl/go-tc/core"
+)
+
+func TestCommand(t *testing.T) {
+	restore, err := core.SetClock(0x3e8, 0x40, 0xf4240)
+	if err != nil {
+		t.Fatalf("could not set clock: %v", err)
+	}
+	defer restore()
+
+	defer func(orig func(uint32) (string, error)) {
+		interfaceName = orig
+	}(interfaceName)
+	interfaceName = func(ifindex uint32) (string, error) {
+		if ifindex > 10 {
+			return "", fmt.Errorf("no interface with index %d", ifindex)
+		}
+		return fmt.Sprintf("eth%d", ifindex-1), nil
+	}
+
+	htbClass, err := htbClassOptions(1250000, 12500000)
+	if err != nil {
+		t.Fatalf("could not create htb class options: %v", err)
+	}
+	netemQopt, err := NetemQoptFromDurations(100000000, 10000000, 1000)
+	if err != nil {
+		t.Fatalf("could not create netem options: %v", err)
+	}
+	netemQopt.Loss = 429496730
+
+	// the protocol is part of Info in network byte order
+	infoIP := core.BuildHandle(1, uint32(ntohs(0x0800)))
+	infoAll := core.BuildHandle(49152, uint32(ntohs(0x0003)))
+	dstIP := net.ParseIP("10.0.0.1").To4()
+	dstMask := net.IPv4(255, 255, 255, 0).To4()
+	dstMac, _ := net.ParseMAC("00:11:22:33:44:55")
+
+	tests := map[string]struct {
+		obj  Object
+		op   string
+		want string
+		err  error
+	}{
+		"htb qdisc": {
+			obj: Object{Msg{Ifindex: 1, Handle: 0x10000, Parent: HandleRoot},
+				Attribute{Kind: "htb", Htb: &Htb{Init: &HtbGlob{Version: 3, Rate2Quantum: 10, Defcls: 0x30}}}},
+			op:   "add",
+			want: "tc qdisc add dev eth0 root handle 1: htb default 30",
+		},
+		"htb qdisc options": {
+			obj: Object{Msg{Ifindex: 1, Handle: 0x10000, Parent: HandleRoot},
+				Attribute{Kind: "htb", Htb: &Htb{Init: &HtbGlob{Version: 3, Rate2Quantum: 5},
+					DirectQlen: uint32Ptr(100)}}},
+			op:   "replace",
+			want: "tc qdisc replace dev eth0 root handle 1: htb r2q 5 direct_qlen 100",
+		},
+		"htb class": {
+			obj: Object{Msg{Ifindex: 1, Handle: 0x10010, Parent: 0x10001},
+				Attribute{Kind: "htb", Htb: htbClass}},
+			op:   "add",
+			want: "tc class add dev eth0 parent 1:1 classid 1:10 htb rate 10Mbit ceil 100Mbit burst 1600 cburst 1600",
+		},
+		"htb class 64bit": {
+			obj: Object{Msg{Ifindex: 1, Handle: 0x10010, Parent: HandleRoot},
+				Attribute{Kind: "htb", Htb: &Htb{Parms: &HtbOpt{Rate: RateSpec{Rate: 0xFFFFFFFF},
+					Ceil: RateSpec{Rate: 0xFFFFFFFF}, Prio: 1, Quantum: 1514},
+					Rate64: uint64Ptr(5000000000), Ceil64: uint64Ptr(5000000000)}}},
+			op:   "change",
+			want: "tc class change dev eth0 root classid 1:10 htb rate 40Gbit ceil 40Gbit burst 0 cburst 0 prio 1 quantum 1514",
+		},
+		"fq_codel": {
+			obj: Object{Msg{Ifindex: 1, Handle: 0x100000, Parent: 0x10010},
+				Attribute{Kind: "fq_codel", FqCodel: DefaultFqCodel()}},
+			op: "add",
+			want: "tc qdisc add dev eth0 parent 1:10 handle 10: fq_codel limit 10240 flows 1024 quantum 1514 " +
+				"target 5ms interval 100ms drop_batch 64 memory_limit 33554432 ecn",
+		},
+		"codel": {
+			obj: Object{Msg{Ifindex: 1, Parent: HandleRoot},
+				Attribute{Kind: "codel", Codel: DefaultCodel()}},
+			op:   "add",
+			want: "tc qdisc add dev eth0 root codel limit 1000 target 5ms interval 100ms noecn",
+		},
+		"pie": {
+			obj: Object{Msg{Ifindex: 1, Parent: HandleRoot},
+				Attribute{Kind: "pie", Pie: &Pie{Limit: uint32Ptr(1000), Target: uint32Ptr(15000),
+					TUpdate: uint32Ptr(15000), ECN: uint32Ptr(1)}}},
+			op:   "add",
+			want: "tc qdisc add dev eth0 root pie limit 1000 target 15ms tupdate 15ms ecn",
+		},
+		"netem": {
+			obj: Object{Msg{Ifindex: 2, Handle: 0x10000, Parent: HandleRoot},
+				Attribute{Kind: "netem", Netem: &Netem{Qopt: netemQopt}}},
+			op:   "add",
+			want: "tc qdisc add dev eth1 root handle 1: netem limit 1000 delay 100ms 10ms loss 10%",
+		},
+		"netem 64bit": {
+			obj: Object{Msg{Ifindex: 2, Parent: HandleRoot},
+				Attribute{Kind: "netem", Netem: &Netem{Latency64: int64Ptr(1500000), Rate64: uint64Ptr(1250000)}}},
+			op:   "add",
+			want: "tc qdisc add dev eth1 root netem delay 1500us rate 10Mbit",
+		},
+		"tbf": {
+			obj: Object{Msg{Ifindex: 1, Parent: HandleRoot},
+				Attribute{Kind: "tbf", Tbf: &Tbf{Parms: &TbfQopt{Rate: RateSpec{Rate: 125000},
+					Limit: 10000}, Burst: uint32Ptr(5000)}}},
+			op:   "add",
+			want: "tc qdisc add dev eth0 root tbf rate 1Mbit burst 5000 limit 10000",
+		},
+		"sfq": {
+			obj: Object{Msg{Ifindex: 1, Handle: 0x300000, Parent: 0x10030},
+				Attribute{Kind: "sfq", Sfq: DefaultSfq()}},
+			op: "add",
+			want: "tc qdisc add dev eth0 parent 1:30 handle 30: sfq limit 127 quantum 1514 divisor 1024 " +
+				"flows 128 depth 127",
+		},
+		"prio": {
+			obj: Object{Msg{Ifindex: 1, Handle: 0x10000, Parent: HandleRoot},
+				Attribute{Kind: "prio", Prio: &Prio{Bands: 3, PrioMap: DefaultPriomap()}}},
+			op:   "add",
+			want: "tc qdisc add dev eth0 root handle 1: prio bands 3 priomap 1 2 2 2 1 2 0 0 1 1 1 1 1 1 1 1",
+		},
+		"pfifo_fast": {
+			obj: Object{Msg{Ifindex: 1, Parent: HandleRoot},
+				Attribute{Kind: "pfifo_fast", Prio: &Prio{Bands: 3, PrioMap: DefaultPriomap()}}},
+			op:   "add",
+			want: "tc qdisc add dev eth0 root pfifo_fast",
+		},
+		"pfifo": {
+			obj: Object{Msg{Ifindex: 1, Parent: 0x10020},
+				Attribute{Kind: "pfifo", Pfifo: &FifoOpt{Limit: 100}}},
+			op:   "add",
+			want: "tc qdisc add dev eth0 parent 1:20 pfifo limit 100",
+		},
+		"bfifo": {
+			obj: Object{Msg{Ifindex: 1, Parent: HandleRoot},
+				Attribute{Kind: "bfifo", Bfifo: &FifoOpt{Limit: 10240}}},
+			op:   "add",
+			want: "tc qdisc add dev eth0 root bfifo limit 10240",
+		},
+		"clsact": {
+			obj:  Object{Msg{Ifindex: 1, Handle: 0xFFFF0000, Parent: HandleIngress}, Attribute{Kind: "clsact"}},
+			op:   "add",
+			want: "tc qdisc add dev eth0 clsact",
+		},
+		"drr class": {
+			obj: Object{Msg{Ifindex: 1, Handle: 0x10001, Parent: 0x10000},
+				Attribute{Kind: "drr", Drr: &Drr{Quantum: uint32Ptr(1514)}}},
+			op:   "add",
+			want: "tc class add dev eth0 parent 1: classid 1:1 drr quantum 1514",
+		},
+		"qfq class": {
+			obj: Object{Msg{Ifindex: 1, Handle: 0x10001, Parent: 0x10000},
+				Attribute{Kind: "qfq", Qfq: &Qfq{Weight: uint32Ptr(10), Lmax: uint32Ptr(1514)}}},
+			op:   "add",
+			want: "tc class add dev eth0 parent 1: classid 1:1 qfq weight 10 maxpkt 1514",
+		},
+		"qfq class without options": {
+			obj:  Object{Msg{Ifindex: 1, Handle: 0x10001, Parent: 0x10000}, Attribute{Kind: "qfq"}},
+			op:   "add",
+			want: "tc class add dev eth0 parent 1: classid 1:1 qfq",
+		},
+		"u32 match": {
+			obj: Object{Msg{Ifindex: 1, Parent: 0x10000, Info: infoIP},
+				Attribute{Kind: "u32", U32: &U32{ClassID: uint32Ptr(0x10010),
+					Sel: &U32Sel{Flags: U32Terminal, NKeys: 1, Keys: []U32Key{
+						{Val: ntohl(0x0a000001), Mask: ntohl(0xffffffff), Off: 16},
+					}}}}},
+			op:   "add",
+			want: "tc filter add dev eth0 parent 1: protocol ip prio 1 u32 match u32 0x0a000001 0xffffffff at 16 classid 1:10",
+		},
+		"u32 hash table": {
+			obj: Object{Msg{Ifindex: 1, Handle: 0x2000000, Parent: 0x10000, Info: infoIP},
+				Attribute{Kind: "u32", U32: &U32{Divisor: uint32Ptr(256)}}},
+			op:   "add",
+			want: "tc filter add dev eth0 parent 1: handle 20: protocol ip prio 1 u32 divisor 256",
+		},
+		"u32 link": {
+			obj: Object{Msg{Ifindex: 1, Handle: 0x80000800, Parent: 0x10000, Info: infoIP},
+				Attribute{Kind: "u32", U32: &U32{Hash: uint32Ptr(0x80000000), Link: uint32Ptr(0x2000000),
+					Flags: uint32Ptr(clsFlagsSkipHw),
+					Sel: &U32Sel{Hoff: 16, Hmask: 0xff, Keys: []U32Key{
+						{Val: ntohl(0x0a000000), Mask: ntohl(0xffffff00), Off: 16},
+					}}}}},
+			op: "add",
+			want: "tc filter add dev eth0 parent 1: handle 800::800 protocol ip prio 1 u32 ht 800: " +
+				"match u32 0x0a000000 0xffffff00 at 16 hashkey mask 0x000000ff at 16 link 20: skip_hw",
+		},
+		"flower": {
+			obj: Object{Msg{Ifindex: 1, Handle: 0x1, Parent: HandleClsactIngress, Info: infoIP},
+				Attribute{Kind: "flower", Flower: &Flower{ClassID: uint32Ptr(0x10010), KeyEthType: uint16Ptr(0x0800),
+					KeyEthDst: &dstMac, KeyIPProto: uint8Ptr(6), KeyIPv4Dst: &dstIP, KeyIPv4DstMask: &dstMask,
+					KeyTCPDst: uint16Ptr(80), Flags: uint32Ptr(clsFlagsSkipSw)}}},
+			op: "add",
+			want: "tc filter add dev eth0 ingress handle 0x1 protocol ip prio 1 flower classid 1:10 " +
+				"dst_mac 00:11:22:33:44:55 ip_proto tcp dst_ip 10.0.0.1/24 dst_port 80 skip_sw",
+		},
+		"flower all": {
+			obj: Object{Msg{Ifindex: 1, Parent: 0x10000, Info: infoAll},
+				Attribute{Kind: "flower", Flower: &Flower{ClassID: uint32Ptr(0x10020)}}},
+			op:   "add",
+			want: "tc filter add dev eth0 parent 1: protocol all prio 49152 flower classid 1:20",
+		},
+		"block filter": {
+			obj: Object{Msg{Ifindex: MagicBlock, Parent: 22, Info: infoIP},
+				Attribute{Kind: "flower", Flower: &Flower{ClassID: uint32Ptr(0x10020)}}},
+			op:   "add",
+			want: "tc filter add block 22 protocol ip prio 1 flower classid 1:20",
+		},
+		"block qdisc": {
+			obj: Object{Msg{Ifindex: MagicBlock, Handle: 0x10000, Parent: HandleRoot},
+				Attribute{Kind: "htb", Htb: &Htb{Init: &HtbGlob{Version: 3, Rate2Quantum: 10, Defcls: 0x30}}}},
+			op:  "add",
+			err: ErrInvalidDev,
+		},
+		"delete qdisc": {
+			obj: Object{Msg{Ifindex: 1, Handle: 0x10000, Parent: HandleRoot},
+				Attribute{Kind: "htb", Htb: &Htb{Init: &HtbGlob{Version: 3, Rate2Quantum: 10, Defcls: 0x30}}}},
+			op:   "del",
+			want: "tc qdisc del dev eth0 root handle 1:",
+		},
+		"delete class": {
+			obj:  Object{Msg{Ifindex: 1, Handle: 0x10010, Parent: 0x10001}, Attribute{Kind: "htb"}},
+			op:   "delete",
+			want: "tc class delete dev eth0 parent 1:1 classid 1:10",
+		},
+		"delete filter": {
+			obj:  Object{Msg{Ifindex: 1, Handle: 0x80000800, Parent: 0x10000, Info: infoIP}, Attribute{Kind: "u32"}},
+			op:   "del",
+			want: "tc filter del dev eth0 parent 1: handle 800::800 protocol ip prio 1 u32",
+		},
+		"unsupported qdisc kind": {
+			obj: Object{Msg{Ifindex: 1, Parent: HandleRoot},
+				Attribute{Kind: "cake", Cake: &Cake{}}},
+			op:  "add",
+			err: ErrNotImplemented,
+		},
+		"unsupported filter kind": {
+			obj: Object{Msg{Ifindex: 1, Parent: 0x10000, Info: infoIP},
+				Attribute{Kind: "matchall", Matchall: &Matchall{ClassID: uint32Ptr(0x10010)}}},
+			op:  "add",
+			err: ErrNotImplemented,
+		},
+		"unsupported netem option": {
+			obj: Object{Msg{Ifindex: 1, Parent: HandleRoot},
+				Attribute{Kind: "netem", Netem: &Netem{Corrupt: &NetemCorrupt{Probability: 1}}}},
+			op:  "add",
+			err: ErrNotImplemented,
+		},
+		"unsupported u32 action": {
+			obj: Object{Msg{Ifindex: 1, Parent: 0x10000, Info: infoIP},
+				Attribute{Kind: "u32", U32: &U32{Sel: &U32Sel{}, Actions: &[]*Action{{Kind: "gact"}}}}},
+			op:  "add",
+			err: ErrNotImplemented,
+		},
+		"flower port without ip_proto": {
+			obj: Object{Msg{Ifindex: 1, Parent: 0x10000, Info: infoIP},
+				Attribute{Kind: "flower", Flower: &Flower{KeyUDPDst: uint16Ptr(53)}}},
+			op:  "add",
+			err: ErrInvalidArg,
+		},
+		"flower non-contiguous mask": {
+			obj: Object{Msg{Ifindex: 1, Parent: 0x10000, Info: infoIP},
+				Attribute{Kind: "flower", Flower: &Flower{KeyIPv4Dst: &dstIP, KeyIPv4DstMask: &dstIP}}},
+			op:  "add",
+			err: ErrNotImplemented,
+		},
+		"invalid op": {
+			obj: Object{Msg{Ifindex: 1, Parent: HandleRoot}, Attribute{Kind: "clsact"}},
+			op:  "show",
+			err: ErrInvalidArg,
+		},
+		"invalid object": {
+			obj: Object{Msg{Ifindex: 1, Parent: HandleRoot}, Attribute{Kind: "htb"}},
+			op:  "add",
+			err: ErrNoArg,
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Command(&testcase.obj, testcase.op)
+			if testcase.err != nil {
+				if !errors.Is(err, testcase.err) {
+					t.Fatalf("expected error %v but got %v", testcase.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != testcase.want {
+				t.Fatalf("expected\n%q\nbut got\n%q", testcase.want, got)
+			}
+		})
+	}
+
+	t.Run("unknown interface", func(t *testing.T) {
+		_, err := Command(&Object{Msg{Ifindex: 42, Parent: HandleRoot}, Attribute{Kind: "clsact"}}, "add")
+		if err == nil {
+			t.Fatalf("expected error for unknown interface")
+		}
+	})
+	t.Run("nil", func(t *testing.T) {
+		if _, err := Command(nil, "add"); !errors.Is(err, ErrNoArg) {
+			t.Fatalf("expected ErrNoArg but got %v", err)
+		}
+	})
+}
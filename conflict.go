@@ -0,0 +1,70 @@
+package tc
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+)
+
+// ConflictError is returned with Config.VerifyWrites or Verify, if the
+// kernel refused to add a qdisc with EEXIST. It names the existing qdisc,
+// which either uses the requested handle under another parent or is
+// attached to the requested parent. It wraps the error of the kernel.
+type ConflictError struct {
+	// Requested is the qdisc, that was refused.
+	Requested Object
+	// Existing is the qdisc, that the request conflicts with.
+	Existing Object
+	// Err is the error of the kernel, usually syscall.EEXIST.
+	Err error
+}
+
+func (e *ConflictError) Error() string {
+	if e.Requested.Handle != 0 && e.Existing.Handle == e.Requested.Handle {
+		return fmt.Sprintf("%s: handle %s already used by %s under %s: %v", e.Requested,
+			core.FormatHandle(e.Existing.Handle), e.Existing.Kind, core.FormatHandle(e.Existing.Parent), e.Err)
+	}
+	return fmt.Sprintf("%s: parent %s already used by %s %s: %v", e.Requested,
+		core.FormatHandle(e.Existing.Parent), e.Existing.Kind, core.FormatHandle(e.Existing.Handle), e.Err)
+}
+
+// Unwrap returns the error of the kernel.
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+// explainConflict returns a *ConflictError for err, if the kernel refused
+// to add the qdisc info with EEXIST and the conflicting qdisc is found in a
+// dump of the device. The kernel refuses a handle, that is used anywhere on
+// the device, and a parent, that already has a qdisc, see
+// linux/net/sched/sch_api.c:tc_modify_qdisc(). Otherwise err is returned as
+// it is.
+func (tc *Tc) explainConflict(info *Object, err error) error {
+	if !tc.verifyWrites || !errors.Is(err, syscall.EEXIST) {
+		return err
+	}
+	qdiscs, dumpErr := tc.get(unix.RTM_GETQDISC, &Msg{Family: unix.AF_UNSPEC, Ifindex: info.Ifindex})
+	if dumpErr != nil {
+		return err
+	}
+	var parent *Object
+	for i := range qdiscs {
+		qdisc := &qdiscs[i]
+		if qdisc.Ifindex != info.Ifindex {
+			continue
+		}
+		if info.Handle != 0 && qdisc.Handle == info.Handle {
+			return &ConflictError{Requested: *info, Existing: *qdisc, Err: err}
+		}
+		if parent == nil && qdisc.Parent == info.Parent {
+			parent = qdisc
+		}
+	}
+	if parent == nil {
+		return err
+	}
+	return &ConflictError{Requested: *info, Existing: *parent, Err: err}
+}
@@ -0,0 +1,143 @@
+package tc
+
+import (
+	"errors"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nltest"
+)
+
+func TestExplainConflict(t *testing.T) {
+	qdisc := func(ifindex, handle, parent uint32, kind string) Object {
+		return Object{Msg: Msg{Family: unix.AF_UNSPEC, Ifindex: ifindex, Handle: handle, Parent: parent},
+			Attribute: Attribute{Kind: kind}}
+	}
+	htb := qdisc(2, core.BuildHandle(0x1, 0x0), HandleRoot, "htb")
+	leaf := qdisc(2, core.BuildHandle(0x10, 0x0), core.BuildHandle(0x1, 0x10), "fq_codel")
+	fqCodel := Attribute{Kind: "fq_codel", FqCodel: &FqCodel{Limit: uint32Ptr(1000)}}
+	requested := Object{Msg: Msg{Family: unix.AF_UNSPEC, Ifindex: 2, Handle: core.BuildHandle(0x1, 0x0),
+		Parent: core.BuildHandle(0x10, 0x1)}, Attribute: fqCodel}
+
+	tests := map[string]struct {
+		config   bool
+		call     bool
+		errno    syscall.Errno
+		request  Object
+		dump     []Object
+		dumpErr  bool
+		existing *Object
+		msg      string
+		err      error
+	}{
+		"handle under root": {
+			config:   true,
+			errno:    syscall.EEXIST,
+			request:  requested,
+			dump:     []Object{qdisc(3, core.BuildHandle(0x1, 0x0), HandleRoot, "fq_codel"), htb, leaf},
+			existing: &htb,
+			msg:      "handle 1: already used by htb under root",
+			err:      syscall.EEXIST,
+		},
+		"parent in use": {
+			call:  true,
+			errno: syscall.EEXIST,
+			request: Object{Msg: Msg{Family: unix.AF_UNSPEC, Ifindex: 2, Handle: core.BuildHandle(0x20, 0x0),
+				Parent: core.BuildHandle(0x1, 0x10)}, Attribute: fqCodel},
+			dump:     []Object{htb, leaf},
+			existing: &leaf,
+			msg:      "parent 1:10 already used by fq_codel 10:",
+			err:      syscall.EEXIST,
+		},
+		"not found": {
+			config:  true,
+			errno:   syscall.EEXIST,
+			request: requested,
+			dump:    []Object{leaf},
+			err:     syscall.EEXIST,
+		},
+		"dump failed": {
+			config:  true,
+			errno:   syscall.EEXIST,
+			request: requested,
+			dumpErr: true,
+			err:     syscall.EEXIST,
+		},
+		"disabled": {
+			errno:   syscall.EEXIST,
+			request: requested,
+			dump:    []Object{htb},
+			err:     syscall.EEXIST,
+		},
+		"other error": {
+			config:  true,
+			errno:   syscall.EINVAL,
+			request: requested,
+			dump:    []Object{htb},
+			err:     syscall.EINVAL,
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			var dumps int
+			tcSocket := &Tc{
+				con: nltest.Dial(func(req []netlink.Message) ([]netlink.Message, error) {
+					if req[0].Header.Type == unix.RTM_NEWQDISC {
+						return nltest.Error(int(testcase.errno), req)
+					}
+					dumps++
+					if testcase.dumpErr {
+						return nltest.Error(int(syscall.EPERM), req)
+					}
+					var msgs []netlink.Message
+					for _, qdisc := range testcase.dump {
+						data, err := marshalStruct(&qdisc.Msg)
+						if err != nil {
+							t.Fatalf("could not encode Msg: %v", err)
+						}
+						attrs, err := marshalAttributes([]tcOption{{Interpretation: vtString, Type: tcaKind, Data: qdisc.Kind}})
+						if err != nil {
+							t.Fatalf("could not encode attributes: %v", err)
+						}
+						msgs = append(msgs, netlink.Message{Header: netlink.Header{Type: unix.RTM_NEWQDISC},
+							Data: append(data, attrs...)})
+					}
+					return msgs, nil
+				}),
+				verifyWrites: testcase.config,
+			}
+			defer tcSocket.Close()
+
+			qd := tcSocket.Qdisc()
+			if testcase.call {
+				qd = qd.Verify()
+			}
+			err := qd.Add(&testcase.request)
+			if !errors.Is(err, testcase.err) {
+				t.Fatalf("expected error %v but got %v", testcase.err, err)
+			}
+			if !testcase.config && !testcase.call && dumps != 0 {
+				t.Fatalf("expected no dump but got %d", dumps)
+			}
+			var conflict *ConflictError
+			if errors.As(err, &conflict) != (testcase.existing != nil) {
+				t.Fatalf("expected existing qdisc %v but got %v", testcase.existing, err)
+			}
+			if testcase.existing == nil {
+				return
+			}
+			if diff := cmp.Diff(*testcase.existing, conflict.Existing); diff != "" {
+				t.Fatalf("existing qdisc missmatch (-want +got):\n%s", diff)
+			}
+			if want := testcase.request.String() + ": " + testcase.msg + ": "; !strings.HasPrefix(err.Error(), want) {
+				t.Fatalf("expected error message %q but got %q", want, err.Error())
+			}
+		})
+	}
+}
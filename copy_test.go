@@ -0,0 +1,115 @@
+package tc
+
+import (
+	"encoding/json"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/florianl/go-tc/core"
+)
+
+// mutate changes every value, that is reachable from v.
+func mutate(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			mutate(v.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Field(i).CanSet() {
+				mutate(v.Field(i))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			mutate(v.Index(i))
+		}
+	case reflect.String:
+		v.SetString(v.String() + "-copy")
+	case reflect.Bool:
+		v.SetBool(!v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(v.Int() + 1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(v.Uint() + 1)
+	}
+}
+
+// testCopy copies obj, mutates the copy and verifies that obj is untouched.
+func testCopy(t *testing.T, obj *Object) {
+	t.Helper()
+	want, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatalf("could not marshal object: %v", err)
+	}
+	c := obj.Copy()
+	if !reflect.DeepEqual(obj, c) {
+		t.Fatalf("copy differs from original:\n%#v\n%#v", obj, c)
+	}
+	mutate(reflect.ValueOf(c))
+	got, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatalf("could not marshal object: %v", err)
+	}
+	if string(want) != string(got) {
+		t.Fatalf("modifying the copy changed the original:\n%s\n%s", want, got)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	mac, _ := net.ParseMAC("00:53:00:00:00:01")
+	ip := net.IP{192, 0, 2, 1}
+
+	tests := map[string]*Object{
+		"empty": {},
+		"htb": {
+			Msg: Msg{Ifindex: 1, Handle: core.BuildHandle(0x1, 0x10), Parent: core.BuildHandle(0x1, 0x0)},
+			Attribute: Attribute{Kind: "htb", Htb: &Htb{
+				Parms:  &HtbOpt{Rate: RateSpec{Rate: 125000}, Buffer: 1600},
+				Rtab:   &[]byte{1, 2, 3},
+				Rate64: uint64Ptr(125000),
+			}, Stats: &Stats{Bytes: 42}},
+		},
+		"flower": {
+			Msg: Msg{Ifindex: 1, Parent: HandleClsactIngress, Info: 0x10000300},
+			Attribute: Attribute{Kind: "flower", Flower: &Flower{
+				ClassID:    uint32Ptr(core.BuildHandle(0x1, 0x1)),
+				KeyEthDst:  &mac,
+				KeyIPv4Src: &ip,
+				Actions: &[]*Action{
+					{Kind: "tunnel_key", TunnelKey: &TunnelKey{KeyEncSrc: &ip, KeyEncDst: &ip}},
+					{Kind: "gact", Gact: &Gact{Parms: &GactParms{Action: 2}}},
+				},
+			}},
+		},
+		"u32": {
+			Msg: Msg{Ifindex: 1, Handle: 0x800, Info: 0x10000300},
+			Attribute: Attribute{Kind: "u32", U32: &U32{Sel: &U32Sel{NKeys: 2, Keys: []U32Key{
+				{Val: 1, Mask: 0xFFFFFFFF, Off: 12},
+				{Val: 2, Mask: 0xFFFFFFFF, Off: 16},
+			}}}},
+		},
+		"netem": {
+			Msg: Msg{Ifindex: 1, Handle: core.BuildHandle(0x1, 0x0), Parent: HandleRoot},
+			Attribute: Attribute{Kind: "netem", Netem: &Netem{
+				Qopt:      NetemQopt{Latency: 1000, Limit: 1000},
+				DelayDist: &[]int16{-1, 0, 1},
+			}},
+		},
+	}
+
+	for name, obj := range tests {
+		t.Run(name, func(t *testing.T) {
+			testCopy(t, obj)
+		})
+	}
+
+	t.Run("nil", func(t *testing.T) {
+		var obj *Object
+		if c := obj.Copy(); c != nil {
+			t.Fatalf("expected nil but got %#v", c)
+		}
+	})
+}
@@ -1,15 +1,34 @@
 package core
 
 import (
+	"math"
+	"sync"
 	"syscall"
 	"time"
 )
 
-var (
+// clockInfo holds the parameters of the clock of the packet scheduler.
+type clockInfo struct {
 	tickInUSec  float64
 	clockFactor float64
+}
+
+var (
+	clock     clockInfo
+	clockOnce sync.Once
+	// loadClock returns the clock parameters of the host. Tests replace it
+	// to be independent of the host.
+	loadClock = hostClock
 )
 
+// getClock returns the clock parameters, which are loaded on first use.
+func getClock() *clockInfo {
+	clockOnce.Do(func() {
+		clock = loadClock()
+	})
+	return &clock
+}
+
 const (
 	// iproute2/include/utils.h:timeUnitsPerSec
 	timeUnitsPerSec = 1000000
@@ -26,16 +45,65 @@ func Duration2TcTime(d time.Duration) (uint32, error) {
 	return uint32(v), nil
 }
 
+// TcTime2Duration is the inverse of Duration2TcTime().
+// It converts a time value, as used by iproute2, into a duration.
+func TcTime2Duration(t uint32) time.Duration {
+	return time.Duration(uint64(t)*(1000000/timeUnitsPerSec)) * time.Microsecond
+}
+
+// Duration2Ticks converts a given duration into the number of CPU ticks,
+// as expected by the kernel for various qdisc parameters.
+// On error it returns syscall.EINVAL.
+func Duration2Ticks(d time.Duration) (uint32, error) {
+	t, err := Duration2TcTime(d)
+	if err != nil {
+		return 0, err
+	}
+	if ticks := float64(t) * getClock().tickInUSec; ticks > math.MaxUint32 {
+		return 0, syscall.EINVAL
+	}
+	return Time2Tick(t), nil
+}
+
+// Ticks2Duration is the inverse of Duration2Ticks().
+// It converts a given number of CPU ticks into a duration.
+func Ticks2Duration(ticks uint32) time.Duration {
+	return TcTime2Duration(Tick2Time(ticks))
+}
+
+// SetClock overrides the clock parameters, that are read from /proc/net/psched
+// by default, and returns a function to restore the previous values.
+// It allows conversions independent of the clock resolution of the host, e.g.
+// for tests. On invalid arguments it returns syscall.EINVAL.
+// SetClock must not be called concurrently with conversions.
+func SetClock(t2us, us2t, clockRes uint32) (func(), error) {
+	if t2us == 0 || us2t == 0 || clockRes == 0 {
+		return func() {}, syscall.EINVAL
+	}
+	c := getClock()
+	prev := *c
+	c.clockFactor, c.tickInUSec = clockParameters(t2us, us2t, clockRes)
+	return func() {
+		*c = prev
+	}, nil
+}
+
+// clockParameters implements iproute2/tc/tc_core.c:tc_core_init().
+func clockParameters(t2us, us2t, clockRes uint32) (float64, float64) {
+	factor := float64(clockRes) / timeUnitsPerSec
+	return factor, float64(t2us) / float64(us2t) * factor
+}
+
 // Time2Tick implements iproute2/tc/tc_core:tc_core_time2tick().
 // It returns the number of CPU ticks for a given time in usec.
 func Time2Tick(time uint32) uint32 {
-	return uint32(float64(time) * tickInUSec)
+	return uint32(float64(time) * getClock().tickInUSec)
 }
 
 // Tick2Time implements iproute2/tc/tc_core:tc_core_tick2time().
 // It returns a time in usec for a given number of CPU ticks.
 func Tick2Time(tick uint32) uint32 {
-	return uint32(float64(tick) / tickInUSec)
+	return uint32(float64(tick) / getClock().tickInUSec)
 }
 
 // XmitTime implements iproute2/tc/tc_core:tc_calc_xmittime().
@@ -47,15 +115,15 @@ func XmitTime(rate uint64, size uint32) uint32 {
 // XmitSize implements iproute2/tc/tc_core:tc_calc_xmitsize().
 // It returns the size that can be transmitted at a given rate during a given time.
 func XmitSize(rate uint64, ticks uint32) uint32 {
-	return uint32(rate*uint64(Tick2Time(ticks))) / timeUnitsPerSec
+	return uint32(rate * uint64(Tick2Time(ticks)) / timeUnitsPerSec)
 }
 
 // Time2Ktime implements iproute2/tc/tc_core:tc_core_time2ktime().
 func Time2Ktime(time uint32) uint32 {
-	return uint32(uint64(time) * uint64(clockFactor))
+	return uint32(uint64(time) * uint64(getClock().clockFactor))
 }
 
 // Ktime2Time implements iproute2/tc/tc_core:tc_core_ktime2time().
 func Ktime2Time(ktime uint32) uint32 {
-	return uint32(float64(ktime) / clockFactor)
+	return uint32(float64(ktime) / getClock().clockFactor)
 }
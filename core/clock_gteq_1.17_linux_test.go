@@ -39,6 +39,26 @@ func TestReadPsched(t *testing.T) {
 		}
 	})
 
+	t.Run("ReadPsched from fixture", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		if err := os.MkdirAll(fmt.Sprintf("%s/net", tmpDir), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fmt.Sprintf("%s/net/psched", tmpDir),
+			[]byte("000003e8 00000040 000f4240 3b9aca00\n"), 0640); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("PROC_ROOT", tmpDir)
+
+		psched, err := ReadPsched()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := (Psched{TickToUsec: 0x3e8, UsecToTick: 0x40, ClockRes: 0xf4240, HiClockRes: 0x3b9aca00}); psched != want {
+			t.Fatalf("expected %+v but got %+v", want, psched)
+		}
+	})
+
 	t.Run("psched does not exist", func(t *testing.T) {
 		tmpDir := t.TempDir()
 		defer os.RemoveAll(tmpDir)
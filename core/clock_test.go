@@ -2,6 +2,7 @@ package core
 
 import (
 	"errors"
+	"sync"
 	"syscall"
 	"testing"
 	"time"
@@ -70,3 +71,120 @@ func TestDuration2TcTime(t *testing.T) {
 		})
 	}
 }
+
+func TestSetClock(t *testing.T) {
+	tests := map[string]struct {
+		t2us, us2t, clockRes uint32
+		ticks                uint32
+		err                  error
+	}{
+		// common content of /proc/net/psched on current kernels
+		"default":       {t2us: 0x3e8, us2t: 0x40, clockRes: 0xf4240, ticks: 1562500},
+		"clockRes 1GHz": {t2us: 0x3e8, us2t: 0x40, clockRes: 0x3b9aca00, ticks: 1562500000},
+		"1:1":           {t2us: 1, us2t: 1, clockRes: 0xf4240, ticks: 100000},
+		"t2us 0":        {t2us: 0, us2t: 1, clockRes: 1, err: syscall.EINVAL},
+		"us2t 0":        {t2us: 1, us2t: 0, clockRes: 1, err: syscall.EINVAL},
+		"clockRes 0":    {t2us: 1, us2t: 1, clockRes: 0, err: syscall.EINVAL},
+	}
+
+	for name, testcase := range tests {
+		testcase := testcase
+		t.Run(name, func(t *testing.T) {
+			restore, err := SetClock(testcase.t2us, testcase.us2t, testcase.clockRes)
+			if !errors.Is(err, testcase.err) {
+				t.Fatalf("expected %v but got %v", testcase.err, err)
+			}
+			defer restore()
+			if testcase.err != nil {
+				return
+			}
+
+			ticks, err := Duration2Ticks(100 * time.Millisecond)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ticks != testcase.ticks {
+				t.Fatalf("expected %d ticks but got %d", testcase.ticks, ticks)
+			}
+			if d := Ticks2Duration(ticks); d != 100*time.Millisecond {
+				t.Fatalf("expected %v but got %v", 100*time.Millisecond, d)
+			}
+		})
+	}
+}
+
+func TestTcTime2Duration(t *testing.T) {
+	for _, d := range []time.Duration{0, time.Microsecond, 73 * time.Millisecond, 73 * time.Second} {
+		tcTime, err := Duration2TcTime(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := TcTime2Duration(tcTime); got != d {
+			t.Fatalf("expected %v but got %v", d, got)
+		}
+	}
+	if _, err := Duration2Ticks(73 * time.Minute); !errors.Is(err, syscall.EINVAL) {
+		t.Fatalf("expected EINVAL but got %v", err)
+	}
+}
+
+// fakeHostClock pretends, that the host has the clock parameters host, and
+// counts the loads of them. It returns a function to restore the host.
+func fakeHostClock(host clockInfo, loads *int) func() {
+	prevLoad, prevClock := loadClock, clock
+	loadClock = func() clockInfo {
+		*loads++
+		return host
+	}
+	clockOnce = sync.Once{}
+	return func() {
+		loadClock, clock = prevLoad, prevClock
+	}
+}
+
+func TestClockOnce(t *testing.T) {
+	// A host with 1000 ticks per microsecond.
+	var loads int
+	defer fakeHostClock(clockInfo{tickInUSec: 1000, clockFactor: 1000}, &loads)()
+
+	for i := 0; i < 10; i++ {
+		if tick := Time2Tick(2); tick != 2000 {
+			t.Fatalf("expected 2000 ticks but got %d", tick)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("expected the clock to be loaded once but got %d loads", loads)
+	}
+
+	// SetClock makes conversions independent of the host.
+	restore, err := SetClock(0x3e8, 0x40, 0xf4240)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ticks, err := Duration2Ticks(100 * time.Millisecond); err != nil || ticks != 1562500 {
+		t.Fatalf("expected 1562500 ticks but got %d (%v)", ticks, err)
+	}
+	restore()
+	if tick := Time2Tick(2); tick != 2000 {
+		t.Fatalf("expected 2000 ticks after restore but got %d", tick)
+	}
+	if loads != 1 {
+		t.Fatalf("expected the clock to be loaded once but got %d loads", loads)
+	}
+}
+
+func BenchmarkTime2Tick(b *testing.B) {
+	var tick uint32
+	for i := 0; i < b.N; i++ {
+		tick += Time2Tick(uint32(i))
+	}
+	_ = tick
+}
+
+func BenchmarkDuration2Ticks(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := Duration2Ticks(time.Duration(i%1000) * time.Millisecond); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
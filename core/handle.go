@@ -1,11 +1,34 @@
 package core
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
 // constants from include/uapi/linux/pkt_sched.h
 const (
 	handleMajMask uint32 = 0xFFFF0000
 	handleMinMask uint32 = 0x0000FFFF
+
+	handleRoot    uint32 = 0xFFFFFFFF
+	handleIngress uint32 = 0xFFFFFFF1
+	// The parents of the filters of the ingress and egress hook of clsact.
+	handleClsactIngress uint32 = 0xFFFFFFF2
+	handleClsactEgress  uint32 = 0xFFFFFFF3
 )
 
+// handleNames contains the names of the special handles. The ingress and
+// the clsact qdisc share the parent TC_H_INGRESS, that is also TC_H_CLSACT.
+var handleNames = map[uint32]string{
+	handleRoot:          "root",
+	0:                   "none",
+	handleIngress:       "ingress",
+	handleClsactIngress: "clsact-ingress",
+	handleClsactEgress:  "clsact-egress",
+}
+
 // BuildHandle is a simple helper function to construct the handle for the Tcmsg struct
 func BuildHandle(maj, min uint32) uint32 {
 	return (((maj << 16) & handleMajMask) | (min & handleMinMask))
@@ -17,3 +40,62 @@ func SplitHandle(handle uint32) (major, minor uint32) {
 	minor = handle & handleMinMask
 	return major, minor
 }
+
+// ParseHandle implements iproute2/tc/tc_util.c:get_tc_classid().
+// It converts the textual form of a handle like "1:10", "1:" or "root" into its
+// numerical representation. Major and minor are interpreted as hexadecimal values.
+// Besides "root" and "none", it accepts the names of the parents of the
+// ingress and clsact qdisc "ingress" and "clsact" and of the filters of
+// clsact "clsact-ingress" and "clsact-egress".
+func ParseHandle(s string) (uint32, error) {
+	if s == "clsact" {
+		return handleIngress, nil
+	}
+	for handle, name := range handleNames {
+		if s == name {
+			return handle, nil
+		}
+	}
+
+	sep := strings.IndexByte(s, ':')
+	if sep < 0 {
+		h, err := strconv.ParseUint(s, 16, 32)
+		if err != nil {
+			return 0, syscall.EINVAL
+		}
+		return uint32(h), nil
+	}
+	majStr, minStr := s[:sep], s[sep+1:]
+	var maj, min uint64
+	var err error
+	if majStr != "" {
+		if maj, err = strconv.ParseUint(majStr, 16, 16); err != nil {
+			return 0, syscall.EINVAL
+		}
+	}
+	if minStr != "" {
+		if min, err = strconv.ParseUint(minStr, 16, 16); err != nil {
+			return 0, syscall.EINVAL
+		}
+	}
+	return BuildHandle(uint32(maj), uint32(min)), nil
+}
+
+// FormatHandle returns the textual form of a handle like "1:10", "1:" or
+// "root". It follows iproute2/tc/tc_util.c:sprint_tc_classid() with one
+// deviation: the parents of clsact are returned as "clsact-ingress" and
+// "clsact-egress" instead of "ffff:fff2" and "ffff:fff3", so that every
+// special handle, that ParseHandle accepts by name, is returned by name.
+func FormatHandle(handle uint32) string {
+	if name, ok := handleNames[handle]; ok {
+		return name
+	}
+	maj, min := SplitHandle(handle)
+	switch {
+	case maj == 0:
+		return fmt.Sprintf(":%x", min)
+	case min == 0:
+		return fmt.Sprintf("%x:", maj)
+	}
+	return fmt.Sprintf("%x:%x", maj, min)
+}
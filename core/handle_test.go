@@ -1,6 +1,10 @@
 package core
 
-import "testing"
+import (
+	"errors"
+	"syscall"
+	"testing"
+)
 
 // Tests out the HandleStr function
 func TestSplitHandle(t *testing.T) {
@@ -43,3 +47,63 @@ func TestBuildHandle(t *testing.T) {
 		})
 	}
 }
+
+func TestParseHandle(t *testing.T) {
+	tests := map[string]struct {
+		want uint32
+		err  error
+	}{
+		"1:10":           {want: 0x10010},
+		"1:":             {want: 0x10000},
+		":10":            {want: 0x10},
+		"ffff:fff1":      {want: 0xFFFFFFF1},
+		"root":           {want: 0xFFFFFFFF},
+		"none":           {want: 0},
+		"ingress":        {want: 0xFFFFFFF1},
+		"clsact":         {want: 0xFFFFFFF1},
+		"clsact-ingress": {want: 0xFFFFFFF2},
+		"clsact-egress":  {want: 0xFFFFFFF3},
+		"egress":         {err: syscall.EINVAL},
+		"10010":          {want: 0x10010},
+		"10000:1":        {err: syscall.EINVAL},
+		"1:10000":        {err: syscall.EINVAL},
+		"1:x":            {err: syscall.EINVAL},
+		"":               {err: syscall.EINVAL},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseHandle(name)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ParseHandle() error = %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseHandle() = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatHandle(t *testing.T) {
+	tests := map[uint32]string{
+		0xFFFFFFFF: "root",
+		0:          "none",
+		0x10:       ":10",
+		0x10000:    "1:",
+		0x10010:    "1:10",
+		0xFFFFFFF1: "ingress",
+		0xFFFFFFF2: "clsact-ingress",
+		0xFFFFFFF3: "clsact-egress",
+		0xFFFF0000: "ffff:",
+		0xFFFFFFF4: "ffff:fff4",
+	}
+	for handle, want := range tests {
+		got := FormatHandle(handle)
+		if got != want {
+			t.Errorf("FormatHandle(%x) = %s, want %s", handle, got, want)
+		}
+		parsed, err := ParseHandle(got)
+		if err != nil || parsed != handle {
+			t.Errorf("ParseHandle(%s) = %x, %v, want %x", got, parsed, err, handle)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/josharian/native"
+)
+
+// Ethernet protocols from include/uapi/linux/if_ether.h, that are used as
+// protocol of filters.
+const (
+	EthPAll    uint16 = 0x0003
+	EthPIP     uint16 = 0x0800
+	EthPARP    uint16 = 0x0806
+	EthP8021Q  uint16 = 0x8100
+	EthPIPv6   uint16 = 0x86DD
+	EthPMPLSUC uint16 = 0x8847
+	EthPMPLSMC uint16 = 0x8848
+	EthP8021AD uint16 = 0x88A8
+)
+
+// protocols contains the names of ethernet protocols in the order of
+// iproute2/lib/ll_proto.c. If a protocol has more than one name, the first
+// one is printed.
+var protocols = []struct {
+	id   uint16
+	name string
+}{
+	{0x0060, "loop"},
+	{0x0200, "pup"},
+	{0x0201, "pupat"},
+	{EthPIP, "ip"},
+	{0x0805, "x25"},
+	{EthPARP, "arp"},
+	{0x08FF, "bpq"},
+	{0x0a00, "ieeepup"},
+	{0x0a01, "ieeepupat"},
+	{0x6000, "dec"},
+	{0x6001, "dna_dl"},
+	{0x6002, "dna_rc"},
+	{0x6003, "dna_rt"},
+	{0x6004, "lat"},
+	{0x6005, "diag"},
+	{0x6006, "cust"},
+	{0x6007, "sca"},
+	{0x8035, "rarp"},
+	{0x809B, "atalk"},
+	{0x80F3, "aarp"},
+	{0x8137, "ipx"},
+	{EthPIPv6, "ipv6"},
+	{0x8863, "ppp_disc"},
+	{0x8864, "ppp_ses"},
+	{0x884c, "atmmpoa"},
+	{0x8884, "atmfate"},
+	{0x0001, "802_3"},
+	{0x0002, "ax25"},
+	{EthPAll, "all"},
+	{0x0004, "802_2"},
+	{0x0005, "snap"},
+	{0x0006, "ddcmp"},
+	{0x0007, "wan_ppp"},
+	{0x0008, "ppp_mp"},
+	{0x0009, "localtalk"},
+	{0x000C, "can"},
+	{0x0010, "ppptalk"},
+	{0x0011, "tr_802_2"},
+	{0x0015, "mobitex"},
+	{0x0016, "control"},
+	{0x0017, "irda"},
+	{0x0018, "econet"},
+	{0x88CA, "tipc"},
+	{0x88A2, "aoe"},
+	{EthP8021Q, "802.1Q"},
+	{EthP8021AD, "802.1ad"},
+	{EthPMPLSUC, "mpls_uc"},
+	{EthPMPLSMC, "mpls_mc"},
+	{0x6558, "teb"},
+	{0x88CC, "LLDP"},
+	{EthPIP, "ipv4"},
+}
+
+// ParseProtocol implements iproute2/lib/ll_proto.c:ll_proto_a2n().
+// It converts the name of an ethernet protocol like "ip" or "802.1Q" or its
+// numerical value like "0x0800" into the protocol in host byte order. Names
+// are case insensitive. On error it returns syscall.EINVAL.
+func ParseProtocol(s string) (uint16, error) {
+	for _, p := range protocols {
+		if strings.EqualFold(p.name, s) {
+			return p.id, nil
+		}
+	}
+	id, err := strconv.ParseUint(s, 0, 16)
+	if err != nil {
+		return 0, syscall.EINVAL
+	}
+	return uint16(id), nil
+}
+
+// FormatProtocol returns the name of the ethernet protocol proto in host
+// byte order like iproute2/lib/ll_proto.c:ll_proto_n2a(). Unknown protocols
+// are returned as hexadecimal value like "0x88b5", that ParseProtocol and tc
+// accept.
+func FormatProtocol(proto uint16) string {
+	for _, p := range protocols {
+		if p.id == proto {
+			return p.name
+		}
+	}
+	return fmt.Sprintf("0x%04x", proto)
+}
+
+// BuildFilterInfo returns the info of the tcmsg of a filter with the priority
+// prio and the ethernet protocol proto in host byte order. The kernel expects
+// the protocol in network byte order.
+func BuildFilterInfo(prio, proto uint16) uint32 {
+	return BuildHandle(uint32(prio), uint32(htons(proto)))
+}
+
+// SplitFilterInfo extracts the priority and the ethernet protocol in host
+// byte order from the info of the tcmsg of a filter.
+func SplitFilterInfo(info uint32) (prio, proto uint16) {
+	maj, min := SplitHandle(info)
+	return uint16(maj), htons(uint16(min))
+}
+
+// htons converts v between host and network byte order.
+func htons(v uint16) uint16 {
+	b := make([]byte, 2)
+	native.Endian.PutUint16(b, v)
+	return binary.BigEndian.Uint16(b)
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/josharian/native"
+)
+
+func TestParseProtocol(t *testing.T) {
+	tests := map[string]struct {
+		want uint16
+		err  error
+	}{
+		"ip":      {want: 0x0800},
+		"ipv4":    {want: 0x0800},
+		"IPv6":    {want: 0x86DD},
+		"802.1Q":  {want: 0x8100},
+		"802.1q":  {want: 0x8100},
+		"802.1ad": {want: 0x88A8},
+		"all":     {want: 0x0003},
+		"lldp":    {want: 0x88CC},
+		"0x88b5":  {want: 0x88B5},
+		"3":       {want: 0x0003},
+		"0x10000": {err: syscall.EINVAL},
+		"ip4":     {err: syscall.EINVAL},
+		"":        {err: syscall.EINVAL},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseProtocol(name)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ParseProtocol() error = %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseProtocol() = %#04x, want %#04x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatProtocol(t *testing.T) {
+	tests := map[uint16]string{
+		0x0003: "all",
+		0x0800: "ip",
+		0x86DD: "ipv6",
+		0x8100: "802.1Q",
+		0x88A8: "802.1ad",
+		0x8847: "mpls_uc",
+		0x6558: "teb",
+		0x88B5: "0x88b5",
+	}
+	for proto, want := range tests {
+		got := FormatProtocol(proto)
+		if got != want {
+			t.Errorf("FormatProtocol(%#04x) = %s, want %s", proto, got, want)
+		}
+		parsed, err := ParseProtocol(got)
+		if err != nil || parsed != proto {
+			t.Errorf("ParseProtocol(%s) = %#04x, %v, want %#04x", got, parsed, err, proto)
+		}
+	}
+}
+
+func TestSplitFilterInfo(t *testing.T) {
+	// The lower 16 bits of tcm_info hold the protocol in network byte order.
+	tests := map[string]struct {
+		wire  []byte
+		prio  uint16
+		proto uint16
+	}{
+		"ip":     {wire: []byte{0x08, 0x00}, prio: 1, proto: EthPIP},
+		"ipv6":   {wire: []byte{0x86, 0xDD}, prio: 2, proto: EthPIPv6},
+		"802.1Q": {wire: []byte{0x81, 0x00}, prio: 49152, proto: EthP8021Q},
+		"all":    {wire: []byte{0x00, 0x03}, prio: 0xFFFF, proto: EthPAll},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			info := BuildHandle(uint32(tt.prio), uint32(native.Endian.Uint16(tt.wire)))
+			if got := BuildFilterInfo(tt.prio, tt.proto); got != info {
+				t.Errorf("BuildFilterInfo() = %#x, want %#x", got, info)
+			}
+			prio, proto := SplitFilterInfo(info)
+			if prio != tt.prio || proto != tt.proto {
+				t.Errorf("SplitFilterInfo() = %d, %#04x, want %d, %#04x", prio, proto, tt.prio, tt.proto)
+			}
+		})
+	}
+}
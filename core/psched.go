@@ -0,0 +1,55 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+)
+
+// Psched holds the parameters of the clock of the packet scheduler, that the
+// kernel reports in /proc/net/psched.
+type Psched struct {
+	// TickToUsec and UsecToTick are the ratio of ticks to microseconds.
+	TickToUsec uint32
+	UsecToTick uint32
+	// ClockRes is the resolution of the clock in Hz and HiClockRes the one of
+	// the high resolution timers.
+	ClockRes   uint32
+	HiClockRes uint32
+}
+
+// pschedFile returns the path of /proc/net/psched, which is beneath
+// PROC_ROOT, if it is set.
+func pschedFile() string {
+	if procRoot := os.Getenv("PROC_ROOT"); procRoot != "" {
+		return fmt.Sprintf("%s/net/psched", procRoot)
+	}
+	return "/proc/net/psched"
+}
+
+// ReadPsched reads the clock parameters from /proc/net/psched. Unlike the
+// conversions of this package, it does not fall back to defaults and the
+// values of SetClock are not returned.
+func ReadPsched() (Psched, error) {
+	fd, err := os.Open(pschedFile())
+	if err != nil {
+		return Psched{}, fmt.Errorf("could not open /proc/net/psched: %w", err)
+	}
+	defer fd.Close()
+	return parsePsched(fd)
+}
+
+// parsePsched parses the content of /proc/net/psched, like
+// linux/net/sched/sch_api.c:psched_show() prints it.
+func parsePsched(r io.Reader) (Psched, error) {
+	var p Psched
+	if _, err := fmt.Fscanf(r, "%08x %08x %08x %08x", &p.TickToUsec, &p.UsecToTick, &p.ClockRes,
+		&p.HiClockRes); err != nil {
+		return Psched{}, fmt.Errorf("could not read /proc/net/psched: %w", err)
+	}
+	if p.UsecToTick == 0 || p.ClockRes == 0 {
+		return Psched{}, fmt.Errorf("invalid values in /proc/net/psched: %w", syscall.EINVAL)
+	}
+	return p, nil
+}
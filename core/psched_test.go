@@ -0,0 +1,45 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParsePsched(t *testing.T) {
+	tests := map[string]struct {
+		data   string
+		psched Psched
+		err    error
+	}{
+		// Kernels with high resolution timers.
+		"hrtimer": {data: "000003e8 00000040 000f4240 3b9aca00\n",
+			psched: Psched{TickToUsec: 0x3e8, UsecToTick: 0x40, ClockRes: 0xf4240, HiClockRes: 0x3b9aca00}},
+		// Kernels without them.
+		"jiffies": {data: "000003e8 00000400 000f4240 000003e8\n",
+			psched: Psched{TickToUsec: 0x3e8, UsecToTick: 0x400, ClockRes: 0xf4240, HiClockRes: 0x3e8}},
+		"zero":    {data: "000003e8 00000000 000f4240 3b9aca00\n", err: syscall.EINVAL},
+		"invalid": {data: "hello world"},
+		"empty":   {},
+	}
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			psched, err := parsePsched(strings.NewReader(testcase.data))
+			if testcase.psched == (Psched{}) {
+				if err == nil || (testcase.err != nil && !errors.Is(err, testcase.err)) {
+					t.Fatalf("expected an error %v but got %v", testcase.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(testcase.psched, psched); diff != "" {
+				t.Fatalf("psched missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package tc
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/florianl/go-tc/core"
+)
+
+const (
+	// redDefaultProbability is the default of iproute2/tc/q_red.c:red_parse_opt().
+	redDefaultProbability = 0.02
+	// redDefaultBandwidth is the default of 10Mbit in bytes per second of iproute2/tc/q_red.c:red_parse_opt().
+	redDefaultBandwidth = 1250000
+)
+
+// DefaultFqCodel returns the options of a fq_codel qdisc, as they are reported by
+// `tc qdisc show` after `tc qdisc add dev X root fq_codel`.
+func DefaultFqCodel() *FqCodel {
+	return &FqCodel{
+		Target:        uint32Ptr(5000),   // 5ms
+		Limit:         uint32Ptr(10240),  // packets
+		Interval:      uint32Ptr(100000), // 100ms
+		ECN:           uint32Ptr(1),
+		Flows:         uint32Ptr(1024),
+		Quantum:       uint32Ptr(1514),
+		DropBatchSize: uint32Ptr(64),
+		MemoryLimit:   uint32Ptr(32 << 20),
+	}
+}
+
+// DefaultCodel returns the options of a codel qdisc, as they are reported by
+// `tc qdisc show` after `tc qdisc add dev X root codel`.
+func DefaultCodel() *Codel {
+	return &Codel{
+		Target:   uint32Ptr(5000),   // 5ms
+		Limit:    uint32Ptr(1000),   // packets
+		Interval: uint32Ptr(100000), // 100ms
+		ECN:      uint32Ptr(0),
+	}
+}
+
+// NormalizeFqCodel sets the options of fq, that are nil, to the defaults of
+// DefaultFqCodel. Kernels before 4.16 do not report DropBatchSize and
+// MemoryLimit, but behave as if they had their defaults. Options, that are
+// disabled unless they are set, like CEThreshold, stay nil.
+func NormalizeFqCodel(fq *FqCodel) {
+	fillDefaults(fq, DefaultFqCodel())
+}
+
+// NormalizeCodel sets the options of codel, that are nil, to the defaults of
+// DefaultCodel.
+func NormalizeCodel(codel *Codel) {
+	fillDefaults(codel, DefaultCodel())
+}
+
+// normalizers set the unset options of a kind to their defaults, so that
+// Diff and Equal compare a dump with a configuration, that leaves out
+// defaults. An object of the kind without options has all defaults.
+var normalizers = map[string]func(attr *Attribute){
+	"fq_codel": func(attr *Attribute) {
+		if attr.FqCodel == nil {
+			attr.FqCodel = &FqCodel{}
+		}
+		NormalizeFqCodel(attr.FqCodel)
+	},
+	"codel": func(attr *Attribute) {
+		if attr.Codel == nil {
+			attr.Codel = &Codel{}
+		}
+		NormalizeCodel(attr.Codel)
+	},
+}
+
+// normalized returns obj or, if its kind has defaults, a copy of obj with
+// them.
+func normalized(obj *Object) *Object {
+	normalize, ok := normalizers[obj.Kind]
+	if !ok || objectType(obj) != "qdisc" {
+		return obj
+	}
+	c := obj.Copy()
+	normalize(&c.Attribute)
+	return c
+}
+
+// fillDefaults sets the nil pointers of the struct, that dst points to, to
+// the fields of defaults.
+func fillDefaults(dst, defaults interface{}) {
+	d := reflect.ValueOf(dst).Elem()
+	def := reflect.ValueOf(defaults).Elem()
+	for i := 0; i < d.NumField(); i++ {
+		if f := d.Field(i); f.Kind() == reflect.Ptr && f.IsNil() {
+			f.Set(def.Field(i))
+		}
+	}
+}
+
+// DefaultSfq returns the options of a sfq qdisc, as they are reported by
+// `tc qdisc show` after `tc qdisc add dev X root sfq`.
+func DefaultSfq() *Sfq {
+	return &Sfq{
+		V0: SfqQopt{
+			Quantum: 1514,
+			Limit:   127,
+			Divisor: 1024,
+			Flows:   128,
+		},
+		Depth: 127,
+	}
+}
+
+// DefaultRed returns the options of a red qdisc, that iproute2 computes for
+// `tc qdisc add dev X root red limit limitBytes avpkt avpkt`.
+// The thresholds follow the recommendations of Sally Floyd
+// (http://www.icir.org/floyd/REDparameters.txt) with a bandwidth of 10Mbit and a
+// probability of 0.02.
+func DefaultRed(limitBytes, avpkt uint32) (*Red, error) {
+	if limitBytes == 0 || avpkt == 0 {
+		return nil, fmt.Errorf("Red: limit and avpkt are required: %w", ErrInvalidArg)
+	}
+	qthMax := limitBytes / 4
+	qthMin := qthMax / 3
+	burst := (2*qthMin + qthMax) / (3 * avpkt)
+
+	wlog, err := redEvalEwma(qthMin, burst, avpkt)
+	if err != nil {
+		return nil, err
+	}
+	plog, err := redEvalP(qthMin, qthMax, redDefaultProbability)
+	if err != nil {
+		return nil, err
+	}
+	scellLog, stab, err := redEvalIdleDamping(wlog, avpkt, redDefaultBandwidth)
+	if err != nil {
+		return nil, err
+	}
+	maxP, err := RedProbability(redDefaultProbability)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Red{
+		Parms: &RedQOpt{
+			Limit:    limitBytes,
+			QthMin:   qthMin,
+			QthMax:   qthMax,
+			Wlog:     wlog,
+			Plog:     plog,
+			ScellLog: scellLog,
+		},
+		Stab: &stab,
+		MaxP: uint32Ptr(maxP),
+	}, nil
+}
+
+// redEvalEwma implements iproute2/tc/tc_red.c:tc_red_eval_ewma().
+func redEvalEwma(qmin, burst, avpkt uint32) (uint8, error) {
+	w := 0.5
+	a := float64(burst) + 1 - float64(qmin)/float64(avpkt)
+	if a < 1.0 {
+		return 0, fmt.Errorf("Red: burst %d is too small, try burst %d: %w",
+			burst, 1+qmin/avpkt, ErrInvalidArg)
+	}
+	for wlog := uint8(1); wlog < 32; wlog++ {
+		if a <= (1-math.Pow(1-w, float64(burst)))/w {
+			return wlog, nil
+		}
+		w /= 2
+	}
+	return 0, fmt.Errorf("Red: failed to calculate EWMA constant: %w", ErrInvalidArg)
+}
+
+// redEvalP implements iproute2/tc/tc_red.c:tc_red_eval_P().
+func redEvalP(qmin, qmax uint32, prob float64) (uint8, error) {
+	if qmax == qmin {
+		return 0, nil
+	}
+	if qmax < qmin {
+		return 0, fmt.Errorf("Red: min %d exceeds max %d: %w", qmin, qmax, ErrInvalidArg)
+	}
+	prob /= float64(qmax - qmin)
+	for i := uint8(0); i < 32; i++ {
+		if prob > 1.0 {
+			return i, nil
+		}
+		prob *= 2
+	}
+	return 0, fmt.Errorf("Red: failed to calculate probability: %w", ErrInvalidArg)
+}
+
+// redEvalIdleDamping implements iproute2/tc/tc_red.c:tc_red_eval_idle_damping().
+// It returns the cell log together with the table of 256 values, that is passed to
+// the kernel as TCA_RED_STAB.
+func redEvalIdleDamping(wlog uint8, avpkt uint32, rate uint64) (uint8, []byte, error) {
+	xmitTime := float64(core.XmitTime(rate, avpkt))
+	lW := -math.Log(1.0-1.0/float64(uint32(1)<<wlog)) / xmitTime
+	maxTime := 31 / lW
+
+	var clog uint8
+	for clog = 0; clog < 32; clog++ {
+		if maxTime/float64(uint64(1)<<clog) < 512 {
+			break
+		}
+	}
+	if clog >= 32 {
+		return 0, nil, fmt.Errorf("Red: failed to calculate idle damping: %w", ErrInvalidArg)
+	}
+
+	stab := make([]byte, 256)
+	for i := 1; i < 255; i++ {
+		stab[i] = uint8(math.Min(float64(uint64(i)<<clog)*lW, 31))
+	}
+	stab[255] = 31
+	return clog, stab, nil
+}
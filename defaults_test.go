@@ -0,0 +1,136 @@
+package tc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDefaultRed(t *testing.T) {
+	restore, err := core.SetClock(0x3e8, 0x40, 0xf4240)
+	if err != nil {
+		t.Fatalf("could not set clock: %v", err)
+	}
+	defer restore()
+
+	tests := map[string]struct {
+		limit, avpkt uint32
+		parms        *RedQOpt
+		stab         map[int]byte
+		err          error
+	}{
+		// tc qdisc add dev X root red limit 400000 avpkt 1000
+		"limit 400000 avpkt 1000": {limit: 400000, avpkt: 1000,
+			parms: &RedQOpt{Limit: 400000, QthMin: 33333, QthMax: 100000, Wlog: 5, Plog: 22, ScellLog: 15},
+			stab:  map[int]byte{0: 0, 12: 0, 13: 1, 25: 2, 133: 11, 254: 21, 255: 31},
+		},
+		// tc qdisc add dev X root red limit 60000 avpkt 1500
+		"limit 60000 avpkt 1500": {limit: 60000, avpkt: 1500,
+			parms: &RedQOpt{Limit: 60000, QthMin: 5000, QthMax: 15000, Wlog: 2, Plog: 19, ScellLog: 12},
+			stab:  map[int]byte{0: 0, 15: 0, 16: 1, 190: 11, 191: 12, 254: 15, 255: 31},
+		},
+		"burst too small": {limit: 4000, avpkt: 1000, err: ErrInvalidArg},
+		"no avpkt":        {limit: 4000, err: ErrInvalidArg},
+	}
+
+	for name, testcase := range tests {
+		testcase := testcase
+		t.Run(name, func(t *testing.T) {
+			red, err := DefaultRed(testcase.limit, testcase.avpkt)
+			if !errors.Is(err, testcase.err) {
+				t.Fatalf("expected %v but got %v", testcase.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(testcase.parms, red.Parms); diff != "" {
+				t.Fatalf("Red parameters missmatch (-want +got):\n%s", diff)
+			}
+			if *red.MaxP != 85899345 {
+				t.Fatalf("expected MaxP 85899345 but got %d", *red.MaxP)
+			}
+			if len(*red.Stab) != 256 {
+				t.Fatalf("expected a Stab of 256 bytes but got %d", len(*red.Stab))
+			}
+			for i, v := range testcase.stab {
+				if (*red.Stab)[i] != v {
+					t.Fatalf("Stab[%d]: expected %d but got %d", i, v, (*red.Stab)[i])
+				}
+			}
+			if _, err := marshalRed(red); err != nil {
+				t.Fatalf("could not marshal Red: %v", err)
+			}
+		})
+	}
+}
+
+func TestDefaultQdiscs(t *testing.T) {
+	fqCodel := DefaultFqCodel()
+	if *fqCodel.Target != 5000 || *fqCodel.Interval != 100000 || *fqCodel.Limit != 10240 ||
+		*fqCodel.MemoryLimit != 33554432 {
+		t.Fatalf("unexpected fq_codel defaults: %#v", fqCodel)
+	}
+	codel := DefaultCodel()
+	if *codel.Target != 5000 || *codel.Interval != 100000 || *codel.Limit != 1000 {
+		t.Fatalf("unexpected codel defaults: %#v", codel)
+	}
+	sfq := DefaultSfq()
+	if sfq.V0.Limit != 127 || sfq.V0.Divisor != 1024 || sfq.Depth != 127 {
+		t.Fatalf("unexpected sfq defaults: %#v", sfq)
+	}
+
+	// Defaults must be usable as options.
+	if _, err := marshalFqCodel(fqCodel); err != nil {
+		t.Fatalf("could not marshal fq_codel defaults: %v", err)
+	}
+	if _, err := marshalCodel(codel); err != nil {
+		t.Fatalf("could not marshal codel defaults: %v", err)
+	}
+	if _, err := marshalSfq(sfq); err != nil {
+		t.Fatalf("could not marshal sfq defaults: %v", err)
+	}
+	if DefaultFqCodel() == fqCodel || DefaultFqCodel().Target == fqCodel.Target {
+		t.Fatal("defaults must not share memory")
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	// A kernel before 4.16 reports fq_codel without DropBatchSize and
+	// MemoryLimit.
+	sparse := &FqCodel{Target: uint32Ptr(5000), Limit: uint32Ptr(1000), Interval: uint32Ptr(100000),
+		ECN: uint32Ptr(1), Flows: uint32Ptr(1024), Quantum: uint32Ptr(1514)}
+	data, err := marshalFqCodel(sparse)
+	if err != nil {
+		t.Fatalf("could not marshal fq_codel: %v", err)
+	}
+	dumped := &FqCodel{}
+	if err := unmarshalFqCodel(data, dumped); err != nil {
+		t.Fatalf("could not unmarshal fq_codel: %v", err)
+	}
+
+	desired := DefaultFqCodel()
+	desired.Limit = uint32Ptr(1000)
+	NormalizeFqCodel(dumped)
+	if diff := cmp.Diff(desired, dumped); diff != "" {
+		t.Fatalf("normalized fq_codel missmatch (-want +got):\n%s", diff)
+	}
+
+	codel := &Codel{Limit: uint32Ptr(500)}
+	NormalizeCodel(codel)
+	want := DefaultCodel()
+	want.Limit = uint32Ptr(500)
+	if diff := cmp.Diff(want, codel); diff != "" {
+		t.Fatalf("normalized codel missmatch (-want +got):\n%s", diff)
+	}
+
+	qdisc := Msg{Ifindex: 2, Handle: 0x10000, Parent: HandleRoot}
+	config := &Object{Msg: qdisc, Attribute: Attribute{Kind: "fq_codel", FqCodel: &FqCodel{Limit: uint32Ptr(1000)}}}
+	if !Equal(&Object{Msg: qdisc, Attribute: Attribute{Kind: "fq_codel", FqCodel: dumped}}, config) {
+		t.Fatal("expected the dump to equal the configuration with defaults")
+	}
+	if config.FqCodel.ECN != nil {
+		t.Fatal("Equal must not modify the compared objects")
+	}
+}
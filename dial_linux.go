@@ -0,0 +1,114 @@
+//go:build linux
+// +build linux
+
+package tc
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/florianl/go-tc/internal/unix"
+	"github.com/mdlayher/netlink"
+)
+
+// dial opens a rtnetlink socket in the network namespace netns.
+func dial(netns int) (tcConn, error) {
+	con, err := netlink.Dial(unix.NETLINK_ROUTE, &netlink.Config{NetNS: netns})
+	if err != nil {
+		return nil, err
+	}
+	raw, err := con.SyscallConn()
+	if err != nil {
+		// Without access to the socket, replies are received as a whole.
+		return con, nil
+	}
+	return &batchConn{Conn: con, raw: raw, buf: make([]byte, os.Getpagesize())}, nil
+}
+
+// batchConn is a netlink.Conn, that receives multipart replies datagram by
+// datagram.
+type batchConn struct {
+	*netlink.Conn
+	raw syscall.RawConn
+	buf []byte
+}
+
+var _ batchReceiver = &batchConn{}
+
+// receiveBatch returns the messages of the next datagram. The messages share
+// the buffer of c. If a message reports an error, the messages before it are
+// returned together with the error.
+func (c *batchConn) receiveBatch() ([]netlink.Message, error) {
+	n, err := c.recv(syscall.MSG_PEEK | syscall.MSG_TRUNC)
+	if err != nil {
+		return nil, err
+	}
+	if n > len(c.buf) {
+		c.buf = make([]byte, n)
+	}
+	if n, err = c.recv(0); err != nil {
+		return nil, err
+	}
+
+	raw, err := syscall.ParseNetlinkMessage(c.buf[:n])
+	if err != nil {
+		return nil, &netlink.OpError{Op: "receive", Err: err}
+	}
+	msgs := make([]netlink.Message, 0, len(raw))
+	for _, r := range raw {
+		msg := netlink.Message{
+			Header: netlink.Header{
+				Length:   r.Header.Len,
+				Type:     netlink.HeaderType(r.Header.Type),
+				Flags:    netlink.HeaderFlags(r.Header.Flags),
+				Sequence: r.Header.Seq,
+				PID:      r.Header.Pid,
+			},
+			Data: r.Data,
+		}
+		if err := checkMessage(msg); err != nil {
+			return msgs, err
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// recv reads a datagram into the buffer of c and returns its size.
+func (c *batchConn) recv(flags int) (int, error) {
+	var n int
+	var rerr error
+	err := c.raw.Read(func(fd uintptr) bool {
+		n, _, rerr = syscall.Recvfrom(int(fd), c.buf, flags)
+		// Wait for the socket to become readable again.
+		return rerr != syscall.EAGAIN && rerr != syscall.EINTR
+	})
+	if err == nil {
+		err = rerr
+	}
+	if err != nil {
+		return 0, &netlink.OpError{Op: "receive", Err: err}
+	}
+	return n, nil
+}
+
+// checkMessage returns the error, that is reported by msg, in the same way
+// as netlink.Conn.Receive.
+func checkMessage(msg netlink.Message) error {
+	switch {
+	case msg.Header.Type == netlink.Error:
+	case msg.Header.Type == netlink.Done && msg.Header.Flags&netlink.Multi != 0:
+		if len(msg.Data) == 0 {
+			return nil
+		}
+	default:
+		return nil
+	}
+	if len(msg.Data) < 4 {
+		return &netlink.OpError{Op: "receive", Err: syscall.EBADMSG}
+	}
+	if errno := int32(nativeEndian.Uint32(msg.Data)); errno != 0 {
+		return &netlink.OpError{Op: "receive", Err: syscall.Errno(-errno)}
+	}
+	return nil
+}
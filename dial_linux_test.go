@@ -0,0 +1,54 @@
+//go:build linux
+// +build linux
+
+package tc
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/mdlayher/netlink"
+)
+
+func TestCheckMessage(t *testing.T) {
+	errno := func(e int32) []byte {
+		b := make([]byte, 4)
+		nativeEndian.PutUint32(b, uint32(e))
+		return b
+	}
+
+	tests := map[string]struct {
+		msg netlink.Message
+		err error
+	}{
+		"data": {msg: netlink.Message{Header: netlink.Header{Type: 42}, Data: errno(-2)}},
+		"ack":  {msg: netlink.Message{Header: netlink.Header{Type: netlink.Error}, Data: errno(0)}},
+		"error": {msg: netlink.Message{Header: netlink.Header{Type: netlink.Error}, Data: errno(-2)},
+			err: syscall.ENOENT},
+		"short error": {msg: netlink.Message{Header: netlink.Header{Type: netlink.Error}, Data: []byte{0x1}},
+			err: syscall.EBADMSG},
+		"done": {msg: netlink.Message{Header: netlink.Header{Type: netlink.Done, Flags: netlink.Multi}}},
+		"done with error": {msg: netlink.Message{Header: netlink.Header{Type: netlink.Done, Flags: netlink.Multi},
+			Data: errno(-16)}, err: syscall.EBUSY},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := checkMessage(testcase.msg)
+			if testcase.err == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, testcase.err) {
+				t.Fatalf("expected error %v but got %v", testcase.err, err)
+			}
+			var opErr *netlink.OpError
+			if !errors.As(err, &opErr) {
+				t.Fatalf("expected a netlink.OpError but got %T", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package tc
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// dial fails, as traffic control is only available on Linux. The codecs of
+// this package can still be used, e.g. with MarshalObject and UnmarshalObject.
+func dial(netns int) (tcConn, error) {
+	return nil, fmt.Errorf("rtnetlink is not available on %s: %w", runtime.GOOS, ErrNotImplemented)
+}
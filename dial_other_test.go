@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package tc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOpenOther(t *testing.T) {
+	if _, err := Open(&Config{}); !errors.Is(err, ErrNotImplemented) {
+		t.Fatalf("expected ErrNotImplemented but got %v", err)
+	}
+}
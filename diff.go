@@ -0,0 +1,193 @@
+package tc
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// FieldDiff describes a field, that differs between two objects.
+type FieldDiff struct {
+	// Path names the field like "Htb.Parms.Rate.Rate". Elements of slices
+	// are referenced by their index like "U32.Sel.Keys[1].Val".
+	Path string
+	// A and B contain the values of the field. Unset fields are nil.
+	A, B interface{}
+}
+
+func (d FieldDiff) String() string {
+	return fmt.Sprintf("%s: %v != %v", d.Path, d.A, d.B)
+}
+
+// ignoredFields contains fields, that are reported by the kernel and do not
+// represent a configuration.
+var ignoredFields = map[string]bool{
+	"Stats":      true,
+	"Stats2":     true,
+	"XStats":     true,
+	"RawStats":   true,
+	"ExtWarnMsg": true,
+	"HwOffload":  true,
+	"InHwCount":  true,
+	"Pcnt":       true,
+	"Tm":         true,
+	"RefCnt":     true,
+	"BindCnt":    true,
+	"Refcnt":     true,
+	"Bindcnt":    true,
+}
+
+// derivedFields returns the paths of fields, that are derived from other
+// fields of a kind and compared by them instead.
+func derivedFields(a, b *Object) map[string]bool {
+	derived := make(map[string]bool)
+	if objectType(a) != "filter" {
+		// For qdiscs and classes, the kernel reports its reference counter.
+		derived["Info"] = true
+	}
+	// Priority and Protocol of filters are compared by Info.
+	derived["Priority"] = true
+	derived["Protocol"] = true
+	switch a.Kind {
+	case "htb":
+		// The level of a class and the number of direct packets are
+		// maintained by the kernel.
+		derived["Htb.Parms.Level"] = true
+		derived["Htb.Init.DirectPkts"] = true
+		// Rate tables are not reported back.
+		derived["Htb.Rtab"] = true
+		derived["Htb.Ctab"] = true
+		if a.Htb != nil && a.Htb.Rate64 != nil || b.Htb != nil && b.Htb.Rate64 != nil {
+			derived["Htb.Parms.Rate.Rate"] = true
+		}
+		if a.Htb != nil && a.Htb.Ceil64 != nil || b.Htb != nil && b.Htb.Ceil64 != nil {
+			derived["Htb.Parms.Ceil.Rate"] = true
+		}
+	case "netem":
+		if a.Netem != nil && a.Netem.Latency64 != nil || b.Netem != nil && b.Netem.Latency64 != nil {
+			derived["Netem.Qopt.Latency"] = true
+		}
+		if a.Netem != nil && a.Netem.Jitter64 != nil || b.Netem != nil && b.Netem.Jitter64 != nil {
+			derived["Netem.Qopt.Jitter"] = true
+		}
+		if a.Netem != nil && a.Netem.Rate64 != nil || b.Netem != nil && b.Netem.Rate64 != nil {
+			derived["Netem.Rate.Rate"] = true
+		}
+		// Without a seed, the kernel chooses one.
+		if a.Netem == nil || b.Netem == nil || a.Netem.PrngSeed == nil || b.Netem.PrngSeed == nil {
+			derived["Netem.PrngSeed"] = true
+		}
+	case "cbq":
+		derived["Cbq.RTab"] = true
+	case "red":
+		// linux/net/sched/sch_red.c:red_dump() does not report the table.
+		derived["Red.Stab"] = true
+	}
+	return derived
+}
+
+// Equal reports whether a and b represent the same configuration.
+// Statistics, timestamps and other fields, that are maintained by the kernel,
+// are ignored. So are fields, that are derived from other fields, like the
+// 32 bit rate of HTB if its 64 bit rate is set. Unset options of kinds with
+// known defaults, like fq_codel, equal their defaults.
+func Equal(a, b *Object) bool {
+	return len(Diff(a, b)) == 0
+}
+
+// Diff returns the fields, in which the configurations of a and b differ.
+// It ignores the same fields as Equal.
+func Diff(a, b *Object) []FieldDiff {
+	if a == nil || b == nil {
+		if a == b {
+			return nil
+		}
+		return []FieldDiff{{A: diffValue(reflect.ValueOf(a)), B: diffValue(reflect.ValueOf(b))}}
+	}
+	a, b = normalized(a), normalized(b)
+	d := &differ{derived: derivedFields(a, b)}
+	d.diff("", reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem())
+	return d.diffs
+}
+
+type differ struct {
+	derived map[string]bool
+	diffs   []FieldDiff
+}
+
+func (d *differ) add(path string, a, b reflect.Value) {
+	d.diffs = append(d.diffs, FieldDiff{Path: path, A: diffValue(a), B: diffValue(b)})
+}
+
+func (d *differ) diff(path string, a, b reflect.Value) {
+	switch a.Kind() {
+	case reflect.Ptr:
+		switch {
+		case a.IsNil() && b.IsNil():
+		case a.IsNil() || b.IsNil():
+			d.add(path, a, b)
+		default:
+			d.diff(path, a.Elem(), b.Elem())
+		}
+	case reflect.Struct:
+		t := a.Type()
+		if t == reflect.TypeOf(Police{}) {
+			a = reflect.ValueOf(canonicalPolice(a.Interface().(Police)))
+			b = reflect.ValueOf(canonicalPolice(b.Interface().(Police)))
+		}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" || ignoredFields[field.Name] {
+				continue
+			}
+			fieldPath := path
+			if !field.Anonymous {
+				fieldPath = joinPath(path, field.Name)
+			}
+			if d.derived[fieldPath] {
+				continue
+			}
+			d.diff(fieldPath, a.Field(i), b.Field(i))
+		}
+	case reflect.Slice:
+		if a.Len() == 0 && b.Len() == 0 {
+			return
+		}
+		switch a.Type().Elem().Kind() {
+		case reflect.Ptr, reflect.Struct:
+			if a.Len() != b.Len() {
+				d.add(path, a, b)
+				return
+			}
+			for i := 0; i < a.Len(); i++ {
+				d.diff(path+"["+strconv.Itoa(i)+"]", a.Index(i), b.Index(i))
+			}
+		default:
+			if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+				d.add(path, a, b)
+			}
+		}
+	default:
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			d.add(path, a, b)
+		}
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// diffValue returns the value of v for FieldDiff. Pointers are resolved.
+func diffValue(v reflect.Value) interface{} {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	return v.Interface()
+}
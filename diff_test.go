@@ -0,0 +1,163 @@
+package tc
+
+import (
+	"net"
+	"testing"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiff(t *testing.T) {
+	mac1, _ := net.ParseMAC("00:53:00:00:00:01")
+	mac2, _ := net.ParseMAC("00:53:00:00:00:02")
+	qdisc := Msg{Ifindex: 1, Handle: core.BuildHandle(0x1, 0x0), Parent: HandleRoot}
+	class := Msg{Ifindex: 1, Handle: core.BuildHandle(0x1, 0x10), Parent: core.BuildHandle(0x1, 0x0)}
+	filter := Msg{Ifindex: 1, Handle: 0x800, Parent: core.BuildHandle(0x1, 0x0), Info: 0x10000300}
+
+	tests := map[string]struct {
+		a, b  *Object
+		diffs []FieldDiff
+	}{
+		"nil": {},
+		"nil object": {
+			a:     &Object{Msg: qdisc},
+			diffs: []FieldDiff{{A: Object{Msg: qdisc}}},
+		},
+		"stats": {
+			a: &Object{Msg: qdisc, Attribute: Attribute{Kind: "fq_codel", FqCodel: &FqCodel{Target: uint32Ptr(5000)}}},
+			b: &Object{Msg: Msg{Ifindex: 1, Handle: qdisc.Handle, Parent: HandleRoot, Info: 2},
+				Attribute: Attribute{Kind: "fq_codel", FqCodel: &FqCodel{Target: uint32Ptr(5000)},
+					Stats:  &Stats{Bytes: 42, Packets: 1},
+					XStats: &XStats{FqCodel: &FqCodelXStats{Type: 0}},
+					Stats2: &Stats2{Basic: &GenBasic{Bytes: 42}},
+				}},
+		},
+		"unset": {
+			a:     &Object{Msg: qdisc, Attribute: Attribute{Kind: "fq", Fq: &Fq{}}},
+			b:     &Object{Msg: qdisc, Attribute: Attribute{Kind: "fq", Fq: &Fq{Quantum: uint32Ptr(3028)}}},
+			diffs: []FieldDiff{{Path: "Fq.Quantum", A: nil, B: uint32(3028)}},
+		},
+		"unset defaults": {
+			a: &Object{Msg: qdisc, Attribute: Attribute{Kind: "fq_codel", FqCodel: &FqCodel{}}},
+			b: &Object{Msg: qdisc, Attribute: Attribute{Kind: "fq_codel", FqCodel: &FqCodel{Target: uint32Ptr(5000)}}},
+		},
+		"changed default": {
+			a:     &Object{Msg: qdisc, Attribute: Attribute{Kind: "fq_codel", FqCodel: &FqCodel{}}},
+			b:     &Object{Msg: qdisc, Attribute: Attribute{Kind: "fq_codel", FqCodel: &FqCodel{ECN: uint32Ptr(0)}}},
+			diffs: []FieldDiff{{Path: "FqCodel.ECN", A: uint32(1), B: uint32(0)}},
+		},
+		"police verdicts": {
+			a: &Object{Msg: filter, Attribute: Attribute{Kind: "basic", Basic: &Basic{Police: &Police{
+				Tbf: &Policy{Burst: 0x4c4b40}, Conform: policyActionPtr(PolicyOk), Exceed: policyActionPtr(PolicyShot)}}}},
+			b: &Object{Msg: filter, Attribute: Attribute{Kind: "basic", Basic: &Basic{Police: &Police{
+				Tbf: &Policy{Action: PolicyShot, Burst: 0x4c4b40}}}}},
+		},
+		"police conform": {
+			a: &Object{Msg: filter, Attribute: Attribute{Kind: "basic", Basic: &Basic{Police: &Police{
+				Conform: policyActionPtr(PolicyPipe)}}}},
+			b: &Object{Msg: filter, Attribute: Attribute{Kind: "basic", Basic: &Basic{Police: &Police{
+				Result: uint32Ptr(uint32(PolicyReclassify))}}}},
+			diffs: []FieldDiff{{Path: "Basic.Police.Conform", A: PolicyPipe, B: PolicyReclassify}},
+		},
+		"kind": {
+			a: &Object{Msg: qdisc, Attribute: Attribute{Kind: "sfq", Sfq: &Sfq{}}},
+			b: &Object{Msg: qdisc, Attribute: Attribute{Kind: "pfifo", Pfifo: &FifoOpt{Limit: 100}}},
+			diffs: []FieldDiff{
+				{Path: "Kind", A: "sfq", B: "pfifo"},
+				{Path: "Sfq", A: Sfq{}, B: nil},
+				{Path: "Pfifo", A: nil, B: FifoOpt{Limit: 100}},
+			},
+		},
+		"red table": {
+			a: &Object{Msg: qdisc, Attribute: Attribute{Kind: "red", Red: &Red{Parms: &RedQOpt{Limit: 1000},
+				Stab: bytesPtr(make([]byte, 256))}}},
+			b: &Object{Msg: qdisc, Attribute: Attribute{Kind: "red", Red: &Red{Parms: &RedQOpt{Limit: 1000}}}},
+		},
+		"htb class": {
+			a: &Object{Msg: class, Attribute: Attribute{Kind: "htb", Htb: &Htb{
+				Parms:  &HtbOpt{Rate: RateSpec{Rate: 0xFFFFFFFF}, Ceil: RateSpec{Rate: 0x1000}, Buffer: 10},
+				Rate64: uint64Ptr(10000000000),
+			}}},
+			b: &Object{Msg: class, Attribute: Attribute{Kind: "htb", Htb: &Htb{
+				Parms:  &HtbOpt{Rate: RateSpec{Rate: 0}, Ceil: RateSpec{Rate: 0x2000}, Buffer: 20, Level: 7},
+				Rate64: uint64Ptr(10000000000),
+			}}},
+			diffs: []FieldDiff{
+				{Path: "Htb.Parms.Ceil.Rate", A: uint32(0x1000), B: uint32(0x2000)},
+				{Path: "Htb.Parms.Buffer", A: uint32(10), B: uint32(20)},
+			},
+		},
+		"netem": {
+			a: &Object{Msg: qdisc, Attribute: Attribute{Kind: "netem", Netem: &Netem{
+				Qopt:      NetemQopt{Latency: 1000, Limit: 1000},
+				Latency64: int64Ptr(1000000),
+			}}},
+			b: &Object{Msg: qdisc, Attribute: Attribute{Kind: "netem", Netem: &Netem{
+				Qopt:      NetemQopt{Latency: 15625, Limit: 1000},
+				Latency64: int64Ptr(1000000),
+				PrngSeed:  uint64Ptr(0xCAFE),
+			}}},
+		},
+		"u32": {
+			a: &Object{Msg: filter, Attribute: Attribute{Kind: "u32", U32: &U32{
+				ClassID: uint32Ptr(core.BuildHandle(0x1, 0x10)),
+				Sel:     &U32Sel{NKeys: 1, Keys: []U32Key{{Val: 0x0100000A, Mask: 0xFFFFFFFF, Off: 16}}},
+			}}},
+			b: &Object{Msg: Msg{Ifindex: 1, Handle: 0x800, Parent: filter.Parent, Info: 0x20000300},
+				Attribute: Attribute{Kind: "u32", U32: &U32{
+					ClassID: uint32Ptr(core.BuildHandle(0x1, 0x10)),
+					Sel:     &U32Sel{NKeys: 1, Keys: []U32Key{{Val: 0x0200000A, Mask: 0xFFFFFFFF, Off: 16}}},
+					Pcnt:    uint64Ptr(42),
+				}}},
+			diffs: []FieldDiff{
+				{Path: "Info", A: uint32(0x10000300), B: uint32(0x20000300)},
+				{Path: "U32.Sel.Keys[0].Val", A: uint32(0x0100000A), B: uint32(0x0200000A)},
+			},
+		},
+		"flower": {
+			a: &Object{Msg: filter, Attribute: Attribute{Kind: "flower", Flower: &Flower{KeyEthDst: &mac1}}},
+			b: &Object{Msg: filter, Attribute: Attribute{Kind: "flower", Flower: &Flower{
+				KeyEthDst: &mac2,
+				InHwCount: uint32Ptr(1),
+			}}},
+			diffs: []FieldDiff{{Path: "Flower.KeyEthDst", A: mac1, B: mac2}},
+		},
+		"actions": {
+			a: &Object{Msg: filter, Attribute: Attribute{Kind: "matchall", Matchall: &Matchall{
+				Actions: &[]*Action{{Kind: "gact", Gact: &Gact{Parms: &GactParms{Action: 2}}}},
+			}}},
+			b: &Object{Msg: filter, Attribute: Attribute{Kind: "matchall", Matchall: &Matchall{
+				Actions: &[]*Action{{Kind: "gact", Gact: &Gact{
+					Parms: &GactParms{Action: 2, RefCnt: 1, BindCnt: 1},
+					Tm:    &Tcft{Install: 42, LastUse: 42},
+				}}},
+			}}},
+		},
+		"actions count": {
+			a: &Object{Msg: filter, Attribute: Attribute{Kind: "matchall", Matchall: &Matchall{
+				Actions: &[]*Action{{Kind: "gact", Gact: &Gact{Parms: &GactParms{Action: 2}}}},
+			}}},
+			b: &Object{Msg: filter, Attribute: Attribute{Kind: "matchall", Matchall: &Matchall{
+				Actions: &[]*Action{},
+			}}},
+			diffs: []FieldDiff{{
+				Path: "Matchall.Actions",
+				A:    []*Action{{Kind: "gact", Gact: &Gact{Parms: &GactParms{Action: 2}}}},
+				B:    []*Action{},
+			}},
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			diffs := Diff(testcase.a, testcase.b)
+			if diff := cmp.Diff(testcase.diffs, diffs); diff != "" {
+				t.Fatalf("Diff missmatch (-want +got):\n%s", diff)
+			}
+			if equal := Equal(testcase.a, testcase.b); equal != (len(testcase.diffs) == 0) {
+				t.Fatalf("Equal returned %t for %d differences", equal, len(testcase.diffs))
+			}
+		})
+	}
+}
@@ -4,5 +4,7 @@ Package tc allows to show and alter traffic control settings in the Linux kernel
 Traffic control is composed of the elements shaping, scheduling, policing and dropping. This processing is controlled by qdiscs, classes and filters.
 
 For a more detailed introduction of these elements, please have a look at http://man7.org/linux/man-pages/man8/tc.8.html.
+
+Classifiers hold their actions in a field Actions of type *[]*Action. A nil pointer and a pointer to an empty slice both mean, that the classifier has no actions, and the attribute is only sent, if the slice holds an action. Classifiers without actions are decoded with a nil pointer.
 */
 package tc
@@ -43,11 +43,7 @@
 
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 // EmatchLayer defines the layer the match will be applied upon.
 type EmatchLayer uint8
@@ -105,38 +101,38 @@ const (
 // Ematch contains attributes of the ematch discipline
 // https://man7.org/linux/man-pages/man8/tc-ematch.8.html
 type Ematch struct {
-	Hdr     *EmatchTreeHdr
-	Matches *[]EmatchMatch
+	Hdr     *EmatchTreeHdr `json:"hdr,omitempty"`
+	Matches *[]EmatchMatch `json:"matches,omitempty"`
 }
 
 // EmatchTreeHdr from tcf_ematch_tree_hdr in include/uapi/linux/pkt_cls.h
 type EmatchTreeHdr struct {
-	NMatches uint16
-	ProgID   uint16
+	NMatches uint16 `json:"n_matches,omitempty"`
+	ProgID   uint16 `json:"prog_id,omitempty"`
 }
 
 // EmatchHdr from tcf_ematch_hdr in include/uapi/linux/pkt_cls.h
 type EmatchHdr struct {
-	MatchID uint16
-	Kind    EmatchKind
-	Flags   uint16
-	Pad     uint16
+	MatchID uint16     `json:"match_id,omitempty"`
+	Kind    EmatchKind `json:"kind,omitempty"`
+	Flags   uint16     `json:"flags,omitempty"`
+	Pad     uint16     `json:"pad,omitempty"`
 }
 
 // EmatchMatch contains attributes of the ematch discipline
 type EmatchMatch struct {
-	Hdr            EmatchHdr
-	U32Match       *U32Match
-	CmpMatch       *CmpMatch
-	IPSetMatch     *IPSetMatch
-	IptMatch       *IptMatch
-	ContainerMatch *ContainerMatch
-	NByteMatch     *NByteMatch
+	Hdr            EmatchHdr       `json:"hdr,omitempty"`
+	U32Match       *U32Match       `json:"u32_match,omitempty"`
+	CmpMatch       *CmpMatch       `json:"cmp_match,omitempty"`
+	IPSetMatch     *IPSetMatch     `json:"ipset_match,omitempty"`
+	IptMatch       *IptMatch       `json:"ipt_match,omitempty"`
+	ContainerMatch *ContainerMatch `json:"container_match,omitempty"`
+	NByteMatch     *NByteMatch     `json:"n_byte_match,omitempty"`
 }
 
 // unmarshalEmatch parses the Ematch-encoded data and stores the result in the value pointed to by info.
 func unmarshalEmatch(data []byte, info *Ematch) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -154,7 +150,7 @@ func unmarshalEmatch(data []byte, info *Ematch) error {
 			multiError = concatError(multiError, err)
 			info.Matches = &list
 		default:
-			return fmt.Errorf("UnmarshalEmatch()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("ematch", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -177,7 +173,7 @@ func marshalEmatch(info *Ematch) ([]byte, error) {
 	if info.Matches != nil {
 		data, err := marshalEmatchTreeList(info.Matches)
 		multiError = concatError(multiError, err)
-		options = append(options, tcOption{Interpretation: vtBytes, Type: tcaEmatchTreeList | nlaFNnested, Data: data})
+		options = append(options, tcOption{Interpretation: vtNested, Type: tcaEmatchTreeList, Data: data})
 	}
 	if multiError != nil {
 		return []byte{}, multiError
@@ -186,46 +182,46 @@ func marshalEmatch(info *Ematch) ([]byte, error) {
 }
 
 func unmarshalEmatchTreeList(data []byte, info *[]EmatchMatch) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
 	var multiError error
 	for ad.Next() {
 		match := EmatchMatch{}
-		tmp := ad.Bytes()
-		if err := unmarshalStruct(tmp[:8], &match.Hdr); err != nil {
+		tmp, err := unmarshalStructAttrs(ad.Bytes(), &match.Hdr)
+		if err != nil {
 			return err
 		}
 		switch match.Hdr.Kind {
 		case EmatchU32:
 			expr := &U32Match{}
-			err := unmarshalU32Match(tmp[8:], expr)
+			err := unmarshalU32Match(tmp, expr)
 			multiError = concatError(multiError, err)
 			match.U32Match = expr
 		case EmatchCmp:
 			expr := &CmpMatch{}
-			err := unmarshalCmpMatch(tmp[8:], expr)
+			err := unmarshalCmpMatch(tmp, expr)
 			multiError = concatError(multiError, err)
 			match.CmpMatch = expr
 		case EmatchIPSet:
 			expr := &IPSetMatch{}
-			err := unmarshalIPSetMatch(tmp[8:], expr)
+			err := unmarshalIPSetMatch(tmp, expr)
 			multiError = concatError(multiError, err)
 			match.IPSetMatch = expr
 		case EmatchIPT:
 			expr := &IptMatch{}
-			err := unmarshalIptMatch(tmp[8:], expr)
+			err := unmarshalIptMatch(tmp, expr)
 			multiError = concatError(multiError, err)
 			match.IptMatch = expr
 		case EmatchContainer:
 			expr := &ContainerMatch{}
-			err := unmarshalContainerMatch(tmp[8:], expr)
+			err := unmarshalContainerMatch(tmp, expr)
 			multiError = concatError(multiError, err)
 			match.ContainerMatch = expr
 		case EmatchNByte:
 			expr := &NByteMatch{}
-			err := unmarshalNByteMatch(tmp[8:], expr)
+			err := unmarshalNByteMatch(tmp, expr)
 			multiError = concatError(multiError, err)
 			match.NByteMatch = expr
 		default:
@@ -262,7 +258,7 @@ func marshalEmatchTreeList(info *[]EmatchMatch) ([]byte, error) {
 			return []byte{}, fmt.Errorf("marshalEmatchTreeList() kind %d is not yet implemented", m.Hdr.Kind)
 		}
 		if err != nil {
-			return []byte{}, fmt.Errorf("marshalEmatchTreeList(): %v", err)
+			return []byte{}, fmt.Errorf("marshalEmatchTreeList(): %w", err)
 		}
 		payload = append(payload, expr...)
 		options = append(options, tcOption{Interpretation: vtBytes, Type: uint16(i + 1), Data: payload})
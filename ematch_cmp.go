@@ -1,5 +1,7 @@
 package tc
 
+import "io"
+
 // CmpMatchAlign defines byte alignments.
 type CmpMatchAlign uint8
 
@@ -20,52 +22,56 @@ const (
 
 // CmpMatch contains attributes of the cmp match discipline
 type CmpMatch struct {
-	Val   uint32
-	Mask  uint32
-	Off   uint16
-	Align CmpMatchAlign
-	Flags CmpMatchFlag
-	Layer EmatchLayer
-	Opnd  EmatchOpnd
+	Val   uint32        `json:"val,omitempty"`
+	Mask  uint32        `json:"mask,omitempty"`
+	Off   uint16        `json:"off,omitempty"`
+	Align CmpMatchAlign `json:"align,omitempty"`
+	Flags CmpMatchFlag  `json:"flags,omitempty"`
+	Layer EmatchLayer   `json:"layer,omitempty"`
+	Opnd  EmatchOpnd    `json:"opnd,omitempty"`
 }
 
-type cmpMatch struct {
-	Val  uint32
-	Mask uint32
-	Off  uint16
-	Opts uint16
-}
+// cmpMatchLen is the size of struct tcf_em_cmp.
+const cmpMatchLen = 12
 
 func unmarshalCmpMatch(data []byte, info *CmpMatch) error {
-	tmp := cmpMatch{}
-	if err := unmarshalStruct(data, &tmp); err != nil {
-		return err
-	}
-	info.Val = tmp.Val
-	info.Mask = tmp.Mask
-	info.Off = tmp.Off
-	info.Align = CmpMatchAlign((tmp.Opts) & 0xf)
-	info.Flags = CmpMatchFlag((tmp.Opts >> 4) & 0xf)
-	info.Layer = EmatchLayer((tmp.Opts >> 8) & 0xf)
-	info.Opnd = EmatchOpnd((tmp.Opts >> 12) & 0xf)
-	return nil
+	return decodeCmpMatch(data, info, nativeLayout)
 }
 
 func marshalCmpMatch(info *CmpMatch) ([]byte, error) {
 	if info == nil {
 		return []byte{}, ErrNoArg
 	}
-	var opts uint16
-	opts |= uint16(info.Align & 0xf)
-	opts |= uint16(info.Flags&0xf) << 4
-	opts |= uint16(info.Layer&0xf) << 8
-	opts |= uint16(info.Opnd&0xf) << 12
+	return encodeCmpMatch(info, nativeLayout), nil
+}
 
-	tmp := cmpMatch{
-		Val:  info.Val,
-		Mask: info.Mask,
-		Off:  info.Off,
-		Opts: opts,
+// decodeCmpMatch decodes struct tcf_em_cmp in the layout l.
+func decodeCmpMatch(data []byte, info *CmpMatch, l cLayout) error {
+	if len(data) < cmpMatchLen {
+		if len(data) == 0 {
+			return io.EOF
+		}
+		return io.ErrUnexpectedEOF
 	}
-	return marshalStruct(&tmp)
+	info.Val = l.order.Uint32(data[0:])
+	info.Mask = l.order.Uint32(data[4:])
+	info.Off = l.order.Uint16(data[8:])
+	align, flags := l.splitNibbles(data[10])
+	layer, opnd := l.splitNibbles(data[11])
+	info.Align = CmpMatchAlign(align)
+	info.Flags = CmpMatchFlag(flags)
+	info.Layer = EmatchLayer(layer)
+	info.Opnd = EmatchOpnd(opnd)
+	return nil
+}
+
+// encodeCmpMatch returns struct tcf_em_cmp in the layout l.
+func encodeCmpMatch(info *CmpMatch, l cLayout) []byte {
+	data := make([]byte, cmpMatchLen)
+	l.order.PutUint32(data[0:], info.Val)
+	l.order.PutUint32(data[4:], info.Mask)
+	l.order.PutUint16(data[8:], info.Off)
+	data[10] = l.nibbles(uint8(info.Align), uint8(info.Flags))
+	data[11] = l.nibbles(uint8(info.Layer), uint8(info.Opnd))
+	return data
 }
@@ -42,3 +42,34 @@ func TestCmpMatch(t *testing.T) {
 		}
 	})
 }
+
+func TestCmpMatchLayout(t *testing.T) {
+	in := CmpMatch{Val: 0x14, Mask: 0xff00, Off: 3, Align: CmpMatchU16, Flags: CmpMatchTrans,
+		Layer: EmatchLayer(2), Opnd: EmatchOpnd(3)}
+	le := []byte{0x14, 0x00, 0x00, 0x00, 0x00, 0xff, 0x00, 0x00, 0x03, 0x00, 0x12, 0x32}
+	tests := map[string][]byte{
+		"amd64":  le,
+		"386":    le,
+		"arm":    le,
+		"mips64": {0x00, 0x00, 0x00, 0x14, 0x00, 0x00, 0xff, 0x00, 0x00, 0x03, 0x21, 0x23},
+	}
+	for arch, want := range tests {
+		t.Run(arch, func(t *testing.T) {
+			l := testLayouts[arch]
+			data := encodeCmpMatch(&in, l)
+			if diff := cmp.Diff(want, data); diff != "" {
+				t.Fatalf("layout missmatch (-want +got):\n%s", diff)
+			}
+			var out CmpMatch
+			if err := decodeCmpMatch(data, &out, l); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(in, out); diff != "" {
+				t.Fatalf("CmpMatch missmatch (-want +got):\n%s", diff)
+			}
+			if err := decodeCmpMatch(data[:11], &out, l); !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Fatalf("expected io.ErrUnexpectedEOF but got %v", err)
+			}
+		})
+	}
+}
@@ -2,7 +2,7 @@ package tc
 
 // ContainerMatch contains attributes of the container match discipline
 type ContainerMatch struct {
-	Pos uint32
+	Pos uint32 `json:"pos,omitempty"`
 }
 
 func unmarshalContainerMatch(data []byte, info *ContainerMatch) error {
@@ -11,8 +11,8 @@ const (
 
 // IPSetMatch contains attributes of the ipset match discipline
 type IPSetMatch struct {
-	IPSetID uint16
-	Dir     []IPSetDir
+	IPSetID uint16     `json:"ipset_id,omitempty"`
+	Dir     []IPSetDir `json:"dir,omitempty"`
 }
 
 type ipsetMatch struct {
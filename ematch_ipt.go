@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaEmIptUnspec = iota
@@ -17,15 +13,15 @@ const (
 
 // IptMatch contains attributes of the ipt match discipline
 type IptMatch struct {
-	Hook      *uint32
-	MatchName *string
-	Revision  *uint8
-	NFProto   *uint8
-	MatchData *[]byte
+	Hook      *uint32 `json:"hook,omitempty"`
+	MatchName *string `json:"match_name,omitempty"`
+	Revision  *uint8  `json:"revision,omitempty"`
+	NFProto   *uint8  `json:"nf_proto,omitempty"`
+	MatchData *[]byte `json:"match_data,omitempty"`
 }
 
 func unmarshalIptMatch(data []byte, info *IptMatch) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -43,7 +39,7 @@ func unmarshalIptMatch(data []byte, info *IptMatch) error {
 		case tcaEmIptMatchData:
 			info.MatchData = bytesPtr(ad.Bytes())
 		default:
-			return fmt.Errorf("unmarshalIptMatch()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("ematch ipt", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -1,57 +1,75 @@
 package tc
 
-import (
-	"encoding/binary"
-	"fmt"
-)
+import "fmt"
 
 // NByteMatch contains attributes of the Nbyte match discipline
 type NByteMatch struct {
-	Offset uint16
-	Layer  uint8
-	Needle []byte
+	Offset uint16 `json:"offset,omitempty"`
+	Layer  uint8  `json:"layer,omitempty"`
+	Needle []byte `json:"needle,omitempty"`
 }
 
-type tcfEmNByte struct {
-	off   uint16
-	len   uint16
-	layer uint8
-}
+// nbyteMatchLen is the size of struct tcf_em_nbyte, that precedes the needle.
+const nbyteMatchLen = 4
+
+// nbyteMaxLen is the maximum length of a needle, as its length is a 12 bit
+// field.
+const nbyteMaxLen = 0xfff
 
 func unmarshalNByteMatch(data []byte, info *NByteMatch) error {
-	if len(data) < 8 {
+	return decodeNByteMatch(data, info, nativeLayout)
+}
+
+func marshalNByteMatch(info *NByteMatch) ([]byte, error) {
+	if info == nil {
+		return []byte{}, fmt.Errorf("marshalNByteMatch: %w", ErrNoArg)
+	}
+	return encodeNByteMatch(info, nativeLayout)
+}
+
+// decodeNByteMatch decodes struct tcf_em_nbyte and the needle, that follows
+// it, in the layout l. The fields len:12 and layer:4 share the second
+// 16 bits of the struct.
+func decodeNByteMatch(data []byte, info *NByteMatch, l cLayout) error {
+	if len(data) < nbyteMatchLen {
 		return fmt.Errorf("unmarshalNByteMatch: incomplete data: %w",
 			ErrInvalidArg)
 	}
 
-	// We can not unmarshal elements of a non-public struct.
-	// So we extract the elements by hand.
-	info.Offset = binary.LittleEndian.Uint16(data[:2])
-	needleLen := binary.LittleEndian.Uint16(data[2:4])
-	info.Layer = uint8(data[4])
-	if len(data) < (8 + int(needleLen)) {
+	info.Offset = l.order.Uint16(data[:2])
+	var needleLen uint16
+	if bits := l.order.Uint16(data[2:4]); l.bigEndian() {
+		needleLen, info.Layer = bits>>4, uint8(bits&0xf)
+	} else {
+		needleLen, info.Layer = bits&nbyteMaxLen, uint8(bits>>12)
+	}
+	if len(data) < (nbyteMatchLen + int(needleLen)) {
 		return fmt.Errorf("unmarshalNByteMatch: invalid needle: %w",
 			ErrInvalidArg)
 	}
-	info.Needle = data[8 : 8+needleLen]
+	info.Needle = data[nbyteMatchLen : nbyteMatchLen+needleLen]
 
 	return nil
 }
 
-func marshalNByteMatch(info *NByteMatch) ([]byte, error) {
-	if info == nil {
-		return []byte{}, fmt.Errorf("marshalNByteMatch: %w", ErrNoArg)
+// encodeNByteMatch returns struct tcf_em_nbyte followed by the needle in
+// the layout l.
+func encodeNByteMatch(info *NByteMatch, l cLayout) ([]byte, error) {
+	if len(info.Needle) > nbyteMaxLen {
+		return []byte{}, fmt.Errorf("marshalNByteMatch: needle is longer than %d bytes: %w",
+			nbyteMaxLen, ErrInvalidArg)
 	}
-	nbyte := tcfEmNByte{
-		off:   info.Offset,
-		len:   uint16(len(info.Needle)),
-		layer: info.Layer,
+	if info.Layer > 0xf {
+		return []byte{}, fmt.Errorf("marshalNByteMatch: layer %d exceeds 4 bits: %w",
+			info.Layer, ErrInvalidArg)
 	}
-
-	tmp, err := marshalAndAlignStruct(nbyte)
-	if err != nil {
-		return []byte{}, err
+	needleLen := uint16(len(info.Needle))
+	bits := needleLen | uint16(info.Layer)<<12
+	if l.bigEndian() {
+		bits = needleLen<<4 | uint16(info.Layer)
 	}
-	tmp = append(tmp, info.Needle...)
-	return tmp, nil
+	data := make([]byte, nbyteMatchLen, nbyteMatchLen+len(info.Needle))
+	l.order.PutUint16(data[0:], info.Offset)
+	l.order.PutUint16(data[2:], bits)
+	return append(data, info.Needle...), nil
 }
@@ -77,3 +77,44 @@ func TestUnmarshalNByteMatch(t *testing.T) {
 		})
 	}
 }
+
+func TestNByteMatchLayout(t *testing.T) {
+	in := NByteMatch{Offset: 0x2a, Layer: 0xd, Needle: []byte("ab")}
+	le := []byte{0x2a, 0x00, 0x02, 0xd0, 0x61, 0x62}
+	tests := map[string][]byte{
+		"amd64":  le,
+		"386":    le,
+		"arm":    le,
+		"mips64": {0x00, 0x2a, 0x00, 0x2d, 0x61, 0x62},
+	}
+	for arch, want := range tests {
+		t.Run(arch, func(t *testing.T) {
+			l := testLayouts[arch]
+			data, err := encodeNByteMatch(&in, l)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(want, data); diff != "" {
+				t.Fatalf("layout missmatch (-want +got):\n%s", diff)
+			}
+			var out NByteMatch
+			if err := decodeNByteMatch(data, &out, l); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(in, out); diff != "" {
+				t.Fatalf("NByteMatch missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		for name, info := range map[string]NByteMatch{
+			"needle": {Needle: make([]byte, nbyteMaxLen+1)},
+			"layer":  {Layer: 0x10},
+		} {
+			if _, err := marshalNByteMatch(&info); !errors.Is(err, ErrInvalidArg) {
+				t.Fatalf("%s: expected ErrInvalidArg but got %v", name, err)
+			}
+		}
+	})
+}
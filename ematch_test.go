@@ -2,9 +2,11 @@ package tc
 
 import (
 	"errors"
+	"io"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/netlink"
 )
 
 func TestEmatch(t *testing.T) {
@@ -188,6 +190,18 @@ func TestEmatch(t *testing.T) {
 			t.Fatalf("expected error but got nil")
 		}
 	})
+	t.Run("unmarshal(truncated header)", func(t *testing.T) {
+		data, err := netlink.MarshalAttributes([]netlink.Attribute{
+			{Type: 1, Data: []byte{0x0, 0x0, 0x3, 0x0}},
+		})
+		if err != nil {
+			t.Fatalf("could not marshal attributes: %v", err)
+		}
+		var matches []EmatchMatch
+		if err := unmarshalEmatchTreeList(data, &matches); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("expected io.ErrUnexpectedEOF but got %v", err)
+		}
+	})
 	t.Run("unmarshal(0x0)", func(t *testing.T) {
 		val := Ematch{}
 		if err := unmarshalEmatch([]byte{0x00}, &val); err == nil {
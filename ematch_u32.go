@@ -6,10 +6,10 @@ import (
 
 // U32Match contains attributes of the u32 match discipline
 type U32Match struct {
-	Mask    uint32 // big endian
-	Value   uint32 // big endian
-	Off     int32
-	OffMask uint32
+	Mask    uint32 `json:"mask,omitempty"`  // big endian
+	Value   uint32 `json:"value,omitempty"` // big endian
+	Off     int32  `json:"off,omitempty"`
+	OffMask uint32 `json:"off_mask,omitempty"`
 }
 
 func unmarshalU32Match(data []byte, info *U32Match) error {
@@ -1,7 +1,55 @@
 package tc
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
+// MultiError holds several errors, that occurred while an object was
+// encoded or decoded. Its message lists the messages of the errors on
+// separate lines. It matches every error, that one of its errors matches.
+type MultiError struct {
+	Errs []error
+}
+
+func (e *MultiError) Error() string {
+	msgs := make([]string, 0, len(e.Errs))
+	for _, err := range e.Errs {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the errors for errors.Is and errors.As of Go 1.20 and later.
+func (e *MultiError) Unwrap() []error {
+	return e.Errs
+}
+
+// Is reports whether one of the errors matches target. Versions of Go before
+// 1.20 ignore Unwrap() []error.
+func (e *MultiError) Is(target error) bool {
+	for _, err := range e.Errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors, that matches target, like Is.
+func (e *MultiError) As(target interface{}) bool {
+	for _, err := range e.Errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// concatError returns existing together with new. If both are not nil, the
+// result is a *MultiError. A new one is returned each time, so that existing
+// is never modified.
 func concatError(existing, new error) error {
 	if new == nil {
 		return existing
@@ -9,5 +57,60 @@ func concatError(existing, new error) error {
 	if existing == nil {
 		return new
 	}
-	return fmt.Errorf("%v\n%v", existing, new)
+	var errs []error
+	if multi, ok := existing.(*MultiError); ok {
+		errs = append(errs, multi.Errs...)
+	} else {
+		errs = append(errs, existing)
+	}
+	if multi, ok := new.(*MultiError); ok {
+		errs = append(errs, multi.Errs...)
+	} else {
+		errs = append(errs, new)
+	}
+	return &MultiError{Errs: errs}
+}
+
+// unknownAttribute returns an error for the attribute typ with the payload
+// data, that is not known in the context of kind.
+func unknownAttribute(kind string, typ uint16, data []byte) error {
+	return fmt.Errorf("%s: attribute %d with %d bytes: %w", kind, typ, len(data), ErrUnknownAttribute)
+}
+
+// optionsError reports options of a kind, that has no options. Next to
+// ErrUnknownAttribute of err, it matches ErrInvalidArg, which was returned for
+// such options before.
+type optionsError struct {
+	err error
+}
+
+func (e *optionsError) Error() string {
+	return e.err.Error()
+}
+
+func (e *optionsError) Is(target error) bool {
+	return target == ErrInvalidArg
+}
+
+func (e *optionsError) Unwrap() error {
+	return e.err
+}
+
+// dumpError reports, that a dump was ended by the error err of the kernel
+// after parts of it were received. It matches both ErrDumpInterrupted and
+// err.
+type dumpError struct {
+	err error
+}
+
+func (e *dumpError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrDumpInterrupted, e.err)
+}
+
+func (e *dumpError) Is(target error) bool {
+	return target == ErrDumpInterrupted
+}
+
+func (e *dumpError) Unwrap() error {
+	return e.err
 }
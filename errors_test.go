@@ -3,9 +3,19 @@ package tc
 import (
 	"errors"
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nlenc"
 )
 
 func TestConcatError(t *testing.T) {
@@ -29,9 +39,193 @@ func TestConcatError(t *testing.T) {
 	})
 	t.Run("io.EOF + os.ErrPermission", func(t *testing.T) {
 		result := concatError(io.EOF, os.ErrPermission)
-		fmt.Println(result)
-		// Output:
-		// EOF
-		// permission denied
+		if !errors.Is(result, io.EOF) || !errors.Is(result, os.ErrPermission) {
+			t.Fatalf("expected io.EOF and os.ErrPermission but got %v", result)
+		}
+		if want := "EOF\npermission denied"; result.Error() != want {
+			t.Fatalf("expected %q but got %q", want, result.Error())
+		}
+	})
+	t.Run("flatten", func(t *testing.T) {
+		first := concatError(io.EOF, os.ErrPermission)
+		result := concatError(first, concatError(io.ErrUnexpectedEOF, ErrInvalidArg))
+		var multi *MultiError
+		if !errors.As(result, &multi) {
+			t.Fatalf("expected *MultiError but got %T", result)
+		}
+		want := []error{io.EOF, os.ErrPermission, io.ErrUnexpectedEOF, ErrInvalidArg}
+		if diff := cmp.Diff(want, multi.Errs, cmpopts.EquateErrors()); diff != "" {
+			t.Fatalf("errors missmatch (-want +got):\n%s", diff)
+		}
+		// existing is not modified.
+		if len(first.(*MultiError).Errs) != 2 {
+			t.Fatalf("expected 2 errors in %v", first)
+		}
 	})
+	t.Run("As", func(t *testing.T) {
+		sizeErr := &MessageSizeError{Size: 2, Limit: 1}
+		result := concatError(io.EOF, fmt.Errorf("wrapped: %w", sizeErr))
+		var got *MessageSizeError
+		if !errors.As(result, &got) || got != sizeErr {
+			t.Fatalf("expected %v but got %v", sizeErr, got)
+		}
+		if errors.Is(result, ErrNoArg) {
+			t.Fatalf("expected %v not to match ErrNoArg", result)
+		}
+	})
+}
+
+// TestDecodeErrors makes sure, that all errors of a decode can be matched.
+func TestDecodeErrors(t *testing.T) {
+	data, err := marshalAttributes([]tcOption{
+		{Interpretation: vtBytes, Type: tcaHtbParms, Data: []byte{0x1, 0x2}},
+		{Interpretation: vtUint32, Type: 0x55, Data: uint32(1)},
+	})
+	if err != nil {
+		t.Fatalf("could not marshal attributes: %v", err)
+	}
+	err = unmarshalHtb(data, &Htb{})
+	for _, target := range []error{io.ErrUnexpectedEOF, ErrUnknownAttribute} {
+		if !errors.Is(err, target) {
+			t.Fatalf("expected %v to match %v", err, target)
+		}
+	}
+	if want := "unexpected EOF\nhtb: attribute 85 with 4 bytes: unknown attribute"; err.Error() != want {
+		t.Fatalf("expected %q but got %q", want, err.Error())
+	}
+}
+
+// TestConcatErrorResult makes sure the result of concatError is never
+// discarded, as it returns a new error instead of modifying existing.
+func TestConcatErrorResult(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			t.Fatalf("could not parse %s: %v", file, err)
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			stmt, ok := n.(*ast.ExprStmt)
+			if !ok {
+				return true
+			}
+			call, ok := stmt.X.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			if ident, ok := call.Fun.(*ast.Ident); ok && ident.Name == "concatError" {
+				t.Errorf("%s: result of concatError is discarded", fset.Position(call.Pos()))
+			}
+			return true
+		})
+	}
+}
+
+func TestUnknownAttribute(t *testing.T) {
+	unknown, err := netlink.MarshalAttributes([]netlink.Attribute{{Type: 0x55, Data: []byte{0x1, 0x2, 0x3, 0x4}}})
+	if err != nil {
+		t.Fatalf("could not marshal attributes: %v", err)
+	}
+	netemQopt, err := marshalStruct(&NetemQopt{})
+	if err != nil {
+		t.Fatalf("could not marshal qopt: %v", err)
+	}
+
+	tests := map[string]struct {
+		unmarshal func() error
+		msg       string
+	}{
+		"tcmsg": {
+			unmarshal: func() error { return extractTcmsgAttributes(actionQdisc, unknown, &Attribute{}) },
+			msg:       "tcmsg: attribute 85 with 4 bytes: unknown attribute",
+		},
+		"u32": {
+			unmarshal: func() error { return unmarshalU32(unknown, &U32{}) },
+			msg:       "u32: attribute 85 with 4 bytes: unknown attribute",
+		},
+		"netem": {
+			unmarshal: func() error { return unmarshalNetem(append(netemQopt, unknown...), &Netem{}) },
+			msg:       "netem: attribute 85 with 4 bytes: unknown attribute",
+		},
+		"action": {
+			unmarshal: func() error { return unmarshalMirred(unknown, &Mirred{}) },
+			msg:       "action mirred: attribute 85 with 4 bytes: unknown attribute",
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := testcase.unmarshal()
+			if !errors.Is(err, ErrUnknownAttribute) {
+				t.Fatalf("expected ErrUnknownAttribute but got %v", err)
+			}
+			if err.Error() != testcase.msg {
+				t.Fatalf("expected %q but got %q", testcase.msg, err.Error())
+			}
+		})
+	}
+}
+
+func TestDecodeAfterUnknownAttribute(t *testing.T) {
+	unknown := netlink.Attribute{Type: 0x55, Data: []byte{0x1, 0x2, 0x3, 0x4}}
+	marshal := func(t *testing.T, attrs ...netlink.Attribute) []byte {
+		t.Helper()
+		data, err := netlink.MarshalAttributes(attrs)
+		if err != nil {
+			t.Fatalf("could not marshal attributes: %v", err)
+		}
+		return data
+	}
+
+	tests := map[string]struct {
+		unmarshal func(t *testing.T) (interface{}, error)
+		want      interface{}
+	}{
+		"route4": {
+			unmarshal: func(t *testing.T) (interface{}, error) {
+				info := &Route4{}
+				err := unmarshalRoute4(marshal(t, unknown, netlink.Attribute{Type: tcaRoute4ClassID, Data: nlenc.Uint32Bytes(42)}), info)
+				return info, err
+			},
+			want: &Route4{ClassID: uint32Ptr(42)},
+		},
+		"police": {
+			unmarshal: func(t *testing.T) (interface{}, error) {
+				info := &Police{}
+				err := unmarshalPolice(marshal(t, unknown, netlink.Attribute{Type: tcaPoliceAvRate, Data: nlenc.Uint32Bytes(42)}), info)
+				return info, err
+			},
+			want: &Police{AvRate: uint32Ptr(42)},
+		},
+		"tcmsg": {
+			unmarshal: func(t *testing.T) (interface{}, error) {
+				info := &Attribute{}
+				options := marshal(t, unknown, netlink.Attribute{Type: tcaPieTarget, Data: nlenc.Uint32Bytes(42)})
+				err := extractTcmsgAttributes(actionQdisc, marshal(t, unknown,
+					netlink.Attribute{Type: tcaKind, Data: []byte("pie\x00")},
+					netlink.Attribute{Type: tcaOptions, Data: options}), info)
+				return info, err
+			},
+			want: &Attribute{Kind: "pie", Pie: &Pie{Target: uint32Ptr(42)}},
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := testcase.unmarshal(t)
+			if !errors.Is(err, ErrUnknownAttribute) {
+				t.Fatalf("expected ErrUnknownAttribute but got %v", err)
+			}
+			if diff := cmp.Diff(testcase.want, got); diff != "" {
+				t.Fatalf("decoding mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
 }
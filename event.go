@@ -0,0 +1,100 @@
+package tc
+
+import (
+	"context"
+	"time"
+
+	"github.com/florianl/go-tc/internal/unix"
+)
+
+// EventType classifies the messages, that are received while monitoring.
+type EventType int
+
+// Various event types.
+const (
+	EventUnknown EventType = iota
+	EventQdiscNew
+	EventQdiscDel
+	EventClassNew
+	EventClassDel
+	EventFilterNew
+	EventFilterDel
+	EventChainNew
+	EventChainDel
+)
+
+var eventTypeNames = map[EventType]string{
+	EventQdiscNew:  "qdisc new",
+	EventQdiscDel:  "qdisc del",
+	EventClassNew:  "class new",
+	EventClassDel:  "class del",
+	EventFilterNew: "filter new",
+	EventFilterDel: "filter del",
+	EventChainNew:  "chain new",
+	EventChainDel:  "chain del",
+}
+
+func (t EventType) String() string {
+	if name, ok := eventTypeNames[t]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// eventTypes maps the message types of RTNLGRP_TC to their event type.
+var eventTypes = map[uint16]EventType{
+	unix.RTM_NEWQDISC:   EventQdiscNew,
+	unix.RTM_DELQDISC:   EventQdiscDel,
+	unix.RTM_NEWTCLASS:  EventClassNew,
+	unix.RTM_DELTCLASS:  EventClassDel,
+	unix.RTM_NEWTFILTER: EventFilterNew,
+	unix.RTM_DELTFILTER: EventFilterDel,
+	unix.RTM_NEWCHAIN:   EventChainNew,
+	unix.RTM_DELCHAIN:   EventChainDel,
+}
+
+// Event is a classified message, that was received while monitoring.
+type Event struct {
+	Type   EventType
+	Object Object
+	// OnBlock is set for filters and chains of a shared block. Ifindex of
+	// Object is MagicBlock then and does not refer to a network interface.
+	OnBlock bool
+	// Block is the index of the shared block with OnBlock.
+	Block uint32
+	// Chain is the index of the chain of filter and chain events.
+	Chain uint32
+	// Template is the filter template of a chain, like the masks of a
+	// flower filter. It is nil for chains without a template.
+	Template *Attribute
+}
+
+// NewEvent classifies the message of type action, that a HookFunc received
+// as obj.
+func NewEvent(action uint16, obj Object) Event {
+	e := Event{Type: eventTypes[action], Object: obj}
+	switch e.Type {
+	case EventFilterNew, EventFilterDel, EventChainNew, EventChainDel:
+		e.Block, e.OnBlock = obj.Block()
+		e.Chain = uint32Value(obj.Chain)
+	}
+	// The kernel reports the template of a chain with its kind and options.
+	if (e.Type == EventChainNew || e.Type == EventChainDel) && obj.Kind != "" {
+		template := obj.Attribute
+		e.Template = &template
+	}
+	return e
+}
+
+// EventFunc is a function, which is called for each event of MonitorEvents.
+// Return something different than 0, to stop receiving events.
+type EventFunc func(e Event) int
+
+// MonitorEvents handles NETLINK_ROUTE messages like MonitorWithErrorFunc and
+// calls fn with the classified event of each message.
+func (tc *Tc) MonitorEvents(ctx context.Context, deadline time.Duration,
+	fn EventFunc, errfn ErrorFunc) error {
+	return tc.monitor(ctx, deadline, func(action uint16, m Object) int {
+		return fn(NewEvent(action, m))
+	}, errfn)
+}
@@ -0,0 +1,126 @@
+package tc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/netlink"
+)
+
+func TestMonitorEvents(t *testing.T) {
+	template := &Flower{KeyEthType: uint16Ptr(0x0800), KeyIPProto: uint8Ptr(6)}
+	templateChain := Object{
+		Msg:       Msg{Family: unix.AF_UNSPEC, Ifindex: MagicBlock, Parent: 22},
+		Attribute: Attribute{Kind: "flower", Chain: uint32Ptr(3), Flower: template},
+	}
+	deletedChain := Object{
+		Msg:       Msg{Family: unix.AF_UNSPEC, Ifindex: 2, Parent: core.BuildHandle(0xFFFF, 0x0)},
+		Attribute: Attribute{Chain: uint32Ptr(4)},
+	}
+	blockFilter := Object{
+		Msg: Msg{Family: unix.AF_UNSPEC, Ifindex: MagicBlock, Handle: 0x1, Parent: 22,
+			Info: core.BuildFilterInfo(1, core.EthPIP)},
+		Attribute: Attribute{Kind: "basic", Chain: uint32Ptr(3), Priority: 1, Protocol: core.EthPIP,
+			Basic: &Basic{ClassID: uint32Ptr(0x10001)}},
+	}
+	qdisc := Object{
+		Msg: Msg{Family: unix.AF_UNSPEC, Ifindex: 2, Handle: 0xFFFF0000, Parent: HandleIngress},
+		Attribute: Attribute{Kind: "ingress", IngressBlock: uint32Ptr(22),
+			Stats2: &Stats2{Basic: &GenBasic{Bytes: 1500, Packets: 1}, Queue: &GenQueue{QueueLen: 3, Backlog: 4500}}},
+	}
+
+	conn := &eventConn{}
+	for _, event := range []struct {
+		action int
+		obj    Object
+	}{
+		{unix.RTM_NEWCHAIN, templateChain},
+		{unix.RTM_DELCHAIN, deletedChain},
+		{unix.RTM_NEWTFILTER, blockFilter},
+		{unix.RTM_NEWQDISC, qdisc},
+	} {
+		var opts []tcOption
+		var err error
+		switch event.action {
+		case unix.RTM_NEWCHAIN, unix.RTM_DELCHAIN:
+			// linux/net/sched/cls_api.c:tc_chain_fill_node() reports the
+			// template of a chain with its kind and options.
+			opts = []tcOption{{Interpretation: vtUint32, Type: tcaChain, Data: uint32Value(event.obj.Chain)}}
+			if event.obj.Kind != "" {
+				var data []byte
+				data, err = marshalFlower(event.obj.Flower)
+				opts = append(opts, tcOption{Interpretation: vtString, Type: tcaKind, Data: event.obj.Kind},
+					tcOption{Interpretation: vtBytes, Type: tcaOptions, Data: data})
+			}
+		case unix.RTM_NEWTFILTER:
+			opts, err = validateFilterObject(event.action, &event.obj)
+		default:
+			// Notifications of qdiscs carry their statistics.
+			msg, err := MarshalReply(netlink.HeaderType(event.action), &event.obj)
+			if err != nil {
+				t.Fatalf("could not marshal %s: %v", event.obj, err)
+			}
+			conn.events = append(conn.events, msg)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("could not marshal %s: %v", event.obj, err)
+		}
+		msg, err := marshalMessage(event.action, 0, event.obj.Msg, opts)
+		if err != nil {
+			t.Fatalf("could not marshal %s: %v", event.obj, err)
+		}
+		conn.events = append(conn.events, msg)
+	}
+	tcSocket := &Tc{con: conn}
+	defer tcSocket.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	var got []Event
+	err := tcSocket.MonitorEvents(ctx, time.Millisecond, func(e Event) int {
+		got = append(got, e)
+		return 0
+	}, func(err error) int {
+		close(done)
+		return 1
+	})
+	if err != nil {
+		t.Fatalf("could not start monitor: %v", err)
+	}
+	<-done
+
+	want := []Event{
+		{Type: EventChainNew, Object: templateChain, OnBlock: true, Block: 22, Chain: 3,
+			Template: &Attribute{Kind: "flower", Chain: uint32Ptr(3), Flower: template}},
+		{Type: EventChainDel, Object: deletedChain, Chain: 4},
+		{Type: EventFilterNew, Object: blockFilter, OnBlock: true, Block: 22, Chain: 3},
+		{Type: EventQdiscNew, Object: qdisc},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("events missmatch (-want +got):\n%s", diff)
+	}
+	if packets, bytes, ok := got[3].Object.Backlog(); !ok || packets != 3 || bytes != 4500 {
+		t.Fatalf("expected a backlog of 3 packets and 4500 bytes but got %d, %d, %v", packets, bytes, ok)
+	}
+}
+
+func TestEventTypeString(t *testing.T) {
+	tests := map[EventType]string{
+		EventUnknown:   "unknown",
+		EventChainNew:  "chain new",
+		EventChainDel:  "chain del",
+		EventFilterDel: "filter del",
+		EventType(42):  "unknown",
+	}
+	for typ, want := range tests {
+		if got := typ.String(); got != want {
+			t.Fatalf("expected %q for %d but got %q", want, int(typ), got)
+		}
+	}
+}
@@ -123,7 +123,7 @@ func Example_eBPF() {
 			Family:  unix.AF_UNSPEC,
 			Ifindex: uint32(devID.Index),
 			Handle:  0,
-			Parent:  core.BuildHandle(tc.HandleRoot, tc.HandleMinIngress),
+			Parent:  tc.HandleClsactIngress,
 			Info:    0x300,
 		},
 		tc.Attribute{
@@ -0,0 +1,62 @@
+package tc_test
+
+import (
+	"fmt"
+
+	"github.com/florianl/go-tc"
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/tctest"
+)
+
+// This example shows how a desired configuration is applied with Plan and
+// Apply. The fake kernel of package tctest stands in for tc.Open.
+func ExamplePlan() {
+	tcSocket := tctest.NewFakeTc()
+	defer tcSocket.Close()
+
+	desired := []tc.Object{
+		{
+			Msg: tc.Msg{Ifindex: 2, Handle: core.BuildHandle(0x1, 0x0), Parent: tc.HandleRoot},
+			Attribute: tc.Attribute{Kind: "htb",
+				Htb: &tc.Htb{Init: &tc.HtbGlob{Version: 3, Defcls: 0x10}}},
+		},
+		{
+			Msg: tc.Msg{Ifindex: 2, Handle: core.BuildHandle(0x1, 0x10), Parent: core.BuildHandle(0x1, 0x0)},
+			Attribute: tc.Attribute{Kind: "htb",
+				Htb: &tc.Htb{Parms: &tc.HtbOpt{Rate: tc.RateSpec{Rate: 125000},
+					Ceil: tc.RateSpec{Rate: 125000}, Buffer: 0xFFFF, Cbuffer: 0xFFFF}}},
+		},
+	}
+
+	// The second plan is empty, as the configuration is already applied.
+	for i := 0; i < 2; i++ {
+		qdiscs, err := tcSocket.Qdisc().Get()
+		if err != nil {
+			fmt.Printf("could not get qdiscs: %v\n", err)
+			return
+		}
+		classes, err := tcSocket.Class().Get(&tc.Msg{Ifindex: 2})
+		if err != nil {
+			fmt.Printf("could not get classes: %v\n", err)
+			return
+		}
+		steps, err := tc.Plan(append(qdiscs, classes...), desired)
+		if err != nil {
+			fmt.Printf("could not plan: %v\n", err)
+			return
+		}
+		fmt.Printf("%d steps\n", len(steps))
+		for _, step := range steps {
+			fmt.Println(step)
+		}
+		if err := tc.Apply(tcSocket.Tc, steps); err != nil {
+			fmt.Printf("could not apply: %v\n", err)
+			return
+		}
+	}
+	// Output:
+	// 2 steps
+	// add qdisc htb 1: dev ifindex 2 parent root
+	// add class htb 1:10 dev ifindex 2 parent 1:
+	// 0 steps
+}
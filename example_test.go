@@ -82,7 +82,7 @@ func ExampleQdisc() {
 			Family:  unix.AF_UNSPEC,
 			Ifindex: uint32(devID.Index),
 			Handle:  core.BuildHandle(0xFFFF, 0x0000),
-			Parent:  0xFFFFFFF1,
+			Parent:  tc.HandleClsact,
 			Info:    0,
 		},
 		tc.Attribute{
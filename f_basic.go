@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaBasicUnspec = iota
@@ -18,16 +14,16 @@ const (
 
 // Basic contains attributes of the basic discipline
 type Basic struct {
-	ClassID *uint32
-	Police  *Police
-	Ematch  *Ematch
-	Actions *[]*Action
-	Pcnt    *uint64
+	ClassID *uint32    `json:"class_id,omitempty"`
+	Police  *Police    `json:"police,omitempty"`
+	Ematch  *Ematch    `json:"ematch,omitempty"`
+	Actions *[]*Action `json:"actions,omitempty"`
+	Pcnt    *uint64    `json:"pcnt,omitempty"`
 }
 
 // unmarshalBasic parses the Basic-encoded data and stores the result in the value pointed to by info.
 func unmarshalBasic(data []byte, info *Basic) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -56,7 +52,7 @@ func unmarshalBasic(data []byte, info *Basic) error {
 		case tcaBasicPad:
 			// padding does not contain data, we just skip it
 		default:
-			return fmt.Errorf("unmarshalBasic()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("basic", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -88,7 +84,7 @@ func marshalBasic(info *Basic) ([]byte, error) {
 		multiError = concatError(multiError, err)
 		options = append(options, tcOption{Interpretation: vtBytes, Type: tcaBasicPolice, Data: data})
 	}
-	if info.Actions != nil {
+	if hasActions(info.Actions) {
 		data, err := marshalActions(0, *info.Actions)
 		multiError = concatError(multiError, err)
 		options = append(options, tcOption{Interpretation: vtBytes, Type: tcaBasicAct, Data: data})
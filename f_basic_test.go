@@ -39,7 +39,7 @@ func TestBasic(t *testing.T) {
 						},
 					},
 				},
-				Police: &Police{AvRate: uint32Ptr(1337), Result: uint32Ptr(12)},
+				Police: &Police{AvRate: uint32Ptr(1337), Result: uint32Ptr(12), Conform: policyActionPtr(12)},
 			},
 		},
 	}
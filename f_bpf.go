@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaBpfUnspec = iota
@@ -23,17 +19,17 @@ const (
 
 // Bpf contains attributes of the bpf discipline
 type Bpf struct {
-	Action   *Action
-	Police   *Police
-	ClassID  *uint32
-	OpsLen   *uint16
-	Ops      *[]byte
-	FD       *uint32
-	Name     *string
-	Flags    *uint32
-	FlagsGen *uint32
-	Tag      *[]byte
-	ID       *uint32
+	Action   *Action `json:"action,omitempty"`
+	Police   *Police `json:"police,omitempty"`
+	ClassID  *uint32 `json:"class_id,omitempty"`
+	OpsLen   *uint16 `json:"ops_len,omitempty"`
+	Ops      *[]byte `json:"ops,omitempty"`
+	FD       *uint32 `json:"fd,omitempty"`
+	Name     *string `json:"name,omitempty"`
+	Flags    *uint32 `json:"flags,omitempty"`
+	FlagsGen *uint32 `json:"flags_gen,omitempty"`
+	Tag      *[]byte `json:"tag,omitempty"`
+	ID       *uint32 `json:"id,omitempty"`
 }
 
 // Flags defined by the kernel for the BPF filter
@@ -43,7 +39,7 @@ const (
 
 // unmarshalBpf parses the Bpf-encoded data and stores the result in the value pointed to by info.
 func unmarshalBpf(data []byte, info *Bpf) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -79,7 +75,7 @@ func unmarshalBpf(data []byte, info *Bpf) error {
 		case tcaBpfID:
 			info.ID = uint32Ptr(ad.Uint32())
 		default:
-			return fmt.Errorf("unmarshalBpf()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("bpf", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
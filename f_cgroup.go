@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaCgroupUnspec = iota
@@ -15,8 +11,8 @@ const (
 
 // Cgroup contains attributes of the cgroup discipline
 type Cgroup struct {
-	Action *Action
-	Ematch *Ematch
+	Action *Action `json:"action,omitempty"`
+	Ematch *Ematch `json:"ematch,omitempty"`
 }
 
 // marshalCgroup returns the binary encoding of Cgroup
@@ -49,7 +45,7 @@ func marshalCgroup(info *Cgroup) ([]byte, error) {
 
 // unmarshalCgroup parses the Cgroup-encoded data and stores the result in the value pointed to by info.
 func unmarshalCgroup(data []byte, info *Cgroup) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -67,7 +63,7 @@ func unmarshalCgroup(data []byte, info *Cgroup) error {
 			multiError = concatError(multiError, err)
 			info.Ematch = ematch
 		default:
-			return fmt.Errorf("unmarshalCgroup()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("cgroup", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
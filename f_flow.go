@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaFlowUnspec = iota
@@ -24,22 +20,22 @@ const (
 
 // Flow contains attributes of the flow discipline
 type Flow struct {
-	Keys      *uint32
-	Mode      *uint32
-	BaseClass *uint32
-	RShift    *uint32
-	Addend    *uint32
-	Mask      *uint32
-	XOR       *uint32
-	Divisor   *uint32
-	PerTurb   *uint32
-	Ematch    *Ematch
-	Actions   *[]*Action
+	Keys      *uint32    `json:"keys,omitempty"`
+	Mode      *uint32    `json:"mode,omitempty"`
+	BaseClass *uint32    `json:"base_class,omitempty"`
+	RShift    *uint32    `json:"r_shift,omitempty"`
+	Addend    *uint32    `json:"addend,omitempty"`
+	Mask      *uint32    `json:"mask,omitempty"`
+	XOR       *uint32    `json:"xor,omitempty"`
+	Divisor   *uint32    `json:"divisor,omitempty"`
+	PerTurb   *uint32    `json:"per_turb,omitempty"`
+	Ematch    *Ematch    `json:"ematch,omitempty"`
+	Actions   *[]*Action `json:"actions,omitempty"`
 }
 
 // unmarshalFlow parses the Flow-encoded data and stores the result in the value pointed to by info.
 func unmarshalFlow(data []byte, info *Flow) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -75,7 +71,7 @@ func unmarshalFlow(data []byte, info *Flow) error {
 			multiError = concatError(multiError, err)
 			info.Actions = actions
 		default:
-			return fmt.Errorf("unmarshalFlow()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("flow", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -123,7 +119,7 @@ func marshalFlow(info *Flow) ([]byte, error) {
 		multiError = concatError(multiError, err)
 		options = append(options, tcOption{Interpretation: vtBytes, Type: tcaFlowEMatches, Data: data})
 	}
-	if info.Actions != nil {
+	if hasActions(info.Actions) {
 		data, err := marshalActions(0, *info.Actions)
 		multiError = concatError(multiError, err)
 		options = append(options, tcOption{Interpretation: vtBytes, Type: tcaFlowAct, Data: data})
@@ -3,8 +3,6 @@ package tc
 import (
 	"fmt"
 	"net"
-
-	"github.com/mdlayher/netlink"
 )
 
 const (
@@ -124,113 +122,113 @@ const (
 
 // Flower contains attrobutes of the flower discipline
 type Flower struct {
-	ClassID              *uint32
-	Indev                *string
-	Actions              *[]*Action
-	KeyEthDst            *net.HardwareAddr
-	KeyEthDstMask        *net.HardwareAddr
-	KeyEthSrc            *net.HardwareAddr
-	KeyEthSrcMask        *net.HardwareAddr
-	KeyEthType           *uint16 /* be16 */
-	KeyIPProto           *uint8
-	KeyIPv4Src           *net.IP
-	KeyIPv4SrcMask       *net.IP
-	KeyIPv4Dst           *net.IP
-	KeyIPv4DstMask       *net.IP
-	KeyTCPSrc            *uint16 /* be16 */
-	KeyTCPDst            *uint16 /* be16 */
-	KeyUDPSrc            *uint16 /* be16 */
-	KeyUDPDst            *uint16 /* be16 */
-	Flags                *uint32
-	KeyVlanID            *uint16 /* be16 */
-	KeyVlanPrio          *uint8
-	KeyVlanEthType       *uint16 /* be16 */
-	KeyEncKeyID          *uint32 /* be32 */
-	KeyEncIPv4Src        *net.IP
-	KeyEncIPv4SrcMask    *net.IP
-	KeyEncIPv4Dst        *net.IP
-	KeyEncIPv4DstMask    *net.IP
-	KeyTCPSrcMask        *uint16 /* be16 */
-	KeyTCPDstMask        *uint16 /* be16 */
-	KeyUDPSrcMask        *uint16 /* be16 */
-	KeyUDPDstMask        *uint16 /* be16 */
-	KeySctpSrc           *uint16 /* be16 */
-	KeySctpDst           *uint16 /* be16 */
-	KeyEncUDPSrcPort     *uint16 /* be16 */
-	KeyEncUDPSrcPortMask *uint16 /* be16 */
-	KeyEncUDPDstPort     *uint16 /* be16 */
-	KeyEncUDPDstPortMask *uint16 /* be16 */
-	KeyFlags             *uint32 /* be32 */
-	KeyFlagsMask         *uint32 /* be32 */
-	KeyIcmpv4Code        *uint8
-	KeyIcmpv4CodeMask    *uint8
-	KeyIcmpv4Type        *uint8
-	KeyIcmpv4TypeMask    *uint8
-	KeyIcmpv6Code        *uint8
-	KeyIcmpv6CodeMask    *uint8
-	KeyArpSIP            *uint32 /* be32 */
-	KeyArpSIPMask        *uint32 /* be32 */
-	KeyArpTIP            *uint32 /* be32 */
-	KeyArpTIPMask        *uint32 /* be32 */
-	KeyArpOp             *uint8
-	KeyArpOpMask         *uint8
-	KeyMplsTTL           *uint8
-	KeyMplsBos           *uint8
-	KeyMplsTc            *uint8
-	KeyMplsLabel         *uint32
-	KeyTCPFlags          *uint16 /* be16 */
-	KeyTCPFlagsMask      *uint16 /* be16 */
-	KeyIPTOS             *uint8
-	KeyIPTOSMask         *uint8
-	KeyIPTTL             *uint8
-	KeyIPTTLMask         *uint8
-	KeyCVlanID           *uint16 /* be16 */
-	KeyCVlanPrio         *uint8
-	KeyCVlanEthType      *uint16 /* be16 */
-	KeyEncIPTOS          *uint8
-	KeyEncIPTOSMask      *uint8
-	KeyEncIPTTL          *uint8
-	KeyEncIPTTLMask      *uint8
-	InHwCount            *uint32
-	KeyPortSrcMin        *uint16 /* be16 */
-	KeyPortSrcMax        *uint16 /* be16 */
-	KeyPortDstMin        *uint16 /* be16 */
-	KeyPortDstMax        *uint16 /* be16 */
+	ClassID              *uint32           `json:"class_id,omitempty"`
+	Indev                *string           `json:"indev,omitempty"`
+	Actions              *[]*Action        `json:"actions,omitempty"`
+	KeyEthDst            *net.HardwareAddr `json:"key_eth_dst,omitempty"`
+	KeyEthDstMask        *net.HardwareAddr `json:"key_eth_dst_mask,omitempty"`
+	KeyEthSrc            *net.HardwareAddr `json:"key_eth_src,omitempty"`
+	KeyEthSrcMask        *net.HardwareAddr `json:"key_eth_src_mask,omitempty"`
+	KeyEthType           *uint16           `json:"key_eth_type,omitempty"` /* be16 */
+	KeyIPProto           *uint8            `json:"key_ip_proto,omitempty"`
+	KeyIPv4Src           *net.IP           `json:"key_ipv4_src,omitempty"`
+	KeyIPv4SrcMask       *net.IP           `json:"key_ipv4_src_mask,omitempty"`
+	KeyIPv4Dst           *net.IP           `json:"key_ipv4_dst,omitempty"`
+	KeyIPv4DstMask       *net.IP           `json:"key_ipv4_dst_mask,omitempty"`
+	KeyTCPSrc            *uint16           `json:"key_tcp_src,omitempty"` /* be16 */
+	KeyTCPDst            *uint16           `json:"key_tcp_dst,omitempty"` /* be16 */
+	KeyUDPSrc            *uint16           `json:"key_udp_src,omitempty"` /* be16 */
+	KeyUDPDst            *uint16           `json:"key_udp_dst,omitempty"` /* be16 */
+	Flags                *uint32           `json:"flags,omitempty"`
+	KeyVlanID            *uint16           `json:"key_vlan_id,omitempty"` /* u16 */
+	KeyVlanPrio          *uint8            `json:"key_vlan_prio,omitempty"`
+	KeyVlanEthType       *uint16           `json:"key_vlan_eth_type,omitempty"` /* be16 */
+	KeyEncKeyID          *uint32           `json:"key_enc_key_id,omitempty"`    /* be32 */
+	KeyEncIPv4Src        *net.IP           `json:"key_enc_ipv4_src,omitempty"`
+	KeyEncIPv4SrcMask    *net.IP           `json:"key_enc_ipv4_src_mask,omitempty"`
+	KeyEncIPv4Dst        *net.IP           `json:"key_enc_ipv4_dst,omitempty"`
+	KeyEncIPv4DstMask    *net.IP           `json:"key_enc_ipv4_dst_mask,omitempty"`
+	KeyTCPSrcMask        *uint16           `json:"key_tcp_src_mask,omitempty"`          /* be16 */
+	KeyTCPDstMask        *uint16           `json:"key_tcp_dst_mask,omitempty"`          /* be16 */
+	KeyUDPSrcMask        *uint16           `json:"key_udp_src_mask,omitempty"`          /* be16 */
+	KeyUDPDstMask        *uint16           `json:"key_udp_dst_mask,omitempty"`          /* be16 */
+	KeySctpSrc           *uint16           `json:"key_sctp_src,omitempty"`              /* be16 */
+	KeySctpDst           *uint16           `json:"key_sctp_dst,omitempty"`              /* be16 */
+	KeyEncUDPSrcPort     *uint16           `json:"key_enc_udp_src_port,omitempty"`      /* be16 */
+	KeyEncUDPSrcPortMask *uint16           `json:"key_enc_udp_src_port_mask,omitempty"` /* be16 */
+	KeyEncUDPDstPort     *uint16           `json:"key_enc_udp_dst_port,omitempty"`      /* be16 */
+	KeyEncUDPDstPortMask *uint16           `json:"key_enc_udp_dst_port_mask,omitempty"` /* be16 */
+	KeyFlags             *uint32           `json:"key_flags,omitempty"`                 /* be32 */
+	KeyFlagsMask         *uint32           `json:"key_flags_mask,omitempty"`            /* be32 */
+	KeyIcmpv4Code        *uint8            `json:"key_icmpv4_code,omitempty"`
+	KeyIcmpv4CodeMask    *uint8            `json:"key_icmpv4_code_mask,omitempty"`
+	KeyIcmpv4Type        *uint8            `json:"key_icmpv4_type,omitempty"`
+	KeyIcmpv4TypeMask    *uint8            `json:"key_icmpv4_type_mask,omitempty"`
+	KeyIcmpv6Code        *uint8            `json:"key_icmpv6_code,omitempty"`
+	KeyIcmpv6CodeMask    *uint8            `json:"key_icmpv6_code_mask,omitempty"`
+	KeyArpSIP            *uint32           `json:"key_arp_s_ip,omitempty"`      /* be32 */
+	KeyArpSIPMask        *uint32           `json:"key_arp_s_ip_mask,omitempty"` /* be32 */
+	KeyArpTIP            *uint32           `json:"key_arp_t_ip,omitempty"`      /* be32 */
+	KeyArpTIPMask        *uint32           `json:"key_arp_t_ip_mask,omitempty"` /* be32 */
+	KeyArpOp             *uint8            `json:"key_arp_op,omitempty"`
+	KeyArpOpMask         *uint8            `json:"key_arp_op_mask,omitempty"`
+	KeyMplsTTL           *uint8            `json:"key_mpls_ttl,omitempty"`
+	KeyMplsBos           *uint8            `json:"key_mpls_bos,omitempty"`
+	KeyMplsTc            *uint8            `json:"key_mpls_tc,omitempty"`
+	KeyMplsLabel         *uint32           `json:"key_mpls_label,omitempty"`
+	KeyTCPFlags          *uint16           `json:"key_tcp_flags,omitempty"`      /* be16 */
+	KeyTCPFlagsMask      *uint16           `json:"key_tcp_flags_mask,omitempty"` /* be16 */
+	KeyIPTOS             *uint8            `json:"key_ip_tos,omitempty"`
+	KeyIPTOSMask         *uint8            `json:"key_ip_tos_mask,omitempty"`
+	KeyIPTTL             *uint8            `json:"key_ip_ttl,omitempty"`
+	KeyIPTTLMask         *uint8            `json:"key_ip_ttl_mask,omitempty"`
+	KeyCVlanID           *uint16           `json:"key_c_vlan_id,omitempty"` /* u16 */
+	KeyCVlanPrio         *uint8            `json:"key_c_vlan_prio,omitempty"`
+	KeyCVlanEthType      *uint16           `json:"key_c_vlan_eth_type,omitempty"` /* be16 */
+	KeyEncIPTOS          *uint8            `json:"key_enc_ip_tos,omitempty"`
+	KeyEncIPTOSMask      *uint8            `json:"key_enc_ip_tos_mask,omitempty"`
+	KeyEncIPTTL          *uint8            `json:"key_enc_ip_ttl,omitempty"`
+	KeyEncIPTTLMask      *uint8            `json:"key_enc_ip_ttl_mask,omitempty"`
+	InHwCount            *uint32           `json:"in_hw_count,omitempty"`
+	KeyPortSrcMin        *uint16           `json:"key_port_src_min,omitempty"` /* be16 */
+	KeyPortSrcMax        *uint16           `json:"key_port_src_max,omitempty"` /* be16 */
+	KeyPortDstMin        *uint16           `json:"key_port_dst_min,omitempty"` /* be16 */
+	KeyPortDstMax        *uint16           `json:"key_port_dst_max,omitempty"` /* be16 */
 
-	KeyCtState     *uint16 /* u16 */
-	KeyCtStateMask *uint16 /* u16 */
-	KeyCtZone      *uint16 /* u16 */
-	KeyCtZoneMask  *uint16 /* u16 */
-	KeyCtMark      *uint32 /* u32 */
-	KeyCtMarkMask  *uint32 /* u32 */
+	KeyCtState     *uint16 `json:"key_ct_state,omitempty"`      /* u16 */
+	KeyCtStateMask *uint16 `json:"key_ct_state_mask,omitempty"` /* u16 */
+	KeyCtZone      *uint16 `json:"key_ct_zone,omitempty"`       /* u16 */
+	KeyCtZoneMask  *uint16 `json:"key_ct_zone_mask,omitempty"`  /* u16 */
+	KeyCtMark      *uint32 `json:"key_ct_mark,omitempty"`       /* u32 */
+	KeyCtMarkMask  *uint32 `json:"key_ct_mark_mask,omitempty"`  /* u32 */
 	//KeyCtLabels,	/* u128 */
 	//KeyCtLabelsMask,	/* u128 */
 	//KeyMplsOpts,
 
-	KeyHash     *uint32 /* u32 */
-	KeyHashMask *uint32 /* u32 */
+	KeyHash     *uint32 `json:"key_hash,omitempty"`      /* u32 */
+	KeyHashMask *uint32 `json:"key_hash_mask,omitempty"` /* u32 */
 
-	KeyNumOfVLANS *uint8 /* u8 */
+	KeyNumOfVLANS *uint8 `json:"key_num_of_vlans,omitempty"` /* u8 */
 
-	KeyPppoeSID *uint16 /* be16 */
-	KeyPppProto *uint16 /* be16 */
+	KeyPppoeSID *uint16 `json:"key_pppoe_s_id,omitempty"` /* be16 */
+	KeyPppProto *uint16 `json:"key_ppp_proto,omitempty"`  /* be16 */
 
-	KeyL2TPV3SID *uint32 /* be32 */
+	KeyL2TPV3SID *uint32 `json:"key_l2_tpv3_s_id,omitempty"` /* be32 */
 
-	L2Miss *uint8 /* u8 */
+	L2Miss *uint8 `json:"l2_miss,omitempty"` /* u8 */
 
 	//KeyCfm,		/* nested */
 
-	KeySpi     *uint32 /* be32 */
-	KeySpiMask *uint32 /* be32 */
+	KeySpi     *uint32 `json:"key_spi,omitempty"`      /* be32 */
+	KeySpiMask *uint32 `json:"key_spi_mask,omitempty"` /* be32 */
 
-	KeyEncFlags     *uint32 /* be32 */
-	KeyEncFlagsMask *uint32 /* be32 */
+	KeyEncFlags     *uint32 `json:"key_enc_flags,omitempty"`      /* be32 */
+	KeyEncFlagsMask *uint32 `json:"key_enc_flags_mask,omitempty"` /* be32 */
 }
 
 // unmarshalFlower parses the Flower-encoded data and stores the result in the value pointed to by info.
 func unmarshalFlower(data []byte, info *Flower) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -261,7 +259,7 @@ func unmarshalFlower(data []byte, info *Flower) error {
 			tmp := bytesToHardwareAddr(ad.Bytes())
 			info.KeyEthSrcMask = &tmp
 		case tcaFlowerKeyEthType:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ntohs(ad.Uint16())
 			info.KeyEthType = &tmp
 		case tcaFlowerKeyIPProto:
 			tmp := ad.Uint8()
@@ -279,31 +277,31 @@ func unmarshalFlower(data []byte, info *Flower) error {
 			tmp := uint32ToIP(ad.Uint32())
 			info.KeyIPv4DstMask = &tmp
 		case tcaFlowerKeyTCPSrc:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ntohs(ad.Uint16())
 			info.KeyTCPSrc = &tmp
 		case tcaFlowerKeyTCPDst:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ntohs(ad.Uint16())
 			info.KeyTCPDst = &tmp
 		case tcaFlowerKeyUDPSrc:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ntohs(ad.Uint16())
 			info.KeyUDPSrc = &tmp
 		case tcaFlowerKeyUDPDst:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ntohs(ad.Uint16())
 			info.KeyUDPDst = &tmp
 		case tcaFlowerFlags:
 			tmp := ad.Uint32()
 			info.Flags = &tmp
 		case tcaFlowerKeyVlanID:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ad.Uint16()
 			info.KeyVlanID = &tmp
 		case tcaFlowerKeyVlanPrio:
 			tmp := ad.Uint8()
 			info.KeyVlanPrio = &tmp
 		case tcaFlowerKeyVlanEthType:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ntohs(ad.Uint16())
 			info.KeyVlanEthType = &tmp
 		case tcaFlowerKeyEncKeyID:
-			tmp := endianSwapUint32(ad.Uint32())
+			tmp := ntohl(ad.Uint32())
 			info.KeyEncKeyID = &tmp
 		case tcaFlowerKeyEncIPv4Src:
 			tmp := uint32ToIP(ad.Uint32())
@@ -318,40 +316,40 @@ func unmarshalFlower(data []byte, info *Flower) error {
 			tmp := uint32ToIP(ad.Uint32())
 			info.KeyEncIPv4DstMask = &tmp
 		case tcaFlowerKeyTCPSrcMask:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ntohs(ad.Uint16())
 			info.KeyTCPSrcMask = &tmp
 		case tcaFlowerKeyTCPDstMask:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ntohs(ad.Uint16())
 			info.KeyTCPDstMask = &tmp
 		case tcaFlowerKeyUDPSrcMask:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ntohs(ad.Uint16())
 			info.KeyUDPSrcMask = &tmp
 		case tcaFlowerKeyUDPDstMask:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ntohs(ad.Uint16())
 			info.KeyUDPDstMask = &tmp
 		case tcaFlowerKeySCTPSrc:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ntohs(ad.Uint16())
 			info.KeySctpSrc = &tmp
 		case tcaFlowerKeySCTPDst:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ntohs(ad.Uint16())
 			info.KeySctpDst = &tmp
 		case tcaFlowerKeyEncUDPSrcPort:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ntohs(ad.Uint16())
 			info.KeyEncUDPSrcPort = &tmp
 		case tcaFlowerKeyEncUDPSrcPortMask:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ntohs(ad.Uint16())
 			info.KeyEncUDPSrcPortMask = &tmp
 		case tcaFlowerKeyEncUDPDstPort:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ntohs(ad.Uint16())
 			info.KeyEncUDPDstPort = &tmp
 		case tcaFlowerKeyEncUDPDstPortMask:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ntohs(ad.Uint16())
 			info.KeyEncUDPDstPortMask = &tmp
 		case tcaFlowerKeyFlags:
-			tmp := endianSwapUint32(ad.Uint32())
+			tmp := ntohl(ad.Uint32())
 			info.KeyFlags = &tmp
 		case tcaFlowerKeyFlagsMask:
-			tmp := endianSwapUint32(ad.Uint32())
+			tmp := ntohl(ad.Uint32())
 			info.KeyFlagsMask = &tmp
 		case tcaFlowerKeyIcmpv4Code:
 			tmp := ad.Uint8()
@@ -372,16 +370,16 @@ func unmarshalFlower(data []byte, info *Flower) error {
 			tmp := ad.Uint8()
 			info.KeyIcmpv6CodeMask = &tmp
 		case tcaFlowerKeyArpSIP:
-			tmp := endianSwapUint32(ad.Uint32())
+			tmp := ntohl(ad.Uint32())
 			info.KeyArpSIP = &tmp
 		case tcaFlowerKeyArpSIPMask:
-			tmp := endianSwapUint32(ad.Uint32())
+			tmp := ntohl(ad.Uint32())
 			info.KeyArpSIPMask = &tmp
 		case tcaFlowerKeyArpTIP:
-			tmp := endianSwapUint32(ad.Uint32())
+			tmp := ntohl(ad.Uint32())
 			info.KeyArpTIP = &tmp
 		case tcaFlowerKeyArpTIPMask:
-			tmp := endianSwapUint32(ad.Uint32())
+			tmp := ntohl(ad.Uint32())
 			info.KeyArpTIPMask = &tmp
 		case tcaFlowerKeyArpOp:
 			tmp := ad.Uint8()
@@ -402,10 +400,10 @@ func unmarshalFlower(data []byte, info *Flower) error {
 			tmp := ad.Uint32()
 			info.KeyMplsLabel = &tmp
 		case tcaFlowerKeyTCPFlags:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ntohs(ad.Uint16())
 			info.KeyTCPFlags = &tmp
 		case tcaFlowerKeyTCPFlagsMask:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ntohs(ad.Uint16())
 			info.KeyTCPFlagsMask = &tmp
 		case tcaFlowerKeyIPTOS:
 			tmp := ad.Uint8()
@@ -420,13 +418,13 @@ func unmarshalFlower(data []byte, info *Flower) error {
 			tmp := ad.Uint8()
 			info.KeyIPTTLMask = &tmp
 		case tcaFlowerKeyCVlanID:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ad.Uint16()
 			info.KeyCVlanID = &tmp
 		case tcaFlowerKeyCVlanPrio:
 			tmp := ad.Uint8()
 			info.KeyCVlanPrio = &tmp
 		case tcaFlowerKeyCVlanEthType:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ntohs(ad.Uint16())
 			info.KeyCVlanEthType = &tmp
 		case tcaFlowerKeyEncIPTOS:
 			tmp := ad.Uint8()
@@ -444,16 +442,16 @@ func unmarshalFlower(data []byte, info *Flower) error {
 			tmp := ad.Uint32()
 			info.InHwCount = &tmp
 		case tcaFlowerKeyPortSrcMin:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ntohs(ad.Uint16())
 			info.KeyPortSrcMin = &tmp
 		case tcaFlowerKeyPortSrcMax:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ntohs(ad.Uint16())
 			info.KeyPortSrcMax = &tmp
 		case tcaFlowerKeyPortDstMin:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ntohs(ad.Uint16())
 			info.KeyPortDstMin = &tmp
 		case tcaFlowerKeyPortDstMax:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ntohs(ad.Uint16())
 			info.KeyPortDstMax = &tmp
 		case tcaFlowerKeyCtState:
 			tmp := ad.Uint16()
@@ -483,31 +481,31 @@ func unmarshalFlower(data []byte, info *Flower) error {
 			tmp := ad.Uint8()
 			info.KeyNumOfVLANS = &tmp
 		case tcaFlowerKeyPppoeSID:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ntohs(ad.Uint16())
 			info.KeyPppoeSID = &tmp
 		case tcaFlowerKeyPppProto:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ntohs(ad.Uint16())
 			info.KeyPppProto = &tmp
 		case tcaFlowerKeyL2TPV3SID:
-			tmp := endianSwapUint32(ad.Uint32())
+			tmp := ntohl(ad.Uint32())
 			info.KeyL2TPV3SID = &tmp
 		case tcaFlowerL2Miss:
 			tmp := ad.Uint8()
 			info.L2Miss = &tmp
 		case tcaFlowerKeySPI:
-			tmp := endianSwapUint32(ad.Uint32())
+			tmp := ntohl(ad.Uint32())
 			info.KeySpi = &tmp
 		case tcaFlowerKeySPIMask:
-			tmp := endianSwapUint32(ad.Uint32())
+			tmp := ntohl(ad.Uint32())
 			info.KeySpiMask = &tmp
 		case tcaFlowerKeyEncFlags:
-			tmp := endianSwapUint32(ad.Uint32())
+			tmp := ntohl(ad.Uint32())
 			info.KeyEncFlags = &tmp
 		case tcaFlowerKeyEncFlagsMask:
-			tmp := endianSwapUint32(ad.Uint32())
+			tmp := ntohl(ad.Uint32())
 			info.KeyEncFlagsMask = &tmp
 		default:
-			return fmt.Errorf("unmarshalFlower()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("flower", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -528,7 +526,7 @@ func marshalFlower(info *Flower) ([]byte, error) {
 	if info.Indev != nil {
 		options = append(options, tcOption{Interpretation: vtString, Type: tcaFlowerIndev, Data: *info.Indev})
 	}
-	if info.Actions != nil {
+	if hasActions(info.Actions) {
 		data, err := marshalActions(0, *info.Actions)
 		multiError = concatError(multiError, err)
 		options = append(options, tcOption{Interpretation: vtBytes, Type: tcaFlowerAct, Data: data})
@@ -591,7 +589,7 @@ func marshalFlower(info *Flower) ([]byte, error) {
 		options = append(options, tcOption{Interpretation: vtUint32, Type: tcaFlowerFlags, Data: *info.Flags})
 	}
 	if info.KeyVlanID != nil {
-		options = append(options, tcOption{Interpretation: vtUint16Be, Type: tcaFlowerKeyVlanID, Data: *info.KeyVlanID})
+		options = append(options, tcOption{Interpretation: vtUint16, Type: tcaFlowerKeyVlanID, Data: *info.KeyVlanID})
 	}
 	if info.KeyVlanPrio != nil {
 		options = append(options, tcOption{Interpretation: vtUint8, Type: tcaFlowerKeyVlanPrio, Data: *info.KeyVlanPrio})
@@ -725,7 +723,7 @@ func marshalFlower(info *Flower) ([]byte, error) {
 		options = append(options, tcOption{Interpretation: vtUint8, Type: tcaFlowerKeyIPTTLMask, Data: *info.KeyIPTTLMask})
 	}
 	if info.KeyCVlanID != nil {
-		options = append(options, tcOption{Interpretation: vtUint16Be, Type: tcaFlowerKeyCVlanID, Data: *info.KeyCVlanID})
+		options = append(options, tcOption{Interpretation: vtUint16, Type: tcaFlowerKeyCVlanID, Data: *info.KeyCVlanID})
 	}
 	if info.KeyCVlanPrio != nil {
 		options = append(options, tcOption{Interpretation: vtUint8, Type: tcaFlowerKeyCVlanPrio, Data: *info.KeyCVlanPrio})
@@ -745,9 +743,7 @@ func marshalFlower(info *Flower) ([]byte, error) {
 	if info.KeyEncIPTTLMask != nil {
 		options = append(options, tcOption{Interpretation: vtUint8, Type: tcaFlowerKeyEncIPTTLMask, Data: *info.KeyEncIPTTLMask})
 	}
-	if info.InHwCount != nil {
-		options = append(options, tcOption{Interpretation: vtUint32, Type: tcaFlowerInHwCount, Data: *info.InHwCount})
-	}
+	// InHwCount is reported by the kernel and can not be set.
 	if info.KeyPortSrcMin != nil {
 		options = append(options, tcOption{Interpretation: vtUint16Be, Type: tcaFlowerKeyPortSrcMin, Data: *info.KeyPortSrcMin})
 	}
@@ -0,0 +1,319 @@
+package tc
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/florianl/go-tc/core"
+)
+
+// Ethernet types and IP protocols, that are set by the FlowerMatch builders.
+const (
+	flowerEthTypeIPv4  = 0x0800
+	flowerEthTypeIPv6  = 0x86DD
+	flowerEthType8021Q = 0x8100
+	flowerEthType8021A = 0x88A8
+
+	flowerIPProtoTCP  = 6
+	flowerIPProtoUDP  = 17
+	flowerIPProtoSCTP = 132
+
+	// flowerVlanIDMax is the largest VLAN ID iproute2/tc/f_flower.c accepts.
+	flowerVlanIDMax = 4095
+)
+
+// FlowerMatch sets the keys of a Flower for one match expression of tc-flower,
+// like "dst_ip 10.0.0.0/24". Together with the key, it sets the keys the match
+// depends on, like KeyEthType or KeyIPProto.
+type FlowerMatch func(*Flower) error
+
+// NewFlower returns a Flower, that combines matches. As tc, it sets Flags, so
+// that the attributes equal the ones of the corresponding tc command.
+func NewFlower(matches ...FlowerMatch) (*Flower, error) {
+	f := &Flower{Flags: uint32Ptr(0)}
+	if err := f.Match(matches...); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Match applies matches to f in the given order. Matches, that contradict
+// keys, which are already set, return ErrInvalidArg.
+func (f *Flower) Match(matches ...FlowerMatch) error {
+	for _, match := range matches {
+		if err := match(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FlowerMatchIPv4Dst matches the IPv4 destination address against ipnet, like
+// "dst_ip 10.0.0.0/24".
+func FlowerMatchIPv4Dst(ipnet *net.IPNet) FlowerMatch {
+	return func(f *Flower) error {
+		return f.matchIPv4("dst_ip", &f.KeyIPv4Dst, &f.KeyIPv4DstMask, ipnet)
+	}
+}
+
+// FlowerMatchIPv4Src matches the IPv4 source address against ipnet, like
+// "src_ip 10.0.0.0/24".
+func FlowerMatchIPv4Src(ipnet *net.IPNet) FlowerMatch {
+	return func(f *Flower) error {
+		return f.matchIPv4("src_ip", &f.KeyIPv4Src, &f.KeyIPv4SrcMask, ipnet)
+	}
+}
+
+// FlowerMatchTCPDst matches the TCP destination port, like "ip_proto tcp dst_port 443".
+func FlowerMatchTCPDst(port uint16) FlowerMatch {
+	return func(f *Flower) error {
+		return f.matchPort("dst_port", flowerIPProtoTCP, &f.KeyTCPDst, port)
+	}
+}
+
+// FlowerMatchTCPSrc matches the TCP source port, like "ip_proto tcp src_port 443".
+func FlowerMatchTCPSrc(port uint16) FlowerMatch {
+	return func(f *Flower) error {
+		return f.matchPort("src_port", flowerIPProtoTCP, &f.KeyTCPSrc, port)
+	}
+}
+
+// FlowerMatchUDPDst matches the UDP destination port, like "ip_proto udp dst_port 53".
+func FlowerMatchUDPDst(port uint16) FlowerMatch {
+	return func(f *Flower) error {
+		return f.matchPort("dst_port", flowerIPProtoUDP, &f.KeyUDPDst, port)
+	}
+}
+
+// FlowerMatchUDPSrc matches the UDP source port, like "ip_proto udp src_port 53".
+func FlowerMatchUDPSrc(port uint16) FlowerMatch {
+	return func(f *Flower) error {
+		return f.matchPort("src_port", flowerIPProtoUDP, &f.KeyUDPSrc, port)
+	}
+}
+
+// FlowerMatchVLAN matches the VLAN ID of 802.1Q tagged packets, like
+// "protocol 802.1Q flower vlan_id 100". An ethernet type, that was set by a
+// previous match, becomes the ethernet type of the VLAN.
+func FlowerMatchVLAN(id uint16) FlowerMatch {
+	return func(f *Flower) error {
+		if err := checkFlowerVlanID("vlan_id", "KeyVlanID", id, f.KeyVlanID); err != nil {
+			return err
+		}
+		if f.KeyEthType != nil && !flowerVlanEthType(*f.KeyEthType) {
+			if f.KeyVlanEthType != nil && *f.KeyVlanEthType != *f.KeyEthType {
+				return fmt.Errorf("flower: KeyEthType %#04x contradicts KeyVlanEthType %#04x: %w",
+					*f.KeyEthType, *f.KeyVlanEthType, ErrInvalidArg)
+			}
+			f.KeyVlanEthType = uint16Ptr(*f.KeyEthType)
+			f.KeyEthType = nil
+		}
+		if f.KeyEthType == nil {
+			f.KeyEthType = uint16Ptr(flowerEthType8021Q)
+		}
+		f.KeyVlanID = uint16Ptr(id)
+		return nil
+	}
+}
+
+// FlowerQinQ matches the service VLAN outerID and the customer VLAN innerID
+// of double tagged packets, like "protocol 802.1ad flower vlan_id 100
+// vlan_ethtype 802.1Q cvlan_id 200". The filter has to be of the protocol
+// 802.1ad, see core.BuildFilterInfo. An ethernet type, that was set by a
+// previous match, becomes the ethernet type of the customer VLAN.
+func FlowerQinQ(outerID, innerID uint16) FlowerMatch {
+	return func(f *Flower) error {
+		if err := checkFlowerVlanID("vlan_id", "KeyVlanID", outerID, f.KeyVlanID); err != nil {
+			return err
+		}
+		if err := checkFlowerVlanID("cvlan_id", "KeyCVlanID", innerID, f.KeyCVlanID); err != nil {
+			return err
+		}
+		var ethType *uint16
+		switch {
+		case f.KeyEthType == nil:
+		case *f.KeyEthType == flowerEthType8021A:
+			if f.KeyVlanEthType == nil || *f.KeyVlanEthType == flowerEthType8021Q {
+				break
+			}
+			if flowerVlanEthType(*f.KeyVlanEthType) {
+				return fmt.Errorf("flower: KeyVlanEthType %#04x contradicts 802.1Q: %w", *f.KeyVlanEthType, ErrInvalidArg)
+			}
+			ethType = f.KeyVlanEthType
+		case flowerVlanEthType(*f.KeyEthType):
+			return fmt.Errorf("flower: KeyEthType %#04x contradicts 802.1ad: %w", *f.KeyEthType, ErrInvalidArg)
+		default:
+			ethType = f.KeyEthType
+		}
+		if ethType != nil {
+			if f.KeyCVlanEthType != nil && *f.KeyCVlanEthType != *ethType {
+				return fmt.Errorf("flower: ethernet type %#04x contradicts KeyCVlanEthType %#04x: %w",
+					*ethType, *f.KeyCVlanEthType, ErrInvalidArg)
+			}
+			f.KeyCVlanEthType = uint16Ptr(*ethType)
+		}
+		f.KeyEthType = uint16Ptr(flowerEthType8021A)
+		f.KeyVlanEthType = uint16Ptr(flowerEthType8021Q)
+		f.KeyVlanID = uint16Ptr(outerID)
+		f.KeyCVlanID = uint16Ptr(innerID)
+		return nil
+	}
+}
+
+// checkFlowerVlanID checks, that id is a valid VLAN ID and does not contradict
+// the already set key.
+func checkFlowerVlanID(name, keyName string, id uint16, key *uint16) error {
+	if id > flowerVlanIDMax {
+		return fmt.Errorf("flower: %s %d exceeds %d: %w", name, id, flowerVlanIDMax, ErrInvalidArg)
+	}
+	if key != nil && *key != id {
+		return fmt.Errorf("flower: %s %d contradicts %s %d: %w", name, id, keyName, *key, ErrInvalidArg)
+	}
+	return nil
+}
+
+func (f *Flower) matchIPv4(name string, addr, mask **net.IP, ipnet *net.IPNet) error {
+	if ipnet == nil {
+		return fmt.Errorf("flower: %s: %w", name, ErrNoArg)
+	}
+	ip := ipnet.IP.To4()
+	ipMask := ipnet.Mask
+	if len(ipMask) == net.IPv6len {
+		ipMask = ipMask[net.IPv6len-net.IPv4len:]
+	}
+	if ip == nil || len(ipMask) != net.IPv4len {
+		return fmt.Errorf("flower: %s %s is no IPv4 network: %w", name, ipnet, ErrInvalidArg)
+	}
+	if err := f.setEthType(flowerEthTypeIPv4); err != nil {
+		return err
+	}
+	*addr = netIPPtr(ip.Mask(ipMask))
+	*mask = netIPPtr(net.IP(ipMask))
+	return nil
+}
+
+func (f *Flower) matchPort(name string, proto uint8, port **uint16, value uint16) error {
+	if f.KeyIPProto != nil && *f.KeyIPProto != proto {
+		return fmt.Errorf("flower: %s of ip_proto %s contradicts KeyIPProto %d: %w",
+			name, ipProtocols[proto], *f.KeyIPProto, ErrInvalidArg)
+	}
+	f.KeyIPProto = uint8Ptr(proto)
+	*port = uint16Ptr(value)
+	return nil
+}
+
+// setEthType sets the ethernet type of the packets, that are matched. For
+// VLAN tagged packets, it is the ethernet type of the VLAN and for double
+// tagged packets the one of the customer VLAN.
+func (f *Flower) setEthType(ethType uint16) error {
+	key := &f.KeyEthType
+	if f.KeyEthType != nil && flowerVlanEthType(*f.KeyEthType) {
+		key = &f.KeyVlanEthType
+		if f.KeyVlanEthType != nil && flowerVlanEthType(*f.KeyVlanEthType) {
+			key = &f.KeyCVlanEthType
+		}
+	}
+	if *key != nil && **key != ethType {
+		return fmt.Errorf("flower: ethernet type %#04x contradicts %#04x: %w", ethType, **key, ErrInvalidArg)
+	}
+	*key = uint16Ptr(ethType)
+	return nil
+}
+
+func flowerVlanEthType(ethType uint16) bool {
+	return ethType == flowerEthType8021Q || ethType == flowerEthType8021A
+}
+
+// validateFlower checks, that the keys of f are consistent with each other and
+// with the protocol of the filter in info. The kernel accepts keys, whose
+// dependencies are missing, but they do not match as intended.
+func validateFlower(f *Flower, info uint32) error {
+	var multiError error
+	invalid := func(format string, a ...interface{}) {
+		multiError = concatError(multiError, fmt.Errorf("flower: "+format+": %w", append(a, ErrInvalidArg)...))
+	}
+
+	// Without KeyEthType, the filter only sees packets of its protocol.
+	_, proto := core.SplitFilterInfo(info)
+	ethType := proto
+	if f.KeyEthType != nil {
+		ethType = *f.KeyEthType
+		// A filter, that is not of protocol all, never sees packets of
+		// another ethernet type.
+		if proto != 0 && proto != core.EthPAll && ethType != proto {
+			invalid("KeyEthType %s contradicts the protocol %s of the filter",
+				core.FormatProtocol(ethType), core.FormatProtocol(proto))
+		}
+	}
+	if (f.KeyVlanID != nil || f.KeyVlanPrio != nil || f.KeyVlanEthType != nil) && f.KeyEthType == nil {
+		// linux/net/sched/cls_flower.c:fl_set_key() only parses the VLAN
+		// keys together with TCA_FLOWER_KEY_ETH_TYPE and ignores them
+		// otherwise, even for the protocol 802.1Q.
+		invalid("VLAN keys require KeyEthType 802.1Q or 802.1ad")
+	} else if flowerVlanEthType(ethType) {
+		ethType = 0
+		if f.KeyVlanEthType != nil {
+			ethType = *f.KeyVlanEthType
+		}
+		// Packets with two VLAN tags are matched by the customer VLAN keys.
+		if flowerVlanEthType(ethType) {
+			ethType = 0
+			if f.KeyCVlanEthType != nil {
+				ethType = *f.KeyCVlanEthType
+			}
+		}
+	} else if f.KeyVlanID != nil || f.KeyVlanPrio != nil || f.KeyVlanEthType != nil {
+		invalid("VLAN keys require KeyEthType 802.1Q or 802.1ad")
+	}
+	if f.KeyVlanID != nil && *f.KeyVlanID > flowerVlanIDMax {
+		invalid("KeyVlanID %d exceeds %d", *f.KeyVlanID, flowerVlanIDMax)
+	}
+	if f.KeyCVlanID != nil || f.KeyCVlanPrio != nil || f.KeyCVlanEthType != nil {
+		// fl_set_key() only parses the customer VLAN keys, if both
+		// KeyEthType and KeyVlanEthType are VLAN types, like 802.1ad
+		// followed by 802.1Q, and ignores them otherwise.
+		if f.KeyEthType == nil || !flowerVlanEthType(*f.KeyEthType) ||
+			f.KeyVlanEthType == nil || !flowerVlanEthType(*f.KeyVlanEthType) {
+			invalid("CVLAN keys require KeyEthType and KeyVlanEthType 802.1Q or 802.1ad")
+		}
+	}
+	if f.KeyCVlanID != nil && *f.KeyCVlanID > flowerVlanIDMax {
+		invalid("KeyCVlanID %d exceeds %d", *f.KeyCVlanID, flowerVlanIDMax)
+	}
+
+	for _, key := range []struct {
+		name    string
+		value   bool
+		mask    bool
+		ethType uint16
+		ipProto uint8
+	}{
+		{"KeyIPv4Src", f.KeyIPv4Src != nil, f.KeyIPv4SrcMask != nil, flowerEthTypeIPv4, 0},
+		{"KeyIPv4Dst", f.KeyIPv4Dst != nil, f.KeyIPv4DstMask != nil, flowerEthTypeIPv4, 0},
+		{"KeyTCPSrc", f.KeyTCPSrc != nil, f.KeyTCPSrcMask != nil, 0, flowerIPProtoTCP},
+		{"KeyTCPDst", f.KeyTCPDst != nil, f.KeyTCPDstMask != nil, 0, flowerIPProtoTCP},
+		{"KeyUDPSrc", f.KeyUDPSrc != nil, f.KeyUDPSrcMask != nil, 0, flowerIPProtoUDP},
+		{"KeyUDPDst", f.KeyUDPDst != nil, f.KeyUDPDstMask != nil, 0, flowerIPProtoUDP},
+		{"KeySctpSrc", f.KeySctpSrc != nil, false, 0, flowerIPProtoSCTP},
+		{"KeySctpDst", f.KeySctpDst != nil, false, 0, flowerIPProtoSCTP},
+	} {
+		if key.mask && !key.value {
+			// linux/net/sched/cls_flower.c:fl_set_key_val() ignores masks
+			// without a value.
+			invalid("%sMask is set without %s", key.name, key.name)
+		}
+		if !key.value {
+			continue
+		}
+		if key.ethType != 0 && ethType != key.ethType {
+			invalid("%s requires the ethernet type %#04x", key.name, key.ethType)
+		}
+		if key.ipProto != 0 && (f.KeyIPProto == nil || *f.KeyIPProto != key.ipProto) {
+			invalid("%s requires KeyIPProto %s", key.name, ipProtocols[key.ipProto])
+		}
+	}
+	if f.KeyIPProto != nil && ethType != flowerEthTypeIPv4 && ethType != flowerEthTypeIPv6 {
+		invalid("KeyIPProto requires the ethernet type ip or ipv6")
+	}
+	return multiError
+}
@@ -0,0 +1,323 @@
+package tc
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/netlink"
+)
+
+func TestFlowerMatchIproute2(t *testing.T) {
+	if nativeEndian.Uint16([]byte{1, 0}) != 1 {
+		t.Skip("fixtures were captured on a little endian host")
+	}
+	fixtures := iproute2Options(t, "testdata/iproute2_flower.txt")
+	ipNet := func(s string) *net.IPNet {
+		_, ipnet, err := net.ParseCIDR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ipnet
+	}
+	const prefix = "tc filter add dev fl0 ingress pref 1 "
+
+	tests := map[string]struct {
+		protocol uint16
+		matches  []FlowerMatch
+	}{
+		"protocol ip flower dst_ip 10.0.0.0/24": {
+			protocol: 0x0800,
+			matches:  []FlowerMatch{FlowerMatchIPv4Dst(ipNet("10.0.0.0/24"))},
+		},
+		"protocol ip flower dst_ip 10.1.2.3": {
+			protocol: 0x0800,
+			matches:  []FlowerMatch{FlowerMatchIPv4Dst(&net.IPNet{IP: net.ParseIP("10.1.2.3"), Mask: net.CIDRMask(32, 32)})},
+		},
+		"protocol ip flower dst_ip 10.0.0.0/24 ip_proto tcp dst_port 443": {
+			protocol: 0x0800,
+			matches:  []FlowerMatch{FlowerMatchIPv4Dst(ipNet("10.0.0.0/24")), FlowerMatchTCPDst(443)},
+		},
+		"protocol ip flower src_ip 192.168.0.0/16 ip_proto udp src_port 53": {
+			protocol: 0x0800,
+			// The order of matches does not matter.
+			matches: []FlowerMatch{FlowerMatchUDPSrc(53), FlowerMatchIPv4Src(ipNet("192.168.3.4/16"))},
+		},
+		"protocol 802.1Q flower vlan_id 100": {
+			protocol: 0x8100,
+			matches:  []FlowerMatch{FlowerMatchVLAN(100)},
+		},
+		"protocol 802.1Q flower vlan_id 4094": {
+			protocol: 0x8100,
+			matches:  []FlowerMatch{FlowerMatchVLAN(4094)},
+		},
+		"protocol 802.1Q flower vlan_id 100 vlan_ethtype ip dst_ip 10.0.0.1/32 ip_proto tcp dst_port 443": {
+			protocol: 0x8100,
+			matches: []FlowerMatch{FlowerMatchIPv4Dst(ipNet("10.0.0.1/32")), FlowerMatchTCPDst(443),
+				FlowerMatchVLAN(100)},
+		},
+		"protocol 802.1ad flower vlan_id 100 vlan_ethtype 802.1Q cvlan_id 200": {
+			protocol: 0x88A8,
+			matches:  []FlowerMatch{FlowerQinQ(100, 200)},
+		},
+		"protocol 802.1ad flower vlan_id 100 vlan_ethtype 802.1Q cvlan_id 200 cvlan_ethtype ip dst_ip 10.0.0.0/24": {
+			protocol: 0x88A8,
+			matches:  []FlowerMatch{FlowerMatchIPv4Dst(ipNet("10.0.0.0/24")), FlowerQinQ(100, 200)},
+		},
+	}
+	if len(tests) != len(fixtures) {
+		t.Fatalf("expected a test for each of the %d fixtures", len(fixtures))
+	}
+
+	for command, testcase := range tests {
+		t.Run(command, func(t *testing.T) {
+			want, ok := fixtures[prefix+command]
+			if !ok {
+				t.Fatalf("missing fixture")
+			}
+			flower, err := NewFlower(testcase.matches...)
+			if err != nil {
+				t.Fatalf("could not build flower: %v", err)
+			}
+			obj := Object{
+				Msg: Msg{Ifindex: 1, Parent: HandleIngress,
+					Info: core.BuildHandle(1, uint32(ntohs(testcase.protocol)))},
+				Attribute: Attribute{Kind: "flower", Flower: flower},
+			}
+			if err := obj.Validate(); err != nil {
+				t.Fatalf("built flower is invalid: %v", err)
+			}
+			data, err := marshalFlower(flower)
+			if err != nil {
+				t.Fatalf("could not marshal flower: %v", err)
+			}
+			ad, err := netlink.NewAttributeDecoder(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[uint16][]byte)
+			for ad.Next() {
+				got[ad.Type()] = ad.Bytes()
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Fatalf("attributes missmatch (-iproute2 +go-tc):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFlowerMatch(t *testing.T) {
+	_, dst, _ := net.ParseCIDR("10.0.0.0/8")
+	tests := map[string]struct {
+		flower  Flower
+		matches []FlowerMatch
+		want    Flower
+		err     error
+	}{
+		"vlan keeps 802.1ad": {
+			flower:  Flower{KeyEthType: uint16Ptr(0x88A8)},
+			matches: []FlowerMatch{FlowerMatchVLAN(10), FlowerMatchIPv4Dst(dst)},
+			want: Flower{KeyEthType: uint16Ptr(0x88A8), KeyVlanID: uint16Ptr(10), KeyVlanEthType: uint16Ptr(0x0800),
+				KeyIPv4Dst: netIPPtr(net.IP{10, 0, 0, 0}), KeyIPv4DstMask: netIPPtr(net.IP{255, 0, 0, 0})},
+		},
+		"mapped IPv4 network": {
+			matches: []FlowerMatch{FlowerMatchIPv4Dst(&net.IPNet{IP: net.ParseIP("10.2.3.4"), Mask: net.CIDRMask(112, 128)})},
+			want: Flower{KeyEthType: uint16Ptr(0x0800), KeyIPv4Dst: netIPPtr(net.IP{10, 2, 0, 0}),
+				KeyIPv4DstMask: netIPPtr(net.IP{255, 255, 0, 0})},
+		},
+		"IPv6 network": {
+			matches: []FlowerMatch{FlowerMatchIPv4Dst(&net.IPNet{IP: net.ParseIP("2001:db8::"), Mask: net.CIDRMask(32, 128)})},
+			err:     ErrInvalidArg,
+		},
+		"nil network": {
+			matches: []FlowerMatch{FlowerMatchIPv4Src(nil)},
+			err:     ErrNoArg,
+		},
+		"other ethernet type": {
+			flower:  Flower{KeyEthType: uint16Ptr(0x86DD)},
+			matches: []FlowerMatch{FlowerMatchIPv4Dst(dst)},
+			err:     ErrInvalidArg,
+		},
+		"tcp and udp": {
+			matches: []FlowerMatch{FlowerMatchTCPDst(80), FlowerMatchUDPDst(53)},
+			err:     ErrInvalidArg,
+		},
+		"vlan id too large": {
+			matches: []FlowerMatch{FlowerMatchVLAN(4096)},
+			err:     ErrInvalidArg,
+		},
+		"other vlan id": {
+			matches: []FlowerMatch{FlowerMatchVLAN(1), FlowerMatchVLAN(2)},
+			err:     ErrInvalidArg,
+		},
+		"qinq then ip": {
+			matches: []FlowerMatch{FlowerQinQ(10, 20), FlowerMatchIPv4Dst(dst)},
+			want: Flower{KeyEthType: uint16Ptr(0x88A8), KeyVlanID: uint16Ptr(10), KeyVlanEthType: uint16Ptr(0x8100),
+				KeyCVlanID: uint16Ptr(20), KeyCVlanEthType: uint16Ptr(0x0800),
+				KeyIPv4Dst: netIPPtr(net.IP{10, 0, 0, 0}), KeyIPv4DstMask: netIPPtr(net.IP{255, 0, 0, 0})},
+		},
+		"qinq of vlan ethernet type": {
+			flower:  Flower{KeyEthType: uint16Ptr(0x88A8), KeyVlanEthType: uint16Ptr(0x86DD)},
+			matches: []FlowerMatch{FlowerQinQ(10, 20)},
+			want: Flower{KeyEthType: uint16Ptr(0x88A8), KeyVlanID: uint16Ptr(10), KeyVlanEthType: uint16Ptr(0x8100),
+				KeyCVlanID: uint16Ptr(20), KeyCVlanEthType: uint16Ptr(0x86DD)},
+		},
+		"qinq of 802.1Q": {
+			matches: []FlowerMatch{FlowerMatchVLAN(10), FlowerQinQ(10, 20)},
+			err:     ErrInvalidArg,
+		},
+		"qinq of other ethernet types": {
+			flower:  Flower{KeyEthType: uint16Ptr(0x0800), KeyCVlanEthType: uint16Ptr(0x86DD)},
+			matches: []FlowerMatch{FlowerQinQ(10, 20)},
+			err:     ErrInvalidArg,
+		},
+		"cvlan id too large": {
+			matches: []FlowerMatch{FlowerQinQ(10, 4096)},
+			err:     ErrInvalidArg,
+		},
+		"other cvlan id": {
+			matches: []FlowerMatch{FlowerQinQ(10, 20), FlowerQinQ(10, 21)},
+			err:     ErrInvalidArg,
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			flower := testcase.flower
+			err := flower.Match(testcase.matches...)
+			if !errors.Is(err, testcase.err) {
+				t.Fatalf("expected error %v but got %v", testcase.err, err)
+			}
+			if testcase.err != nil {
+				return
+			}
+			if diff := cmp.Diff(testcase.want, flower); diff != "" {
+				t.Fatalf("flower missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateFlower(t *testing.T) {
+	ipProto := func(proto uint16) uint32 { return core.BuildFilterInfo(1, proto) }
+	dst := netIPPtr(net.IP{10, 0, 0, 1})
+	tests := map[string]struct {
+		flower Flower
+		info   uint32
+		err    error
+	}{
+		"protocol of filter": {
+			flower: Flower{KeyIPv4Dst: dst, KeyIPProto: uint8Ptr(6), KeyTCPDst: uint16Ptr(22)},
+			info:   ipProto(0x0800),
+		},
+		"ethernet type": {
+			flower: Flower{KeyEthType: uint16Ptr(0x0800), KeyIPv4Dst: dst},
+			info:   ipProto(0x0003),
+		},
+		"vlan ethernet type": {
+			flower: Flower{KeyEthType: uint16Ptr(0x8100), KeyVlanEthType: uint16Ptr(0x86DD), KeyIPProto: uint8Ptr(17),
+				KeyUDPSrc: uint16Ptr(53)},
+		},
+		"ipv4 of all protocols": {
+			flower: Flower{KeyIPv4Dst: dst},
+			info:   ipProto(0x0003),
+			err:    ErrInvalidArg,
+		},
+		"ipv4 of ipv6": {
+			flower: Flower{KeyEthType: uint16Ptr(0x86DD), KeyIPv4Src: dst},
+			err:    ErrInvalidArg,
+		},
+		"port without ip_proto": {
+			flower: Flower{KeyEthType: uint16Ptr(0x0800), KeyTCPSrc: uint16Ptr(22)},
+			err:    ErrInvalidArg,
+		},
+		"port of other ip_proto": {
+			flower: Flower{KeyEthType: uint16Ptr(0x0800), KeyIPProto: uint8Ptr(17), KeySctpDst: uint16Ptr(22)},
+			err:    ErrInvalidArg,
+		},
+		"mask without value": {
+			flower: Flower{KeyEthType: uint16Ptr(0x0800), KeyIPProto: uint8Ptr(6), KeyTCPDstMask: uint16Ptr(0xff00)},
+			err:    ErrInvalidArg,
+		},
+		"ip_proto without ip": {
+			flower: Flower{KeyEthType: uint16Ptr(0x0806), KeyIPProto: uint8Ptr(6)},
+			err:    ErrInvalidArg,
+		},
+		"vlan without 802.1Q": {
+			flower: Flower{KeyVlanID: uint16Ptr(1)},
+			info:   ipProto(0x0800),
+			err:    ErrInvalidArg,
+		},
+		"vlan of 802.1Q": {
+			flower: Flower{KeyEthType: uint16Ptr(0x8100), KeyVlanID: uint16Ptr(1), KeyVlanEthType: uint16Ptr(0x0800),
+				KeyIPv4Dst: dst},
+			info: ipProto(core.EthP8021Q),
+		},
+		"vlan of 802.1Q without ethernet type": {
+			flower: Flower{KeyVlanID: uint16Ptr(1)},
+			info:   ipProto(core.EthP8021Q),
+			err:    ErrInvalidArg,
+		},
+		"ethernet type of other protocol": {
+			flower: Flower{KeyEthType: uint16Ptr(0x86DD)},
+			info:   ipProto(core.EthPIP),
+			err:    ErrInvalidArg,
+		},
+		"vlan id too large": {
+			flower: Flower{KeyEthType: uint16Ptr(0x8100), KeyVlanID: uint16Ptr(4096)},
+			err:    ErrInvalidArg,
+		},
+		"qinq of 802.1ad": {
+			flower: Flower{KeyEthType: uint16Ptr(0x88A8), KeyVlanID: uint16Ptr(1), KeyVlanEthType: uint16Ptr(0x8100),
+				KeyCVlanID: uint16Ptr(2), KeyCVlanEthType: uint16Ptr(0x0800), KeyIPv4Dst: dst},
+			info: ipProto(core.EthP8021AD),
+		},
+		"qinq of all protocols": {
+			flower: Flower{KeyEthType: uint16Ptr(0x88A8), KeyVlanID: uint16Ptr(1), KeyVlanEthType: uint16Ptr(0x8100),
+				KeyCVlanID: uint16Ptr(2)},
+			info: ipProto(core.EthPAll),
+		},
+		// The kernel would accept the filter, but ignore the customer VLAN.
+		"cvlan without inner vlan ethernet type": {
+			flower: Flower{KeyEthType: uint16Ptr(0x88A8), KeyVlanID: uint16Ptr(1), KeyCVlanID: uint16Ptr(2)},
+			info:   ipProto(core.EthP8021AD),
+			err:    ErrInvalidArg,
+		},
+		"cvlan of ip": {
+			flower: Flower{KeyEthType: uint16Ptr(0x88A8), KeyVlanID: uint16Ptr(1), KeyVlanEthType: uint16Ptr(0x0800),
+				KeyCVlanID: uint16Ptr(2)},
+			info: ipProto(core.EthP8021AD),
+			err:  ErrInvalidArg,
+		},
+		"cvlan without vlan": {
+			flower: Flower{KeyEthType: uint16Ptr(0x0800), KeyCVlanPrio: uint8Ptr(3)},
+			info:   ipProto(core.EthPIP),
+			err:    ErrInvalidArg,
+		},
+		"qinq of other protocol": {
+			flower: Flower{KeyEthType: uint16Ptr(0x88A8), KeyVlanID: uint16Ptr(1), KeyVlanEthType: uint16Ptr(0x8100),
+				KeyCVlanID: uint16Ptr(2)},
+			info: ipProto(core.EthP8021Q),
+			err:  ErrInvalidArg,
+		},
+		"cvlan id too large": {
+			flower: Flower{KeyEthType: uint16Ptr(0x88A8), KeyVlanID: uint16Ptr(1), KeyVlanEthType: uint16Ptr(0x8100),
+				KeyCVlanID: uint16Ptr(4096)},
+			err: ErrInvalidArg,
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			obj := Object{
+				Msg:       Msg{Ifindex: 1, Parent: HandleIngress, Info: testcase.info},
+				Attribute: Attribute{Kind: "flower", Flower: &testcase.flower},
+			}
+			if err := obj.Validate(); !errors.Is(err, testcase.err) {
+				t.Fatalf("expected error %v but got %v", testcase.err, err)
+			}
+		})
+	}
+}
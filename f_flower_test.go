@@ -86,7 +86,6 @@ func TestFlower(t *testing.T) {
 			KeyEncIPTOSMask:      uint8Ptr(50),
 			KeyEncIPTTL:          uint8Ptr(51),
 			KeyEncIPTTLMask:      uint8Ptr(52),
-			InHwCount:            uint32Ptr(53),
 			Flags:                uint32Ptr(54),
 			KeyPortSrcMin:        uint16Ptr(55),
 			KeyPortSrcMax:        uint16Ptr(56),
@@ -137,6 +136,31 @@ func TestFlower(t *testing.T) {
 		})
 	}
 
+	t.Run("InHwCount", func(t *testing.T) {
+		data, err := marshalFlower(&Flower{ClassID: uint32Ptr(42), InHwCount: uint32Ptr(53)})
+		if err != nil {
+			t.Fatalf("could not marshal flower: %v", err)
+		}
+		val := Flower{}
+		if err := unmarshalFlower(data, &val); err != nil {
+			t.Fatalf("could not unmarshal flower: %v", err)
+		}
+		if val.InHwCount != nil {
+			t.Fatalf("InHwCount must not be sent to the kernel")
+		}
+
+		// InHwCount is only reported by the kernel in dumps.
+		inHwCount := make([]byte, 4)
+		nativeEndian.PutUint32(inHwCount, 53)
+		data = injectAttribute(t, data, inHwCount, tcaFlowerInHwCount)
+		if err := unmarshalFlower(data, &val); err != nil {
+			t.Fatalf("could not unmarshal flower: %v", err)
+		}
+		attr := Attribute{Kind: "flower", Flower: &val}
+		if attr.InHwCount() != 53 {
+			t.Fatalf("unexpected InHwCount: %d", attr.InHwCount())
+		}
+	})
 	t.Run("nil", func(t *testing.T) {
 		_, err := marshalFlower(nil)
 		if !errors.Is(err, ErrNoArg) {
@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaFwUnspec = iota
@@ -17,16 +13,16 @@ const (
 
 // Fw contains attributes of the fw discipline
 type Fw struct {
-	ClassID *uint32
-	Police  *Police
-	InDev   *string
-	Mask    *uint32
-	Actions *[]*Action
+	ClassID *uint32    `json:"class_id,omitempty"`
+	Police  *Police    `json:"police,omitempty"`
+	InDev   *string    `json:"in_dev,omitempty"`
+	Mask    *uint32    `json:"mask,omitempty"`
+	Actions *[]*Action `json:"actions,omitempty"`
 }
 
 // unmarshalFw parses the Fw-encoded data and stores the result in the value pointed to by info.
 func unmarshalFw(data []byte, info *Fw) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -50,7 +46,7 @@ func unmarshalFw(data []byte, info *Fw) error {
 			multiError = concatError(multiError, err)
 			info.Actions = actions
 		default:
-			return fmt.Errorf("unmarshalFw()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("fw", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -80,7 +76,7 @@ func marshalFw(info *Fw) ([]byte, error) {
 		multiError = concatError(multiError, err)
 		options = append(options, tcOption{Interpretation: vtBytes, Type: tcaFwPolice, Data: data})
 	}
-	if info.Actions != nil {
+	if hasActions(info.Actions) {
 		data, err := marshalActions(0, *info.Actions)
 		multiError = concatError(multiError, err)
 		options = append(options, tcOption{Interpretation: vtBytes, Type: tcaFwAct, Data: data})
@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaMatchallUnspec = iota
@@ -17,14 +13,14 @@ const (
 
 // Matchall contains attributes of the matchall discipline
 type Matchall struct {
-	ClassID *uint32
-	Actions *[]*Action
-	Flags   *uint32
-	Pcnt    *uint64
+	ClassID *uint32    `json:"class_id,omitempty"`
+	Actions *[]*Action `json:"actions,omitempty"`
+	Flags   *uint32    `json:"flags,omitempty"`
+	Pcnt    *uint64    `json:"pcnt,omitempty"`
 }
 
 func unmarshalMatchall(data []byte, info *Matchall) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -45,7 +41,7 @@ func unmarshalMatchall(data []byte, info *Matchall) error {
 		case tcaMatchallPad:
 			// padding does not contain data, we just skip it
 		default:
-			return fmt.Errorf("unmarshalMatchall()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("matchall", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -65,7 +61,7 @@ func marshalMatchall(info *Matchall) ([]byte, error) {
 	if info.ClassID != nil {
 		options = append(options, tcOption{Interpretation: vtUint32, Type: tcaMatchallClassID, Data: uint32Value(info.ClassID)})
 	}
-	if info.Actions != nil {
+	if hasActions(info.Actions) {
 		data, err := marshalActions(0, *info.Actions)
 		multiError = concatError(multiError, err)
 		options = append(options, tcOption{Interpretation: vtBytes, Type: tcaMatchallAct, Data: data})
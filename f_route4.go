@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaRoute4Unspec = iota
@@ -18,16 +14,16 @@ const (
 
 // Route4 contains attributes of the route discipline
 type Route4 struct {
-	ClassID *uint32
-	To      *uint32
-	From    *uint32
-	IIf     *uint32
-	Actions *[]*Action
+	ClassID *uint32    `json:"class_id,omitempty"`
+	To      *uint32    `json:"to,omitempty"`
+	From    *uint32    `json:"from,omitempty"`
+	IIf     *uint32    `json:"iif,omitempty"`
+	Actions *[]*Action `json:"actions,omitempty"`
 }
 
 // unmarshalRoute4 parses the Route4-encoded data and stores the result in the value pointed to by info.
 func unmarshalRoute4(data []byte, info *Route4) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -48,7 +44,7 @@ func unmarshalRoute4(data []byte, info *Route4) error {
 			multiError = concatError(multiError, err)
 			info.Actions = actions
 		default:
-			return fmt.Errorf("unmarshalRoute4()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("route4", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -76,7 +72,7 @@ func marshalRoute4(info *Route4) ([]byte, error) {
 	if info.IIf != nil {
 		options = append(options, tcOption{Interpretation: vtUint32, Type: tcaRoute4IIf, Data: uint32Value(info.IIf)})
 	}
-	if info.Actions != nil {
+	if hasActions(info.Actions) {
 		data, err := marshalActions(0, *info.Actions)
 		if err != nil {
 			return []byte{}, err
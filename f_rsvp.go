@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaRsvpUnspec = iota
@@ -18,17 +14,17 @@ const (
 
 // Rsvp contains attributes of the rsvp discipline
 type Rsvp struct {
-	ClassID *uint32
-	Dst     *[]byte
-	Src     *[]byte
-	PInfo   *RsvpPInfo
-	Police  *Police
-	Actions *[]*Action
+	ClassID *uint32    `json:"class_id,omitempty"`
+	Dst     *[]byte    `json:"dst,omitempty"`
+	Src     *[]byte    `json:"src,omitempty"`
+	PInfo   *RsvpPInfo `json:"p_info,omitempty"`
+	Police  *Police    `json:"police,omitempty"`
+	Actions *[]*Action `json:"actions,omitempty"`
 }
 
 // unmarshalRsvp parses the Rsvp-encoded data and stores the result in the value pointed to by info.
 func unmarshalRsvp(data []byte, info *Rsvp) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -57,7 +53,7 @@ func unmarshalRsvp(data []byte, info *Rsvp) error {
 			multiError = concatError(multiError, err)
 			info.Actions = actions
 		default:
-			return fmt.Errorf("unmarshalRsvp()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("rsvp", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -92,7 +88,7 @@ func marshalRsvp(info *Rsvp) ([]byte, error) {
 		multiError = concatError(multiError, err)
 		options = append(options, tcOption{Interpretation: vtBytes, Type: tcaRsvpPolice, Data: data})
 	}
-	if info.Actions != nil {
+	if hasActions(info.Actions) {
 		data, err := marshalActions(0, *info.Actions)
 		multiError = concatError(multiError, err)
 		options = append(options, tcOption{Interpretation: vtBytes, Type: tcaRsvpAct, Data: data})
@@ -107,17 +103,17 @@ func marshalRsvp(info *Rsvp) ([]byte, error) {
 
 // RsvpPInfo from include/uapi/linux/pkt_sched.h
 type RsvpPInfo struct {
-	Dpi       RsvpGpi
-	Spi       RsvpGpi
-	Protocol  uint8
-	TunnelID  uint8
-	TunnelHdr uint8
-	Pad       uint8
+	Dpi       RsvpGpi `json:"dpi,omitempty"`
+	Spi       RsvpGpi `json:"spi,omitempty"`
+	Protocol  uint8   `json:"protocol,omitempty"`
+	TunnelID  uint8   `json:"tunnel_id,omitempty"`
+	TunnelHdr uint8   `json:"tunnel_hdr,omitempty"`
+	Pad       uint8   `json:"pad,omitempty"`
 }
 
 // RsvpGpi from include/uapi/linux/pkt_sched.h
 type RsvpGpi struct {
-	Key    uint32
-	Mask   uint32
-	Offset uint32
+	Key    uint32 `json:"key,omitempty"`
+	Mask   uint32 `json:"mask,omitempty"`
+	Offset uint32 `json:"offset,omitempty"`
 }
@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaTcIndexUnspec = iota
@@ -19,12 +15,12 @@ const (
 
 // TcIndex contains attributes of the tcIndex discipline
 type TcIndex struct {
-	Hash        *uint32
-	Mask        *uint16
-	Shift       *uint32
-	FallThrough *uint32
-	ClassID     *uint32
-	Actions     *[]*Action
+	Hash        *uint32    `json:"hash,omitempty"`
+	Mask        *uint16    `json:"mask,omitempty"`
+	Shift       *uint32    `json:"shift,omitempty"`
+	FallThrough *uint32    `json:"fall_through,omitempty"`
+	ClassID     *uint32    `json:"class_id,omitempty"`
+	Actions     *[]*Action `json:"actions,omitempty"`
 }
 
 // marshalTcIndex returns the binary encoding of TcIndex
@@ -51,7 +47,7 @@ func marshalTcIndex(info *TcIndex) ([]byte, error) {
 	if info.ClassID != nil {
 		options = append(options, tcOption{Interpretation: vtUint32, Type: tcaTcIndexClassID, Data: uint32Value(info.ClassID)})
 	}
-	if info.Actions != nil {
+	if hasActions(info.Actions) {
 		data, err := marshalActions(0, *info.Actions)
 		if err != nil {
 			return []byte{}, err
@@ -63,7 +59,7 @@ func marshalTcIndex(info *TcIndex) ([]byte, error) {
 
 // unmarshalTcIndex parses the TcIndex-encoded data and stores the result in the value pointed to by info.
 func unmarshalTcIndex(data []byte, info *TcIndex) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -86,7 +82,7 @@ func unmarshalTcIndex(data []byte, info *TcIndex) error {
 			multiError = concatError(multiError, err)
 			info.Actions = actions
 		default:
-			return fmt.Errorf("unmarshalTcIndex()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("tcindex", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
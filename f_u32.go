@@ -1,11 +1,9 @@
 package tc
 
 import (
-	"bytes"
 	"encoding/binary"
 	"fmt"
-
-	"github.com/mdlayher/netlink"
+	"io"
 )
 
 const (
@@ -26,17 +24,56 @@ const (
 
 // U32 contains attributes of the u32 discipline
 type U32 struct {
-	ClassID *uint32
-	Hash    *uint32
-	Link    *uint32
-	Divisor *uint32
-	Sel     *U32Sel
-	InDev   *string
-	Pcnt    *uint64
-	Mark    *U32Mark
-	Flags   *uint32
-	Police  *Police
-	Actions *[]*Action
+	ClassID *uint32    `json:"class_id,omitempty"`
+	Hash    *uint32    `json:"hash,omitempty"`
+	Link    *uint32    `json:"link,omitempty"`
+	Divisor *uint32    `json:"divisor,omitempty"`
+	Sel     *U32Sel    `json:"sel,omitempty"`
+	InDev   *string    `json:"in_dev,omitempty"`
+	Pcnt    *uint64    `json:"pcnt,omitempty"`
+	Mark    *U32Mark   `json:"mark,omitempty"`
+	Flags   *uint32    `json:"flags,omitempty"`
+	Police  *Police    `json:"police,omitempty"`
+	Actions *[]*Action `json:"actions,omitempty"`
+}
+
+// U32Handle returns the handle of a u32 filter or hash table, like
+// `ht 1:f:` or `link 2:` of tc. It holds the 12 bit htid of the hash table,
+// the 8 bit bucket of the hash table and the 12 bit node within the bucket.
+// The hash table of the root is 0x800.
+func U32Handle(htid uint16, bucket uint8, node uint16) uint32 {
+	return (uint32(htid)<<20)&u32HtidMask | uint32(bucket)<<12 | uint32(node)&u32NodeMask
+}
+
+// SplitU32Handle extracts htid, bucket and node from a u32 handle.
+func SplitU32Handle(handle uint32) (htid uint16, bucket uint8, node uint16) {
+	return uint16(handle >> 20), uint8(handle >> 12), uint16(handle & u32NodeMask)
+}
+
+// Masks of the u32 handle from include/uapi/linux/pkt_cls.h
+const (
+	u32HtidMask uint32 = 0xFFF00000
+	u32NodeMask uint32 = 0x00000FFF
+)
+
+// validateU32Handles checks, that Hash and Link of info refer to hash tables.
+// linux/net/sched/cls_u32.c rejects a Link with bucket or node and uses the
+// htid and the bucket of Hash only.
+func validateU32Handles(info *U32) error {
+	var multiError error
+	if info.Hash != nil {
+		if htid, _, node := SplitU32Handle(*info.Hash); htid == 0 || node != 0 {
+			multiError = concatError(multiError, fmt.Errorf("U32: Hash 0x%x is no bucket of a hash table: %w",
+				*info.Hash, ErrInvalidArg))
+		}
+	}
+	if info.Link != nil && *info.Link != 0 {
+		if htid, bucket, node := SplitU32Handle(*info.Link); htid == 0 || bucket != 0 || node != 0 {
+			multiError = concatError(multiError, fmt.Errorf("U32: Link 0x%x is no hash table: %w",
+				*info.Link, ErrInvalidArg))
+		}
+	}
+	return multiError
 }
 
 // marshalU32 returns the binary encoding of U32
@@ -48,7 +85,7 @@ func marshalU32(info *U32) ([]byte, error) {
 	}
 
 	// TODO: improve logic and check combinations
-	var multiError error
+	multiError := validateU32Handles(info)
 
 	if info.Sel != nil {
 		data, err := validateU32SelOptions(info.Sel)
@@ -77,7 +114,7 @@ func marshalU32(info *U32) ([]byte, error) {
 	if info.Flags != nil {
 		options = append(options, tcOption{Interpretation: vtUint32, Type: tcaU32Flags, Data: uint32Value(info.Flags)})
 	}
-	if info.Actions != nil {
+	if hasActions(info.Actions) {
 		data, err := marshalActions(0, *info.Actions)
 		multiError = concatError(multiError, err)
 		options = append(options, tcOption{Interpretation: vtBytes, Type: tcaU32Act, Data: data})
@@ -107,7 +144,7 @@ func marshalU32(info *U32) ([]byte, error) {
 
 // unmarshalU32 parses the U32-encoded data and stores the result in the value pointed to by info.
 func unmarshalU32(data []byte, info *U32) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -151,7 +188,7 @@ func unmarshalU32(data []byte, info *U32) error {
 		case tcaU32Pad:
 			// padding does not contain data, we just skip it
 		default:
-			return fmt.Errorf("unmarshalU32()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("u32", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -159,80 +196,111 @@ func unmarshalU32(data []byte, info *U32) error {
 
 // U32Sel from include/uapi/linux/pkt_sched.h
 type U32Sel struct {
-	Flags    uint8
-	Offshift uint8
-	NKeys    uint8
-	OffMask  uint16
-	Off      uint16
-	Offoff   uint16
-	Hoff     uint16
-	Hmask    uint32
-	Keys     []U32Key
+	Flags    uint8    `json:"flags,omitempty"`
+	Offshift uint8    `json:"offshift,omitempty"`
+	NKeys    uint8    `json:"n_keys,omitempty"`
+	OffMask  uint16   `json:"off_mask,omitempty"`
+	Off      uint16   `json:"off,omitempty"`
+	Offoff   uint16   `json:"offoff,omitempty"`
+	Hoff     uint16   `json:"hoff,omitempty"`
+	Hmask    uint32   `json:"hmask,omitempty"`
+	Keys     []U32Key `json:"keys,omitempty"`
 }
 
+// Flags of U32Sel from include/uapi/linux/pkt_cls.h
+const (
+	U32Terminal  = 0x1
+	U32Offset    = 0x2
+	U32VarOffset = 0x4
+	U32Eat       = 0x8
+)
+
+// u32SelLen is the size of tc_u32_sel without keys and u32KeyLen the size
+// of a single tc_u32_key.
+const (
+	u32SelLen = 16
+	u32KeyLen = 16
+)
+
+// u32MaxKeys is the number of keys, iproute2/tc/f_u32.c can handle in a
+// single selector.
+const u32MaxKeys = 128
+
+// validateU32SelOptions returns the encoding of tc_u32_sel. If NKeys is not
+// set, it is derived from the number of Keys. Like the kernel, the fields
+// off, offoff and hoff are in host byte order, while offmask and hmask are
+// in network byte order.
 func validateU32SelOptions(info *U32Sel) ([]byte, error) {
-	if int(info.NKeys) != len(info.Keys) {
+	nkeys := int(info.NKeys)
+	if nkeys == 0 {
+		nkeys = len(info.Keys)
+	}
+	if nkeys != len(info.Keys) {
 		return []byte{}, fmt.Errorf("number of expected keys matches not number of provided keys: %w", ErrInvalidArg)
 	}
+	if nkeys > u32MaxKeys {
+		return []byte{}, fmt.Errorf("%d keys exceed the maximum of %d keys: %w", nkeys, u32MaxKeys, ErrInvalidArg)
+	}
 
-	buf := new(bytes.Buffer)
-	binary.Write(buf, nativeEndian, info.Flags)
-	binary.Write(buf, nativeEndian, info.Offshift)
-	binary.Write(buf, nativeEndian, info.NKeys)
-	binary.Write(buf, binary.BigEndian, info.OffMask)
-	binary.Write(buf, nativeEndian, info.Off)
-	binary.Write(buf, nativeEndian, info.Offoff)
-	binary.Write(buf, nativeEndian, info.Hoff)
-	binary.Write(buf, binary.BigEndian, info.Hmask)
-	if info.NKeys != 0 {
-		buf.WriteByte(0x00)
-	}
-	for _, v := range info.Keys {
-		data, err := marshalStruct(v)
-		if err != nil {
-			return []byte{}, err
-		}
-		buf.Write(data)
+	data := make([]byte, u32SelLen+nkeys*u32KeyLen)
+	data[0] = info.Flags
+	data[1] = info.Offshift
+	data[2] = uint8(nkeys)
+	// data[3] is the padding to align offmask
+	binary.BigEndian.PutUint16(data[4:], info.OffMask)
+	nativeEndian.PutUint16(data[6:], info.Off)
+	nativeEndian.PutUint16(data[8:], info.Offoff)
+	nativeEndian.PutUint16(data[10:], info.Hoff)
+	binary.BigEndian.PutUint32(data[12:], info.Hmask)
+	for i, key := range info.Keys {
+		key.encode(data[u32SelLen+i*u32KeyLen:])
 	}
-	return buf.Bytes(), nil
+	return data, nil
 }
 
+// extractU32Sel decodes tc_u32_sel. Truncated data returns an error wrapping
+// io.ErrUnexpectedEOF and an invalid number of keys an error wrapping
+// ErrInvalidArg.
 func extractU32Sel(data []byte, info *U32Sel) error {
-	if len(data) < 15 {
-		return fmt.Errorf("not enough bytes for U32Sel")
+	if len(data) < u32SelLen {
+		return fmt.Errorf("U32Sel needs %d bytes, but got %d: %w", u32SelLen, len(data), io.ErrUnexpectedEOF)
 	}
 	info.Flags = data[0]
 	info.Offshift = data[1]
 	info.NKeys = data[2]
-	info.OffMask = binary.BigEndian.Uint16(data[3:5])
-	info.Off = nativeEndian.Uint16(data[5:7])
-	info.Offoff = nativeEndian.Uint16(data[7:9])
-	info.Hoff = nativeEndian.Uint16(data[9:11])
-	info.Hmask = binary.BigEndian.Uint32(data[11:15])
-	if len(data) < int(info.NKeys)*16+16 {
-		return fmt.Errorf("not enough bytes for U32Keys")
-	}
-	for i := 0; i < int(info.NKeys); i++ {
-		key := &U32Key{}
-		if err := unmarshalStruct(data[16+i*16:16+(i+1)*16], key); err != nil {
-			return err
-		}
-		info.Keys = append(info.Keys, *key)
+	info.OffMask = binary.BigEndian.Uint16(data[4:6])
+	info.Off = nativeEndian.Uint16(data[6:8])
+	info.Offoff = nativeEndian.Uint16(data[8:10])
+	info.Hoff = nativeEndian.Uint16(data[10:12])
+	info.Hmask = binary.BigEndian.Uint32(data[12:16])
+	if info.NKeys > u32MaxKeys {
+		return fmt.Errorf("U32Sel with %d keys exceeds the maximum of %d keys: %w",
+			info.NKeys, u32MaxKeys, ErrInvalidArg)
+	}
+	if need := u32SelLen + int(info.NKeys)*u32KeyLen; len(data) < need {
+		return fmt.Errorf("U32Sel with %d keys needs %d bytes, but got %d: %w",
+			info.NKeys, need, len(data), io.ErrUnexpectedEOF)
+	}
+	if info.NKeys > 0 {
+		info.Keys = make([]U32Key, info.NKeys)
+	}
+	for i := range info.Keys {
+		info.Keys[i].decode(data[u32SelLen+i*u32KeyLen:])
 	}
 	return nil
 }
 
 // U32Mark from include/uapi/linux/pkt_sched.h
 type U32Mark struct {
-	Val     uint32
-	Mask    uint32
-	Success uint32
+	Val     uint32 `json:"val,omitempty"`
+	Mask    uint32 `json:"mask,omitempty"`
+	Success uint32 `json:"success,omitempty"`
 }
 
 // U32Key from include/uapi/linux/pkt_sched.h
 type U32Key struct {
-	Mask    uint32
-	Val     uint32
-	Off     uint32
-	OffMask uint32
+	Mask    uint32 `json:"mask,omitempty"`
+	Val     uint32 `json:"val,omitempty"`
+	Off     uint32 `json:"off,omitempty"`
+	OffMask uint32 `json:"off_mask,omitempty"`
 }
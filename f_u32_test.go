@@ -1,7 +1,10 @@
 package tc
 
 import (
+	"encoding/binary"
 	"errors"
+	"io"
+	"math/rand"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -21,13 +24,16 @@ func TestU32(t *testing.T) {
 		"simple": {val: U32{
 			ClassID: uint32Ptr(0xFFFF),
 			Mark:    &U32Mark{Val: 0x55, Mask: 0xAA, Success: 0x1},
-			Hash:    uint32Ptr(1234), Pcnt: uint64Ptr(4321), InDev: stringPtr("foobar"),
+			Hash:    uint32Ptr(U32Handle(0x1, 0x4, 0x0)), Pcnt: uint64Ptr(4321), InDev: stringPtr("foobar"),
 		}},
-		"divisor": {val: U32{Divisor: uint32Ptr(1), Link: uint32Ptr(42)}},
+		"divisor":        {val: U32{Divisor: uint32Ptr(1), Link: uint32Ptr(U32Handle(0x2, 0x0, 0x0))}},
+		"bucket as link": {val: U32{Link: uint32Ptr(U32Handle(0x2, 0x1, 0x0))}, err1: ErrInvalidArg},
+		"node as hash":   {val: U32{Hash: uint32Ptr(U32Handle(0x2, 0x1, 0x800))}, err1: ErrInvalidArg},
+		"zero":           {val: U32{ClassID: uint32Ptr(0), Flags: uint32Ptr(0), Divisor: uint32Ptr(0)}},
 		"extended": {val: U32{
 			ClassID: uint32Ptr(0xFFFF),
 			Mark:    &U32Mark{Val: 0x55, Mask: 0xAA, Success: 0x1},
-			Police:  &Police{AvRate: uint32Ptr(1337), Result: uint32Ptr(12)},
+			Police:  &Police{AvRate: uint32Ptr(1337), Result: uint32Ptr(12), Conform: policyActionPtr(12)},
 		}},
 		"policy": {val: U32{
 			Sel: &U32Sel{
@@ -45,6 +51,7 @@ func TestU32(t *testing.T) {
 						Rate:      0x1e848,
 					},
 				},
+				Exceed: policyActionPtr(PolicyReclassify),
 			},
 		}},
 		"multiple Keys": {val: U32{
@@ -86,6 +93,21 @@ func TestU32(t *testing.T) {
 			}
 		})
 	}
+	t.Run("NKeys from Keys", func(t *testing.T) {
+		keys := []U32Key{{Mask: 0xff, Val: 0x6, Off: 8}, {Mask: 0xffff, Val: 0x50, Off: 20}}
+		data, err := marshalU32(&U32{ClassID: uint32Ptr(0x10010), Sel: &U32Sel{Flags: U32Terminal, Keys: keys}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		val := U32{}
+		if err := unmarshalU32(data, &val); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := U32{ClassID: uint32Ptr(0x10010), Sel: &U32Sel{Flags: U32Terminal, NKeys: 2, Keys: keys}}
+		if diff := cmp.Diff(want, val); diff != "" {
+			t.Fatalf("U32 missmatch (-want +got):\n%s", diff)
+		}
+	})
 	t.Run("nil", func(t *testing.T) {
 		_, err := marshalU32(nil)
 		if !errors.Is(err, ErrNoArg) {
@@ -93,3 +115,169 @@ func TestU32(t *testing.T) {
 		}
 	})
 }
+
+func TestU32Handle(t *testing.T) {
+	t.Run("split", func(t *testing.T) {
+		handle := U32Handle(0xabc, 0xde, 0xf01)
+		if handle != 0xabcdef01 {
+			t.Fatalf("expected 0xabcdef01 but got 0x%x", handle)
+		}
+		if htid, bucket, node := SplitU32Handle(handle); htid != 0xabc || bucket != 0xde || node != 0xf01 {
+			t.Fatalf("expected abc:de:f01 but got %x:%x:%x", htid, bucket, node)
+		}
+		if handle := U32Handle(0x1abc, 0x0, 0x1f01); handle != 0xabc00f01 {
+			t.Fatalf("expected 0xabc00f01 but got 0x%x", handle)
+		}
+	})
+
+	// Two level hashing on the destination address, like the hashing
+	// example of tc-u32(8):
+	//  tc filter add dev eth0 parent 1: prio 1 handle 1: protocol ip u32 divisor 256
+	//  tc filter add dev eth0 parent 1: prio 1 handle 2: protocol ip u32 divisor 256
+	//  tc filter add dev eth0 parent 1: prio 1 protocol ip u32 ht 800:: \
+	//    match ip dst 10.0.0.0/16 hashkey mask 0x0000ff00 at 16 link 1:
+	//  tc filter add dev eth0 parent 1: prio 1 protocol ip u32 ht 1:1: \
+	//    match ip dst 10.0.1.0/24 hashkey mask 0x000000ff at 16 link 2:
+	//  tc filter add dev eth0 parent 1: prio 1 protocol ip u32 ht 2:f: \
+	//    match ip dst 10.0.1.15/32 flowid 1:10
+	// The kernel assigns the node 800 to the first filter of a bucket.
+	root := U32Handle(0x800, 0x0, 0x0)
+	level1 := U32Handle(0x1, 0x0, 0x0)
+	level2 := U32Handle(0x2, 0x0, 0x0)
+	filters := []struct {
+		handle uint32
+		u32    U32
+		want   string
+	}{
+		{handle: level1, u32: U32{Divisor: uint32Ptr(256)}, want: "1:"},
+		{handle: level2, u32: U32{Divisor: uint32Ptr(256)}, want: "2:"},
+		{handle: U32Handle(0x800, 0x0, 0x800), u32: U32{Hash: uint32Ptr(root), Link: uint32Ptr(level1),
+			Sel: &U32Sel{Hoff: 16, Hmask: 0x0000ff00, Keys: []U32Key{{Mask: 0xffff0000, Val: 0x0a000000, Off: 16}}}},
+			want: "800::800"},
+		{handle: U32Handle(0x1, 0x1, 0x800), u32: U32{Hash: uint32Ptr(U32Handle(0x1, 0x1, 0x0)), Link: uint32Ptr(level2),
+			Sel: &U32Sel{Hoff: 16, Hmask: 0x000000ff, Keys: []U32Key{{Mask: 0xffffff00, Val: 0x0a000100, Off: 16}}}},
+			want: "1:1:800"},
+		{handle: U32Handle(0x2, 0xf, 0x800), u32: U32{Hash: uint32Ptr(U32Handle(0x2, 0xf, 0x0)), ClassID: uint32Ptr(0x10010),
+			Sel: &U32Sel{Flags: U32Terminal, Keys: []U32Key{{Mask: 0xffffffff, Val: 0x0a00010f, Off: 16}}}},
+			want: "2:f:800"},
+	}
+	for _, filter := range filters {
+		if got := formatU32Handle(filter.handle); got != filter.want {
+			t.Fatalf("expected handle %s but got %s", filter.want, got)
+		}
+		if _, err := marshalU32(&filter.u32); err != nil {
+			t.Fatalf("%s: unexpected error: %v", filter.want, err)
+		}
+	}
+}
+
+func TestU32Sel(t *testing.T) {
+	key := U32Key{Mask: 0xffffff00, Val: 0x0a000000, Off: 16}
+	keyData, err := marshalStruct(key)
+	if err != nil {
+		t.Fatalf("could not marshal key: %v", err)
+	}
+
+	// sel holds a tc_u32_sel in the layout of include/uapi/linux/pkt_sched.h.
+	sel := make([]byte, u32SelLen)
+	sel[0], sel[1], sel[2] = 0x1, 0x2, 0x1
+	binary.BigEndian.PutUint16(sel[4:], 0x00ff)
+	nativeEndian.PutUint16(sel[6:], 0x10)
+	nativeEndian.PutUint16(sel[8:], 0x20)
+	nativeEndian.PutUint16(sel[10:], 0x30)
+	binary.BigEndian.PutUint32(sel[12:], 0xff00)
+	sel = append(sel, keyData...)
+	want := U32Sel{Flags: 0x1, Offshift: 0x2, NKeys: 1, OffMask: 0x00ff, Off: 0x10, Offoff: 0x20,
+		Hoff: 0x30, Hmask: 0xff00, Keys: []U32Key{key}}
+
+	t.Run("layout", func(t *testing.T) {
+		data, err := validateU32SelOptions(&want)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if diff := cmp.Diff(sel, data); diff != "" {
+			t.Fatalf("encoding missmatch (-want +got):\n%s", diff)
+		}
+		got := U32Sel{}
+		if err := extractU32Sel(sel, &got); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Fatalf("U32Sel missmatch (-want +got):\n%s", diff)
+		}
+	})
+	t.Run("NKeys from Keys", func(t *testing.T) {
+		info := want
+		info.NKeys = 0
+		data, err := validateU32SelOptions(&info)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if diff := cmp.Diff(sel, data); diff != "" {
+			t.Fatalf("encoding missmatch (-want +got):\n%s", diff)
+		}
+		if info.NKeys != 0 {
+			t.Fatalf("NKeys of the argument was modified")
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		tests := map[string]U32Sel{
+			"NKeys mismatch": {NKeys: 2, Keys: []U32Key{key}},
+			"too many keys":  {Keys: make([]U32Key, u32MaxKeys+1)},
+		}
+		for name, info := range tests {
+			if _, err := validateU32SelOptions(&info); !errors.Is(err, ErrInvalidArg) {
+				t.Fatalf("%s: expected ErrInvalidArg but got %v", name, err)
+			}
+		}
+	})
+	t.Run("corrupt", func(t *testing.T) {
+		tooMany := append([]byte{}, sel...)
+		tooMany[2] = 0xff
+		tests := map[string]struct {
+			data []byte
+			err  error
+		}{
+			"empty":         {data: []byte{}, err: io.ErrUnexpectedEOF},
+			"truncated sel": {data: sel[:u32SelLen-1], err: io.ErrUnexpectedEOF},
+			"truncated key": {data: sel[:len(sel)-1], err: io.ErrUnexpectedEOF},
+			"too many keys": {data: tooMany, err: ErrInvalidArg},
+		}
+		for name, testcase := range tests {
+			if err := extractU32Sel(testcase.data, &U32Sel{}); !errors.Is(err, testcase.err) {
+				t.Fatalf("%s: expected %v but got %v", name, testcase.err, err)
+			}
+		}
+	})
+	t.Run("random", func(t *testing.T) {
+		rng := rand.New(rand.NewSource(42))
+		for i := 0; i < 10000; i++ {
+			data := make([]byte, rng.Intn(2*len(sel)))
+			rng.Read(data)
+			// Only check, that random data does not panic.
+			_ = extractU32Sel(data, &U32Sel{})
+		}
+	})
+}
+
+func BenchmarkUnmarshalU32(b *testing.B) {
+	keys := make([]U32Key, 8)
+	for i := range keys {
+		keys[i] = U32Key{Mask: 0xffffff00, Val: uint32(i), Off: 12, OffMask: 0}
+	}
+	data, err := marshalU32(&U32{
+		ClassID: uint32Ptr(0x10001),
+		Sel:     &U32Sel{Flags: U32Terminal, Keys: keys},
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var info U32
+		if err := unmarshalU32(data, &info); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
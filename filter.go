@@ -3,6 +3,7 @@ package tc
 import (
 	"errors"
 	"fmt"
+	"syscall"
 
 	"github.com/florianl/go-tc/internal/unix"
 	"github.com/mdlayher/netlink"
@@ -23,11 +24,17 @@ func (f *Filter) Add(info *Object) error {
 	if info == nil {
 		return ErrNoArg
 	}
+	if err := f.validateFilter(info); err != nil {
+		return err
+	}
 	options, err := validateFilterObject(unix.RTM_NEWTFILTER, info)
 	if err != nil {
 		return err
 	}
-	return f.action(unix.RTM_NEWTFILTER, netlink.Create|netlink.Excl, &info.Msg, options)
+	if err := f.action(unix.RTM_NEWTFILTER, netlink.Create|netlink.Excl, &info.Msg, options); err != nil {
+		return err
+	}
+	return f.verifyWrite(unix.RTM_GETTFILTER, info)
 }
 
 // Replace add/remove a filter. If the node does not exist yet it is created
@@ -35,11 +42,17 @@ func (f *Filter) Replace(info *Object) error {
 	if info == nil {
 		return ErrNoArg
 	}
+	if err := f.validateFilter(info); err != nil {
+		return err
+	}
 	options, err := validateFilterObject(unix.RTM_NEWTFILTER, info)
 	if err != nil {
 		return err
 	}
-	return f.action(unix.RTM_NEWTFILTER, netlink.Create, &info.Msg, options)
+	if err := f.action(unix.RTM_NEWTFILTER, netlink.Create, &info.Msg, options); err != nil {
+		return err
+	}
+	return f.verifyWrite(unix.RTM_GETTFILTER, info)
 }
 
 // Delete removes a filter
@@ -47,13 +60,44 @@ func (f *Filter) Delete(info *Object) error {
 	if info == nil {
 		return ErrNoArg
 	}
-	options, err := validateFilterObject(unix.RTM_DELTFILTER, info)
+	options, err := validateDeleteObject(unix.RTM_DELTFILTER, info)
 	if err != nil {
 		return err
 	}
 	return f.action(unix.RTM_DELTFILTER, netlink.HeaderFlags(0), &info.Msg, options)
 }
 
+// FlushAll removes all filters of info.Parent, that match the priority and
+// protocol of info.Info, like `tc filter del dev eth0 parent 1: prio 1`.
+// Only the chain info.Chain is flushed, which defaults to chain 0, like
+// tcf_chain_flush() of the kernel. If Info is zero, all filters of this chain
+// are removed, filters of other chains remain. Use FlushParent to remove the
+// filters of every chain. info.Handle must be zero, use Delete to remove a
+// single filter.
+func (f *Filter) FlushAll(info *Object) error {
+	if info == nil {
+		return ErrNoArg
+	}
+	options, err := validateFlushObject(unix.RTM_DELTFILTER, info)
+	if err != nil {
+		return err
+	}
+	return f.action(unix.RTM_DELTFILTER, netlink.HeaderFlags(0), &info.Msg, options)
+}
+
+// validateFlushObject returns the options of a request, that deletes all
+// filters described by info.
+func validateFlushObject(action int, info *Object) ([]tcOption, error) {
+	if info.Ifindex == 0 {
+		return []tcOption{}, ErrInvalidDev
+	}
+	if info.Handle != 0 {
+		return []tcOption{}, fmt.Errorf("%s: Handle is set, use Delete to remove a single filter: %w",
+			info.Kind, ErrInvalidArg)
+	}
+	return deleteOptions(action, info), nil
+}
+
 // Get fetches all filters
 func (f *Filter) Get(i *Msg) ([]Object, error) {
 	if i == nil {
@@ -62,6 +106,112 @@ func (f *Filter) Get(i *Msg) ([]Object, error) {
 	return f.get(unix.RTM_GETTFILTER, i)
 }
 
+// Walk calls fn for every filter, that matches i, as soon as it is received.
+// Unlike Get, large dumps are not kept in memory at once. The Object passed
+// to fn is reused for the next filter, so use Object.Copy to retain it. If fn
+// returns an error, Walk stops and returns it.
+func (f *Filter) Walk(i *Msg, fn func(*Object) error) error {
+	if i == nil {
+		return ErrNoArg
+	}
+	return f.walk(unix.RTM_GETTFILTER, i, fn)
+}
+
+// FlushParent removes all filters of all chains of parent on ifindex, like
+// `tc filter del dev eth0 parent 1:` does for every chain. It returns the
+// number of filters, that are still found afterwards.
+func (f *Filter) FlushParent(ifindex, parent uint32) (int, error) {
+	if ifindex == 0 {
+		return 0, ErrInvalidDev
+	}
+	filters, err := f.Get(&Msg{Family: unix.AF_UNSPEC, Ifindex: ifindex, Parent: parent})
+	if err != nil {
+		return 0, err
+	}
+	chains := make(map[uint32]bool)
+	for _, filter := range filters {
+		chain := filterChain(&filter)
+		if chains[chain] {
+			continue
+		}
+		chains[chain] = true
+		if err := f.flush(ifindex, parent, chain, 0); err != nil {
+			return 0, err
+		}
+	}
+	return f.remaining(ifindex, parent, func(*Object) bool { return true })
+}
+
+// FlushChain removes all filters of chain of parent on ifindex, like
+// `tc filter del dev eth0 parent 1: chain 3`. It returns the number of
+// filters of the chain, that are still found afterwards.
+func (f *Filter) FlushChain(ifindex, parent, chain uint32) (int, error) {
+	if ifindex == 0 {
+		return 0, ErrInvalidDev
+	}
+	if err := f.flush(ifindex, parent, chain, 0); err != nil {
+		return 0, err
+	}
+	return f.remaining(ifindex, parent, func(filter *Object) bool {
+		return filterChain(filter) == chain
+	})
+}
+
+// FlushPrio removes all filters with the priority prio of chain of parent on
+// ifindex, like `tc filter del dev eth0 parent 1: chain 0 prio 10`. It
+// returns the number of these filters, that are still found afterwards.
+func (f *Filter) FlushPrio(ifindex, parent, chain uint32, prio uint16) (int, error) {
+	if ifindex == 0 {
+		return 0, ErrInvalidDev
+	}
+	if prio == 0 {
+		return 0, fmt.Errorf("prio is zero and would flush the whole chain: %w", ErrInvalidArg)
+	}
+	if err := f.flush(ifindex, parent, chain, prio); err != nil {
+		return 0, err
+	}
+	return f.remaining(ifindex, parent, func(filter *Object) bool {
+		return filterChain(filter) == chain && uint16(filter.Info>>16) == prio
+	})
+}
+
+// flush sends the wildcard request, that deletes all filters of chain with
+// prio. A prio of zero matches all priorities. A chain, that does not exist,
+// has no filters to delete.
+func (f *Filter) flush(ifindex, parent, chain uint32, prio uint16) error {
+	msg := Msg{Family: unix.AF_UNSPEC, Ifindex: ifindex, Parent: parent, Info: uint32(prio) << 16}
+	options := []tcOption{{Interpretation: vtUint32, Type: tcaChain, Data: chain}}
+	err := f.action(unix.RTM_DELTFILTER, netlink.HeaderFlags(0), &msg, options)
+	if errors.Is(err, syscall.ENOENT) {
+		return nil
+	}
+	return err
+}
+
+// remaining returns the number of filters of parent, that match.
+func (f *Filter) remaining(ifindex, parent uint32, match func(*Object) bool) (int, error) {
+	filters, err := f.Get(&Msg{Family: unix.AF_UNSPEC, Ifindex: ifindex, Parent: parent})
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for i := range filters {
+		if match(&filters[i]) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// filterChain returns the chain of filter. Kernels without chains report
+// all filters in chain 0.
+func filterChain(filter *Object) uint32 {
+	if filter.Chain == nil {
+		return 0
+	}
+	return *filter.Chain
+}
+
 func marshalFilterOptions(kind string, info *Object) ([]byte, error) {
 	var data []byte
 	var err error
@@ -99,6 +249,9 @@ func validateFilterObject(action int, info *Object) ([]tcOption, error) {
 	if info.Ifindex == 0 {
 		return options, ErrInvalidDev
 	}
+	if err := validateKind(info.Kind); err != nil {
+		return options, err
+	}
 
 	if !isFilter(info.Kind) && !isChainAction(action) {
 		return options, ErrInvalidArg
@@ -114,9 +267,7 @@ func validateFilterObject(action int, info *Object) ([]tcOption, error) {
 	if info.IngressBlock != nil {
 		options = append(options, tcOption{Interpretation: vtUint32, Type: tcaIngressBlock, Data: uint32Value(info.IngressBlock)})
 	}
-	if info.HwOffload != nil {
-		options = append(options, tcOption{Interpretation: vtUint8, Type: tcaHwOffload, Data: uint8Value(info.HwOffload)})
-	}
+	// HwOffload is reported by the kernel and can not be set.
 	if info.Chain != nil {
 		options = append(options, tcOption{Interpretation: vtUint32, Type: tcaChain, Data: uint32Value(info.Chain)})
 	}
@@ -130,7 +281,7 @@ func validateFilterObject(action int, info *Object) ([]tcOption, error) {
 	}
 	if err != nil {
 		if !errors.Is(err, ErrNoArg) && action != unix.RTM_DELTFILTER {
-			return options, err
+			return options, withKind(info.Kind, err)
 		}
 	}
 
@@ -0,0 +1,158 @@
+package tc
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/florianl/go-tc/core"
+)
+
+// ChildError is the error of a single child qdisc in a *ChildrenError.
+type ChildError struct {
+	// Qdisc is the child qdisc.
+	Qdisc Object
+	Err   error
+}
+
+// ChildrenError is returned by AddToChildren and DeleteFromChildren, if the
+// filter could not be added to or deleted from some of the child qdiscs.
+// The other children are changed regardless. It matches every error, that
+// one of the children matches.
+type ChildrenError struct {
+	// Op is the failed operation, "add" or "delete".
+	Op string
+	// Root is the multiqueue qdisc.
+	Root Object
+	// Children holds an error for each failed child. Total is the number of
+	// children of Root.
+	Children []ChildError
+	Total    int
+}
+
+func (e *ChildrenError) Error() string {
+	errs := make([]string, 0, len(e.Children))
+	for _, child := range e.Children {
+		errs = append(errs, fmt.Sprintf("%s: %v", child.Qdisc, child.Err))
+	}
+	return fmt.Sprintf("%s filter for %d of %d children of %s: %s", e.Op, len(e.Children), e.Total,
+		e.Root, strings.Join(errs, "; "))
+}
+
+// Unwrap returns the error of the failed child or, if several children
+// failed, a *MultiError of their errors.
+func (e *ChildrenError) Unwrap() error {
+	var err error
+	for _, child := range e.Children {
+		err = concatError(err, child.Err)
+	}
+	return err
+}
+
+// Is reports whether the error of one of the children matches target.
+func (e *ChildrenError) Is(target error) bool {
+	for _, child := range e.Children {
+		if errors.Is(child.Err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddToChildren adds info to every child qdisc of the multiqueue qdisc, like
+// mq or mqprio, with the handle rootHandle on the device ifindex. These are
+// the qdiscs of the transmit queues, that are attached to the classes of the
+// root. For each child, info is added with Ifindex set to ifindex and Parent
+// to the handle of the child. All children are tried, the errors of the
+// failed ones are returned in a *ChildrenError. The per queue qdiscs, that
+// the kernel attaches by default, have no handle and can not hold filters.
+// They have to be replaced by qdiscs with a handle first. The root qdisc is
+// identified by rootHandle and the parent HandleRoot, so that rootHandle 0
+// refers to the mq qdisc, that the kernel attaches by default.
+func (f *Filter) AddToChildren(ifindex, rootHandle uint32, info *Object) error {
+	return f.forChildren("add", ifindex, rootHandle, info, f.Add)
+}
+
+// DeleteFromChildren deletes info from every child qdisc of the multiqueue
+// qdisc rootHandle on the device ifindex, like AddToChildren added it.
+func (f *Filter) DeleteFromChildren(ifindex, rootHandle uint32, info *Object) error {
+	return f.forChildren("delete", ifindex, rootHandle, info, f.Delete)
+}
+
+// forChildren calls fn with a copy of info for every child qdisc of the
+// multiqueue qdisc rootHandle on ifindex.
+func (f *Filter) forChildren(op string, ifindex, rootHandle uint32, info *Object, fn func(*Object) error) error {
+	if info == nil {
+		return ErrNoArg
+	}
+	if ifindex == 0 {
+		return ErrInvalidDev
+	}
+	root, children, err := f.multiQueueChildren(ifindex, rootHandle)
+	if err != nil {
+		return err
+	}
+	childrenErr := &ChildrenError{Op: op, Root: root, Total: len(children)}
+	for _, child := range children {
+		if child.Handle == 0 {
+			childrenErr.Children = append(childrenErr.Children, ChildError{Qdisc: child,
+				Err: fmt.Errorf("qdisc without handle can not hold filters: %w", ErrInvalidArg)})
+			continue
+		}
+		filter := *info
+		filter.Ifindex = ifindex
+		filter.Parent = child.Handle
+		if err := fn(&filter); err != nil {
+			childrenErr.Children = append(childrenErr.Children, ChildError{Qdisc: child, Err: err})
+		}
+	}
+	if len(childrenErr.Children) > 0 {
+		return childrenErr
+	}
+	return nil
+}
+
+// multiQueueChildren returns the multiqueue qdisc rootHandle on ifindex and
+// its child qdiscs ordered by their parent. The children are dumped with
+// Qdisc.Invisible, as the kernel hides the per queue qdiscs, it attaches by
+// default.
+func (f *Filter) multiQueueChildren(ifindex, rootHandle uint32) (Object, []Object, error) {
+	qdiscs, err := f.Qdisc().Invisible().Get()
+	if err != nil {
+		return Object{}, nil, err
+	}
+	var root *Object
+	var children []Object
+	rootMaj, _ := core.SplitHandle(rootHandle)
+	for i := range qdiscs {
+		qdisc := &qdiscs[i]
+		if qdisc.Ifindex != ifindex {
+			continue
+		}
+		// Multiqueue qdiscs are only attached to the root. The root of the
+		// kernel and its per queue qdiscs all have the handle 0.
+		if qdisc.Handle == rootHandle && qdisc.Parent == HandleRoot {
+			root = qdisc
+			continue
+		}
+		if maj, min := core.SplitHandle(qdisc.Parent); maj == rootMaj && min != 0 &&
+			qdisc.Parent != HandleRoot && qdisc.Parent != HandleIngress {
+			children = append(children, *qdisc)
+		}
+	}
+	if root == nil {
+		return Object{}, nil, fmt.Errorf("device %d has no root qdisc %s: %w", ifindex,
+			core.FormatHandle(rootHandle), ErrInvalidArg)
+	}
+	if !multiQueueKinds[root.Kind] {
+		return Object{}, nil, fmt.Errorf("%s: %s is no multiqueue qdisc: %w", *root, root.Kind, ErrInvalidArg)
+	}
+	if len(children) == 0 {
+		return Object{}, nil, fmt.Errorf("%s: qdisc has no children: %w", *root, ErrInvalidArg)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].Parent < children[j].Parent
+	})
+	return *root, children, nil
+}
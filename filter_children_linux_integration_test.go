@@ -0,0 +1,125 @@
+//go:build integration && linux
+// +build integration,linux
+
+package tc
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func TestLinuxFilterChildren(t *testing.T) {
+	tcIface := "tcMultiQueue"
+	queues := 4
+
+	tcnl, err := Open(&Config{})
+	if err != nil {
+		t.Fatalf("could not open rtnetlink socket: %v", err)
+	}
+	defer func() {
+		if err := tcnl.Close(); err != nil {
+			t.Fatalf("could not close rtnetlink socket: %v", err)
+		}
+	}()
+
+	// rtnetlink.LinkAttributes can not set the number of transmit queues.
+	linkInfo, err := marshalAttributes([]tcOption{
+		{Interpretation: vtString, Type: unix.IFLA_INFO_KIND, Data: "dummy"},
+	})
+	if err != nil {
+		t.Fatalf("could not marshal link info: %v", err)
+	}
+	if err := tcnl.action(unix.RTM_NEWLINK, netlink.Create|netlink.Excl, unix.IfInfomsg{
+		Family: unix.AF_UNSPEC,
+		Flags:  unix.IFF_UP,
+		Change: unix.IFF_UP,
+	}, []tcOption{
+		{Interpretation: vtString, Type: unix.IFLA_IFNAME, Data: tcIface},
+		{Interpretation: vtUint32, Type: unix.IFLA_NUM_TX_QUEUES, Data: uint32(queues)},
+		{Interpretation: vtNested, Type: unix.IFLA_LINKINFO, Data: linkInfo},
+	}); err != nil {
+		t.Skipf("could not setup multiqueue dummy interface: %v", err)
+	}
+	devID, err := net.InterfaceByName(tcIface)
+	if err != nil {
+		t.Fatalf("could not get interface ID: %v", err)
+	}
+	ifindex := uint32(devID.Index)
+	defer func() {
+		if err := tcnl.action(unix.RTM_DELLINK, 0, unix.IfInfomsg{
+			Family: unix.AF_UNSPEC,
+			Index:  int32(ifindex),
+		}, nil); err != nil {
+			t.Fatalf("could not delete interface: %v", err)
+		}
+	}()
+
+	root := core.BuildHandle(0x1, 0x0)
+	if err := tcnl.Qdisc().Add(&Object{
+		Msg{Family: unix.AF_UNSPEC, Ifindex: ifindex, Handle: root, Parent: HandleRoot},
+		Attribute{Kind: "mq"},
+	}); err != nil {
+		t.Fatalf("could not add mq qdisc: %v", err)
+	}
+
+	filter := Object{
+		Msg:       Msg{Family: unix.AF_UNSPEC, Handle: 0x1, Info: core.BuildFilterInfo(1, core.EthPIP)},
+		Attribute: Attribute{Kind: "basic", Basic: &Basic{ClassID: uint32Ptr(core.BuildHandle(0x1, 0x1))}},
+	}
+
+	// The per queue qdiscs of the kernel have no handle.
+	err = tcnl.Filter().AddToChildren(ifindex, root, &filter)
+	var childrenErr *ChildrenError
+	if !errors.As(err, &childrenErr) || len(childrenErr.Children) != queues || childrenErr.Total != queues {
+		t.Fatalf("expected an error for each of the %d default children but got %v", queues, err)
+	}
+
+	var handles []uint32
+	for i := 1; i <= queues; i++ {
+		handle := core.BuildHandle(uint32(0x10*i), 0x0)
+		if err := tcnl.Qdisc().Replace(&Object{
+			Msg{Family: unix.AF_UNSPEC, Ifindex: ifindex, Handle: handle, Parent: core.BuildHandle(0x1, uint32(i))},
+			Attribute{Kind: "fq_codel", FqCodel: &FqCodel{Limit: uint32Ptr(1000)}},
+		}); err != nil {
+			t.Fatalf("could not replace child qdisc of queue %d: %v", i, err)
+		}
+		handles = append(handles, handle)
+	}
+
+	if err := tcnl.Filter().AddToChildren(ifindex, root, &filter); err != nil {
+		t.Fatalf("could not add filter to children: %v", err)
+	}
+	for _, handle := range handles {
+		filters, err := tcnl.Filter().Get(&Msg{Family: unix.AF_UNSPEC, Ifindex: ifindex, Parent: handle})
+		if err != nil {
+			t.Fatalf("could not get filters of %s: %v", core.FormatHandle(handle), err)
+		}
+		if len(filters) != 1 || filters[0].Kind != "basic" {
+			t.Fatalf("expected the basic filter on %s but got %v", core.FormatHandle(handle), filters)
+		}
+	}
+
+	// The filter exists already on every child.
+	if err := tcnl.Filter().AddToChildren(ifindex, root, &filter); !errors.As(err, &childrenErr) ||
+		!errors.Is(err, unix.EEXIST) || len(childrenErr.Children) != queues {
+		t.Fatalf("expected EEXIST for each child but got %v", err)
+	}
+
+	if err := tcnl.Filter().DeleteFromChildren(ifindex, root, &filter); err != nil {
+		t.Fatalf("could not delete filter from children: %v", err)
+	}
+	for _, handle := range handles {
+		filters, err := tcnl.Filter().Get(&Msg{Family: unix.AF_UNSPEC, Ifindex: ifindex, Parent: handle})
+		if err != nil {
+			t.Fatalf("could not get filters of %s: %v", core.FormatHandle(handle), err)
+		}
+		if len(filters) != 0 {
+			t.Fatalf("expected no filters on %s but got %v", core.FormatHandle(handle), filters)
+		}
+	}
+}
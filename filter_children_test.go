@@ -0,0 +1,203 @@
+package tc
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nltest"
+)
+
+func TestFilterChildren(t *testing.T) {
+	qdisc := func(ifindex, handle, parent uint32, kind string) Object {
+		return Object{Msg: Msg{Family: unix.AF_UNSPEC, Ifindex: ifindex, Handle: handle, Parent: parent},
+			Attribute: Attribute{Kind: kind}}
+	}
+	mq := qdisc(2, core.BuildHandle(0x1, 0x0), HandleRoot, "mq")
+	children := []Object{
+		qdisc(2, core.BuildHandle(0x20, 0x0), core.BuildHandle(0x1, 0x2), "prio"),
+		qdisc(2, core.BuildHandle(0x10, 0x0), core.BuildHandle(0x1, 0x1), "prio"),
+		qdisc(3, core.BuildHandle(0x30, 0x0), core.BuildHandle(0x1, 0x1), "prio"),
+		qdisc(2, core.BuildHandle(0x40, 0x0), core.BuildHandle(0x10, 0x1), "fq_codel"),
+	}
+	filter := Object{
+		Msg:       Msg{Family: unix.AF_UNSPEC, Handle: 0x1, Info: core.BuildFilterInfo(1, core.EthPIP)},
+		Attribute: Attribute{Kind: "basic", Basic: &Basic{ClassID: uint32Ptr(core.BuildHandle(0x10, 0x1))}},
+	}
+
+	tests := map[string]struct {
+		delete  bool
+		root    *uint32
+		dump    []Object
+		fail    map[uint32]syscall.Errno
+		parents []uint32
+		failed  []uint32
+		total   int
+		err     error
+	}{
+		"mq": {
+			dump:    append([]Object{mq}, children...),
+			parents: []uint32{core.BuildHandle(0x10, 0x0), core.BuildHandle(0x20, 0x0)},
+		},
+		"mqprio": {
+			delete:  true,
+			dump:    append([]Object{qdisc(2, core.BuildHandle(0x1, 0x0), HandleRoot, "mqprio")}, children...),
+			parents: []uint32{core.BuildHandle(0x10, 0x0), core.BuildHandle(0x20, 0x0)},
+		},
+		"child fails": {
+			dump:    append([]Object{mq}, children...),
+			fail:    map[uint32]syscall.Errno{core.BuildHandle(0x10, 0x0): syscall.EEXIST},
+			parents: []uint32{core.BuildHandle(0x10, 0x0), core.BuildHandle(0x20, 0x0)},
+			failed:  []uint32{core.BuildHandle(0x10, 0x0)},
+			total:   2,
+			err:     syscall.EEXIST,
+		},
+		"children fail": {
+			dump: append([]Object{mq}, children...),
+			fail: map[uint32]syscall.Errno{core.BuildHandle(0x10, 0x0): syscall.EEXIST,
+				core.BuildHandle(0x20, 0x0): syscall.ENOMEM},
+			parents: []uint32{core.BuildHandle(0x10, 0x0), core.BuildHandle(0x20, 0x0)},
+			failed:  []uint32{core.BuildHandle(0x10, 0x0), core.BuildHandle(0x20, 0x0)},
+			total:   2,
+			err:     syscall.ENOMEM,
+		},
+		"default children": {
+			dump: []Object{mq, qdisc(2, 0, core.BuildHandle(0x1, 0x1), "pfifo_fast"),
+				qdisc(2, core.BuildHandle(0x20, 0x0), core.BuildHandle(0x1, 0x2), "prio")},
+			parents: []uint32{core.BuildHandle(0x20, 0x0)},
+			failed:  []uint32{0},
+			total:   2,
+			err:     ErrInvalidArg,
+		},
+		"default root": {
+			root: uint32Ptr(0),
+			dump: []Object{qdisc(2, 0, HandleRoot, "mq"), qdisc(2, 0, core.BuildHandle(0x0, 0x1), "pfifo_fast"),
+				qdisc(2, core.BuildHandle(0x20, 0x0), core.BuildHandle(0x0, 0x2), "prio")},
+			parents: []uint32{core.BuildHandle(0x20, 0x0)},
+			failed:  []uint32{0},
+			total:   2,
+			err:     ErrInvalidArg,
+		},
+		"child with root handle": {
+			root: uint32Ptr(0),
+			dump: []Object{qdisc(2, 0, core.BuildHandle(0x1, 0x1), "pfifo_fast"),
+				qdisc(2, core.BuildHandle(0x1, 0x0), HandleRoot, "mq")},
+			err: ErrInvalidArg,
+		},
+		"htb": {
+			dump: append([]Object{qdisc(2, core.BuildHandle(0x1, 0x0), HandleRoot, "htb")}, children...),
+			err:  ErrInvalidArg,
+		},
+		"without children": {
+			dump: []Object{mq},
+			err:  ErrInvalidArg,
+		},
+		"missing root": {
+			dump: children,
+			err:  ErrInvalidArg,
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			var parents []uint32
+			tcSocket := &Tc{
+				con: nltest.Dial(func(req []netlink.Message) ([]netlink.Message, error) {
+					if req[0].Header.Type == unix.RTM_GETQDISC {
+						// The per queue qdiscs are only dumped with
+						// TCA_DUMP_INVISIBLE.
+						_, attrs, err := unmarshalTcmsg(req[0].Data)
+						if err != nil {
+							t.Fatalf("could not decode request: %v", err)
+						}
+						want, _ := marshalAttributes([]tcOption{{Interpretation: vtFlag, Type: tcaDumpInvisible, Data: true}})
+						if diff := cmp.Diff(want, attrs); diff != "" {
+							t.Fatalf("dump request missmatch (-want +got):\n%s", diff)
+						}
+						var msgs []netlink.Message
+						for _, qdisc := range testcase.dump {
+							data, err := marshalStruct(&qdisc.Msg)
+							if err != nil {
+								t.Fatalf("could not encode Msg: %v", err)
+							}
+							attrs, err := marshalAttributes([]tcOption{{Interpretation: vtString, Type: tcaKind, Data: qdisc.Kind}})
+							if err != nil {
+								t.Fatalf("could not encode attributes: %v", err)
+							}
+							msgs = append(msgs, netlink.Message{Header: netlink.Header{Type: unix.RTM_NEWQDISC},
+								Data: append(data, attrs...)})
+						}
+						return msgs, nil
+					}
+					want := netlink.HeaderType(unix.RTM_NEWTFILTER)
+					if testcase.delete {
+						want = unix.RTM_DELTFILTER
+					}
+					if req[0].Header.Type != want {
+						t.Fatalf("expected request %d but got %d", want, req[0].Header.Type)
+					}
+					msg, _, err := unmarshalTcmsg(req[0].Data)
+					if err != nil {
+						t.Fatalf("could not decode request: %v", err)
+					}
+					if msg.Ifindex != 2 {
+						t.Fatalf("expected device 2 but got %d", msg.Ifindex)
+					}
+					parents = append(parents, msg.Parent)
+					if errno, ok := testcase.fail[msg.Parent]; ok {
+						return nltest.Error(int(errno), req)
+					}
+					return []netlink.Message{{Header: netlink.Header{Type: netlink.Error}, Data: make([]byte, 4)}}, nil
+				}),
+			}
+			defer tcSocket.Close()
+
+			info := filter
+			root := core.BuildHandle(0x1, 0x0)
+			if testcase.root != nil {
+				root = *testcase.root
+			}
+			var err error
+			if testcase.delete {
+				err = tcSocket.Filter().DeleteFromChildren(2, root, &info)
+			} else {
+				err = tcSocket.Filter().AddToChildren(2, root, &info)
+			}
+			if !errors.Is(err, testcase.err) {
+				t.Fatalf("expected error %v but got %v", testcase.err, err)
+			}
+			if diff := cmp.Diff(testcase.parents, parents); diff != "" {
+				t.Fatalf("parents missmatch (-want +got):\n%s", diff)
+			}
+			if info.Parent != 0 || info.Ifindex != 0 {
+				t.Fatalf("expected the filter to be unchanged but got %s", info)
+			}
+			var childrenErr *ChildrenError
+			if errors.As(err, &childrenErr) != (len(testcase.failed) > 0) {
+				t.Fatalf("expected failed children %v but got %v", testcase.failed, err)
+			}
+			if childrenErr == nil {
+				return
+			}
+			var failed []uint32
+			for _, child := range childrenErr.Children {
+				failed = append(failed, child.Qdisc.Handle)
+			}
+			if diff := cmp.Diff(testcase.failed, failed); diff != "" {
+				t.Fatalf("failed children missmatch (-want +got):\n%s", diff)
+			}
+			if childrenErr.Total != testcase.total {
+				t.Fatalf("expected %d children but got %d", testcase.total, childrenErr.Total)
+			}
+			for _, child := range childrenErr.Children {
+				if !errors.Is(childrenErr.Unwrap(), child.Err) {
+					t.Fatalf("expected %v to be unwrapped but got %v", child.Err, childrenErr.Unwrap())
+				}
+			}
+		})
+	}
+}
@@ -2,10 +2,14 @@ package tc
 
 import (
 	"errors"
+	"syscall"
 	"testing"
 
 	"github.com/florianl/go-tc/core"
 	"github.com/florianl/go-tc/internal/unix"
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nltest"
 )
 
 func TestFilter(t *testing.T) {
@@ -21,7 +25,7 @@ func TestFilter(t *testing.T) {
 		Family:  unix.AF_UNSPEC,
 		Ifindex: 1337,
 		Handle:  core.BuildHandle(0xFFFF, 0x0000),
-		Parent:  0xFFFFFFF1,
+		Parent:  HandleIngress,
 		Info:    0,
 	}
 
@@ -43,25 +47,35 @@ func TestFilter(t *testing.T) {
 		matchall   *Matchall
 		cgroup     *Cgroup
 		tcindex    *TcIndex
+		handle     uint32
 		errAdd     error
 		errReplace error
 	}{
 		"unknown":         {kind: "unknown", errAdd: ErrInvalidArg},
 		"missingArgument": {kind: "bpf", errAdd: ErrNoArg},
-		"u32-exactMatch":  {kind: "u32", u32: &U32{ClassID: uint32Ptr(13)}},
-		"flower":          {kind: "flower", flower: &Flower{ClassID: uint32Ptr(13)}},
-		"matchall":        {kind: "matchall", matchall: &Matchall{ClassID: uint32Ptr(13)}},
+		"u32-exactMatch": {kind: "u32", u32: &U32{ClassID: uint32Ptr(13), Sel: &U32Sel{
+			Flags: 0x1,
+			NKeys: 0x1,
+			Keys:  []U32Key{{Mask: 0xFFFFFFFF, Val: 0x0100000A, Off: 16}},
+		}}},
+		"flower":   {kind: "flower", flower: &Flower{ClassID: uint32Ptr(13)}},
+		"matchall": {kind: "matchall", matchall: &Matchall{ClassID: uint32Ptr(13)}},
 		"cgroup": {kind: "cgroup", cgroup: &Cgroup{Action: &Action{
 			Kind: "vlan",
 			VLan: &VLan{PushID: uint16Ptr(12)},
 		}}},
-		"tcindex": {kind: "tcindex", tcindex: &TcIndex{Mask: uint16Ptr(42), ClassID: uint32Ptr(1337)}},
+		// The handle of a tcindex filter is its 16 bit key.
+		"tcindex": {kind: "tcindex", tcindex: &TcIndex{Mask: uint16Ptr(42), ClassID: uint32Ptr(1337)}, handle: 0x2E},
 	}
 
+	// Filters require a protocol, ETH_P_ALL in network byte order.
+	filterMsg := tcMsg
+	filterMsg.Info = 0x300
+
 	for name, testcase := range tests {
 		t.Run(name, func(t *testing.T) {
 			testFilter := Object{
-				tcMsg,
+				filterMsg,
 				Attribute{
 					Kind:     testcase.kind,
 					U32:      testcase.u32,
@@ -71,6 +85,13 @@ func TestFilter(t *testing.T) {
 					TcIndex:  testcase.tcindex,
 				},
 			}
+			if testcase.handle != 0 {
+				testFilter.Handle = testcase.handle
+			}
+
+			t.Run("Copy", func(t *testing.T) {
+				testCopy(t, &testFilter)
+			})
 
 			if err := tcSocket.Filter().Add(&testFilter); err != nil {
 				if errors.Is(err, testcase.errAdd) {
@@ -105,6 +126,26 @@ func TestFilter(t *testing.T) {
 			t.Fatalf("unexpected error: %v", err)
 		}
 	})
+	t.Run("delete without handle", func(t *testing.T) {
+		msg := filterMsg
+		msg.Handle = 0
+		if err := tcSocket.Filter().Delete(&Object{msg, Attribute{Kind: "u32"}}); !errors.Is(err, ErrInvalidArg) {
+			t.Fatalf("expected ErrInvalidArg but got %v", err)
+		}
+		if err := tcSocket.Filter().FlushAll(&Object{msg, Attribute{}}); err != nil {
+			t.Fatalf("could not flush filters: %v", err)
+		}
+	})
+	t.Run("flush with handle", func(t *testing.T) {
+		if err := tcSocket.Filter().FlushAll(&Object{filterMsg, Attribute{Kind: "u32"}}); !errors.Is(err, ErrInvalidArg) {
+			t.Fatalf("expected ErrInvalidArg but got %v", err)
+		}
+	})
+	t.Run("flush nil", func(t *testing.T) {
+		if err := tcSocket.Filter().FlushAll(nil); !errors.Is(err, ErrNoArg) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
 	t.Run("replace nil", func(t *testing.T) {
 		if err := tcSocket.Filter().Replace(nil); !errors.Is(err, ErrNoArg) {
 			t.Fatalf("unexpected error: %v", err)
@@ -261,3 +302,217 @@ func TestMarshalFilterOptions(t *testing.T) {
 		})
 	}
 }
+
+// flushConn returns a connection, that dumps filters and deletes those, that
+// match a wildcard request. The delete requests are recorded in deletes.
+// Filters with the handle stuck are never deleted.
+func flushConn(t *testing.T, filters []Object, stuck uint32, deletes *[]netlink.Message) *Tc {
+	t.Helper()
+
+	return &Tc{
+		con: nltest.Dial(func(req []netlink.Message) ([]netlink.Message, error) {
+			if len(req) == 0 {
+				return []netlink.Message{}, nil
+			}
+			switch req[0].Header.Type {
+			case unix.RTM_GETTFILTER:
+				var msgs []netlink.Message
+				for _, filter := range filters {
+					tcmsg, err := marshalStruct(&filter.Msg)
+					if err != nil {
+						t.Fatalf("could not encode Msg: %v", err)
+					}
+					attrs, err := marshalAttributes([]tcOption{
+						{Interpretation: vtString, Type: tcaKind, Data: filter.Kind},
+						{Interpretation: vtUint32, Type: tcaChain, Data: *filter.Chain},
+					})
+					if err != nil {
+						t.Fatalf("could not encode attributes: %v", err)
+					}
+					msgs = append(msgs, netlink.Message{
+						Header: netlink.Header{Type: unix.RTM_NEWTFILTER},
+						Data:   append(tcmsg, attrs...),
+					})
+				}
+				return msgs, nil
+			case unix.RTM_DELTFILTER:
+				*deletes = append(*deletes, req[0])
+				msg, attrs, err := unmarshalTcmsg(req[0].Data)
+				if err != nil {
+					t.Fatalf("could not decode request: %v", err)
+				}
+				var attr Attribute
+				if err := extractTcmsgAttributes(unix.RTM_DELTFILTER, attrs, &attr); err != nil {
+					t.Fatalf("could not decode attributes: %v", err)
+				}
+				var kept []Object
+				var found bool
+				for _, filter := range filters {
+					if *filter.Chain != *attr.Chain || (msg.Info != 0 && filter.Info>>16 != msg.Info>>16) {
+						kept = append(kept, filter)
+						continue
+					}
+					found = true
+					if filter.Handle == stuck {
+						kept = append(kept, filter)
+					}
+				}
+				filters = kept
+				if !found {
+					return nil, syscall.ENOENT
+				}
+			}
+			return []netlink.Message{}, nil
+		}),
+	}
+}
+
+func TestFilterFlush(t *testing.T) {
+	parent := core.BuildHandle(0x1, 0x0)
+	filter := func(handle, chain uint32, prio uint16) Object {
+		return Object{
+			Msg{Family: unix.AF_UNSPEC, Ifindex: 42, Handle: handle, Parent: parent, Info: uint32(prio)<<16 | 0x300},
+			Attribute{Kind: "matchall", Chain: uint32Ptr(chain)},
+		}
+	}
+	filters := []Object{filter(1, 0, 10), filter(2, 0, 20), filter(3, 7, 10)}
+
+	// wildcard returns the request, that deletes all filters of chain with prio.
+	wildcard := func(chain uint32, prio uint16) []byte {
+		tcmsg, err := marshalStruct(&Msg{Family: unix.AF_UNSPEC, Ifindex: 42, Parent: parent, Info: uint32(prio) << 16})
+		if err != nil {
+			t.Fatalf("could not encode Msg: %v", err)
+		}
+		data := make([]byte, 4)
+		nativeEndian.PutUint32(data, chain)
+		attrs, err := netlink.MarshalAttributes([]netlink.Attribute{{Type: tcaChain, Data: data}})
+		if err != nil {
+			t.Fatalf("could not encode attributes: %v", err)
+		}
+		return append(tcmsg, attrs...)
+	}
+
+	tests := map[string]struct {
+		flush     func(f *Filter) (int, error)
+		stuck     uint32
+		requests  [][]byte
+		remaining int
+	}{
+		"parent": {
+			flush:    func(f *Filter) (int, error) { return f.FlushParent(42, parent) },
+			requests: [][]byte{wildcard(0, 0), wildcard(7, 0)},
+		},
+		"chain": {
+			flush:    func(f *Filter) (int, error) { return f.FlushChain(42, parent, 7) },
+			requests: [][]byte{wildcard(7, 0)},
+		},
+		"missing chain": {
+			flush:    func(f *Filter) (int, error) { return f.FlushChain(42, parent, 3) },
+			requests: [][]byte{wildcard(3, 0)},
+		},
+		"prio": {
+			flush:    func(f *Filter) (int, error) { return f.FlushPrio(42, parent, 0, 10) },
+			requests: [][]byte{wildcard(0, 10)},
+		},
+		"partial": {
+			flush:     func(f *Filter) (int, error) { return f.FlushChain(42, parent, 0) },
+			stuck:     2,
+			requests:  [][]byte{wildcard(0, 0)},
+			remaining: 1,
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			var deletes []netlink.Message
+			tcSocket := flushConn(t, append([]Object{}, filters...), testcase.stuck, &deletes)
+			defer tcSocket.Close()
+
+			remaining, err := testcase.flush(tcSocket.Filter())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if remaining != testcase.remaining {
+				t.Fatalf("expected %d remaining filters but got %d", testcase.remaining, remaining)
+			}
+			var requests [][]byte
+			for _, msg := range deletes {
+				if msg.Header.Flags != netlink.Request|netlink.Acknowledge {
+					t.Fatalf("unexpected flags %v", msg.Header.Flags)
+				}
+				requests = append(requests, msg.Data)
+			}
+			if diff := cmp.Diff(testcase.requests, requests); diff != "" {
+				t.Fatalf("request missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		var deletes []netlink.Message
+		tcSocket := flushConn(t, nil, 0, &deletes)
+		defer tcSocket.Close()
+		if _, err := tcSocket.Filter().FlushParent(0, parent); !errors.Is(err, ErrInvalidDev) {
+			t.Fatalf("expected ErrInvalidDev but got %v", err)
+		}
+		if _, err := tcSocket.Filter().FlushPrio(42, parent, 0, 0); !errors.Is(err, ErrInvalidArg) {
+			t.Fatalf("expected ErrInvalidArg but got %v", err)
+		}
+		if len(deletes) != 0 {
+			t.Fatalf("expected no requests but got %d", len(deletes))
+		}
+	})
+}
+
+func TestFilterProtocol(t *testing.T) {
+	// tcf_fill_node() reports the priority and the protocol in network byte
+	// order in tcm_info, see `tc filter show dev eth0 ingress`:
+	//  filter protocol ip pref 1 matchall chain 0
+	dump := map[string]struct {
+		wire     [2]byte
+		priority uint16
+		protocol uint16
+	}{
+		"ip":     {wire: [2]byte{0x08, 0x00}, priority: 1, protocol: core.EthPIP},
+		"ipv6":   {wire: [2]byte{0x86, 0xDD}, priority: 2, protocol: core.EthPIPv6},
+		"802.1Q": {wire: [2]byte{0x81, 0x00}, priority: 49152, protocol: core.EthP8021Q},
+		"all":    {wire: [2]byte{0x00, 0x03}, priority: 0xFFFF, protocol: core.EthPAll},
+	}
+	for name, testcase := range dump {
+		t.Run(name, func(t *testing.T) {
+			info := core.BuildHandle(uint32(testcase.priority), uint32(nativeEndian.Uint16(testcase.wire[:])))
+			tcSocket := &Tc{
+				con: nltest.Dial(func(req []netlink.Message) ([]netlink.Message, error) {
+					data, err := marshalStruct(&Msg{Family: unix.AF_UNSPEC, Ifindex: 2, Handle: 0x1,
+						Parent: HandleIngress, Info: info})
+					if err != nil {
+						t.Fatalf("could not encode Msg: %v", err)
+					}
+					attrs, err := marshalAttributes([]tcOption{{Interpretation: vtString, Type: tcaKind, Data: "matchall"}})
+					if err != nil {
+						t.Fatalf("could not encode attributes: %v", err)
+					}
+					return []netlink.Message{{Header: netlink.Header{Type: unix.RTM_NEWTFILTER},
+						Data: append(data, attrs...)}}, nil
+				}),
+			}
+			defer tcSocket.Close()
+
+			filters, err := tcSocket.Filter().Get(&Msg{Family: unix.AF_UNSPEC, Ifindex: 2, Parent: HandleIngress})
+			if err != nil {
+				t.Fatalf("could not get filters: %v", err)
+			}
+			if len(filters) != 1 {
+				t.Fatalf("expected one filter but got %d", len(filters))
+			}
+			got := filters[0]
+			if got.Priority != testcase.priority || got.Protocol != testcase.protocol || got.Info != info {
+				t.Fatalf("expected priority %d and protocol %#04x but got %d and %#04x",
+					testcase.priority, testcase.protocol, got.Priority, got.Protocol)
+			}
+			if formatted := core.FormatProtocol(got.Protocol); formatted != name {
+				t.Fatalf("expected protocol %s but got %s", name, formatted)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package tc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// goStringMaxElems is the number of elements of a slice or array of numbers,
+// up to which GoString prints them. Longer ones, like rate tables and
+// distributions, are printed with their length and a hash.
+const goStringMaxElems = 32
+
+// GoString returns the object in Go syntax like %#v, but with the values of
+// pointers instead of their addresses, so that two dumps can be compared.
+// Fields with nil pointers, slices and maps are left out. Slices of more
+// than 32 numbers are printed as their length and the start of their
+// SHA-256 hash.
+func (o Object) GoString() string {
+	return goString(reflect.ValueOf(o))
+}
+
+// GoString returns the attributes like Object.GoString.
+func (a Attribute) GoString() string {
+	return goString(reflect.ValueOf(a))
+}
+
+// GoString returns the action like Object.GoString.
+func (a Action) GoString() string {
+	return goString(reflect.ValueOf(a))
+}
+
+// GoString returns the statistics like Object.GoString.
+func (x XStats) GoString() string {
+	return goString(reflect.ValueOf(x))
+}
+
+func goString(v reflect.Value) string {
+	var buf bytes.Buffer
+	writeGoString(&buf, v)
+	return buf.String()
+}
+
+// writeGoString writes v in Go syntax to buf.
+func writeGoString(buf *bytes.Buffer, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			buf.WriteString("nil")
+			return
+		}
+		buf.WriteByte('&')
+		writeGoString(buf, v.Elem())
+	case reflect.Interface:
+		if v.IsNil() {
+			buf.WriteString("nil")
+			return
+		}
+		writeGoString(buf, v.Elem())
+	case reflect.Struct:
+		buf.WriteString(v.Type().String())
+		buf.WriteByte('{')
+		var n int
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.PkgPath != "" || isNilValue(v.Field(i)) {
+				continue
+			}
+			if n > 0 {
+				buf.WriteString(", ")
+			}
+			n++
+			buf.WriteString(field.Name)
+			buf.WriteByte(':')
+			writeGoString(buf, v.Field(i))
+		}
+		buf.WriteByte('}')
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			buf.WriteString("nil")
+			return
+		}
+		if v.Len() > goStringMaxElems && isNumberKind(v.Type().Elem().Kind()) {
+			fmt.Fprintf(buf, "%s(len=%d sha256=%x)", v.Type(), v.Len(), numbersHash(v))
+			return
+		}
+		buf.WriteString(v.Type().String())
+		buf.WriteByte('{')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			writeGoString(buf, v.Index(i))
+		}
+		buf.WriteByte('}')
+	case reflect.Map:
+		if v.IsNil() {
+			buf.WriteString("nil")
+			return
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprintf("%#v", keys[i]) < fmt.Sprintf("%#v", keys[j])
+		})
+		buf.WriteString(v.Type().String())
+		buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			writeGoString(buf, key)
+			buf.WriteByte(':')
+			writeGoString(buf, v.MapIndex(key))
+		}
+		buf.WriteByte('}')
+	default:
+		fmt.Fprintf(buf, "%#v", v)
+	}
+}
+
+// isNilValue reports whether v is a nil pointer, interface, slice or map.
+func isNilValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		return v.IsNil()
+	}
+	return false
+}
+
+func isNumberKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// numbersHash returns the first 8 bytes of the SHA-256 hash of the little
+// endian encoding of the numbers of v.
+func numbersHash(v reflect.Value) []byte {
+	h := sha256.New()
+	for i := 0; i < v.Len(); i++ {
+		// The kinds of isNumberKind have a fixed size.
+		_ = binary.Write(h, binary.LittleEndian, v.Index(i).Interface())
+	}
+	return h.Sum(nil)[:8]
+}
@@ -0,0 +1,72 @@
+package tc
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestGoString(t *testing.T) {
+	table := func() *[]byte {
+		data := make([]byte, 256)
+		for i := range data {
+			data[i] = byte(i)
+		}
+		return &data
+	}
+	dist := []int16{-1, 0, 1}
+
+	tests := map[string]struct {
+		value interface{}
+		want  string
+	}{
+		"object": {
+			value: Object{Msg{Ifindex: 3, Handle: 0x10000, Parent: HandleRoot},
+				Attribute{Kind: "fq_codel", FqCodel: &FqCodel{Target: uint32Ptr(42), Limit: uint32Ptr(0xCAFE)}}},
+			want: `tc.Object{Msg:tc.Msg{Family:0x0, Ifindex:0x3, Handle:0x10000, Parent:0xffffffff, Info:0x0}, ` +
+				`Attribute:tc.Attribute{Kind:"fq_codel", ExtWarnMsg:"", Refcnt:0x0, Priority:0x0, Protocol:0x0, ` +
+				`FqCodel:&tc.FqCodel{Target:&0x2a, Limit:&0xcafe}}}`,
+		},
+		"long bytes": {
+			value: Attribute{Kind: "netem", Stab: &Stab{Data: table()},
+				Netem: &Netem{Qopt: NetemQopt{Limit: 1000}, DelayDist: &dist}},
+			want: `tc.Attribute{Kind:"netem", Stab:&tc.Stab{Data:&[]uint8(len=256 sha256=40aff2e9d2d8922e)}, ` +
+				`ExtWarnMsg:"", Refcnt:0x0, Priority:0x0, Protocol:0x0, Netem:&tc.Netem{Qopt:tc.NetemQopt{Latency:0x0, ` +
+				`Limit:0x3e8, Loss:0x0, Gap:0x0, Duplicate:0x0, Jitter:0x0}, DelayDist:&[]int16{-1, 0, 1}}}`,
+		},
+		"action": {
+			value: Action{Kind: "gact", Gact: &Gact{Parms: &GactParms{Action: ActShot}}},
+			want: `tc.Action{Kind:"gact", Index:0x0, Gact:&tc.Gact{Parms:&tc.GactParms{Index:0x0, Capab:0x0, ` +
+				`Action:0x2, RefCnt:0x0, BindCnt:0x0}}}`,
+		},
+		"actions": {
+			value: Attribute{Kind: "matchall", Matchall: &Matchall{Actions: &[]*Action{{Kind: "gact"}, nil}}},
+			want: `tc.Attribute{Kind:"matchall", ExtWarnMsg:"", Refcnt:0x0, Priority:0x0, Protocol:0x0, ` +
+				`Matchall:&tc.Matchall{Actions:&[]*tc.Action{&tc.Action{Kind:"gact", Index:0x0}, nil}}}`,
+		},
+		"xstats": {
+			value: &XStats{Kind: "sfq", Sfq: &SfqXStats{Allot: 1514}, Raw: []byte{0xea, 0x05, 0, 0}},
+			want:  `tc.XStats{Sfq:&tc.SfqXStats{Allot:1514}, Kind:"sfq", Raw:[]uint8{0xea, 0x5, 0x0, 0x0}}`,
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := fmt.Sprintf("%#v", testcase.value)
+			if got != testcase.want {
+				t.Fatalf("expected\n%s\nbut got\n%s", testcase.want, got)
+			}
+		})
+	}
+
+	t.Run("deterministic", func(t *testing.T) {
+		a := Object{Msg{Ifindex: 3}, Attribute{Kind: "red", Red: &Red{MaxP: uint32Ptr(42), Stab: table()}}}
+		b := *a.Copy()
+		if fmt.Sprintf("%#v", a) != fmt.Sprintf("%#v", b) {
+			t.Fatalf("dumps of equal objects differ:\n%#v\n%#v", a, b)
+		}
+		*b.Red.MaxP = 43
+		if fmt.Sprintf("%#v", a) == fmt.Sprintf("%#v", b) {
+			t.Fatalf("dumps of different objects are equal: %#v", a)
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package tc
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+)
+
+const (
+	// handlePoolMinMinor is the smallest minor handed out by a HandlePool.
+	// A minor of 0 refers to the qdisc itself.
+	handlePoolMinMinor = 0x1
+	// handlePoolMaxMinor is the largest minor handed out by a HandlePool.
+	// A minor of 0xFFFF is reserved, e.g. for TC_H_ROOT and the ingress qdisc.
+	handlePoolMaxMinor = 0xFFFE
+)
+
+// HandlePool hands out collision-free class handles below a single major.
+// It is safe for concurrent use.
+type HandlePool struct {
+	mu    sync.Mutex
+	major uint16
+	used  map[uint16]bool
+	next  uint16
+}
+
+// NewHandlePool returns a HandlePool for major. Handles of inUse, like the handles
+// of a Class().Get() dump, are considered as already allocated. Handles of inUse
+// with a different major are ignored.
+func NewHandlePool(major uint16, inUse []uint32) *HandlePool {
+	p := &HandlePool{
+		major: major,
+		used:  make(map[uint16]bool),
+		next:  handlePoolMinMinor,
+	}
+	for _, handle := range inUse {
+		maj, min := core.SplitHandle(handle)
+		if maj == uint32(major) {
+			p.used[uint16(min)] = true
+		}
+	}
+	return p
+}
+
+// Get allocates and returns a free handle.
+func (p *HandlePool) Get() (uint32, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	min := p.next
+	for i := 0; i < handlePoolMaxMinor; i++ {
+		if !p.used[min] {
+			p.used[min] = true
+			p.next = min + 1
+			if p.next > handlePoolMaxMinor {
+				p.next = handlePoolMinMinor
+			}
+			return core.BuildHandle(uint32(p.major), uint32(min)), nil
+		}
+		min++
+		if min > handlePoolMaxMinor {
+			min = handlePoolMinMinor
+		}
+	}
+	return 0, fmt.Errorf("major %x: %w", p.major, ErrNoFreeHandle)
+}
+
+// Reserve marks handle as allocated, so that it is not returned by Get.
+func (p *HandlePool) Reserve(handle uint32) error {
+	min, err := p.minor(handle)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.used[min] {
+		return fmt.Errorf("handle %x:%x is already in use: %w", p.major, min, ErrInvalidArg)
+	}
+	p.used[min] = true
+	return nil
+}
+
+// Release returns handle to the pool, so that it can be allocated again.
+func (p *HandlePool) Release(handle uint32) error {
+	min, err := p.minor(handle)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.used[min] {
+		return fmt.Errorf("handle %x:%x is not in use: %w", p.major, min, ErrInvalidArg)
+	}
+	delete(p.used, min)
+	return nil
+}
+
+// minor returns the minor of handle, if it can be managed by the pool.
+func (p *HandlePool) minor(handle uint32) (uint16, error) {
+	maj, min := core.SplitHandle(handle)
+	if maj != uint32(p.major) {
+		return 0, fmt.Errorf("handle %x:%x does not belong to major %x: %w", maj, min, p.major, ErrInvalidArg)
+	}
+	if min < handlePoolMinMinor || min > handlePoolMaxMinor {
+		return 0, fmt.Errorf("handle %x:%x has a reserved minor: %w", maj, min, ErrInvalidArg)
+	}
+	return uint16(min), nil
+}
+
+// NextFreeClassID scans the classes of the network interface ifindex and returns the
+// first class handle below major, that is not in use.
+func (c *Class) NextFreeClassID(ifindex uint32, major uint16) (uint32, error) {
+	classes, err := c.Get(&Msg{Family: unix.AF_UNSPEC, Ifindex: ifindex})
+	if err != nil {
+		return 0, err
+	}
+	handles := make([]uint32, 0, len(classes))
+	for _, class := range classes {
+		handles = append(handles, class.Handle)
+	}
+	return NewHandlePool(major, handles).Get()
+}
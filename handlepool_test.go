@@ -0,0 +1,130 @@
+package tc
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+)
+
+func TestHandlePool(t *testing.T) {
+	p := NewHandlePool(0x1, []uint32{0x10001, 0x10002, 0x10004, 0x20003, 0x10000})
+
+	for _, want := range []uint32{0x10003, 0x10005} {
+		got, err := p.Get()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != want {
+			t.Fatalf("expected %x but got %x", want, got)
+		}
+	}
+
+	if err := p.Release(0x10002); err != nil {
+		t.Fatalf("could not release handle: %v", err)
+	}
+	if err := p.Release(0x10002); !errors.Is(err, ErrInvalidArg) {
+		t.Fatalf("expected ErrInvalidArg for releasing a free handle but got %v", err)
+	}
+	if err := p.Reserve(0x10006); err != nil {
+		t.Fatalf("could not reserve handle: %v", err)
+	}
+	if err := p.Reserve(0x10006); !errors.Is(err, ErrInvalidArg) {
+		t.Fatalf("expected ErrInvalidArg for reserving a used handle but got %v", err)
+	}
+	if got, _ := p.Get(); got != 0x10007 {
+		t.Fatalf("expected 10007 but got %x", got)
+	}
+
+	for name, handle := range map[string]uint32{
+		"minor 0":       0x10000,
+		"minor ffff":    0x1FFFF,
+		"foreign major": 0x20001,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := p.Reserve(handle); !errors.Is(err, ErrInvalidArg) {
+				t.Fatalf("expected ErrInvalidArg but got %v", err)
+			}
+			if err := p.Release(handle); !errors.Is(err, ErrInvalidArg) {
+				t.Fatalf("expected ErrInvalidArg but got %v", err)
+			}
+		})
+	}
+}
+
+func TestHandlePoolExhausted(t *testing.T) {
+	p := NewHandlePool(0x1, nil)
+	for i := 0; i < 0xFFFE; i++ {
+		handle, err := p.Get()
+		if err != nil {
+			t.Fatalf("unexpected error after %d handles: %v", i, err)
+		}
+		if _, min := core.SplitHandle(handle); min == 0 || min == 0xFFFF {
+			t.Fatalf("reserved handle %x was handed out", handle)
+		}
+	}
+	if _, err := p.Get(); !errors.Is(err, ErrNoFreeHandle) {
+		t.Fatalf("expected ErrNoFreeHandle but got %v", err)
+	}
+
+	// A released handle is handed out again, even if it is below the last one.
+	if err := p.Release(0x10010); err != nil {
+		t.Fatalf("could not release handle: %v", err)
+	}
+	if got, err := p.Get(); err != nil || got != 0x10010 {
+		t.Fatalf("expected 10010 but got %x and %v", got, err)
+	}
+}
+
+func TestHandlePoolConcurrent(t *testing.T) {
+	p := NewHandlePool(0x1, nil)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	seen := map[uint32]bool{}
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				handle, err := p.Get()
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+				mu.Lock()
+				if seen[handle] {
+					t.Errorf("handle %x was handed out twice", handle)
+				}
+				seen[handle] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != 800 {
+		t.Fatalf("expected 800 handles but got %d", len(seen))
+	}
+}
+
+func TestNextFreeClassID(t *testing.T) {
+	tcSocket, _, stored := htbBuilderConn(t, 0)
+	defer tcSocket.Close()
+
+	for _, handle := range []uint32{0x10001, 0x10002, 0x10004} {
+		stored[handle] = Object{
+			Msg{Family: unix.AF_UNSPEC, Ifindex: 42, Handle: handle, Parent: 0x10000},
+			Attribute{Kind: "htb"},
+		}
+	}
+
+	handle, err := tcSocket.Class().NextFreeClassID(42, 0x1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if handle != 0x10003 {
+		t.Fatalf("expected 10003 but got %x", handle)
+	}
+}
@@ -1,7 +1,11 @@
 package tc
 
 import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
 	"net"
+	"strings"
 )
 
 // ipToUint32 converts a legacy ip object to its uint32 representative.
@@ -62,3 +66,42 @@ func bytesToInt32(b []byte) int32 {
 	}
 	return int32(b[0])<<24 | int32(b[1])<<16 | int32(b[2])<<8 | int32(b[3])
 }
+
+// ntohs converts a value in network byte order, that was read in native byte order.
+func ntohs(in uint16) uint16 {
+	b := make([]byte, 2)
+	nativeEndian.PutUint16(b, in)
+	return binary.BigEndian.Uint16(b)
+}
+
+// ntohl converts a value in network byte order, that was read in native byte order.
+func ntohl(in uint32) uint32 {
+	b := make([]byte, 4)
+	nativeEndian.PutUint32(b, in)
+	return binary.BigEndian.Uint32(b)
+}
+
+// kindMaxLen is the maximum length of a kind. The kernel copies kinds into
+// buffers of IFNAMSIZ bytes, that include the terminating NUL.
+const kindMaxLen = 15
+
+// validateKind checks, that kind can be sent as attribute to the kernel.
+func validateKind(kind string) error {
+	if i := strings.IndexByte(kind, 0x0); i >= 0 {
+		return fmt.Errorf("kind %q contains a NUL at offset %d: %w", kind, i, ErrInvalidArg)
+	}
+	if len(kind) > kindMaxLen {
+		return fmt.Errorf("kind %q is longer than %d bytes: %w", kind, kindMaxLen, ErrInvalidArg)
+	}
+	return nil
+}
+
+// kindString returns the kind from the payload of a kind attribute. Some
+// kernels pad the payload with NULs after the terminating one, so everything
+// from the first NUL on is dropped.
+func kindString(data []byte) string {
+	if i := bytes.IndexByte(data, 0x0); i >= 0 {
+		data = data[:i]
+	}
+	return string(data)
+}
@@ -5,6 +5,7 @@ import (
 	"net"
 	"testing"
 
+	"github.com/florianl/go-tc/internal/unix"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -158,3 +159,66 @@ func TestBytesToInt32(t *testing.T) {
 		}
 	}
 }
+
+func TestKind(t *testing.T) {
+	tests := map[string]struct {
+		kind string
+		err  error
+	}{
+		"valid":    {kind: "fq_codel"},
+		"empty":    {kind: ""},
+		"max":      {kind: "abcdefghijklmno"},
+		"too long": {kind: "abcdefghijklmnop", err: ErrInvalidArg},
+		"nul":      {kind: "fq_codel\x00", err: ErrInvalidArg},
+		"embedded": {kind: "fq\x00codel", err: ErrInvalidArg},
+	}
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := validateKind(testcase.kind); !errors.Is(err, testcase.err) {
+				t.Fatalf("expected error %v but got %v", testcase.err, err)
+			}
+		})
+	}
+
+	decode := map[string]struct {
+		data []byte
+		kind string
+	}{
+		"terminated": {data: []byte("fq_codel\x00"), kind: "fq_codel"},
+		"padded":     {data: []byte("fq_codel\x00\x00\x00\x00"), kind: "fq_codel"},
+		"garbage":    {data: []byte("htb\x00\x01\x02"), kind: "htb"},
+		"plain":      {data: []byte("sfq"), kind: "sfq"},
+		"empty":      {data: []byte{}, kind: ""},
+	}
+	for name, testcase := range decode {
+		t.Run("decode "+name, func(t *testing.T) {
+			if kind := kindString(testcase.data); kind != testcase.kind {
+				t.Fatalf("expected %q but got %q", testcase.kind, kind)
+			}
+		})
+	}
+
+	t.Run("marshal", func(t *testing.T) {
+		tcSocket, done := testConn(t)
+		defer done()
+
+		info := &Object{
+			Msg:       Msg{Family: unix.AF_UNSPEC, Ifindex: 42, Handle: 0x10000, Parent: HandleRoot, Info: 0x300},
+			Attribute: Attribute{Kind: "htb\x00"},
+		}
+		if err := tcSocket.Qdisc().Replace(info); !errors.Is(err, ErrInvalidArg) {
+			t.Fatalf("qdisc: expected ErrInvalidArg but got %v", err)
+		}
+		if err := tcSocket.Class().Replace(info); !errors.Is(err, ErrInvalidArg) {
+			t.Fatalf("class: expected ErrInvalidArg but got %v", err)
+		}
+		info.Kind = "matchall_with_a_long_name"
+		if err := tcSocket.Filter().Replace(info); !errors.Is(err, ErrInvalidArg) {
+			t.Fatalf("filter: expected ErrInvalidArg but got %v", err)
+		}
+		info.Handle = 1
+		if err := tcSocket.Filter().Delete(info); !errors.Is(err, ErrInvalidArg) {
+			t.Fatalf("filter delete: expected ErrInvalidArg but got %v", err)
+		}
+	})
+}
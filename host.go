@@ -0,0 +1,92 @@
+package tc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/florianl/go-tc/core"
+)
+
+// fallbackQdisc is the default qdisc of linux/net/sched/sch_generic.c for
+// kernels without net.core.default_qdisc.
+const fallbackQdisc = "pfifo_fast"
+
+var (
+	defaultQdiscOnce sync.Once
+	defaultQdiscKind string
+	defaultQdiscErr  error
+
+	pschedOnce sync.Once
+	psched     core.Psched
+	pschedErr  error
+)
+
+// procFile returns the path of name beneath /proc or beneath PROC_ROOT, if it
+// is set, like package core does for /proc/net/psched.
+func procFile(name string) string {
+	if procRoot := os.Getenv("PROC_ROOT"); procRoot != "" {
+		return fmt.Sprintf("%s/%s", procRoot, name)
+	}
+	return "/proc/" + name
+}
+
+// DefaultQdiscKind returns the kind of the qdisc, that the kernel attaches
+// to network interfaces by default, from the sysctl net.core.default_qdisc.
+// Multiqueue devices get mq with such a qdisc for every queue. The sysctl is
+// read once and the result is cached. Without the sysctl, pfifo_fast is
+// returned. If the sysctl can not be read, pfifo_fast is returned together
+// with the error.
+func DefaultQdiscKind() (string, error) {
+	defaultQdiscOnce.Do(func() {
+		defaultQdiscKind, defaultQdiscErr = readDefaultQdisc()
+	})
+	return defaultQdiscKind, defaultQdiscErr
+}
+
+// SetDefaultQdiscKind overrides the kind, that DefaultQdiscKind returns, and
+// returns a function to restore the previous value. It allows tests to be
+// independent of the host. SetDefaultQdiscKind must not be called
+// concurrently with DefaultQdiscKind.
+func SetDefaultQdiscKind(kind string) func() {
+	prevKind, prevErr := DefaultQdiscKind()
+	defaultQdiscKind, defaultQdiscErr = kind, nil
+	return func() {
+		defaultQdiscKind, defaultQdiscErr = prevKind, prevErr
+	}
+}
+
+// readDefaultQdisc reads net.core.default_qdisc.
+func readDefaultQdisc() (string, error) {
+	data, err := ioutil.ReadFile(procFile("sys/net/core/default_qdisc"))
+	if os.IsNotExist(err) {
+		return fallbackQdisc, nil
+	}
+	if err != nil {
+		return fallbackQdisc, fmt.Errorf("could not read net.core.default_qdisc: %w", err)
+	}
+	return parseDefaultQdisc(data)
+}
+
+// parseDefaultQdisc parses the content of /proc/sys/net/core/default_qdisc.
+func parseDefaultQdisc(data []byte) (string, error) {
+	kind := strings.TrimSpace(string(data))
+	if kind == "" || strings.ContainsAny(kind, " \t\n") {
+		return fallbackQdisc, fmt.Errorf("net.core.default_qdisc %q: %w", kind, ErrInvalidArg)
+	}
+	return kind, nil
+}
+
+// PschedInfo returns the parameters of the clock of the packet scheduler from
+// /proc/net/psched, that the time conversions of package core are based on.
+// The file is read once and the result is cached. Unlike the conversions,
+// which fall back to defaults, the error is returned, if the file can not be
+// read.
+func PschedInfo() (core.Psched, error) {
+	pschedOnce.Do(func() {
+		psched, pschedErr = core.ReadPsched()
+	})
+	return psched, pschedErr
+}
@@ -0,0 +1,55 @@
+//go:build linux && go1.17
+// +build linux,go1.17
+
+package tc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadDefaultQdisc(t *testing.T) {
+	t.Run("Read from system", func(t *testing.T) {
+		if _, err := readDefaultQdisc(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("fixture", func(t *testing.T) {
+		procRoot := t.TempDir()
+		if err := os.MkdirAll(filepath.Join(procRoot, "sys/net/core"), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(procRoot, "sys/net/core/default_qdisc"),
+			[]byte("fq_codel\n"), 0640); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("PROC_ROOT", procRoot)
+
+		if kind, err := readDefaultQdisc(); kind != "fq_codel" || err != nil {
+			t.Fatalf("expected fq_codel but got %q, %v", kind, err)
+		}
+	})
+
+	t.Run("sysctl does not exist", func(t *testing.T) {
+		t.Setenv("PROC_ROOT", t.TempDir())
+
+		if kind, err := readDefaultQdisc(); kind != "pfifo_fast" || err != nil {
+			t.Fatalf("expected pfifo_fast but got %q, %v", kind, err)
+		}
+	})
+
+	t.Run("sysctl can not be read", func(t *testing.T) {
+		procRoot := t.TempDir()
+		if err := os.MkdirAll(filepath.Join(procRoot, "sys/net/core/default_qdisc"), 0750); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("PROC_ROOT", procRoot)
+
+		if kind, err := readDefaultQdisc(); kind != "pfifo_fast" || err == nil {
+			t.Fatalf("expected pfifo_fast with an error but got %q, %v", kind, err)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package tc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/florianl/go-tc/core"
+)
+
+func TestParseDefaultQdisc(t *testing.T) {
+	tests := map[string]struct {
+		data string
+		kind string
+		err  error
+	}{
+		"fq_codel":   {data: "fq_codel\n", kind: "fq_codel"},
+		"pfifo_fast": {data: "pfifo_fast\n", kind: "pfifo_fast"},
+		"no newline": {data: "sfq", kind: "sfq"},
+		"empty":      {data: "\n", kind: "pfifo_fast", err: ErrInvalidArg},
+		"two words":  {data: "fq codel\n", kind: "pfifo_fast", err: ErrInvalidArg},
+	}
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			kind, err := parseDefaultQdisc([]byte(testcase.data))
+			if !errors.Is(err, testcase.err) {
+				t.Fatalf("expected %v but got %v", testcase.err, err)
+			}
+			if kind != testcase.kind {
+				t.Fatalf("expected %q but got %q", testcase.kind, kind)
+			}
+		})
+	}
+}
+
+func TestSetDefaultQdiscKind(t *testing.T) {
+	hostKind, hostErr := DefaultQdiscKind()
+	restore := SetDefaultQdiscKind("cake")
+	if kind, err := DefaultQdiscKind(); kind != "cake" || err != nil {
+		t.Fatalf("expected cake but got %q, %v", kind, err)
+	}
+	restore()
+	if kind, err := DefaultQdiscKind(); kind != hostKind || err != hostErr {
+		t.Fatalf("expected %q, %v but got %q, %v", hostKind, hostErr, kind, err)
+	}
+}
+
+func TestPschedInfo(t *testing.T) {
+	want, wantErr := core.ReadPsched()
+	for i := 0; i < 2; i++ {
+		psched, err := PschedInfo()
+		if (err == nil) != (wantErr == nil) {
+			t.Fatalf("expected %v but got %v", wantErr, err)
+		}
+		if psched != want {
+			t.Fatalf("expected %+v but got %+v", want, psched)
+		}
+	}
+}
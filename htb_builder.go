@@ -0,0 +1,363 @@
+package tc
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+)
+
+const (
+	// htbDefaultMtu is the mtu iproute2/tc/q_htb.c uses to calculate the default burst size.
+	htbDefaultMtu = 1600
+	// htbHz is the value iproute2/tc/tc_core.c:get_hz() returns for high resolution timers.
+	htbHz = 1000000000
+)
+
+// HtbBuilder assembles a HTB hierarchy of a root qdisc, its classes and their leaf
+// qdiscs. Handles of leaf qdiscs are allocated automatically and parents filled in,
+// so that the resulting objects can be applied in dependency order.
+type HtbBuilder struct {
+	ifindex uint32
+	root    *HtbNode
+	err     error
+}
+
+// HtbNode is either the root qdisc or a class in the hierarchy of a HtbBuilder.
+type HtbNode struct {
+	builder  *HtbBuilder
+	obj      Object
+	children []*HtbNode
+	leaf     *Object
+}
+
+// NewHtbBuilder returns a HtbBuilder for the network interface ifindex.
+func NewHtbBuilder(ifindex uint32) *HtbBuilder {
+	return &HtbBuilder{ifindex: ifindex}
+}
+
+// Root sets up the HTB root qdisc with the handle 1: . Unclassified traffic is sent to
+// the class with the minor defcls.
+func (b *HtbBuilder) Root(defcls uint32) *HtbNode {
+	b.root = &HtbNode{
+		builder: b,
+		obj: Object{
+			Msg{
+				Family:  unix.AF_UNSPEC,
+				Ifindex: b.ifindex,
+				Handle:  core.BuildHandle(0x1, 0x0),
+				Parent:  HandleRoot,
+			},
+			Attribute{
+				Kind: "htb",
+				Htb: &Htb{
+					Init: &HtbGlob{
+						Version:      0x3,
+						Rate2Quantum: 0xa,
+						Defcls:       defcls,
+					},
+				},
+			},
+		},
+	}
+	return b.root
+}
+
+// Class adds a HTB class with the given classid, like "1:10", below n. An empty
+// major, like in ":10", refers to the major of the root qdisc. rate and ceil are
+// in bytes per second. If ceil is zero, rate is used as ceil.
+func (n *HtbNode) Class(classid string, rate, ceil uint64) *HtbNode {
+	b := n.builder
+	child := &HtbNode{builder: b}
+	n.children = append(n.children, child)
+
+	handle, err := core.ParseHandle(classid)
+	if err != nil {
+		b.err = concatError(b.err, fmt.Errorf("htb class %q: %w", classid, ErrInvalidArg))
+		return child
+	}
+	rootMaj, _ := core.SplitHandle(b.root.obj.Handle)
+	maj, min := core.SplitHandle(handle)
+	if maj == 0 {
+		maj = rootMaj
+	}
+	if maj != rootMaj || min == 0 {
+		b.err = concatError(b.err, fmt.Errorf("htb class %q does not belong to root %x: or has minor 0: %w",
+			classid, rootMaj, ErrInvalidArg))
+		return child
+	}
+	for _, c := range b.classes() {
+		if c != child && c.obj.Handle == core.BuildHandle(maj, min) {
+			b.err = concatError(b.err, fmt.Errorf("htb class %q is used multiple times: %w",
+				classid, ErrInvalidArg))
+			return child
+		}
+	}
+
+	htb, err := htbClassOptions(rate, ceil)
+	if err != nil {
+		b.err = concatError(b.err, fmt.Errorf("htb class %q: %w", classid, err))
+	}
+
+	child.obj = Object{
+		Msg{
+			Family:  unix.AF_UNSPEC,
+			Ifindex: b.ifindex,
+			Handle:  core.BuildHandle(maj, min),
+			Parent:  n.obj.Handle,
+		},
+		Attribute{
+			Kind: "htb",
+			Htb:  htb,
+		},
+	}
+	return child
+}
+
+// Leaf attaches a qdisc of the given kind with its options, like &FqCodel{}, to the
+// class n. The handle of the leaf qdisc is allocated when the objects are built.
+func (n *HtbNode) Leaf(kind string, options interface{}) *HtbNode {
+	b := n.builder
+	if n == b.root {
+		b.err = concatError(b.err, fmt.Errorf("leaf %s can not be attached to the root qdisc: %w",
+			kind, ErrInvalidArg))
+		return n
+	}
+	attr := Attribute{Kind: kind}
+	if err := setQdiscOptions(&attr, options); err != nil {
+		b.err = concatError(b.err, fmt.Errorf("leaf %s: %w", kind, err))
+		return n
+	}
+	n.leaf = &Object{
+		Msg{
+			Family:  unix.AF_UNSPEC,
+			Ifindex: b.ifindex,
+		},
+		attr,
+	}
+	return n
+}
+
+// Objects returns the root qdisc, the classes and the leaf qdiscs of the hierarchy.
+// Every object is returned after its parent.
+func (b *HtbBuilder) Objects() ([]Object, error) {
+	return b.objects(nil)
+}
+
+// objects returns the objects of the hierarchy like Objects. The handles of
+// the leaf qdiscs avoid the majors of qdiscs, which exist already on the
+// device, like AttachLeaf. An existing qdisc of the same kind beneath a class
+// keeps its handle.
+func (b *HtbBuilder) objects(qdiscs []Object) ([]Object, error) {
+	if b.root == nil {
+		return nil, fmt.Errorf("htb root: %w", ErrNoArg)
+	}
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	objs := []Object{b.root.obj}
+	var leafs []*HtbNode
+	rootMaj, _ := core.SplitHandle(b.root.obj.Handle)
+	used := map[uint16]bool{uint16(rootMaj): true}
+	for _, qdisc := range qdiscs {
+		if qdisc.Ifindex != b.ifindex || qdisc.Handle == 0 {
+			continue
+		}
+		maj, _ := core.SplitHandle(qdisc.Handle)
+		used[uint16(maj)] = true
+	}
+
+	for _, c := range b.classes() {
+		objs = append(objs, c.obj)
+		if c.leaf == nil {
+			continue
+		}
+		if len(c.children) != 0 {
+			return nil, fmt.Errorf("htb class %x:%x has children and can not have a leaf qdisc: %w",
+				rootMaj, c.obj.Handle&0xFFFF, ErrInvalidArg)
+		}
+		leafs = append(leafs, c)
+	}
+
+	for _, c := range leafs {
+		leaf := *c.leaf
+		leaf.Parent = c.obj.Handle
+		for _, qdisc := range qdiscs {
+			if qdisc.Ifindex == b.ifindex && qdisc.Handle != 0 && qdisc.Parent == leaf.Parent &&
+				qdisc.Kind == leaf.Kind {
+				leaf.Handle = qdisc.Handle
+			}
+		}
+		if leaf.Handle == 0 {
+			leaf.Handle = core.BuildHandle(uint32(leafMajor(c.obj.Handle, used)), 0x0)
+		}
+		objs = append(objs, leaf)
+	}
+	return objs, nil
+}
+
+// Apply creates or updates the hierarchy on tcSocket. As all objects are sent with
+// Replace, applying the same hierarchy again is safe. If an object can not be applied,
+// all objects, that did not exist before Apply was called, are removed again. The
+// leaf qdiscs get handles, that are not used by other qdiscs of the device.
+func (b *HtbBuilder) Apply(tcSocket *Tc) error {
+	if _, err := b.Objects(); err != nil {
+		return err
+	}
+
+	qdiscs, err := tcSocket.Qdisc().Get()
+	if err != nil {
+		return err
+	}
+	objs, err := b.objects(qdiscs)
+	if err != nil {
+		return err
+	}
+	classes, err := tcSocket.Class().Get(&Msg{Family: unix.AF_UNSPEC, Ifindex: b.ifindex})
+	if err != nil {
+		return err
+	}
+	existing := map[uint32]bool{}
+	for _, qdisc := range qdiscs {
+		if qdisc.Ifindex == b.ifindex && qdisc.Handle != 0 {
+			existing[qdisc.Handle] = true
+		}
+	}
+	for _, class := range classes {
+		existing[class.Handle] = true
+	}
+
+	var created []Object
+	for i := range objs {
+		obj := &objs[i]
+		if _, min := core.SplitHandle(obj.Handle); min == 0 {
+			err = tcSocket.Qdisc().Replace(obj)
+		} else {
+			err = tcSocket.Class().Replace(obj)
+		}
+		if err != nil {
+			err = fmt.Errorf("could not apply %s %x: %w", obj.Kind, obj.Handle, err)
+			return concatError(err, b.rollback(tcSocket, created))
+		}
+		if !existing[obj.Handle] {
+			created = append(created, *obj)
+		}
+	}
+	return nil
+}
+
+// rollback removes objs in reverse order.
+func (b *HtbBuilder) rollback(tcSocket *Tc, objs []Object) error {
+	var multiError error
+	for i := len(objs) - 1; i >= 0; i-- {
+		obj := &objs[i]
+		var err error
+		if _, min := core.SplitHandle(obj.Handle); min == 0 {
+			err = tcSocket.Qdisc().Delete(obj)
+		} else {
+			err = tcSocket.Class().Delete(obj)
+		}
+		if err != nil {
+			multiError = concatError(multiError, fmt.Errorf("could not roll back %s %x: %w",
+				obj.Kind, obj.Handle, err))
+		}
+	}
+	return multiError
+}
+
+// classes returns all classes of the hierarchy with parents before their children.
+func (b *HtbBuilder) classes() []*HtbNode {
+	var result []*HtbNode
+	queue := append([]*HtbNode{}, b.root.children...)
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		result = append(result, n)
+		queue = append(queue, n.children...)
+	}
+	return result
+}
+
+// htbClassOptions implements the rate and burst handling of iproute2/tc/q_htb.c:htb_parse_class_opt().
+func htbClassOptions(rate, ceil uint64) (*Htb, error) {
+	if rate == 0 {
+		return nil, fmt.Errorf("rate: %w", ErrInvalidArg)
+	}
+	if ceil == 0 {
+		ceil = rate
+	}
+	htb := &Htb{}
+
+	rateSpec, err := htbRateSpec(rate)
+	if err != nil {
+		return nil, err
+	}
+	ceilSpec, err := htbRateSpec(ceil)
+	if err != nil {
+		return nil, err
+	}
+	if rate > math.MaxUint32 {
+		htb.Rate64 = uint64Ptr(rate)
+	}
+	if ceil > math.MaxUint32 {
+		htb.Ceil64 = uint64Ptr(ceil)
+	}
+
+	buffer := uint32(rate/htbHz + htbDefaultMtu)
+	cbuffer := uint32(ceil/htbHz + htbDefaultMtu)
+	htb.Parms = &HtbOpt{
+		Rate:    rateSpec,
+		Ceil:    ceilSpec,
+		Buffer:  core.XmitTime(rate, buffer),
+		Cbuffer: core.XmitTime(ceil, cbuffer),
+	}
+	return htb, nil
+}
+
+func htbRateSpec(rate uint64) (RateSpec, error) {
+	return NewRateSpec(rate, 0, LinklayerEthernet, 0, 0)
+}
+
+// setQdiscOptions stores the options of a classless qdisc in attr.
+func setQdiscOptions(attr *Attribute, options interface{}) error {
+	switch opt := options.(type) {
+	case nil:
+	case *Cake:
+		attr.Cake = opt
+	case *FqCodel:
+		attr.FqCodel = opt
+	case *Codel:
+		attr.Codel = opt
+	case *Fq:
+		attr.Fq = opt
+	case *Pie:
+		attr.Pie = opt
+	case *Hhf:
+		attr.Hhf = opt
+	case *Tbf:
+		attr.Tbf = opt
+	case *Sfb:
+		attr.Sfb = opt
+	case *Sfq:
+		attr.Sfq = opt
+	case *Red:
+		attr.Red = opt
+	case *Choke:
+		attr.Choke = opt
+	case *Netem:
+		attr.Netem = opt
+	case *Plug:
+		attr.Plug = opt
+	case *FifoOpt:
+		if attr.Kind == "bfifo" {
+			attr.Bfifo = opt
+		} else {
+			attr.Pfifo = opt
+		}
+	default:
+		return fmt.Errorf("options of type %T: %w", options, ErrNotImplemented)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+//go:build integration && linux
+// +build integration,linux
+
+package tc
+
+import (
+	"net"
+	"testing"
+
+	"github.com/jsimonetti/rtnetlink"
+	"golang.org/x/sys/unix"
+)
+
+func TestLinuxHtbBuilder(t *testing.T) {
+	tcIface := "tcHtbBuilder"
+
+	rtnl, err := setupDummyInterface(tcIface)
+	if err != nil {
+		t.Skipf("could not setup dummy interface: %v", err)
+	}
+	defer rtnl.Close()
+
+	devID, err := net.InterfaceByName(tcIface)
+	if err != nil {
+		t.Fatalf("could not get interface ID: %v", err)
+	}
+	defer func(devID uint32, rtnl *rtnetlink.Conn) {
+		if err := rtnl.Link.Delete(devID); err != nil {
+			t.Fatalf("could not delete interface: %v", err)
+		}
+	}(uint32(devID.Index), rtnl)
+
+	tcnl, err := Open(&Config{})
+	if err != nil {
+		t.Fatalf("could not open rtnetlink socket: %v", err)
+	}
+	defer func() {
+		if err := tcnl.Close(); err != nil {
+			t.Fatalf("could not close rtnetlink socket: %v", err)
+		}
+	}()
+
+	b := NewHtbBuilder(uint32(devID.Index))
+	root := b.Root(0x30)
+	root.Class("1:10", 1250000, 12500000).Leaf("fq_codel", &FqCodel{})
+	root.Class("1:20", 1250000, 12500000).Leaf("fq_codel", &FqCodel{})
+	root.Class("1:30", 125000, 0).Leaf("sfq", &Sfq{})
+
+	if err := b.Apply(tcnl); err != nil {
+		t.Fatalf("could not apply htb hierarchy: %v", err)
+	}
+	// Applying the hierarchy a second time must succeed as well.
+	if err := b.Apply(tcnl); err != nil {
+		t.Fatalf("could not apply htb hierarchy again: %v", err)
+	}
+
+	classes, err := tcnl.Class().Get(&Msg{Family: unix.AF_UNSPEC, Ifindex: uint32(devID.Index)})
+	if err != nil {
+		t.Fatalf("could not get classes: %v", err)
+	}
+	gotClasses := map[uint32]uint32{}
+	for _, class := range classes {
+		if class.Kind == "htb" {
+			gotClasses[class.Handle] = class.Htb.Parms.Rate.Rate
+		}
+	}
+	for handle, rate := range map[uint32]uint32{0x10010: 1250000, 0x10020: 1250000, 0x10030: 125000} {
+		if gotClasses[handle] != rate {
+			t.Fatalf("class %x: expected rate %d but got %d", handle, rate, gotClasses[handle])
+		}
+	}
+
+	qdiscs, err := tcnl.Qdisc().Get()
+	if err != nil {
+		t.Fatalf("could not get qdiscs: %v", err)
+	}
+	gotLeafs := map[uint32]string{}
+	for _, qdisc := range qdiscs {
+		if qdisc.Ifindex == uint32(devID.Index) {
+			gotLeafs[qdisc.Parent] = qdisc.Kind
+		}
+	}
+	for parent, kind := range map[uint32]string{HandleRoot: "htb", 0x10010: "fq_codel", 0x10020: "fq_codel", 0x10030: "sfq"} {
+		if gotLeafs[parent] != kind {
+			t.Fatalf("parent %x: expected %s but got %s", parent, kind, gotLeafs[parent])
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package tc
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"syscall"
+	"testing"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nltest"
+)
+
+type htbBuilderRequest struct {
+	Type   netlink.HeaderType
+	Kind   string
+	Handle uint32
+	Parent uint32
+}
+
+func newTestHtbBuilder() *HtbBuilder {
+	b := NewHtbBuilder(42)
+	root := b.Root(0x30)
+	tenants := root.Class("1:1", 12500000, 0)
+	tenants.Class("1:10", 1250000, 12500000).Leaf("fq_codel", &FqCodel{Target: uint32Ptr(5000)})
+	tenants.Class("1:20", 1250000, 12500000).Leaf("fq_codel", &FqCodel{Target: uint32Ptr(5000)})
+	root.Class(":30", 125000, 0).Leaf("sfq", &Sfq{})
+	return b
+}
+
+func TestHtbBuilderObjects(t *testing.T) {
+	objs, err := newTestHtbBuilder().Objects()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got []htbBuilderRequest
+	for _, obj := range objs {
+		if obj.Ifindex != 42 {
+			t.Fatalf("%s %x: unexpected ifindex %d", obj.Kind, obj.Handle, obj.Ifindex)
+		}
+		got = append(got, htbBuilderRequest{Kind: obj.Kind, Handle: obj.Handle, Parent: obj.Parent})
+	}
+	want := []htbBuilderRequest{
+		{Kind: "htb", Handle: 0x10000, Parent: HandleRoot},
+		{Kind: "htb", Handle: 0x10001, Parent: 0x10000},
+		{Kind: "htb", Handle: 0x10030, Parent: 0x10000},
+		{Kind: "htb", Handle: 0x10010, Parent: 0x10001},
+		{Kind: "htb", Handle: 0x10020, Parent: 0x10001},
+		{Kind: "sfq", Handle: 0x300000, Parent: 0x10030},
+		{Kind: "fq_codel", Handle: 0x100000, Parent: 0x10010},
+		{Kind: "fq_codel", Handle: 0x200000, Parent: 0x10020},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("hierarchy missmatch (-want +got):\n%s", diff)
+	}
+
+	if diff := cmp.Diff(&HtbGlob{Version: 3, Rate2Quantum: 10, Defcls: 0x30}, objs[0].Htb.Init); diff != "" {
+		t.Fatalf("root options missmatch (-want +got):\n%s", diff)
+	}
+	parms := objs[3].Htb.Parms
+	if parms.Rate.Rate != 1250000 || parms.Ceil.Rate != 12500000 {
+		t.Fatalf("unexpected rate %d and ceil %d", parms.Rate.Rate, parms.Ceil.Rate)
+	}
+	if parms.Rate.Linklayer != uint8(LinklayerEthernet) {
+		t.Fatalf("unexpected linklayer %d", parms.Rate.Linklayer)
+	}
+	if parms.Buffer != core.XmitTime(1250000, htbDefaultMtu) {
+		t.Fatalf("unexpected buffer %d", parms.Buffer)
+	}
+	if objs[2].Htb.Parms.Ceil.Rate != 125000 {
+		t.Fatalf("ceil does not default to rate: %d", objs[2].Htb.Parms.Ceil.Rate)
+	}
+}
+
+func TestHtbBuilderRate64(t *testing.T) {
+	b := NewHtbBuilder(42)
+	b.Root(0x10).Class("1:10", 1<<33, 0)
+	objs, err := b.Objects()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	htb := objs[1].Htb
+	if htb.Parms.Rate.Rate != 0xFFFFFFFF || htb.Rate64 == nil || *htb.Rate64 != 1<<33 {
+		t.Fatalf("unexpected 64-bit rate: %d %v", htb.Parms.Rate.Rate, htb.Rate64)
+	}
+	if htb.Ceil64 == nil || *htb.Ceil64 != 1<<33 {
+		t.Fatalf("unexpected 64-bit ceil: %v", htb.Ceil64)
+	}
+}
+
+func TestHtbBuilderErrors(t *testing.T) {
+	tests := map[string]struct {
+		build func(b *HtbBuilder)
+		err   error
+	}{
+		"no root": {build: func(b *HtbBuilder) {}, err: ErrNoArg},
+		"invalid classid": {
+			build: func(b *HtbBuilder) { b.Root(0x10).Class("foo", 1000, 0) },
+			err:   ErrInvalidArg,
+		},
+		"foreign major": {
+			build: func(b *HtbBuilder) { b.Root(0x10).Class("2:10", 1000, 0) },
+			err:   ErrInvalidArg,
+		},
+		"minor 0": {
+			build: func(b *HtbBuilder) { b.Root(0x10).Class("1:", 1000, 0) },
+			err:   ErrInvalidArg,
+		},
+		"duplicate classid": {
+			build: func(b *HtbBuilder) {
+				root := b.Root(0x10)
+				root.Class("1:10", 1000, 0)
+				root.Class("1:1", 1000, 0).Class("1:10", 1000, 0)
+			},
+			err: ErrInvalidArg,
+		},
+		"no rate": {
+			build: func(b *HtbBuilder) { b.Root(0x10).Class("1:10", 0, 0) },
+			err:   ErrInvalidArg,
+		},
+		"leaf on root": {
+			build: func(b *HtbBuilder) { b.Root(0x10).Leaf("fq_codel", &FqCodel{}) },
+			err:   ErrInvalidArg,
+		},
+		"leaf on inner class": {
+			build: func(b *HtbBuilder) {
+				parent := b.Root(0x10).Class("1:1", 1000, 0)
+				parent.Class("1:10", 1000, 0)
+				parent.Leaf("fq_codel", &FqCodel{})
+			},
+			err: ErrInvalidArg,
+		},
+		"unsupported leaf options": {
+			build: func(b *HtbBuilder) { b.Root(0x10).Class("1:10", 1000, 0).Leaf("htb", &Htb{}) },
+			err:   ErrNotImplemented,
+		},
+	}
+
+	for name, testcase := range tests {
+		testcase := testcase
+		t.Run(name, func(t *testing.T) {
+			b := NewHtbBuilder(42)
+			testcase.build(b)
+			if _, err := b.Objects(); !errors.Is(err, testcase.err) {
+				t.Fatalf("expected %v but got %v", testcase.err, err)
+			}
+		})
+	}
+}
+
+// htbBuilderConn simulates the kernel side for HtbBuilder.Apply().
+// Qdiscs and classes are stored by their handle and a request for failHandle is rejected.
+func htbBuilderConn(t *testing.T, failHandle uint32) (*Tc, *[]htbBuilderRequest, map[uint32]Object) {
+	t.Helper()
+
+	var requests []htbBuilderRequest
+	stored := map[uint32]Object{}
+
+	dump := func(classes bool) ([]netlink.Message, error) {
+		var handles []uint32
+		for handle := range stored {
+			if _, min := core.SplitHandle(handle); (min != 0) == classes {
+				handles = append(handles, handle)
+			}
+		}
+		if len(handles) == 0 {
+			return nil, io.EOF
+		}
+		sort.Slice(handles, func(i, j int) bool { return handles[i] < handles[j] })
+		var msgs []netlink.Message
+		for _, handle := range handles {
+			obj := stored[handle]
+			data, err := marshalStruct(&obj.Msg)
+			if err != nil {
+				t.Fatalf("could not encode Msg: %v", err)
+			}
+			attrs, err := marshalAttributes([]tcOption{{Interpretation: vtString, Type: tcaKind, Data: obj.Kind}})
+			if err != nil {
+				t.Fatalf("could not encode attributes: %v", err)
+			}
+			msgs = append(msgs, netlink.Message{Data: append(data, attrs...)})
+		}
+		return msgs, nil
+	}
+
+	c := &Tc{
+		con: nltest.Dial(func(req []netlink.Message) ([]netlink.Message, error) {
+			if len(req) == 0 {
+				return nil, io.EOF
+			}
+			msg, attrs, err := unmarshalTcmsg(req[0].Data)
+			if err != nil {
+				t.Fatalf("could not decode Msg: %v", err)
+			}
+			var attr Attribute
+			switch req[0].Header.Type {
+			case unix.RTM_GETQDISC:
+				return dump(false)
+			case unix.RTM_GETTCLASS:
+				return dump(true)
+			case unix.RTM_NEWQDISC, unix.RTM_NEWTCLASS:
+				if err := extractTcmsgAttributes(int(req[0].Header.Type), attrs, &attr); err != nil {
+					t.Fatalf("could not decode attributes: %v", err)
+				}
+			}
+
+			requests = append(requests, htbBuilderRequest{
+				Type:   req[0].Header.Type,
+				Kind:   attr.Kind,
+				Handle: msg.Handle,
+				Parent: msg.Parent,
+			})
+			if msg.Handle == failHandle {
+				return nltest.Error(int(syscall.EINVAL), req)
+			}
+			switch req[0].Header.Type {
+			case unix.RTM_NEWQDISC, unix.RTM_NEWTCLASS:
+				stored[msg.Handle] = Object{Msg: msg, Attribute: Attribute{Kind: attr.Kind}}
+			case unix.RTM_DELQDISC, unix.RTM_DELTCLASS:
+				delete(stored, msg.Handle)
+			}
+			return []netlink.Message{{Header: netlink.Header{Type: netlink.Error}, Data: make([]byte, 4)}}, nil
+		}),
+	}
+	return c, &requests, stored
+}
+
+func TestHtbBuilderApply(t *testing.T) {
+	tcSocket, requests, stored := htbBuilderConn(t, 0)
+	defer tcSocket.Close()
+
+	b := newTestHtbBuilder()
+	if err := b.Apply(tcSocket); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(stored) != 8 {
+		t.Fatalf("expected 8 objects but got %d", len(stored))
+	}
+	applied := map[uint32]bool{HandleRoot: true}
+	for _, req := range *requests {
+		if !applied[req.Parent] {
+			t.Fatalf("%s %x was applied before its parent %x", req.Kind, req.Handle, req.Parent)
+		}
+		applied[req.Handle] = true
+	}
+
+	// Applying the same hierarchy again must not fail.
+	*requests = (*requests)[:0]
+	if err := b.Apply(tcSocket); err != nil {
+		t.Fatalf("unexpected error on second apply: %v", err)
+	}
+	if len(*requests) != 8 || len(stored) != 8 {
+		t.Fatalf("unexpected second apply: %d requests, %d objects", len(*requests), len(stored))
+	}
+}
+
+func TestHtbBuilderApplyRollback(t *testing.T) {
+	// The second leaf qdisc is rejected.
+	tcSocket, requests, stored := htbBuilderConn(t, 0x200000)
+	defer tcSocket.Close()
+
+	// An already existing root qdisc must survive the rollback.
+	stored[0x10000] = Object{
+		Msg{Family: unix.AF_UNSPEC, Ifindex: 42, Handle: 0x10000, Parent: HandleRoot},
+		Attribute{Kind: "htb"},
+	}
+
+	if err := newTestHtbBuilder().Apply(tcSocket); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	want := []htbBuilderRequest{
+		{Type: unix.RTM_NEWQDISC, Kind: "htb", Handle: 0x10000, Parent: HandleRoot},
+		{Type: unix.RTM_NEWTCLASS, Kind: "htb", Handle: 0x10001, Parent: 0x10000},
+		{Type: unix.RTM_NEWTCLASS, Kind: "htb", Handle: 0x10030, Parent: 0x10000},
+		{Type: unix.RTM_NEWTCLASS, Kind: "htb", Handle: 0x10010, Parent: 0x10001},
+		{Type: unix.RTM_NEWTCLASS, Kind: "htb", Handle: 0x10020, Parent: 0x10001},
+		{Type: unix.RTM_NEWQDISC, Kind: "sfq", Handle: 0x300000, Parent: 0x10030},
+		{Type: unix.RTM_NEWQDISC, Kind: "fq_codel", Handle: 0x100000, Parent: 0x10010},
+		{Type: unix.RTM_NEWQDISC, Kind: "fq_codel", Handle: 0x200000, Parent: 0x10020},
+		{Type: unix.RTM_DELQDISC, Handle: 0x100000, Parent: 0x10010},
+		{Type: unix.RTM_DELQDISC, Handle: 0x300000, Parent: 0x10030},
+		{Type: unix.RTM_DELTCLASS, Handle: 0x10020, Parent: 0x10001},
+		{Type: unix.RTM_DELTCLASS, Handle: 0x10010, Parent: 0x10001},
+		{Type: unix.RTM_DELTCLASS, Handle: 0x10030, Parent: 0x10000},
+		{Type: unix.RTM_DELTCLASS, Handle: 0x10001, Parent: 0x10000},
+	}
+	if diff := cmp.Diff(want, *requests); diff != "" {
+		t.Fatalf("requests missmatch (-want +got):\n%s", diff)
+	}
+	if len(stored) != 1 {
+		t.Fatalf("expected only the root qdisc to remain but got %v", stored)
+	}
+}
+
+func TestHtbBuilderApplyUsedMajor(t *testing.T) {
+	tcSocket, requests, stored := htbBuilderConn(t, 0)
+	defer tcSocket.Close()
+
+	// The major 10: of the preferred leaf handle of class 1:10 is used by a
+	// qdisc, that is not part of the hierarchy.
+	other := Object{
+		Msg{Family: unix.AF_UNSPEC, Ifindex: 42, Handle: 0x100000, Parent: 0x50001},
+		Attribute{Kind: "pfifo"},
+	}
+	stored[other.Handle] = other
+
+	b := newTestHtbBuilder()
+	if err := b.Apply(tcSocket); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []htbBuilderRequest{
+		{Type: unix.RTM_NEWQDISC, Kind: "htb", Handle: 0x10000, Parent: HandleRoot},
+		{Type: unix.RTM_NEWTCLASS, Kind: "htb", Handle: 0x10001, Parent: 0x10000},
+		{Type: unix.RTM_NEWTCLASS, Kind: "htb", Handle: 0x10030, Parent: 0x10000},
+		{Type: unix.RTM_NEWTCLASS, Kind: "htb", Handle: 0x10010, Parent: 0x10001},
+		{Type: unix.RTM_NEWTCLASS, Kind: "htb", Handle: 0x10020, Parent: 0x10001},
+		{Type: unix.RTM_NEWQDISC, Kind: "sfq", Handle: 0x300000, Parent: 0x10030},
+		{Type: unix.RTM_NEWQDISC, Kind: "fq_codel", Handle: 0x110000, Parent: 0x10010},
+		{Type: unix.RTM_NEWQDISC, Kind: "fq_codel", Handle: 0x200000, Parent: 0x10020},
+	}
+	if diff := cmp.Diff(want, *requests); diff != "" {
+		t.Fatalf("requests missmatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(other, stored[other.Handle]); diff != "" {
+		t.Fatalf("existing qdisc missmatch (-want +got):\n%s", diff)
+	}
+
+	// The leaf qdiscs keep their handles on the next apply.
+	*requests = (*requests)[:0]
+	if err := b.Apply(tcSocket); err != nil {
+		t.Fatalf("unexpected error on second apply: %v", err)
+	}
+	if diff := cmp.Diff(want, *requests); diff != "" {
+		t.Fatalf("requests of second apply missmatch (-want +got):\n%s", diff)
+	}
+}
@@ -7,6 +7,11 @@ const (
 	LINKLAYER_ATM
 )
 
+// Make linter happy with this comment.
+const (
+	TC_LINKLAYER_MASK = 0x0F
+)
+
 // Make linter happy with this comment.
 const (
 	ATM_CELL_PAYLOAD = 48
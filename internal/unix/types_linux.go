@@ -51,3 +51,12 @@ const (
 	RTM_DELACTION = linux.RTM_DELACTION
 	RTM_GETACTION = linux.RTM_GETACTION
 )
+
+// Make linter happy with this comment.
+const (
+	RTM_NEWLINK    = linux.RTM_NEWLINK
+	RTM_DELLINK    = linux.RTM_DELLINK
+	IFLA_IFNAME    = linux.IFLA_IFNAME
+	IFLA_LINKINFO  = linux.IFLA_LINKINFO
+	IFLA_INFO_KIND = linux.IFLA_INFO_KIND
+)
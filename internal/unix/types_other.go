@@ -49,3 +49,11 @@ const (
 	RTM_DELACTION = 49
 	RTM_GETACTION = 50
 )
+
+const (
+	RTM_NEWLINK    = 16
+	RTM_DELLINK    = 17
+	IFLA_IFNAME    = 3
+	IFLA_LINKINFO  = 18
+	IFLA_INFO_KIND = 1
+)
@@ -0,0 +1,460 @@
+package tc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/florianl/go-tc/core"
+)
+
+// jsonHandle represents a handle in its textual form like "1:10" or "root".
+type jsonHandle uint32
+
+func (h jsonHandle) MarshalText() ([]byte, error) {
+	return []byte(core.FormatHandle(uint32(h))), nil
+}
+
+func (h *jsonHandle) UnmarshalText(text []byte) error {
+	v, err := core.ParseHandle(string(text))
+	if err != nil {
+		return fmt.Errorf("handle %q: %w", text, ErrInvalidArg)
+	}
+	*h = jsonHandle(v)
+	return nil
+}
+
+// jsonMAC represents a MAC address in its textual form like "00:53:00:00:00:01".
+type jsonMAC net.HardwareAddr
+
+func (m jsonMAC) MarshalText() ([]byte, error) {
+	return []byte(net.HardwareAddr(m).String()), nil
+}
+
+func (m *jsonMAC) UnmarshalText(text []byte) error {
+	mac, err := net.ParseMAC(string(text))
+	if err != nil {
+		return fmt.Errorf("mac %q: %w", text, ErrInvalidArg)
+	}
+	*m = jsonMAC(mac)
+	return nil
+}
+
+// jsonIP represents an IP address in its textual form. Unlike net.IP, IPv4
+// addresses are restored in their 4 byte representation, as they are
+// returned by the kernel.
+type jsonIP net.IP
+
+func (ip jsonIP) MarshalText() ([]byte, error) {
+	return net.IP(ip).MarshalText()
+}
+
+func (ip *jsonIP) UnmarshalText(text []byte) error {
+	v := net.ParseIP(string(text))
+	if v == nil {
+		return fmt.Errorf("ip %q: %w", text, ErrInvalidArg)
+	}
+	if v4 := v.To4(); v4 != nil && !strings.Contains(string(text), ":") {
+		v = v4
+	}
+	*ip = jsonIP(v)
+	return nil
+}
+
+type jsonMsg Msg
+
+// jsonObject renders handle and parent of an Object in their textual form.
+// Objects of shared blocks are rendered with the index of the block instead
+// of ifindex and parent.
+type jsonObject struct {
+	jsonMsg
+	Block  *uint32    `json:"block,omitempty"`
+	Handle jsonHandle `json:"handle,omitempty"`
+	Parent jsonHandle `json:"parent,omitempty"`
+	Attribute
+}
+
+// MarshalJSON returns the JSON encoding of Object.
+func (o Object) MarshalJSON() ([]byte, error) {
+	v := jsonObject{
+		jsonMsg:   jsonMsg(o.Msg),
+		Handle:    jsonHandle(o.Handle),
+		Parent:    jsonHandle(o.Parent),
+		Attribute: o.Attribute,
+	}
+	if block, ok := o.Block(); ok {
+		v.Block = &block
+		v.Ifindex = 0
+		v.Parent = 0
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON parses the JSON encoding of Object.
+func (o *Object) UnmarshalJSON(data []byte) error {
+	var v jsonObject
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	o.Msg = Msg(v.jsonMsg)
+	o.Handle = uint32(v.Handle)
+	o.Parent = uint32(v.Parent)
+	if v.Block != nil {
+		o.Ifindex = MagicBlock
+		o.Parent = *v.Block
+	}
+	o.Attribute = v.Attribute
+	return nil
+}
+
+// MarshalJSON returns the JSON encoding of Basic.
+func (b Basic) MarshalJSON() ([]byte, error) {
+	type basic Basic
+	return json.Marshal(struct {
+		basic
+		ClassID *jsonHandle `json:"class_id,omitempty"`
+	}{basic(b), (*jsonHandle)(b.ClassID)})
+}
+
+// UnmarshalJSON parses the JSON encoding of Basic.
+func (b *Basic) UnmarshalJSON(data []byte) error {
+	type basic Basic
+	v := struct {
+		*basic
+		ClassID *jsonHandle `json:"class_id,omitempty"`
+	}{basic: (*basic)(b)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	b.ClassID = (*uint32)(v.ClassID)
+	return nil
+}
+
+// MarshalJSON returns the JSON encoding of Bpf.
+func (b Bpf) MarshalJSON() ([]byte, error) {
+	type bpf Bpf
+	return json.Marshal(struct {
+		bpf
+		ClassID *jsonHandle `json:"class_id,omitempty"`
+	}{bpf(b), (*jsonHandle)(b.ClassID)})
+}
+
+// UnmarshalJSON parses the JSON encoding of Bpf.
+func (b *Bpf) UnmarshalJSON(data []byte) error {
+	type bpf Bpf
+	v := struct {
+		*bpf
+		ClassID *jsonHandle `json:"class_id,omitempty"`
+	}{bpf: (*bpf)(b)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	b.ClassID = (*uint32)(v.ClassID)
+	return nil
+}
+
+// MarshalJSON returns the JSON encoding of Fw.
+func (f Fw) MarshalJSON() ([]byte, error) {
+	type fw Fw
+	return json.Marshal(struct {
+		fw
+		ClassID *jsonHandle `json:"class_id,omitempty"`
+	}{fw(f), (*jsonHandle)(f.ClassID)})
+}
+
+// UnmarshalJSON parses the JSON encoding of Fw.
+func (f *Fw) UnmarshalJSON(data []byte) error {
+	type fw Fw
+	v := struct {
+		*fw
+		ClassID *jsonHandle `json:"class_id,omitempty"`
+	}{fw: (*fw)(f)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	f.ClassID = (*uint32)(v.ClassID)
+	return nil
+}
+
+// MarshalJSON returns the JSON encoding of Matchall.
+func (m Matchall) MarshalJSON() ([]byte, error) {
+	type matchall Matchall
+	return json.Marshal(struct {
+		matchall
+		ClassID *jsonHandle `json:"class_id,omitempty"`
+	}{matchall(m), (*jsonHandle)(m.ClassID)})
+}
+
+// UnmarshalJSON parses the JSON encoding of Matchall.
+func (m *Matchall) UnmarshalJSON(data []byte) error {
+	type matchall Matchall
+	v := struct {
+		*matchall
+		ClassID *jsonHandle `json:"class_id,omitempty"`
+	}{matchall: (*matchall)(m)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	m.ClassID = (*uint32)(v.ClassID)
+	return nil
+}
+
+// MarshalJSON returns the JSON encoding of Route4.
+func (r Route4) MarshalJSON() ([]byte, error) {
+	type route4 Route4
+	return json.Marshal(struct {
+		route4
+		ClassID *jsonHandle `json:"class_id,omitempty"`
+	}{route4(r), (*jsonHandle)(r.ClassID)})
+}
+
+// UnmarshalJSON parses the JSON encoding of Route4.
+func (r *Route4) UnmarshalJSON(data []byte) error {
+	type route4 Route4
+	v := struct {
+		*route4
+		ClassID *jsonHandle `json:"class_id,omitempty"`
+	}{route4: (*route4)(r)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	r.ClassID = (*uint32)(v.ClassID)
+	return nil
+}
+
+// MarshalJSON returns the JSON encoding of Rsvp.
+func (r Rsvp) MarshalJSON() ([]byte, error) {
+	type rsvp Rsvp
+	return json.Marshal(struct {
+		rsvp
+		ClassID *jsonHandle `json:"class_id,omitempty"`
+	}{rsvp(r), (*jsonHandle)(r.ClassID)})
+}
+
+// UnmarshalJSON parses the JSON encoding of Rsvp.
+func (r *Rsvp) UnmarshalJSON(data []byte) error {
+	type rsvp Rsvp
+	v := struct {
+		*rsvp
+		ClassID *jsonHandle `json:"class_id,omitempty"`
+	}{rsvp: (*rsvp)(r)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	r.ClassID = (*uint32)(v.ClassID)
+	return nil
+}
+
+// MarshalJSON returns the JSON encoding of TcIndex.
+func (t TcIndex) MarshalJSON() ([]byte, error) {
+	type tcIndex TcIndex
+	return json.Marshal(struct {
+		tcIndex
+		ClassID *jsonHandle `json:"class_id,omitempty"`
+	}{tcIndex(t), (*jsonHandle)(t.ClassID)})
+}
+
+// UnmarshalJSON parses the JSON encoding of TcIndex.
+func (t *TcIndex) UnmarshalJSON(data []byte) error {
+	type tcIndex TcIndex
+	v := struct {
+		*tcIndex
+		ClassID *jsonHandle `json:"class_id,omitempty"`
+	}{tcIndex: (*tcIndex)(t)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	t.ClassID = (*uint32)(v.ClassID)
+	return nil
+}
+
+// MarshalJSON returns the JSON encoding of U32.
+func (u U32) MarshalJSON() ([]byte, error) {
+	type u32 U32
+	return json.Marshal(struct {
+		u32
+		ClassID *jsonHandle `json:"class_id,omitempty"`
+	}{u32(u), (*jsonHandle)(u.ClassID)})
+}
+
+// UnmarshalJSON parses the JSON encoding of U32.
+func (u *U32) UnmarshalJSON(data []byte) error {
+	type u32 U32
+	v := struct {
+		*u32
+		ClassID *jsonHandle `json:"class_id,omitempty"`
+	}{u32: (*u32)(u)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	u.ClassID = (*uint32)(v.ClassID)
+	return nil
+}
+
+type flower Flower
+
+// jsonFlower renders the handle, MAC and IP fields of Flower in their
+// textual form.
+type jsonFlower struct {
+	*flower
+	ClassID           *jsonHandle `json:"class_id,omitempty"`
+	KeyEthDst         *jsonMAC    `json:"key_eth_dst,omitempty"`
+	KeyEthDstMask     *jsonMAC    `json:"key_eth_dst_mask,omitempty"`
+	KeyEthSrc         *jsonMAC    `json:"key_eth_src,omitempty"`
+	KeyEthSrcMask     *jsonMAC    `json:"key_eth_src_mask,omitempty"`
+	KeyIPv4Src        *jsonIP     `json:"key_ipv4_src,omitempty"`
+	KeyIPv4SrcMask    *jsonIP     `json:"key_ipv4_src_mask,omitempty"`
+	KeyIPv4Dst        *jsonIP     `json:"key_ipv4_dst,omitempty"`
+	KeyIPv4DstMask    *jsonIP     `json:"key_ipv4_dst_mask,omitempty"`
+	KeyEncIPv4Src     *jsonIP     `json:"key_enc_ipv4_src,omitempty"`
+	KeyEncIPv4SrcMask *jsonIP     `json:"key_enc_ipv4_src_mask,omitempty"`
+	KeyEncIPv4Dst     *jsonIP     `json:"key_enc_ipv4_dst,omitempty"`
+	KeyEncIPv4DstMask *jsonIP     `json:"key_enc_ipv4_dst_mask,omitempty"`
+}
+
+// MarshalJSON returns the JSON encoding of Flower.
+func (f Flower) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonFlower{
+		flower:            (*flower)(&f),
+		ClassID:           (*jsonHandle)(f.ClassID),
+		KeyEthDst:         (*jsonMAC)(f.KeyEthDst),
+		KeyEthDstMask:     (*jsonMAC)(f.KeyEthDstMask),
+		KeyEthSrc:         (*jsonMAC)(f.KeyEthSrc),
+		KeyEthSrcMask:     (*jsonMAC)(f.KeyEthSrcMask),
+		KeyIPv4Src:        (*jsonIP)(f.KeyIPv4Src),
+		KeyIPv4SrcMask:    (*jsonIP)(f.KeyIPv4SrcMask),
+		KeyIPv4Dst:        (*jsonIP)(f.KeyIPv4Dst),
+		KeyIPv4DstMask:    (*jsonIP)(f.KeyIPv4DstMask),
+		KeyEncIPv4Src:     (*jsonIP)(f.KeyEncIPv4Src),
+		KeyEncIPv4SrcMask: (*jsonIP)(f.KeyEncIPv4SrcMask),
+		KeyEncIPv4Dst:     (*jsonIP)(f.KeyEncIPv4Dst),
+		KeyEncIPv4DstMask: (*jsonIP)(f.KeyEncIPv4DstMask),
+	})
+}
+
+// UnmarshalJSON parses the JSON encoding of Flower.
+func (f *Flower) UnmarshalJSON(data []byte) error {
+	v := jsonFlower{flower: (*flower)(f)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	f.ClassID = (*uint32)(v.ClassID)
+	f.KeyEthDst = (*net.HardwareAddr)(v.KeyEthDst)
+	f.KeyEthDstMask = (*net.HardwareAddr)(v.KeyEthDstMask)
+	f.KeyEthSrc = (*net.HardwareAddr)(v.KeyEthSrc)
+	f.KeyEthSrcMask = (*net.HardwareAddr)(v.KeyEthSrcMask)
+	f.KeyIPv4Src = (*net.IP)(v.KeyIPv4Src)
+	f.KeyIPv4SrcMask = (*net.IP)(v.KeyIPv4SrcMask)
+	f.KeyIPv4Dst = (*net.IP)(v.KeyIPv4Dst)
+	f.KeyIPv4DstMask = (*net.IP)(v.KeyIPv4DstMask)
+	f.KeyEncIPv4Src = (*net.IP)(v.KeyEncIPv4Src)
+	f.KeyEncIPv4SrcMask = (*net.IP)(v.KeyEncIPv4SrcMask)
+	f.KeyEncIPv4Dst = (*net.IP)(v.KeyEncIPv4Dst)
+	f.KeyEncIPv4DstMask = (*net.IP)(v.KeyEncIPv4DstMask)
+	return nil
+}
+
+// MarshalJSON returns the JSON encoding of Ct.
+func (c Ct) MarshalJSON() ([]byte, error) {
+	type ct Ct
+	return json.Marshal(struct {
+		ct
+		NatIPv4Min *jsonIP `json:"nat_ipv4_min,omitempty"`
+		NatIPv4Max *jsonIP `json:"nat_ipv4_max,omitempty"`
+	}{ct(c), (*jsonIP)(c.NatIPv4Min), (*jsonIP)(c.NatIPv4Max)})
+}
+
+// UnmarshalJSON parses the JSON encoding of Ct.
+func (c *Ct) UnmarshalJSON(data []byte) error {
+	type ct Ct
+	v := struct {
+		*ct
+		NatIPv4Min *jsonIP `json:"nat_ipv4_min,omitempty"`
+		NatIPv4Max *jsonIP `json:"nat_ipv4_max,omitempty"`
+	}{ct: (*ct)(c)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	c.NatIPv4Min = (*net.IP)(v.NatIPv4Min)
+	c.NatIPv4Max = (*net.IP)(v.NatIPv4Max)
+	return nil
+}
+
+// MarshalJSON returns the JSON encoding of TunnelKey.
+func (t TunnelKey) MarshalJSON() ([]byte, error) {
+	type tunnelKey TunnelKey
+	return json.Marshal(struct {
+		tunnelKey
+		KeyEncSrc *jsonIP `json:"key_enc_src,omitempty"`
+		KeyEncDst *jsonIP `json:"key_enc_dst,omitempty"`
+	}{tunnelKey(t), (*jsonIP)(t.KeyEncSrc), (*jsonIP)(t.KeyEncDst)})
+}
+
+// UnmarshalJSON parses the JSON encoding of TunnelKey.
+func (t *TunnelKey) UnmarshalJSON(data []byte) error {
+	type tunnelKey TunnelKey
+	v := struct {
+		*tunnelKey
+		KeyEncSrc *jsonIP `json:"key_enc_src,omitempty"`
+		KeyEncDst *jsonIP `json:"key_enc_dst,omitempty"`
+	}{tunnelKey: (*tunnelKey)(t)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	t.KeyEncSrc = (*net.IP)(v.KeyEncSrc)
+	t.KeyEncDst = (*net.IP)(v.KeyEncDst)
+	return nil
+}
+
+// MarshalJSON returns the JSON encoding of Ife.
+func (i Ife) MarshalJSON() ([]byte, error) {
+	type ife Ife
+	return json.Marshal(struct {
+		ife
+		SMac *jsonMAC `json:"s_mac,omitempty"`
+		DMac *jsonMAC `json:"d_mac,omitempty"`
+	}{ife(i), (*jsonMAC)(i.SMac), (*jsonMAC)(i.DMac)})
+}
+
+// UnmarshalJSON parses the JSON encoding of Ife.
+func (i *Ife) UnmarshalJSON(data []byte) error {
+	type ife Ife
+	v := struct {
+		*ife
+		SMac *jsonMAC `json:"s_mac,omitempty"`
+		DMac *jsonMAC `json:"d_mac,omitempty"`
+	}{ife: (*ife)(i)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	i.SMac = (*net.HardwareAddr)(v.SMac)
+	i.DMac = (*net.HardwareAddr)(v.DMac)
+	return nil
+}
+
+// MarshalJSON returns the JSON encoding of SkbMod.
+func (s SkbMod) MarshalJSON() ([]byte, error) {
+	type skbMod SkbMod
+	return json.Marshal(struct {
+		skbMod
+		DMac *jsonMAC `json:"d_mac,omitempty"`
+		SMac *jsonMAC `json:"s_mac,omitempty"`
+	}{skbMod(s), (*jsonMAC)(s.DMac), (*jsonMAC)(s.SMac)})
+}
+
+// UnmarshalJSON parses the JSON encoding of SkbMod.
+func (s *SkbMod) UnmarshalJSON(data []byte) error {
+	type skbMod SkbMod
+	v := struct {
+		*skbMod
+		DMac *jsonMAC `json:"d_mac,omitempty"`
+		SMac *jsonMAC `json:"s_mac,omitempty"`
+	}{skbMod: (*skbMod)(s)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	s.DMac = (*net.HardwareAddr)(v.DMac)
+	s.SMac = (*net.HardwareAddr)(v.SMac)
+	return nil
+}
@@ -0,0 +1,146 @@
+package tc
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/florianl/go-tc/core"
+)
+
+func TestObjectJSON(t *testing.T) {
+	mac, _ := net.ParseMAC("00:53:00:00:00:01")
+	mask, _ := net.ParseMAC("ff:ff:ff:ff:ff:00")
+	ip := net.IP{192, 0, 2, 1}
+	ipMask := net.IP{255, 255, 255, 0}
+
+	tests := map[string]struct {
+		obj      Object
+		contains []string
+		excludes []string
+	}{
+		"qdisc": {
+			obj: Object{
+				Msg: Msg{Ifindex: 1, Handle: core.BuildHandle(0x1, 0x0), Parent: HandleRoot},
+				Attribute: Attribute{Kind: "htb", Htb: &Htb{
+					Init: &HtbGlob{Version: 3, Rate2Quantum: 10, Defcls: 0x30},
+				}},
+			},
+			contains: []string{`"handle":"1:"`, `"parent":"root"`, `"kind":"htb"`, `"rate2_quantum":10`},
+		},
+		"class": {
+			obj: Object{
+				Msg:       Msg{Ifindex: 1, Handle: core.BuildHandle(0x1, 0x10), Parent: core.BuildHandle(0x1, 0x1)},
+				Attribute: Attribute{Kind: "drr", Drr: &Drr{Quantum: uint32Ptr(1514)}},
+			},
+			contains: []string{`"handle":"1:10"`, `"parent":"1:1"`},
+		},
+		"u32": {
+			obj: Object{
+				Msg: Msg{Ifindex: 1, Handle: 0x800, Parent: core.BuildHandle(0x1, 0x0), Info: 0x10000300},
+				Attribute: Attribute{Kind: "u32", U32: &U32{
+					ClassID: uint32Ptr(core.BuildHandle(0x1, 0x10)),
+					Sel: &U32Sel{Flags: 1, NKeys: 1, Keys: []U32Key{
+						{Mask: 0xFFFFFFFF, Val: 0x0100000A, Off: 16},
+					}},
+				}},
+			},
+			contains: []string{`"class_id":"1:10"`, `"handle":":800"`, `"info":268436224`},
+		},
+		"flower": {
+			obj: Object{
+				Msg: Msg{Ifindex: 1, Parent: HandleClsactIngress},
+				Attribute: Attribute{Kind: "flower", Flower: &Flower{
+					ClassID:        uint32Ptr(core.BuildHandle(0x1, 0x1)),
+					KeyEthDst:      &mac,
+					KeyEthDstMask:  &mask,
+					KeyIPv4Dst:     &ip,
+					KeyIPv4DstMask: &ipMask,
+					KeyIPProto:     uint8Ptr(6),
+				}},
+			},
+			contains: []string{`"class_id":"1:1"`, `"key_eth_dst":"00:53:00:00:00:01"`,
+				`"key_eth_dst_mask":"ff:ff:ff:ff:ff:00"`, `"key_ipv4_dst":"192.0.2.1"`,
+				`"key_ipv4_dst_mask":"255.255.255.0"`, `"parent":"clsact-ingress"`},
+		},
+		"clsact": {
+			obj: Object{
+				Msg:       Msg{Ifindex: 1, Handle: core.BuildHandle(0xFFFF, 0x0), Parent: HandleClsact},
+				Attribute: Attribute{Kind: "clsact"},
+			},
+			contains: []string{`"handle":"ffff:"`, `"parent":"ingress"`},
+		},
+		"egress": {
+			obj: Object{
+				Msg:       Msg{Ifindex: 1, Parent: HandleClsactEgress},
+				Attribute: Attribute{Kind: "matchall", Matchall: &Matchall{ClassID: uint32Ptr(core.BuildHandle(0x1, 0x1))}},
+			},
+			contains: []string{`"parent":"clsact-egress"`},
+		},
+		"actions": {
+			obj: Object{
+				Msg: Msg{Ifindex: 1, Parent: HandleClsactIngress},
+				Attribute: Attribute{Kind: "matchall", Matchall: &Matchall{
+					Actions: &[]*Action{
+						{Kind: "skbmod", SkbMod: &SkbMod{DMac: &mac}},
+						{Kind: "tunnel_key", TunnelKey: &TunnelKey{KeyEncSrc: &ip, KeyEncDst: &ip}},
+						{Kind: "ife", Ife: &Ife{SMac: &mac}},
+						{Kind: "ct", Ct: &Ct{NatIPv4Min: &ip, NatIPv4Max: &ip}},
+					},
+				}},
+			},
+			contains: []string{`"d_mac":"00:53:00:00:00:01"`, `"key_enc_src":"192.0.2.1"`,
+				`"s_mac":"00:53:00:00:00:01"`, `"nat_ipv4_max":"192.0.2.1"`},
+		},
+		"block": {
+			obj: Object{
+				Msg:       Msg{Ifindex: MagicBlock, Handle: 0x1, Parent: 22, Info: 0x20008},
+				Attribute: Attribute{Kind: "basic", Basic: &Basic{ClassID: uint32Ptr(core.BuildHandle(0x1, 0x1))}},
+			},
+			contains: []string{`"block":22`},
+			excludes: []string{`"ifindex"`, `"parent"`},
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, err := json.Marshal(testcase.obj)
+			if err != nil {
+				t.Fatalf("could not marshal object: %v", err)
+			}
+			for _, s := range testcase.contains {
+				if !strings.Contains(string(data), s) {
+					t.Fatalf("expected %s in %s", s, data)
+				}
+			}
+			for _, s := range testcase.excludes {
+				if strings.Contains(string(data), s) {
+					t.Fatalf("unexpected %s in %s", s, data)
+				}
+			}
+			var got Object
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("could not unmarshal object: %v", err)
+			}
+			if !reflect.DeepEqual(testcase.obj, got) {
+				t.Fatalf("round trip through JSON missmatch: %s", data)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, data := range []string{
+			`{"handle":"1:10000"}`,
+			`{"kind":"flower","flower":{"key_eth_dst":"00:53"}}`,
+			`{"kind":"flower","flower":{"key_ipv4_src":"192.0.2"}}`,
+		} {
+			var obj Object
+			if err := json.Unmarshal([]byte(data), &obj); !errors.Is(err, ErrInvalidArg) {
+				t.Fatalf("expected ErrInvalidArg for %s but got %v", data, err)
+			}
+		}
+	})
+}
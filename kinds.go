@@ -0,0 +1,187 @@
+package tc
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// kindEntry registers a kind of qdisc, filter or action, that is known to
+// this package.
+type kindEntry struct {
+	kindType ProbeKind
+	// fields are the names of the option structs in Attribute or Action,
+	// that configure the kind. The classes of classful qdiscs share the
+	// option struct of the qdisc, except the classes of hfsc, which use a
+	// second one.
+	fields []string
+	// parameterless kinds can be used without options.
+	parameterless bool
+}
+
+// optionlessKind reports whether kind never has options, like clsact and
+// ingress, which emit an empty TCA_OPTIONS, or the teql qdiscs, whose kind
+// is the name of their device.
+func optionlessKind(kind string) bool {
+	if strings.HasPrefix(kind, "teql") {
+		return true
+	}
+	entry, ok := attributeKinds[kind]
+	return ok && entry.parameterless && len(entry.fields) == 0
+}
+
+// attributeKinds registers the kinds of qdiscs and filters. It is the source
+// for the validation of Attribute and for KindOf.
+var attributeKinds = map[string]kindEntry{
+	// Filters
+	"basic":    {kindType: ProbeFilter, fields: []string{"Basic"}},
+	"bpf":      {kindType: ProbeFilter, fields: []string{"BPF"}},
+	"cgroup":   {kindType: ProbeFilter, fields: []string{"Cgroup"}},
+	"flow":     {kindType: ProbeFilter, fields: []string{"Flow"}},
+	"flower":   {kindType: ProbeFilter, fields: []string{"Flower"}},
+	"fw":       {kindType: ProbeFilter, fields: []string{"Fw"}},
+	"matchall": {kindType: ProbeFilter, fields: []string{"Matchall"}},
+	"route4":   {kindType: ProbeFilter, fields: []string{"Route4"}},
+	"rsvp":     {kindType: ProbeFilter, fields: []string{"Rsvp"}},
+	"tcindex":  {kindType: ProbeFilter, fields: []string{"TcIndex"}},
+	"u32":      {kindType: ProbeFilter, fields: []string{"U32"}},
+
+	// Classless qdiscs
+	"bfifo":    {kindType: ProbeQdisc, fields: []string{"Bfifo"}},
+	"cake":     {kindType: ProbeQdisc, fields: []string{"Cake"}},
+	"choke":    {kindType: ProbeQdisc, fields: []string{"Choke"}},
+	"clsact":   {kindType: ProbeQdisc, parameterless: true},
+	"codel":    {kindType: ProbeQdisc, fields: []string{"Codel"}},
+	"fq":       {kindType: ProbeQdisc, fields: []string{"Fq"}},
+	"fq_codel": {kindType: ProbeQdisc, fields: []string{"FqCodel"}},
+	"hhf":      {kindType: ProbeQdisc, fields: []string{"Hhf"}},
+	"ingress":  {kindType: ProbeQdisc, parameterless: true},
+	"mqprio":   {kindType: ProbeQdisc, fields: []string{"MqPrio"}},
+	"noqueue":  {kindType: ProbeQdisc, parameterless: true},
+	"netem":    {kindType: ProbeQdisc, fields: []string{"Netem"}},
+	"pfifo":    {kindType: ProbeQdisc, fields: []string{"Pfifo"}},
+	"pie":      {kindType: ProbeQdisc, fields: []string{"Pie"}},
+	"plug":     {kindType: ProbeQdisc, fields: []string{"Plug"}},
+	"red":      {kindType: ProbeQdisc, fields: []string{"Red"}},
+	"sfb":      {kindType: ProbeQdisc, fields: []string{"Sfb"}},
+	"sfq":      {kindType: ProbeQdisc, fields: []string{"Sfq"}},
+	"tbf":      {kindType: ProbeQdisc, fields: []string{"Tbf"}},
+
+	// Classful qdiscs
+	"atm":        {kindType: ProbeQdisc, fields: []string{"Atm"}},
+	"cbq":        {kindType: ProbeQdisc, fields: []string{"Cbq"}},
+	"cbs":        {kindType: ProbeQdisc, fields: []string{"Cbs"}},
+	"drr":        {kindType: ProbeQdisc, fields: []string{"Drr"}},
+	"dsmark":     {kindType: ProbeQdisc, fields: []string{"Dsmark"}},
+	"hfsc":       {kindType: ProbeQdisc, fields: []string{"HfscQOpt", "Hfsc"}},
+	"htb":        {kindType: ProbeQdisc, fields: []string{"Htb"}},
+	"mq":         {kindType: ProbeQdisc, parameterless: true},
+	"pfifo_fast": {kindType: ProbeQdisc, fields: []string{"Prio"}},
+	"prio":       {kindType: ProbeQdisc, fields: []string{"Prio"}},
+	// qfq is parameterless as qdisc - only its classes require options
+	"qfq":    {kindType: ProbeQdisc, fields: []string{"Qfq"}, parameterless: true},
+	"taprio": {kindType: ProbeQdisc, fields: []string{"TaPrio"}},
+}
+
+// actionKinds registers the kinds of actions.
+var actionKinds = map[string]kindEntry{
+	"bpf":        {kindType: ProbeAction, fields: []string{"Bpf"}},
+	"connmark":   {kindType: ProbeAction, fields: []string{"ConnMark"}},
+	"csum":       {kindType: ProbeAction, fields: []string{"CSum"}},
+	"ct":         {kindType: ProbeAction, fields: []string{"Ct"}},
+	"ctinfo":     {kindType: ProbeAction, fields: []string{"CtInfo"}},
+	"defact":     {kindType: ProbeAction, fields: []string{"Defact"}},
+	"gact":       {kindType: ProbeAction, fields: []string{"Gact"}},
+	"gate":       {kindType: ProbeAction, fields: []string{"Gate"}},
+	"ife":        {kindType: ProbeAction, fields: []string{"Ife"}},
+	"ipt":        {kindType: ProbeAction, fields: []string{"Ipt"}},
+	"mirred":     {kindType: ProbeAction, fields: []string{"Mirred"}},
+	"mpls":       {kindType: ProbeAction, fields: []string{"MPLS"}},
+	"nat":        {kindType: ProbeAction, fields: []string{"Nat"}},
+	"police":     {kindType: ProbeAction, fields: []string{"Police"}},
+	"sample":     {kindType: ProbeAction, fields: []string{"Sample"}},
+	"skbedit":    {kindType: ProbeAction, fields: []string{"SkbEdit"}},
+	"skbmod":     {kindType: ProbeAction, fields: []string{"SkbMod"}},
+	"tunnel_key": {kindType: ProbeAction, fields: []string{"TunnelKey"}},
+	"vlan":       {kindType: ProbeAction, fields: []string{"VLan"}},
+}
+
+// KindOf returns the type of the option struct in Attribute, that configures
+// the qdisc or filter kind, like the type of FqCodel for "fq_codel". ok
+// reports whether kind is known. Kinds without options, like clsact, return
+// a nil type. New options of a kind are created with reflect.New.
+func KindOf(kind string) (typ reflect.Type, ok bool) {
+	return kindOf(attributeKinds, reflect.TypeOf(Attribute{}), kind)
+}
+
+// ActionKindOf returns the type of the option struct in Action, that
+// configures the action kind, like the type of Gact for "gact". ok reports
+// whether kind is known.
+func ActionKindOf(kind string) (typ reflect.Type, ok bool) {
+	return kindOf(actionKinds, reflect.TypeOf(Action{}), kind)
+}
+
+func kindOf(registry map[string]kindEntry, container reflect.Type, kind string) (reflect.Type, bool) {
+	entry, ok := registry[kind]
+	if !ok {
+		return nil, false
+	}
+	if len(entry.fields) == 0 {
+		return nil, true
+	}
+	field, _ := container.FieldByName(entry.fields[0])
+	return field.Type.Elem(), true
+}
+
+// Kinds returns the sorted kinds of qdiscs, filters or actions, that are
+// known to this package.
+func Kinds(kindType ProbeKind) []string {
+	registry := attributeKinds
+	if kindType == ProbeAction {
+		registry = actionKinds
+	}
+	var kinds []string
+	for kind, entry := range registry {
+		if entry.kindType == kindType {
+			kinds = append(kinds, kind)
+		}
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// registeredKinds returns the kinds of qdiscs or filters as set.
+func registeredKinds(kindType ProbeKind) map[string]bool {
+	kinds := make(map[string]bool)
+	for _, kind := range Kinds(kindType) {
+		kinds[kind] = true
+	}
+	return kinds
+}
+
+// attributeOptions returns the option structs of Attribute, that are
+// registered in attributeKinds, in the order of their declaration.
+func attributeOptions() []kindOption {
+	typ := reflect.TypeOf(Attribute{})
+	kinds := append(Kinds(ProbeFilter), Kinds(ProbeQdisc)...)
+	var options []kindOption
+	for i := 0; i < typ.NumField(); i++ {
+		ko := kindOption{name: typ.Field(i).Name}
+		for _, kind := range kinds {
+			for _, field := range attributeKinds[kind].fields {
+				if field == ko.name {
+					ko.kinds = append(ko.kinds, kind)
+				}
+			}
+		}
+		if len(ko.kinds) == 0 {
+			continue
+		}
+		index := i
+		ko.isSet = func(a *Attribute) bool {
+			return !reflect.ValueOf(a).Elem().Field(index).IsNil()
+		}
+		options = append(options, ko)
+	}
+	return options
+}
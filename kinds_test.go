@@ -0,0 +1,153 @@
+package tc
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestKindOf(t *testing.T) {
+	tests := map[string]struct {
+		kind   string
+		action bool
+		want   reflect.Type
+		ok     bool
+	}{
+		"qdisc":               {kind: "fq_codel", want: reflect.TypeOf(FqCodel{}), ok: true},
+		"filter":              {kind: "bpf", want: reflect.TypeOf(Bpf{}), ok: true},
+		"action":              {kind: "bpf", action: true, want: reflect.TypeOf(ActBpf{}), ok: true},
+		"shared options":      {kind: "pfifo_fast", want: reflect.TypeOf(Prio{}), ok: true},
+		"options of qdisc":    {kind: "hfsc", want: reflect.TypeOf(HfscQOpt{}), ok: true},
+		"without options":     {kind: "clsact", ok: true},
+		"unknown":             {kind: "foo"},
+		"action is no filter": {kind: "gact"},
+		"filter is no action": {kind: "u32", action: true},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			kindOf := KindOf
+			if testcase.action {
+				kindOf = ActionKindOf
+			}
+			typ, ok := kindOf(testcase.kind)
+			if ok != testcase.ok {
+				t.Fatalf("expected %v but got %v", testcase.ok, ok)
+			}
+			if typ != testcase.want {
+				t.Fatalf("expected type %v but got %v", testcase.want, typ)
+			}
+		})
+	}
+}
+
+func TestKinds(t *testing.T) {
+	for _, kindType := range []ProbeKind{ProbeQdisc, ProbeFilter, ProbeAction} {
+		kinds := Kinds(kindType)
+		if len(kinds) == 0 || !sort.StringsAreSorted(kinds) {
+			t.Fatalf("%s: expected sorted kinds but got %v", kindType, kinds)
+		}
+		for _, kind := range kinds {
+			if _, ok := KindOf(kind); !ok && kindType != ProbeAction {
+				t.Fatalf("%s: %s is not known to KindOf", kindType, kind)
+			}
+			if _, ok := ActionKindOf(kind); !ok && kindType == ProbeAction {
+				t.Fatalf("%s: %s is not known to ActionKindOf", kindType, kind)
+			}
+		}
+	}
+	if diff := cmp.Diff([]string{"basic", "bpf", "cgroup", "flow", "flower", "fw", "matchall",
+		"route4", "rsvp", "tcindex", "u32"}, Kinds(ProbeFilter)); diff != "" {
+		t.Fatalf("filter kinds missmatch (-want +got):\n%s", diff)
+	}
+	if kinds := Kinds(ProbeKind(7)); len(kinds) != 0 {
+		t.Fatalf("expected no kinds but got %v", kinds)
+	}
+}
+
+// TestKindsRegistered checks, that every option struct of Attribute and
+// Action is used by a registered kind and that the registry only refers to
+// existing option structs.
+func TestKindsRegistered(t *testing.T) {
+	tests := map[string]struct {
+		container reflect.Type
+		registry  map[string]kindEntry
+		// ignore are the fields, that are no options of a kind.
+		ignore map[string]bool
+	}{
+		"Attribute": {
+			container: reflect.TypeOf(Attribute{}),
+			registry:  attributeKinds,
+			ignore: map[string]bool{"Stats": true, "XStats": true, "Stats2": true,
+				"Stab": true, "RawStats": true},
+		},
+		"Action": {
+			container: reflect.TypeOf(Action{}),
+			registry:  actionKinds,
+			ignore:    map[string]bool{"Stats": true},
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			registered := make(map[string]bool)
+			for kind, entry := range testcase.registry {
+				if len(entry.fields) == 0 && !entry.parameterless {
+					t.Fatalf("%s: kind without options has to be parameterless", kind)
+				}
+				for _, name := range entry.fields {
+					field, ok := testcase.container.FieldByName(name)
+					if !ok {
+						t.Fatalf("%s: %s has no field %s", kind, testcase.container, name)
+					}
+					if field.Type.Kind() != reflect.Ptr || field.Type.Elem().Kind() != reflect.Struct {
+						t.Fatalf("%s: %s is no pointer to an option struct", kind, name)
+					}
+					registered[name] = true
+				}
+			}
+			for i := 0; i < testcase.container.NumField(); i++ {
+				field := testcase.container.Field(i)
+				if field.Type.Kind() != reflect.Ptr || field.Type.Elem().Kind() != reflect.Struct ||
+					testcase.ignore[field.Name] {
+					continue
+				}
+				if !registered[field.Name] {
+					t.Fatalf("option struct %s is not registered for a kind", field.Name)
+				}
+			}
+		})
+	}
+}
+
+// TestKindsDecoded checks, that every registered kind of qdisc and filter is
+// decoded into one of its option structs.
+func TestKindsDecoded(t *testing.T) {
+	for kind, entry := range attributeKinds {
+		var attr Attribute
+		err := extractTCAOptions(nil, &attr, kind)
+		if errors.Is(err, ErrUnknownKind) {
+			t.Fatalf("%s: registered kind is not decoded", kind)
+		}
+		for _, ko := range kindOptions {
+			if ko.isSet(&attr) && !ko.usedBy(kind) {
+				t.Fatalf("%s: decoded into %s, which is not registered for it", kind, ko.name)
+			}
+		}
+		if len(entry.fields) == 0 {
+			continue
+		}
+		var found bool
+		for _, field := range entry.fields {
+			if !reflect.ValueOf(attr).FieldByName(field).IsNil() {
+				found = true
+			}
+		}
+		if !found && err == nil {
+			t.Fatalf("%s: decoded without error, but none of %v is set", kind, entry.fields)
+		}
+	}
+}
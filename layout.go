@@ -0,0 +1,52 @@
+package tc
+
+import "encoding/binary"
+
+// cLayout describes the properties of the C ABI of the kernel, that change
+// the layout of structs from the uapi headers.
+//
+// Most structs are decoded with encoding/binary, which reads and writes the
+// fields back to back in nativeEndian, regardless of how Go aligns them.
+// This matches the kernel for all structs, whose fields are naturally
+// aligned and which have at most padding at their end, like Stats, NetemQopt
+// or HtbOpt. The following structs do not fit this scheme and are encoded
+// field by field with a cLayout:
+//   - SkbModParms, whose 64 bit field follows 20 bytes and is aligned to
+//     8 bytes on all architectures but 386,
+//   - CmpMatch and NByteMatch, whose bit fields are allocated in an order,
+//     that depends on the byte order.
+type cLayout struct {
+	order binary.ByteOrder
+	// align64 is the alignment of 64 bit integers in structs.
+	align64 int
+}
+
+// nativeLayout is the layout of the architecture, the package is built for.
+var nativeLayout = cLayout{order: nativeEndian, align64: align64}
+
+// bigEndian reports whether bit fields are allocated from the most
+// significant bit on, as GCC does on big endian architectures.
+func (l cLayout) bigEndian() bool {
+	return l.order == binary.ByteOrder(binary.BigEndian)
+}
+
+// pad64 returns offset aligned for a 64 bit integer.
+func (l cLayout) pad64(offset int) int {
+	return (offset + l.align64 - 1) &^ (l.align64 - 1)
+}
+
+// nibbles returns the byte, that holds the bit fields first:4 and second:4.
+func (l cLayout) nibbles(first, second uint8) uint8 {
+	if l.bigEndian() {
+		return first<<4 | second&0xf
+	}
+	return first&0xf | second<<4
+}
+
+// splitNibbles returns the bit fields first:4 and second:4 of b.
+func (l cLayout) splitNibbles(b uint8) (first, second uint8) {
+	if l.bigEndian() {
+		return b >> 4, b & 0xf
+	}
+	return b & 0xf, b >> 4
+}
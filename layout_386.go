@@ -0,0 +1,4 @@
+package tc
+
+// align64 is the alignment of 64 bit integers in structs of the i386 ABI.
+const align64 = 4
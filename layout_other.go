@@ -0,0 +1,8 @@
+//go:build !386
+// +build !386
+
+package tc
+
+// align64 is the alignment of 64 bit integers in structs. All other
+// architectures, that are supported by Go, align them to their size.
+const align64 = 8
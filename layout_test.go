@@ -0,0 +1,53 @@
+package tc
+
+import (
+	"encoding/binary"
+	"runtime"
+	"testing"
+)
+
+// testLayouts contains the layouts of architectures, that differ in byte
+// order or in the alignment of 64 bit integers.
+var testLayouts = map[string]cLayout{
+	"amd64":  {order: binary.LittleEndian, align64: 8},
+	"386":    {order: binary.LittleEndian, align64: 4},
+	"arm":    {order: binary.LittleEndian, align64: 8},
+	"mips64": {order: binary.BigEndian, align64: 8},
+}
+
+func TestLayout(t *testing.T) {
+	tests := map[string]struct {
+		pad64         int
+		nibbles       uint8
+		first, second uint8
+	}{
+		"amd64":  {pad64: 24, nibbles: 0x21},
+		"386":    {pad64: 20, nibbles: 0x21},
+		"arm":    {pad64: 24, nibbles: 0x21},
+		"mips64": {pad64: 24, nibbles: 0x12},
+	}
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			l := testLayouts[name]
+			if offset := l.pad64(20); offset != testcase.pad64 {
+				t.Fatalf("expected offset %d but got %d", testcase.pad64, offset)
+			}
+			if b := l.nibbles(1, 2); b != testcase.nibbles {
+				t.Fatalf("expected %#x but got %#x", testcase.nibbles, b)
+			}
+			if first, second := l.splitNibbles(testcase.nibbles); first != 1 || second != 2 {
+				t.Fatalf("expected 1 and 2 but got %d and %d", first, second)
+			}
+		})
+	}
+
+	t.Run("native", func(t *testing.T) {
+		l, ok := testLayouts[runtime.GOARCH]
+		if !ok {
+			t.Skipf("no layout for %s", runtime.GOARCH)
+		}
+		if nativeLayout.align64 != l.align64 || nativeLayout.bigEndian() != l.bigEndian() {
+			t.Fatalf("expected layout %v but got %v", l, nativeLayout)
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package tc
+
+import (
+	"fmt"
+	"syscall"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+)
+
+// AttachLeaf attaches a qdisc of kind with the options of attr to the class
+// classID of ifindex, like `tc qdisc replace dev eth0 parent 1:10 handle 10:
+// netem delay 100ms`. The handle of the qdisc is derived from the class like
+// HtbBuilder does. A leaf qdisc of the same kind, that is already attached to
+// the class, keeps its handle, so that AttachLeaf can be run again. A leaf of
+// another kind is replaced. AttachLeaf returns the attached qdisc.
+func AttachLeaf(tcSocket *Tc, ifindex, classID uint32, kind string, attr Attribute) (*Object, error) {
+	if tcSocket == nil {
+		return nil, ErrNoArg
+	}
+	qdiscs, err := tcSocket.Qdisc().Get()
+	if err != nil {
+		return nil, err
+	}
+	classes, err := tcSocket.Class().Get(&Msg{Family: unix.AF_UNSPEC, Ifindex: ifindex})
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := leafObject(ifindex, classID, kind, attr, qdiscs, classes)
+	if err != nil {
+		return nil, err
+	}
+	if err := tcSocket.Qdisc().Replace(leaf); err != nil {
+		return nil, err
+	}
+	return leaf, nil
+}
+
+// leafObject returns the qdisc, that AttachLeaf attaches to classID, for the
+// dumped qdiscs and classes.
+func leafObject(ifindex, classID uint32, kind string, attr Attribute, qdiscs, classes []Object) (*Object, error) {
+	if ifindex == 0 {
+		return nil, ErrInvalidDev
+	}
+	if attr.Kind != "" && attr.Kind != kind {
+		return nil, fmt.Errorf("leaf %s: Kind %s of attr: %w", kind, attr.Kind, ErrInvalidArg)
+	}
+	attr.Kind = kind
+	if filterKinds[kind] {
+		return nil, fmt.Errorf("leaf %s: not a qdisc: %w", kind, ErrInvalidArg)
+	}
+	if _, min := core.SplitHandle(classID); min == 0 || classID == HandleRoot || classID == HandleIngress {
+		return nil, fmt.Errorf("leaf %s: %s is no class: %w", kind, core.FormatHandle(classID), ErrInvalidArg)
+	}
+
+	var found bool
+	for _, class := range classes {
+		if class.Ifindex != ifindex {
+			continue
+		}
+		if class.Handle == classID {
+			found = true
+		}
+		if class.Parent == classID {
+			// The kernel refuses qdiscs for inner classes, like htb_graft().
+			return nil, fmt.Errorf("leaf %s: class %s has child classes: %w",
+				kind, core.FormatHandle(classID), ErrInvalidArg)
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("leaf %s: class %s of device %d: %w",
+			kind, core.FormatHandle(classID), ifindex, syscall.ENOENT)
+	}
+
+	leaf := &Object{
+		Msg{
+			Family:  unix.AF_UNSPEC,
+			Ifindex: ifindex,
+			Parent:  classID,
+		},
+		attr,
+	}
+	used := map[uint16]bool{}
+	for _, qdisc := range qdiscs {
+		if qdisc.Ifindex != ifindex || qdisc.Handle == 0 {
+			continue
+		}
+		if qdisc.Parent == classID && qdisc.Kind == kind {
+			leaf.Handle = qdisc.Handle
+			return leaf, nil
+		}
+		maj, _ := core.SplitHandle(qdisc.Handle)
+		used[uint16(maj)] = true
+	}
+	leaf.Handle = core.BuildHandle(uint32(leafMajor(classID, used)), 0x0)
+	return leaf, nil
+}
+
+// leafMajor returns the major for the handle of a leaf qdisc of the class
+// classID, that is not used. It prefers the minor of the class as major, like
+// 1:10 -> 10: .
+func leafMajor(classID uint32, used map[uint16]bool) uint16 {
+	_, min := core.SplitHandle(classID)
+	maj := uint16(min)
+	for used[maj] || maj == 0 || maj == 0xFFFF {
+		maj++
+	}
+	used[maj] = true
+	return maj
+}
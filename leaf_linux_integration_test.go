@@ -0,0 +1,91 @@
+//go:build integration && linux
+// +build integration,linux
+
+package tc
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/jsimonetti/rtnetlink"
+	"golang.org/x/sys/unix"
+)
+
+func TestLinuxAttachLeaf(t *testing.T) {
+	tcIface := "tcLeaf"
+
+	rtnl, err := setupVethInterface(tcIface)
+	if err != nil {
+		t.Skipf("could not setup veth interface: %v", err)
+	}
+	defer rtnl.Close()
+
+	devID, err := net.InterfaceByName(tcIface)
+	if err != nil {
+		t.Fatalf("could not get interface ID: %v", err)
+	}
+	defer func(devID uint32, rtnl *rtnetlink.Conn) {
+		// Deleting one end of the pair removes the peer as well.
+		if err := rtnl.Link.Delete(devID); err != nil {
+			t.Fatalf("could not delete interface: %v", err)
+		}
+	}(uint32(devID.Index), rtnl)
+
+	tcnl, err := Open(&Config{})
+	if err != nil {
+		t.Fatalf("could not open rtnetlink socket: %v", err)
+	}
+	defer func() {
+		if err := tcnl.Close(); err != nil {
+			t.Fatalf("could not close rtnetlink socket: %v", err)
+		}
+	}()
+
+	ifindex := uint32(devID.Index)
+	b := NewHtbBuilder(ifindex)
+	b.Root(0x10).Class("1:10", 1250000, 12500000)
+	if err := b.Apply(tcnl); err != nil {
+		t.Fatalf("could not apply htb hierarchy: %v", err)
+	}
+
+	classID := core.BuildHandle(0x1, 0x10)
+	leafs := func() []Object {
+		qdiscs, err := tcnl.Qdisc().Get()
+		if err != nil {
+			t.Fatalf("could not get qdiscs: %v", err)
+		}
+		var leafs []Object
+		for _, qdisc := range qdiscs {
+			if qdisc.Ifindex == ifindex && qdisc.Parent == classID {
+				leafs = append(leafs, qdisc)
+			}
+		}
+		return leafs
+	}
+
+	netem := Attribute{Netem: &Netem{Qopt: NetemQopt{Latency: 1000, Limit: 1000}}}
+	for i := 0; i < 2; i++ {
+		// Attaching the leaf again keeps its handle.
+		leaf, err := AttachLeaf(tcnl, ifindex, classID, "netem", netem)
+		if errors.Is(err, unix.ENOENT) {
+			t.Skipf("netem is not available: %v", err)
+		} else if err != nil {
+			t.Fatalf("could not attach netem: %v", err)
+		}
+		if got := leafs(); len(got) != 1 || got[0].Kind != "netem" || got[0].Handle != leaf.Handle {
+			t.Fatalf("expected netem %x below %x but got %v", leaf.Handle, classID, got)
+		}
+		if leaf.Handle != core.BuildHandle(0x10, 0x0) {
+			t.Fatalf("expected handle 10: but got %x", leaf.Handle)
+		}
+	}
+
+	if _, err := AttachLeaf(tcnl, ifindex, classID, "fq_codel", Attribute{FqCodel: &FqCodel{}}); err != nil {
+		t.Fatalf("could not replace netem: %v", err)
+	}
+	if got := leafs(); len(got) != 1 || got[0].Kind != "fq_codel" {
+		t.Fatalf("expected fq_codel below %x but got %v", classID, got)
+	}
+}
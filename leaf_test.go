@@ -0,0 +1,75 @@
+package tc
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLeafObject(t *testing.T) {
+	msg := func(handle, parent uint32) Msg {
+		return Msg{Ifindex: 2, Handle: handle, Parent: parent}
+	}
+	qdiscs := []Object{
+		{Msg: msg(0x10000, HandleRoot), Attribute: Attribute{Kind: "htb"}},
+		// 20: is in use, so the leaf of 1:20 gets 21:
+		{Msg: msg(0x200000, 0x10030), Attribute: Attribute{Kind: "netem"}},
+		{Msg: Msg{Ifindex: 3, Handle: 0x100000, Parent: HandleRoot}, Attribute: Attribute{Kind: "htb"}},
+	}
+	classes := []Object{
+		{Msg: msg(0x10001, HandleRoot), Attribute: Attribute{Kind: "htb"}},
+		{Msg: msg(0x10010, 0x10001), Attribute: Attribute{Kind: "htb"}},
+		{Msg: msg(0x10020, 0x10001), Attribute: Attribute{Kind: "htb"}},
+		{Msg: msg(0x10030, 0x10001), Attribute: Attribute{Kind: "htb"}},
+	}
+	netem := Attribute{Netem: &Netem{Qopt: NetemQopt{Limit: 1000}}}
+
+	tests := map[string]struct {
+		ifindex, classID uint32
+		kind             string
+		attr             Attribute
+		handle           uint32
+		err              error
+	}{
+		"minor as major":   {ifindex: 2, classID: 0x10010, kind: "netem", attr: netem, handle: 0x100000},
+		"next free major":  {ifindex: 2, classID: 0x10020, kind: "netem", attr: netem, handle: 0x210000},
+		"existing leaf":    {ifindex: 2, classID: 0x10030, kind: "netem", attr: netem, handle: 0x200000},
+		"other kind":       {ifindex: 2, classID: 0x10030, kind: "fq_codel", handle: 0x300000},
+		"inner class":      {ifindex: 2, classID: 0x10001, kind: "netem", attr: netem, err: ErrInvalidArg},
+		"qdisc handle":     {ifindex: 2, classID: 0x10000, kind: "netem", attr: netem, err: ErrInvalidArg},
+		"root":             {ifindex: 2, classID: HandleRoot, kind: "netem", attr: netem, err: ErrInvalidArg},
+		"unknown class":    {ifindex: 2, classID: 0x10040, kind: "netem", attr: netem, err: syscall.ENOENT},
+		"other device":     {ifindex: 3, classID: 0x10010, kind: "netem", attr: netem, err: syscall.ENOENT},
+		"without device":   {classID: 0x10010, kind: "netem", attr: netem, err: ErrInvalidDev},
+		"filter kind":      {ifindex: 2, classID: 0x10010, kind: "u32", err: ErrInvalidArg},
+		"conflicting kind": {ifindex: 2, classID: 0x10010, kind: "netem", attr: Attribute{Kind: "fq"}, err: ErrInvalidArg},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			leaf, err := leafObject(testcase.ifindex, testcase.classID, testcase.kind, testcase.attr, qdiscs, classes)
+			if !errors.Is(err, testcase.err) {
+				t.Fatalf("expected %v but got %v", testcase.err, err)
+			}
+			if err != nil {
+				return
+			}
+			attr := testcase.attr
+			attr.Kind = testcase.kind
+			want := &Object{Msg: Msg{Ifindex: testcase.ifindex, Handle: testcase.handle, Parent: testcase.classID},
+				Attribute: attr}
+			if diff := cmp.Diff(want, leaf); diff != "" {
+				t.Fatalf("leaf missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("without socket", func(t *testing.T) {
+		if _, err := AttachLeaf(nil, 2, core.BuildHandle(0x1, 0x10), "netem", netem); !errors.Is(err, ErrNoArg) {
+			t.Fatalf("expected ErrNoArg but got %v", err)
+		}
+	})
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 
 	"github.com/florianl/go-tc/internal/unix"
-	"github.com/mdlayher/netlink"
 )
 
 const (
@@ -43,66 +42,72 @@ const (
 	ActRepeat     = 6
 	ActRedirect   = 7
 	ActTrap       = 8
+
+	// ActJump and ActGotoChain carry a value in the lower 28 bits, the
+	// number of actions to skip or the chain to continue with.
+	ActJump      = 0x10000000
+	ActGotoChain = 0x20000000
 )
 
 // Action represents action attributes of various filters and classes
 type Action struct {
-	Kind        string
-	Index       uint32
-	Stats       *GenStats
-	Cookie      *[]byte
-	Flags       *uint64 // 32-bit bitfield value; 32-bit bitfield selector
-	HwStats     *uint64 // 32-bit bitfield value; 32-bit bitfield selector
-	UsedHwStats *uint64 // 32-bit bitfield value; 32-bit bitfield selector
-	InHwCount   *uint32
+	Kind        string    `json:"kind,omitempty"`
+	Index       uint32    `json:"index,omitempty"`
+	Stats       *GenStats `json:"stats,omitempty"`
+	Cookie      *[]byte   `json:"cookie,omitempty"`
+	Flags       *uint64   `json:"flags,omitempty"`         // 32-bit bitfield value; 32-bit bitfield selector
+	HwStats     *uint64   `json:"hw_stats,omitempty"`      // 32-bit bitfield value; 32-bit bitfield selector
+	UsedHwStats *uint64   `json:"used_hw_stats,omitempty"` // 32-bit bitfield value; 32-bit bitfield selector
+	InHwCount   *uint32   `json:"in_hw_count,omitempty"`
 
-	Bpf       *ActBpf
-	ConnMark  *Connmark
-	CSum      *Csum
-	Ct        *Ct
-	CtInfo    *CtInfo
-	Defact    *Defact
-	Gact      *Gact
-	Gate      *Gate
-	Ife       *Ife
-	Ipt       *Ipt
-	Mirred    *Mirred
-	Nat       *Nat
-	Sample    *Sample
-	VLan      *VLan
-	Police    *Police
-	TunnelKey *TunnelKey
-	MPLS      *MPLS
-	SkbEdit   *SkbEdit
-	SkbMod    *SkbMod
+	Bpf       *ActBpf    `json:"bpf,omitempty"`
+	ConnMark  *Connmark  `json:"conn_mark,omitempty"`
+	CSum      *Csum      `json:"c_sum,omitempty"`
+	Ct        *Ct        `json:"ct,omitempty"`
+	CtInfo    *CtInfo    `json:"ct_info,omitempty"`
+	Defact    *Defact    `json:"defact,omitempty"`
+	Gact      *Gact      `json:"gact,omitempty"`
+	Gate      *Gate      `json:"gate,omitempty"`
+	Ife       *Ife       `json:"ife,omitempty"`
+	Ipt       *Ipt       `json:"ipt,omitempty"`
+	Mirred    *Mirred    `json:"mirred,omitempty"`
+	Nat       *Nat       `json:"nat,omitempty"`
+	Sample    *Sample    `json:"sample,omitempty"`
+	VLan      *VLan      `json:"vlan,omitempty"`
+	Police    *Police    `json:"police,omitempty"`
+	TunnelKey *TunnelKey `json:"tunnel_key,omitempty"`
+	MPLS      *MPLS      `json:"mpls,omitempty"`
+	SkbEdit   *SkbEdit   `json:"skb_edit,omitempty"`
+	SkbMod    *SkbMod    `json:"skb_mod,omitempty"`
 }
 
 func unmarshalActions(data []byte, actions *[]*Action) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
+	var multiError error
 	for ad.Next() {
 		action := &Action{}
-		if err := unmarshalAction(ad.Bytes(), action); err != nil {
-			return err
-		}
+		err := unmarshalAction(ad.Bytes(), action)
+		multiError = concatError(multiError, err)
 		*actions = append(*actions, action)
 	}
-	return ad.Err()
+	return concatError(multiError, ad.Err())
 }
 
 // unmarshalAction parses the Action-encoded data and stores the result in the value pointed to by info.
 func unmarshalAction(data []byte, info *Action) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
 	var actOptions []byte
+	var multiError error
 	for ad.Next() {
 		switch ad.Type() {
 		case tcaActKind:
-			info.Kind = ad.String()
+			info.Kind = kindString(ad.Bytes())
 		case tcaActIndex:
 			info.Index = ad.Uint32()
 		case tcaActOptions:
@@ -112,9 +117,8 @@ func unmarshalAction(data []byte, info *Action) error {
 			info.Cookie = &tmp
 		case tcaActStats:
 			stats := &GenStats{}
-			if err := unmarshalGenStats(ad.Bytes(), stats); err != nil {
-				return err
-			}
+			err := unmarshalGenStats(ad.Bytes(), stats)
+			multiError = concatError(multiError, err)
 			info.Stats = stats
 		case tcaActFlags:
 			flags := ad.Uint64()
@@ -131,23 +135,31 @@ func unmarshalAction(data []byte, info *Action) error {
 		case tcaActPad:
 			// padding does not contain data, we just skip it
 		default:
-			return fmt.Errorf("unmarshalAction()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("action", ad.Type(), ad.Bytes()))
 		}
 	}
+	if err := ad.Err(); err != nil {
+		return concatError(multiError, err)
+	}
 	if len(actOptions) > 0 {
-		if err := extractActOptions(actOptions, info, info.Kind); err != nil {
-			return err
-		}
+		err := extractActOptions(actOptions, info, info.Kind)
+		multiError = concatError(multiError, err)
 	}
+	return multiError
+}
 
-	return ad.Err()
+// hasActions reports whether the Actions of a classifier hold an action.
+// A nil pointer and a pointer to an empty slice both mean no actions and the
+// attribute is left out, as some kernels refuse an empty container.
+func hasActions(actions *[]*Action) bool {
+	return actions != nil && len(*actions) > 0
 }
 
 func marshalActions(cmd int, info []*Action) ([]byte, error) {
 	options := []tcOption{}
 
 	for i, action := range info {
-		data, err := marshalAction(cmd, action, tcaActOptions|nlaFNnested)
+		data, err := marshalAction(cmd, action, tcaActOptions)
 		if err != nil {
 			return []byte{}, err
 		}
@@ -168,6 +180,9 @@ func marshalAction(cmd int, info *Action, actOption uint16) ([]byte, error) {
 	if len(info.Kind) == 0 {
 		return []byte{}, fmt.Errorf("kind is missing")
 	}
+	if err := validateKind(info.Kind); err != nil {
+		return []byte{}, fmt.Errorf("action: %w", err)
+	}
 	var err error
 	var data []byte
 
@@ -219,7 +234,7 @@ func marshalAction(cmd int, info *Action, actOption uint16) ([]byte, error) {
 		return []byte{}, err
 	}
 
-	options = append(options, tcOption{Interpretation: vtBytes, Type: actOption, Data: data})
+	options = append(options, tcOption{Interpretation: vtNested, Type: actOption, Data: data})
 	options = append(options, tcOption{Interpretation: vtString, Type: tcaActKind, Data: info.Kind})
 
 	if info.Index != 0 {
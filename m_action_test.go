@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/netlink"
 )
 
 func TestAction(t *testing.T) {
@@ -141,6 +142,59 @@ func TestAction(t *testing.T) {
 		}
 	})
 
+	t.Run("unmarshalAction(padded kind)", func(t *testing.T) {
+		vlan, err := marshalVlan(&VLan{PushID: uint16Ptr(42)})
+		if err != nil {
+			t.Fatalf("could not marshal vlan: %v", err)
+		}
+		data, err := marshalAttributes([]tcOption{
+			{Interpretation: vtBytes, Type: tcaActKind, Data: []byte("vlan\x00\x00\x00\x00")},
+			{Interpretation: vtBytes, Type: tcaActOptions, Data: vlan},
+		})
+		if err != nil {
+			t.Fatalf("could not generate test data: %v", err)
+		}
+		info := &Action{}
+		if err := unmarshalAction(data, info); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if diff := cmp.Diff(&Action{Kind: "vlan", VLan: &VLan{PushID: uint16Ptr(42)}}, info); diff != "" {
+			t.Fatalf("Action missmatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("nested options", func(t *testing.T) {
+		data, err := marshalAction(0, &Action{Kind: "vlan", VLan: &VLan{PushID: uint16Ptr(42)}}, tcaActOptions)
+		if err != nil {
+			t.Fatalf("could not marshal action: %v", err)
+		}
+		ad, err := netlink.NewAttributeDecoder(data)
+		if err != nil {
+			t.Fatalf("could not decode action: %v", err)
+		}
+		var found bool
+		for ad.Next() {
+			if ad.Type() != tcaActOptions {
+				continue
+			}
+			found = true
+			if ad.TypeFlags() != netlink.Nested {
+				t.Fatalf("expected NLA_F_NESTED on options but got %#x", ad.TypeFlags())
+			}
+		}
+		if !found {
+			t.Fatalf("options are missing")
+		}
+	})
+
+	t.Run("invalid kind", func(t *testing.T) {
+		for _, kind := range []string{"vlan\x00", "vlan_with_a_long_name"} {
+			if _, err := marshalAction(0, &Action{Kind: kind}, tcaActOptions); !errors.Is(err, ErrInvalidArg) {
+				t.Fatalf("expected ErrInvalidArg for %q but got %v", kind, err)
+			}
+		}
+	})
+
 	t.Run("unmarshalAction(unknown)", func(t *testing.T) {
 		info := &Action{}
 		if err := unmarshalAction(generateActUnknown(t), info); err == nil {
@@ -183,3 +237,102 @@ func Test_extractActOptions(t *testing.T) {
 		})
 	}
 }
+
+func TestClassifierActions(t *testing.T) {
+	actions := []*Action{
+		{Kind: "gact", Gact: &Gact{Parms: &GactParms{Action: 2}}},
+	}
+
+	classifiers := map[string]struct {
+		typ       uint16
+		marshal   func(actions *[]*Action) ([]byte, error)
+		unmarshal func(data []byte) (*[]*Action, error)
+	}{
+		"u32": {
+			typ:     tcaU32Act,
+			marshal: func(actions *[]*Action) ([]byte, error) { return marshalU32(&U32{Actions: actions}) },
+			unmarshal: func(data []byte) (*[]*Action, error) {
+				info := U32{}
+				err := unmarshalU32(data, &info)
+				return info.Actions, err
+			},
+		},
+		"matchall": {
+			typ: tcaMatchallAct,
+			marshal: func(actions *[]*Action) ([]byte, error) {
+				return marshalMatchall(&Matchall{Actions: actions})
+			},
+			unmarshal: func(data []byte) (*[]*Action, error) {
+				info := Matchall{}
+				err := unmarshalMatchall(data, &info)
+				return info.Actions, err
+			},
+		},
+		"flower": {
+			typ: tcaFlowerAct,
+			marshal: func(actions *[]*Action) ([]byte, error) {
+				return marshalFlower(&Flower{Actions: actions})
+			},
+			unmarshal: func(data []byte) (*[]*Action, error) {
+				info := Flower{}
+				err := unmarshalFlower(data, &info)
+				return info.Actions, err
+			},
+		},
+		"basic": {
+			typ:     tcaBasicAct,
+			marshal: func(actions *[]*Action) ([]byte, error) { return marshalBasic(&Basic{Actions: actions}) },
+			unmarshal: func(data []byte) (*[]*Action, error) {
+				info := Basic{}
+				err := unmarshalBasic(data, &info)
+				return info.Actions, err
+			},
+		},
+	}
+	states := map[string]struct {
+		actions *[]*Action
+		sent    bool
+	}{
+		"nil":       {},
+		"empty":     {actions: &[]*Action{}},
+		"populated": {actions: &actions, sent: true},
+	}
+
+	for kind, classifier := range classifiers {
+		for state, testcase := range states {
+			classifier, testcase := classifier, testcase
+			t.Run(kind+"/"+state, func(t *testing.T) {
+				data, err := classifier.marshal(testcase.actions)
+				if err != nil {
+					t.Fatalf("could not marshal %s: %v", kind, err)
+				}
+				ad, err := netlink.NewAttributeDecoder(data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var sent bool
+				for ad.Next() {
+					if ad.Type() == classifier.typ {
+						sent = true
+					}
+				}
+				if sent != testcase.sent {
+					t.Fatalf("expected the actions to be sent %v but got %v", testcase.sent, sent)
+				}
+				got, err := classifier.unmarshal(data)
+				if err != nil {
+					t.Fatalf("could not unmarshal %s: %v", kind, err)
+				}
+				if !testcase.sent {
+					if got != nil {
+						t.Fatalf("expected no actions but got %v", *got)
+					}
+					return
+				}
+				if got == nil || len(*got) != len(actions) || (*got)[0].Kind != "gact" {
+					t.Fatalf("expected %d actions but got %v", len(actions), got)
+				}
+			})
+		}
+	}
+}
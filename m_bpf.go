@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaActBpfUnspec = iota
@@ -21,19 +17,19 @@ const (
 
 // ActBpf represents policing attributes of various filters and classes
 type ActBpf struct {
-	Tm     *Tcft
-	Parms  *ActBpfParms
-	Ops    *[]byte
-	OpsLen *uint16
-	FD     *uint32
-	Name   *string
-	Tag    *[]byte
-	ID     *uint32
+	Tm     *Tcft        `json:"tm,omitempty"`
+	Parms  *ActBpfParms `json:"parms,omitempty"`
+	Ops    *[]byte      `json:"ops,omitempty"`
+	OpsLen *uint16      `json:"ops_len,omitempty"`
+	FD     *uint32      `json:"fd,omitempty"`
+	Name   *string      `json:"name,omitempty"`
+	Tag    *[]byte      `json:"tag,omitempty"`
+	ID     *uint32      `json:"id,omitempty"`
 }
 
 // unmarshalActBpf parses the ActBpf-encoded data and stores the result in the value pointed to by info.
 func unmarshalActBpf(data []byte, info *ActBpf) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -65,7 +61,7 @@ func unmarshalActBpf(data []byte, info *ActBpf) error {
 		case tcaActBpfPad:
 			// padding does not contain data, we just skip it
 		default:
-			return fmt.Errorf("UnmarshalActBpf()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("action bpf", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -112,9 +108,9 @@ func marshalActBpf(info *ActBpf) ([]byte, error) {
 
 // ActBpfParms from include/uapi/linux/tc_act/tc_bpf.h
 type ActBpfParms struct {
-	Index   uint32
-	Capab   uint32
-	Action  uint32
-	Refcnt  uint32
-	Bindcnt uint32
+	Index   uint32 `json:"index,omitempty"`
+	Capab   uint32 `json:"capab,omitempty"`
+	Action  uint32 `json:"action,omitempty"`
+	Refcnt  uint32 `json:"refcnt,omitempty"`
+	Bindcnt uint32 `json:"bindcnt,omitempty"`
 }
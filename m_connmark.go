@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaConnmarkUnspec = iota
@@ -15,22 +11,22 @@ const (
 
 // Connmark represents policing attributes of various filters and classes
 type Connmark struct {
-	Parms *ConnmarkParam
-	Tm    *Tcft
+	Parms *ConnmarkParam `json:"parms,omitempty"`
+	Tm    *Tcft          `json:"tm,omitempty"`
 }
 
 // ConnmarkParam from include/uapi/linux/tc_act/tc_connmark.h
 type ConnmarkParam struct {
-	Index   uint32
-	Capab   uint32
-	Action  uint32
-	RefCnt  uint32
-	BindCnt uint32
-	Zone    uint16
+	Index   uint32 `json:"index,omitempty"`
+	Capab   uint32 `json:"capab,omitempty"`
+	Action  uint32 `json:"action,omitempty"`
+	RefCnt  uint32 `json:"ref_cnt,omitempty"`
+	BindCnt uint32 `json:"bind_cnt,omitempty"`
+	Zone    uint16 `json:"zone,omitempty"`
 }
 
 func unmarshalConnmark(data []byte, info *Connmark) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -50,7 +46,7 @@ func unmarshalConnmark(data []byte, info *Connmark) error {
 		case tcaConnmarkPad:
 			// padding does not contain data, we just skip it
 		default:
-			return fmt.Errorf("unmarshalConnmark()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("action connmark", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
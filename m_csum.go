@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaCsumUnspec = iota
@@ -15,8 +11,8 @@ const (
 
 // Csum contains attributes of the csum discipline
 type Csum struct {
-	Parms *CsumParms
-	Tm    *Tcft
+	Parms *CsumParms `json:"parms,omitempty"`
+	Tm    *Tcft      `json:"tm,omitempty"`
 }
 
 // marshalCsum returns the binary encoding of Csum
@@ -42,7 +38,7 @@ func marshalCsum(info *Csum) ([]byte, error) {
 
 // unmarshalCsum parses the csum-encoded data and stores the result in the value pointed to by info.
 func unmarshalCsum(data []byte, info *Csum) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -62,7 +58,7 @@ func unmarshalCsum(data []byte, info *Csum) error {
 		case tcaCsumPad:
 			// padding does not contain data, we just skip it
 		default:
-			return fmt.Errorf("UnmarshalCsum()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("action csum", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -70,10 +66,10 @@ func unmarshalCsum(data []byte, info *Csum) error {
 
 // CsumParms from include/uapi/linux/tc_act/tc_csum.h
 type CsumParms struct {
-	Index       uint32
-	Capab       uint32
-	Action      uint32
-	RefCnt      uint32
-	BindCnt     uint32
-	UpdateFlags uint32
+	Index       uint32 `json:"index,omitempty"`
+	Capab       uint32 `json:"capab,omitempty"`
+	Action      uint32 `json:"action,omitempty"`
+	RefCnt      uint32 `json:"ref_cnt,omitempty"`
+	BindCnt     uint32 `json:"bind_cnt,omitempty"`
+	UpdateFlags uint32 `json:"update_flags,omitempty"`
 }
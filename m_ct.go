@@ -3,8 +3,6 @@ package tc
 import (
 	"fmt"
 	"net"
-
-	"github.com/mdlayher/netlink"
 )
 
 const (
@@ -31,33 +29,33 @@ const (
 
 // Ct contains attributes of the ct discipline
 type Ct struct {
-	Parms        *CtParms
-	Tm           *Tcft
-	Action       *uint16
-	Zone         *uint16
-	Mark         *uint32
-	MarkMask     *uint32
-	NatIPv4Min   *net.IP
-	NatIPv4Max   *net.IP
-	NatPortMin   *uint16
-	NatPortMax   *uint16
-	HelperName   *string
-	HelperFamily *uint8
-	HelperProto  *uint8
+	Parms        *CtParms `json:"parms,omitempty"`
+	Tm           *Tcft    `json:"tm,omitempty"`
+	Action       *uint16  `json:"action,omitempty"`
+	Zone         *uint16  `json:"zone,omitempty"`
+	Mark         *uint32  `json:"mark,omitempty"`
+	MarkMask     *uint32  `json:"mark_mask,omitempty"`
+	NatIPv4Min   *net.IP  `json:"nat_ipv4_min,omitempty"`
+	NatIPv4Max   *net.IP  `json:"nat_ipv4_max,omitempty"`
+	NatPortMin   *uint16  `json:"nat_port_min,omitempty"`
+	NatPortMax   *uint16  `json:"nat_port_max,omitempty"`
+	HelperName   *string  `json:"helper_name,omitempty"`
+	HelperFamily *uint8   `json:"helper_family,omitempty"`
+	HelperProto  *uint8   `json:"helper_proto,omitempty"`
 }
 
 // CtParms contains further ct attributes.
 type CtParms struct {
-	Index   uint32
-	Capab   uint32
-	Action  uint32
-	RefCnt  uint32
-	BindCnt uint32
+	Index   uint32 `json:"index,omitempty"`
+	Capab   uint32 `json:"capab,omitempty"`
+	Action  uint32 `json:"action,omitempty"`
+	RefCnt  uint32 `json:"ref_cnt,omitempty"`
+	BindCnt uint32 `json:"bind_cnt,omitempty"`
 }
 
 // unmarshalCt parses the ct-encoded data and stores the result in the value pointed to by info.
 func unmarshalCt(data []byte, info *Ct) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -89,10 +87,10 @@ func unmarshalCt(data []byte, info *Ct) error {
 			tmp := uint32ToIP(ad.Uint32())
 			info.NatIPv4Max = &tmp
 		case tcaCtNatPortMin:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ntohs(ad.Uint16())
 			info.NatPortMin = &tmp
 		case tcaCtNatPortMax:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ntohs(ad.Uint16())
 			info.NatPortMax = &tmp
 		case tcaCtPad:
 			// padding does not contain data, we just skip it
@@ -103,7 +101,7 @@ func unmarshalCt(data []byte, info *Ct) error {
 		case tcaCtHelperProto:
 			info.HelperProto = uint8Ptr(ad.Uint8())
 		default:
-			return fmt.Errorf("UnmarshalCt()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("action ct", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
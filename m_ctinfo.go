@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaCtInfoUnspec = iota
@@ -22,29 +18,29 @@ const (
 
 // CtInfo contains attributes of the ctinfo discipline
 type CtInfo struct {
-	Tm                 *Tcft
-	Act                *CtInfoAct
-	Zone               *uint16
-	ParmsDscpMask      *uint32
-	ParmsDscpStateMask *uint32
-	ParmsCpMarkMask    *uint32
-	StatsDscpSet       *uint64
-	StatsDscpError     *uint64
-	StatsCpMarkSet     *uint64
+	Tm                 *Tcft      `json:"tm,omitempty"`
+	Act                *CtInfoAct `json:"act,omitempty"`
+	Zone               *uint16    `json:"zone,omitempty"`
+	ParmsDscpMask      *uint32    `json:"parms_dscp_mask,omitempty"`
+	ParmsDscpStateMask *uint32    `json:"parms_dscp_state_mask,omitempty"`
+	ParmsCpMarkMask    *uint32    `json:"parms_cp_mark_mask,omitempty"`
+	StatsDscpSet       *uint64    `json:"stats_dscp_set,omitempty"`
+	StatsDscpError     *uint64    `json:"stats_dscp_error,omitempty"`
+	StatsCpMarkSet     *uint64    `json:"stats_cp_mark_set,omitempty"`
 }
 
 // CtInfoAct as tc_ctinfo from include/uapi/linux/tc_act/tc_ctinfo.h
 type CtInfoAct struct {
-	Index   uint32
-	Capab   uint32
-	Action  uint32
-	RefCnt  uint32
-	BindCnt uint32
+	Index   uint32 `json:"index,omitempty"`
+	Capab   uint32 `json:"capab,omitempty"`
+	Action  uint32 `json:"action,omitempty"`
+	RefCnt  uint32 `json:"ref_cnt,omitempty"`
+	BindCnt uint32 `json:"bind_cnt,omitempty"`
 }
 
 // unmarshalCtInfo parses the ctinfo-encoded data and stores the result in the value pointed to by info.
 func unmarshalCtInfo(data []byte, info *CtInfo) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -78,7 +74,7 @@ func unmarshalCtInfo(data []byte, info *CtInfo) error {
 		case tcaCtInfoPad:
 			// padding does not contain data, we just skip it
 		default:
-			return fmt.Errorf("UnmarshalCtInfo()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("action ctinfo", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
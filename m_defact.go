@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaDefUnspec = iota
@@ -16,18 +12,18 @@ const (
 
 // Defact contains attributes of the defact discipline
 type Defact struct {
-	Parms *DefactParms
-	Tm    *Tcft
-	Data  *string
+	Parms *DefactParms `json:"parms,omitempty"`
+	Tm    *Tcft        `json:"tm,omitempty"`
+	Data  *string      `json:"data,omitempty"`
 }
 
 // DefactParms from include/uapi/linux/tc_act/tc_defact.h
 type DefactParms struct {
-	Index   uint32
-	Capab   uint32
-	Action  uint32
-	RefCnt  uint32
-	BindCnt uint32
+	Index   uint32 `json:"index,omitempty"`
+	Capab   uint32 `json:"capab,omitempty"`
+	Action  uint32 `json:"action,omitempty"`
+	RefCnt  uint32 `json:"ref_cnt,omitempty"`
+	BindCnt uint32 `json:"bind_cnt,omitempty"`
 }
 
 // marshalDefact returns the binary encoding of Defact
@@ -56,7 +52,7 @@ func marshalDefact(info *Defact) ([]byte, error) {
 
 // unmarshalDefact parses the defact-encoded data and stores the result in the value pointed to by info.
 func unmarshalDefact(data []byte, info *Defact) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -79,7 +75,7 @@ func unmarshalDefact(data []byte, info *Defact) error {
 		case tcaDefPad:
 			// padding does not contain data, we just skip it
 		default:
-			return fmt.Errorf("unmarshalDefact()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("action defact", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaGactUnspec = iota
@@ -16,25 +12,25 @@ const (
 
 // Gact contains attributes of the gact discipline
 type Gact struct {
-	Tm    *Tcft
-	Parms *GactParms
-	Prob  *GactProb
+	Tm    *Tcft      `json:"tm,omitempty"`
+	Parms *GactParms `json:"parms,omitempty"`
+	Prob  *GactProb  `json:"prob,omitempty"`
 }
 
 // GactProb from include/uapi/linux/tc_act/tc_gact.h
 type GactProb struct {
-	PType   uint16
-	PVal    uint16
-	PAction uint32
+	PType   uint16 `json:"p_type,omitempty"`
+	PVal    uint16 `json:"p_val,omitempty"`
+	PAction uint32 `json:"p_action,omitempty"`
 }
 
 // GactParms from include/uapi/linux/tc_act/tc_gact.h
 type GactParms struct {
-	Index   uint32
-	Capab   uint32
-	Action  uint32
-	RefCnt  uint32
-	BindCnt uint32
+	Index   uint32 `json:"index,omitempty"`
+	Capab   uint32 `json:"capab,omitempty"`
+	Action  uint32 `json:"action,omitempty"`
+	RefCnt  uint32 `json:"ref_cnt,omitempty"`
+	BindCnt uint32 `json:"bind_cnt,omitempty"`
 }
 
 // marshalGact returns the binary encoding of Gact
@@ -68,7 +64,7 @@ func marshalGact(info *Gact) ([]byte, error) {
 
 // unmarshalGact parses the gact-encoded data and stores the result in the value pointed to by info.
 func unmarshalGact(data []byte, info *Gact) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -93,7 +89,7 @@ func unmarshalGact(data []byte, info *Gact) error {
 		case tcaGactPad:
 			// padding does not contain data, we just skip it
 		default:
-			return fmt.Errorf("UnmarshalGact()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("action gact", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaGateUnspec = iota
@@ -23,14 +19,14 @@ const (
 // Gate contains attributes of the gate discipline
 // https://man7.org/linux/man-pages/man8/tc-gate.8.html
 type Gate struct {
-	Tm           *Tcft
-	Parms        *GateParms
-	Priority     *int32
-	BaseTime     *uint64
-	CycleTime    *uint64
-	CycleTimeExt *uint64
-	Flags        *uint32
-	ClockID      *int32
+	Tm           *Tcft      `json:"tm,omitempty"`
+	Parms        *GateParms `json:"parms,omitempty"`
+	Priority     *int32     `json:"priority,omitempty"`
+	BaseTime     *uint64    `json:"base_time,omitempty"`
+	CycleTime    *uint64    `json:"cycle_time,omitempty"`
+	CycleTimeExt *uint64    `json:"cycle_time_ext,omitempty"`
+	Flags        *uint32    `json:"flags,omitempty"`
+	ClockID      *int32     `json:"clock_id,omitempty"`
 }
 
 // marshalGate returns the binary encoding of Gate
@@ -74,7 +70,7 @@ func marshalGate(info *Gate) ([]byte, error) {
 
 // unmarshalGate parses the gate-encoded data and stores the result in the value pointed to by info.
 func unmarshalGate(data []byte, info *Gate) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -106,7 +102,7 @@ func unmarshalGate(data []byte, info *Gate) error {
 		case tcaGateClockID:
 			info.ClockID = int32Ptr(ad.Int32())
 		default:
-			return fmt.Errorf("UnmarshalGate()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("action gate", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -114,9 +110,9 @@ func unmarshalGate(data []byte, info *Gate) error {
 
 // GateParms from include/uapi/linux/tc_act/tc_gate.h
 type GateParms struct {
-	Index   uint32
-	Capab   uint32
-	Action  uint32
-	RefCnt  uint32
-	BindCnt uint32
+	Index   uint32 `json:"index,omitempty"`
+	Capab   uint32 `json:"capab,omitempty"`
+	Action  uint32 `json:"action,omitempty"`
+	RefCnt  uint32 `json:"ref_cnt,omitempty"`
+	BindCnt uint32 `json:"bind_cnt,omitempty"`
 }
@@ -3,8 +3,6 @@ package tc
 import (
 	"fmt"
 	"net"
-
-	"github.com/mdlayher/netlink"
 )
 
 const (
@@ -20,21 +18,21 @@ const (
 
 // Ife contains attribute of the ife discipline
 type Ife struct {
-	Parms *IfeParms
-	SMac  *net.HardwareAddr
-	DMac  *net.HardwareAddr
-	Type  *uint16
-	Tm    *Tcft
+	Parms *IfeParms         `json:"parms,omitempty"`
+	SMac  *net.HardwareAddr `json:"s_mac,omitempty"`
+	DMac  *net.HardwareAddr `json:"d_mac,omitempty"`
+	Type  *uint16           `json:"type,omitempty"`
+	Tm    *Tcft             `json:"tm,omitempty"`
 }
 
 // IfeParms from include/uapi/linux/tc_act/tc_ife.h
 type IfeParms struct {
-	Index   uint32
-	Capab   uint32
-	Action  uint32
-	RefCnt  uint32
-	BindCnt uint32
-	Flags   uint16
+	Index   uint32 `json:"index,omitempty"`
+	Capab   uint32 `json:"capab,omitempty"`
+	Action  uint32 `json:"action,omitempty"`
+	RefCnt  uint32 `json:"ref_cnt,omitempty"`
+	BindCnt uint32 `json:"bind_cnt,omitempty"`
+	Flags   uint16 `json:"flags,omitempty"`
 }
 
 // marshalIfe returns the binary encoding of Ife
@@ -69,7 +67,7 @@ func marshalIfe(info *Ife) ([]byte, error) {
 
 // unmarshalIfe parses the ife-encoded data and stores the result in the value pointed to by info.
 func unmarshalIfe(data []byte, info *Ife) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -98,7 +96,7 @@ func unmarshalIfe(data []byte, info *Ife) error {
 		case tcaIfePad:
 			// padding does not contain data, we just skip it
 		default:
-			return fmt.Errorf("unmarshalIfe()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("action ife", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaIptUnspec = iota
@@ -19,22 +15,22 @@ const (
 
 // Ipt contains attribute of the ipt discipline
 type Ipt struct {
-	Table *string
-	Hook  *uint32
-	Index *uint32
-	Cnt   *IptCnt
-	Tm    *Tcft
+	Table *string `json:"table,omitempty"`
+	Hook  *uint32 `json:"hook,omitempty"`
+	Index *uint32 `json:"index,omitempty"`
+	Cnt   *IptCnt `json:"cnt,omitempty"`
+	Tm    *Tcft   `json:"tm,omitempty"`
 }
 
 // IptCnt as tc_cnt from include/uapi/linux/pkt_cls.h
 type IptCnt struct {
-	RefCnt  uint32
-	BindCnt uint32
+	RefCnt  uint32 `json:"ref_cnt,omitempty"`
+	BindCnt uint32 `json:"bind_cnt,omitempty"`
 }
 
 // unmarshalIpt parses the ipt-encoded data and stores the result in the value pointed to by info.
 func unmarshalIpt(data []byte, info *Ipt) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -60,7 +56,7 @@ func unmarshalIpt(data []byte, info *Ipt) error {
 		case tcaIptPad:
 			// padding does not contain data, we just skip it
 		default:
-			return fmt.Errorf("UnmarshalIpt()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("action ipt", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
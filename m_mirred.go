@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaMirredUnspec = iota
@@ -16,24 +12,24 @@ const (
 
 // Mirred represents policing attributes of various filters and classes
 type Mirred struct {
-	Parms   *MirredParam
-	Tm      *Tcft
-	BlockID *uint32
+	Parms   *MirredParam `json:"parms,omitempty"`
+	Tm      *Tcft        `json:"tm,omitempty"`
+	BlockID *uint32      `json:"block_id,omitempty"`
 }
 
 // MirredParam from include/uapi/linux/tc_act/tc_mirred.h
 type MirredParam struct {
-	Index   uint32
-	Capab   uint32
-	Action  uint32
-	RefCnt  uint32
-	BindCnt uint32
-	Eaction uint32
-	IfIndex uint32
+	Index   uint32 `json:"index,omitempty"`
+	Capab   uint32 `json:"capab,omitempty"`
+	Action  uint32 `json:"action,omitempty"`
+	RefCnt  uint32 `json:"ref_cnt,omitempty"`
+	BindCnt uint32 `json:"bind_cnt,omitempty"`
+	Eaction uint32 `json:"eaction,omitempty"`
+	IfIndex uint32 `json:"if_index,omitempty"`
 }
 
 func unmarshalMirred(data []byte, info *Mirred) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -55,7 +51,7 @@ func unmarshalMirred(data []byte, info *Mirred) error {
 		case tcaMirredBlockID:
 			info.BlockID = uint32Ptr(ad.Uint32())
 		default:
-			return fmt.Errorf("unmarshalMirred()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("action mirred", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaMPLSUnspec = iota
@@ -33,27 +29,27 @@ const (
 // MPLS contains attributes of the mpls discipline
 // https://man7.org/linux/man-pages/man8/tc-mpls.8.html
 type MPLS struct {
-	Parms *MPLSParam
-	Tm    *Tcft
-	Proto *int16
-	Label *uint32
-	TC    *uint8
-	TTL   *uint8
-	BOS   *uint8
+	Parms *MPLSParam `json:"parms,omitempty"`
+	Tm    *Tcft      `json:"tm,omitempty"`
+	Proto *int16     `json:"proto,omitempty"`
+	Label *uint32    `json:"label,omitempty"`
+	TC    *uint8     `json:"tc,omitempty"`
+	TTL   *uint8     `json:"ttl,omitempty"`
+	BOS   *uint8     `json:"bos,omitempty"`
 }
 
 // MPLSParam contains further MPLS attributes.
 type MPLSParam struct {
-	Index   uint32
-	Capab   uint32
-	Action  uint32
-	RefCnt  uint32
-	BindCnt uint32
-	MAction MPLSAction
+	Index   uint32     `json:"index,omitempty"`
+	Capab   uint32     `json:"capab,omitempty"`
+	Action  uint32     `json:"action,omitempty"`
+	RefCnt  uint32     `json:"ref_cnt,omitempty"`
+	BindCnt uint32     `json:"bind_cnt,omitempty"`
+	MAction MPLSAction `json:"m_action,omitempty"`
 }
 
 func unmarshalMPLS(data []byte, info *MPLS) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -73,7 +69,7 @@ func unmarshalMPLS(data []byte, info *MPLS) error {
 		case tcaMPLSPad:
 			// padding does not contain data, we just skip it
 		case tcaMPLSProto: /* be16; eth_type of pushed or next (for pop) header. */
-			tmp := endianSwapUint16(uint16(ad.Int16()))
+			tmp := ntohs(uint16(ad.Int16()))
 			info.Proto = int16Ptr(int16(tmp))
 		case tcaMPLSLabel:
 			info.Label = uint32Ptr(ad.Uint32())
@@ -84,7 +80,7 @@ func unmarshalMPLS(data []byte, info *MPLS) error {
 		case tcaMPLSBOS:
 			info.BOS = uint8Ptr(ad.Uint8())
 		default:
-			return fmt.Errorf("unmarshalMPLS()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("action mpls", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -1,9 +1,8 @@
 package tc
 
 import (
+	"encoding/binary"
 	"fmt"
-
-	"github.com/mdlayher/netlink"
 )
 
 const (
@@ -15,21 +14,52 @@ const (
 
 // Nat contains attribute of the nat discipline
 type Nat struct {
-	Parms *NatParms
-	Tm    *Tcft
+	Parms *NatParms `json:"parms,omitempty"`
+	Tm    *Tcft     `json:"tm,omitempty"`
 }
 
 // NatParms from include/uapi/linux/tc_act/tc_nat.h
+// OldAddr, NewAddr and Mask are IPv4 addresses in host byte order, like
+// 0x0a000001 for 10.0.0.1. They are sent in network byte order.
 type NatParms struct {
-	Index   uint32
-	Capab   uint32
-	Action  uint32
-	RefCnt  uint32
-	BindCnt uint32
-	OldAddr uint32
-	NewAddr uint32
-	Mask    uint32
-	Flags   uint32
+	Index   uint32 `json:"index,omitempty"`
+	Capab   uint32 `json:"capab,omitempty"`
+	Action  uint32 `json:"action,omitempty"`
+	RefCnt  uint32 `json:"ref_cnt,omitempty"`
+	BindCnt uint32 `json:"bind_cnt,omitempty"`
+	OldAddr uint32 `json:"old_addr,omitempty"`
+	NewAddr uint32 `json:"new_addr,omitempty"`
+	Mask    uint32 `json:"mask,omitempty"`
+	Flags   uint32 `json:"flags,omitempty"`
+}
+
+// natParmsLen is the size of struct tc_nat.
+const natParmsLen = 36
+
+func (p NatParms) size() int { return natParmsLen }
+
+func (p NatParms) encode(b []byte) {
+	nativeEndian.PutUint32(b[0:], p.Index)
+	nativeEndian.PutUint32(b[4:], p.Capab)
+	nativeEndian.PutUint32(b[8:], p.Action)
+	nativeEndian.PutUint32(b[12:], p.RefCnt)
+	nativeEndian.PutUint32(b[16:], p.BindCnt)
+	binary.BigEndian.PutUint32(b[20:], p.OldAddr)
+	binary.BigEndian.PutUint32(b[24:], p.NewAddr)
+	binary.BigEndian.PutUint32(b[28:], p.Mask)
+	nativeEndian.PutUint32(b[32:], p.Flags)
+}
+
+func (p *NatParms) decode(b []byte) {
+	p.Index = nativeEndian.Uint32(b[0:])
+	p.Capab = nativeEndian.Uint32(b[4:])
+	p.Action = nativeEndian.Uint32(b[8:])
+	p.RefCnt = nativeEndian.Uint32(b[12:])
+	p.BindCnt = nativeEndian.Uint32(b[16:])
+	p.OldAddr = binary.BigEndian.Uint32(b[20:])
+	p.NewAddr = binary.BigEndian.Uint32(b[24:])
+	p.Mask = binary.BigEndian.Uint32(b[28:])
+	p.Flags = nativeEndian.Uint32(b[32:])
 }
 
 // marshalNat returns the binary encoding of Ife
@@ -55,7 +85,7 @@ func marshalNat(info *Nat) ([]byte, error) {
 
 // unmarshalNat parses the nat-encoded data and stores the result in the value pointed to by info.
 func unmarshalNat(data []byte, info *Nat) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -75,7 +105,7 @@ func unmarshalNat(data []byte, info *Nat) error {
 		case tcaNatPad:
 			// padding does not contain data, we just skip it
 		default:
-			return fmt.Errorf("unmarshalNat()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("action nat", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
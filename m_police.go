@@ -2,8 +2,9 @@ package tc
 
 import (
 	"fmt"
+	"math"
 
-	"github.com/mdlayher/netlink"
+	"github.com/florianl/go-tc/core"
 )
 
 const (
@@ -33,19 +34,124 @@ const (
 
 // Police represents policing attributes of various filters and classes
 type Police struct {
-	Tbf        *Policy
-	Rate       *RateSpec
-	PeakRate   *RateSpec
-	AvRate     *uint32
-	Result     *uint32
-	Tm         *Tcft
-	Rate64     *uint64
-	PeakRate64 *uint64
+	Tbf        *Policy   `json:"tbf,omitempty"`
+	Rate       *RateSpec `json:"rate,omitempty"`
+	PeakRate   *RateSpec `json:"peak_rate,omitempty"`
+	AvRate     *uint32   `json:"av_rate,omitempty"`
+	Result     *uint32   `json:"result,omitempty"`
+	Tm         *Tcft     `json:"tm,omitempty"`
+	Rate64     *uint64   `json:"rate64,omitempty"`
+	PeakRate64 *uint64   `json:"peak_rate64,omitempty"`
+
+	// Conform and Exceed are the verdicts for packets within and above the
+	// rate, like `conform-exceed drop/pipe` of tc sets Exceed to PolicyShot
+	// and Conform to PolicyPipe. Conform is sent as Result and Exceed as
+	// Action of Tbf. They are encoded alike for the police of classifiers and
+	// the police action. Without Conform, the kernel uses PolicyOk.
+	Conform *PolicyAction `json:"conform,omitempty"`
+	Exceed  *PolicyAction `json:"exceed,omitempty"`
+}
+
+// NewPolice returns the Police for rate in bytes per second, that allows
+// bursts of burst bytes and packets of up to mtu bytes, like
+// `tc ... police rate RATE burst BURST mtu MTU EXCEED` computes it. Packets
+// above the rate get the verdict exceed. If mtu is 0, the kernel uses the
+// largest size of the rate table. The rate table is generated, when the
+// Police is marshaled. An error is returned, if the Police does not pass
+// Validate.
+func NewPolice(rate uint64, burst, mtu uint32, exceed PolicyAction) (*Police, error) {
+	if rate == 0 {
+		return nil, fmt.Errorf("NewPolice: rate is required: %w", ErrInvalidArg)
+	}
+	if rate >= math.MaxUint32 {
+		return nil, fmt.Errorf("NewPolice: rate %d requires Rate64: %w", rate, ErrNotImplemented)
+	}
+	spec, err := NewRateSpec(rate, mtu, LinklayerEthernet, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	info := &Police{
+		Tbf: &Policy{
+			Action: exceed,
+			Burst:  core.XmitTime(rate, burst),
+			Mtu:    mtu,
+			Rate:   spec,
+		},
+		Exceed: &exceed,
+	}
+	if err := info.Validate(); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Validate checks info for configurations, that the kernel accepts, but that
+// do not police as intended, like a burst, that is smaller than the MTU. As
+// the bucket never holds enough tokens for larger packets, they exceed the
+// rate regardless of the traffic.
+func (info *Police) Validate() error {
+	if info == nil {
+		return fmt.Errorf("Police: %w", ErrNoArg)
+	}
+	if info.Tbf == nil {
+		return fmt.Errorf("police: Tbf is required: %w", ErrNoArg)
+	}
+	tbf := info.Tbf
+	if tbf.Rate.Rate == 0 {
+		if tbf.PeakRate.Rate != 0 {
+			return fmt.Errorf("police: Tbf.PeakRate requires Tbf.Rate: %w", ErrInvalidArg)
+		}
+		return nil
+	}
+	mtu := tbf.Mtu
+	if mtu == 0 {
+		// linux/net/sched/act_police.c:tcf_police_init() limits packets
+		// to the largest size of the rate table.
+		mtu = 255 << uint(tbf.Rate.CellLog)
+	}
+	if burst := core.XmitSize(uint64(tbf.Rate.Rate), tbf.Burst); burst < mtu {
+		return fmt.Errorf("police: burst of %d bytes is smaller than the mtu of %d bytes, larger packets always exceed the rate: %w",
+			burst, mtu, ErrInvalidArg)
+	}
+	return nil
+}
+
+// policeVerdicts returns the conform and exceed verdicts of info, as the
+// kernel applies them.
+func policeVerdicts(info *Police) (conform, exceed PolicyAction) {
+	switch {
+	case info.Conform != nil:
+		conform = *info.Conform
+	case info.Result != nil:
+		conform = PolicyAction(*info.Result)
+	}
+	switch {
+	case info.Exceed != nil:
+		exceed = *info.Exceed
+	case info.Tbf != nil:
+		exceed = info.Tbf.Action
+	}
+	return conform, exceed
+}
+
+// canonicalPolice returns a copy of info, that holds the verdicts in Conform
+// and Exceed only, so that Diff compares them regardless of the representation.
+func canonicalPolice(info Police) Police {
+	conform, exceed := policeVerdicts(&info)
+	info.Result = nil
+	info.Conform = &conform
+	if info.Tbf != nil {
+		tbf := *info.Tbf
+		tbf.Action = PolicyOk
+		info.Tbf = &tbf
+		info.Exceed = &exceed
+	}
+	return info
 }
 
 // unmarshalPolice parses the Police-encoded data and stores the result in the value pointed to by info.
 func unmarshalPolice(data []byte, info *Police) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -57,12 +163,21 @@ func unmarshalPolice(data []byte, info *Police) error {
 			err = unmarshalStruct(ad.Bytes(), policy)
 			multiError = concatError(multiError, err)
 			info.Tbf = policy
+			exceed := policy.Action
+			info.Exceed = &exceed
 		case tcaPoliceRate:
+			if len(ad.Bytes()) == rateTableLen {
+				// The rate table is generated from Tbf.
+				continue
+			}
 			rate := &RateSpec{}
 			err = unmarshalStruct(ad.Bytes(), rate)
 			multiError = concatError(multiError, err)
 			info.Rate = rate
 		case tcaPolicePeakRate:
+			if len(ad.Bytes()) == rateTableLen {
+				continue
+			}
 			rate := &RateSpec{}
 			err = unmarshalStruct(ad.Bytes(), rate)
 			multiError = concatError(multiError, err)
@@ -71,6 +186,8 @@ func unmarshalPolice(data []byte, info *Police) error {
 			info.AvRate = uint32Ptr(ad.Uint32())
 		case tcaPoliceResult:
 			info.Result = uint32Ptr(ad.Uint32())
+			conform := PolicyAction(*info.Result)
+			info.Conform = &conform
 		case tcaPoliceTm:
 			tm := &Tcft{}
 			err = unmarshalStruct(ad.Bytes(), tm)
@@ -80,12 +197,12 @@ func unmarshalPolice(data []byte, info *Police) error {
 			// padding does not contain data, we just skip it
 		case tcaPoliceRate64:
 			info.Rate64 = uint64Ptr(ad.Uint64())
-			return ErrNotImplemented
+			multiError = concatError(multiError, ErrNotImplemented)
 		case tcaPolicePeakRate64:
 			info.PeakRate64 = uint64Ptr(ad.Uint64())
-			return ErrNotImplemented
+			multiError = concatError(multiError, ErrNotImplemented)
 		default:
-			return fmt.Errorf("UnmarshalPolice()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("police", ad.Type(), ad.Bytes()))
 
 		}
 	}
@@ -112,16 +229,47 @@ func marshalPolice(info *Police) ([]byte, error) {
 		multiError = concatError(multiError, err)
 		options = append(options, tcOption{Interpretation: vtBytes, Type: tcaPolicePeakRate, Data: data})
 	}
+	if info.Conform != nil && info.Result != nil && PolicyAction(*info.Result) != *info.Conform {
+		return []byte{}, fmt.Errorf("police: conform %d and result %d: %w", *info.Conform, *info.Result, ErrInvalidArg)
+	}
+	if info.Exceed != nil {
+		if info.Tbf == nil {
+			return []byte{}, fmt.Errorf("police: exceed is sent with tbf: %w", ErrNoArg)
+		}
+		if info.Tbf.Action != PolicyOk && info.Tbf.Action != *info.Exceed {
+			return []byte{}, fmt.Errorf("police: exceed %d and action %d of tbf: %w",
+				*info.Exceed, info.Tbf.Action, ErrInvalidArg)
+		}
+	}
+	conform, exceed := policeVerdicts(info)
 	if info.Tbf != nil {
-		data, err := marshalStruct(info.Tbf)
+		tbf := *info.Tbf
+		tbf.Action = exceed
+		data, err := marshalStruct(&tbf)
 		multiError = concatError(multiError, err)
 		options = append(options, tcOption{Interpretation: vtBytes, Type: tcaPoliceTbf, Data: data})
+		// linux/net/sched/act_police.c:tcf_police_init() requires the rate
+		// tables for the rates of Tbf. Like tc, they follow Tbf.
+		if info.Rate == nil && tbf.Rate.Rate != 0 {
+			rtab, err := generateRateTable(&Policy{Mtu: tbf.Mtu, Rate: tbf.Rate})
+			multiError = concatError(multiError, err)
+			options = append(options, tcOption{Interpretation: vtBytes, Type: tcaPoliceRate, Data: rtab})
+		}
+		if info.PeakRate == nil && tbf.PeakRate.Rate != 0 {
+			ptab, err := generateRateTable(&Policy{Mtu: tbf.Mtu, PeakRate: tbf.PeakRate})
+			multiError = concatError(multiError, err)
+			options = append(options, tcOption{Interpretation: vtBytes, Type: tcaPolicePeakRate, Data: ptab})
+		}
 	}
 	if info.AvRate != nil {
 		options = append(options, tcOption{Interpretation: vtUint32, Type: tcaPoliceAvRate, Data: uint32Value(info.AvRate)})
 	}
-	if info.Result != nil {
+	switch {
+	case info.Result != nil:
 		options = append(options, tcOption{Interpretation: vtUint32, Type: tcaPoliceResult, Data: uint32Value(info.Result)})
+	case conform != PolicyOk:
+		// Like tc, the default of the kernel is not sent.
+		options = append(options, tcOption{Interpretation: vtUint32, Type: tcaPoliceResult, Data: uint32(conform)})
 	}
 	if info.Rate64 != nil {
 		return []byte{}, fmt.Errorf("police: rate64: %w", ErrNotImplemented)
@@ -1,25 +1,38 @@
 package tc
 
 import (
+	"bytes"
 	"errors"
 	"testing"
 
+	"github.com/florianl/go-tc/core"
 	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nlenc"
 )
 
+func policyActionPtr(v PolicyAction) *PolicyAction {
+	return &v
+}
+
 func TestPolice(t *testing.T) {
 	tests := map[string]struct {
 		val  Police
 		err1 error
 		err2 error
 	}{
-		"simple":          {val: Police{AvRate: uint32Ptr(1337), Result: uint32Ptr(42)}},
+		"simple":          {val: Police{AvRate: uint32Ptr(1337), Result: uint32Ptr(42), Conform: policyActionPtr(42)}},
 		"invalidArgument": {val: Police{AvRate: uint32Ptr(1337), Result: uint32Ptr(42), Tm: &Tcft{Install: 1}}, err1: ErrNoArgAlter},
 		"tbfOnly": {val: Police{Tbf: &Policy{
 			Index: 0x0, Action: 0x2, Limit: 0x0, Burst: 0x4c4b40, Mtu: 0x2400,
 			Rate:     RateSpec{CellLog: 0x6, Linklayer: 0x1, Overhead: 1, CellAlign: 0xffff, Mpu: 1, Rate: 0x7d},
 			PeakRate: RateSpec{CellLog: 1, Linklayer: 1, Overhead: 1, CellAlign: 1, Mpu: 1, Rate: 1},
-		}}},
+		}, Exceed: policyActionPtr(PolicyShot)}},
+		"verdicts without tbf": {val: Police{Exceed: policyActionPtr(PolicyShot)}, err1: ErrNoArg},
+		"conflicting conform": {val: Police{Result: uint32Ptr(1), Conform: policyActionPtr(PolicyPipe)},
+			err1: ErrInvalidArg},
+		"conflicting exceed": {val: Police{Tbf: &Policy{Action: PolicyShot}, Exceed: policyActionPtr(PolicyPipe)},
+			err1: ErrInvalidArg},
 		"rate64":     {val: Police{Rate64: uint64Ptr(42)}, err1: ErrNotImplemented},
 		"peakrate64": {val: Police{PeakRate64: uint64Ptr(123)}, err1: ErrNotImplemented},
 		"rates":      {val: Police{Rate: &RateSpec{Rate: 42}, PeakRate: &RateSpec{Rate: 1337}}},
@@ -58,3 +71,190 @@ func TestPolice(t *testing.T) {
 		}
 	})
 }
+
+func TestPoliceVerdicts(t *testing.T) {
+	verdicts := map[string]PolicyAction{
+		"ok":         PolicyOk,
+		"reclassify": PolicyReclassify,
+		"drop":       PolicyShot,
+		"pipe":       PolicyPipe,
+	}
+	// captured returns the options, that `tc filter add dev eth0 parent 1:
+	// basic police rate 1mbit burst 10k conform-exceed exceed/conform` sends.
+	captured := func(exceed, conform PolicyAction) []byte {
+		// struct tc_police in host byte order, see tc/m_police.c of iproute2.
+		tbf := make([]byte, 60)
+		nlenc.PutUint16(tbf[0:2], 60)
+		nlenc.PutUint16(tbf[2:4], tcaPoliceTbf)
+		nlenc.PutUint32(tbf[8:12], uint32(exceed)) // action
+		nlenc.PutUint32(tbf[16:20], 0x9c40)        // burst
+		nlenc.PutUint32(tbf[20:24], 0x2400)        // mtu
+		tbf[24], tbf[25] = 0x6, 0x1                // rate.cell_log, rate.linklayer
+		nlenc.PutUint16(tbf[28:30], 0xffff)        // rate.cell_align
+		nlenc.PutUint32(tbf[32:36], 0x1e848)       // rate.rate
+		// The rate table follows struct tc_police.
+		rtab := make([]byte, 4+rateTableLen)
+		nlenc.PutUint16(rtab[0:2], uint16(len(rtab)))
+		nlenc.PutUint16(rtab[2:4], tcaPoliceRate)
+		table, err := RateSpec{Linklayer: 0x1, Rate: 0x1e848}.RateTable(0x2400)
+		if err != nil {
+			t.Fatalf("could not calculate rate table: %v", err)
+		}
+		for i, v := range table {
+			nativeEndian.PutUint32(rtab[4+4*i:], v)
+		}
+		tbf = append(tbf, rtab...)
+		if conform == PolicyOk {
+			return tbf
+		}
+		result := make([]byte, 8)
+		nlenc.PutUint16(result[0:2], 8)
+		nlenc.PutUint16(result[2:4], tcaPoliceResult)
+		nlenc.PutUint32(result[4:8], uint32(conform))
+		return append(tbf, result...)
+	}
+
+	for exceedName, exceed := range verdicts {
+		for conformName, conform := range verdicts {
+			exceed, conform := exceed, conform
+			t.Run(exceedName+"/"+conformName, func(t *testing.T) {
+				info := Police{
+					Tbf: &Policy{Burst: 0x9c40, Mtu: 0x2400,
+						Rate: RateSpec{CellLog: 0x6, Linklayer: 0x1, CellAlign: 0xffff, Rate: 0x1e848}},
+					Conform: &conform,
+					Exceed:  &exceed,
+				}
+				data, err := marshalPolice(&info)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				want := captured(exceed, conform)
+				if !bytes.Equal(data, want) {
+					t.Fatalf("encoding missmatch:\nwant %x\ngot  %x", want, data)
+				}
+
+				val := Police{}
+				if err := unmarshalPolice(want, &val); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if val.Exceed == nil || *val.Exceed != exceed || val.Tbf.Action != exceed {
+					t.Fatalf("expected exceed %d but got %v", exceed, val.Exceed)
+				}
+				if got, _ := policeVerdicts(&val); got != conform {
+					t.Fatalf("expected conform %d but got %d", conform, got)
+				}
+			})
+		}
+	}
+}
+
+func TestNewPoliceIproute2(t *testing.T) {
+	restore, err := core.SetClock(0x3e8, 0x40, 0xf4240)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restore()
+	fixtures := iproute2Options(t, "testdata/iproute2_police.txt")
+
+	tests := map[string]struct {
+		rate   uint64
+		burst  uint32
+		mtu    uint32
+		exceed PolicyAction
+	}{
+		"tc actions add action police rate 1mbit burst 10k drop": {
+			rate: 125000, burst: 10240, exceed: PolicyShot,
+		},
+		"tc actions add action police rate 10mbit burst 64k mtu 1514 conform-exceed pipe": {
+			rate: 1250000, burst: 65536, mtu: 1514, exceed: PolicyPipe,
+		},
+		"tc actions add action police rate 1gbit burst 1m mtu 9000 drop": {
+			rate: 125000000, burst: 1048576, mtu: 9000, exceed: PolicyShot,
+		},
+	}
+	for command, testcase := range tests {
+		testcase := testcase
+		t.Run(command, func(t *testing.T) {
+			want, ok := fixtures[command]
+			if !ok {
+				t.Fatalf("missing fixture")
+			}
+			info, err := NewPolice(testcase.rate, testcase.burst, testcase.mtu, testcase.exceed)
+			if err != nil {
+				t.Fatalf("could not create Police: %v", err)
+			}
+			data, err := marshalPolice(info)
+			if err != nil {
+				t.Fatalf("could not marshal Police: %v", err)
+			}
+			ad, err := netlink.NewAttributeDecoder(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[uint16][]byte)
+			for ad.Next() {
+				got[ad.Type()] = ad.Bytes()
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Fatalf("encoding missmatch (-want +got):\n%s", diff)
+			}
+
+			val := Police{}
+			if err := unmarshalPolice(data, &val); err != nil {
+				t.Fatalf("could not unmarshal Police: %v", err)
+			}
+			if diff := cmp.Diff(*info, val); diff != "" {
+				t.Fatalf("Police missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPoliceValidate(t *testing.T) {
+	restore, err := core.SetClock(0x3e8, 0x40, 0xf4240)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restore()
+
+	tests := map[string]struct {
+		rate  uint64
+		burst uint32
+		mtu   uint32
+		err   error
+	}{
+		"burst above mtu":       {rate: 125000, burst: 10240, mtu: 1514},
+		"burst equals mtu":      {rate: 125000, burst: 1514, mtu: 1514},
+		"burst below mtu":       {rate: 125000, burst: 1500, mtu: 1514, err: ErrInvalidArg},
+		"burst below rate cell": {rate: 125000, burst: 1500, err: ErrInvalidArg},
+		"no rate":               {burst: 10240, err: ErrInvalidArg},
+		"rate64":                {rate: 1 << 32, burst: 10240, err: ErrNotImplemented},
+	}
+	for name, testcase := range tests {
+		testcase := testcase
+		t.Run(name, func(t *testing.T) {
+			_, err := NewPolice(testcase.rate, testcase.burst, testcase.mtu, PolicyShot)
+			if !errors.Is(err, testcase.err) {
+				t.Fatalf("expected error %v but got %v", testcase.err, err)
+			}
+		})
+	}
+
+	invalid := map[string]struct {
+		val *Police
+		err error
+	}{
+		"nil":               {err: ErrNoArg},
+		"without tbf":       {val: &Police{AvRate: uint32Ptr(1337)}, err: ErrNoArg},
+		"without rate":      {val: &Police{Tbf: &Policy{Burst: 0x9c40}}},
+		"peak without rate": {val: &Police{Tbf: &Policy{PeakRate: RateSpec{Rate: 0x1e848}}}, err: ErrInvalidArg},
+	}
+	for name, testcase := range invalid {
+		testcase := testcase
+		t.Run(name, func(t *testing.T) {
+			if err := testcase.val.Validate(); !errors.Is(err, testcase.err) {
+				t.Fatalf("expected error %v but got %v", testcase.err, err)
+			}
+		})
+	}
+}
@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaSampleUnspec = iota
@@ -18,20 +14,20 @@ const (
 
 // Sample contains attribute of the Sample discipline
 type Sample struct {
-	Parms       *SampleParms
-	Tm          *Tcft
-	Rate        *uint32
-	TruncSize   *uint32
-	SampleGroup *uint32
+	Parms       *SampleParms `json:"parms,omitempty"`
+	Tm          *Tcft        `json:"tm,omitempty"`
+	Rate        *uint32      `json:"rate,omitempty"`
+	TruncSize   *uint32      `json:"trunc_size,omitempty"`
+	SampleGroup *uint32      `json:"sample_group,omitempty"`
 }
 
 // SampleParms from include/uapi/linux/tc_act/tc_sample.h
 type SampleParms struct {
-	Index   uint32
-	Capab   uint32
-	Action  uint32
-	RefCnt  uint32
-	BindCnt uint32
+	Index   uint32 `json:"index,omitempty"`
+	Capab   uint32 `json:"capab,omitempty"`
+	Action  uint32 `json:"action,omitempty"`
+	RefCnt  uint32 `json:"ref_cnt,omitempty"`
+	BindCnt uint32 `json:"bind_cnt,omitempty"`
 }
 
 // marshalSample returns the binary encoding of Sample
@@ -66,7 +62,7 @@ func marshalSample(info *Sample) ([]byte, error) {
 
 // unmarshalSample parses the Sample-encoded data and stores the result in the value pointed to by info.
 func unmarshalSample(data []byte, info *Sample) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -92,7 +88,7 @@ func unmarshalSample(data []byte, info *Sample) error {
 		case tcaSamplePad:
 			// padding does not contain data, we just skip it
 		default:
-			return fmt.Errorf("unmarshalSample()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("action sample", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
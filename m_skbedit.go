@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaSkbEditUnspec = iota
@@ -22,29 +18,29 @@ const (
 
 // SkbEdit  contains attribute of the SkbEdit discipline
 type SkbEdit struct {
-	Tm              *Tcft
-	Parms           *SkbEditParms
-	Priority        *uint32
-	QueueMapping    *uint16
-	Mark            *uint32
-	Ptype           *uint16
-	Mask            *uint32
-	Flags           *uint64
-	QueueMappingMax *uint16
+	Tm              *Tcft         `json:"tm,omitempty"`
+	Parms           *SkbEditParms `json:"parms,omitempty"`
+	Priority        *uint32       `json:"priority,omitempty"`
+	QueueMapping    *uint16       `json:"queue_mapping,omitempty"`
+	Mark            *uint32       `json:"mark,omitempty"`
+	Ptype           *uint16       `json:"ptype,omitempty"`
+	Mask            *uint32       `json:"mask,omitempty"`
+	Flags           *uint64       `json:"flags,omitempty"`
+	QueueMappingMax *uint16       `json:"queue_mapping_max,omitempty"`
 }
 
 // SkbEditParms from include/uapi/linux/tc_act/tc_skbedit.h
 type SkbEditParms struct {
-	Index   uint32
-	Capab   uint32
-	Action  uint32
-	RefCnt  uint32
-	BindCnt uint32
+	Index   uint32 `json:"index,omitempty"`
+	Capab   uint32 `json:"capab,omitempty"`
+	Action  uint32 `json:"action,omitempty"`
+	RefCnt  uint32 `json:"ref_cnt,omitempty"`
+	BindCnt uint32 `json:"bind_cnt,omitempty"`
 }
 
 // unmarshalSkbEdit parses the skbedit-encoded data and stores the result in the value pointed to by info.
 func unmarshalSkbEdit(data []byte, info *SkbEdit) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -78,7 +74,7 @@ func unmarshalSkbEdit(data []byte, info *SkbEdit) error {
 		case tcaSkbEditPad:
 			// padding does not contain data, we just skip it
 		default:
-			return fmt.Errorf("UnmarshalSkbEdit()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("action skbedit", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
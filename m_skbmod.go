@@ -2,9 +2,8 @@ package tc
 
 import (
 	"fmt"
+	"io"
 	"net"
-
-	"github.com/mdlayher/netlink"
 )
 
 const (
@@ -19,21 +18,53 @@ const (
 
 // SkbMod contains attribute of thet SkbMod discipline
 type SkbMod struct {
-	Tm    *Tcft
-	Parms *SkbModParms
-	DMac  *net.HardwareAddr
-	SMac  *net.HardwareAddr
-	EType *uint16
+	Tm    *Tcft             `json:"tm,omitempty"`
+	Parms *SkbModParms      `json:"parms,omitempty"`
+	DMac  *net.HardwareAddr `json:"d_mac,omitempty"`
+	SMac  *net.HardwareAddr `json:"s_mac,omitempty"`
+	EType *uint16           `json:"e_type,omitempty"`
 }
 
 // SkbModParms from include/uapi/linux/tc_act/tc_skbmod.h
 type SkbModParms struct {
-	Index   uint32
-	Capab   uint32
-	Action  uint32
-	RefCnt  uint32
-	BindCnt uint32
-	Flags   uint64
+	Index   uint32 `json:"index,omitempty"`
+	Capab   uint32 `json:"capab,omitempty"`
+	Action  uint32 `json:"action,omitempty"`
+	RefCnt  uint32 `json:"ref_cnt,omitempty"`
+	BindCnt uint32 `json:"bind_cnt,omitempty"`
+	Flags   uint64 `json:"flags,omitempty"`
+}
+
+// skbModFlagsOffset is the offset of the 64 bit flags in struct tc_skbmod
+// before alignment.
+const skbModFlagsOffset = 20
+
+// marshalSkbModParms returns struct tc_skbmod in the layout l.
+func marshalSkbModParms(info *SkbModParms, l cLayout) []byte {
+	offset := l.pad64(skbModFlagsOffset)
+	data := make([]byte, l.pad64(offset+8))
+	l.order.PutUint32(data[0:], info.Index)
+	l.order.PutUint32(data[4:], info.Capab)
+	l.order.PutUint32(data[8:], info.Action)
+	l.order.PutUint32(data[12:], info.RefCnt)
+	l.order.PutUint32(data[16:], info.BindCnt)
+	l.order.PutUint64(data[offset:], info.Flags)
+	return data
+}
+
+// unmarshalSkbModParms decodes struct tc_skbmod in the layout l.
+func unmarshalSkbModParms(data []byte, info *SkbModParms, l cLayout) error {
+	offset := l.pad64(skbModFlagsOffset)
+	if len(data) < offset+8 {
+		return fmt.Errorf("%T needs %d bytes, but got %d: %w", info, offset+8, len(data), io.ErrUnexpectedEOF)
+	}
+	info.Index = l.order.Uint32(data[0:])
+	info.Capab = l.order.Uint32(data[4:])
+	info.Action = l.order.Uint32(data[8:])
+	info.RefCnt = l.order.Uint32(data[12:])
+	info.BindCnt = l.order.Uint32(data[16:])
+	info.Flags = l.order.Uint64(data[offset:])
+	return nil
 }
 
 func marshalSkbMod(info *SkbMod) ([]byte, error) {
@@ -47,10 +78,7 @@ func marshalSkbMod(info *SkbMod) ([]byte, error) {
 	}
 
 	if info.Parms != nil {
-		data, err := marshalStruct(info.Parms)
-		if err != nil {
-			return []byte{}, err
-		}
+		data := marshalSkbModParms(info.Parms, nativeLayout)
 		options = append(options, tcOption{Interpretation: vtBytes, Type: tcaSkbModParms, Data: data})
 	}
 	if info.DMac != nil {
@@ -67,7 +95,7 @@ func marshalSkbMod(info *SkbMod) ([]byte, error) {
 }
 
 func unmarshalSkbMod(data []byte, info *SkbMod) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -76,7 +104,7 @@ func unmarshalSkbMod(data []byte, info *SkbMod) error {
 		switch ad.Type() {
 		case tcaSkbModParms:
 			parms := &SkbModParms{}
-			err = unmarshalStruct(ad.Bytes(), parms)
+			err = unmarshalSkbModParms(ad.Bytes(), parms, nativeLayout)
 			multiError = concatError(multiError, err)
 			info.Parms = parms
 		case tcaSkbModTm:
@@ -95,7 +123,7 @@ func unmarshalSkbMod(data []byte, info *SkbMod) error {
 		case tcaSkbModPad:
 			// padding does not contain data, we just skip it
 		default:
-			return fmt.Errorf("unmarshalSkbMod()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("action skbmod", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
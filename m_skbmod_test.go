@@ -2,6 +2,7 @@ package tc
 
 import (
 	"errors"
+	"io"
 	"net"
 	"testing"
 
@@ -57,3 +58,45 @@ func TestSkbMod(t *testing.T) {
 		}
 	})
 }
+
+func TestSkbModParmsLayout(t *testing.T) {
+	parms := SkbModParms{Index: 1, Capab: 2, Action: 3, RefCnt: 4, BindCnt: 5, Flags: 0x0102030405060708}
+	le := []byte{
+		0x01, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00,
+		0x03, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00,
+		0x05, 0x00, 0x00, 0x00,
+	}
+	tests := map[string][]byte{
+		"amd64": append(append([]byte{}, le...), 0x00, 0x00, 0x00, 0x00,
+			0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01),
+		"386": append(append([]byte{}, le...),
+			0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01),
+		"arm": append(append([]byte{}, le...), 0x00, 0x00, 0x00, 0x00,
+			0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01),
+		"mips64": {
+			0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02,
+			0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00, 0x04,
+			0x00, 0x00, 0x00, 0x05, 0x00, 0x00, 0x00, 0x00,
+			0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+		},
+	}
+	for arch, want := range tests {
+		t.Run(arch, func(t *testing.T) {
+			l := testLayouts[arch]
+			data := marshalSkbModParms(&parms, l)
+			if diff := cmp.Diff(want, data); diff != "" {
+				t.Fatalf("layout missmatch (-want +got):\n%s", diff)
+			}
+			var got SkbModParms
+			if err := unmarshalSkbModParms(data, &got, l); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(parms, got); diff != "" {
+				t.Fatalf("SkbModParms missmatch (-want +got):\n%s", diff)
+			}
+			if err := unmarshalSkbModParms(data[:len(data)-1], &got, l); !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Fatalf("expected io.ErrUnexpectedEOF but got %v", err)
+			}
+		})
+	}
+}
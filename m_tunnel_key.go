@@ -3,8 +3,6 @@ package tc
 import (
 	"fmt"
 	"net"
-
-	"github.com/mdlayher/netlink"
 )
 
 const (
@@ -27,26 +25,26 @@ const (
 
 // TunnelKey contains attribute of the TunnelKey discipline
 type TunnelKey struct {
-	Parms         *TunnelParms
-	Tm            *Tcft
-	KeyEncSrc     *net.IP
-	KeyEncDst     *net.IP
-	KeyEncKeyID   *uint32
-	KeyEncDstPort *uint16
-	KeyNoCSUM     *uint8
-	KeyEncTOS     *uint8
-	KeyEncTTL     *uint8
-	KeyNoFrag     *bool
+	Parms         *TunnelParms `json:"parms,omitempty"`
+	Tm            *Tcft        `json:"tm,omitempty"`
+	KeyEncSrc     *net.IP      `json:"key_enc_src,omitempty"`
+	KeyEncDst     *net.IP      `json:"key_enc_dst,omitempty"`
+	KeyEncKeyID   *uint32      `json:"key_enc_key_id,omitempty"`
+	KeyEncDstPort *uint16      `json:"key_enc_dst_port,omitempty"`
+	KeyNoCSUM     *uint8       `json:"key_no_csum,omitempty"`
+	KeyEncTOS     *uint8       `json:"key_enc_tos,omitempty"`
+	KeyEncTTL     *uint8       `json:"key_enc_ttl,omitempty"`
+	KeyNoFrag     *bool        `json:"key_no_frag,omitempty"`
 }
 
 // TunnelParms from include/uapi/linux/tc_act/tc_tunnel_key.h
 type TunnelParms struct {
-	Index           uint32
-	Capab           uint32
-	Action          uint32
-	RefCnt          uint32
-	BindCnt         uint32
-	TunnelKeyAction uint32
+	Index           uint32 `json:"index,omitempty"`
+	Capab           uint32 `json:"capab,omitempty"`
+	Action          uint32 `json:"action,omitempty"`
+	RefCnt          uint32 `json:"ref_cnt,omitempty"`
+	BindCnt         uint32 `json:"bind_cnt,omitempty"`
+	TunnelKeyAction uint32 `json:"tunnel_key_action,omitempty"`
 }
 
 // marshalTunnelKey returns the binary encoding of TunnelKey
@@ -111,7 +109,7 @@ func marshalTunnelKey(info *TunnelKey) ([]byte, error) {
 
 // unmarshalTunnelKey parses the TunnelKey-encoded data and stores the result in the value pointed to by info.
 func unmarshalTunnelKey(data []byte, info *TunnelKey) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -143,10 +141,10 @@ func unmarshalTunnelKey(data []byte, info *TunnelKey) error {
 			multiError = concatError(multiError, err)
 			info.KeyEncDst = &tmp
 		case tcaTunnelKeyEncKeyID:
-			tmp := endianSwapUint32(ad.Uint32())
+			tmp := ntohl(ad.Uint32())
 			info.KeyEncKeyID = &tmp
 		case tcaTunnelKeyEncDstPort:
-			tmp := endianSwapUint16(ad.Uint16())
+			tmp := ntohs(ad.Uint16())
 			info.KeyEncDstPort = &tmp
 		case tcaTunnelKeyNoCSUM:
 			tmp := ad.Uint8()
@@ -163,7 +161,7 @@ func unmarshalTunnelKey(data []byte, info *TunnelKey) error {
 			tmp := ad.Flag()
 			info.KeyNoFrag = &tmp
 		default:
-			return fmt.Errorf("unmarshalTunnelKey()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("action tunnel_key", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
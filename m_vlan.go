@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaVLanUnspec = iota
@@ -16,23 +12,24 @@ const (
 	tcaVLanPushVLanPriority
 )
 
-// VLan contains attribute of the VLan discipline
+// VLan contains attribute of the VLan discipline. PushProtocol is the
+// ethertype in host byte order, like 0x8100 for 802.1Q.
 type VLan struct {
-	Parms        *VLanParms
-	Tm           *Tcft
-	PushID       *uint16
-	PushProtocol *uint16
-	PushPriority *uint32
+	Parms        *VLanParms `json:"parms,omitempty"`
+	Tm           *Tcft      `json:"tm,omitempty"`
+	PushID       *uint16    `json:"push_id,omitempty"`
+	PushProtocol *uint16    `json:"push_protocol,omitempty"`
+	PushPriority *uint32    `json:"push_priority,omitempty"`
 }
 
 // VLanParms from include/uapi/linux/tc_act/tc_vlan.h
 type VLanParms struct {
-	Index      uint32
-	Capab      uint32
-	Action     uint32
-	RefCnt     uint32
-	BindCnt    uint32
-	VLanAction uint32
+	Index      uint32 `json:"index,omitempty"`
+	Capab      uint32 `json:"capab,omitempty"`
+	Action     uint32 `json:"action,omitempty"`
+	RefCnt     uint32 `json:"ref_cnt,omitempty"`
+	BindCnt    uint32 `json:"bind_cnt,omitempty"`
+	VLanAction uint32 `json:"vlan_action,omitempty"`
 }
 
 // marshalVLan returns the binary encoding of Vlan
@@ -57,17 +54,21 @@ func marshalVlan(info *VLan) ([]byte, error) {
 		options = append(options, tcOption{Interpretation: vtUint16, Type: tcaVLanPushVLanID, Data: *info.PushID})
 	}
 	if info.PushProtocol != nil {
-		options = append(options, tcOption{Interpretation: vtUint16, Type: tcaVLanPushVLanProtocol, Data: *info.PushProtocol})
+		options = append(options, tcOption{Interpretation: vtUint16Be, Type: tcaVLanPushVLanProtocol, Data: *info.PushProtocol})
 	}
 	if info.PushPriority != nil {
-		options = append(options, tcOption{Interpretation: vtUint32, Type: tcaVLanPushVLanPriority, Data: *info.PushPriority})
+		// The kernel expects the 3 bit priority as u8.
+		if *info.PushPriority > 7 {
+			return []byte{}, fmt.Errorf("VLan: PushPriority %d: %w", *info.PushPriority, ErrInvalidArg)
+		}
+		options = append(options, tcOption{Interpretation: vtUint8, Type: tcaVLanPushVLanPriority, Data: uint8(*info.PushPriority)})
 	}
 	return marshalAttributes(options)
 }
 
 // unmarshalVLan parses the VLan-encoded data and stores the result in the value pointed to by info.
 func unmarshalVLan(data []byte, info *VLan) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -88,15 +89,15 @@ func unmarshalVLan(data []byte, info *VLan) error {
 			tmp := ad.Uint16()
 			info.PushID = &tmp
 		case tcaVLanPushVLanProtocol:
-			tmp := ad.Uint16()
+			tmp := ntohs(ad.Uint16())
 			info.PushProtocol = &tmp
 		case tcaVLanPushVLanPriority:
-			tmp := ad.Uint32()
+			tmp := uint32(ad.Uint8())
 			info.PushPriority = &tmp
 		case tcaVLanPad:
 			// padding does not contain data, we just skip it
 		default:
-			return fmt.Errorf("unmarshalVLan()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("action vlan", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
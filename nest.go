@@ -25,6 +25,8 @@ const (
 	vtUint16Be
 	vtUint32Be
 	vtInt16Be
+	// vtNested is a container of attributes and is sent with NLA_F_NESTED.
+	vtNested
 )
 
 type tcOption struct {
@@ -36,51 +38,137 @@ type tcOption struct {
 // NLA_F_NESTED from include/uapi/linux/netlink.h
 const nlaFNnested = (1 << 15)
 
+const (
+	// nlaHeaderLen is the size of struct nlattr.
+	nlaHeaderLen = 4
+	// nlaMaxLen is the maximum size of an attribute including its header.
+	nlaMaxLen = 0xffff
+)
+
+// nlaAlign returns n aligned to the alignment of netlink attributes.
+func nlaAlign(n int) int {
+	return (n + rtaAlignTo - 1) & ^(rtaAlignTo - 1)
+}
+
+// marshalAttributes encodes options in the same way as
+// netlink.AttributeEncoder. The size of all attributes is determined first,
+// so that they are written into a single buffer.
 func marshalAttributes(options []tcOption) ([]byte, error) {
 	if len(options) == 0 {
 		return []byte{}, nil
 	}
 	var multiError error
-	ad := netlink.NewAttributeEncoder()
-
+	var size int
 	for _, option := range options {
-		switch option.Interpretation {
-		case vtUint8:
-			ad.Uint8(option.Type, (option.Data).(uint8))
-		case vtUint16:
-			ad.Uint16(option.Type, (option.Data).(uint16))
-		case vtUint32:
-			ad.Uint32(option.Type, (option.Data).(uint32))
-		case vtUint64:
-			ad.Uint64(option.Type, (option.Data).(uint64))
-		case vtString:
-			ad.String(option.Type, (option.Data).(string))
-		case vtBytes:
-			ad.Bytes(option.Type, (option.Data).([]byte))
-		case vtFlag:
-			ad.Flag(option.Type, true)
-		case vtInt8:
-			ad.Int8(option.Type, (option.Data).(int8))
-		case vtInt16:
-			ad.Int16(option.Type, (option.Data).(int16))
-		case vtInt32:
-			ad.Int32(option.Type, (option.Data).(int32))
-		case vtInt64:
-			ad.Int64(option.Type, (option.Data).(int64))
-		case vtUint16Be:
-			ad.Uint16(option.Type, endianSwapUint16((option.Data).(uint16)))
-		case vtUint32Be:
-			ad.Uint32(option.Type, endianSwapUint32((option.Data).(uint32)))
-		case vtInt16Be:
-			ad.Uint16(option.Type, endianSwapUint16(uint16((option.Data).(int16))))
-		default:
-			multiError = fmt.Errorf("unknown interpretation (%d)", option.Interpretation)
+		n, err := optionLen(option)
+		if err != nil {
+			multiError = err
+			continue
 		}
+		size += nlaAlign(nlaHeaderLen + n)
 	}
 	if multiError != nil {
 		return []byte{}, multiError
 	}
-	return ad.Encode()
+
+	data := make([]byte, size)
+	var offset int
+	for _, option := range options {
+		offset += putOption(data[offset:], option)
+	}
+	return data, nil
+}
+
+// optionLen returns the size of the payload of option.
+func optionLen(option tcOption) (int, error) {
+	var n int
+	switch option.Interpretation {
+	case vtFlag:
+		n = 0
+	case vtUint8, vtInt8:
+		n = 1
+	case vtUint16, vtInt16, vtUint16Be, vtInt16Be:
+		n = 2
+	case vtUint32, vtInt32, vtUint32Be:
+		n = 4
+	case vtUint64, vtInt64:
+		n = 8
+	case vtString:
+		// strings are terminated by NUL
+		n = len((option.Data).(string)) + 1
+	case vtBytes, vtNested:
+		n = len((option.Data).([]byte))
+	default:
+		return 0, fmt.Errorf("unknown interpretation (%d)", option.Interpretation)
+	}
+	if nlaHeaderLen+n > nlaMaxLen {
+		return 0, &MessageSizeError{Attribute: option.Type, Size: nlaHeaderLen + n, Limit: nlaMaxLen}
+	}
+	return n, nil
+}
+
+// putOption writes the attribute option to b, which is large enough to hold
+// it, and returns the number of written bytes including the padding.
+func putOption(b []byte, option tcOption) int {
+	typ := option.Type
+	payload := b[nlaHeaderLen:]
+	var n int
+	switch option.Interpretation {
+	case vtUint8:
+		payload[0] = (option.Data).(uint8)
+		n = 1
+	case vtInt8:
+		payload[0] = uint8((option.Data).(int8))
+		n = 1
+	case vtUint16:
+		nativeEndian.PutUint16(payload, (option.Data).(uint16))
+		n = 2
+	case vtInt16:
+		nativeEndian.PutUint16(payload, uint16((option.Data).(int16)))
+		n = 2
+	case vtUint16Be:
+		binary.BigEndian.PutUint16(payload, (option.Data).(uint16))
+		n = 2
+	case vtInt16Be:
+		binary.BigEndian.PutUint16(payload, uint16((option.Data).(int16)))
+		n = 2
+	case vtUint32:
+		nativeEndian.PutUint32(payload, (option.Data).(uint32))
+		n = 4
+	case vtInt32:
+		nativeEndian.PutUint32(payload, uint32((option.Data).(int32)))
+		n = 4
+	case vtUint32Be:
+		binary.BigEndian.PutUint32(payload, (option.Data).(uint32))
+		n = 4
+	case vtUint64:
+		nativeEndian.PutUint64(payload, (option.Data).(uint64))
+		n = 8
+	case vtInt64:
+		nativeEndian.PutUint64(payload, uint64((option.Data).(int64)))
+		n = 8
+	case vtString:
+		n = copy(payload, (option.Data).(string)) + 1
+	case vtBytes:
+		n = copy(payload, (option.Data).([]byte))
+	case vtNested:
+		n = copy(payload, (option.Data).([]byte))
+		typ |= nlaFNnested
+	}
+	nativeEndian.PutUint16(b[0:], uint16(nlaHeaderLen+n))
+	nativeEndian.PutUint16(b[2:], typ)
+	return nlaAlign(nlaHeaderLen + n)
+}
+
+// newDecoder returns a decoder for the attributes in data, that reads
+// integers in the byte order of the kernel.
+func newDecoder(data []byte) (*netlink.AttributeDecoder, error) {
+	ad, err := netlink.NewAttributeDecoder(data)
+	if err != nil {
+		return nil, err
+	}
+	ad.ByteOrder = nativeLayout.order
+	return ad, nil
 }
 
 func unmarshalNetlinkAttribute(data []byte, val interface{}) error {
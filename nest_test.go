@@ -2,8 +2,15 @@ package tc
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"testing"
+
+	"github.com/florianl/go-tc/internal/unix"
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nlenc"
 )
 
 func TestMarshalAttributes(t *testing.T) {
@@ -28,7 +35,8 @@ func TestMarshalAttributes(t *testing.T) {
 		"uint16Be": {interpretation: vtUint16Be, attributeType: 12, data: uint16(124), result: []byte{0x6, 0x0, 0xC, 0x0, 0x0, 0x7c, 0x0, 0x0}},
 		"uint32Be": {interpretation: vtUint32Be, attributeType: 13, data: uint32(125), result: []byte{0x8, 0x0, 0xD, 0x0, 0x0, 0x0, 0x0, 0x7d}},
 		"int16Be":  {interpretation: vtInt16Be, attributeType: 14, data: int16(-73), result: []byte{0x6, 0x0, 0xE, 0x0, 0xFF, 0xB7, 0x0, 0x0}},
-		"unknown":  {interpretation: vtInt16Be + 1, attributeType: 42, data: nil, err: fmt.Errorf("unknown interpretation (14)")},
+		"nested":   {interpretation: vtNested, attributeType: 15, data: []byte{0x5, 0x0, 0x1, 0x0, 0x2a, 0x0, 0x0, 0x0}, result: []byte{0xC, 0x0, 0xF, 0x80, 0x5, 0x0, 0x1, 0x0, 0x2a, 0x0, 0x0, 0x0}},
+		"unknown":  {interpretation: vtNested + 1, attributeType: 42, data: nil, err: fmt.Errorf("unknown interpretation (15)")},
 	}
 
 	for name, tc := range tests {
@@ -58,6 +66,74 @@ func TestMarshalAttributes(t *testing.T) {
 	}
 }
 
+func TestMarshalAttributesEncoder(t *testing.T) {
+	options := []tcOption{
+		{Interpretation: vtUint8, Type: 1, Data: uint8(1)},
+		{Interpretation: vtString, Type: 2, Data: "fq_codel"},
+		{Interpretation: vtUint16Be, Type: 3, Data: uint16(0x800)},
+		{Interpretation: vtFlag, Type: 4},
+		{Interpretation: vtBytes, Type: 5, Data: []byte{0x1, 0x2, 0x3}},
+		{Interpretation: vtInt64, Type: 6, Data: int64(-6)},
+		{Interpretation: vtNested, Type: 7, Data: []byte{0x5, 0x0, 0x1, 0x0, 0x2a, 0x0, 0x0, 0x0}},
+		{Interpretation: vtUint32Be, Type: 8, Data: uint32(0xc0a80001)},
+	}
+
+	ae := netlink.NewAttributeEncoder()
+	for _, option := range options {
+		switch option.Interpretation {
+		case vtUint8:
+			ae.Uint8(option.Type, option.Data.(uint8))
+		case vtString:
+			ae.String(option.Type, option.Data.(string))
+		case vtUint16Be:
+			ae.Uint16(option.Type, ntohs(option.Data.(uint16)))
+		case vtFlag:
+			ae.Flag(option.Type, true)
+		case vtBytes:
+			ae.Bytes(option.Type, option.Data.([]byte))
+		case vtInt64:
+			ae.Int64(option.Type, option.Data.(int64))
+		case vtNested:
+			ae.Bytes(option.Type|nlaFNnested, option.Data.([]byte))
+		case vtUint32Be:
+			ae.Uint32(option.Type, ntohl(option.Data.(uint32)))
+		}
+	}
+	want, err := ae.Encode()
+	if err != nil {
+		t.Fatalf("could not encode attributes: %v", err)
+	}
+
+	got, err := marshalAttributes(options)
+	if err != nil {
+		t.Fatalf("could not marshal attributes: %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("attributes missmatch (-want +got):\n%s", diff)
+	}
+
+	tooLarge := []tcOption{{Interpretation: vtBytes, Type: 1, Data: make([]byte, nlaMaxLen)}}
+	if _, err := marshalAttributes(tooLarge); !errors.Is(err, ErrInvalidArg) {
+		t.Fatalf("expected ErrInvalidArg but got %v", err)
+	}
+}
+
+func TestMarshalAttributesAllocs(t *testing.T) {
+	options := make([]tcOption, 64)
+	for i := range options {
+		options[i] = tcOption{Interpretation: vtUint32, Type: uint16(i + 1), Data: uint32(i)}
+	}
+	// marshalAttributes writes all options into a single buffer.
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := marshalAttributes(options); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs > 1 {
+		t.Fatalf("expected at most 1 allocation but got %.1f", allocs)
+	}
+}
+
 func TestUnmarshalAttributes(t *testing.T) {
 	var valInt8 int8
 	if err := unmarshalNetlinkAttribute([]byte{0xF8}, &valInt8); err != nil {
@@ -89,3 +165,287 @@ func TestUnmarshalAttributes(t *testing.T) {
 		t.Fatalf("expexted: -8\tgot: %d", valInt8)
 	}
 }
+
+func TestNewDecoder(t *testing.T) {
+	for arch, layout := range map[string]cLayout{"amd64": testLayouts["amd64"], "mips64": testLayouts["mips64"]} {
+		t.Run(arch, func(t *testing.T) {
+			defer func(orig cLayout) { nativeLayout = orig }(nativeLayout)
+			nativeLayout = layout
+
+			// Only the payloads of the attributes depend on the byte order
+			// of the kernel, as netlink decodes the headers itself.
+			ecn := make([]byte, 4)
+			layout.order.PutUint32(ecn, 0x01020304)
+			rate := make([]byte, 8)
+			layout.order.PutUint64(rate, 0x0102030405060708)
+			attrs, err := netlink.MarshalAttributes([]netlink.Attribute{
+				{Type: tcaNetemEcn, Data: ecn},
+				{Type: tcaNetemRate64, Data: rate},
+			})
+			if err != nil {
+				t.Fatalf("could not encode attributes: %v", err)
+			}
+			// The zero NetemQopt is the same in both byte orders.
+			data := append(make([]byte, binary.Size(NetemQopt{})), attrs...)
+
+			info := &Netem{}
+			if err := unmarshalNetem(data, info); err != nil {
+				t.Fatalf("could not decode netem: %v", err)
+			}
+			want := &Netem{Ecn: uint32Ptr(0x01020304), Rate64: uint64Ptr(0x0102030405060708)}
+			if diff := cmp.Diff(want, info); diff != "" {
+				t.Fatalf("Netem missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// benchmarkFilter returns a u32 filter with 4 keys, a policer and 2 actions.
+// networkOrder returns the attributes of data with their payload.
+func networkOrder(t *testing.T, data []byte) map[uint16][]byte {
+	t.Helper()
+	attrs, err := netlink.UnmarshalAttributes(data)
+	if err != nil {
+		t.Fatalf("could not decode attributes: %v", err)
+	}
+	payloads := make(map[uint16][]byte, len(attrs))
+	for _, attr := range attrs {
+		payloads[attr.Type] = attr.Data
+	}
+	return payloads
+}
+
+func TestNetworkByteOrder(t *testing.T) {
+	mplsUC := uint16(0x8847)
+	// The payloads in network byte order are taken from captures of
+	// iproute2 on x86. Headers and fields in host byte order are encoded in
+	// native byte order, so that the fixtures hold on big endian hosts too.
+	tests := map[string]struct {
+		attrs []netlink.Attribute
+		want  interface{}
+	}{
+		"flower dst_port": {
+			// tc filter add dev eth0 ingress protocol ip flower ip_proto tcp dst_port 80
+			attrs: []netlink.Attribute{
+				{Type: tcaFlowerKeyEthType, Data: []byte{0x08, 0x00}},
+				{Type: tcaFlowerKeyIPProto, Data: []byte{0x06}},
+				{Type: tcaFlowerKeyTCPDst, Data: []byte{0x00, 0x50}},
+			},
+			want: &Flower{KeyEthType: uint16Ptr(0x0800), KeyIPProto: uint8Ptr(6), KeyTCPDst: uint16Ptr(80)},
+		},
+		"flower vxlan": {
+			// tc filter add dev vxlan0 ingress protocol 802.1q flower vlan_id 100
+			//   vlan_ethtype ipv4 enc_key_id 42 enc_dst_port 4789
+			attrs: []netlink.Attribute{
+				{Type: tcaFlowerKeyEthType, Data: []byte{0x81, 0x00}},
+				{Type: tcaFlowerKeyVlanID, Data: nlenc.Uint16Bytes(100)},
+				{Type: tcaFlowerKeyVlanEthType, Data: []byte{0x08, 0x00}},
+				{Type: tcaFlowerKeyEncKeyID, Data: []byte{0x00, 0x00, 0x00, 0x2a}},
+				{Type: tcaFlowerKeyEncUDPDstPort, Data: []byte{0x12, 0xb5}},
+			},
+			want: &Flower{KeyEthType: uint16Ptr(0x8100), KeyVlanID: uint16Ptr(100), KeyVlanEthType: uint16Ptr(0x0800),
+				KeyEncKeyID: uint32Ptr(42), KeyEncUDPDstPort: uint16Ptr(4789)},
+		},
+		"tunnel_key": {
+			// tc filter add ... action tunnel_key set id 42 dst_port 4789
+			attrs: []netlink.Attribute{
+				{Type: tcaTunnelKeyEncKeyID, Data: []byte{0x00, 0x00, 0x00, 0x2a}},
+				{Type: tcaTunnelKeyEncDstPort, Data: []byte{0x12, 0xb5}},
+			},
+			want: &TunnelKey{KeyEncKeyID: uint32Ptr(42), KeyEncDstPort: uint16Ptr(4789)},
+		},
+		"ct": {
+			// tc filter add ... action ct nat src addr 10.0.0.1 port 1000-2000
+			attrs: []netlink.Attribute{
+				{Type: tcaCtNatPortMin, Data: []byte{0x03, 0xe8}},
+				{Type: tcaCtNatPortMax, Data: []byte{0x07, 0xd0}},
+			},
+			want: &Ct{NatPortMin: uint16Ptr(1000), NatPortMax: uint16Ptr(2000)},
+		},
+		"mpls": {
+			// tc filter add ... action mpls push protocol mpls_uc label 20
+			attrs: []netlink.Attribute{
+				{Type: tcaMPLSProto, Data: []byte{0x88, 0x47}},
+				{Type: tcaMPLSLabel, Data: nlenc.Uint32Bytes(20)},
+			},
+			want: &MPLS{Proto: int16Ptr(int16(mplsUC)), Label: uint32Ptr(20)},
+		},
+		"vlan": {
+			// tc filter add ... action vlan push id 100 protocol 802.1ad priority 5
+			attrs: []netlink.Attribute{
+				{Type: tcaVLanPushVLanID, Data: nlenc.Uint16Bytes(100)},
+				{Type: tcaVLanPushVLanProtocol, Data: []byte{0x88, 0xa8}},
+				{Type: tcaVLanPushVLanPriority, Data: []byte{0x05}},
+			},
+			want: &VLan{PushID: uint16Ptr(100), PushProtocol: uint16Ptr(0x88a8), PushPriority: uint32Ptr(5)},
+		},
+		"nat": {
+			// tc filter add ... action nat ingress 10.0.0.1 192.168.0.1
+			attrs: []netlink.Attribute{
+				{Type: tcaNatParms, Data: append(make([]byte, 20),
+					0x0a, 0x00, 0x00, 0x01, // old_addr
+					0xc0, 0xa8, 0x00, 0x01, // new_addr
+					0xff, 0xff, 0xff, 0xff, // mask
+					0x00, 0x00, 0x00, 0x00, // flags
+				)},
+			},
+			want: &Nat{Parms: &NatParms{OldAddr: 0x0a000001, NewAddr: 0xc0a80001, Mask: 0xffffffff}},
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, err := netlink.MarshalAttributes(testcase.attrs)
+			if err != nil {
+				t.Fatalf("could not encode fixture: %v", err)
+			}
+			var got interface{}
+			var encoded []byte
+			switch want := testcase.want.(type) {
+			case *Flower:
+				info := &Flower{}
+				err = unmarshalFlower(data, info)
+				got = info
+				if err == nil {
+					encoded, err = marshalFlower(want)
+				}
+			case *TunnelKey:
+				info := &TunnelKey{}
+				err = unmarshalTunnelKey(data, info)
+				got = info
+				if err == nil {
+					encoded, err = marshalTunnelKey(want)
+				}
+			case *Ct:
+				info := &Ct{}
+				err = unmarshalCt(data, info)
+				got = info
+				if err == nil {
+					encoded, err = marshalCt(want)
+				}
+			case *MPLS:
+				info := &MPLS{}
+				err = unmarshalMPLS(data, info)
+				got = info
+				if err == nil {
+					encoded, err = marshalMPLS(want)
+				}
+			case *VLan:
+				info := &VLan{}
+				err = unmarshalVLan(data, info)
+				got = info
+				if err == nil {
+					encoded, err = marshalVlan(want)
+				}
+			case *Nat:
+				info := &Nat{}
+				err = unmarshalNat(data, info)
+				got = info
+				if err == nil {
+					encoded, err = marshalNat(want)
+				}
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(testcase.want, got); diff != "" {
+				t.Fatalf("decoding missmatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(networkOrder(t, data), networkOrder(t, encoded)); diff != "" {
+				t.Fatalf("encoding missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func benchmarkFilter() *Object {
+	keys := make([]U32Key, 4)
+	for i := range keys {
+		keys[i] = U32Key{Mask: 0xffffff00, Val: 0xc0a80000 | uint32(i)<<8, Off: 12}
+	}
+	actions := []*Action{
+		{Kind: "gact", Gact: &Gact{Parms: &GactParms{Action: 2}}},
+		{Kind: "mirred", Mirred: &Mirred{Parms: &MirredParam{Action: 4, Eaction: 1, IfIndex: 42}}},
+	}
+	return &Object{
+		Msg: Msg{Family: 0, Ifindex: 42, Handle: 0x800, Parent: 0xffff0000, Info: 0x10300},
+		Attribute: Attribute{
+			Kind: "u32",
+			U32: &U32{
+				ClassID: uint32Ptr(0x10001),
+				Sel:     &U32Sel{Flags: U32Terminal, Keys: keys},
+				Police: &Police{
+					Tbf:  &Policy{Action: 2, Burst: 0x2710, Mtu: 0xffff, Rate: RateSpec{CellLog: 3, Rate: 125000}},
+					Rate: &RateSpec{CellLog: 3, Rate: 125000},
+				},
+				Actions: &actions,
+			},
+		},
+	}
+}
+
+func BenchmarkMarshalAttributes(b *testing.B) {
+	kinds := []tcOption{
+		{Interpretation: vtString, Data: "fq_codel"},
+		{Interpretation: vtUint32, Data: uint32(10240)},
+		{Interpretation: vtUint64, Data: uint64(1 << 40)},
+		{Interpretation: vtUint16Be, Data: uint16(0x800)},
+		{Interpretation: vtBytes, Data: make([]byte, 36)},
+		{Interpretation: vtFlag},
+		{Interpretation: vtInt32, Data: int32(-1)},
+		{Interpretation: vtUint8, Data: uint8(1)},
+	}
+	for _, n := range []int{1, 8, 64} {
+		options := make([]tcOption, n)
+		for i := range options {
+			options[i] = kinds[i%len(kinds)]
+			options[i].Type = uint16(i + 1)
+		}
+		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := marshalAttributes(options); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkUnmarshalFilter(b *testing.B) {
+	info := benchmarkFilter()
+	options, err := validateFilterObject(unix.RTM_NEWTFILTER, info)
+	if err != nil {
+		b.Fatal(err)
+	}
+	msg, err := marshalMessage(unix.RTM_NEWTFILTER, netlink.Create, &info.Msg, options)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var result Object
+		var attrs []byte
+		if result.Msg, attrs, err = unmarshalTcmsg(msg.Data); err != nil {
+			b.Fatal(err)
+		}
+		if err := extractTcmsgAttributes(unix.RTM_NEWTFILTER, attrs, &result.Attribute); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshalFilter(b *testing.B) {
+	info := benchmarkFilter()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		options, err := validateFilterObject(unix.RTM_NEWTFILTER, info)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := marshalMessage(unix.RTM_NEWTFILTER, netlink.Create, &info.Msg, options); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package tc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"syscall"
+	"time"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+)
+
+// NetemSpec describes a common netem setup, like a delay with jitter and a
+// loss of packets.
+type NetemSpec struct {
+	// Delay and Jitter are added to every packet.
+	Delay  time.Duration
+	Jitter time.Duration
+
+	// Loss, Duplicate, Reorder and Corrupt are probabilities in percent.
+	Loss      float64
+	Duplicate float64
+	Reorder   float64
+	Corrupt   float64
+
+	// Rate limits the rate in bytes per second. Zero disables the limit.
+	Rate uint64
+
+	// Limit is the maximum number of packets in the queue. If zero, the
+	// default of tc of 1000 packets is used.
+	Limit uint32
+}
+
+// netemDefaultLimit is the default limit of iproute2/tc/q_netem.c.
+const netemDefaultLimit = 1000
+
+// netemObject returns the root netem qdisc of ifindex described by spec.
+// Durations, that exceed the range of 32 bit ticks, are passed as 64 bit
+// attributes in the same way tc does.
+func netemObject(ifindex uint32, spec NetemSpec) (*Object, error) {
+	if spec.Delay < 0 || spec.Jitter < 0 {
+		return nil, fmt.Errorf("netem: negative delay: %w", ErrInvalidArg)
+	}
+
+	netem := &Netem{}
+	netem.Qopt.Limit = spec.Limit
+	if netem.Qopt.Limit == 0 {
+		netem.Qopt.Limit = netemDefaultLimit
+	}
+
+	var err error
+	if netem.Qopt.Latency, err = core.Duration2Ticks(spec.Delay); err != nil {
+		netem.Qopt.Latency = math.MaxUint32
+		latency := spec.Delay.Nanoseconds()
+		netem.Latency64 = &latency
+	}
+	if netem.Qopt.Jitter, err = core.Duration2Ticks(spec.Jitter); err != nil {
+		netem.Qopt.Jitter = math.MaxUint32
+		jitter := spec.Jitter.Nanoseconds()
+		netem.Jitter64 = &jitter
+	}
+
+	if err := netem.Qopt.SetLossPercent(spec.Loss); err != nil {
+		return nil, fmt.Errorf("netem: %w", err)
+	}
+	if err := netem.Qopt.SetDuplicatePercent(spec.Duplicate); err != nil {
+		return nil, fmt.Errorf("netem: %w", err)
+	}
+	if spec.Reorder != 0 {
+		netem.Reorder = &NetemReorder{}
+		if err := netem.Reorder.SetProbabilityPercent(spec.Reorder); err != nil {
+			return nil, fmt.Errorf("netem: %w", err)
+		}
+	}
+	if spec.Corrupt != 0 {
+		netem.Corrupt = &NetemCorrupt{}
+		if err := netem.Corrupt.SetProbabilityPercent(spec.Corrupt); err != nil {
+			return nil, fmt.Errorf("netem: %w", err)
+		}
+	}
+	if spec.Rate != 0 {
+		netem.Rate = &NetemRate{Rate: math.MaxUint32}
+		if spec.Rate < math.MaxUint32 {
+			netem.Rate.Rate = uint32(spec.Rate)
+		} else {
+			netem.Rate64 = uint64Ptr(spec.Rate)
+		}
+	}
+
+	return &Object{
+		Msg: Msg{
+			Family:  unix.AF_UNSPEC,
+			Ifindex: ifindex,
+			Parent:  HandleRoot,
+		},
+		Attribute: Attribute{
+			Kind:  "netem",
+			Netem: netem,
+		},
+	}, nil
+}
+
+// SetNetem replaces the root qdisc of ifindex with a netem qdisc described
+// by spec, like `tc qdisc replace dev eth0 root netem delay 100ms 10ms loss 1%`.
+func SetNetem(tcSocket *Tc, ifindex uint32, spec NetemSpec) error {
+	obj, err := netemObject(ifindex, spec)
+	if err != nil {
+		return err
+	}
+	return tcSocket.Qdisc().Replace(obj)
+}
+
+// ClearNetem removes the root netem qdisc of ifindex, that was set by
+// SetNetem, so that the kernel restores its default qdisc. If the root qdisc
+// is not netem, it is left untouched.
+func ClearNetem(tcSocket *Tc, ifindex uint32) error {
+	qdiscs, err := tcSocket.Qdisc().Get()
+	if err != nil {
+		return err
+	}
+	for _, qdisc := range qdiscs {
+		if qdisc.Ifindex != ifindex || qdisc.Parent != HandleRoot || qdisc.Kind != "netem" {
+			continue
+		}
+		err := tcSocket.Qdisc().Delete(&qdisc)
+		if errors.Is(err, syscall.ENOENT) {
+			// removed in the meantime
+			return nil
+		}
+		return err
+	}
+	return nil
+}
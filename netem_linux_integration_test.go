@@ -0,0 +1,126 @@
+//go:build integration && linux
+// +build integration,linux
+
+package tc
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jsimonetti/rtnetlink"
+	"golang.org/x/sys/unix"
+)
+
+func setupVethInterface(iface string) (*rtnetlink.Conn, error) {
+	con, err := rtnetlink.Dial(nil)
+	if err != nil {
+		return &rtnetlink.Conn{}, err
+	}
+
+	// Without a peer, the kernel chooses the name of the other end.
+	if err := con.Link.New(&rtnetlink.LinkMessage{
+		Family: unix.AF_UNSPEC,
+		Flags:  unix.IFF_UP,
+		Change: unix.IFF_UP,
+		Attributes: &rtnetlink.LinkAttributes{
+			Name: iface,
+			Info: &rtnetlink.LinkInfo{Kind: "veth"},
+		},
+	}); err != nil {
+		return con, err
+	}
+
+	return con, err
+}
+
+func TestLinuxNetem(t *testing.T) {
+	tcIface := "tcNetem"
+
+	rtnl, err := setupVethInterface(tcIface)
+	if err != nil {
+		t.Skipf("could not setup veth interface: %v", err)
+	}
+	defer rtnl.Close()
+
+	devID, err := net.InterfaceByName(tcIface)
+	if err != nil {
+		t.Fatalf("could not get interface ID: %v", err)
+	}
+	defer func(devID uint32, rtnl *rtnetlink.Conn) {
+		// Deleting one end of the pair removes the peer as well.
+		if err := rtnl.Link.Delete(devID); err != nil {
+			t.Fatalf("could not delete interface: %v", err)
+		}
+	}(uint32(devID.Index), rtnl)
+
+	tcnl, err := Open(&Config{})
+	if err != nil {
+		t.Fatalf("could not open rtnetlink socket: %v", err)
+	}
+	defer func() {
+		if err := tcnl.Close(); err != nil {
+			t.Fatalf("could not close rtnetlink socket: %v", err)
+		}
+	}()
+
+	ifindex := uint32(devID.Index)
+	rootKind := func() string {
+		qdiscs, err := tcnl.Qdisc().Get()
+		if err != nil {
+			t.Fatalf("could not get qdiscs: %v", err)
+		}
+		for _, qdisc := range qdiscs {
+			if qdisc.Ifindex == ifindex && qdisc.Parent == HandleRoot {
+				return qdisc.Kind
+			}
+		}
+		return ""
+	}
+	defaultKind := rootKind()
+
+	spec := NetemSpec{Delay: 100 * time.Millisecond, Jitter: 10 * time.Millisecond, Loss: 1}
+	if err := SetNetem(tcnl, ifindex, spec); errors.Is(err, unix.ENOENT) {
+		t.Skipf("netem is not available: %v", err)
+	} else if err != nil {
+		t.Fatalf("could not set netem: %v", err)
+	}
+	// Setting netem a second time replaces the first one.
+	spec.Delay = 10 * time.Minute
+	if err := SetNetem(tcnl, ifindex, spec); err != nil {
+		t.Fatalf("could not set netem again: %v", err)
+	}
+
+	qdiscs, err := tcnl.Qdisc().Get()
+	if err != nil {
+		t.Fatalf("could not get qdiscs: %v", err)
+	}
+	var found bool
+	for _, qdisc := range qdiscs {
+		if qdisc.Ifindex != ifindex || qdisc.Parent != HandleRoot {
+			continue
+		}
+		if qdisc.Kind != "netem" || qdisc.Netem == nil {
+			t.Fatalf("expected netem as root qdisc but got %s", qdisc.Kind)
+		}
+		if qdisc.Netem.Latency64 == nil || *qdisc.Netem.Latency64 != int64(spec.Delay) {
+			t.Fatalf("expected a latency of %v but got %v", spec.Delay, qdisc.Netem.Latency64)
+		}
+		found = true
+	}
+	if !found {
+		t.Fatalf("could not find root qdisc")
+	}
+
+	if err := ClearNetem(tcnl, ifindex); err != nil {
+		t.Fatalf("could not clear netem: %v", err)
+	}
+	if kind := rootKind(); kind != defaultKind {
+		t.Fatalf("expected default qdisc %q but got %q", defaultKind, kind)
+	}
+	// Clearing netem a second time has no effect.
+	if err := ClearNetem(tcnl, ifindex); err != nil {
+		t.Fatalf("could not clear netem again: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package tc
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNetemObject(t *testing.T) {
+	restore, err := core.SetClock(0x3e8, 0x40, 0xf4240)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restore()
+
+	percent := func(p float64) uint32 {
+		v, err := Percentage(p)
+		if err != nil {
+			t.Fatalf("could not convert %f%%: %v", p, err)
+		}
+		return v
+	}
+
+	tests := map[string]struct {
+		spec  NetemSpec
+		netem *Netem
+		err   error
+	}{
+		"defaults": {
+			spec:  NetemSpec{},
+			netem: &Netem{Qopt: NetemQopt{Limit: 1000}},
+		},
+		"delay and loss": {
+			spec: NetemSpec{Delay: 100 * time.Millisecond, Jitter: 10 * time.Millisecond, Loss: 1},
+			netem: &Netem{Qopt: NetemQopt{
+				Latency: 1562500, Jitter: 156250, Limit: 1000, Loss: percent(1),
+			}},
+		},
+		"all": {
+			spec: NetemSpec{Delay: time.Millisecond, Duplicate: 2, Reorder: 25, Corrupt: 0.1,
+				Rate: 1250000, Limit: 100},
+			netem: &Netem{
+				Qopt:    NetemQopt{Latency: 15625, Limit: 100, Duplicate: percent(2)},
+				Reorder: &NetemReorder{Probability: percent(25)},
+				Corrupt: &NetemCorrupt{Probability: percent(0.1)},
+				Rate:    &NetemRate{Rate: 1250000},
+			},
+		},
+		"64 bit": {
+			spec: NetemSpec{Delay: 5 * time.Minute, Jitter: 2 * time.Hour, Rate: 10000000000},
+			netem: &Netem{
+				Qopt:      NetemQopt{Latency: math.MaxUint32, Jitter: math.MaxUint32, Limit: 1000},
+				Latency64: int64Ptr(int64(5 * time.Minute)),
+				Jitter64:  int64Ptr(int64(2 * time.Hour)),
+				Rate:      &NetemRate{Rate: math.MaxUint32},
+				Rate64:    uint64Ptr(10000000000),
+			},
+		},
+		"negative delay": {spec: NetemSpec{Delay: -time.Second}, err: ErrInvalidArg},
+		"invalid loss":   {spec: NetemSpec{Loss: 101}, err: ErrInvalidArg},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			obj, err := netemObject(42, testcase.spec)
+			if testcase.err != nil {
+				if !errors.Is(err, testcase.err) {
+					t.Fatalf("expected error %v but got %v", testcase.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			want := &Object{
+				Msg:       Msg{Family: unix.AF_UNSPEC, Ifindex: 42, Parent: HandleRoot},
+				Attribute: Attribute{Kind: "netem", Netem: testcase.netem},
+			}
+			if diff := cmp.Diff(want, obj); diff != "" {
+				t.Fatalf("object missmatch (-want +got):\n%s", diff)
+			}
+			if err := obj.Validate(); err != nil {
+				t.Fatalf("object is not valid: %v", err)
+			}
+		})
+	}
+}
+
+func TestSetNetem(t *testing.T) {
+	tcSocket, done := testConn(t)
+	defer done()
+
+	if err := SetNetem(tcSocket, 123, NetemSpec{Delay: 10 * time.Millisecond, Loss: 0.5}); err != nil {
+		t.Fatalf("could not set netem: %v", err)
+	}
+	if err := ClearNetem(tcSocket, 123); err != nil {
+		t.Fatalf("could not clear netem: %v", err)
+	}
+	if err := SetNetem(tcSocket, 0, NetemSpec{}); !errors.Is(err, ErrInvalidDev) {
+		t.Fatalf("expected ErrInvalidDev but got %v", err)
+	}
+}
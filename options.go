@@ -0,0 +1,93 @@
+package tc
+
+import (
+	"io"
+	"time"
+)
+
+// Option modifies the Config of Open.
+type Option func(*Config)
+
+// applyOptions returns a copy of config with opts applied.
+func applyOptions(config *Config, opts []Option) Config {
+	var cfg Config
+	if config != nil {
+		cfg = *config
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// WithTimeout limits the time to wait for the response of the kernel.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Config) {
+		c.Timeout = timeout
+	}
+}
+
+// WithNetNSFd operates in the network namespace referred to by the file
+// descriptor fd.
+func WithNetNSFd(fd int) Option {
+	return func(c *Config) {
+		c.NetNS = fd
+	}
+}
+
+// WithExtendedAck enables extended acknowledgements.
+func WithExtendedAck() Option {
+	return func(c *Config) {
+		c.ExtendedAck = true
+	}
+}
+
+// WithStrictCheck enables the strict checking of dump requests.
+func WithStrictCheck() Option {
+	return func(c *Config) {
+		c.StrictCheck = true
+	}
+}
+
+// WithReadBuffer sets the size of the receive buffer of the socket in bytes.
+func WithReadBuffer(bytes int) Option {
+	return func(c *Config) {
+		c.ReadBuffer = bytes
+	}
+}
+
+// WithLazyStats defers the decoding of statistics. See Config.LazyStats.
+func WithLazyStats() Option {
+	return func(c *Config) {
+		c.LazyStats = true
+	}
+}
+
+// WithAutoReopen replaces a failed socket. See Config.AutoReopen.
+func WithAutoReopen() Option {
+	return func(c *Config) {
+		c.AutoReopen = true
+	}
+}
+
+// WithOrphanCheck protects the objects beneath a qdisc. See
+// Config.OrphanCheck.
+func WithOrphanCheck() Option {
+	return func(c *Config) {
+		c.OrphanCheck = true
+	}
+}
+
+// WithVerifyWrites reads written objects back. See Config.VerifyWrites.
+func WithVerifyWrites() Option {
+	return func(c *Config) {
+		c.VerifyWrites = true
+	}
+}
+
+// WithLogger passes every netlink message to w. See Config.Logger.
+func WithLogger(w io.Writer) Option {
+	return func(c *Config) {
+		c.Logger = w
+	}
+}
@@ -0,0 +1,148 @@
+package tc
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/mdlayher/netlink"
+)
+
+// optionConn records the configuration of the socket.
+type optionConn struct {
+	fakeConn
+	options    map[netlink.ConnOption]bool
+	readBuffer int
+	deadline   time.Time
+	err        error
+}
+
+func (c *optionConn) SetOption(o netlink.ConnOption, enable bool) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.options[o] = enable
+	return nil
+}
+
+func (c *optionConn) SetReadBuffer(bytes int) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.readBuffer = bytes
+	return nil
+}
+
+func (c *optionConn) SetReadDeadline(t time.Time) error {
+	c.deadline = t
+	return nil
+}
+
+func TestOptions(t *testing.T) {
+	var logger bytes.Buffer
+
+	tests := map[string]struct {
+		config     *Config
+		opts       []Option
+		want       Config
+		options    map[netlink.ConnOption]bool
+		readBuffer int
+	}{
+		"none": {
+			want:    Config{},
+			options: map[netlink.ConnOption]bool{},
+		},
+		"config only": {
+			config:  &Config{NetNS: 3, ExtendedAck: true},
+			want:    Config{NetNS: 3, ExtendedAck: true},
+			options: map[netlink.ConnOption]bool{netlink.ExtendedAcknowledge: true},
+		},
+		"all": {
+			opts: []Option{WithTimeout(time.Second), WithNetNSFd(4), WithExtendedAck(),
+				WithStrictCheck(), WithReadBuffer(1 << 20), WithLogger(&logger), WithLazyStats(),
+				WithAutoReopen(), WithOrphanCheck(), WithVerifyWrites()},
+			want: Config{Timeout: time.Second, NetNS: 4, ExtendedAck: true, StrictCheck: true,
+				ReadBuffer: 1 << 20, Logger: &logger, LazyStats: true, AutoReopen: true,
+				OrphanCheck: true, VerifyWrites: true},
+			options: map[netlink.ConnOption]bool{
+				netlink.ExtendedAcknowledge: true,
+				netlink.GetStrictCheck:      true,
+			},
+			readBuffer: 1 << 20,
+		},
+		"options on top of config": {
+			config:     &Config{NetNS: 3, SkipValidation: true, ReadBuffer: 4096},
+			opts:       []Option{WithNetNSFd(5), WithStrictCheck()},
+			want:       Config{NetNS: 5, SkipValidation: true, StrictCheck: true, ReadBuffer: 4096},
+			options:    map[netlink.ConnOption]bool{netlink.GetStrictCheck: true},
+			readBuffer: 4096,
+		},
+		"last option wins": {
+			opts:    []Option{WithTimeout(time.Second), WithTimeout(time.Minute)},
+			want:    Config{Timeout: time.Minute},
+			options: map[netlink.ConnOption]bool{},
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			var orig Config
+			if testcase.config != nil {
+				orig = *testcase.config
+			}
+			cfg := applyOptions(testcase.config, testcase.opts)
+			if diff := cmp.Diff(testcase.want, cfg, cmpopts.IgnoreFields(Config{}, "Logger")); diff != "" {
+				t.Fatalf("config missmatch (-want +got):\n%s", diff)
+			}
+			if cfg.Logger != testcase.want.Logger {
+				t.Fatalf("expected logger %v but got %v", testcase.want.Logger, cfg.Logger)
+			}
+			if testcase.config != nil && *testcase.config != orig {
+				t.Fatalf("options modified the given config")
+			}
+
+			con := &optionConn{options: make(map[netlink.ConnOption]bool)}
+			tc, err := newTc(con, &cfg)
+			if err != nil {
+				t.Fatalf("could not configure connection: %v", err)
+			}
+			if diff := cmp.Diff(testcase.options, con.options); diff != "" {
+				t.Fatalf("socket options missmatch (-want +got):\n%s", diff)
+			}
+			if con.readBuffer != testcase.readBuffer {
+				t.Fatalf("expected read buffer of %d but got %d", testcase.readBuffer, con.readBuffer)
+			}
+			if tc.timeout != cfg.Timeout || tc.skipValidation != cfg.SkipValidation ||
+				tc.lazyStats != cfg.LazyStats || tc.orphanCheck != cfg.OrphanCheck ||
+				tc.verifyWrites != cfg.VerifyWrites {
+				t.Fatalf("configuration was not applied")
+			}
+		})
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		con := &optionConn{options: make(map[netlink.ConnOption]bool)}
+		tc, err := newTc(con, &Config{Timeout: time.Minute})
+		if err != nil {
+			t.Fatalf("could not configure connection: %v", err)
+		}
+		before := time.Now()
+		if _, err := tc.query(netlink.Message{}); err != nil {
+			t.Fatalf("query failed: %v", err)
+		}
+		if con.deadline.Before(before.Add(time.Minute)) {
+			t.Fatalf("expected read deadline after %v but got %v", before.Add(time.Minute), con.deadline)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		errOption := errors.New("option not supported")
+		con := &optionConn{err: errOption}
+		if _, err := newTc(con, &Config{StrictCheck: true}); !errors.Is(err, errOption) {
+			t.Fatalf("expected %v but got %v", errOption, err)
+		}
+	})
+}
@@ -0,0 +1,149 @@
+package tc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+)
+
+// OrphanError is returned with Config.OrphanCheck, if replacing or deleting
+// a qdisc would destroy the classes, filters and qdiscs beneath it. It wraps
+// ErrWouldOrphan.
+type OrphanError struct {
+	// Op is the refused operation, "replace" or "delete".
+	Op string
+	// Qdisc is the existing qdisc, that would be destroyed.
+	Qdisc Object
+	// Objects are the classes, qdiscs and filters beneath Qdisc.
+	Objects []Object
+}
+
+func (e *OrphanError) Error() string {
+	objs := make([]string, 0, len(e.Objects))
+	for _, obj := range e.Objects {
+		objs = append(objs, obj.String())
+	}
+	return fmt.Sprintf("%s %s: %v: %s", e.Op, e.Qdisc, ErrWouldOrphan, strings.Join(objs, ", "))
+}
+
+// Unwrap returns ErrWouldOrphan.
+func (e *OrphanError) Unwrap() error {
+	return ErrWouldOrphan
+}
+
+// Force returns a Qdisc, that replaces and deletes queueing disciplines
+// without the check of Config.OrphanCheck.
+func (qd *Qdisc) Force() *Qdisc {
+	force := *qd
+	force.orphanCheck = false
+	return &force
+}
+
+// checkOrphans returns an *OrphanError, if the operation op with info
+// destroys an existing qdisc with objects beneath it. Replacing a qdisc by
+// one of the same kind and handle changes it in place and keeps them.
+func (qd *Qdisc) checkOrphans(op string, info *Object) error {
+	if !qd.orphanCheck {
+		return nil
+	}
+	qdiscs, err := qd.Get()
+	if err != nil {
+		return err
+	}
+	target := targetQdisc(qdiscs, info)
+	if target == nil {
+		// Nothing is destroyed or the kernel refuses the operation.
+		return nil
+	}
+	switch {
+	case op == "delete" && info.Handle != 0 && info.Handle != target.Handle:
+		// The kernel only deletes the qdisc of Parent, if the handles match.
+		return nil
+	case op == "replace" && target.Kind == info.Kind &&
+		(info.Handle == 0 || info.Handle == target.Handle):
+		return nil
+	}
+	objs, err := qd.beneath(target, qdiscs)
+	if err != nil {
+		return err
+	}
+	if len(objs) == 0 {
+		return nil
+	}
+	return &OrphanError{Op: op, Qdisc: *target, Objects: objs}
+}
+
+// targetQdisc returns the qdisc of qdiscs, that is replaced or deleted with
+// info, like linux/net/sched/sch_api.c looks it up. Qdiscs with handle 0 are
+// created by the kernel and are not returned.
+func targetQdisc(qdiscs []Object, info *Object) *Object {
+	for i := range qdiscs {
+		qdisc := &qdiscs[i]
+		if qdisc.Ifindex != info.Ifindex || qdisc.Handle == 0 {
+			continue
+		}
+		if info.Parent == 0 {
+			if qdisc.Handle == info.Handle {
+				return qdisc
+			}
+			continue
+		}
+		if qdisc.Parent == info.Parent {
+			return qdisc
+		}
+	}
+	return nil
+}
+
+// beneath returns the classes and qdiscs beneath target followed by the
+// filters attached to target and to them.
+func (qd *Qdisc) beneath(target *Object, qdiscs []Object) ([]Object, error) {
+	classes, err := qd.Class().Get(&Msg{Family: unix.AF_UNSPEC, Ifindex: target.Ifindex})
+	if err != nil {
+		return nil, err
+	}
+
+	var objs []Object
+	parents := filterParents(target)
+	// Classes beneath a qdisc share its major. Qdiscs beneath a class refer to
+	// it as their parent.
+	majors := []uint32{target.Handle}
+	seen := make(map[uint32]bool)
+	for len(majors) > 0 {
+		maj, _ := core.SplitHandle(majors[0])
+		majors = majors[1:]
+		if seen[maj] {
+			continue
+		}
+		seen[maj] = true
+		for _, class := range classes {
+			if classMaj, _ := core.SplitHandle(class.Handle); classMaj == maj {
+				objs = append(objs, class)
+				parents = append(parents, class.Handle)
+			}
+		}
+		for i := range qdiscs {
+			qdisc := &qdiscs[i]
+			if qdisc.Ifindex != target.Ifindex || qdisc.Parent == HandleRoot ||
+				qdisc.Parent == HandleIngress {
+				continue
+			}
+			if parentMaj, _ := core.SplitHandle(qdisc.Parent); parentMaj != maj {
+				continue
+			}
+			objs = append(objs, *qdisc)
+			if qdisc.Handle != 0 {
+				parents = append(parents, filterParents(qdisc)...)
+				majors = append(majors, qdisc.Handle)
+			}
+		}
+	}
+
+	filters, err := parentFilters(&qd.Tc, target.Ifindex, parents)
+	if err != nil {
+		return nil, err
+	}
+	return append(objs, filters...), nil
+}
@@ -0,0 +1,204 @@
+package tc
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/florianl/go-tc/internal/unix"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOrphanCheck(t *testing.T) {
+	htb := func(ifindex, handle uint32) *Object {
+		return &Object{
+			Msg: Msg{Family: unix.AF_UNSPEC, Ifindex: ifindex, Handle: handle, Parent: HandleRoot},
+			Attribute: Attribute{Kind: "htb", Htb: &Htb{
+				Init: &HtbGlob{Version: 0x3, Rate2Quantum: 0xa, Defcls: 0x10}}},
+		}
+	}
+	fqCodel := &Object{
+		Msg:       Msg{Family: unix.AF_UNSPEC, Ifindex: 2, Handle: 0x20000, Parent: HandleRoot},
+		Attribute: Attribute{Kind: "fq_codel", FqCodel: &FqCodel{Limit: uint32Ptr(10240)}},
+	}
+	// Deleted qdiscs are taken from the dump.
+	dumped := func(ifindex uint32, kind string) *Object {
+		for _, obj := range ownedDump() {
+			if obj.Ifindex == ifindex && obj.Kind == kind && objectType(&obj) == "qdisc" {
+				return &obj
+			}
+		}
+		t.Fatalf("missing %s qdisc of ifindex %d", kind, ifindex)
+		return nil
+	}
+	htbTree := []string{
+		"class htb 1:1 dev ifindex 2 parent root",
+		"class htb 1:10 dev ifindex 2 parent 1:1",
+		"qdisc fq_codel 10: dev ifindex 2 parent 1:10",
+		"filter fw 0xa dev ifindex 2 parent 1: pref 1",
+		"filter matchall 0xa dev ifindex 2 parent 1:1 pref 1",
+	}
+	replace := func(qd *Qdisc, info *Object) error { return qd.Replace(info) }
+	link := func(qd *Qdisc, info *Object) error { return qd.Link(info) }
+	del := func(qd *Qdisc, info *Object) error { return qd.Delete(info) }
+
+	tests := map[string]struct {
+		info     *Object
+		op       func(*Qdisc, *Object) error
+		disabled bool
+		force    bool
+		// orphans are the objects of the OrphanError. Without them, info is
+		// expected to be sent to the kernel.
+		orphans []string
+		opName  string
+		err     error
+	}{
+		"replace with other handle": {
+			info:    htb(2, 0x20000),
+			op:      replace,
+			orphans: htbTree,
+			opName:  "replace",
+		},
+		"replace with other kind": {
+			info:    fqCodel,
+			op:      replace,
+			orphans: htbTree,
+			opName:  "replace",
+		},
+		"link with other handle": {
+			info:    htb(2, 0x20000),
+			op:      link,
+			orphans: htbTree,
+			opName:  "replace",
+		},
+		"replace forced": {
+			info:  fqCodel,
+			op:    replace,
+			force: true,
+		},
+		"replace in place": {
+			info: htb(2, 0x10000),
+			op:   replace,
+		},
+		"replace without qdisc": {
+			info: htb(4, 0x10000),
+			op:   replace,
+		},
+		"delete": {
+			info:    dumped(2, "htb"),
+			op:      del,
+			orphans: htbTree,
+			opName:  "delete",
+		},
+		"delete forced": {
+			info:  dumped(2, "htb"),
+			op:    del,
+			force: true,
+		},
+		"delete by handle": {
+			info: &Object{Msg: Msg{Family: unix.AF_UNSPEC, Ifindex: 2, Handle: 0x10000},
+				Attribute: Attribute{Kind: "htb"}},
+			op:      del,
+			orphans: htbTree,
+			opName:  "delete",
+		},
+		"delete leaf": {
+			info: dumped(2, "fq_codel"),
+			op:   del,
+		},
+		"delete clsact": {
+			info: dumped(2, "clsact"),
+			op:   del,
+			orphans: []string{
+				"filter matchall 0xa dev ifindex 2 parent clsact-ingress pref 1",
+				"filter matchall 0x1 dev ifindex 2 parent clsact-ingress pref 2",
+				"filter bpf 0xa dev ifindex 2 parent clsact-ingress pref 3",
+				"filter u32 0x80000801 dev ifindex 2 parent clsact-ingress pref 4",
+				"filter basic 0x1 dev ifindex 2 parent clsact-egress pref 1",
+			},
+			opName: "delete",
+		},
+		"delete on other device": {
+			info:    dumped(3, "htb"),
+			op:      del,
+			orphans: []string{"filter matchall 0xa dev ifindex 3 parent 1: pref 1"},
+			opName:  "delete",
+		},
+		"delete other handle": {
+			info: &Object{Msg: Msg{Family: unix.AF_UNSPEC, Ifindex: 2, Handle: 0x20000, Parent: HandleRoot},
+				Attribute: Attribute{Kind: "htb"}},
+			op:  del,
+			err: syscall.ENOENT,
+		},
+		"disabled": {
+			info:     dumped(2, "htb"),
+			op:       del,
+			disabled: true,
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			tcSocket, sent := ownedConn(t, ownedDump(), 0)
+			defer tcSocket.Close()
+			tcSocket.skipValidation = true
+			tcSocket.orphanCheck = !testcase.disabled
+
+			qd := tcSocket.Qdisc()
+			if testcase.force {
+				qd = qd.Force()
+			}
+			err := testcase.op(qd, testcase.info)
+			if testcase.orphans == nil {
+				if !errors.Is(err, testcase.err) {
+					t.Fatalf("expected error %v but got %v", testcase.err, err)
+				}
+				if len(*sent) != 1 {
+					t.Fatalf("expected a single request but got %v", *sent)
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrWouldOrphan) {
+				t.Fatalf("expected error %v but got %v", ErrWouldOrphan, err)
+			}
+			var orphanErr *OrphanError
+			if !errors.As(err, &orphanErr) {
+				t.Fatalf("expected *OrphanError but got %T", err)
+			}
+			if orphanErr.Op != testcase.opName {
+				t.Fatalf("expected operation %s but got %s", testcase.opName, orphanErr.Op)
+			}
+			if orphanErr.Qdisc.Ifindex != testcase.info.Ifindex {
+				t.Fatalf("unexpected qdisc %s", orphanErr.Qdisc)
+			}
+			var orphans []string
+			for _, obj := range orphanErr.Objects {
+				orphans = append(orphans, obj.String())
+			}
+			if diff := cmp.Diff(testcase.orphans, orphans); diff != "" {
+				t.Fatalf("orphans missmatch (-want +got):\n%s", diff)
+			}
+			if len(*sent) != 0 {
+				t.Fatalf("refused request was sent: %v", *sent)
+			}
+		})
+	}
+}
+
+func TestOrphanErrorMessage(t *testing.T) {
+	err := &OrphanError{
+		Op: "delete",
+		Qdisc: Object{Msg: Msg{Ifindex: 2, Handle: 0x10000, Parent: HandleRoot},
+			Attribute: Attribute{Kind: "htb"}},
+		Objects: []Object{
+			{Msg: Msg{Ifindex: 2, Handle: 0x10001, Parent: HandleRoot}, Attribute: Attribute{Kind: "htb"}},
+			{Msg: Msg{Ifindex: 2, Handle: 0xA, Parent: 0x10000, Info: 0x10300}, Attribute: Attribute{Kind: "fw"}},
+		},
+	}
+	want := "delete qdisc htb 1: dev ifindex 2 parent root: objects beneath it would be destroyed: " +
+		"class htb 1:1 dev ifindex 2 parent root, filter fw 0xa dev ifindex 2 parent 1: pref 1"
+	if got := err.Error(); got != want {
+		t.Fatalf("expected %q but got %q", want, got)
+	}
+}
@@ -0,0 +1,257 @@
+package tc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"syscall"
+
+	"github.com/florianl/go-tc/internal/unix"
+)
+
+// cookieMaxLen is TC_COOKIE_MAX_SIZE of include/uapi/linux/pkt_cls.h.
+const cookieMaxLen = 16
+
+// filterActions returns the actions of the filter obj.
+func filterActions(obj *Object) []*Action {
+	var actions *[]*Action
+	switch obj.Kind {
+	case "basic":
+		if obj.Basic != nil {
+			actions = obj.Basic.Actions
+		}
+	case "bpf":
+		if obj.BPF != nil && obj.BPF.Action != nil {
+			return []*Action{obj.BPF.Action}
+		}
+	case "cgroup":
+		if obj.Cgroup != nil && obj.Cgroup.Action != nil {
+			return []*Action{obj.Cgroup.Action}
+		}
+	case "flow":
+		if obj.Flow != nil {
+			actions = obj.Flow.Actions
+		}
+	case "flower":
+		if obj.Flower != nil {
+			actions = obj.Flower.Actions
+		}
+	case "fw":
+		if obj.Fw != nil {
+			actions = obj.Fw.Actions
+		}
+	case "matchall":
+		if obj.Matchall != nil {
+			actions = obj.Matchall.Actions
+		}
+	case "route4":
+		if obj.Route4 != nil {
+			actions = obj.Route4.Actions
+		}
+	case "rsvp":
+		if obj.Rsvp != nil {
+			actions = obj.Rsvp.Actions
+		}
+	case "tcindex":
+		if obj.TcIndex != nil {
+			actions = obj.TcIndex.Actions
+		}
+	case "u32":
+		if obj.U32 != nil {
+			actions = obj.U32.Actions
+		}
+	}
+	if actions == nil {
+		return nil
+	}
+	return *actions
+}
+
+// SetCookie sets cookie on every action of the filter obj, so that the
+// filter can be recognized in later dumps. The kernel keeps cookies only
+// for actions, so qdiscs, classes and filters without actions can not be
+// marked.
+func SetCookie(obj *Object, cookie []byte) error {
+	if obj == nil {
+		return ErrNoArg
+	}
+	if len(cookie) == 0 || len(cookie) > cookieMaxLen {
+		return fmt.Errorf("cookie of %d bytes, the kernel accepts 1 to %d bytes: %w",
+			len(cookie), cookieMaxLen, ErrInvalidArg)
+	}
+	if objectType(obj) != "filter" {
+		return fmt.Errorf("%s %s: only the actions of filters carry cookies: %w",
+			objectType(obj), obj.Kind, ErrInvalidArg)
+	}
+	actions := filterActions(obj)
+	if len(actions) == 0 {
+		return fmt.Errorf("%s: filter without actions: %w", obj.Kind, ErrNoArg)
+	}
+	for _, action := range actions {
+		if action == nil {
+			return fmt.Errorf("%s: %w", obj.Kind, ErrNoArg)
+		}
+	}
+	for _, action := range actions {
+		c := append([]byte{}, cookie...)
+		action.Cookie = &c
+	}
+	return nil
+}
+
+// HasCookiePrefix reports whether obj is a filter with actions, whose
+// cookies all start with prefix.
+func HasCookiePrefix(obj *Object, prefix []byte) bool {
+	if obj == nil || objectType(obj) != "filter" {
+		return false
+	}
+	actions := filterActions(obj)
+	if len(actions) == 0 {
+		return false
+	}
+	for _, action := range actions {
+		if action == nil || action.Cookie == nil || !bytes.HasPrefix(*action.Cookie, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// SelectOwned returns the objects, for which HasCookiePrefix reports true.
+func SelectOwned(objs []Object, prefix []byte) []Object {
+	var owned []Object
+	for i := range objs {
+		if HasCookiePrefix(&objs[i], prefix) {
+			owned = append(owned, objs[i])
+		}
+	}
+	return owned
+}
+
+// deletionOrder returns objs in the order, in which they can be deleted:
+// filters first, then classes and qdiscs with their descendants ahead of
+// them, as the kernel removes the descendants of a qdisc or class with it.
+func deletionOrder(objs []Object) ([]Object, error) {
+	var filters, qdiscs, classes []Object
+	for _, obj := range objs {
+		switch objectType(&obj) {
+		case "filter":
+			filters = append(filters, obj)
+		case "class":
+			classes = append(classes, obj)
+		default:
+			qdiscs = append(qdiscs, obj)
+		}
+	}
+	tree, err := BuildTree(qdiscs, classes)
+	if err != nil {
+		return nil, err
+	}
+
+	ordered := filters
+	var postOrder func(n *Node)
+	postOrder = func(n *Node) {
+		for _, child := range n.Children {
+			postOrder(child)
+		}
+		if !n.virtual {
+			ordered = append(ordered, n.Object)
+		}
+	}
+	postOrder(tree)
+	for _, orphan := range tree.Orphans {
+		postOrder(orphan)
+	}
+	return ordered, nil
+}
+
+// DeleteObjects deletes the filters, classes and qdiscs objs in an order,
+// that deletes filters before classes before qdiscs and the descendants of
+// a class or qdisc before it. Objects, that do not exist anymore, are
+// skipped. DeleteObjects stops at the first error.
+func DeleteObjects(tcSocket *Tc, objs []Object) error {
+	ordered, err := deletionOrder(objs)
+	if err != nil {
+		return err
+	}
+	for i := range ordered {
+		obj := &ordered[i]
+		var err error
+		switch objectType(obj) {
+		case "filter":
+			err = tcSocket.Filter().Delete(obj)
+		case "class":
+			err = tcSocket.Class().Delete(obj)
+		default:
+			err = tcSocket.Qdisc().Delete(obj)
+		}
+		if errors.Is(err, syscall.ENOENT) {
+			// removed in the meantime or together with its parent
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("could not delete %s: %w", obj, err)
+		}
+	}
+	return nil
+}
+
+// CleanupOwned deletes the filters of ifindex, whose action cookies start
+// with prefix, like they were set with SetCookie. Filters of other software
+// are left untouched. As the kernel stores no cookies for qdiscs and
+// classes, they are never deleted.
+func CleanupOwned(tcSocket *Tc, ifindex uint32, prefix []byte) error {
+	if ifindex == 0 {
+		return ErrInvalidDev
+	}
+	filters, err := deviceFilters(tcSocket, ifindex)
+	if err != nil {
+		return err
+	}
+	return DeleteObjects(tcSocket, SelectOwned(filters, prefix))
+}
+
+// deviceFilters returns the filters of all qdiscs and classes of ifindex.
+func deviceFilters(tcSocket *Tc, ifindex uint32) ([]Object, error) {
+	qdiscs, err := tcSocket.Qdisc().Get()
+	if err != nil {
+		return nil, err
+	}
+	var parents []uint32
+	for i := range qdiscs {
+		if qdiscs[i].Ifindex != ifindex || qdiscs[i].Handle == 0 {
+			continue
+		}
+		parents = append(parents, filterParents(&qdiscs[i])...)
+	}
+	classes, err := tcSocket.Class().Get(&Msg{Family: unix.AF_UNSPEC, Ifindex: ifindex})
+	if err != nil {
+		return nil, err
+	}
+	for _, class := range classes {
+		parents = append(parents, class.Handle)
+	}
+	return parentFilters(tcSocket, ifindex, parents)
+}
+
+// filterParents returns the parents, the filters of qdisc are attached to.
+func filterParents(qdisc *Object) []uint32 {
+	if qdisc.Kind == "clsact" {
+		// Filters are attached to the hooks of clsact.
+		return []uint32{HandleClsactIngress, HandleClsactEgress}
+	}
+	return []uint32{qdisc.Handle}
+}
+
+// parentFilters returns the filters of ifindex, that are attached to parents.
+func parentFilters(tcSocket *Tc, ifindex uint32, parents []uint32) ([]Object, error) {
+	var filters []Object
+	for _, parent := range parents {
+		objs, err := tcSocket.Filter().Get(&Msg{Family: unix.AF_UNSPEC, Ifindex: ifindex, Parent: parent})
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, objs...)
+	}
+	return filters, nil
+}
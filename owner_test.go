@@ -0,0 +1,336 @@
+package tc
+
+import (
+	"errors"
+	"io"
+	"syscall"
+	"testing"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nltest"
+)
+
+// gactWithCookie returns a gact action with cookie, unless cookie is empty.
+func gactWithCookie(cookie string) *Action {
+	action := &Action{Kind: "gact", Gact: &Gact{Parms: &GactParms{Action: 2}}}
+	if cookie != "" {
+		action.Cookie = bytesPtr([]byte(cookie))
+	}
+	return action
+}
+
+// ownedDump returns a mixed dump of two devices. The filters, whose
+// handles have the minor 0xA, carry cookies with the prefix "ctl-" on all
+// of their actions.
+func ownedDump() []Object {
+	msg := func(ifindex, handle, parent, info uint32) Msg {
+		return Msg{Family: unix.AF_UNSPEC, Ifindex: ifindex, Handle: handle, Parent: parent, Info: info}
+	}
+	ingress := HandleClsactIngress
+	egress := HandleClsactEgress
+	return []Object{
+		{Msg: msg(2, core.BuildHandle(0xFFFF, 0), HandleIngress, 1), Attribute: Attribute{Kind: "clsact"}},
+		{Msg: msg(2, 0x10000, HandleRoot, 1), Attribute: Attribute{Kind: "htb"}},
+		{Msg: msg(2, 0x10001, HandleRoot, 0), Attribute: Attribute{Kind: "htb"}},
+		{Msg: msg(2, 0x10010, 0x10001, 0), Attribute: Attribute{Kind: "htb"}},
+		{Msg: msg(2, 0x100000, 0x10010, 1), Attribute: Attribute{Kind: "fq_codel"}},
+		{Msg: msg(3, 0x10000, HandleRoot, 1), Attribute: Attribute{Kind: "htb"}},
+
+		{Msg: msg(2, 0xA, ingress, 0x10300), Attribute: Attribute{Kind: "matchall",
+			Matchall: &Matchall{Actions: &[]*Action{gactWithCookie("ctl-a")}}}},
+		{Msg: msg(2, 0x1, ingress, 0x20300), Attribute: Attribute{Kind: "matchall",
+			Matchall: &Matchall{Actions: &[]*Action{gactWithCookie("other")}}}},
+		{Msg: msg(2, 0xA, ingress, 0x30300), Attribute: Attribute{Kind: "bpf",
+			BPF: &Bpf{Action: gactWithCookie("ctl-b"), FD: uint32Ptr(3)}}},
+		{Msg: msg(2, 0x80000801, ingress, 0x40300), Attribute: Attribute{Kind: "u32",
+			U32: &U32{ClassID: uint32Ptr(0x10010),
+				Actions: &[]*Action{gactWithCookie("ctl-x"), gactWithCookie("")}}}},
+		{Msg: msg(2, 0x1, egress, 0x10300), Attribute: Attribute{Kind: "basic",
+			Basic: &Basic{ClassID: uint32Ptr(0x10010)}}},
+		{Msg: msg(2, 0xA, 0x10000, 0x10300), Attribute: Attribute{Kind: "fw",
+			Fw: &Fw{Actions: &[]*Action{gactWithCookie("ctl-c"), gactWithCookie("ctl-d")}}}},
+		{Msg: msg(2, 0xA, 0x10001, 0x10300), Attribute: Attribute{Kind: "matchall",
+			Matchall: &Matchall{Actions: &[]*Action{gactWithCookie("ctl-e")}}}},
+		{Msg: msg(3, 0xA, 0x10000, 0x10300), Attribute: Attribute{Kind: "matchall",
+			Matchall: &Matchall{Actions: &[]*Action{gactWithCookie("ctl-f")}}}},
+	}
+}
+
+// ownedDelete identifies a deleted object or, with RTM_NEWQDISC, a replaced
+// qdisc.
+type ownedDelete struct {
+	Type    netlink.HeaderType
+	Ifindex uint32
+	Handle  uint32
+	Parent  uint32
+	Info    uint32
+}
+
+// ownedConn answers dumps with stored and deletes objects from it.
+func ownedConn(t *testing.T, stored []Object, failHandle uint32) (*Tc, *[]ownedDelete) {
+	t.Helper()
+	var deleted []ownedDelete
+
+	dump := func(typ string, match func(*Object) bool) ([]netlink.Message, error) {
+		var msgs []netlink.Message
+		for i := range stored {
+			obj := &stored[i]
+			if objectType(obj) != typ || !match(obj) {
+				continue
+			}
+			data, err := marshalStruct(&obj.Msg)
+			if err != nil {
+				t.Fatalf("could not encode Msg: %v", err)
+			}
+			opts := []tcOption{{Interpretation: vtString, Type: tcaKind, Data: obj.Kind}}
+			if typ == "filter" {
+				filter, err := MarshalObject(OpFilterAdd, obj)
+				if err != nil {
+					t.Fatalf("could not encode %s: %v", obj, err)
+				}
+				data = filter.Data
+				opts = nil
+			}
+			attrs, err := marshalAttributes(opts)
+			if err != nil {
+				t.Fatalf("could not encode attributes: %v", err)
+			}
+			msgs = append(msgs, netlink.Message{Data: append(data, attrs...)})
+		}
+		if len(msgs) == 0 {
+			return nil, io.EOF
+		}
+		return msgs, nil
+	}
+
+	c := &Tc{
+		con: nltest.Dial(func(req []netlink.Message) ([]netlink.Message, error) {
+			if len(req) == 0 {
+				return nil, io.EOF
+			}
+			msg, _, err := unmarshalTcmsg(req[0].Data)
+			if err != nil {
+				t.Fatalf("could not decode Msg: %v", err)
+			}
+			switch req[0].Header.Type {
+			case unix.RTM_GETQDISC:
+				return dump("qdisc", func(*Object) bool { return true })
+			case unix.RTM_GETTCLASS:
+				return dump("class", func(obj *Object) bool {
+					return obj.Ifindex == msg.Ifindex
+				})
+			case unix.RTM_GETTFILTER:
+				return dump("filter", func(obj *Object) bool {
+					return obj.Ifindex == msg.Ifindex && obj.Parent == msg.Parent
+				})
+			}
+
+			deleted = append(deleted, ownedDelete{req[0].Header.Type, msg.Ifindex, msg.Handle, msg.Parent, msg.Info})
+			if msg.Handle == failHandle {
+				return nltest.Error(int(syscall.EBUSY), req)
+			}
+			if req[0].Header.Type == unix.RTM_NEWQDISC {
+				return []netlink.Message{{Header: netlink.Header{Type: netlink.Error}, Data: make([]byte, 4)}}, nil
+			}
+			// Info of a qdisc is its reference counter, which is not sent.
+			for i := range stored {
+				if stored[i].Ifindex == msg.Ifindex && stored[i].Handle == msg.Handle &&
+					stored[i].Parent == msg.Parent && (stored[i].Info == msg.Info || isQdiscMsg(int(req[0].Header.Type))) {
+					stored = append(stored[:i], stored[i+1:]...)
+					return []netlink.Message{{Header: netlink.Header{Type: netlink.Error}, Data: make([]byte, 4)}}, nil
+				}
+			}
+			return nltest.Error(int(syscall.ENOENT), req)
+		}),
+	}
+	return c, &deleted
+}
+
+func TestSetCookie(t *testing.T) {
+	tests := map[string]struct {
+		obj    *Object
+		cookie []byte
+		err    error
+	}{
+		"actions": {
+			obj: &Object{Attribute: Attribute{Kind: "u32",
+				U32: &U32{Actions: &[]*Action{gactWithCookie(""), gactWithCookie("old")}}}},
+			cookie: []byte("ctl-1"),
+		},
+		"single action": {
+			obj:    &Object{Attribute: Attribute{Kind: "cgroup", Cgroup: &Cgroup{Action: gactWithCookie("")}}},
+			cookie: []byte("ctl-1"),
+		},
+		"qdisc": {
+			obj:    &Object{Attribute: Attribute{Kind: "htb"}},
+			cookie: []byte("ctl-1"),
+			err:    ErrInvalidArg,
+		},
+		"without actions": {
+			obj:    &Object{Attribute: Attribute{Kind: "matchall", Matchall: &Matchall{}}},
+			cookie: []byte("ctl-1"),
+			err:    ErrNoArg,
+		},
+		"nil action": {
+			obj: &Object{Attribute: Attribute{Kind: "fw",
+				Fw: &Fw{Actions: &[]*Action{gactWithCookie(""), nil}}}},
+			cookie: []byte("ctl-1"),
+			err:    ErrNoArg,
+		},
+		"cookie too long": {
+			obj: &Object{Attribute: Attribute{Kind: "matchall",
+				Matchall: &Matchall{Actions: &[]*Action{gactWithCookie("")}}}},
+			cookie: make([]byte, cookieMaxLen+1),
+			err:    ErrInvalidArg,
+		},
+		"missing cookie": {
+			obj: &Object{Attribute: Attribute{Kind: "matchall",
+				Matchall: &Matchall{Actions: &[]*Action{gactWithCookie("")}}}},
+			err: ErrInvalidArg,
+		},
+		"missing object": {cookie: []byte("ctl-1"), err: ErrNoArg},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := SetCookie(testcase.obj, testcase.cookie)
+			if testcase.err != nil {
+				if !errors.Is(err, testcase.err) {
+					t.Fatalf("expected error %v but got %v", testcase.err, err)
+				}
+				if testcase.obj != nil && HasCookiePrefix(testcase.obj, testcase.cookie) {
+					t.Fatalf("object was marked despite the error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !HasCookiePrefix(testcase.obj, []byte("ctl-")) {
+				t.Fatalf("object is not marked")
+			}
+			// Every action has its own copy of the cookie.
+			testcase.cookie[0] = 'x'
+			if !HasCookiePrefix(testcase.obj, []byte("ctl-")) {
+				t.Fatalf("cookie is shared with the caller")
+			}
+		})
+	}
+}
+
+func TestSelectOwned(t *testing.T) {
+	var want []uint32
+	dump := ownedDump()
+	for i := range dump {
+		if objectType(&dump[i]) == "filter" && dump[i].Handle == 0xA {
+			want = append(want, dump[i].Info)
+		}
+	}
+
+	var got []uint32
+	for _, obj := range SelectOwned(dump, []byte("ctl-")) {
+		got = append(got, obj.Info)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("owned objects missmatch (-want +got):\n%s", diff)
+	}
+	if owned := SelectOwned(dump, []byte("ctl-a")); len(owned) != 1 {
+		t.Fatalf("expected 1 object with prefix ctl-a but got %d", len(owned))
+	}
+	if owned := SelectOwned(dump, []byte("none")); len(owned) != 0 {
+		t.Fatalf("expected no object but got %d", len(owned))
+	}
+}
+
+func TestDeleteObjects(t *testing.T) {
+	dump := ownedDump()
+	var objs []Object
+	// Reverse the dump, so that every object is listed before its parent.
+	for i := len(dump) - 1; i >= 0; i-- {
+		if dump[i].Ifindex == 2 {
+			objs = append(objs, dump[i])
+		}
+	}
+
+	t.Run("order", func(t *testing.T) {
+		tcSocket, deleted := ownedConn(t, ownedDump(), 0)
+		defer tcSocket.Close()
+
+		if err := DeleteObjects(tcSocket, objs); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var types []netlink.HeaderType
+		var qdiscs, classes []uint32
+		for _, d := range *deleted {
+			// Filters can be deleted in any order, but before all classes.
+			if n := len(types); n == 0 || types[n-1] != d.Type {
+				types = append(types, d.Type)
+			}
+			switch d.Type {
+			case unix.RTM_DELTCLASS:
+				classes = append(classes, d.Handle)
+			case unix.RTM_DELQDISC:
+				qdiscs = append(qdiscs, d.Handle)
+			}
+		}
+		if diff := cmp.Diff([]netlink.HeaderType{unix.RTM_DELTFILTER, unix.RTM_DELQDISC,
+			unix.RTM_DELTCLASS, unix.RTM_DELQDISC}, types); diff != "" {
+			t.Fatalf("order of types missmatch (-want +got):\n%s", diff)
+		}
+		if diff := cmp.Diff([]uint32{0x10010, 0x10001}, classes); diff != "" {
+			t.Fatalf("order of classes missmatch (-want +got):\n%s", diff)
+		}
+		// The leaf qdisc goes before its class and htb, clsact has no children.
+		if diff := cmp.Diff([]uint32{0x100000, 0x10000, 0xFFFF0000}, qdiscs); diff != "" {
+			t.Fatalf("order of qdiscs missmatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("already deleted", func(t *testing.T) {
+		tcSocket, _ := ownedConn(t, nil, 0)
+		defer tcSocket.Close()
+
+		if err := DeleteObjects(tcSocket, objs); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		tcSocket, deleted := ownedConn(t, ownedDump(), 0x10010)
+		defer tcSocket.Close()
+
+		if err := DeleteObjects(tcSocket, objs); !errors.Is(err, syscall.EBUSY) {
+			t.Fatalf("expected EBUSY but got %v", err)
+		}
+		last := (*deleted)[len(*deleted)-1]
+		if last.Type != unix.RTM_DELTCLASS || last.Handle != 0x10010 {
+			t.Fatalf("expected to stop at class 1:10 but stopped at %+v", last)
+		}
+	})
+}
+
+func TestCleanupOwned(t *testing.T) {
+	var want []ownedDelete
+	for _, obj := range SelectOwned(ownedDump(), []byte("ctl-")) {
+		if obj.Ifindex == 2 {
+			want = append(want, ownedDelete{unix.RTM_DELTFILTER, obj.Ifindex, obj.Handle, obj.Parent, obj.Info})
+		}
+	}
+
+	tcSocket, deleted := ownedConn(t, ownedDump(), 0)
+	defer tcSocket.Close()
+
+	if err := CleanupOwned(tcSocket, 2, []byte("ctl-")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(want, *deleted); diff != "" {
+		t.Fatalf("deleted objects missmatch (-want +got):\n%s", diff)
+	}
+
+	if err := CleanupOwned(tcSocket, 0, []byte("ctl-")); !errors.Is(err, ErrInvalidDev) {
+		t.Fatalf("expected ErrInvalidDev but got %v", err)
+	}
+}
@@ -0,0 +1,384 @@
+package tc
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+
+	"github.com/florianl/go-tc/core"
+)
+
+// Step is a change of a qdisc, class or filter, that is part of a plan
+// returned by Plan.
+type Step struct {
+	// Op is one of the operations OpQdiscAdd, OpQdiscReplace, OpQdiscDelete,
+	// OpClassAdd, OpClassReplace, OpClassDelete, OpFilterAdd,
+	// OpFilterReplace and OpFilterDelete.
+	Op Operation
+	// Object is the desired object for adds and replaces and the current one
+	// for deletes.
+	Object Object
+	// Previous is the current object, that a replace changes. Apply restores
+	// it, if a later step fails.
+	Previous *Object
+}
+
+func (s Step) String() string {
+	return fmt.Sprintf("%s %s", stepVerbs[s.Op], s.Object.String())
+}
+
+// planOperations are the operations of the steps for each type of object.
+var planOperations = map[string]struct{ add, replace, del Operation }{
+	"qdisc":  {OpQdiscAdd, OpQdiscReplace, OpQdiscDelete},
+	"class":  {OpClassAdd, OpClassReplace, OpClassDelete},
+	"filter": {OpFilterAdd, OpFilterReplace, OpFilterDelete},
+}
+
+var stepVerbs = map[Operation]string{
+	OpQdiscAdd:      "add",
+	OpQdiscReplace:  "replace",
+	OpQdiscDelete:   "delete",
+	OpClassAdd:      "add",
+	OpClassReplace:  "replace",
+	OpClassDelete:   "delete",
+	OpFilterAdd:     "add",
+	OpFilterReplace: "replace",
+	OpFilterDelete:  "delete",
+}
+
+// stepFuncs perform the operations of steps.
+var stepFuncs = map[Operation]func(tcSocket *Tc, info *Object) error{
+	OpQdiscAdd:      func(tcSocket *Tc, info *Object) error { return tcSocket.Qdisc().Add(info) },
+	OpQdiscReplace:  func(tcSocket *Tc, info *Object) error { return tcSocket.Qdisc().Replace(info) },
+	OpQdiscDelete:   func(tcSocket *Tc, info *Object) error { return tcSocket.Qdisc().Delete(info) },
+	OpClassAdd:      func(tcSocket *Tc, info *Object) error { return tcSocket.Class().Add(info) },
+	OpClassReplace:  func(tcSocket *Tc, info *Object) error { return tcSocket.Class().Replace(info) },
+	OpClassDelete:   func(tcSocket *Tc, info *Object) error { return tcSocket.Class().Delete(info) },
+	OpFilterAdd:     func(tcSocket *Tc, info *Object) error { return tcSocket.Filter().Add(info) },
+	OpFilterReplace: func(tcSocket *Tc, info *Object) error { return tcSocket.Filter().Replace(info) },
+	OpFilterDelete:  func(tcSocket *Tc, info *Object) error { return tcSocket.Filter().Delete(info) },
+}
+
+// planKey identifies a qdisc or class by its handle and a filter by its
+// handle, parent, priority, protocol and chain.
+type planKey struct {
+	typ     string
+	ifindex uint32
+	handle  uint32
+	parent  uint32
+	info    uint32
+	chain   uint32
+}
+
+func planKeyOf(obj *Object) planKey {
+	typ := objectType(obj)
+	key := planKey{typ: typ, ifindex: obj.Ifindex, handle: obj.Handle}
+	if typ == "filter" {
+		key.parent = obj.Parent
+		key.info = obj.Info
+		if obj.Chain != nil {
+			key.chain = *obj.Chain
+		}
+	}
+	return key
+}
+
+// ownerKey returns the key of the qdisc or class, that the kernel destroys obj
+// with. ok is false for root and ingress qdiscs and filters of shared blocks.
+// classes contains the keys of the known classes.
+func ownerKey(obj *Object, classes map[planKey]bool) (key planKey, ok bool) {
+	typ := objectType(obj)
+	switch {
+	case typ == "qdisc" && (obj.Parent == HandleRoot || obj.Parent == HandleIngress):
+		return planKey{}, false
+	case typ == "filter":
+		if _, ok := obj.Block(); ok {
+			return planKey{}, false
+		}
+	}
+	parent := obj.Parent
+	if typ == "class" && (parent == HandleRoot || parent&0xFFFF == 0) {
+		// Top level classes belong to their qdisc.
+		parent = obj.Handle
+	}
+	class := planKey{typ: "class", ifindex: obj.Ifindex, handle: parent}
+	if parent&0xFFFF != 0 && classes[class] {
+		return class, true
+	}
+	// The per queue qdiscs of mq and the filters of clsact are attached to a
+	// minor of the qdisc, that is not a class.
+	maj, _ := core.SplitHandle(parent)
+	return planKey{typ: "qdisc", ifindex: obj.Ifindex, handle: core.BuildHandle(maj, 0)}, true
+}
+
+// planObject returns a copy of obj without the fields, that are only
+// reported by the kernel and refused in requests.
+func planObject(obj *Object) Object {
+	c := obj.Copy()
+	c.Stats, c.Stats2, c.XStats, c.RawStats = nil, nil, nil, nil
+	c.ExtWarnMsg = ""
+	return *c
+}
+
+// planIndex returns the keys of objs and the keys of their classes. It fails, if
+// an object is contained more than once.
+func planIndex(objs []Object) ([]planKey, map[planKey]bool, error) {
+	keys := make([]planKey, len(objs))
+	seen := make(map[planKey]bool, len(objs))
+	classes := make(map[planKey]bool)
+	for i := range objs {
+		key := planKeyOf(&objs[i])
+		if seen[key] {
+			return nil, nil, fmt.Errorf("%s is contained multiple times: %w", objs[i].String(), ErrInvalidArg)
+		}
+		seen[key] = true
+		if key.typ == "class" {
+			classes[key] = true
+		}
+		keys[i] = key
+	}
+	return keys, classes, nil
+}
+
+// validatePlanObject checks, that obj can be identified in later dumps.
+func validatePlanObject(obj *Object) error {
+	switch objectType(obj) {
+	case "qdisc":
+		if obj.Handle == 0 {
+			return fmt.Errorf("qdisc %s without handle: %w", obj.Kind, ErrInvalidArg)
+		}
+	case "filter":
+		if _, ok := obj.Block(); ok {
+			return nil
+		}
+		if obj.Handle == 0 || obj.Info>>16 == 0 {
+			return fmt.Errorf("filter %s without handle or priority: %w", obj.Kind, ErrInvalidArg)
+		}
+	}
+	if obj.Ifindex == 0 {
+		return fmt.Errorf("%s %s: %w", objectType(obj), obj.Kind, ErrInvalidDev)
+	}
+	return nil
+}
+
+// planParent returns the parent of obj and HandleRoot for top level classes,
+// which may refer to their qdisc instead.
+func planParent(obj *Object) uint32 {
+	if objectType(obj) == "class" && obj.Parent&0xFFFF == 0 {
+		return HandleRoot
+	}
+	return obj.Parent
+}
+
+// planEqual reports whether the current object cur has the configuration of
+// want. An unset chain of a filter is the chain 0, that the kernel reports.
+func planEqual(cur, want *Object) bool {
+	if want.Chain == nil && cur.Chain != nil && *cur.Chain == 0 {
+		tmp := *want
+		tmp.Chain = cur.Chain
+		return Equal(cur, &tmp)
+	}
+	return Equal(cur, want)
+}
+
+// Plan returns the steps, that change the qdiscs, classes and filters current,
+// like they are dumped from the kernel, into desired. Objects of current, that
+// are not desired, are deleted, so that current has to contain only the
+// objects, that are managed. Qdiscs without handle, like the default qdiscs
+// of the kernel, are never deleted. Desired qdiscs and filters need a handle
+// and filters a priority, so that they can be identified.
+//
+// Objects, that are equal in current and desired, are left untouched and
+// changed objects are replaced. If the kind or parent of a qdisc or class
+// change, it is deleted and added again together with the objects beneath
+// it, as the kernel can not change them in place. The deletes come first,
+// with the objects beneath a qdisc or class before it. The adds and replaces
+// follow with parents before their children and filters last.
+func Plan(current, desired []Object) ([]Step, error) {
+	for i := range desired {
+		if err := validatePlanObject(&desired[i]); err != nil {
+			return nil, err
+		}
+	}
+	curKeys, curClasses, err := planIndex(current)
+	if err != nil {
+		return nil, err
+	}
+	wantKeys, _, err := planIndex(desired)
+	if err != nil {
+		return nil, err
+	}
+	curIndex := make(map[planKey]int, len(current))
+	for i, key := range curKeys {
+		curIndex[key] = i
+	}
+
+	// gone contains the current objects, that are deleted explicitly or
+	// together with their owner.
+	gone := make(map[planKey]bool)
+	wanted := make(map[planKey]bool, len(desired))
+	for i, key := range wantKeys {
+		wanted[key] = true
+		j, ok := curIndex[key]
+		if !ok {
+			continue
+		}
+		cur, want := &current[j], &desired[i]
+		if cur.Kind != want.Kind || key.typ != "filter" && planParent(cur) != planParent(want) {
+			gone[key] = true
+		}
+	}
+	for _, key := range curKeys {
+		if !wanted[key] {
+			gone[key] = true
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for i, key := range curKeys {
+			if gone[key] {
+				continue
+			}
+			if owner, ok := ownerKey(&current[i], curClasses); ok && gone[owner] {
+				gone[key] = true
+				changed = true
+			}
+		}
+	}
+
+	var steps []Step
+	var deleted []Object
+	for i, key := range curKeys {
+		if gone[key] && !(key.typ == "qdisc" && key.handle == 0) {
+			deleted = append(deleted, planObject(&current[i]))
+		}
+	}
+	ordered, err := deletionOrder(deleted)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range ordered {
+		steps = append(steps, Step{Op: planOperations[objectType(&obj)].del, Object: obj})
+	}
+
+	addOrder, err := additionOrder(desired)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range addOrder {
+		want := &desired[i]
+		key := wantKeys[i]
+		ops := planOperations[key.typ]
+		j, exists := curIndex[key]
+		switch {
+		case !exists || gone[key]:
+			steps = append(steps, Step{Op: ops.add, Object: planObject(want)})
+		case !planEqual(&current[j], want):
+			previous := planObject(&current[j])
+			steps = append(steps, Step{Op: ops.replace, Object: planObject(want), Previous: &previous})
+		}
+	}
+	return steps, nil
+}
+
+// additionOrder returns the indexes of objs in the order, in which they can
+// be added: qdiscs and classes before their children and filters last.
+func additionOrder(objs []Object) ([]int, error) {
+	var qdiscs, classes []Object
+	var qdiscIndex, classIndex, filterIndex []int
+	for i := range objs {
+		switch objectType(&objs[i]) {
+		case "filter":
+			filterIndex = append(filterIndex, i)
+		case "class":
+			classes = append(classes, objs[i])
+			classIndex = append(classIndex, i)
+		default:
+			qdiscs = append(qdiscs, objs[i])
+			qdiscIndex = append(qdiscIndex, i)
+		}
+	}
+	tree, err := BuildTree(qdiscs, classes)
+	if err != nil {
+		return nil, err
+	}
+	// The nodes of the tree hold copies, so they are mapped back by their key.
+	index := make(map[planKey]int, len(qdiscIndex)+len(classIndex))
+	for _, i := range append(qdiscIndex, classIndex...) {
+		index[planKeyOf(&objs[i])] = i
+	}
+
+	var ordered []int
+	visit := func(depth int, n *Node) error {
+		ordered = append(ordered, index[planKeyOf(&n.Object)])
+		return nil
+	}
+	tree.Walk(visit)
+	for _, orphan := range tree.Orphans {
+		orphan.Walk(visit)
+	}
+	return append(ordered, filterIndex...), nil
+}
+
+// Apply performs steps, like they are returned by Plan, in their order. If a
+// step fails, the steps, that were already performed, are reverted in reverse
+// order and the error of the step is returned. Adding an object, that
+// exists, replaces it and deleting an object, that does not exist, succeeds,
+// so that a plan can be applied again after a partial failure. Objects, that
+// were replaced this way, can not be restored.
+func Apply(tcSocket *Tc, steps []Step) error {
+	if tcSocket == nil {
+		return ErrNoArg
+	}
+	var undo []Step
+	for i := range steps {
+		step := steps[i]
+		revert, err := applyStep(tcSocket, step)
+		if err != nil {
+			err = fmt.Errorf("could not %s: %w", step.String(), err)
+			for j := len(undo) - 1; j >= 0; j-- {
+				if _, rerr := applyStep(tcSocket, undo[j]); rerr != nil {
+					err = concatError(err, fmt.Errorf("could not revert with %s: %v", undo[j].String(), rerr))
+				}
+			}
+			return err
+		}
+		if revert != nil {
+			undo = append(undo, *revert)
+		}
+	}
+	return nil
+}
+
+// applyStep performs step and returns the step, that reverts it, if it
+// changed an object, that can be restored.
+func applyStep(tcSocket *Tc, step Step) (*Step, error) {
+	ops := planOperations[objectType(&step.Object)]
+	if step.Op != ops.add && step.Op != ops.replace && step.Op != ops.del {
+		return nil, fmt.Errorf("operation %d for %s: %w", step.Op, step.Object.String(), ErrInvalidArg)
+	}
+	fn := stepFuncs[step.Op]
+	obj := step.Object.Copy()
+	err := fn(tcSocket, obj)
+	switch {
+	case err == nil:
+	case step.Op == ops.add && errors.Is(err, syscall.EEXIST):
+		// The previous configuration is unknown.
+		return nil, stepFuncs[ops.replace](tcSocket, obj)
+	case step.Op == ops.del && errors.Is(err, syscall.ENOENT):
+		return nil, nil
+	default:
+		return nil, err
+	}
+
+	switch step.Op {
+	case ops.add:
+		return &Step{Op: ops.del, Object: step.Object}, nil
+	case ops.del:
+		return &Step{Op: ops.add, Object: step.Object}, nil
+	}
+	if step.Previous == nil {
+		return nil, nil
+	}
+	return &Step{Op: ops.replace, Object: *step.Previous, Previous: &step.Object}, nil
+}
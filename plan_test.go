@@ -0,0 +1,247 @@
+package tc
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/florianl/go-tc/internal/unix"
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nltest"
+)
+
+// planObjects returns a root htb qdisc with a class, a leaf qdisc beneath
+// the class and a filter, that classifies into the class.
+func planObjects() (root, class, leaf, filter Object) {
+	root = Object{Msg: Msg{Family: unix.AF_UNSPEC, Ifindex: 2, Handle: 0x10000, Parent: HandleRoot},
+		Attribute: Attribute{Kind: "htb", Htb: &Htb{Init: &HtbGlob{Version: 3, Defcls: 0x10}}}}
+	class = Object{Msg: Msg{Family: unix.AF_UNSPEC, Ifindex: 2, Handle: 0x10010, Parent: 0x10000},
+		Attribute: Attribute{Kind: "htb", Htb: &Htb{Parms: &HtbOpt{Rate: RateSpec{Rate: 125000},
+			Ceil: RateSpec{Rate: 125000}, Buffer: 0xFFFF, Cbuffer: 0xFFFF}}}}
+	leaf = Object{Msg: Msg{Family: unix.AF_UNSPEC, Ifindex: 2, Handle: 0x100000, Parent: 0x10010},
+		Attribute: Attribute{Kind: "fq_codel", FqCodel: &FqCodel{Limit: uint32Ptr(1000)}}}
+	filter = Object{Msg: Msg{Family: unix.AF_UNSPEC, Ifindex: 2, Handle: 0x1, Parent: 0x10000, Info: 0x10300},
+		Attribute: Attribute{Kind: "matchall", Matchall: &Matchall{ClassID: uint32Ptr(0x10010)}}}
+	return root, class, leaf, filter
+}
+
+func TestPlan(t *testing.T) {
+	root, class, leaf, filter := planObjects()
+
+	// The kernel reports statistics and the chain of filters.
+	dumped := func(obj Object) Object {
+		c := *obj.Copy()
+		c.Stats = &Stats{Packets: 42}
+		if objectType(&c) == "filter" {
+			c.Chain = uint32Ptr(0)
+		}
+		return c
+	}
+	// Deletes carry the dumped filter without its statistics.
+	chained := dumped(filter)
+	faster := *class.Copy()
+	faster.Htb.Parms.Rate.Rate = 250000
+	fqCodel := Object{Msg: root.Msg, Attribute: Attribute{Kind: "fq_codel", FqCodel: &FqCodel{Limit: uint32Ptr(1000)}}}
+	fq := Object{Msg: leaf.Msg, Attribute: Attribute{Kind: "fq"}}
+	otherRoot := *root.Copy()
+	otherRoot.Handle = 0x20000
+	defaultRoot := Object{Msg: Msg{Ifindex: 2, Parent: HandleRoot}, Attribute: Attribute{Kind: "pfifo_fast"}}
+
+	tests := map[string]struct {
+		current []Object
+		desired []Object
+		want    []Step
+		err     error
+	}{
+		"no changes": {
+			current: []Object{dumped(root), dumped(class), dumped(leaf), dumped(filter)},
+			desired: []Object{root, class, leaf, filter},
+		},
+		"add in dependency order": {
+			current: []Object{defaultRoot},
+			desired: []Object{filter, leaf, class, root},
+			want: []Step{{Op: OpQdiscAdd, Object: root}, {Op: OpClassAdd, Object: class},
+				{Op: OpQdiscAdd, Object: leaf}, {Op: OpFilterAdd, Object: filter}},
+		},
+		"replace": {
+			current: []Object{dumped(root), dumped(class), dumped(leaf)},
+			desired: []Object{root, faster, leaf},
+			want:    []Step{{Op: OpClassReplace, Object: faster, Previous: &class}},
+		},
+		"delete in reverse order": {
+			current: []Object{dumped(root), dumped(class), dumped(leaf), dumped(filter)},
+			desired: []Object{root},
+			want: []Step{{Op: OpFilterDelete, Object: planObject(&chained)}, {Op: OpQdiscDelete, Object: leaf},
+				{Op: OpClassDelete, Object: class}},
+		},
+		"change of kind": {
+			current: []Object{dumped(fqCodel)},
+			desired: []Object{root, class},
+			want: []Step{{Op: OpQdiscDelete, Object: fqCodel}, {Op: OpQdiscAdd, Object: root},
+				{Op: OpClassAdd, Object: class}},
+		},
+		"objects beneath a recreated qdisc": {
+			current: []Object{dumped(root), dumped(class), dumped(fq), dumped(filter)},
+			desired: []Object{root, class, leaf, filter},
+			want:    []Step{{Op: OpQdiscDelete, Object: fq}, {Op: OpQdiscAdd, Object: leaf}},
+		},
+		"recreated root": {
+			current: []Object{dumped(otherRoot)},
+			desired: []Object{root, class, filter},
+			want: []Step{{Op: OpQdiscDelete, Object: otherRoot}, {Op: OpQdiscAdd, Object: root},
+				{Op: OpClassAdd, Object: class}, {Op: OpFilterAdd, Object: filter}},
+		},
+		"qdisc without handle": {
+			desired: []Object{defaultRoot},
+			err:     ErrInvalidArg,
+		},
+		"filter without priority": {
+			desired: []Object{{Msg: Msg{Ifindex: 2, Handle: 0x1, Parent: 0x10000},
+				Attribute: Attribute{Kind: "matchall"}}},
+			err: ErrInvalidArg,
+		},
+		"without device": {
+			desired: []Object{{Msg: Msg{Handle: 0x10000, Parent: HandleRoot}, Attribute: Attribute{Kind: "htb"}}},
+			err:     ErrInvalidDev,
+		},
+		"duplicate": {
+			desired: []Object{root, class, root},
+			err:     ErrInvalidArg,
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			steps, err := Plan(testcase.current, testcase.desired)
+			if !errors.Is(err, testcase.err) {
+				t.Fatalf("expected %v but got %v", testcase.err, err)
+			}
+			if diff := cmp.Diff(testcase.want, steps); diff != "" {
+				t.Fatalf("steps missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// planRequest identifies a request of Apply.
+type planRequest struct {
+	Type   netlink.HeaderType
+	Flags  netlink.HeaderFlags
+	Handle uint32
+}
+
+// planConn acknowledges all requests and fails the requests, for which fail
+// returns an errno.
+func planConn(t *testing.T, fail func(planRequest) syscall.Errno) (*Tc, *[]planRequest) {
+	t.Helper()
+	var requests []planRequest
+	c := &Tc{
+		con: nltest.Dial(func(req []netlink.Message) ([]netlink.Message, error) {
+			msg, _, err := unmarshalTcmsg(req[0].Data)
+			if err != nil {
+				t.Fatalf("could not decode Msg: %v", err)
+			}
+			r := planRequest{Type: req[0].Header.Type, Flags: req[0].Header.Flags &^ (netlink.Request | netlink.Acknowledge),
+				Handle: msg.Handle}
+			requests = append(requests, r)
+			if errno := fail(r); errno != 0 {
+				return nltest.Error(int(errno), req)
+			}
+			return []netlink.Message{{Header: netlink.Header{Type: netlink.Error}, Data: make([]byte, 4)}}, nil
+		}),
+	}
+	return c, &requests
+}
+
+func TestApply(t *testing.T) {
+	root, class, leaf, filter := planObjects()
+	faster := *class.Copy()
+	faster.Htb.Parms.Rate.Rate = 250000
+	steps := []Step{
+		{Op: OpQdiscDelete, Object: leaf},
+		{Op: OpQdiscAdd, Object: root},
+		{Op: OpClassReplace, Object: faster, Previous: &class},
+		{Op: OpFilterAdd, Object: filter},
+	}
+	add := netlink.Create | netlink.Excl
+	replace := netlink.Create | netlink.Replace
+
+	tests := map[string]struct {
+		fail func(planRequest) syscall.Errno
+		want []planRequest
+		err  error
+	}{
+		"success": {
+			want: []planRequest{{unix.RTM_DELQDISC, 0, 0x100000}, {unix.RTM_NEWQDISC, add, 0x10000},
+				{unix.RTM_NEWTCLASS, netlink.Create, 0x10010}, {unix.RTM_NEWTFILTER, add, 0x1}},
+		},
+		"rollback": {
+			fail: func(r planRequest) syscall.Errno {
+				if r.Type == unix.RTM_NEWTFILTER {
+					return syscall.EINVAL
+				}
+				return 0
+			},
+			// The class gets its previous rate, the qdisc is deleted and the
+			// leaf qdisc is added again.
+			want: []planRequest{{unix.RTM_DELQDISC, 0, 0x100000}, {unix.RTM_NEWQDISC, add, 0x10000},
+				{unix.RTM_NEWTCLASS, netlink.Create, 0x10010}, {unix.RTM_NEWTFILTER, add, 0x1},
+				{unix.RTM_NEWTCLASS, netlink.Create, 0x10010}, {unix.RTM_DELQDISC, 0, 0x10000},
+				{unix.RTM_NEWQDISC, add, 0x100000}},
+			err: syscall.EINVAL,
+		},
+		"idempotent": {
+			fail: func(r planRequest) syscall.Errno {
+				switch {
+				case r.Type == unix.RTM_DELQDISC:
+					return syscall.ENOENT
+				case r.Type == unix.RTM_NEWQDISC && r.Flags == add:
+					return syscall.EEXIST
+				}
+				return 0
+			},
+			// The existing qdisc is replaced and the missing leaf qdisc is not
+			// added again on rollback.
+			want: []planRequest{{unix.RTM_DELQDISC, 0, 0x100000}, {unix.RTM_NEWQDISC, add, 0x10000},
+				{unix.RTM_NEWQDISC, replace, 0x10000},
+				{unix.RTM_NEWTCLASS, netlink.Create, 0x10010}, {unix.RTM_NEWTFILTER, add, 0x1}},
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			fail := testcase.fail
+			if fail == nil {
+				fail = func(planRequest) syscall.Errno { return 0 }
+			}
+			tcSocket, requests := planConn(t, fail)
+			defer tcSocket.Close()
+
+			if err := Apply(tcSocket, steps); !errors.Is(err, testcase.err) {
+				t.Fatalf("expected %v but got %v", testcase.err, err)
+			}
+			if diff := cmp.Diff(testcase.want, *requests); diff != "" {
+				t.Fatalf("requests missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("no changes", func(t *testing.T) {
+		tcSocket, requests := planConn(t, func(planRequest) syscall.Errno { return syscall.EINVAL })
+		defer tcSocket.Close()
+		if err := Apply(tcSocket, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(*requests) != 0 {
+			t.Fatalf("expected no requests but got %v", *requests)
+		}
+	})
+	t.Run("invalid operation", func(t *testing.T) {
+		tcSocket, _ := planConn(t, func(planRequest) syscall.Errno { return 0 })
+		defer tcSocket.Close()
+		if err := Apply(tcSocket, []Step{{Op: OpFilterAdd, Object: root}}); !errors.Is(err, ErrInvalidArg) {
+			t.Fatalf("expected ErrInvalidArg but got %v", err)
+		}
+	})
+}
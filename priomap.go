@@ -0,0 +1,55 @@
+package tc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Priomap maps the 16 Linux packet priorities (skb->priority) to bands of prio and
+// pfifo_fast or to traffic classes of mqprio.
+type Priomap [16]uint8 // TC_PRIO_MAX + 1 = 16
+
+// DefaultPriomap returns the priority map iproute2 and the kernel use by default.
+func DefaultPriomap() Priomap {
+	// iproute2/tc/q_prio.c:prio_parse_opt()
+	return Priomap{1, 2, 2, 2, 1, 2, 0, 0, 1, 1, 1, 1, 1, 1, 1, 1}
+}
+
+// SetBand maps the packet priority prio to band.
+func (p *Priomap) SetBand(prio, band int) error {
+	if prio < 0 || prio >= len(p) {
+		return fmt.Errorf("Priomap: priority %d: %w", prio, ErrInvalidArg)
+	}
+	// TCQ_PRIO_BANDS and TC_QOPT_MAX_QUEUE limit the bands to 16
+	if band < 0 || band >= 16 {
+		return fmt.Errorf("Priomap: band %d: %w", band, ErrInvalidArg)
+	}
+	p[prio] = uint8(band)
+	return nil
+}
+
+// Band returns the band the packet priority prio is mapped to.
+func (p Priomap) Band(prio int) int {
+	return int(p[prio])
+}
+
+// Validate returns an error, if a priority is mapped to a band beyond bands.
+func (p Priomap) Validate(bands uint32) error {
+	for prio, band := range p {
+		if uint32(band) >= bands {
+			return fmt.Errorf("Priomap: priority %d is mapped to band %d, but only %d bands exist: %w",
+				prio, band, bands, ErrInvalidArg)
+		}
+	}
+	return nil
+}
+
+// String returns the priority map in the format of `tc qdisc show`.
+func (p Priomap) String() string {
+	bands := make([]string, 0, len(p))
+	for _, band := range p {
+		bands = append(bands, strconv.Itoa(int(band)))
+	}
+	return strings.Join(bands, " ")
+}
@@ -0,0 +1,50 @@
+package tc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPriomap(t *testing.T) {
+	p := DefaultPriomap()
+	if got := p.String(); got != "1 2 2 2 1 2 0 0 1 1 1 1 1 1 1 1" {
+		t.Fatalf("unexpected default priomap: %s", got)
+	}
+	if err := p.Validate(3); err != nil {
+		t.Fatalf("default priomap is not valid for 3 bands: %v", err)
+	}
+	if err := p.Validate(2); !errors.Is(err, ErrInvalidArg) {
+		t.Fatalf("expected ErrInvalidArg but got %v", err)
+	}
+
+	for prio := 0; prio < 16; prio++ {
+		if err := p.SetBand(prio, prio%4); err != nil {
+			t.Fatalf("could not set band of priority %d: %v", prio, err)
+		}
+	}
+	if got := p.String(); got != "0 1 2 3 0 1 2 3 0 1 2 3 0 1 2 3" {
+		t.Fatalf("unexpected priomap: %s", got)
+	}
+	if p.Band(7) != 3 {
+		t.Fatalf("expected band 3 for priority 7 but got %d", p.Band(7))
+	}
+	if err := p.Validate(3); !errors.Is(err, ErrInvalidArg) {
+		t.Fatalf("expected ErrInvalidArg but got %v", err)
+	}
+
+	tests := map[string]struct {
+		prio, band int
+	}{
+		"negative priority": {prio: -1, band: 0},
+		"priority 16":       {prio: 16, band: 0},
+		"negative band":     {prio: 0, band: -1},
+		"band 16":           {prio: 0, band: 16},
+	}
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := p.SetBand(testcase.prio, testcase.band); !errors.Is(err, ErrInvalidArg) {
+				t.Fatalf("expected ErrInvalidArg but got %v", err)
+			}
+		})
+	}
+}
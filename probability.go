@@ -0,0 +1,54 @@
+package tc
+
+import (
+	"fmt"
+	"math"
+)
+
+// validPercentage returns an error if p is not a valid percentage in the range of [0, 100].
+func validPercentage(p float64) error {
+	if math.IsNaN(p) || p < 0 || p > 100 {
+		return fmt.Errorf("percentage %v not in range [0, 100]: %w", p, ErrInvalidArg)
+	}
+	return nil
+}
+
+// Percentage implements iproute2/tc/q_netem.c:get_percent().
+// It converts a percentage in the range of [0, 100] into the fixed point
+// representation as fraction of math.MaxUint32, as used by netem for loss,
+// duplicate, corrupt and reorder probabilities. The value is rounded to the
+// nearest integer with ties to even, like rint(3) does, so Percentage(10)
+// returns 429496730 as iproute2 does for "10%".
+func Percentage(p float64) (uint32, error) {
+	if err := validPercentage(p); err != nil {
+		return 0, err
+	}
+	return uint32(math.RoundToEven(p / 100. * math.MaxUint32)), nil
+}
+
+// PercentageOf is the inverse of Percentage().
+// It converts a fixed point fraction of math.MaxUint32 into a percentage.
+func PercentageOf(v uint32) float64 {
+	return float64(v) / math.MaxUint32 * 100.
+}
+
+// RedProbability implements the conversion of the probability argument in
+// iproute2/tc/q_red.c. It converts a probability in the range of [0, 1] into
+// the fixed point representation as fraction of 2^32, as used by RED and CHOKe
+// for MaxP. As in iproute2 the value is truncated. A probability of 1 is
+// clamped to math.MaxUint32.
+func RedProbability(p float64) (uint32, error) {
+	if math.IsNaN(p) || p < 0 || p > 1 {
+		return 0, fmt.Errorf("probability %v not in range [0, 1]: %w", p, ErrInvalidArg)
+	}
+	v := p * math.Pow(2, 32)
+	if v > math.MaxUint32 {
+		return math.MaxUint32, nil
+	}
+	return uint32(v), nil
+}
+
+// RedProbabilityOf is the inverse of RedProbability().
+func RedProbabilityOf(v uint32) float64 {
+	return float64(v) / math.Pow(2, 32)
+}
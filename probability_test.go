@@ -0,0 +1,112 @@
+package tc
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestPercentage(t *testing.T) {
+	tests := map[string]struct {
+		p   float64
+		val uint32
+		err error
+	}{
+		"0%":    {p: 0, val: 0},
+		"1%":    {p: 1, val: 42949673},
+		"10%":   {p: 10, val: 429496730},
+		"0.5%":  {p: 0.5, val: 21474836},
+		"50%":   {p: 50, val: 2147483648},
+		"100%":  {p: 100, val: math.MaxUint32},
+		"101%":  {p: 101, err: ErrInvalidArg},
+		"-1%":   {p: -1, err: ErrInvalidArg},
+		"NaN":   {p: math.NaN(), err: ErrInvalidArg},
+		"+Inf":  {p: math.Inf(1), err: ErrInvalidArg},
+		"1e-9%": {p: 1e-9, val: 0},
+	}
+	for name, testcase := range tests {
+		testcase := testcase
+		t.Run(name, func(t *testing.T) {
+			val, err := Percentage(testcase.p)
+			if !errors.Is(err, testcase.err) {
+				t.Fatalf("expected %v but got %v", testcase.err, err)
+			}
+			if val != testcase.val {
+				t.Fatalf("expected %d but got %d", testcase.val, val)
+			}
+			if err != nil {
+				return
+			}
+			if p := PercentageOf(val); math.Abs(p-testcase.p) > 1e-7 {
+				t.Fatalf("expected %v but got %v", testcase.p, p)
+			}
+		})
+	}
+}
+
+func TestRedProbability(t *testing.T) {
+	tests := map[string]struct {
+		p   float64
+		val uint32
+		err error
+	}{
+		"0":    {p: 0, val: 0},
+		"0.02": {p: 0.02, val: 85899345},
+		"0.5":  {p: 0.5, val: 2147483648},
+		"1":    {p: 1, val: math.MaxUint32},
+		"1.1":  {p: 1.1, err: ErrInvalidArg},
+		"-0.1": {p: -0.1, err: ErrInvalidArg},
+		"NaN":  {p: math.NaN(), err: ErrInvalidArg},
+	}
+	for name, testcase := range tests {
+		testcase := testcase
+		t.Run(name, func(t *testing.T) {
+			val, err := RedProbability(testcase.p)
+			if !errors.Is(err, testcase.err) {
+				t.Fatalf("expected %v but got %v", testcase.err, err)
+			}
+			if val != testcase.val {
+				t.Fatalf("expected %d but got %d", testcase.val, val)
+			}
+			if err != nil {
+				return
+			}
+			if p := RedProbabilityOf(val); math.Abs(p-testcase.p) > 1e-9 {
+				t.Fatalf("expected %v but got %v", testcase.p, p)
+			}
+		})
+	}
+}
+
+func TestProbabilitySetters(t *testing.T) {
+	var qopt NetemQopt
+	if err := qopt.SetLossPercent(10); err != nil {
+		t.Fatal(err)
+	}
+	if err := qopt.SetDuplicatePercent(1); err != nil {
+		t.Fatal(err)
+	}
+	if qopt.Loss != 429496730 || qopt.Duplicate != 42949673 {
+		t.Fatalf("unexpected NetemQopt: %#v", qopt)
+	}
+	if err := qopt.SetLossPercent(110); !errors.Is(err, ErrInvalidArg) {
+		t.Fatalf("expected ErrInvalidArg but got %v", err)
+	}
+	if qopt.Loss != 429496730 {
+		t.Fatalf("invalid input altered Loss: %d", qopt.Loss)
+	}
+
+	var reorder NetemReorder
+	if err := reorder.SetProbabilityPercent(25); err != nil || reorder.Probability != 1073741824 {
+		t.Fatalf("unexpected result %d: %v", reorder.Probability, err)
+	}
+	var corrupt NetemCorrupt
+	if err := corrupt.SetProbabilityPercent(-3); !errors.Is(err, ErrInvalidArg) {
+		t.Fatalf("expected ErrInvalidArg but got %v", err)
+	}
+
+	var red Red
+	if err := red.SetMaxProbability(0.02); err != nil || uint32Value(red.MaxP) != 85899345 {
+		t.Fatalf("unexpected result %d: %v", uint32Value(red.MaxP), err)
+	}
+}
@@ -0,0 +1,445 @@
+package tc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+	"github.com/mdlayher/netlink"
+)
+
+// ProbeKind selects, whether Probe checks a qdisc, a filter or an action.
+type ProbeKind int
+
+// Kinds of objects, that can be probed.
+const (
+	ProbeQdisc ProbeKind = iota
+	ProbeFilter
+	ProbeAction
+)
+
+func (k ProbeKind) String() string {
+	switch k {
+	case ProbeQdisc:
+		return "qdisc"
+	case ProbeFilter:
+		return "filter"
+	case ProbeAction:
+		return "action"
+	}
+	return fmt.Sprintf("ProbeKind(%d)", int(k))
+}
+
+// Kinds, that are probed by Capabilities. These are the kinds of the
+// registry in kinds.go.
+var (
+	probeQdiscs  = Kinds(ProbeQdisc)
+	probeFilters = Kinds(ProbeFilter)
+	probeActions = Kinds(ProbeAction)
+)
+
+// netlinkKinds maps the kinds of the registry, that the kernel knows under a
+// different name, to the name of the kernel.
+var netlinkKinds = map[string]string{
+	// cls_route registers itself as route.
+	"route4": "route",
+	// The attributes of defact are the ones of act_simple.
+	"defact": "simple",
+}
+
+// netlinkKind returns the name of kind, that is sent to the kernel.
+func netlinkKind(kind string) string {
+	if name, ok := netlinkKinds[kind]; ok {
+		return name
+	}
+	return kind
+}
+
+const (
+	// probeAttempts limits the number of times a probe is sent, if the kernel
+	// answers with EAGAIN after it loaded the module of a kind.
+	probeAttempts = 3
+)
+
+// probeDevices counts the scratch devices of this process, so that
+// concurrent probes do not conflict on the name.
+var probeDevices uint32
+
+// Capabilities summarizes the kinds of the kernel, that are probed by
+// Tc.Capabilities. A kind is set to true, if it is available.
+type Capabilities struct {
+	Qdiscs  map[string]bool
+	Filters map[string]bool
+	Actions map[string]bool
+}
+
+// capabilityCache holds the result of Tc.Capabilities, which is shared by
+// all copies of a Tc.
+type capabilityCache struct {
+	sync.Mutex
+	caps *Capabilities
+}
+
+// Probe reports whether the kernel supports kind as kindType. To not affect
+// any existing device, Probe creates a scratch dummy device, tries to add kind
+// without options to it and removes the device again. This requires
+// CAP_NET_ADMIN and the dummy module.
+//
+// Qdiscs are probed as root qdisc and filters on a clsact or, on kernels
+// without clsact, on an ingress qdisc. As no options are passed, the kernel
+// refuses most kinds with EINVAL or EOPNOTSUPP after it found them, which
+// shows that the kind is available. Only ENOENT indicates an unknown kind.
+// Actions are not bound to the scratch device. An action, that the kernel
+// accepts, is deleted again. Kinds, that this package names differently
+// than the kernel, like route4, are probed under the name of the kernel.
+func Probe(tcSocket *Tc, kind string, kindType ProbeKind) (bool, error) {
+	if len(kind) == 0 {
+		return false, fmt.Errorf("probe: kind is missing: %w", ErrNoArg)
+	}
+	if err := validateKind(kind); err != nil {
+		return false, fmt.Errorf("probe: %w", err)
+	}
+	if kindType < ProbeQdisc || kindType > ProbeAction {
+		return false, fmt.Errorf("probe: %s: %w", kindType, ErrInvalidArg)
+	}
+	p, err := newProber(tcSocket)
+	if err != nil {
+		return false, err
+	}
+	defer p.close()
+	return p.probe(kind, kindType)
+}
+
+// Capabilities probes the qdiscs, filters and actions, that are returned by
+// Kinds, like Probe does.
+// The result is cached, so that later calls do not probe the kernel again.
+// Errors are not cached.
+func (tc *Tc) Capabilities() (*Capabilities, error) {
+	if tc.caps == nil {
+		return tc.probeCapabilities()
+	}
+	tc.caps.Lock()
+	defer tc.caps.Unlock()
+	if tc.caps.caps != nil {
+		return tc.caps.caps, nil
+	}
+	caps, err := tc.probeCapabilities()
+	if err != nil {
+		return nil, err
+	}
+	tc.caps.caps = caps
+	return caps, nil
+}
+
+func (tc *Tc) probeCapabilities() (*Capabilities, error) {
+	p, err := newProber(tc)
+	if err != nil {
+		return nil, err
+	}
+	defer p.close()
+
+	caps := &Capabilities{
+		Qdiscs:  make(map[string]bool, len(probeQdiscs)),
+		Filters: make(map[string]bool, len(probeFilters)),
+		Actions: make(map[string]bool, len(probeActions)),
+	}
+	for _, set := range []struct {
+		kindType ProbeKind
+		kinds    []string
+		result   map[string]bool
+	}{
+		{ProbeQdisc, probeQdiscs, caps.Qdiscs},
+		{ProbeFilter, probeFilters, caps.Filters},
+		{ProbeAction, probeActions, caps.Actions},
+	} {
+		for _, kind := range set.kinds {
+			ok, err := p.probe(kind, set.kindType)
+			if err != nil {
+				return nil, fmt.Errorf("probe %s %s: %w", set.kindType, kind, err)
+			}
+			set.result[kind] = ok
+		}
+	}
+	return caps, nil
+}
+
+// prober probes kinds on a scratch dummy device.
+type prober struct {
+	tc      *Tc
+	name    string
+	ifindex uint32
+	// filterParent is the parent of probed filters. It is set, when the
+	// first filter is probed.
+	filterParent uint32
+}
+
+// newProber creates the scratch device of a prober.
+func newProber(tc *Tc) (*prober, error) {
+	n := atomic.AddUint32(&probeDevices, 1)
+	p := &prober{
+		tc:   tc,
+		name: fmt.Sprintf("tcprobe%08x", uint32(os.Getpid())<<8|n&0xFF),
+	}
+
+	linkInfo, err := marshalAttributes([]tcOption{
+		{Interpretation: vtString, Type: unix.IFLA_INFO_KIND, Data: "dummy"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tc.action(unix.RTM_NEWLINK, netlink.Create|netlink.Excl, unix.IfInfomsg{
+		Family: unix.AF_UNSPEC,
+	}, []tcOption{
+		{Interpretation: vtString, Type: unix.IFLA_IFNAME, Data: p.name},
+		{Interpretation: vtNested, Type: unix.IFLA_LINKINFO, Data: linkInfo},
+	}); err != nil {
+		return nil, fmt.Errorf("probe: could not create device %s: %w", p.name, err)
+	}
+
+	if p.ifindex, err = p.lookup(); err != nil {
+		p.close()
+		return nil, fmt.Errorf("probe: could not get index of device %s: %w", p.name, err)
+	}
+	return p, nil
+}
+
+// lookup returns the index of the scratch device.
+func (p *prober) lookup() (uint32, error) {
+	req, err := marshalMessage(unix.RTM_GETLINK, 0, unix.IfInfomsg{
+		Family: unix.AF_UNSPEC,
+	}, []tcOption{
+		{Interpretation: vtString, Type: unix.IFLA_IFNAME, Data: p.name},
+	})
+	if err != nil {
+		return 0, err
+	}
+	// The device is looked up by name and the reply contains no ACK.
+	req.Header.Flags = netlink.Request
+	msgs, err := p.tc.query(req)
+	if err != nil {
+		return 0, err
+	}
+	for _, msg := range msgs {
+		// struct ifinfomsg is 16 bytes long and ifi_index at offset 4.
+		if msg.Header.Type == unix.RTM_NEWLINK && len(msg.Data) >= 16 {
+			return nativeEndian.Uint32(msg.Data[4:8]), nil
+		}
+	}
+	return 0, ErrInvalidDev
+}
+
+// close removes the scratch device together with everything probed on it.
+func (p *prober) close() error {
+	return p.tc.action(unix.RTM_DELLINK, 0, unix.IfInfomsg{
+		Family: unix.AF_UNSPEC,
+		Index:  int32(p.ifindex),
+	}, []tcOption{
+		{Interpretation: vtString, Type: unix.IFLA_IFNAME, Data: p.name},
+	})
+}
+
+// probe sends the probe for kind and interprets the answer of the kernel.
+func (p *prober) probe(kind string, kindType ProbeKind) (bool, error) {
+	var err error
+	kind = netlinkKind(kind)
+	for i := 0; i < probeAttempts; i++ {
+		switch kindType {
+		case ProbeQdisc:
+			err = p.qdisc(kind)
+		case ProbeFilter:
+			err = p.filter(kind)
+		case ProbeAction:
+			err = p.action(kind)
+		default:
+			return false, fmt.Errorf("probe: %s: %w", kindType, ErrInvalidArg)
+		}
+		if !errors.Is(err, syscall.EAGAIN) {
+			break
+		}
+	}
+	return probeResult(err)
+}
+
+// probeResult interprets the answer of the kernel to a probe.
+func probeResult(err error) (bool, error) {
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, syscall.ENOENT):
+		// Neither the kernel nor one of its modules provides the kind.
+		return false, nil
+	case errors.Is(err, syscall.EINVAL), errors.Is(err, syscall.EOPNOTSUPP),
+		errors.Is(err, syscall.EEXIST), errors.Is(err, syscall.EBUSY):
+		// The kernel found the kind and refused the missing options or the
+		// scratch device.
+		return true, nil
+	}
+	return false, err
+}
+
+func (p *prober) qdisc(kind string) error {
+	msg := Msg{
+		Family:  unix.AF_UNSPEC,
+		Ifindex: p.ifindex,
+		Parent:  HandleRoot,
+	}
+	if err := p.tc.action(unix.RTM_NEWQDISC, netlink.Create|netlink.Excl, msg, []tcOption{
+		{Interpretation: vtString, Type: tcaKind, Data: kind},
+	}); err != nil {
+		return err
+	}
+	// Restore the default qdisc for the next probe.
+	return p.tc.action(unix.RTM_DELQDISC, 0, msg, nil)
+}
+
+func (p *prober) filter(kind string) error {
+	parent, err := p.filterParentHandle()
+	if err != nil {
+		return err
+	}
+	msg := Msg{
+		Family:  unix.AF_UNSPEC,
+		Ifindex: p.ifindex,
+		Parent:  parent,
+		Info:    core.BuildFilterInfo(1, core.EthPAll),
+	}
+	if err := p.tc.action(unix.RTM_NEWTFILTER, netlink.Create|netlink.Excl, msg, []tcOption{
+		{Interpretation: vtString, Type: tcaKind, Data: kind},
+	}); err != nil {
+		return err
+	}
+	return p.tc.action(unix.RTM_DELTFILTER, 0, msg, nil)
+}
+
+// filterParentHandle adds the qdisc, that holds the probed filters, to the
+// scratch device and returns its handle.
+func (p *prober) filterParentHandle() (uint32, error) {
+	if p.filterParent != 0 {
+		return p.filterParent, nil
+	}
+	for _, qdisc := range []struct {
+		kind   string
+		parent uint32
+	}{
+		{"clsact", HandleClsactIngress},
+		// clsact was added with Linux 4.5.
+		{"ingress", core.BuildHandle(0xFFFF, 0)},
+	} {
+		err := p.tc.action(unix.RTM_NEWQDISC, netlink.Create|netlink.Excl, Msg{
+			Family:  unix.AF_UNSPEC,
+			Ifindex: p.ifindex,
+			Handle:  core.BuildHandle(0xFFFF, 0),
+			Parent:  HandleIngress,
+		}, []tcOption{
+			{Interpretation: vtString, Type: tcaKind, Data: qdisc.kind},
+		})
+		if err == nil {
+			p.filterParent = qdisc.parent
+			return p.filterParent, nil
+		}
+		if !errors.Is(err, syscall.ENOENT) {
+			return 0, fmt.Errorf("could not add %s qdisc: %w", qdisc.kind, err)
+		}
+	}
+	return 0, fmt.Errorf("neither clsact nor ingress is available: %w", ErrNotImplemented)
+}
+
+func (p *prober) action(kind string) error {
+	tab, err := probeActionTab(kind, 0)
+	if err != nil {
+		return err
+	}
+	// Actions are not bound to the scratch device. To remove an action, that
+	// the kernel accepts, the actions of kind are compared before and after
+	// the probe.
+	existing, err := p.actionIndexes(tab)
+	if err != nil {
+		return err
+	}
+	// Without options, the kernel refuses the action after it found the kind.
+	if err := p.tc.action(unix.RTM_NEWACTION, netlink.Create|netlink.Excl, tcaMsg{
+		Family: unix.AF_UNSPEC,
+	}, []tcOption{
+		{Interpretation: vtBytes, Type: tcaRootTab, Data: tab},
+	}); err != nil {
+		return err
+	}
+	indexes, err := p.actionIndexes(tab)
+	if err != nil {
+		return fmt.Errorf("could not get probed %s action: %w", kind, err)
+	}
+	for index := range indexes {
+		if existing[index] {
+			continue
+		}
+		tab, err := probeActionTab(kind, index)
+		if err != nil {
+			return err
+		}
+		if err := p.tc.action(unix.RTM_DELACTION, 0, tcaMsg{
+			Family: unix.AF_UNSPEC,
+		}, []tcOption{
+			{Interpretation: vtBytes, Type: tcaRootTab, Data: tab},
+		}); err != nil {
+			return fmt.Errorf("could not delete probed %s action %d: %w", kind, index, err)
+		}
+	}
+	return nil
+}
+
+// probeActionTab returns the TCA_ROOT_TAB of a probe for the action kind.
+// A non zero index selects a single action.
+func probeActionTab(kind string, index uint32) ([]byte, error) {
+	options := []tcOption{
+		{Interpretation: vtString, Type: tcaActKind, Data: kind},
+	}
+	if index != 0 {
+		options = append(options, tcOption{Interpretation: vtUint32, Type: tcaActIndex, Data: index})
+	}
+	act, err := marshalAttributes(options)
+	if err != nil {
+		return nil, err
+	}
+	return marshalAttributes([]tcOption{
+		{Interpretation: vtBytes, Type: 1, Data: act},
+	})
+}
+
+// actionIndexes returns the indexes of the actions, that are selected by tab.
+func (p *prober) actionIndexes(tab []byte) (map[uint32]bool, error) {
+	req, err := marshalMessage(unix.RTM_GETACTION, 0, tcaMsg{
+		Family: unix.AF_UNSPEC,
+	}, []tcOption{
+		{Interpretation: vtBytes, Type: tcaRootTab, Data: tab},
+	})
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Flags = netlink.Request | netlink.Dump
+	indexes := make(map[uint32]bool)
+	err = p.tc.queryEach(req, func(msg netlink.Message) error {
+		if msg.Header.Type != unix.RTM_NEWACTION {
+			return nil
+		}
+		attrs, err := unmarshalStructAttrs(msg.Data, &tcaMsg{})
+		if err != nil {
+			return err
+		}
+		var actions []*Action
+		// Only the index is needed, so errors in the options, like the ones
+		// of kinds this package does not decode, are ignored.
+		_ = unmarshalRoot(attrs, &actions)
+		for _, act := range actions {
+			if act != nil && act.Index != 0 {
+				indexes[act.Index] = true
+			}
+		}
+		return nil
+	})
+	return indexes, err
+}
@@ -0,0 +1,415 @@
+package tc
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nltest"
+)
+
+// probeKernel answers probes like a kernel, that provides the kinds of
+// qdiscs, filters and actions. A kind maps to the errno, the kernel refuses
+// it with, or to 0, if it is accepted. Missing kinds are answered with ENOENT.
+type probeKernel struct {
+	qdiscs  map[string]syscall.Errno
+	filters map[string]syscall.Errno
+	actions map[string]syscall.Errno
+	// eagain is the number of probes of a kind, that are answered with EAGAIN
+	// until its module is loaded.
+	eagain map[string]int
+	// link is the answer to the creation of the scratch device.
+	link syscall.Errno
+	// created holds the kinds of the actions of the kernel by their index.
+	created map[uint32]string
+
+	links   map[string]bool
+	parents []uint32
+	probes  int
+}
+
+func (k *probeKernel) conn(t *testing.T) *Tc {
+	t.Helper()
+	if k.links == nil {
+		k.links = make(map[string]bool)
+	}
+	ack := []netlink.Message{{Header: netlink.Header{Type: netlink.Error}, Data: make([]byte, 4)}}
+
+	// answer calls accepted, if it is set and the kernel accepts kind.
+	answer := func(req []netlink.Message, kinds map[string]syscall.Errno, kind string, accepted func()) ([]netlink.Message, error) {
+		k.probes++
+		if k.eagain[kind] > 0 {
+			k.eagain[kind]--
+			return nltest.Error(int(syscall.EAGAIN), req)
+		}
+		errno, ok := kinds[kind]
+		if !ok {
+			errno = syscall.ENOENT
+		}
+		if errno != 0 {
+			return nltest.Error(int(errno), req)
+		}
+		if accepted != nil {
+			accepted()
+		}
+		return ack, nil
+	}
+
+	return &Tc{
+		con: nltest.Dial(func(req []netlink.Message) ([]netlink.Message, error) {
+			switch req[0].Header.Type {
+			case unix.RTM_NEWLINK, unix.RTM_GETLINK, unix.RTM_DELLINK:
+				name := kindString(probeAttribute(t, req[0].Data[16:], unix.IFLA_IFNAME))
+				switch req[0].Header.Type {
+				case unix.RTM_NEWLINK:
+					if k.link != 0 {
+						return nltest.Error(int(k.link), req)
+					}
+					k.links[name] = true
+				case unix.RTM_GETLINK:
+					if !k.links[name] {
+						return nltest.Error(int(syscall.ENODEV), req)
+					}
+					data := make([]byte, 16)
+					nativeEndian.PutUint32(data[4:8], 42)
+					return []netlink.Message{{Header: netlink.Header{Type: unix.RTM_NEWLINK}, Data: data}}, nil
+				case unix.RTM_DELLINK:
+					delete(k.links, name)
+				}
+				return ack, nil
+			case unix.RTM_NEWQDISC, unix.RTM_NEWTFILTER:
+				msg, attrs, err := unmarshalTcmsg(req[0].Data)
+				if err != nil {
+					t.Fatalf("could not decode Msg: %v", err)
+				}
+				if msg.Ifindex != 42 {
+					t.Fatalf("expected probe on the scratch device but got ifindex %d", msg.Ifindex)
+				}
+				kind := kindString(probeAttribute(t, attrs, tcaKind))
+				if req[0].Header.Type == unix.RTM_NEWTFILTER {
+					k.parents = append(k.parents, msg.Parent)
+					return answer(req, k.filters, kind, nil)
+				}
+				if msg.Parent == HandleIngress {
+					// qdisc for the filters
+					if msg.Handle != core.BuildHandle(0xFFFF, 0) {
+						t.Fatalf("expected handle ffff: for %s but got %x", kind, msg.Handle)
+					}
+					if _, ok := k.qdiscs[kind]; !ok {
+						return nltest.Error(int(syscall.ENOENT), req)
+					}
+					return ack, nil
+				}
+				return answer(req, k.qdiscs, kind, nil)
+			case unix.RTM_NEWACTION, unix.RTM_GETACTION, unix.RTM_DELACTION:
+				act := probeAttribute(t, req[0].Data[4:], tcaRootTab)
+				act = probeAttribute(t, act, 1)
+				kind := kindString(probeAttribute(t, act, tcaActKind))
+				switch req[0].Header.Type {
+				case unix.RTM_GETACTION:
+					return k.dumpActions(t, kind), nil
+				case unix.RTM_DELACTION:
+					index := nativeEndian.Uint32(probeAttribute(t, act, tcaActIndex))
+					if k.created[index] != kind {
+						return nltest.Error(int(syscall.ENOENT), req)
+					}
+					delete(k.created, index)
+					return ack, nil
+				}
+				return answer(req, k.actions, kind, func() {
+					if k.created == nil {
+						k.created = make(map[uint32]string)
+					}
+					k.created[uint32(len(k.created)+1)] = kind
+				})
+			}
+			return ack, nil
+		}),
+		caps: &capabilityCache{},
+	}
+}
+
+// dumpActions returns the actions of kind like a dump of the kernel.
+func (k *probeKernel) dumpActions(t *testing.T, kind string) []netlink.Message {
+	t.Helper()
+	var msgs []netlink.Message
+	for index, created := range k.created {
+		if created != kind {
+			continue
+		}
+		act, err := marshalAttributes([]tcOption{
+			{Interpretation: vtString, Type: tcaActKind, Data: kind},
+			{Interpretation: vtUint32, Type: tcaActIndex, Data: index},
+		})
+		if err != nil {
+			t.Fatalf("could not marshal action: %v", err)
+		}
+		tab, err := marshalAttributes([]tcOption{{Interpretation: vtBytes, Type: 1, Data: act}})
+		if err != nil {
+			t.Fatalf("could not marshal action: %v", err)
+		}
+		root, err := marshalAttributes([]tcOption{{Interpretation: vtBytes, Type: tcaRootTab, Data: tab}})
+		if err != nil {
+			t.Fatalf("could not marshal action: %v", err)
+		}
+		msgs = append(msgs, netlink.Message{
+			Header: netlink.Header{Type: unix.RTM_NEWACTION, Flags: netlink.Multi},
+			Data:   append(make([]byte, 4), root...),
+		})
+	}
+	return append(msgs, netlink.Message{
+		Header: netlink.Header{Type: netlink.Done, Flags: netlink.Multi},
+		Data:   []byte{0, 0, 0, 0},
+	})
+}
+
+// probeAttribute returns the payload of the attribute typ in data.
+func probeAttribute(t *testing.T, data []byte, typ uint16) []byte {
+	t.Helper()
+	ad, err := netlink.NewAttributeDecoder(data)
+	if err != nil {
+		t.Fatalf("could not decode attributes: %v", err)
+	}
+	for ad.Next() {
+		if ad.Type() == typ {
+			return ad.Bytes()
+		}
+	}
+	t.Fatalf("missing attribute %d", typ)
+	return nil
+}
+
+func TestProbe(t *testing.T) {
+	tests := map[string]struct {
+		kernel   probeKernel
+		kind     string
+		kindType ProbeKind
+		want     bool
+		parent   uint32
+		// created are the actions, that are left after the probe.
+		created map[uint32]string
+		err     error
+	}{
+		"qdisc": {
+			kernel:   probeKernel{qdiscs: map[string]syscall.Errno{"htb": 0}},
+			kind:     "htb",
+			kindType: ProbeQdisc,
+			want:     true,
+		},
+		"qdisc without options": {
+			kernel:   probeKernel{qdiscs: map[string]syscall.Errno{"taprio": syscall.EINVAL}},
+			kind:     "taprio",
+			kindType: ProbeQdisc,
+			want:     true,
+		},
+		"qdisc on single queue device": {
+			kernel:   probeKernel{qdiscs: map[string]syscall.Errno{"mqprio": syscall.EOPNOTSUPP}},
+			kind:     "mqprio",
+			kindType: ProbeQdisc,
+			want:     true,
+		},
+		"unknown qdisc": {
+			kernel:   probeKernel{qdiscs: map[string]syscall.Errno{"htb": 0}},
+			kind:     "cake",
+			kindType: ProbeQdisc,
+		},
+		"module loaded": {
+			kernel: probeKernel{
+				qdiscs: map[string]syscall.Errno{"cake": syscall.EINVAL},
+				eagain: map[string]int{"cake": 1},
+			},
+			kind:     "cake",
+			kindType: ProbeQdisc,
+			want:     true,
+		},
+		"module still loading": {
+			kernel: probeKernel{
+				qdiscs: map[string]syscall.Errno{"cake": syscall.EINVAL},
+				eagain: map[string]int{"cake": probeAttempts},
+			},
+			kind:     "cake",
+			kindType: ProbeQdisc,
+			err:      syscall.EAGAIN,
+		},
+		"filter on clsact": {
+			kernel: probeKernel{
+				qdiscs:  map[string]syscall.Errno{"clsact": 0, "ingress": 0},
+				filters: map[string]syscall.Errno{"flower": 0},
+			},
+			kind:     "flower",
+			kindType: ProbeFilter,
+			want:     true,
+			parent:   HandleClsactIngress,
+		},
+		"filter on ingress": {
+			kernel: probeKernel{
+				qdiscs:  map[string]syscall.Errno{"ingress": 0},
+				filters: map[string]syscall.Errno{"bpf": syscall.EINVAL},
+			},
+			kind:     "bpf",
+			kindType: ProbeFilter,
+			want:     true,
+			parent:   core.BuildHandle(0xFFFF, 0),
+		},
+		"filter named differently by the kernel": {
+			kernel: probeKernel{
+				qdiscs:  map[string]syscall.Errno{"clsact": 0},
+				filters: map[string]syscall.Errno{"route": syscall.EINVAL},
+			},
+			kind:     "route4",
+			kindType: ProbeFilter,
+			want:     true,
+			parent:   HandleClsactIngress,
+		},
+		"unknown filter": {
+			kernel: probeKernel{
+				qdiscs:  map[string]syscall.Errno{"clsact": 0},
+				filters: map[string]syscall.Errno{"u32": 0},
+			},
+			kind:     "flower",
+			kindType: ProbeFilter,
+			parent:   HandleClsactIngress,
+		},
+		"filter without ingress": {
+			kernel:   probeKernel{filters: map[string]syscall.Errno{"u32": 0}},
+			kind:     "u32",
+			kindType: ProbeFilter,
+			err:      ErrNotImplemented,
+		},
+		"action": {
+			kernel:   probeKernel{actions: map[string]syscall.Errno{"gact": syscall.EINVAL}},
+			kind:     "gact",
+			kindType: ProbeAction,
+			want:     true,
+		},
+		"accepted action": {
+			kernel: probeKernel{
+				actions: map[string]syscall.Errno{"gact": 0},
+				created: map[uint32]string{1: "gact", 2: "mirred"},
+			},
+			kind:     "gact",
+			kindType: ProbeAction,
+			want:     true,
+			created:  map[uint32]string{1: "gact", 2: "mirred"},
+		},
+		"unknown action": {
+			kernel:   probeKernel{actions: map[string]syscall.Errno{"gact": syscall.EINVAL}},
+			kind:     "ct",
+			kindType: ProbeAction,
+		},
+		"not permitted": {
+			kernel:   probeKernel{link: syscall.EPERM},
+			kind:     "htb",
+			kindType: ProbeQdisc,
+			err:      syscall.EPERM,
+		},
+		"missing kind": {
+			kindType: ProbeQdisc,
+			err:      ErrNoArg,
+		},
+		"invalid kind": {
+			kind:     "h\x00tb",
+			kindType: ProbeQdisc,
+			err:      ErrInvalidArg,
+		},
+		"invalid kind type": {
+			kind:     "htb",
+			kindType: ProbeKind(7),
+			err:      ErrInvalidArg,
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			kernel := testcase.kernel
+			tcSocket := kernel.conn(t)
+			defer tcSocket.Close()
+
+			got, err := Probe(tcSocket, testcase.kind, testcase.kindType)
+			if testcase.err != nil {
+				if !errors.Is(err, testcase.err) {
+					t.Fatalf("expected error %v but got %v", testcase.err, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != testcase.want {
+				t.Fatalf("expected %v but got %v", testcase.want, got)
+			}
+			if len(kernel.links) != 0 {
+				t.Fatalf("scratch device was not removed: %v", kernel.links)
+			}
+			if diff := cmp.Diff(testcase.created, kernel.created, cmpopts.EquateEmpty()); diff != "" {
+				t.Fatalf("probed action was not removed (-want +got):\n%s", diff)
+			}
+			if testcase.parent != 0 {
+				if diff := cmp.Diff([]uint32{testcase.parent}, kernel.parents); diff != "" {
+					t.Fatalf("filter parent missmatch (-want +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	kernel := probeKernel{
+		qdiscs:  map[string]syscall.Errno{"clsact": 0, "htb": 0, "taprio": syscall.EINVAL},
+		filters: map[string]syscall.Errno{"bpf": syscall.EINVAL, "route": syscall.EINVAL, "u32": 0},
+		actions: map[string]syscall.Errno{"gact": syscall.EINVAL, "simple": syscall.EINVAL},
+		link:    syscall.EPERM,
+	}
+	tcSocket := kernel.conn(t)
+	defer tcSocket.Close()
+
+	if _, err := tcSocket.Capabilities(); !errors.Is(err, syscall.EPERM) {
+		t.Fatalf("expected EPERM but got %v", err)
+	}
+	// Errors are not cached.
+	kernel.link = 0
+	caps, err := tcSocket.Capabilities()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(kernel.links) != 0 {
+		t.Fatalf("scratch device was not removed: %v", kernel.links)
+	}
+	for _, kind := range probeQdiscs {
+		_, want := kernel.qdiscs[netlinkKind(kind)]
+		if caps.Qdiscs[kind] != want {
+			t.Fatalf("expected qdisc %s to be %v", kind, want)
+		}
+	}
+	for _, kind := range probeFilters {
+		_, want := kernel.filters[netlinkKind(kind)]
+		if caps.Filters[kind] != want {
+			t.Fatalf("expected filter %s to be %v", kind, want)
+		}
+	}
+	for _, kind := range probeActions {
+		_, want := kernel.actions[netlinkKind(kind)]
+		if caps.Actions[kind] != want {
+			t.Fatalf("expected action %s to be %v", kind, want)
+		}
+	}
+	if !caps.Filters["route4"] || !caps.Actions["defact"] {
+		t.Fatalf("expected route4 and defact to be probed under the names of the kernel")
+	}
+	if want := len(probeQdiscs) + len(probeFilters) + len(probeActions); kernel.probes != want {
+		t.Fatalf("expected %d probes but got %d", want, kernel.probes)
+	}
+
+	// Copies of Tc share the cache.
+	class := tcSocket.Class()
+	cached, err := class.Capabilities()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cached != caps {
+		t.Fatalf("capabilities were probed again")
+	}
+}
@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaAtmUnspec = iota
@@ -18,15 +14,15 @@ const (
 
 // Atm contains attributes of the atm discipline
 type Atm struct {
-	FD     *uint32
-	Excess *uint32
-	Addr   *AtmPvc
-	State  *uint32
+	FD     *uint32 `json:"fd,omitempty"`
+	Excess *uint32 `json:"excess,omitempty"`
+	Addr   *AtmPvc `json:"addr,omitempty"`
+	State  *uint32 `json:"state,omitempty"`
 }
 
 // unmarshalAtm parses the Atm-encoded data and stores the result in the value pointed to by info.
 func unmarshalAtm(data []byte, info *Atm) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -45,7 +41,7 @@ func unmarshalAtm(data []byte, info *Atm) error {
 		case tcaAtmState:
 			info.State = uint32Ptr(ad.Uint32())
 		default:
-			return fmt.Errorf("unmarshalAtm()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("atm", ad.Type(), ad.Bytes()))
 
 		}
 	}
@@ -83,8 +79,8 @@ func marshalAtm(info *Atm) ([]byte, error) {
 
 // AtmPvc from include/uapi/linux/atm.h
 type AtmPvc struct {
-	SapFamily byte
-	Itf       byte
-	Vpi       byte
-	Vci       byte
+	SapFamily byte `json:"sap_family,omitempty"`
+	Itf       byte `json:"itf,omitempty"`
+	Vpi       byte `json:"vpi,omitempty"`
+	Vci       byte `json:"vci,omitempty"`
 }
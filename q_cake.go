@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaCakeUnspec = iota
@@ -31,31 +27,32 @@ const (
 // Cake contains attributes of the cake discipline.
 // http://man7.org/linux/man-pages/man8/tc-cake.8.html
 type Cake struct {
-	BaseRate     *uint64
-	DiffServMode *uint32
-	Atm          *uint32
-	FlowMode     *uint32
-	Overhead     *uint32
-	Rtt          *uint32
-	Target       *uint32
-	Autorate     *uint32
-	Memory       *uint32
-	Nat          *uint32
-	Raw          *uint32
-	Wash         *uint32
-	Mpu          *uint32
-	Ingress      *uint32
-	AckFilter    *uint32
-	SplitGso     *uint32
-	FwMark       *uint32
+	BaseRate     *uint64 `json:"base_rate,omitempty"`
+	DiffServMode *uint32 `json:"diff_serv_mode,omitempty"`
+	Atm          *uint32 `json:"atm,omitempty"`
+	FlowMode     *uint32 `json:"flow_mode,omitempty"`
+	Overhead     *uint32 `json:"overhead,omitempty"`
+	Rtt          *uint32 `json:"rtt,omitempty"`
+	Target       *uint32 `json:"target,omitempty"`
+	Autorate     *uint32 `json:"autorate,omitempty"`
+	Memory       *uint32 `json:"memory,omitempty"`
+	Nat          *uint32 `json:"nat,omitempty"`
+	Raw          *uint32 `json:"raw,omitempty"`
+	Wash         *uint32 `json:"wash,omitempty"`
+	Mpu          *uint32 `json:"mpu,omitempty"`
+	Ingress      *uint32 `json:"ingress,omitempty"`
+	AckFilter    *uint32 `json:"ack_filter,omitempty"`
+	SplitGso     *uint32 `json:"split_gso,omitempty"`
+	FwMark       *uint32 `json:"fw_mark,omitempty"`
 }
 
 // unmarshalCake parses the Cake-encoded data and stores the result in the value pointed to by info.
 func unmarshalCake(data []byte, info *Cake) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
+	var multiError error
 	for ad.Next() {
 		switch ad.Type() {
 		case tcaCakeBaseRate64:
@@ -112,10 +109,10 @@ func unmarshalCake(data []byte, info *Cake) error {
 		case tcaCakePad:
 			// padding does not contain data, we just skip it
 		default:
-			return fmt.Errorf("unmarshalCake()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("cake", ad.Type(), ad.Bytes()))
 		}
 	}
-	return ad.Err()
+	return concatError(multiError, ad.Err())
 }
 
 // marshalCake returns the binary encoding of Red
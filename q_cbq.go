@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaCbqUnspec = iota
@@ -19,18 +15,18 @@ const (
 
 // Cbq contains attributes of the cbq discipline
 type Cbq struct {
-	LssOpt      *CbqLssOpt
-	WrrOpt      *CbqWrrOpt
-	FOpt        *CbqFOpt
-	OVLStrategy *CbqOvl
-	Rate        *RateSpec
-	RTab        []byte
-	Police      *CbqPolice
+	LssOpt      *CbqLssOpt `json:"lss_opt,omitempty"`
+	WrrOpt      *CbqWrrOpt `json:"wrr_opt,omitempty"`
+	FOpt        *CbqFOpt   `json:"f_opt,omitempty"`
+	OVLStrategy *CbqOvl    `json:"ovl_strategy,omitempty"`
+	Rate        *RateSpec  `json:"rate,omitempty"`
+	RTab        []byte     `json:"r_tab,omitempty"`
+	Police      *CbqPolice `json:"police,omitempty"`
 }
 
 // unmarshalCbq parses the Cbq-encoded data and stores the result in the value pointed to by info.
 func unmarshalCbq(data []byte, info *Cbq) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -70,7 +66,7 @@ func unmarshalCbq(data []byte, info *Cbq) error {
 			multiError = concatError(multiError, err)
 			info.Police = arg
 		default:
-			return fmt.Errorf("unmarshalCbq()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("cbq", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -119,44 +115,44 @@ func marshalCbq(info *Cbq) ([]byte, error) {
 
 // CbqLssOpt from include/uapi/linux/pkt_sched.h
 type CbqLssOpt struct {
-	Change  byte
-	Flags   byte
-	EwmaLog byte
-	Level   byte
-	Maxidle uint32
-	Minidle uint32
-	OffTime uint32
-	Avpkt   uint32
+	Change  byte   `json:"change,omitempty"`
+	Flags   byte   `json:"flags,omitempty"`
+	EwmaLog byte   `json:"ewma_log,omitempty"`
+	Level   byte   `json:"level,omitempty"`
+	Maxidle uint32 `json:"maxidle,omitempty"`
+	Minidle uint32 `json:"minidle,omitempty"`
+	OffTime uint32 `json:"off_time,omitempty"`
+	Avpkt   uint32 `json:"avpkt,omitempty"`
 }
 
 // CbqWrrOpt from include/uapi/linux/pkt_sched.h
 type CbqWrrOpt struct {
-	Flags     byte
-	Priority  byte
-	CPriority byte
-	Reserved  byte
-	Allot     uint32
-	Weight    uint32
+	Flags     byte   `json:"flags,omitempty"`
+	Priority  byte   `json:"priority,omitempty"`
+	CPriority byte   `json:"c_priority,omitempty"`
+	Reserved  byte   `json:"reserved,omitempty"`
+	Allot     uint32 `json:"allot,omitempty"`
+	Weight    uint32 `json:"weight,omitempty"`
 }
 
 // CbqFOpt from include/uapi/linux/pkt_sched.h
 type CbqFOpt struct {
-	Split     uint32
-	Defmap    uint32
-	Defchange uint32
+	Split     uint32 `json:"split,omitempty"`
+	Defmap    uint32 `json:"defmap,omitempty"`
+	Defchange uint32 `json:"defchange,omitempty"`
 }
 
 // CbqOvl from include/uapi/linux/pkt_sched.h
 type CbqOvl struct {
-	Strategy  byte
-	Priority2 byte
-	Pad       uint16
-	Penalty   uint32
+	Strategy  byte   `json:"strategy,omitempty"`
+	Priority2 byte   `json:"priority2,omitempty"`
+	Pad       uint16 `json:"pad,omitempty"`
+	Penalty   uint32 `json:"penalty,omitempty"`
 }
 
 // CbqPolice from include/uapi/linux/pkt_sched.h
 type CbqPolice struct {
-	Police byte
-	Res1   byte
-	Res2   uint16
+	Police byte   `json:"police,omitempty"`
+	Res1   byte   `json:"res1,omitempty"`
+	Res2   uint16 `json:"res2,omitempty"`
 }
@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaCbsUnspec = iota
@@ -13,22 +9,22 @@ const (
 
 // CbsOpt contains attributes of the cbs discipline
 type CbsOpt struct {
-	Offload   uint8
-	Pad       [3]uint8
-	HiCredit  int32
-	LoCredit  int32
-	IdleSlope int32
-	SendSlope int32
+	Offload   uint8    `json:"offload,omitempty"`
+	Pad       [3]uint8 `json:"pad,omitempty"`
+	HiCredit  int32    `json:"hi_credit,omitempty"`
+	LoCredit  int32    `json:"lo_credit,omitempty"`
+	IdleSlope int32    `json:"idle_slope,omitempty"`
+	SendSlope int32    `json:"send_slope,omitempty"`
 }
 
 // Cbs contains attributes of the cbs discipline
 type Cbs struct {
-	Parms *CbsOpt
+	Parms *CbsOpt `json:"parms,omitempty"`
 }
 
 // unmarshalCbs parses the Cbs-encoded data and stores the result in the value pointed to by info.
 func unmarshalCbs(data []byte, info *Cbs) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -41,7 +37,7 @@ func unmarshalCbs(data []byte, info *Cbs) error {
 			multiError = concatError(multiError, err)
 			info.Parms = opt
 		default:
-			return fmt.Errorf("unmarshalCbs()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("cbs", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
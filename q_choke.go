@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaChokeUnspec = iota
@@ -15,13 +11,13 @@ const (
 
 // Choke contains attributes of the choke discipline
 type Choke struct {
-	Parms *RedQOpt
-	MaxP  *uint32
+	Parms *RedQOpt `json:"parms,omitempty"`
+	MaxP  *uint32  `json:"max_p,omitempty"`
 }
 
 // unmarshalChoke parses the Choke-encoded data and stores the result in the value pointed to by info.
 func unmarshalChoke(data []byte, info *Choke) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -36,7 +32,7 @@ func unmarshalChoke(data []byte, info *Choke) error {
 		case tcaChokeMaxP:
 			info.MaxP = uint32Ptr(ad.Uint32())
 		default:
-			return fmt.Errorf("unmarshalChoke()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("choke", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
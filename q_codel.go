@@ -2,8 +2,7 @@ package tc
 
 import (
 	"fmt"
-
-	"github.com/mdlayher/netlink"
+	"time"
 )
 
 const (
@@ -17,19 +16,20 @@ const (
 
 // Codel contains attributes of the codel discipline
 type Codel struct {
-	Target      *uint32
-	Limit       *uint32
-	Interval    *uint32
-	ECN         *uint32
-	CEThreshold *uint32
+	Target      *uint32 `json:"target,omitempty"`
+	Limit       *uint32 `json:"limit,omitempty"`
+	Interval    *uint32 `json:"interval,omitempty"`
+	ECN         *uint32 `json:"ecn,omitempty"`
+	CEThreshold *uint32 `json:"ce_threshold,omitempty"`
 }
 
 // unmarshalCodel parses the Codel-encoded data and stores the result in the value pointed to by info.
 func unmarshalCodel(data []byte, info *Codel) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
+	var multiError error
 	for ad.Next() {
 		switch ad.Type() {
 		case tcaCodelTarget:
@@ -43,10 +43,10 @@ func unmarshalCodel(data []byte, info *Codel) error {
 		case tcaCodelCEThreshold:
 			info.CEThreshold = uint32Ptr(ad.Uint32())
 		default:
-			return fmt.Errorf("unmarshalCodel()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("codel", ad.Type(), ad.Bytes()))
 		}
 	}
-	return ad.Err()
+	return concatError(multiError, ad.Err())
 }
 
 // marshalCodel returns the binary encoding of Red
@@ -75,3 +75,69 @@ func marshalCodel(info *Codel) ([]byte, error) {
 	}
 	return marshalAttributes(options)
 }
+
+// CurrentDelay returns the sojourn time of the last dequeued packet.
+func (x *CodelXStats) CurrentDelay() time.Duration {
+	return codelTime(int64(x.LDelay))
+}
+
+// InDroppingState reports, whether codel drops or marks packets, as the
+// sojourn time stayed above the target for an interval. Count holds the
+// packets, that were dropped or marked in the current dropping state, and
+// LastCount the ones of the previous dropping state, that codel resumes
+// with, if it reenters the dropping state soon.
+func (x *CodelXStats) InDroppingState() bool {
+	return x.Dropping != 0
+}
+
+// NextDrop returns the time until the next packet is dropped or marked. It
+// is negative, if the drop is overdue. ok is false, if codel is not in the
+// dropping state.
+func (x *CodelXStats) NextDrop() (d time.Duration, ok bool) {
+	return codelNextDrop(x.Dropping, x.DropNext)
+}
+
+// DropRate returns the packets per second, that were dropped as the queue
+// exceeded its limit, since prev was dumped elapsed before x. The drops of
+// the control law are counted by Stats.Drops, see GenStats.Drops.
+func (x *CodelXStats) DropRate(prev *CodelXStats, elapsed time.Duration) float64 {
+	if prev == nil {
+		return 0
+	}
+	return counterRate(prev.DropOverlimit, x.DropOverlimit, elapsed)
+}
+
+// MarkRate returns the packets per second, that were marked with ECN
+// instead of being dropped, since prev was dumped elapsed before x.
+func (x *CodelXStats) MarkRate(prev *CodelXStats, elapsed time.Duration) float64 {
+	if prev == nil {
+		return 0
+	}
+	return counterRate(prev.EcnMark, x.EcnMark, elapsed)
+}
+
+// codelTime converts a time of the extended statistics of codel and
+// fq_codel, that linux/include/net/codel.h:codel_time_to_us() converted from
+// the internal time base of 1024 ns to microseconds.
+func codelTime(us int64) time.Duration {
+	return time.Duration(us) * time.Microsecond
+}
+
+// codelNextDrop returns the time until the next drop, that is only reported
+// in the dropping state.
+func codelNextDrop(dropping uint32, dropNext int32) (time.Duration, bool) {
+	if dropping == 0 {
+		return 0, false
+	}
+	return codelTime(int64(dropNext)), true
+}
+
+// counterRate returns the rate per second of the 32 bit counter, that
+// changed from prev to cur within elapsed.
+func counterRate(prev, cur uint32, elapsed time.Duration) float64 {
+	seconds := elapsed.Seconds()
+	if seconds <= 0 {
+		return 0
+	}
+	return float64(counterDelta(uint64(prev), uint64(cur), true)) / seconds
+}
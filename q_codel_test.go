@@ -3,6 +3,7 @@ package tc
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 )
@@ -13,7 +14,8 @@ func TestCode(t *testing.T) {
 		err1 error
 		err2 error
 	}{
-		"simple": {val: Codel{Target: uint32Ptr(1), Limit: uint32Ptr(2), Interval: uint32Ptr(3), ECN: uint32Ptr(4), CEThreshold: uint32Ptr(5)}},
+		"simple":       {val: Codel{Target: uint32Ptr(1), Limit: uint32Ptr(2), Interval: uint32Ptr(3), ECN: uint32Ptr(4), CEThreshold: uint32Ptr(5)}},
+		"ecn disabled": {val: Codel{ECN: uint32Ptr(0)}},
 	}
 
 	for name, testcase := range tests {
@@ -46,3 +48,106 @@ func TestCode(t *testing.T) {
 		}
 	})
 }
+
+// codelXStatsFixture returns tc_codel_xstats with the values in the order of
+// include/uapi/linux/pkt_sched.h.
+func codelXStatsFixture(values ...uint32) []byte {
+	data := make([]byte, 4*len(values))
+	for i, v := range values {
+		nativeEndian.PutUint32(data[4*i:], v)
+	}
+	return data
+}
+
+func TestCodelXStatsHelpers(t *testing.T) {
+	// The fields of tc_codel_xstats are maxpacket, count, lastcount, ldelay,
+	// drop_next, drop_overlimit, ecn_mark, dropping and ce_mark.
+	tests := map[string]struct {
+		data     []byte
+		delay    time.Duration
+		dropping bool
+		next     time.Duration
+	}{
+		// A queue without a standing delay.
+		"idle": {
+			data:  codelXStatsFixture(1514, 0, 0, 213, 0, 0, 0, 0, 0),
+			delay: 213 * time.Microsecond,
+		},
+		// A queue with 100ms of induced latency, that exceeds the target of
+		// 5ms, drops a packet every interval/sqrt(count).
+		"dropping": {
+			data:     codelXStatsFixture(1514, 23, 19, 100350, 20851, 3, 0, 1, 0),
+			delay:    100350 * time.Microsecond,
+			dropping: true,
+			next:     20851 * time.Microsecond,
+		},
+		"drop overdue": {
+			data:     codelXStatsFixture(1514, 4, 0, 7042, uint32(0xFFFFFEC8), 0, 2, 1, 0),
+			delay:    7042 * time.Microsecond,
+			dropping: true,
+			next:     -312 * time.Microsecond,
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			xstats := &CodelXStats{}
+			if err := unmarshalStruct(testcase.data, xstats); err != nil {
+				t.Fatalf("could not decode xstats: %v", err)
+			}
+			if delay := xstats.CurrentDelay(); delay != testcase.delay {
+				t.Fatalf("expected delay %v but got %v", testcase.delay, delay)
+			}
+			if dropping := xstats.InDroppingState(); dropping != testcase.dropping {
+				t.Fatalf("expected dropping state %t but got %t", testcase.dropping, dropping)
+			}
+			next, ok := xstats.NextDrop()
+			if next != testcase.next || ok != testcase.dropping {
+				t.Fatalf("expected next drop %v, %t but got %v, %t", testcase.next, testcase.dropping, next, ok)
+			}
+		})
+	}
+}
+
+func TestCodelXStatsRate(t *testing.T) {
+	tests := map[string]struct {
+		prev, cur *CodelXStats
+		elapsed   time.Duration
+		drops     float64
+		marks     float64
+	}{
+		"rate": {
+			prev:    &CodelXStats{DropOverlimit: 100, EcnMark: 10},
+			cur:     &CodelXStats{DropOverlimit: 400, EcnMark: 15},
+			elapsed: 2 * time.Second,
+			drops:   150,
+			marks:   2.5,
+		},
+		"wrapped": {
+			prev:    &CodelXStats{DropOverlimit: 0xFFFFFFF0, EcnMark: 0xFFFFFFFF},
+			cur:     &CodelXStats{DropOverlimit: 0x10, EcnMark: 0x1},
+			elapsed: time.Second,
+			drops:   32,
+			marks:   2,
+		},
+		"no previous": {
+			cur:     &CodelXStats{DropOverlimit: 400},
+			elapsed: time.Second,
+		},
+		"no time elapsed": {
+			prev: &CodelXStats{DropOverlimit: 100},
+			cur:  &CodelXStats{DropOverlimit: 400},
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			if drops := testcase.cur.DropRate(testcase.prev, testcase.elapsed); drops != testcase.drops {
+				t.Fatalf("expected %f drops/s but got %f", testcase.drops, drops)
+			}
+			if marks := testcase.cur.MarkRate(testcase.prev, testcase.elapsed); marks != testcase.marks {
+				t.Fatalf("expected %f marks/s but got %f", testcase.marks, marks)
+			}
+		})
+	}
+}
@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaDrrUnspec = iota
@@ -13,24 +9,25 @@ const (
 
 // Drr contains attributes of the drr discipline
 type Drr struct {
-	Quantum *uint32
+	Quantum *uint32 `json:"quantum,omitempty"`
 }
 
 // unmarshalDrr parses the Drr-encoded data and stores the result in the value pointed to by info.
 func unmarshalDrr(data []byte, info *Drr) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
+	var multiError error
 	for ad.Next() {
 		switch ad.Type() {
 		case tcaDrrQuantum:
 			info.Quantum = uint32Ptr(ad.Uint32())
 		default:
-			return fmt.Errorf("UnmarshalDrr()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("drr", ad.Type(), ad.Bytes()))
 		}
 	}
-	return ad.Err()
+	return concatError(multiError, ad.Err())
 }
 
 // marshalDrr returns the binary encoding of Qfq
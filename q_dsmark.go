@@ -3,7 +3,7 @@ package tc
 import (
 	"fmt"
 
-	"github.com/mdlayher/netlink"
+	"github.com/florianl/go-tc/core"
 )
 
 const (
@@ -17,19 +17,33 @@ const (
 
 // Dsmark contains attributes of the dsmark discipline
 type Dsmark struct {
-	Indices      *uint16
-	DefaultIndex *uint16
-	SetTCIndex   *bool
-	Mask         *uint8
-	Value        *uint8
+	Indices      *uint16 `json:"indices,omitempty"`
+	DefaultIndex *uint16 `json:"default_index,omitempty"`
+	SetTCIndex   *bool   `json:"set_tc_index,omitempty"`
+	Mask         *uint8  `json:"mask,omitempty"`
+	Value        *uint8  `json:"value,omitempty"`
+}
+
+// DsmarkClassHandle returns the handle of the class with the index of the
+// dsmark qdisc with the handle qdisc. The index of a class is the minor of its
+// handle, so an index, that does not fit into 16 bits, would select another
+// class and is rejected with ErrInvalidArg.
+func DsmarkClassHandle(qdisc, index uint32) (uint32, error) {
+	if index > 0xFFFF {
+		return 0, fmt.Errorf("dsmark: index 0x%x does not fit into the 16 bit minor of a class handle: %w",
+			index, ErrInvalidArg)
+	}
+	maj, _ := core.SplitHandle(qdisc)
+	return core.BuildHandle(maj, index), nil
 }
 
 // unmarshalDsmark parses the Dsmark-encoded data and stores the result in the value pointed to by info.
 func unmarshalDsmark(data []byte, info *Dsmark) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
+	var multiError error
 	for ad.Next() {
 		switch ad.Type() {
 		case tcaDsmarkIndices:
@@ -43,10 +57,10 @@ func unmarshalDsmark(data []byte, info *Dsmark) error {
 		case tcaDsmarkValue:
 			info.Value = uint8Ptr(ad.Uint8())
 		default:
-			return fmt.Errorf("UnmarshalDsmark()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("dsmark", ad.Type(), ad.Bytes()))
 		}
 	}
-	return ad.Err()
+	return concatError(multiError, ad.Err())
 }
 
 // marshalDsmark returns the binary encoding of Qfq
@@ -47,3 +47,29 @@ func TestDsmark(t *testing.T) {
 		}
 	})
 }
+
+func TestDsmarkClassHandle(t *testing.T) {
+	tests := map[string]struct {
+		qdisc  uint32
+		index  uint32
+		handle uint32
+		err    error
+	}{
+		"first":       {qdisc: 0x10000, index: 0x0, handle: 0x10000},
+		"last":        {qdisc: 0x10000, index: 0xFFFF, handle: 0x1FFFF},
+		"class":       {qdisc: 0x20003, index: 0x2E, handle: 0x2002E},
+		"index 16bit": {qdisc: 0x10000, index: 0x10000, err: ErrInvalidArg},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			handle, err := DsmarkClassHandle(testcase.qdisc, testcase.index)
+			if !errors.Is(err, testcase.err) {
+				t.Fatalf("expected error %v but got %v", testcase.err, err)
+			}
+			if handle != testcase.handle {
+				t.Fatalf("expected handle 0x%x but got 0x%x", testcase.handle, handle)
+			}
+		})
+	}
+}
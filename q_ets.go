@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaEtsUnspec = iota
@@ -19,27 +15,28 @@ const (
 // Ets represents a struct for Enhanced Transmission Selection, a 802.1Qaz-based Qdisc.
 // More info at https://lwn.net/Articles/805229/
 type Ets struct {
-	NBands  *uint8
-	NStrict *uint8
-	Quanta  *[]uint32
-	PrioMap *[]uint8
+	NBands  *uint8    `json:"n_bands,omitempty"`
+	NStrict *uint8    `json:"n_strict,omitempty"`
+	Quanta  *[]uint32 `json:"quanta,omitempty"`
+	PrioMap *[]uint8  `json:"prio_map,omitempty"`
 }
 
 // unmarshalEtsQuanta
 func unmarshalEtsQuanta(data []byte, info *[]uint32) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
+	var multiError error
 	for ad.Next() {
 		switch ad.Type() {
 		case tcaEtsQuantaBand:
 			*info = append(*info, ad.Uint32())
 		default:
-			return fmt.Errorf("unmarshalEtsQuanta()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("ets quanta", ad.Type(), ad.Bytes()))
 		}
 	}
-	return ad.Err()
+	return concatError(multiError, ad.Err())
 }
 
 // marshalEtsQuanta
@@ -59,19 +56,20 @@ func marshalEtsQuanta(info *[]uint32) ([]byte, error) {
 
 // unmarshalEtsPrioMap
 func unmarshalEtsPrioMap(data []byte, info *[]uint8) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
+	var multiError error
 	for ad.Next() {
 		switch ad.Type() {
 		case tcaEtsPrioMapBand:
 			*info = append(*info, ad.Uint8())
 		default:
-			return fmt.Errorf("unmarshalEtsPrioMap()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("ets priomap", ad.Type(), ad.Bytes()))
 		}
 	}
-	return ad.Err()
+	return concatError(multiError, ad.Err())
 }
 
 // marshalEtsPrioMap
@@ -91,7 +89,7 @@ func marshalEtsPrioMap(info *[]uint8) ([]byte, error) {
 
 // unmarshalEts parses the Ets-encoded data and stores the result in the value pointed to by info.
 func unmarshalEts(data []byte, info *Ets) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -115,7 +113,7 @@ func unmarshalEts(data []byte, info *Ets) error {
 			multiError = concatError(multiError, err)
 			info.PrioMap = &tmp
 		default:
-			return fmt.Errorf("unmarshalEts()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("ets", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -139,12 +137,12 @@ func marshalEts(info *Ets) ([]byte, error) {
 	if info.Quanta != nil {
 		data, err := marshalEtsQuanta(info.Quanta)
 		multiError = concatError(multiError, err)
-		options = append(options, tcOption{Interpretation: vtBytes, Type: tcaEtsQuanta, Data: data})
+		options = append(options, tcOption{Interpretation: vtNested, Type: tcaEtsQuanta, Data: data})
 	}
 	if info.PrioMap != nil {
 		data, err := marshalEtsPrioMap(info.PrioMap)
 		multiError = concatError(multiError, err)
-		options = append(options, tcOption{Interpretation: vtBytes, Type: tcaEtsPrioMap, Data: data})
+		options = append(options, tcOption{Interpretation: vtNested, Type: tcaEtsPrioMap, Data: data})
 	}
 
 	if multiError != nil {
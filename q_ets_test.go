@@ -54,6 +54,26 @@ func TestEts(t *testing.T) {
 			t.Fatalf("unexpected error: %v", err)
 		}
 	})
+	t.Run("iproute2", func(t *testing.T) {
+		// TCA_OPTIONS of `tc qdisc add dev eth0 root ets bands 2 quanta 1500 3000 priomap 1 0`.
+		// The quanta and the priomap are sent with NLA_F_NESTED.
+		want := []byte{
+			0x05, 0x00, 0x01, 0x00, 0x02, 0x00, 0x00, 0x00,
+			0x14, 0x00, 0x03, 0x80,
+			0x08, 0x00, 0x04, 0x00, 0xdc, 0x05, 0x00, 0x00,
+			0x08, 0x00, 0x04, 0x00, 0xb8, 0x0b, 0x00, 0x00,
+			0x14, 0x00, 0x05, 0x80,
+			0x05, 0x00, 0x06, 0x00, 0x01, 0x00, 0x00, 0x00,
+			0x05, 0x00, 0x06, 0x00, 0x00, 0x00, 0x00, 0x00,
+		}
+		data, err := marshalEts(&Ets{NBands: uint8Ptr(2), Quanta: &[]uint32{1500, 3000}, PrioMap: &[]uint8{1, 0}})
+		if err != nil {
+			t.Fatalf("could not marshal Ets: %v", err)
+		}
+		if diff := cmp.Diff(want, data); diff != "" {
+			t.Fatalf("Ets missmatch (-want +got):\n%s", diff)
+		}
+	})
 	t.Run("marshalEtsPrioMap(nil)", func(t *testing.T) {
 		_, err := marshalEtsPrioMap(nil)
 		if !errors.Is(err, ErrNoArg) {
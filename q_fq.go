@@ -4,8 +4,6 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
-
-	"github.com/mdlayher/netlink"
 )
 
 const (
@@ -32,38 +30,39 @@ const (
 
 // FqPrioQopt according to tc_prio_qopt in /include/uapi/linux/pkt_sched.h
 type FqPrioQopt struct {
-	Bands   int32
-	PrioMap [16]uint8 // TC_PRIO_MAX + 1 = 16
+	Bands   int32     `json:"bands,omitempty"`
+	PrioMap [16]uint8 `json:"prio_map,omitempty"` // TC_PRIO_MAX + 1 = 16
 }
 
 // Fq contains attributes of the fq discipline
 type Fq struct {
-	PLimit           *uint32
-	FlowPLimit       *uint32
-	Quantum          *uint32
-	InitQuantum      *uint32
-	RateEnable       *uint32
-	FlowDefaultRate  *uint32
-	FlowMaxRate      *uint32
-	BucketsLog       *uint32
-	FlowRefillDelay  *uint32
-	OrphanMask       *uint32
-	LowRateThreshold *uint32
-	CEThreshold      *uint32
-	TimerSlack       *uint32
-	Horizon          *uint32
-	HorizonDrop      *uint8
-	PrioMap          *FqPrioQopt
-	Weights          *[]int32
-	OffloadHorizon   *uint32
+	PLimit           *uint32     `json:"p_limit,omitempty"`
+	FlowPLimit       *uint32     `json:"flow_p_limit,omitempty"`
+	Quantum          *uint32     `json:"quantum,omitempty"`
+	InitQuantum      *uint32     `json:"init_quantum,omitempty"`
+	RateEnable       *uint32     `json:"rate_enable,omitempty"`
+	FlowDefaultRate  *uint32     `json:"flow_default_rate,omitempty"`
+	FlowMaxRate      *uint32     `json:"flow_max_rate,omitempty"`
+	BucketsLog       *uint32     `json:"buckets_log,omitempty"`
+	FlowRefillDelay  *uint32     `json:"flow_refill_delay,omitempty"`
+	OrphanMask       *uint32     `json:"orphan_mask,omitempty"`
+	LowRateThreshold *uint32     `json:"low_rate_threshold,omitempty"`
+	CEThreshold      *uint32     `json:"ce_threshold,omitempty"`
+	TimerSlack       *uint32     `json:"timer_slack,omitempty"`
+	Horizon          *uint32     `json:"horizon,omitempty"`
+	HorizonDrop      *uint8      `json:"horizon_drop,omitempty"`
+	PrioMap          *FqPrioQopt `json:"prio_map,omitempty"`
+	Weights          *[]int32    `json:"weights,omitempty"`
+	OffloadHorizon   *uint32     `json:"offload_horizon,omitempty"`
 }
 
 // unmarshalFq parses the Fq-encoded data and stores the result in the value pointed to by info.
 func unmarshalFq(data []byte, info *Fq) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
+	var multiError error
 	for ad.Next() {
 		switch ad.Type() {
 		case tcaFqPLimit:
@@ -98,21 +97,23 @@ func unmarshalFq(data []byte, info *Fq) error {
 			info.HorizonDrop = uint8Ptr(ad.Uint8())
 		case tcaFqPrioMap:
 			priomap := &FqPrioQopt{}
-			err = unmarshalStruct(ad.Bytes(), priomap)
+			err := unmarshalStruct(ad.Bytes(), priomap)
+			multiError = concatError(multiError, err)
 			info.PrioMap = priomap
 		case tcaFqWeights:
 			size := len(ad.Bytes()) / 4
 			weights := make([]int32, size)
 			reader := bytes.NewReader(ad.Bytes())
-			err = binary.Read(reader, nativeEndian, weights)
+			err := binary.Read(reader, nativeEndian, weights)
+			multiError = concatError(multiError, err)
 			info.Weights = &weights
 		case tcaFqOffloadHorizon:
 			info.OffloadHorizon = uint32Ptr(ad.Uint32())
 		default:
-			return fmt.Errorf("unmarshalFq()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("fq", ad.Type(), ad.Bytes()))
 		}
 	}
-	return concatError(err, ad.Err())
+	return concatError(multiError, ad.Err())
 }
 
 // marshalFq returns the binary encoding of Fq
@@ -2,8 +2,7 @@ package tc
 
 import (
 	"fmt"
-
-	"github.com/mdlayher/netlink"
+	"time"
 )
 
 const (
@@ -28,17 +27,17 @@ const (
 
 // FqCodel contains attributes of the fq_codel discipline
 type FqCodel struct {
-	Target              *uint32
-	Limit               *uint32
-	Interval            *uint32
-	ECN                 *uint32
-	Flows               *uint32
-	Quantum             *uint32
-	CEThreshold         *uint32
-	DropBatchSize       *uint32
-	MemoryLimit         *uint32
-	CeThresholdSelector *uint8
-	CeThresholdMask     *uint8
+	Target              *uint32 `json:"target,omitempty"`
+	Limit               *uint32 `json:"limit,omitempty"`
+	Interval            *uint32 `json:"interval,omitempty"`
+	ECN                 *uint32 `json:"ecn,omitempty"`
+	Flows               *uint32 `json:"flows,omitempty"`
+	Quantum             *uint32 `json:"quantum,omitempty"`
+	CEThreshold         *uint32 `json:"ce_threshold,omitempty"`
+	DropBatchSize       *uint32 `json:"drop_batch_size,omitempty"`
+	MemoryLimit         *uint32 `json:"memory_limit,omitempty"`
+	CeThresholdSelector *uint8  `json:"ce_threshold_selector,omitempty"`
+	CeThresholdMask     *uint8  `json:"ce_threshold_mask,omitempty"`
 }
 
 // marshalFqCodel returns the binary encoding of FqCodel
@@ -100,10 +99,11 @@ func marshalFqCodel(info *FqCodel) ([]byte, error) {
 
 // unmarshalFqCodel parses the FqCodel-encoded data and stores the result in the value pointed to by info.
 func unmarshalFqCodel(data []byte, info *FqCodel) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
+	var multiError error
 	for ad.Next() {
 		switch ad.Type() {
 		case tcaFqCodelTarget:
@@ -129,8 +129,47 @@ func unmarshalFqCodel(data []byte, info *FqCodel) error {
 		case tcaFqCodelCeThresholdMask:
 			info.CeThresholdMask = uint8Ptr(ad.Uint8())
 		default:
-			return fmt.Errorf("unmarshalFqCodel()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("fq_codel", ad.Type(), ad.Bytes()))
 		}
 	}
-	return ad.Err()
+	return concatError(multiError, ad.Err())
+}
+
+// DropRate returns the packets per second, that were dropped as the qdisc
+// exceeded its limit or memory limit, since prev was dumped elapsed before
+// x. The drops of the control law are counted by Stats.Drops, see
+// GenStats.Drops.
+func (x *FqCodelQdStats) DropRate(prev *FqCodelQdStats, elapsed time.Duration) float64 {
+	if prev == nil {
+		return 0
+	}
+	return counterRate(prev.DropOverlimit, x.DropOverlimit, elapsed) +
+		counterRate(prev.DropOvermemory, x.DropOvermemory, elapsed)
+}
+
+// MarkRate returns the packets per second, that were marked with ECN
+// instead of being dropped, since prev was dumped elapsed before x.
+func (x *FqCodelQdStats) MarkRate(prev *FqCodelQdStats, elapsed time.Duration) float64 {
+	if prev == nil {
+		return 0
+	}
+	return counterRate(prev.EcnMark, x.EcnMark, elapsed)
+}
+
+// CurrentDelay returns the sojourn time of the packet at the head of the
+// flow, like CodelXStats.CurrentDelay.
+func (x *FqCodelClStats) CurrentDelay() time.Duration {
+	return codelTime(int64(x.LDelay))
+}
+
+// InDroppingState reports, whether codel drops or marks packets of the flow,
+// like CodelXStats.InDroppingState.
+func (x *FqCodelClStats) InDroppingState() bool {
+	return x.Dropping != 0
+}
+
+// NextDrop returns the time until the next packet of the flow is dropped or
+// marked, like CodelXStats.NextDrop.
+func (x *FqCodelClStats) NextDrop() (d time.Duration, ok bool) {
+	return codelNextDrop(x.Dropping, x.DropNext)
 }
@@ -3,7 +3,9 @@ package tc
 import (
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/florianl/go-tc/internal/unix"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -25,6 +27,8 @@ func TestFqCodel(t *testing.T) {
 			MemoryLimit:         uint32Ptr(9),
 			CeThresholdSelector: uint8Ptr(10),
 			CeThresholdMask:     uint8Ptr(11)}},
+		// An explicit zero disables ECN and must be sent to the kernel.
+		"ecn disabled": {val: FqCodel{ECN: uint32Ptr(0)}},
 	}
 
 	for name, testcase := range tests {
@@ -50,6 +54,34 @@ func TestFqCodel(t *testing.T) {
 			}
 		})
 	}
+	t.Run("iproute2", func(t *testing.T) {
+		// TCA_OPTIONS of `tc qdisc add dev eth0 root fq_codel limit 100 ecn`.
+		// The options of fq_codel are sent without NLA_F_NESTED.
+		want := []byte{
+			0x14, 0x00, 0x02, 0x00,
+			0x08, 0x00, 0x02, 0x00, 0x64, 0x00, 0x00, 0x00,
+			0x08, 0x00, 0x04, 0x00, 0x01, 0x00, 0x00, 0x00,
+		}
+		options, err := validateQdiscObject(unix.RTM_NEWQDISC, &Object{
+			Msg:       Msg{Ifindex: 42, Parent: HandleRoot},
+			Attribute: Attribute{Kind: "fq_codel", FqCodel: &FqCodel{Limit: uint32Ptr(100), ECN: uint32Ptr(1)}},
+		})
+		if err != nil {
+			t.Fatalf("could not marshal FqCodel: %v", err)
+		}
+		var data []byte
+		for _, option := range options {
+			if option.Type != tcaOptions {
+				continue
+			}
+			if data, err = marshalAttributes([]tcOption{option}); err != nil {
+				t.Fatalf("could not marshal options: %v", err)
+			}
+		}
+		if diff := cmp.Diff(want, data); diff != "" {
+			t.Fatalf("FqCodel missmatch (-want +got):\n%s", diff)
+		}
+	})
 	t.Run("nil", func(t *testing.T) {
 		_, err := marshalFqCodel(nil)
 		if !errors.Is(err, ErrNoArg) {
@@ -63,3 +95,49 @@ func TestFqCodel(t *testing.T) {
 		}
 	})
 }
+
+func TestFqCodelXStatsHelpers(t *testing.T) {
+	t.Run("flow", func(t *testing.T) {
+		// tc_fq_codel_xstats of a class dump of a flow with 100ms of induced
+		// latency: type, deficit, ldelay, count, lastcount, dropping and
+		// drop_next.
+		data := codelXStatsFixture(tcaFqCodelXStatsClass, uint32(0xFFFFFA16), 100350, 23, 19, 1, 20851)
+		xstats := &FqCodelXStats{}
+		if err := unmarshalFqCodelXStats(data, xstats); err != nil {
+			t.Fatalf("could not decode xstats: %v", err)
+		}
+		flow := xstats.Cl
+		if flow == nil {
+			t.Fatalf("expected the statistics of a flow but got %#v", xstats)
+		}
+		if delay := flow.CurrentDelay(); delay != 100350*time.Microsecond {
+			t.Fatalf("expected delay 100.35ms but got %v", delay)
+		}
+		if !flow.InDroppingState() {
+			t.Fatalf("expected the dropping state")
+		}
+		if next, ok := flow.NextDrop(); !ok || next != 20851*time.Microsecond {
+			t.Fatalf("expected next drop in 20.851ms but got %v, %t", next, ok)
+		}
+		flow.Dropping = 0
+		if next, ok := flow.NextDrop(); ok || next != 0 {
+			t.Fatalf("expected no next drop outside the dropping state but got %v, %t", next, ok)
+		}
+	})
+
+	t.Run("qdisc", func(t *testing.T) {
+		prev := &FqCodelQdStats{DropOverlimit: 10, DropOvermemory: 5, EcnMark: 100}
+		cur := &FqCodelQdStats{DropOverlimit: 30, DropOvermemory: 25, EcnMark: 0x10}
+		if drops := cur.DropRate(prev, 10*time.Second); drops != 4 {
+			t.Fatalf("expected 4 drops/s but got %f", drops)
+		}
+		// EcnMark is smaller than before, so the 32 bit counter wrapped.
+		const wrapped = 1<<32 + 0x10 - 100
+		if marks := cur.MarkRate(prev, time.Second); marks != wrapped {
+			t.Fatalf("expected %f marks/s but got %f", float64(wrapped), marks)
+		}
+		if drops := cur.DropRate(nil, time.Second); drops != 0 {
+			t.Fatalf("expected no drops without previous statistics but got %f", drops)
+		}
+	})
+}
@@ -4,8 +4,6 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
-
-	"github.com/mdlayher/netlink"
 )
 
 const (
@@ -17,14 +15,14 @@ const (
 
 // Hfsc contains attributes of the hfsc class
 type Hfsc struct {
-	Rsc *ServiceCurve
-	Fsc *ServiceCurve
-	Usc *ServiceCurve
+	Rsc *ServiceCurve `json:"rsc,omitempty"`
+	Fsc *ServiceCurve `json:"fsc,omitempty"`
+	Usc *ServiceCurve `json:"usc,omitempty"`
 }
 
 // unmarshalHfsc parses the Hfsc-encoded data and stores the result in the value pointed to by info.
 func unmarshalHfsc(data []byte, info *Hfsc) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -47,7 +45,7 @@ func unmarshalHfsc(data []byte, info *Hfsc) error {
 			multiError = concatError(multiError, err)
 			info.Usc = curve
 		default:
-			return fmt.Errorf("unmarshalHfsc()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("hfsc", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -86,14 +84,14 @@ func marshalHfsc(info *Hfsc) ([]byte, error) {
 
 // ServiceCurve from include/uapi/linux/pkt_sched.h
 type ServiceCurve struct {
-	M1 uint32
-	D  uint32
-	M2 uint32
+	M1 uint32 `json:"m1,omitempty"`
+	D  uint32 `json:"d,omitempty"`
+	M2 uint32 `json:"m2,omitempty"`
 }
 
 // HfscQOpt contains attributes of the hfsc qdisc
 type HfscQOpt struct {
-	DefCls uint16
+	DefCls uint16 `json:"def_cls,omitempty"`
 }
 
 // unmarshalHfscQOpt parses the HfscQOpt-encoded data and stores the result in the value pointed to by info.
@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaHhfUnspec = iota
@@ -19,21 +15,22 @@ const (
 
 // Hhf contains attributes of the hhf discipline
 type Hhf struct {
-	BacklogLimit *uint32
-	Quantum      *uint32
-	HHFlowsLimit *uint32
-	ResetTimeout *uint32
-	AdmitBytes   *uint32
-	EVICTTimeout *uint32
-	NonHHWeight  *uint32
+	BacklogLimit *uint32 `json:"backlog_limit,omitempty"`
+	Quantum      *uint32 `json:"quantum,omitempty"`
+	HHFlowsLimit *uint32 `json:"hh_flows_limit,omitempty"`
+	ResetTimeout *uint32 `json:"reset_timeout,omitempty"`
+	AdmitBytes   *uint32 `json:"admit_bytes,omitempty"`
+	EVICTTimeout *uint32 `json:"evict_timeout,omitempty"`
+	NonHHWeight  *uint32 `json:"non_hh_weight,omitempty"`
 }
 
 // unmarshalHhf parses the Hhf-encoded data and stores the result in the value pointed to by info.
 func unmarshalHhf(data []byte, info *Hhf) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
+	var multiError error
 	for ad.Next() {
 		switch ad.Type() {
 		case tcaHhfBacklogLimit:
@@ -51,10 +48,10 @@ func unmarshalHhf(data []byte, info *Hhf) error {
 		case tcaHhfNonHHWeight:
 			info.NonHHWeight = uint32Ptr(ad.Uint32())
 		default:
-			return fmt.Errorf("unmarshalHhf()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("hhf", ad.Type(), ad.Bytes()))
 		}
 	}
-	return ad.Err()
+	return concatError(multiError, ad.Err())
 }
 
 // marshalHhf returns the binary encoding of Hhf
@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaHtbUnspec = iota
@@ -21,19 +17,19 @@ const (
 
 // Htb contains attributes of the HTB discipline
 type Htb struct {
-	Parms      *HtbOpt
-	Init       *HtbGlob
-	Ctab       *[]byte
-	Rtab       *[]byte
-	DirectQlen *uint32
-	Rate64     *uint64
-	Ceil64     *uint64
-	Offload    *bool
+	Parms      *HtbOpt  `json:"parms,omitempty"`
+	Init       *HtbGlob `json:"init,omitempty"`
+	Ctab       *[]byte  `json:"ctab,omitempty"`
+	Rtab       *[]byte  `json:"rtab,omitempty"`
+	DirectQlen *uint32  `json:"direct_qlen,omitempty"`
+	Rate64     *uint64  `json:"rate64,omitempty"`
+	Ceil64     *uint64  `json:"ceil64,omitempty"`
+	Offload    *bool    `json:"offload,omitempty"`
 }
 
 // unmarshalHtb parses the Htb-encoded data and stores the result in the value pointed to by info.
 func unmarshalHtb(data []byte, info *Htb) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -65,7 +61,7 @@ func unmarshalHtb(data []byte, info *Htb) error {
 		case tcaHtbOffload:
 			info.Offload = boolPtr(ad.Flag())
 		default:
-			return fmt.Errorf("unmarshalHtb()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("htb", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -110,20 +106,20 @@ func marshalHtb(info *Htb) ([]byte, error) {
 
 // HtbGlob from include/uapi/linux/pkt_sched.h
 type HtbGlob struct {
-	Version      uint32
-	Rate2Quantum uint32
-	Defcls       uint32
-	Debug        uint32
-	DirectPkts   uint32
+	Version      uint32 `json:"version,omitempty"`
+	Rate2Quantum uint32 `json:"rate2_quantum,omitempty"`
+	Defcls       uint32 `json:"defcls,omitempty"`
+	Debug        uint32 `json:"debug,omitempty"`
+	DirectPkts   uint32 `json:"direct_pkts,omitempty"`
 }
 
 // HtbOpt from include/uapi/linux/pkt_sched.h
 type HtbOpt struct {
-	Rate    RateSpec
-	Ceil    RateSpec
-	Buffer  uint32
-	Cbuffer uint32
-	Quantum uint32
-	Level   uint32
-	Prio    uint32
+	Rate    RateSpec `json:"rate,omitempty"`
+	Ceil    RateSpec `json:"ceil,omitempty"`
+	Buffer  uint32   `json:"buffer,omitempty"`
+	Cbuffer uint32   `json:"cbuffer,omitempty"`
+	Quantum uint32   `json:"quantum,omitempty"`
+	Level   uint32   `json:"level,omitempty"`
+	Prio    uint32   `json:"prio,omitempty"`
 }
@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaMqPrioUnspec = iota
@@ -16,38 +12,38 @@ const (
 
 // MqPrio contains attributes of the mqprio discipline
 type MqPrio struct {
-	Opt       *MqPrioQopt
-	Mode      *uint16
-	Shaper    *uint16
-	MinRate64 *uint64
-	MaxRate64 *uint64
+	Opt       *MqPrioQopt `json:"opt,omitempty"`
+	Mode      *uint16     `json:"mode,omitempty"`
+	Shaper    *uint16     `json:"shaper,omitempty"`
+	MinRate64 *uint64     `json:"min_rate64,omitempty"`
+	MaxRate64 *uint64     `json:"max_rate64,omitempty"`
 }
 
 // MqPrioQopt according to tc_mqprio_qopt in /include/uapi/linux/pkt_sched.h
 type MqPrioQopt struct {
-	NumTc     uint8
-	PrioTcMap [16]uint8 //  TC_QOPT_BITMASK + 1 = 16
-	Hw        uint8
-	Count     [16]uint16 // TC_QOPT_MAX_QUEUE = 16
-	Offset    [16]uint16 // TC_QOPT_MAX_QUEUE = 16
+	NumTc     uint8      `json:"num_tc,omitempty"`
+	PrioTcMap Priomap    `json:"prio_tc_map,omitempty"` //  TC_QOPT_BITMASK + 1 = 16
+	Hw        uint8      `json:"hw,omitempty"`
+	Count     [16]uint16 `json:"count,omitempty"`  // TC_QOPT_MAX_QUEUE = 16
+	Offset    [16]uint16 `json:"offset,omitempty"` // TC_QOPT_MAX_QUEUE = 16
 }
 
 // unmarshalMqPrio parses the MqPrio-encoded data and stores the result in the value pointed to by info.
 func unmarshalMqPrio(data []byte, info *MqPrio) error {
 	opt := &MqPrioQopt{}
-	if err := unmarshalStruct(data, opt); err != nil {
+	// The size of MqPrioQopt is 82 bytes and the attributes follow it
+	// aligned to 4 byte boundaries.
+	attrs, err := unmarshalStructAttrs(data, opt)
+	if err != nil {
 		return err
 	}
 	info.Opt = opt
 
-	// The size of MqPrioQopt is 82 bytes. To align it to 4 byte boundaries
-	// we add two.
-	data = data[84:]
-
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(attrs)
 	if err != nil {
 		return err
 	}
+	var multiError error
 	for ad.Next() {
 		switch ad.Type() {
 		case tcaMqPrioMode:
@@ -59,10 +55,10 @@ func unmarshalMqPrio(data []byte, info *MqPrio) error {
 		case tcaMqPrioMaxRate64:
 			info.MaxRate64 = uint64Ptr(ad.Uint64())
 		default:
-			return fmt.Errorf("unmarshalMqPrio()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("mqprio", ad.Type(), ad.Bytes()))
 		}
 	}
-	return ad.Err()
+	return concatError(multiError, ad.Err())
 }
 
 // marshalMqPrio returns the binary encoding of MqPrio
@@ -72,6 +68,9 @@ func marshalMqPrio(info *MqPrio) ([]byte, error) {
 	if info == nil || info.Opt == nil {
 		return []byte{}, fmt.Errorf("MqPrio: %w", ErrNoArg)
 	}
+	if err := info.Opt.PrioTcMap.Validate(uint32(info.Opt.NumTc)); err != nil {
+		return []byte{}, err
+	}
 
 	// TODO: improve logic and check combinations
 	if info.Mode != nil {
@@ -2,6 +2,7 @@ package tc
 
 import (
 	"errors"
+	"io"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -14,9 +15,10 @@ func TestMqPrio(t *testing.T) {
 		err2 error
 	}{
 		"simple": {val: MqPrio{
-			Opt: &MqPrioQopt{}, Mode: uint16Ptr(1),
+			Opt: &MqPrioQopt{NumTc: 1}, Mode: uint16Ptr(1),
 			Shaper: uint16Ptr(2), MinRate64: uint64Ptr(3), MaxRate64: uint64Ptr(4),
 		}},
+		"tc beyond NumTc": {val: MqPrio{Opt: &MqPrioQopt{NumTc: 2, PrioTcMap: DefaultPriomap()}}, err1: ErrInvalidArg},
 	}
 
 	for name, testcase := range tests {
@@ -48,6 +50,23 @@ func TestMqPrio(t *testing.T) {
 			t.Fatalf("unexpected error: %v", err)
 		}
 	})
+	t.Run("truncated", func(t *testing.T) {
+		data, err := marshalStruct(&MqPrioQopt{NumTc: 3})
+		if err != nil {
+			t.Fatalf("could not marshal qopt: %v", err)
+		}
+		// A qopt of 82 bytes without attributes and the alignment to 84 bytes.
+		val := MqPrio{}
+		if err := unmarshalMqPrio(data, &val); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if diff := cmp.Diff(MqPrio{Opt: &MqPrioQopt{NumTc: 3}}, val); diff != "" {
+			t.Fatalf("MqPrio missmatch (want +got):\n%s", diff)
+		}
+		if err := unmarshalMqPrio(data[:40], &MqPrio{}); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("expected io.ErrUnexpectedEOF but got %v", err)
+		}
+	})
 	t.Run("options are nil", func(t *testing.T) {
 		_, err := marshalMqPrio(&MqPrio{})
 		if !errors.Is(err, ErrNoArg) {
@@ -1,11 +1,10 @@
 package tc
 
 import (
-	"bytes"
-	"encoding/binary"
 	"fmt"
+	"time"
 
-	"github.com/mdlayher/netlink"
+	"github.com/florianl/go-tc/core"
 )
 
 const (
@@ -28,77 +27,134 @@ const (
 
 // Netem contains attributes of the netem discipline
 type Netem struct {
-	Qopt      NetemQopt
-	Corr      *NetemCorr
-	DelayDist *[]int16
-	Reorder   *NetemReorder
-	Corrupt   *NetemCorrupt
-	Rate      *NetemRate
-	Ecn       *uint32
-	Rate64    *uint64
-	Latency64 *int64
-	Jitter64  *int64
-	Slot      *NetemSlot
-	PrngSeed  *uint64
+	Qopt      NetemQopt     `json:"qopt,omitempty"`
+	Corr      *NetemCorr    `json:"corr,omitempty"`
+	DelayDist *[]int16      `json:"delay_dist,omitempty"`
+	Reorder   *NetemReorder `json:"reorder,omitempty"`
+	Corrupt   *NetemCorrupt `json:"corrupt,omitempty"`
+	Rate      *NetemRate    `json:"rate,omitempty"`
+	Ecn       *uint32       `json:"ecn,omitempty"`
+	Rate64    *uint64       `json:"rate64,omitempty"`
+	Latency64 *int64        `json:"latency64,omitempty"`
+	Jitter64  *int64        `json:"jitter64,omitempty"`
+	Slot      *NetemSlot    `json:"slot,omitempty"`
+	PrngSeed  *uint64       `json:"prng_seed,omitempty"`
 }
 
 // NetemQopt from include/uapi/linux/pkt_sched.h
 type NetemQopt struct {
-	Latency   uint32
-	Limit     uint32
-	Loss      uint32
-	Gap       uint32
-	Duplicate uint32
-	Jitter    uint32
+	Latency   uint32 `json:"latency,omitempty"`
+	Limit     uint32 `json:"limit,omitempty"`
+	Loss      uint32 `json:"loss,omitempty"`
+	Gap       uint32 `json:"gap,omitempty"`
+	Duplicate uint32 `json:"duplicate,omitempty"`
+	Jitter    uint32 `json:"jitter,omitempty"`
+}
+
+// NetemQoptFromDurations returns a NetemQopt with latency and jitter converted
+// into CPU ticks according to the clock resolution of the system.
+func NetemQoptFromDurations(latency, jitter time.Duration, limit uint32) (NetemQopt, error) {
+	var qopt NetemQopt
+	var err error
+
+	if qopt.Latency, err = core.Duration2Ticks(latency); err != nil {
+		return NetemQopt{}, fmt.Errorf("latency %v: %w", latency, err)
+	}
+	if qopt.Jitter, err = core.Duration2Ticks(jitter); err != nil {
+		return NetemQopt{}, fmt.Errorf("jitter %v: %w", jitter, err)
+	}
+	qopt.Limit = limit
+	return qopt, nil
+}
+
+// SetLossPercent sets the probability of packet loss to p percent.
+func (q *NetemQopt) SetLossPercent(p float64) error {
+	v, err := Percentage(p)
+	if err != nil {
+		return fmt.Errorf("loss: %w", err)
+	}
+	q.Loss = v
+	return nil
+}
+
+// SetDuplicatePercent sets the probability of packet duplication to p percent.
+func (q *NetemQopt) SetDuplicatePercent(p float64) error {
+	v, err := Percentage(p)
+	if err != nil {
+		return fmt.Errorf("duplicate: %w", err)
+	}
+	q.Duplicate = v
+	return nil
 }
 
 // NetemCorr from include/uapi/linux/pkt_sched.h
 type NetemCorr struct {
-	Delay uint32
-	Loss  uint32
-	Dup   uint32
+	Delay uint32 `json:"delay,omitempty"`
+	Loss  uint32 `json:"loss,omitempty"`
+	Dup   uint32 `json:"dup,omitempty"`
 }
 
 // NetemReorder from include/uapi/linux/pkt_sched.h
 type NetemReorder struct {
-	Probability uint32
-	Correlation uint32
+	Probability uint32 `json:"probability,omitempty"`
+	Correlation uint32 `json:"correlation,omitempty"`
+}
+
+// SetProbabilityPercent sets the probability of reordering to p percent.
+func (r *NetemReorder) SetProbabilityPercent(p float64) error {
+	v, err := Percentage(p)
+	if err != nil {
+		return fmt.Errorf("reorder: %w", err)
+	}
+	r.Probability = v
+	return nil
 }
 
 // NetemCorrupt from include/uapi/linux/pkt_sched.h
 type NetemCorrupt struct {
-	Probability uint32
-	Correlation uint32
+	Probability uint32 `json:"probability,omitempty"`
+	Correlation uint32 `json:"correlation,omitempty"`
+}
+
+// SetProbabilityPercent sets the probability of packet corruption to p percent.
+func (c *NetemCorrupt) SetProbabilityPercent(p float64) error {
+	v, err := Percentage(p)
+	if err != nil {
+		return fmt.Errorf("corrupt: %w", err)
+	}
+	c.Probability = v
+	return nil
 }
 
 // NetemRate from include/uapi/linux/pkt_sched.h
 type NetemRate struct {
-	Rate           uint32
-	PacketOverhead int32
-	CellSize       int32
-	CellOverhead   int32
+	Rate           uint32 `json:"rate,omitempty"`
+	PacketOverhead int32  `json:"packet_overhead,omitempty"`
+	CellSize       int32  `json:"cell_size,omitempty"`
+	CellOverhead   int32  `json:"cell_overhead,omitempty"`
 }
 
 // NetemSlot from include/uapi/linux/pkt_sched.h
 type NetemSlot struct {
-	MinDelay   int64
-	MaxDelay   int64
-	MaxPackets int32
-	MaxBytes   int32
-	DistDelay  int64
-	DistJitter int64
+	MinDelay   int64 `json:"min_delay,omitempty"`
+	MaxDelay   int64 `json:"max_delay,omitempty"`
+	MaxPackets int32 `json:"max_packets,omitempty"`
+	MaxBytes   int32 `json:"max_bytes,omitempty"`
+	DistDelay  int64 `json:"dist_delay,omitempty"`
+	DistJitter int64 `json:"dist_jitter,omitempty"`
 }
 
 // unmarshalNetem parses the Netem-encoded data and stores the result in the value pointed to by info.
 func unmarshalNetem(data []byte, info *Netem) error {
 	qopt := NetemQopt{}
-	if err := unmarshalStruct(data, &qopt); err != nil {
+	attrs, err := unmarshalStructAttrs(data, &qopt)
+	if err != nil {
 		return err
 	}
 	info.Qopt = qopt
 
-	// continue decoding attributes after the size of the NetemQopt struct
-	ad, err := netlink.NewAttributeDecoder(data[24:])
+	// continue decoding attributes after the NetemQopt struct
+	ad, err := newDecoder(attrs)
 	if err != nil {
 		return err
 	}
@@ -111,11 +167,11 @@ func unmarshalNetem(data []byte, info *Netem) error {
 			multiError = concatError(multiError, err)
 			info.Corr = tmp
 		case tcaNetemDelayDist:
-			size := len(ad.Bytes()) / 2
-			dist := make([]int16, size)
-			reader := bytes.NewReader(ad.Bytes())
-			err := binary.Read(reader, nativeEndian, dist)
-			multiError = concatError(multiError, err)
+			data := ad.Bytes()
+			dist := make([]int16, len(data)/2)
+			for i := range dist {
+				dist[i] = int16(nativeEndian.Uint16(data[2*i:]))
+			}
 			info.DelayDist = &dist
 		case tcaNetemReorder:
 			tmp := &NetemReorder{}
@@ -159,7 +215,7 @@ func unmarshalNetem(data []byte, info *Netem) error {
 			tmp := ad.Uint64()
 			info.PrngSeed = &tmp
 		default:
-			return fmt.Errorf("unmarshalNetem()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("netem", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -179,10 +235,11 @@ func marshalNetem(info *Netem) ([]byte, error) {
 		options = append(options, tcOption{Interpretation: vtBytes, Type: tcaNetemCorr, Data: data})
 	}
 	if info.DelayDist != nil {
-		buf := new(bytes.Buffer)
-		err := binary.Write(buf, nativeEndian, *info.DelayDist)
-		multiError = concatError(multiError, err)
-		options = append(options, tcOption{Interpretation: vtBytes, Type: tcaNetemDelayDist, Data: buf.Bytes()})
+		data := make([]byte, 2*len(*info.DelayDist))
+		for i, v := range *info.DelayDist {
+			nativeEndian.PutUint16(data[2*i:], uint16(v))
+		}
+		options = append(options, tcOption{Interpretation: vtBytes, Type: tcaNetemDelayDist, Data: data})
 	}
 	if info.Reorder != nil {
 		data, err := marshalStruct(info.Reorder)
@@ -220,14 +277,18 @@ func marshalNetem(info *Netem) ([]byte, error) {
 		options = append(options, tcOption{Interpretation: vtUint64, Type: tcaNetemPrngSeed, Data: *info.PrngSeed})
 	}
 
+	if multiError != nil {
+		return []byte{}, multiError
+	}
 	data, err := marshalAttributes(options)
-	multiError = concatError(multiError, err)
+	if err != nil {
+		return []byte{}, err
+	}
 
-	var qoptErr error
-	var qoptData []byte
-	if qoptData, qoptErr = marshalStruct(info.Qopt); qoptErr != nil {
-		return []byte{}, qoptErr
+	qoptData, err := marshalStruct(info.Qopt)
+	if err != nil {
+		return []byte{}, err
 	}
 
-	return append(qoptData[:], data[:]...), multiError
+	return append(qoptData, data...), nil
 }
@@ -2,11 +2,14 @@ package tc
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"testing"
+	"time"
 
 	"github.com/florianl/go-tc/core"
 	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/netlink"
 )
 
 func TestNetem(t *testing.T) {
@@ -57,9 +60,84 @@ func TestNetem(t *testing.T) {
 			t.Fatalf("unexpected error: %v", err)
 		}
 	})
+	t.Run("corrupt attributes", func(t *testing.T) {
+		qopt, err := marshalStruct(&NetemQopt{Latency: 42})
+		if err != nil {
+			t.Fatalf("could not marshal qopt: %v", err)
+		}
+		for name, typ := range map[string]uint16{
+			"corr":    tcaNetemCorr,
+			"reorder": tcaNetemReorder,
+			"corrupt": tcaNetemCorrupt,
+			"rate":    tcaNetemRate,
+			"slot":    tcaNetemSlot,
+		} {
+			// Every attribute is followed by a valid one, so that a lost
+			// error would not be hidden by the error of the last attribute.
+			attrs, err := netlink.MarshalAttributes([]netlink.Attribute{
+				{Type: typ, Data: []byte{0x1, 0x2}},
+				{Type: tcaNetemEcn, Data: []byte{0x1, 0x0, 0x0, 0x0}},
+			})
+			if err != nil {
+				t.Fatalf("could not marshal attributes: %v", err)
+			}
+			val := Netem{}
+			if err := unmarshalNetem(append(qopt, attrs...), &val); !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Fatalf("%s: expected io.ErrUnexpectedEOF but got %v", name, err)
+			}
+		}
+	})
+	t.Run("truncated qopt", func(t *testing.T) {
+		data, err := marshalNetem(&Netem{Qopt: NetemQopt{Latency: 42}, Ecn: uint32Ptr(1)})
+		if err != nil {
+			t.Fatalf("could not marshal netem: %v", err)
+		}
+		if err := unmarshalNetem(data[:20], &Netem{}); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("expected io.ErrUnexpectedEOF but got %v", err)
+		}
+	})
 	t.Run("nil-unmarshalNetem", func(t *testing.T) {
 		if err := unmarshalNetem([]byte{}, nil); !errors.Is(err, io.EOF) {
 			t.Fatalf("unexpected error: %v", err)
 		}
 	})
 }
+
+func TestNetemQoptFromDurations(t *testing.T) {
+	restore, err := core.SetClock(0x3e8, 0x40, 0xf4240)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restore()
+
+	qopt, err := NetemQoptFromDurations(100*time.Millisecond, 10*time.Millisecond, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := NetemQopt{Latency: 1562500, Jitter: 156250, Limit: 1000}
+	if diff := cmp.Diff(expected, qopt); diff != "" {
+		t.Fatalf("NetemQopt missmatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := NetemQoptFromDurations(73*time.Minute, 0, 0); err == nil {
+		t.Fatal("expected error for a latency out of range")
+	}
+}
+
+func BenchmarkMarshalNetemDist(b *testing.B) {
+	for _, size := range []int{256, 4096} {
+		dist := make([]int16, size)
+		for i := range dist {
+			dist[i] = int16(i - len(dist)/2)
+		}
+		info := &Netem{Qopt: NetemQopt{Latency: 15625, Limit: 1000}, DelayDist: &dist}
+		b.Run(fmt.Sprintf("%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := marshalNetem(info); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaPieUnspec = iota
@@ -20,22 +16,23 @@ const (
 
 // Pie contains attributes of the pie discipline
 type Pie struct {
-	Target          *uint32
-	Limit           *uint32
-	TUpdate         *uint32
-	Alpha           *uint32
-	Beta            *uint32
-	ECN             *uint32
-	Bytemode        *uint32
-	DqRateEstimator *uint32
+	Target          *uint32 `json:"target,omitempty"`
+	Limit           *uint32 `json:"limit,omitempty"`
+	TUpdate         *uint32 `json:"t_update,omitempty"`
+	Alpha           *uint32 `json:"alpha,omitempty"`
+	Beta            *uint32 `json:"beta,omitempty"`
+	ECN             *uint32 `json:"ecn,omitempty"`
+	Bytemode        *uint32 `json:"bytemode,omitempty"`
+	DqRateEstimator *uint32 `json:"dq_rate_estimator,omitempty"`
 }
 
 // unmarshalPie parses the Pie-encoded data and stores the result in the value pointed to by info.
 func unmarshalPie(data []byte, info *Pie) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
+	var multiError error
 	for ad.Next() {
 		switch ad.Type() {
 		case tcaPieTarget:
@@ -55,10 +52,10 @@ func unmarshalPie(data []byte, info *Pie) error {
 		case tcaPieDqRateEstimator:
 			info.DqRateEstimator = uint32Ptr(ad.Uint32())
 		default:
-			return fmt.Errorf("extractPieOptions()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("pie", ad.Type(), ad.Bytes()))
 		}
 	}
-	return ad.Err()
+	return concatError(multiError, ad.Err())
 }
 
 // marshalPie returns the binary encoding of Qfq
@@ -15,8 +15,8 @@ const (
 
 // Plug contains attributes of the plug discipline
 type Plug struct {
-	Action PlugAction
-	Limit  uint32
+	Action PlugAction `json:"action,omitempty"`
+	Limit  uint32     `json:"limit,omitempty"`
 }
 
 func marshalPlug(info *Plug) ([]byte, error) {
@@ -4,8 +4,8 @@ import "fmt"
 
 // Prio contains attributes of the prio discipline
 type Prio struct {
-	Bands   uint32
-	PrioMap [16]uint8
+	Bands   uint32  `json:"bands,omitempty"`
+	PrioMap Priomap `json:"prio_map,omitempty"`
 }
 
 // unmarshalPrio parses the Prio-encoded data and stores the result in the value pointed to by info.
@@ -18,5 +18,8 @@ func marshalPrio(info *Prio) ([]byte, error) {
 	if info == nil {
 		return []byte{}, fmt.Errorf("Prio: %w", ErrNoArg)
 	}
+	if err := info.PrioMap.Validate(info.Bands); err != nil {
+		return []byte{}, err
+	}
 	return marshalStruct(info)
 }
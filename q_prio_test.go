@@ -17,6 +17,7 @@ func TestPrio(t *testing.T) {
 			Bands:   3,
 			PrioMap: [16]uint8{1, 2, 2, 2, 1, 2, 0, 0, 1, 1, 1, 1, 1, 1, 1, 1},
 		}},
+		"band beyond bands": {val: Prio{Bands: 2, PrioMap: DefaultPriomap()}, err1: ErrInvalidArg},
 	}
 
 	for name, testcase := range tests {
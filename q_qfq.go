@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaQfqUnspec = iota
@@ -14,16 +10,17 @@ const (
 
 // Qfq contains attributes of the qfq discipline
 type Qfq struct {
-	Weight *uint32
-	Lmax   *uint32
+	Weight *uint32 `json:"weight,omitempty"`
+	Lmax   *uint32 `json:"lmax,omitempty"`
 }
 
 // unmarshalQfq parses the Qfq-encoded data and stores the result in the value pointed to by info.
 func unmarshalQfq(data []byte, info *Qfq) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
+	var multiError error
 	for ad.Next() {
 		switch ad.Type() {
 		case tcaQfqWeight:
@@ -31,10 +28,10 @@ func unmarshalQfq(data []byte, info *Qfq) error {
 		case tcaQfqLmax:
 			info.Lmax = uint32Ptr(ad.Uint32())
 		default:
-			return fmt.Errorf("UnmarshalQfq()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("qfq", ad.Type(), ad.Bytes()))
 		}
 	}
-	return ad.Err()
+	return concatError(multiError, ad.Err())
 }
 
 // marshalQfq returns the binary encoding of Qfq
@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaRedUnspec = iota
@@ -15,13 +11,24 @@ const (
 
 // Red contains attributes of the red discipline
 type Red struct {
-	Parms *RedQOpt
-	MaxP  *uint32
+	Parms *RedQOpt `json:"parms,omitempty"`
+	Stab  *[]byte  `json:"stab,omitempty"`
+	MaxP  *uint32  `json:"max_p,omitempty"`
+}
+
+// SetMaxProbability sets MaxP to the probability p in the range of [0, 1].
+func (r *Red) SetMaxProbability(p float64) error {
+	v, err := RedProbability(p)
+	if err != nil {
+		return fmt.Errorf("Red: %w", err)
+	}
+	r.MaxP = uint32Ptr(v)
+	return nil
 }
 
 // unmarshalRed parses the Red-encoded data and stores the result in the value pointed to by info.
 func unmarshalRed(data []byte, info *Red) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -32,10 +39,12 @@ func unmarshalRed(data []byte, info *Red) error {
 			opt := &RedQOpt{}
 			multiError = unmarshalStruct(ad.Bytes(), opt)
 			info.Parms = opt
+		case tcaRedStab:
+			info.Stab = bytesPtr(ad.Bytes())
 		case tcaRedMaxP:
 			info.MaxP = uint32Ptr(ad.Uint32())
 		default:
-			return fmt.Errorf("unmarshalRed()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("red", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -57,6 +66,9 @@ func marshalRed(info *Red) ([]byte, error) {
 		}
 		options = append(options, tcOption{Interpretation: vtBytes, Type: tcaRedParms, Data: data})
 	}
+	if info.Stab != nil {
+		options = append(options, tcOption{Interpretation: vtBytes, Type: tcaRedStab, Data: bytesValue(info.Stab)})
+	}
 	if info.MaxP != nil {
 		options = append(options, tcOption{Interpretation: vtUint32, Type: tcaRedMaxP, Data: uint32Value(info.MaxP)})
 	}
@@ -65,11 +77,11 @@ func marshalRed(info *Red) ([]byte, error) {
 
 // RedQOpt from include/uapi/linux/pkt_sched.h
 type RedQOpt struct {
-	Limit    uint32
-	QthMin   uint32
-	QthMax   uint32
-	Wlog     byte
-	Plog     byte
-	ScellLog byte
-	Flags    byte
+	Limit    uint32 `json:"limit,omitempty"`
+	QthMin   uint32 `json:"qth_min,omitempty"`
+	QthMax   uint32 `json:"qth_max,omitempty"`
+	Wlog     byte   `json:"wlog,omitempty"`
+	Plog     byte   `json:"plog,omitempty"`
+	ScellLog byte   `json:"scell_log,omitempty"`
+	Flags    byte   `json:"flags,omitempty"`
 }
@@ -14,6 +14,7 @@ func TestRed(t *testing.T) {
 		err2 error
 	}{
 		"simple": {val: Red{MaxP: uint32Ptr(2), Parms: &RedQOpt{QthMin: 2, QthMax: 4}}},
+		"stab":   {val: Red{Parms: &RedQOpt{QthMin: 2, QthMax: 4}, Stab: &[]byte{0, 1, 2, 31}}},
 	}
 
 	for name, testcase := range tests {
@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaSfbUnspec = iota
@@ -13,12 +9,12 @@ const (
 
 // Sfb contains attributes of the SBF discipline
 type Sfb struct {
-	Parms *SfbQopt
+	Parms *SfbQopt `json:"parms,omitempty"`
 }
 
 // unmarshalSfb parses the Sfb-encoded data and stores the result in the value pointed to by info.
 func unmarshalSfb(data []byte, info *Sfb) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -30,7 +26,7 @@ func unmarshalSfb(data []byte, info *Sfb) error {
 			multiError = unmarshalStruct(ad.Bytes(), opt)
 			info.Parms = opt
 		default:
-			return fmt.Errorf("extractSfbOptions()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("sfb", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -58,13 +54,13 @@ func marshalSfb(info *Sfb) ([]byte, error) {
 
 // SfbQopt from include/uapi/linux/pkt_sched.h
 type SfbQopt struct {
-	RehashInterval uint32 // in ms
-	WarmupTime     uint32 //  in ms
-	Max            uint32
-	BinSize        uint32
-	Increment      uint32
-	Decrement      uint32
-	Limit          uint32
-	PenaltyRate    uint32
-	PenaltyBurst   uint32
+	RehashInterval uint32 `json:"rehash_interval,omitempty"` // in ms
+	WarmupTime     uint32 `json:"warmup_time,omitempty"`     //  in ms
+	Max            uint32 `json:"max,omitempty"`
+	BinSize        uint32 `json:"bin_size,omitempty"`
+	Increment      uint32 `json:"increment,omitempty"`
+	Decrement      uint32 `json:"decrement,omitempty"`
+	Limit          uint32 `json:"limit,omitempty"`
+	PenaltyRate    uint32 `json:"penalty_rate,omitempty"`
+	PenaltyBurst   uint32 `json:"penalty_burst,omitempty"`
 }
@@ -2,34 +2,36 @@ package tc
 
 import (
 	"fmt"
+
+	"github.com/florianl/go-tc/core"
 )
 
 // SfqQopt contains SFQ attributes
 type SfqQopt struct {
-	Quantum       uint32 /* Bytes per round allocated to flow */
-	PerturbPeriod int32  /* Period of hash perturbation */
-	Limit         uint32 /* Maximal packets in queue */
-	Divisor       uint32 /* Hash divisor  */
-	Flows         uint32 /* Maximal number of flows  */
+	Quantum       uint32 `json:"quantum,omitempty"`        /* Bytes per round allocated to flow */
+	PerturbPeriod int32  `json:"perturb_period,omitempty"` /* Period of hash perturbation */
+	Limit         uint32 `json:"limit,omitempty"`          /* Maximal packets in queue */
+	Divisor       uint32 `json:"divisor,omitempty"`        /* Hash divisor  */
+	Flows         uint32 `json:"flows,omitempty"`          /* Maximal number of flows  */
 }
 
 // Sfq contains attributes of the SFQ discipline
 // https://man7.org/linux/man-pages/man8/sfq.8.html
 type Sfq struct {
-	V0 SfqQopt
+	V0 SfqQopt `json:"v0,omitempty"`
 
-	Depth    uint32 /* max number of packets per flow */
-	Headdrop uint32
+	Depth    uint32 `json:"depth,omitempty"` /* max number of packets per flow */
+	Headdrop uint32 `json:"headdrop,omitempty"`
 
 	/* SFQRED parameters */
-	Limit    uint32 /* HARD maximal flow queue length (bytes) */
-	QthMin   uint32 /* Min average length threshold (bytes) */
-	QthMax   uint32 /* Max average length threshold (bytes) */
-	Wlog     uint8  /* log(W)		*/
-	Plog     uint8  /* log(P_max/(qth_max-qth_min))	*/
-	ScellLog uint8  /* cell size for idle damping */
-	Flags    uint8
-	MaxP     uint32 /* probability, high resolution */
+	Limit    uint32 `json:"limit,omitempty"`     /* HARD maximal flow queue length (bytes) */
+	QthMin   uint32 `json:"qth_min,omitempty"`   /* Min average length threshold (bytes) */
+	QthMax   uint32 `json:"qth_max,omitempty"`   /* Max average length threshold (bytes) */
+	Wlog     uint8  `json:"wlog,omitempty"`      /* log(W)		*/
+	Plog     uint8  `json:"plog,omitempty"`      /* log(P_max/(qth_max-qth_min))	*/
+	ScellLog uint8  `json:"scell_log,omitempty"` /* cell size for idle damping */
+	Flags    uint8  `json:"flags,omitempty"`
+	MaxP     uint32 `json:"max_p,omitempty"` /* probability, high resolution */
 }
 
 // unmarshalSfq parses the Sfq-encoded data and stores the result in the value pointed to by info.
@@ -46,3 +48,49 @@ func marshalSfq(info *Sfq) ([]byte, error) {
 	// TODO: improve logic and check combinations
 	return marshalStruct(info)
 }
+
+// SfqFlow is a flow of a sfq qdisc, that holds packets.
+//
+// The kernel reports such flows as classes of the qdisc, see sfq_walk() of
+// linux/net/sched/sch_sfq.c. Class().Get returns a class for every hash
+// bucket with packets. The major of its handle is the major of the qdisc and
+// the minor is the index of the bucket plus one. The parent is the handle of
+// the qdisc. The classes have no options. Their statistics contain the
+// packets in the bucket and SfqXStats with the allot of the flow. Filters of
+// the qdisc can assign packets to a bucket with such a handle as ClassID.
+type SfqFlow struct {
+	// Bucket is the index of the hash bucket of the flow.
+	Bucket uint32
+	// Allot is the number of bytes the flow may still send in the current
+	// round. It becomes negative, if a packet exceeded it.
+	Allot   int32
+	Packets uint32
+	Bytes   uint32
+}
+
+// SfqFlows returns the flows of the sfq qdisc with handle on ifindex from
+// classes, as returned by Class().Get. Classes of other qdiscs are skipped.
+// With Config.LazyStats, the statistics are decoded without changing classes.
+func SfqFlows(classes []Object, ifindex, handle uint32) ([]SfqFlow, error) {
+	var flows []SfqFlow
+	for i := range classes {
+		class := classes[i]
+		_, minor := core.SplitHandle(class.Handle)
+		if class.Ifindex != ifindex || class.Parent != handle || class.Kind != "sfq" || minor == 0 {
+			continue
+		}
+		if err := class.DecodeStats(); err != nil {
+			return nil, fmt.Errorf("%s: %w", &classes[i], err)
+		}
+		if err := class.DecodeXStats(); err != nil {
+			return nil, fmt.Errorf("%s: %w", &classes[i], err)
+		}
+		if class.XStats == nil || class.XStats.Sfq == nil {
+			return nil, fmt.Errorf("%s: no sfq statistics: %w", &classes[i], ErrInvalidArg)
+		}
+		flow := SfqFlow{Bucket: minor - 1, Allot: class.XStats.Sfq.Allot}
+		flow.Packets, flow.Bytes, _ = class.Backlog()
+		flows = append(flows, flow)
+	}
+	return flows, nil
+}
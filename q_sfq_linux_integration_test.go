@@ -0,0 +1,117 @@
+//go:build integration && linux
+// +build integration,linux
+
+package tc
+
+import (
+	"net"
+	"testing"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/jsimonetti/rtnetlink"
+	"golang.org/x/sys/unix"
+)
+
+func TestLinuxSfqFlows(t *testing.T) {
+	tcIface := "tcSfqFlows"
+	packets := 4
+
+	rtnl, err := setupDummyInterface(tcIface)
+	if err != nil {
+		t.Skipf("could not setup dummy interface: %v", err)
+	}
+	defer rtnl.Close()
+	devID, err := net.InterfaceByName(tcIface)
+	if err != nil {
+		t.Fatalf("could not get interface ID: %v", err)
+	}
+	ifindex := uint32(devID.Index)
+	defer func() {
+		if err := rtnl.Link.Delete(ifindex); err != nil {
+			t.Fatalf("could not delete interface: %v", err)
+		}
+	}()
+	if err := rtnl.Address.New(&rtnetlink.AddressMessage{
+		Family:       unix.AF_INET,
+		PrefixLength: 24,
+		Index:        ifindex,
+		Attributes: &rtnetlink.AddressAttributes{
+			Address: net.IPv4(198, 51, 100, 1).To4(),
+			Local:   net.IPv4(198, 51, 100, 1).To4(),
+		},
+	}); err != nil {
+		t.Fatalf("could not add address: %v", err)
+	}
+
+	tcnl, err := Open(&Config{})
+	if err != nil {
+		t.Fatalf("could not open rtnetlink socket: %v", err)
+	}
+	defer func() {
+		if err := tcnl.Close(); err != nil {
+			t.Fatalf("could not close rtnetlink socket: %v", err)
+		}
+	}()
+
+	// The dummy device drops packets immediately. tbf with 8kbit holds them
+	// back, so that the flows of sfq beneath it stay active.
+	rate, err := NewRateSpec(1000, 1514, LinklayerEthernet, 0, 0)
+	if err != nil {
+		t.Fatalf("could not compute rate: %v", err)
+	}
+	root := core.BuildHandle(0x1, 0x0)
+	if err := tcnl.Qdisc().Add(&Object{
+		Msg{Family: unix.AF_UNSPEC, Ifindex: ifindex, Handle: root, Parent: HandleRoot},
+		Attribute{Kind: "tbf", Tbf: &Tbf{
+			Parms: &TbfQopt{Rate: rate, Limit: 100000, Buffer: CalcXmitTime(1000, 1600), Mtu: 1514},
+			Burst: uint32Ptr(1600),
+		}},
+	}); err != nil {
+		t.Fatalf("could not add tbf qdisc: %v", err)
+	}
+	sfq := core.BuildHandle(0x10, 0x0)
+	if err := tcnl.Qdisc().Add(&Object{
+		Msg{Family: unix.AF_UNSPEC, Ifindex: ifindex, Handle: sfq, Parent: core.BuildHandle(0x1, 0x1)},
+		Attribute{Kind: "sfq", Sfq: &Sfq{V0: SfqQopt{Limit: 127, Divisor: 1024}}},
+	}); err != nil {
+		t.Skipf("could not add sfq qdisc: %v", err)
+	}
+
+	// Two flows, that are hashed into the buckets of sfq.
+	for _, port := range []int{9, 10} {
+		conn, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: net.IPv4(198, 51, 100, 2), Port: port})
+		if err != nil {
+			t.Fatalf("could not open socket: %v", err)
+		}
+		for i := 0; i < packets; i++ {
+			if _, err := conn.Write(make([]byte, 1000)); err != nil {
+				t.Fatalf("could not send packet: %v", err)
+			}
+		}
+		conn.Close()
+	}
+
+	classes, err := tcnl.Class().Get(&Msg{Family: unix.AF_UNSPEC, Ifindex: ifindex})
+	if err != nil {
+		t.Fatalf("could not get classes: %v", err)
+	}
+	flows, err := SfqFlows(classes, ifindex, sfq)
+	if err != nil {
+		t.Fatalf("could not get flows: %v", err)
+	}
+	if len(flows) == 0 {
+		t.Fatalf("expected active flows but got the classes %v", classes)
+	}
+	var queued uint32
+	for _, flow := range flows {
+		if flow.Bucket >= 1024 || flow.Packets == 0 || flow.Bytes == 0 {
+			t.Fatalf("unexpected flow %+v", flow)
+		}
+		queued += flow.Packets
+	}
+	// tbf may have sent the first packet already. The device can also send
+	// packets of its own, like IPv6 router solicitations.
+	if queued < uint32(2*packets-1) {
+		t.Fatalf("expected at least %d queued packets but got %d in %+v", 2*packets-1, queued, flows)
+	}
+}
@@ -1,10 +1,18 @@
 package tc
 
 import (
+	"bufio"
+	"encoding/hex"
 	"errors"
+	"os"
+	"strings"
 	"testing"
 
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
 	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nltest"
 )
 
 func TestSfq(t *testing.T) {
@@ -50,3 +58,121 @@ func TestSfq(t *testing.T) {
 		}
 	})
 }
+
+// sfqClasses returns the payloads of the class dump in testdata.
+func sfqClasses(t *testing.T) [][]byte {
+	t.Helper()
+	f, err := os.Open("testdata/sfq_classes.txt")
+	if err != nil {
+		t.Fatalf("could not open fixtures: %v", err)
+	}
+	defer f.Close()
+
+	var payloads [][]byte
+	var class string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "#"):
+		case class == "":
+			class = line
+		default:
+			data, err := hex.DecodeString(line)
+			if err != nil {
+				t.Fatalf("%s: %v", class, err)
+			}
+			payloads = append(payloads, data)
+			class = ""
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("could not read fixtures: %v", err)
+	}
+	return payloads
+}
+
+func TestSfqFlows(t *testing.T) {
+	qdisc := core.BuildHandle(0x10, 0x0)
+	flows := []SfqFlow{
+		{Bucket: 0x2a, Allot: -1302, Packets: 1, Bytes: 1514},
+		{Bucket: 0x3ff, Allot: 1514, Packets: 3, Bytes: 4542},
+	}
+
+	tests := map[string]struct {
+		lazyStats bool
+		// withoutXStats drops TCA_PAD and TCA_XSTATS at the end of the
+		// payloads, like kernels do, that emit only TCA_STATS_APP.
+		withoutXStats bool
+	}{
+		"eager":               {},
+		"lazy":                {lazyStats: true},
+		"without xstats":      {withoutXStats: true},
+		"lazy without xstats": {lazyStats: true, withoutXStats: true},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			tcSocket := &Tc{con: nltest.Dial(func(req []netlink.Message) ([]netlink.Message, error) {
+				if req[0].Header.Type != unix.RTM_GETTCLASS {
+					t.Fatalf("expected RTM_GETTCLASS but got %d", req[0].Header.Type)
+				}
+				var msgs []netlink.Message
+				for _, data := range sfqClasses(t) {
+					if testcase.withoutXStats {
+						data = data[:len(data)-12]
+					}
+					msgs = append(msgs, netlink.Message{Header: netlink.Header{Type: unix.RTM_NEWTCLASS}, Data: data})
+				}
+				return swapDump(msgs), nil
+			}), lazyStats: testcase.lazyStats}
+			defer tcSocket.Close()
+
+			classes, err := tcSocket.Class().Get(&Msg{Family: unix.AF_UNSPEC, Ifindex: 2})
+			if err != nil {
+				t.Fatalf("could not get classes: %v", err)
+			}
+			var handles []uint32
+			for _, class := range classes {
+				if class.Parent != qdisc || class.Kind != "sfq" {
+					t.Fatalf("expected a sfq class of %s but got %s", core.FormatHandle(qdisc), &class)
+				}
+				handles = append(handles, class.Handle)
+			}
+			if diff := cmp.Diff([]uint32{core.BuildHandle(0x10, 0x2b), core.BuildHandle(0x10, 0x400)}, handles); diff != "" {
+				t.Fatalf("handles missmatch (-want +got):\n%s", diff)
+			}
+
+			got, err := SfqFlows(classes, 2, qdisc)
+			if err != nil {
+				t.Fatalf("could not get flows: %v", err)
+			}
+			if diff := cmp.Diff(flows, got); diff != "" {
+				t.Fatalf("flows missmatch (-want +got):\n%s", diff)
+			}
+			if testcase.lazyStats && (classes[0].Stats2 != nil || classes[0].XStats != nil) {
+				t.Fatalf("expected the classes to be unchanged but got %#v", classes[0].Attribute)
+			}
+		})
+	}
+
+	t.Run("other qdisc", func(t *testing.T) {
+		classes := []Object{
+			{Msg: Msg{Ifindex: 2, Handle: core.BuildHandle(0x1, 0x1), Parent: core.BuildHandle(0x1, 0x0)},
+				Attribute: Attribute{Kind: "htb"}},
+			{Msg: Msg{Ifindex: 3, Handle: core.BuildHandle(0x10, 0x1), Parent: qdisc},
+				Attribute: Attribute{Kind: "sfq"}},
+		}
+		got, err := SfqFlows(classes, 2, qdisc)
+		if err != nil || len(got) != 0 {
+			t.Fatalf("expected no flows but got %v: %v", got, err)
+		}
+	})
+	t.Run("without statistics", func(t *testing.T) {
+		classes := []Object{{Msg: Msg{Ifindex: 2, Handle: core.BuildHandle(0x10, 0x1), Parent: qdisc},
+			Attribute: Attribute{Kind: "sfq"}}}
+		if _, err := SfqFlows(classes, 2, qdisc); !errors.Is(err, ErrInvalidArg) {
+			t.Fatalf("expected ErrInvalidArg but got %v", err)
+		}
+	})
+}
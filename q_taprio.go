@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaTaPrioUnspec           = iota
@@ -24,18 +20,18 @@ const (
 
 // TaPrio contains TaPrio attributes
 type TaPrio struct {
-	PrioMap                 *MqPrioQopt
-	SchedBaseTime           *int64
-	SchedClockID            *int32
-	SchedCycleTime          *int64
-	SchedCycleTimeExtension *int64
-	Flags                   *uint32
-	TxTimeDelay             *uint32
+	PrioMap                 *MqPrioQopt `json:"prio_map,omitempty"`
+	SchedBaseTime           *int64      `json:"sched_base_time,omitempty"`
+	SchedClockID            *int32      `json:"sched_clock_id,omitempty"`
+	SchedCycleTime          *int64      `json:"sched_cycle_time,omitempty"`
+	SchedCycleTimeExtension *int64      `json:"sched_cycle_time_extension,omitempty"`
+	Flags                   *uint32     `json:"flags,omitempty"`
+	TxTimeDelay             *uint32     `json:"tx_time_delay,omitempty"`
 }
 
 // unmarshalTaPrio parses the TaPrio-encoded data and stores the result in the value pointed to by info.
 func unmarshalTaPrio(data []byte, info *TaPrio) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -62,7 +58,7 @@ func unmarshalTaPrio(data []byte, info *TaPrio) error {
 		case tcaTaPrioPad:
 			// padding does not contain data, we just skip it
 		default:
-			return fmt.Errorf("unmarshalTaPrio()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("taprio", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaTbfUnspec = iota
@@ -20,14 +16,14 @@ const (
 
 // Tbf contains attributes of the TBF discipline
 type Tbf struct {
-	Parms  *TbfQopt
-	Burst  *uint32
-	Pburst *uint32
+	Parms  *TbfQopt `json:"parms,omitempty"`
+	Burst  *uint32  `json:"burst,omitempty"`
+	Pburst *uint32  `json:"pburst,omitempty"`
 }
 
 // unmarshalTbf parses the FqCodel-encoded data and stores the result in the value pointed to by info.
 func unmarshalTbf(data []byte, info *Tbf) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -46,7 +42,7 @@ func unmarshalTbf(data []byte, info *Tbf) error {
 		case tcaTbfPad:
 			// padding does not contain data, we just skip it
 		default:
-			return fmt.Errorf("unmarshalTbf()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("tbf", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -66,7 +62,7 @@ func marshalTbf(info *Tbf) ([]byte, error) {
 		ratePolicy.Burst = uint32Value(info.Burst)
 		ratePolicy.Action = PolicyOk
 		ratePolicy.Limit = info.Parms.Limit
-		ratePolicy.Rate.Rate = info.Parms.Rate.Rate
+		ratePolicy.Rate = info.Parms.Rate
 
 		rtab, err := generateRateTable(&ratePolicy)
 		multiError = concatError(multiError, err)
@@ -77,7 +73,7 @@ func marshalTbf(info *Tbf) ([]byte, error) {
 		ratePolicy.Burst = uint32Value(info.Pburst)
 		ratePolicy.Action = PolicyOk
 		ratePolicy.Limit = info.Parms.Limit
-		ratePolicy.PeakRate.Rate = info.Parms.PeakRate.Rate
+		ratePolicy.PeakRate = info.Parms.PeakRate
 
 		ptab, err := generateRateTable(&ratePolicy)
 		multiError = concatError(multiError, err)
@@ -103,9 +99,9 @@ func marshalTbf(info *Tbf) ([]byte, error) {
 
 // TbfQopt from include/uapi/linux/pkt_sched.h
 type TbfQopt struct {
-	Rate     RateSpec
-	PeakRate RateSpec
-	Limit    uint32
-	Buffer   uint32
-	Mtu      uint32
+	Rate     RateSpec `json:"rate,omitempty"`
+	PeakRate RateSpec `json:"peak_rate,omitempty"`
+	Limit    uint32   `json:"limit,omitempty"`
+	Buffer   uint32   `json:"buffer,omitempty"`
+	Mtu      uint32   `json:"mtu,omitempty"`
 }
@@ -10,58 +10,91 @@ import (
 // Qdisc represents the queueing discipline part of traffic control
 type Qdisc struct {
 	Tc
+
+	ignoreMissing bool
+	dumpInvisible bool
 }
 
 // Qdisc allows to read and alter queues
 func (tc *Tc) Qdisc() *Qdisc {
-	return &Qdisc{*tc}
+	return &Qdisc{Tc: *tc}
 }
 
-// Add creates a new queueing discipline
+// Add creates a new queueing discipline. With Config.VerifyWrites, a
+// *ConflictError names the existing qdisc, if the kernel refused it with
+// EEXIST.
 func (qd *Qdisc) Add(info *Object) error {
 	if info == nil {
 		return ErrNoArg
 	}
+	if err := qd.validateQdisc(info); err != nil {
+		return err
+	}
 	options, err := validateQdiscObject(unix.RTM_NEWQDISC, info)
 	if err != nil {
 		return err
 	}
-	return qd.action(unix.RTM_NEWQDISC, netlink.Create|netlink.Excl, &info.Msg, options)
+	if err := qd.action(unix.RTM_NEWQDISC, netlink.Create|netlink.Excl, &info.Msg, options); err != nil {
+		return qd.explainConflict(info, err)
+	}
+	return qd.verifyWrite(unix.RTM_GETQDISC, info)
 }
 
-// Replace add/remove a queueing discipline. If the node does not exist yet it is created
+// Replace add/remove a queueing discipline. If the node does not exist yet it is created.
+// With Config.OrphanCheck, an *OrphanError is returned instead of destroying
+// the objects beneath the replaced queueing discipline.
 func (qd *Qdisc) Replace(info *Object) error {
 	if info == nil {
 		return ErrNoArg
 	}
+	if err := qd.validateQdisc(info); err != nil {
+		return err
+	}
 	options, err := validateQdiscObject(unix.RTM_NEWQDISC, info)
 	if err != nil {
 		return err
 	}
-	return qd.action(unix.RTM_NEWQDISC, netlink.Create|netlink.Replace, &info.Msg, options)
+	if err := qd.checkOrphans("replace", info); err != nil {
+		return err
+	}
+	if err := qd.action(unix.RTM_NEWQDISC, netlink.Create|netlink.Replace, &info.Msg, options); err != nil {
+		return err
+	}
+	return qd.verifyWrite(unix.RTM_GETQDISC, info)
 }
 
-// Link performs a replace on an existing queueing discipline
+// Link performs a replace on an existing queueing discipline. Like Replace, it
+// is checked with Config.OrphanCheck.
 func (qd *Qdisc) Link(info *Object) error {
 	if info == nil {
 		return ErrNoArg
 	}
+	if err := qd.validateQdisc(info); err != nil {
+		return err
+	}
 	options, err := validateQdiscObject(unix.RTM_NEWQDISC, info)
 	if err != nil {
 		return err
 	}
+	if err := qd.checkOrphans("replace", info); err != nil {
+		return err
+	}
 	return qd.action(unix.RTM_NEWQDISC, netlink.Replace, &info.Msg, options)
 }
 
-// Delete removes a queueing discipline
+// Delete removes a queueing discipline. With Config.OrphanCheck, an
+// *OrphanError is returned instead of destroying the objects beneath it.
 func (qd *Qdisc) Delete(info *Object) error {
 	if info == nil {
 		return ErrNoArg
 	}
-	options, err := validateQdiscObject(unix.RTM_DELQDISC, info)
+	options, err := validateDeleteObject(unix.RTM_DELQDISC, info)
 	if err != nil {
 		return err
 	}
+	if err := qd.checkOrphans("delete", info); err != nil {
+		return err
+	}
 	return qd.action(unix.RTM_DELQDISC, netlink.HeaderFlags(0), &info.Msg, options)
 }
 
@@ -70,6 +103,9 @@ func (qd *Qdisc) Change(info *Object) error {
 	if info == nil {
 		return ErrNoArg
 	}
+	if err := qd.validateQdisc(info); err != nil {
+		return err
+	}
 	options, err := validateQdiscObject(unix.RTM_NEWQDISC, info)
 	if err != nil {
 		return err
@@ -79,7 +115,32 @@ func (qd *Qdisc) Change(info *Object) error {
 
 // Get fetches all queueing disciplines
 func (qd *Qdisc) Get() ([]Object, error) {
-	return qd.get(unix.RTM_GETQDISC, &Msg{})
+	return qd.getWith(unix.RTM_GETQDISC, &Msg{}, qd.dumpOptions())
+}
+
+// Invisible returns a Qdisc, whose Get and Walk also fetch the queueing
+// disciplines, that the kernel hides in dumps, like `tc qdisc show
+// invisible`. These are the default qdiscs, like the per queue qdiscs of mq.
+func (qd *Qdisc) Invisible() *Qdisc {
+	invisible := *qd
+	invisible.dumpInvisible = true
+	return &invisible
+}
+
+// dumpOptions returns the attributes of a dump request.
+func (qd *Qdisc) dumpOptions() []tcOption {
+	if !qd.dumpInvisible {
+		return nil
+	}
+	return []tcOption{{Interpretation: vtFlag, Type: tcaDumpInvisible, Data: true}}
+}
+
+// Walk calls fn for every queueing discipline as soon as it is received,
+// without keeping all of them in memory. The Object passed to fn is reused
+// for the next queueing discipline, so use Object.Copy to retain it. If fn
+// returns an error, Walk stops and returns it.
+func (qd *Qdisc) Walk(fn func(*Object) error) error {
+	return qd.walkWith(unix.RTM_GETQDISC, &Msg{}, qd.dumpOptions(), fn)
 }
 
 func validateQdiscObject(action int, info *Object) ([]tcOption, error) {
@@ -87,6 +148,9 @@ func validateQdiscObject(action int, info *Object) ([]tcOption, error) {
 	if info.Ifindex == 0 {
 		return options, ErrInvalidDev
 	}
+	if err := validateKind(info.Kind); err != nil {
+		return options, err
+	}
 
 	// TODO: improve logic and check combinations
 	var data []byte
@@ -145,18 +209,16 @@ func validateQdiscObject(action int, info *Object) ([]tcOption, error) {
 		data, err = marshalPlug(info.Plug)
 	case "taprio":
 		data, err = marshalTaPrio(info.TaPrio)
-	case "clsact":
-		// clsact is parameterless
-	case "ingress":
-		// ingress is parameterless
+	case "clsact", "ingress", "mq", "noqueue":
+		// these kinds have no options
 	default:
 		return options, fmt.Errorf("%s: %w", info.Kind, ErrNotImplemented)
 	}
 	if err != nil {
-		return options, err
+		return options, withKind(info.Kind, err)
 	}
 	if len(data) < 1 && action == unix.RTM_NEWQDISC {
-		if info.Kind != "clsact" && info.Kind != "ingress" && info.Kind != "qfq" {
+		if !attributeKinds[info.Kind].parameterless {
 			return options, ErrNoArg
 		}
 	} else {
@@ -174,9 +236,7 @@ func validateQdiscObject(action int, info *Object) ([]tcOption, error) {
 	if info.IngressBlock != nil {
 		options = append(options, tcOption{Interpretation: vtUint32, Type: tcaIngressBlock, Data: uint32Value(info.IngressBlock)})
 	}
-	if info.HwOffload != nil {
-		options = append(options, tcOption{Interpretation: vtUint8, Type: tcaHwOffload, Data: uint8Value(info.HwOffload)})
-	}
+	// HwOffload is reported by the kernel and can not be set.
 	if info.Chain != nil {
 		options = append(options, tcOption{Interpretation: vtUint32, Type: tcaChain, Data: uint32Value(info.Chain)})
 	}
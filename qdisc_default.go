@@ -0,0 +1,134 @@
+package tc
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+	"github.com/mdlayher/netlink"
+)
+
+// ErrNotDefault is wrapped by a *DefaultQdiscError.
+var ErrNotDefault = errors.New("qdisc is not the default of the kernel")
+
+// DefaultQdiscError is returned by DeleteRoot and DeleteIngress, if the
+// qdisc, that is found after the deletion, is not the one the kernel
+// attaches by default. Usually another process attached it in the meantime.
+type DefaultQdiscError struct {
+	Ifindex uint32
+	// Parent is HandleRoot or HandleIngress.
+	Parent uint32
+	// Want is the kind of the default qdisc. It is empty, if no qdisc is
+	// expected.
+	Want string
+	// Found is the qdisc, that was found instead.
+	Found Object
+}
+
+func (e *DefaultQdiscError) Error() string {
+	if e.Want == "" {
+		return fmt.Sprintf("device %d: %s is still attached: %v", e.Ifindex, e.Found, ErrNotDefault)
+	}
+	return fmt.Sprintf("device %d: %s instead of %s: %v", e.Ifindex, e.Found, e.Want, ErrNotDefault)
+}
+
+// Unwrap returns ErrNotDefault.
+func (e *DefaultQdiscError) Unwrap() error {
+	return ErrNotDefault
+}
+
+// IgnoreMissing returns a Qdisc, whose DeleteRoot and DeleteIngress succeed,
+// if there is no qdisc to delete.
+func (qd *Qdisc) IgnoreMissing() *Qdisc {
+	ignore := *qd
+	ignore.ignoreMissing = true
+	return &ignore
+}
+
+// DeleteRoot deletes the root qdisc of ifindex, like `tc qdisc del dev eth0
+// root`, so that the kernel attaches its default qdisc again. Afterwards
+// the qdiscs are dumped once to verify, that the root qdisc is the one of
+// DefaultQdiscKind, mq with such qdiscs for multiqueue devices or noqueue for
+// devices without a queue. Otherwise a *DefaultQdiscError with the found
+// qdisc is returned. Devices, that are down, report noop until they are up
+// and get their default qdisc, which is accepted as well.
+// Like Delete, it is checked with Config.OrphanCheck.
+func (qd *Qdisc) DeleteRoot(ifindex uint32) error {
+	// If net.core.default_qdisc can not be read, the default of the kernel
+	// without the sysctl is expected.
+	want, _ := DefaultQdiscKind()
+	return qd.deleteDefault(ifindex, HandleRoot, func(qdiscs []Object) error {
+		for _, qdisc := range qdiscs {
+			if qdisc.Ifindex != ifindex || qdisc.Parent != HandleRoot {
+				continue
+			}
+			if qdisc.Handle != 0 || !isDefaultRoot(qdisc, qdiscs, want) {
+				return &DefaultQdiscError{Ifindex: ifindex, Parent: HandleRoot, Want: want, Found: qdisc}
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteIngress deletes the ingress or clsact qdisc of ifindex, like `tc
+// qdisc del dev eth0 ingress`. Afterwards the qdiscs are dumped once to
+// verify, that no such qdisc is attached anymore. Otherwise a
+// *DefaultQdiscError with the found qdisc is returned.
+func (qd *Qdisc) DeleteIngress(ifindex uint32) error {
+	return qd.deleteDefault(ifindex, HandleIngress, func(qdiscs []Object) error {
+		for _, qdisc := range qdiscs {
+			if qdisc.Ifindex == ifindex && qdisc.Parent == HandleIngress {
+				return &DefaultQdiscError{Ifindex: ifindex, Parent: HandleIngress, Found: qdisc}
+			}
+		}
+		return nil
+	})
+}
+
+// deleteDefault deletes the qdisc of parent and passes the dumped qdiscs to
+// verify. Without Kind and Handle, linux/net/sched/sch_api.c:tc_get_qdisc()
+// deletes whatever qdisc is attached to parent.
+func (qd *Qdisc) deleteDefault(ifindex, parent uint32, verify func([]Object) error) error {
+	if ifindex == 0 {
+		return ErrInvalidDev
+	}
+	info := &Object{Msg: Msg{Family: unix.AF_UNSPEC, Ifindex: ifindex, Parent: parent}}
+	if err := qd.checkOrphans("delete", info); err != nil {
+		return err
+	}
+	// The kernel refuses to delete its own qdiscs with handle 0 with ENOENT.
+	err := qd.action(unix.RTM_DELQDISC, netlink.HeaderFlags(0), &info.Msg, []tcOption{})
+	if err != nil && !(qd.ignoreMissing && errors.Is(err, syscall.ENOENT)) {
+		return fmt.Errorf("delete qdisc %s of device %d: %w", core.FormatHandle(parent), ifindex, err)
+	}
+	qdiscs, err := qd.Get()
+	if err != nil {
+		return err
+	}
+	return verify(qdiscs)
+}
+
+// isDefaultRoot reports, whether root is a default root qdisc of the kernel.
+// linux/net/sched/sch_generic.c:attach_default_qdiscs() attaches want to
+// single queue devices and mq with want for every queue to multiqueue
+// devices. Devices without a queue get noqueue. Devices, that are down, have
+// noop, which can not be attached with a request, until dev_activate().
+func isDefaultRoot(root Object, qdiscs []Object, want string) bool {
+	switch root.Kind {
+	case want, "noqueue", "noop":
+		return true
+	case "mq":
+		for _, qdisc := range qdiscs {
+			if qdisc.Ifindex != root.Ifindex || qdisc.Parent == HandleRoot || qdisc.Parent == HandleIngress {
+				continue
+			}
+			if maj, _ := core.SplitHandle(qdisc.Parent); maj == 0 && qdisc.Kind != want {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+//go:build integration && linux
+// +build integration,linux
+
+package tc
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/jsimonetti/rtnetlink"
+	"golang.org/x/sys/unix"
+)
+
+func TestLinuxDeleteDefault(t *testing.T) {
+	tcIface := "tcDefault"
+
+	rtnl, err := setupVethInterface(tcIface)
+	if err != nil {
+		t.Skipf("could not setup veth interface: %v", err)
+	}
+	defer rtnl.Close()
+
+	devID, err := net.InterfaceByName(tcIface)
+	if err != nil {
+		t.Fatalf("could not get interface ID: %v", err)
+	}
+	defer func(devID uint32, rtnl *rtnetlink.Conn) {
+		if err := rtnl.Link.Delete(devID); err != nil {
+			t.Fatalf("could not delete interface: %v", err)
+		}
+	}(uint32(devID.Index), rtnl)
+
+	tcnl, err := Open(&Config{})
+	if err != nil {
+		t.Fatalf("could not open rtnetlink socket: %v", err)
+	}
+	defer func() {
+		if err := tcnl.Close(); err != nil {
+			t.Fatalf("could not close rtnetlink socket: %v", err)
+		}
+	}()
+
+	ifindex := uint32(devID.Index)
+	for _, qdisc := range []Object{
+		{Msg{Family: unix.AF_UNSPEC, Ifindex: ifindex, Handle: core.BuildHandle(0x1, 0x0), Parent: HandleRoot},
+			Attribute{Kind: "fq_codel", FqCodel: &FqCodel{Limit: uint32Ptr(1000)}}},
+		{Msg{Family: unix.AF_UNSPEC, Ifindex: ifindex, Handle: core.BuildHandle(0xFFFF, 0x0), Parent: HandleIngress},
+			Attribute{Kind: "clsact"}},
+	} {
+		qdisc := qdisc
+		if err := tcnl.Qdisc().Add(&qdisc); err != nil {
+			t.Fatalf("could not add %s: %v", qdisc, err)
+		}
+	}
+
+	if err := tcnl.Qdisc().DeleteRoot(ifindex); err != nil {
+		t.Fatalf("could not restore the default root qdisc: %v", err)
+	}
+	if err := tcnl.Qdisc().DeleteIngress(ifindex); err != nil {
+		t.Fatalf("could not delete the clsact qdisc: %v", err)
+	}
+
+	// The default qdiscs of the kernel can not be deleted.
+	if err := tcnl.Qdisc().DeleteRoot(ifindex); !errors.Is(err, syscall.ENOENT) {
+		t.Fatalf("expected ENOENT for the default root qdisc but got %v", err)
+	}
+	if err := tcnl.Qdisc().IgnoreMissing().DeleteRoot(ifindex); err != nil {
+		t.Fatalf("could not delete the default root qdisc again: %v", err)
+	}
+	if err := tcnl.Qdisc().IgnoreMissing().DeleteIngress(ifindex); err != nil {
+		t.Fatalf("could not delete the ingress qdisc again: %v", err)
+	}
+}
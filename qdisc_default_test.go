@@ -0,0 +1,137 @@
+package tc
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nltest"
+)
+
+func TestDeleteDefault(t *testing.T) {
+	defer SetDefaultQdiscKind("fq_codel")()
+
+	qdisc := func(ifindex, handle, parent uint32, kind string) Object {
+		return Object{Msg: Msg{Family: unix.AF_UNSPEC, Ifindex: ifindex, Handle: handle, Parent: parent},
+			Attribute: Attribute{Kind: kind}}
+	}
+	restored := []Object{
+		qdisc(1, 0, HandleRoot, "noqueue"),
+		qdisc(2, 0, HandleRoot, "fq_codel"),
+		qdisc(3, 0, HandleRoot, "mq"),
+		qdisc(3, 0, core.BuildHandle(0x0, 0x1), "fq_codel"),
+		qdisc(3, 0, core.BuildHandle(0x0, 0x2), "fq_codel"),
+	}
+
+	tests := map[string]struct {
+		ifindex uint32
+		ingress bool
+		errno   syscall.Errno
+		ignore  bool
+		dump    []Object
+		found   *Object
+		err     error
+	}{
+		"noqueue":    {ifindex: 1, dump: restored},
+		"default":    {ifindex: 2, dump: restored},
+		"multiqueue": {ifindex: 3, dump: restored},
+		// The device is down and has no root qdisc until it is up.
+		"down": {ifindex: 4, dump: restored},
+		// The root of a device, that is down, can also be reported as noop.
+		"down with noop": {ifindex: 4, dump: append([]Object{qdisc(4, 0, HandleRoot, "noop")}, restored...)},
+		"replaced": {ifindex: 2, dump: []Object{qdisc(2, core.BuildHandle(0x1, 0x0), HandleRoot, "fq_codel")},
+			found: &Object{Msg: Msg{Family: unix.AF_UNSPEC, Ifindex: 2, Handle: core.BuildHandle(0x1, 0x0),
+				Parent: HandleRoot}, Attribute: Attribute{Kind: "fq_codel"}}, err: ErrNotDefault},
+		"other default": {ifindex: 2, dump: []Object{qdisc(2, 0, HandleRoot, "pfifo_fast")},
+			found: &Object{Msg: Msg{Family: unix.AF_UNSPEC, Ifindex: 2, Parent: HandleRoot},
+				Attribute: Attribute{Kind: "pfifo_fast"}}, err: ErrNotDefault},
+		"multiqueue other default": {ifindex: 3,
+			dump: append([]Object{qdisc(3, 0, core.BuildHandle(0x0, 0x3), "pfifo_fast")}, restored...),
+			found: &Object{Msg: Msg{Family: unix.AF_UNSPEC, Ifindex: 3, Parent: HandleRoot},
+				Attribute: Attribute{Kind: "mq"}}, err: ErrNotDefault},
+		"missing":         {ifindex: 2, errno: syscall.ENOENT, dump: restored, err: syscall.ENOENT},
+		"ignored missing": {ifindex: 2, errno: syscall.ENOENT, ignore: true, dump: restored},
+		"busy":            {ifindex: 2, errno: syscall.EBUSY, ignore: true, dump: restored, err: syscall.EBUSY},
+		"ingress":         {ifindex: 2, ingress: true, dump: restored},
+		"ingress refused": {ifindex: 2, ingress: true, errno: syscall.EINVAL, dump: restored, err: syscall.EINVAL},
+		"clsact": {ifindex: 2, ingress: true, dump: []Object{qdisc(2, core.BuildHandle(0xFFFF, 0x0), HandleIngress, "clsact")},
+			found: &Object{Msg: Msg{Family: unix.AF_UNSPEC, Ifindex: 2, Handle: core.BuildHandle(0xFFFF, 0x0),
+				Parent: HandleIngress}, Attribute: Attribute{Kind: "clsact"}}, err: ErrNotDefault},
+		"no device": {err: ErrInvalidDev},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			var deleted []Msg
+			tcSocket := &Tc{
+				con: nltest.Dial(func(req []netlink.Message) ([]netlink.Message, error) {
+					if req[0].Header.Type == unix.RTM_DELQDISC {
+						msg, attrs, err := unmarshalTcmsg(req[0].Data)
+						if err != nil {
+							t.Fatalf("could not decode Msg: %v", err)
+						}
+						if len(attrs) != 0 {
+							t.Fatalf("expected only a tcmsg but got %d bytes of attributes", len(attrs))
+						}
+						deleted = append(deleted, msg)
+						if testcase.errno != 0 {
+							return nltest.Error(int(testcase.errno), req)
+						}
+						return []netlink.Message{{Header: netlink.Header{Type: netlink.Error}, Data: make([]byte, 4)}}, nil
+					}
+					var msgs []netlink.Message
+					for _, qdisc := range testcase.dump {
+						data, err := marshalStruct(&qdisc.Msg)
+						if err != nil {
+							t.Fatalf("could not encode Msg: %v", err)
+						}
+						attrs, err := marshalAttributes([]tcOption{{Interpretation: vtString, Type: tcaKind, Data: qdisc.Kind}})
+						if err != nil {
+							t.Fatalf("could not encode attributes: %v", err)
+						}
+						msgs = append(msgs, netlink.Message{Header: netlink.Header{Type: unix.RTM_NEWQDISC},
+							Data: append(data, attrs...)})
+					}
+					return msgs, nil
+				}),
+			}
+			defer tcSocket.Close()
+
+			qd := tcSocket.Qdisc()
+			if testcase.ignore {
+				qd = qd.IgnoreMissing()
+			}
+			parent := HandleRoot
+			var err error
+			if testcase.ingress {
+				parent = HandleIngress
+				err = qd.DeleteIngress(testcase.ifindex)
+			} else {
+				err = qd.DeleteRoot(testcase.ifindex)
+			}
+			if !errors.Is(err, testcase.err) {
+				t.Fatalf("expected error %v but got %v", testcase.err, err)
+			}
+			var defaultErr *DefaultQdiscError
+			if errors.As(err, &defaultErr) != (testcase.found != nil) {
+				t.Fatalf("expected found qdisc %v but got %v", testcase.found, err)
+			}
+			if testcase.found != nil {
+				if diff := cmp.Diff(*testcase.found, defaultErr.Found); diff != "" {
+					t.Fatalf("found qdisc missmatch (-want +got):\n%s", diff)
+				}
+			}
+			if testcase.ifindex == 0 {
+				return
+			}
+			want := []Msg{{Family: unix.AF_UNSPEC, Ifindex: testcase.ifindex, Parent: parent}}
+			if diff := cmp.Diff(want, deleted); diff != "" {
+				t.Fatalf("delete request missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
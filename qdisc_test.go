@@ -1,13 +1,131 @@
 package tc
 
 import (
+	"encoding/json"
 	"errors"
+	"reflect"
+	"sort"
 	"testing"
 
 	"github.com/florianl/go-tc/core"
 	"github.com/florianl/go-tc/internal/unix"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nltest"
 )
 
+// qdiscTestCase describes a qdisc of TestQdisc and BenchmarkQdiscMarshal.
+type qdiscTestCase struct {
+	kind    string
+	err     error
+	fqCodel *FqCodel
+	fq      *Fq
+	red     *Red
+	sfb     *Sfb
+	sfq     *Sfq
+	cbq     *Cbq
+	cbs     *Cbs
+	codel   *Codel
+	hhf     *Hhf
+	pie     *Pie
+	choke   *Choke
+	netem   *Netem
+	cake    *Cake
+	htb     *Htb
+	prio    *Prio
+	plug    *Plug
+	taPrio  *TaPrio
+}
+
+// object returns the qdisc of testcase with the header msg.
+func (testcase qdiscTestCase) object(msg Msg) Object {
+	return Object{
+		msg,
+		Attribute{
+			Kind:    testcase.kind,
+			Cbs:     testcase.cbs,
+			FqCodel: testcase.fqCodel,
+			Fq:      testcase.fq,
+			Red:     testcase.red,
+			Sfb:     testcase.sfb,
+			Sfq:     testcase.sfq,
+			Cbq:     testcase.cbq,
+			Codel:   testcase.codel,
+			Hhf:     testcase.hhf,
+			Pie:     testcase.pie,
+			Choke:   testcase.choke,
+			Netem:   testcase.netem,
+			Cake:    testcase.cake,
+			Htb:     testcase.htb,
+			Prio:    testcase.prio,
+			Plug:    testcase.plug,
+			TaPrio:  testcase.taPrio,
+		},
+	}
+}
+
+// qdiscTestMsg returns the header of a qdisc of kind. Only clsact and ingress
+// may use HandleIngress as parent.
+func qdiscTestMsg(kind string) Msg {
+	msg := Msg{
+		Family:  unix.AF_UNSPEC,
+		Ifindex: 123,
+		Handle:  core.BuildHandle(0x1, 0x0000),
+		Parent:  HandleRoot,
+	}
+	if kind == "clsact" || kind == "ingress" {
+		msg.Handle = core.BuildHandle(0xFFFF, 0x0000)
+		msg.Parent = HandleIngress
+	}
+	return msg
+}
+
+var qdiscTests = map[string]qdiscTestCase{
+	"clsact":   {kind: "clsact"},
+	"emptyHtb": {kind: "htb", err: ErrNoArg},
+	"fq_codel": {
+		kind:    "fq_codel",
+		fqCodel: &FqCodel{Target: uint32Ptr(42), Limit: uint32Ptr(0xCAFE)},
+	},
+	"fq":  {kind: "fq", fq: &Fq{PLimit: uint32Ptr(10000), Quantum: uint32Ptr(3028)}},
+	"red": {kind: "red", red: &Red{MaxP: uint32Ptr(42)}},
+	"sfb": {kind: "sfb", sfb: &Sfb{Parms: &SfbQopt{Max: 0xFF}}},
+	"sfq": {kind: "sfq", sfq: &Sfq{V0: SfqQopt{
+		PerturbPeriod: 64,
+		Limit:         3000,
+		Flows:         512,
+	}}},
+	"cbq": {kind: "cbq", cbq: &Cbq{
+		LssOpt: &CbqLssOpt{OffTime: 10}, WrrOpt: &CbqWrrOpt{Weight: 42},
+		FOpt: &CbqFOpt{Split: 2}, OVLStrategy: &CbqOvl{Penalty: 2},
+	}},
+	"codel": {kind: "codel", codel: &Codel{
+		Target: uint32Ptr(1), Limit: uint32Ptr(2), Interval: uint32Ptr(3),
+		ECN: uint32Ptr(4), CEThreshold: uint32Ptr(5),
+	}},
+	"hhf": {kind: "hhf", hhf: &Hhf{
+		BacklogLimit: uint32Ptr(1), Quantum: uint32Ptr(2), HHFlowsLimit: uint32Ptr(3),
+		ResetTimeout: uint32Ptr(4), AdmitBytes: uint32Ptr(5), EVICTTimeout: uint32Ptr(6), NonHHWeight: uint32Ptr(7),
+	}},
+	"pie": {kind: "pie", pie: &Pie{
+		Target: uint32Ptr(1), Limit: uint32Ptr(2), TUpdate: uint32Ptr(3),
+		Alpha: uint32Ptr(4), Beta: uint32Ptr(5), ECN: uint32Ptr(6), Bytemode: uint32Ptr(7),
+	}},
+	"choke": {kind: "choke", choke: &Choke{MaxP: uint32Ptr(42)}},
+	"netem": {kind: "netem", netem: &Netem{Ecn: uint32Ptr(64)}},
+	"cake":  {kind: "cake", cake: &Cake{BaseRate: uint64Ptr(128)}},
+	"htb":   {kind: "htb", htb: &Htb{Rate64: uint64Ptr(96)}},
+	"prio": {kind: "prio", prio: &Prio{
+		Bands:   3,
+		PrioMap: Priomap{1, 2, 2, 2, 1, 2, 0, 0, 1, 1, 1, 1, 1, 1, 1, 1},
+	}},
+	"cbs": {kind: "cbs",
+		cbs: &Cbs{Parms: &CbsOpt{Offload: 73}}},
+	"taprio": {kind: "taprio",
+		taPrio: &TaPrio{SchedClockID: int32Ptr(73)}},
+	// TODO(flo): reenable this test.
+	//"plug": {kind: "plug", plug: &Plug{Action: PlugReleaseIndefinite}},
+}
+
 func TestQdisc(t *testing.T) {
 	tcSocket, done := testConn(t)
 	defer done()
@@ -22,7 +140,7 @@ func TestQdisc(t *testing.T) {
 			Family:  unix.AF_UNSPEC,
 			Ifindex: 0,
 			Handle:  core.BuildHandle(0xFFFF, 0x0000),
-			Parent:  0xFFFFFFF1,
+			Parent:  HandleIngress,
 			Info:    0,
 		},
 	}
@@ -30,102 +148,27 @@ func TestQdisc(t *testing.T) {
 		t.Fatalf("expected ErrInvalidDev, received: %v", err)
 	}
 
-	tests := map[string]struct {
-		kind    string
-		err     error
-		fqCodel *FqCodel
-		red     *Red
-		sfb     *Sfb
-		sfq     *Sfq
-		cbq     *Cbq
-		cbs     *Cbs
-		codel   *Codel
-		hhf     *Hhf
-		pie     *Pie
-		choke   *Choke
-		netem   *Netem
-		cake    *Cake
-		htb     *Htb
-		prio    *Prio
-		plug    *Plug
-		taPrio  *TaPrio
-	}{
-		"clsact":   {kind: "clsact"},
-		"emptyHtb": {kind: "htb", err: ErrNoArg},
-		"fq_codel": {
-			kind:    "fq_codel",
-			fqCodel: &FqCodel{Target: uint32Ptr(42), Limit: uint32Ptr(0xCAFE)},
-		},
-		"red": {kind: "red", red: &Red{MaxP: uint32Ptr(42)}},
-		"sfb": {kind: "sfb", sfb: &Sfb{Parms: &SfbQopt{Max: 0xFF}}},
-		"sfq": {kind: "sfq", sfq: &Sfq{V0: SfqQopt{
-			PerturbPeriod: 64,
-			Limit:         3000,
-			Flows:         512,
-		}}},
-		"cbq": {kind: "cbq", cbq: &Cbq{
-			LssOpt: &CbqLssOpt{OffTime: 10}, WrrOpt: &CbqWrrOpt{Weight: 42},
-			FOpt: &CbqFOpt{Split: 2}, OVLStrategy: &CbqOvl{Penalty: 2},
-		}},
-		"codel": {kind: "codel", codel: &Codel{
-			Target: uint32Ptr(1), Limit: uint32Ptr(2), Interval: uint32Ptr(3),
-			ECN: uint32Ptr(4), CEThreshold: uint32Ptr(5),
-		}},
-		"hhf": {kind: "hhf", hhf: &Hhf{
-			BacklogLimit: uint32Ptr(1), Quantum: uint32Ptr(2), HHFlowsLimit: uint32Ptr(3),
-			ResetTimeout: uint32Ptr(4), AdmitBytes: uint32Ptr(5), EVICTTimeout: uint32Ptr(6), NonHHWeight: uint32Ptr(7),
-		}},
-		"pie": {kind: "pie", pie: &Pie{
-			Target: uint32Ptr(1), Limit: uint32Ptr(2), TUpdate: uint32Ptr(3),
-			Alpha: uint32Ptr(4), Beta: uint32Ptr(5), ECN: uint32Ptr(6), Bytemode: uint32Ptr(7),
-		}},
-		"choke": {kind: "choke", choke: &Choke{MaxP: uint32Ptr(42)}},
-		"netem": {kind: "netem", netem: &Netem{Ecn: uint32Ptr(64)}},
-		"cake":  {kind: "cake", cake: &Cake{BaseRate: uint64Ptr(128)}},
-		"htb":   {kind: "htb", htb: &Htb{Rate64: uint64Ptr(96)}},
-		"prio": {kind: "prio", prio: &Prio{
-			Bands:   3,
-			PrioMap: [16]uint8{1, 2, 2, 2, 1, 2, 9, 9, 1, 1, 1, 1, 1, 1, 1, 1},
-		}},
-		"cbs": {kind: "cbs",
-			cbs: &Cbs{Parms: &CbsOpt{Offload: 73}}},
-		"taprio": {kind: "taprio",
-			taPrio: &TaPrio{SchedClockID: int32Ptr(73)}},
-		// TODO(flo): reenable this test.
-		//"plug": {kind: "plug", plug: &Plug{Action: PlugReleaseIndefinite}},
-	}
-
-	tcMsg := Msg{
-		Family:  unix.AF_UNSPEC,
-		Ifindex: 123,
-		Handle:  core.BuildHandle(0xFFFF, 0x0000),
-		Parent:  0xFFFFFFF1,
-		Info:    0,
-	}
-	for name, testcase := range tests {
+	for name, testcase := range qdiscTests {
 		t.Run(name, func(t *testing.T) {
-			testQdisc := Object{
-				tcMsg,
-				Attribute{
-					Kind:    testcase.kind,
-					Cbs:     testcase.cbs,
-					FqCodel: testcase.fqCodel,
-					Red:     testcase.red,
-					Sfb:     testcase.sfb,
-					Sfq:     testcase.sfq,
-					Cbq:     testcase.cbq,
-					Codel:   testcase.codel,
-					Hhf:     testcase.hhf,
-					Pie:     testcase.pie,
-					Choke:   testcase.choke,
-					Netem:   testcase.netem,
-					Cake:    testcase.cake,
-					Htb:     testcase.htb,
-					Prio:    testcase.prio,
-					Plug:    testcase.plug,
-					TaPrio:  testcase.taPrio,
-				},
-			}
+			testQdisc := testcase.object(qdiscTestMsg(testcase.kind))
+
+			t.Run("Copy", func(t *testing.T) {
+				testCopy(t, &testQdisc)
+			})
+
+			t.Run("JSON", func(t *testing.T) {
+				data, err := json.Marshal(testQdisc)
+				if err != nil {
+					t.Fatalf("could not marshal qdisc: %v", err)
+				}
+				var got Object
+				if err := json.Unmarshal(data, &got); err != nil {
+					t.Fatalf("could not unmarshal qdisc: %v", err)
+				}
+				if !reflect.DeepEqual(testQdisc, got) {
+					t.Fatalf("round trip through JSON missmatch: %s", data)
+				}
+			})
 
 			if err := tcSocket.Qdisc().Add(&testQdisc); err != nil {
 				if testcase.err != nil && !errors.Is(testcase.err, err) {
@@ -141,6 +184,10 @@ func TestQdisc(t *testing.T) {
 			}
 			for _, qdisc := range qdiscs {
 				t.Logf("%#v\n", qdisc)
+				if qdisc.Kind == "fq" && (qdisc.XStats == nil || qdisc.XStats.Fq == nil ||
+					qdisc.XStats.Fq.GcFlows != 73) {
+					t.Fatalf("could not decode fq xstats: %#v", qdisc.XStats)
+				}
 			}
 
 			t.Run("Change", func(t *testing.T) {
@@ -195,7 +242,7 @@ func TestQdisc(t *testing.T) {
 
 	t.Run("general qdisc attributes", func(t *testing.T) {
 		testQdisc := Object{
-			tcMsg,
+			qdiscTestMsg("qfq"),
 			Attribute{
 				Kind:         "qfq",
 				EgressBlock:  uint32Ptr(0xA5a5),
@@ -209,3 +256,190 @@ func TestQdisc(t *testing.T) {
 		}
 	})
 }
+
+func BenchmarkQdiscMarshal(b *testing.B) {
+	names := make([]string, 0, len(qdiscTests))
+	for name := range qdiscTests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		testcase := qdiscTests[name]
+		if testcase.err != nil {
+			continue
+		}
+		info := testcase.object(qdiscTestMsg(testcase.kind))
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				options, err := validateQdiscObject(unix.RTM_NEWQDISC, &info)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := marshalMessage(unix.RTM_NEWQDISC, netlink.Create, &info.Msg, options); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkQdiscGetDump(b *testing.B) {
+	stats, err := marshalStruct(&Stats{Bytes: 1 << 40, Packets: 1 << 20, Qlen: 3})
+	if err != nil {
+		b.Fatal(err)
+	}
+	stats2, err := marshalGenStats(&GenStats{
+		Basic:   &GenBasic{Bytes: 1 << 40, Packets: 1 << 20},
+		RateEst: &GenRateEst{BytePerSecond: 1 << 20, PacketPerSecond: 1 << 10},
+		Queue:   &GenQueue{QueueLen: 3, Drops: 42},
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	fqCodel, err := marshalFqCodel(&FqCodel{Limit: uint32Ptr(10240), Flows: uint32Ptr(1024)})
+	if err != nil {
+		b.Fatal(err)
+	}
+	xstats, err := marshalXStats(XStats{FqCodel: &FqCodelXStats{Type: tcaFqCodelXStatsQdisc,
+		Qd: &FqCodelQdStats{MaxPacket: 1514, NewFlowCount: 3}}})
+	if err != nil {
+		b.Fatal(err)
+	}
+	var dump []netlink.Message
+	for i := 0; i < 10000; i++ {
+		tcmsg, err := marshalStruct(&Msg{Family: unix.AF_UNSPEC, Ifindex: uint32(i + 1),
+			Handle: 0x80010000, Parent: HandleRoot})
+		if err != nil {
+			b.Fatal(err)
+		}
+		attrs, err := marshalAttributes([]tcOption{
+			{Interpretation: vtString, Type: tcaKind, Data: "fq_codel"},
+			{Interpretation: vtBytes, Type: tcaOptions, Data: fqCodel},
+			{Interpretation: vtBytes, Type: tcaStats, Data: stats},
+			{Interpretation: vtBytes, Type: tcaStats2, Data: stats2},
+			{Interpretation: vtBytes, Type: tcaXstats, Data: xstats},
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		dump = append(dump, netlink.Message{
+			Header: netlink.Header{Type: unix.RTM_NEWQDISC},
+			Data:   append(tcmsg, attrs...),
+		})
+	}
+
+	for name, lazyStats := range map[string]bool{"eager": false, "lazy": true} {
+		b.Run(name, func(b *testing.B) {
+			tcSocket := &Tc{con: nltest.Dial(func(_ []netlink.Message) ([]netlink.Message, error) {
+				return dump, nil
+			}), lazyStats: lazyStats}
+			defer tcSocket.Close()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				qdiscs, err := tcSocket.Qdisc().Get()
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(qdiscs) != len(dump) {
+					b.Fatalf("expected %d qdiscs but got %d", len(dump), len(qdiscs))
+				}
+			}
+		})
+	}
+}
+
+func TestRefcnt(t *testing.T) {
+	// The dump of a device with a single queue and a device with mq, see
+	// `tc qdisc show`:
+	//  qdisc fq_codel 0: dev lo root refcnt 2 ...
+	//  qdisc mq 0: dev eth0 root
+	//  qdisc fq_codel 0: dev eth0 parent :1 ...
+	dump := []struct {
+		msg    Msg
+		kind   string
+		refcnt uint32
+	}{
+		{msg: Msg{Family: unix.AF_UNSPEC, Ifindex: 1, Parent: HandleRoot, Info: 2}, kind: "fq_codel", refcnt: 2},
+		{msg: Msg{Family: unix.AF_UNSPEC, Ifindex: 2, Parent: HandleRoot, Info: 1}, kind: "mq", refcnt: 1},
+		{msg: Msg{Family: unix.AF_UNSPEC, Ifindex: 2, Parent: core.BuildHandle(0x0, 0x1), Info: 1}, kind: "fq_codel", refcnt: 1},
+	}
+	var sent []Msg
+	tcSocket := &Tc{
+		con: nltest.Dial(func(req []netlink.Message) ([]netlink.Message, error) {
+			if req[0].Header.Type != unix.RTM_GETQDISC {
+				msg, _, err := unmarshalTcmsg(req[0].Data)
+				if err != nil {
+					t.Fatalf("could not decode Msg: %v", err)
+				}
+				sent = append(sent, msg)
+				return []netlink.Message{{Header: netlink.Header{Type: netlink.Error}, Data: make([]byte, 4)}}, nil
+			}
+			var msgs []netlink.Message
+			for _, qdisc := range dump {
+				data, err := marshalStruct(&qdisc.msg)
+				if err != nil {
+					t.Fatalf("could not encode Msg: %v", err)
+				}
+				attrs, err := marshalAttributes([]tcOption{{Interpretation: vtString, Type: tcaKind, Data: qdisc.kind}})
+				if err != nil {
+					t.Fatalf("could not encode attributes: %v", err)
+				}
+				msgs = append(msgs, netlink.Message{Header: netlink.Header{Type: unix.RTM_NEWQDISC},
+					Data: append(data, attrs...)})
+			}
+			return msgs, nil
+		}),
+	}
+	defer tcSocket.Close()
+	tcSocket.skipValidation = true
+
+	qdiscs, err := tcSocket.Qdisc().Get()
+	if err != nil {
+		t.Fatalf("could not get qdiscs: %v", err)
+	}
+	if len(qdiscs) != len(dump) {
+		t.Fatalf("expected %d qdiscs but got %d", len(dump), len(qdiscs))
+	}
+	for i, qdisc := range qdiscs {
+		if qdisc.Refcnt != dump[i].refcnt || qdisc.Info != dump[i].msg.Info {
+			t.Fatalf("%s: expected refcnt %d but got %d", qdisc.String(), dump[i].refcnt, qdisc.Refcnt)
+		}
+	}
+
+	t.Run("class", func(t *testing.T) {
+		// Info of a class is the handle of its leaf qdisc.
+		data, err := marshalStruct(&Msg{Family: unix.AF_UNSPEC, Ifindex: 2, Handle: 0x10010, Parent: 0x10000, Info: 0x100000})
+		if err != nil {
+			t.Fatalf("could not encode Msg: %v", err)
+		}
+		class, err := UnmarshalObject(netlink.Message{Header: netlink.Header{Type: unix.RTM_NEWTCLASS}, Data: data})
+		if err != nil {
+			t.Fatalf("could not decode class: %v", err)
+		}
+		if class.Refcnt != 0 || class.Info != 0x100000 {
+			t.Fatalf("expected no refcnt and leaf 10: but got %d and %s", class.Refcnt, core.FormatHandle(class.Info))
+		}
+	})
+	t.Run("replace", func(t *testing.T) {
+		// The reference counter of the dumped qdisc is not sent back.
+		sent = nil
+		qdisc := qdiscs[0].Copy()
+		qdisc.FqCodel = &FqCodel{Limit: uint32Ptr(10240)}
+		if err := tcSocket.Qdisc().Replace(qdisc); err != nil {
+			t.Fatalf("could not replace qdisc: %v", err)
+		}
+		if len(sent) != 1 || sent[0].Info != 0 {
+			t.Fatalf("expected a request without refcnt but got %v", sent)
+		}
+		msg, err := MarshalObject(OpQdiscAdd, qdisc)
+		if err != nil {
+			t.Fatalf("could not marshal qdisc: %v", err)
+		}
+		if tcmsg, _, _ := unmarshalTcmsg(msg.Data); tcmsg.Info != 0 {
+			t.Fatalf("expected no refcnt but got %d", tcmsg.Info)
+		}
+	})
+}
@@ -1,58 +1,141 @@
 package tc
 
 import (
-	"bytes"
-	"encoding/binary"
 	"fmt"
+	"math"
+	"time"
 
 	"github.com/florianl/go-tc/core"
 	"github.com/florianl/go-tc/internal/unix"
 )
 
-// iproute2/tc/tc_core.c:tc_calc_rtable()
-func generateRateTable(pol *Policy) ([]byte, error) {
-	var rate [256]uint32
+// Linklayer defines the type of link layer, a rate is calculated for.
+type Linklayer uint8
 
-	if pol == nil {
-		return []byte{}, fmt.Errorf("generateRateTable: %w", ErrNoArg)
+// Linklayer types from include/uapi/linux/pkt_sched.h
+const (
+	LinklayerUnaware  Linklayer = unix.LINKLAYER_UNSPEC
+	LinklayerEthernet Linklayer = unix.LINKLAYER_ETHERNET
+	LinklayerAtm      Linklayer = unix.LINKLAYER_ATM
+)
+
+// rateTableLen is TC_RTAB_SIZE, the size of an encoded rate table.
+const rateTableLen = 1024
+
+// NewRateSpec returns the RateSpec for rate in bytes per second, like tc
+// computes it for packets of up to mtu bytes on linklayer. The kernel adds
+// overhead bytes to the size of every packet and accounts packets smaller
+// than mpu bytes with mpu bytes. If mtu is 0, a default of 2047 bytes is
+// assumed. Rates, that exceed 32 bits, are capped and have to be passed
+// additionally as 64 bit attribute, like Htb.Rate64.
+func NewRateSpec(rate uint64, mtu uint32, linklayer Linklayer, overhead, mpu uint16) (RateSpec, error) {
+	switch linklayer {
+	case LinklayerUnaware, LinklayerEthernet, LinklayerAtm:
+	default:
+		return RateSpec{}, fmt.Errorf("NewRateSpec: unknown link layer %d: %w", linklayer, ErrInvalidArg)
 	}
-	mtu := pol.Mtu
+	spec := RateSpec{Rate: math.MaxUint32, Overhead: overhead, Mpu: mpu}
+	if rate < math.MaxUint32 {
+		spec.Rate = uint32(rate)
+	}
+	_, spec, err := CalcRateTable(spec, mtu, linklayer)
+	return spec, err
+}
 
-	var cellLog int = -1
+// RateTable returns the rate table for packets of up to mtu bytes, that
+// takes the link layer and the minimum packet unit of r into account. If mtu
+// is 0, a default of 2047 bytes is assumed.
+func (r RateSpec) RateTable(mtu uint32) ([256]uint32, error) {
+	rtab, _, err := CalcRateTable(r, mtu, Linklayer(r.Linklayer))
+	return rtab, err
+}
+
+// MarshalBinary returns r in the encoding of struct tc_ratespec.
+func (r RateSpec) MarshalBinary() ([]byte, error) {
+	return marshalStruct(&r)
+}
+
+// UnmarshalBinary decodes data in the encoding of struct tc_ratespec.
+func (r *RateSpec) UnmarshalBinary(data []byte) error {
+	return unmarshalStruct(data, r)
+}
+
+// CalcRateTable implements iproute2/tc/tc_core.c:tc_calc_rtable().
+// It returns the rate table for the given rate and the RateSpec with the cell
+// log, cell alignment and link layer adjusted to the rate table.
+// If mtu is 0, a default of 2047 bytes is assumed.
+func CalcRateTable(rate RateSpec, mtu uint32, linklayer Linklayer) ([256]uint32, RateSpec, error) {
+	var rtab [256]uint32
+
+	if rate.Rate == 0 {
+		return rtab, rate, fmt.Errorf("CalcRateTable: rate is required: %w", ErrInvalidArg)
+	}
 	if mtu == 0 {
 		mtu = 2047
 	}
 
-	var linklayer, mpu uint
-	var polRate uint64
+	cellLog := 0
+	for (mtu >> uint(cellLog)) > 255 {
+		cellLog++
+	}
+
+	for i := 0; i < 256; i++ {
+		sz := adjustSize(uint((i+1)<<uint(cellLog)), uint(rate.Mpu), uint(linklayer))
+		rtab[i] = core.XmitTime(uint64(rate.Rate), sz)
+	}
+
+	rate.CellAlign = 0xFFFF // -1
+	rate.CellLog = uint8(cellLog)
+	rate.Linklayer = uint8(linklayer) & unix.TC_LINKLAYER_MASK
+	return rtab, rate, nil
+}
+
+// CalcXmitTime returns the ticks of the packet scheduler clock, that are
+// needed to transmit size bytes with the given rate in bytes per second, like
+// iproute2/tc/tc_core.c:tc_calc_xmittime(). rate must not be 0.
+func CalcXmitTime(rate uint64, size uint32) uint32 {
+	return core.XmitTime(rate, size)
+}
 
+// CalcBurst returns the number of bytes, that can be transmitted with the
+// given rate in bytes per second during buffer.
+func CalcBurst(rate uint64, buffer time.Duration) (uint32, error) {
+	if buffer < 0 {
+		return 0, fmt.Errorf("CalcBurst: negative buffer: %w", ErrInvalidArg)
+	}
+	burst := float64(rate) * buffer.Seconds()
+	if burst > math.MaxUint32 {
+		return 0, fmt.Errorf("CalcBurst: burst of %.0f bytes overflows: %w", burst, ErrInvalidArg)
+	}
+	return uint32(burst), nil
+}
+
+// generateRateTable returns the binary encoding of the rate table for
+// the Rate or, if unset, the PeakRate of pol.
+func generateRateTable(pol *Policy) ([]byte, error) {
+	if pol == nil {
+		return []byte{}, fmt.Errorf("generateRateTable: %w", ErrNoArg)
+	}
+
+	var spec RateSpec
 	if pol.Rate.Rate != 0 {
-		linklayer = uint(pol.Rate.Linklayer)
-		mpu = uint(pol.Rate.Mpu)
-		polRate = uint64(pol.Rate.Rate)
+		spec = pol.Rate
 	} else if pol.PeakRate.Rate != 0 {
-		linklayer = uint(pol.PeakRate.Linklayer)
-		mpu = uint(pol.PeakRate.Mpu)
-		polRate = uint64(pol.PeakRate.Rate)
+		spec = pol.PeakRate
 	} else {
 		return []byte{}, fmt.Errorf("generateRateTable: Rate or PeakRate is required: %w", ErrNoArg)
 	}
 
-	if cellLog < 0 {
-		cellLog = 0
-		for (mtu >> uint(cellLog)) > 255 {
-			cellLog++
-		}
+	rtab, err := spec.RateTable(pol.Mtu)
+	if err != nil {
+		return []byte{}, err
 	}
 
-	for i := 0; i < 256; i++ {
-		sz := adjustSize(uint((i+1)<<uint(cellLog)), mpu, linklayer)
-		rate[i] = core.XmitTime(polRate, uint32(sz))
+	data := make([]byte, rateTableLen)
+	for i, v := range rtab {
+		nativeEndian.PutUint32(data[4*i:], v)
 	}
-
-	buf := new(bytes.Buffer)
-	err := binary.Write(buf, nativeEndian, rate)
-	return buf.Bytes(), err
+	return data, nil
 }
 
 // iproute2/tc/tc_core.c:tc_adjust_size()
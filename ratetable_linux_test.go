@@ -7,6 +7,7 @@ import (
 	"errors"
 	"testing"
 
+	"github.com/florianl/go-tc/core"
 	"github.com/florianl/go-tc/internal/unix"
 )
 
@@ -206,6 +207,54 @@ func TestGenerateRateTable(t *testing.T) {
 	})
 }
 
+func TestCalcRateTable(t *testing.T) {
+	restore, err := core.SetClock(0x3e8, 0x40, 0xf4240)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restore()
+
+	tests := map[string]struct {
+		rate   RateSpec
+		mtu    uint32
+		expect []uint32
+	}{
+		"police rate 1kbit burst 40 mtu 9k": {
+			rate:   RateSpec{Rate: 125},
+			mtu:    9000,
+			expect: rate1kbitBurst40Mtu9k,
+		},
+		"police rate 1mbit burst 100k": {
+			rate:   RateSpec{Rate: 125000},
+			expect: rate1mbitBurst100k,
+		},
+		"police rate 8kbit burst 5kb peakrate 12kbit mpu 64 mtu 1464 drop": {
+			rate:   RateSpec{Rate: 1000, Mpu: 64},
+			mtu:    1464,
+			expect: rate8kbitBurst5kbPeakrate12kbitMpu64Mtu1464Drop,
+		},
+	}
+
+	for name, testcase := range tests {
+		testcase := testcase
+		t.Run(name, func(t *testing.T) {
+			rtab, spec, err := CalcRateTable(testcase.rate, testcase.mtu, LinklayerEthernet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 256; i++ {
+				// the captured rate tables are stored in network byte order
+				if expect := endianSwapUint32(testcase.expect[i]); rtab[i] != expect {
+					t.Fatalf("\n%d:\t0x%08x 0x%08x", i, rtab[i], expect)
+				}
+			}
+			if spec.CellAlign != 0xFFFF || spec.Linklayer != unix.LINKLAYER_ETHERNET {
+				t.Fatalf("unexpected adjusted RateSpec: %#v", spec)
+			}
+		})
+	}
+}
+
 func TestAdjustSize(t *testing.T) {
 	tests := map[string]struct {
 		sz, mpu, linklayer uint
@@ -1,7 +1,16 @@
 package tc
 
 import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/google/go-cmp/cmp"
 
 	"github.com/mdlayher/netlink"
 )
@@ -38,3 +47,325 @@ func stripRateTable(t *testing.T, orig []byte, skip []uint16) ([]byte, error) {
 
 	return netlink.MarshalAttributes(attrs)
 }
+
+func TestCalcRateTableAtm(t *testing.T) {
+	restore, err := core.SetClock(0x3e8, 0x40, 0xf4240)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restore()
+
+	// tc qdisc add dev eth0 root tbf rate 8kbit burst 5kb latency 70ms linklayer atm
+	rtab, spec, err := CalcRateTable(RateSpec{Rate: 1000}, 0, LinklayerAtm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.CellLog != 3 || spec.CellAlign != 0xFFFF || spec.Linklayer != uint8(LinklayerAtm) {
+		t.Fatalf("unexpected adjusted RateSpec: %#v", spec)
+	}
+	expected := map[int]uint32{
+		// 8 bytes fit into a single ATM cell of 53 bytes
+		0: 828125,
+		// 48 bytes are the payload of a single ATM cell
+		5: 828125,
+		// 56 bytes require two ATM cells
+		6:   1656250,
+		255: 35609375,
+	}
+	for i, ticks := range expected {
+		if rtab[i] != ticks {
+			t.Fatalf("rtab[%d]: expected %d but got %d", i, ticks, rtab[i])
+		}
+	}
+
+	if _, _, err := CalcRateTable(RateSpec{}, 0, LinklayerEthernet); !errors.Is(err, ErrInvalidArg) {
+		t.Fatalf("expected ErrInvalidArg but got %v", err)
+	}
+}
+
+func TestCalcXmitTime(t *testing.T) {
+	restore, err := core.SetClock(0x3e8, 0x40, 0xf4240)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restore()
+
+	// The burst of testdata/iproute2_police.txt, that tc computes with
+	// tc_calc_xmittime().
+	tests := map[string]struct {
+		rate  uint64
+		size  uint32
+		ticks uint32
+	}{
+		"rate 1mbit burst 10k":  {rate: 125000, size: 10240, ticks: 0x138800},
+		"rate 10mbit burst 64k": {rate: 1250000, size: 65536, ticks: 0xc7ff3},
+		"rate 1gbit burst 1m":   {rate: 125000000, size: 1048576, ticks: 0x1fff6},
+	}
+	for name, testcase := range tests {
+		testcase := testcase
+		t.Run(name, func(t *testing.T) {
+			if ticks := CalcXmitTime(testcase.rate, testcase.size); ticks != testcase.ticks {
+				t.Fatalf("expected %d but got %d", testcase.ticks, ticks)
+			}
+		})
+	}
+}
+
+func TestCalcBurst(t *testing.T) {
+	tests := map[string]struct {
+		rate   uint64
+		buffer time.Duration
+		burst  uint32
+		err    error
+	}{
+		"1mbit 100ms": {rate: 125000, buffer: 100 * time.Millisecond, burst: 12500},
+		"10gbit 1s":   {rate: 1250000000, buffer: time.Second, burst: 1250000000},
+		"100gbit 1s":  {rate: 12500000000, buffer: time.Second, err: ErrInvalidArg},
+		"negative":    {rate: 125000, buffer: -time.Second, err: ErrInvalidArg},
+		"no rate":     {buffer: time.Second},
+	}
+	for name, testcase := range tests {
+		testcase := testcase
+		t.Run(name, func(t *testing.T) {
+			burst, err := CalcBurst(testcase.rate, testcase.buffer)
+			if !errors.Is(err, testcase.err) {
+				t.Fatalf("expected %v but got %v", testcase.err, err)
+			}
+			if burst != testcase.burst {
+				t.Fatalf("expected %d but got %d", testcase.burst, burst)
+			}
+		})
+	}
+}
+
+func TestNewRateSpec(t *testing.T) {
+	tests := map[string]struct {
+		rate      uint64
+		mtu       uint32
+		linklayer Linklayer
+		overhead  uint16
+		mpu       uint16
+		want      RateSpec
+		err       error
+	}{
+		"ethernet": {
+			rate: 125000, linklayer: LinklayerEthernet,
+			want: RateSpec{CellLog: 3, Linklayer: 1, CellAlign: 0xFFFF, Rate: 125000},
+		},
+		"atm": {
+			rate: 125000, mtu: 1600, linklayer: LinklayerAtm, overhead: 10, mpu: 64,
+			want: RateSpec{CellLog: 3, Linklayer: 2, Overhead: 10, CellAlign: 0xFFFF, Mpu: 64, Rate: 125000},
+		},
+		"jumbo frames": {
+			rate: 125000, mtu: 9000, linklayer: LinklayerEthernet,
+			want: RateSpec{CellLog: 6, Linklayer: 1, CellAlign: 0xFFFF, Rate: 125000},
+		},
+		"64 bit rate": {
+			rate: 12500000000, linklayer: LinklayerEthernet,
+			want: RateSpec{CellLog: 3, Linklayer: 1, CellAlign: 0xFFFF, Rate: 0xFFFFFFFF},
+		},
+		"unknown link layer": {rate: 125000, linklayer: Linklayer(3), err: ErrInvalidArg},
+		"no rate":            {linklayer: LinklayerEthernet, err: ErrInvalidArg},
+	}
+	for name, testcase := range tests {
+		testcase := testcase
+		t.Run(name, func(t *testing.T) {
+			spec, err := NewRateSpec(testcase.rate, testcase.mtu, testcase.linklayer,
+				testcase.overhead, testcase.mpu)
+			if !errors.Is(err, testcase.err) {
+				t.Fatalf("expected %v but got %v", testcase.err, err)
+			}
+			if testcase.err != nil {
+				return
+			}
+			if diff := cmp.Diff(testcase.want, spec); diff != "" {
+				t.Fatalf("RateSpec missmatch (-want +got):\n%s", diff)
+			}
+
+			data, err := spec.MarshalBinary()
+			if err != nil {
+				t.Fatalf("could not encode RateSpec: %v", err)
+			}
+			var decoded RateSpec
+			if err := decoded.UnmarshalBinary(data); err != nil {
+				t.Fatalf("could not decode RateSpec: %v", err)
+			}
+			if diff := cmp.Diff(spec, decoded); diff != "" {
+				t.Fatalf("decoded RateSpec missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// iproute2Options returns the payloads of TCA_OPTIONS in testdata, that were
+// sent by tc for the commands.
+func iproute2Options(t *testing.T, file string) map[string]map[uint16][]byte {
+	t.Helper()
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatalf("could not open fixtures: %v", err)
+	}
+	defer f.Close()
+
+	fixtures := make(map[string]map[uint16][]byte)
+	var command string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<16)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "#"):
+		case command == "":
+			command = line
+		default:
+			data, err := hex.DecodeString(line)
+			if err != nil {
+				t.Fatalf("%s: %v", command, err)
+			}
+			ad, err := netlink.NewAttributeDecoder(data)
+			if err != nil {
+				t.Fatalf("%s: %v", command, err)
+			}
+			attrs := make(map[uint16][]byte)
+			for ad.Next() {
+				attrs[ad.Type()] = ad.Bytes()
+			}
+			fixtures[command] = attrs
+			command = ""
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("could not read fixtures: %v", err)
+	}
+	return fixtures
+}
+
+func TestRateSpecIproute2(t *testing.T) {
+	restore, err := core.SetClock(0x3e8, 0x40, 0xf4240)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restore()
+	fixtures := iproute2Options(t, "testdata/iproute2_ratespec.txt")
+
+	rateTable := func(t *testing.T, spec RateSpec, mtu uint32) []byte {
+		t.Helper()
+		rtab, err := spec.RateTable(mtu)
+		if err != nil {
+			t.Fatalf("could not calculate rate table: %v", err)
+		}
+		data := make([]byte, 4*len(rtab))
+		for i, v := range rtab {
+			nativeEndian.PutUint32(data[4*i:], v)
+		}
+		return data
+	}
+
+	tbfTests := map[string]struct {
+		linklayer Linklayer
+		overhead  uint16
+		mpu       uint16
+	}{
+		"tc qdisc add dev veth0 root handle 1: tbf rate 1mbit burst 10kb latency 50ms": {
+			linklayer: LinklayerEthernet,
+		},
+		"tc qdisc replace dev veth0 root handle 1: tbf rate 1mbit burst 10kb latency 50ms linklayer atm overhead 10 mpu 64": {
+			linklayer: LinklayerAtm, overhead: 10, mpu: 64,
+		},
+	}
+	for command, testcase := range tbfTests {
+		testcase := testcase
+		t.Run(command, func(t *testing.T) {
+			want, ok := fixtures[command]
+			if !ok {
+				t.Fatalf("missing fixture")
+			}
+			spec, err := NewRateSpec(125000, 0, testcase.linklayer, testcase.overhead, testcase.mpu)
+			if err != nil {
+				t.Fatalf("could not create RateSpec: %v", err)
+			}
+			data, err := marshalTbf(&Tbf{
+				Parms: &TbfQopt{Rate: spec, Limit: 16490, Buffer: 1280000},
+				Burst: uint32Ptr(10240),
+			})
+			if err != nil {
+				t.Fatalf("could not encode Tbf: %v", err)
+			}
+			ad, err := netlink.NewAttributeDecoder(data)
+			if err != nil {
+				t.Fatalf("could not decode Tbf: %v", err)
+			}
+			got := make(map[uint16][]byte)
+			for ad.Next() {
+				got[ad.Type()] = ad.Bytes()
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Fatalf("options missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	htbTests := map[string]struct {
+		linklayer Linklayer
+		overhead  uint16
+		mpu       uint16
+	}{
+		"tc class add dev veth0 parent 1: classid 1:1 htb rate 5mbit ceil 10mbit": {
+			linklayer: LinklayerEthernet,
+		},
+		"tc class add dev veth0 parent 1: classid 1:2 htb rate 5mbit ceil 10mbit linklayer atm overhead 8 mpu 53": {
+			linklayer: LinklayerAtm, overhead: 8, mpu: 53,
+		},
+	}
+	for command, testcase := range htbTests {
+		testcase := testcase
+		t.Run(command, func(t *testing.T) {
+			want, ok := fixtures[command]
+			if !ok {
+				t.Fatalf("missing fixture")
+			}
+			// tc assumes an MTU of 1600 bytes for htb.
+			rate, err := NewRateSpec(625000, 1600, testcase.linklayer, testcase.overhead, testcase.mpu)
+			if err != nil {
+				t.Fatalf("could not create RateSpec: %v", err)
+			}
+			ceil, err := NewRateSpec(1250000, 1600, testcase.linklayer, testcase.overhead, testcase.mpu)
+			if err != nil {
+				t.Fatalf("could not create RateSpec: %v", err)
+			}
+			var parms HtbOpt
+			if err := unmarshalStruct(want[tcaHtbParms], &parms); err != nil {
+				t.Fatalf("could not decode HtbOpt: %v", err)
+			}
+			if diff := cmp.Diff(parms.Rate, rate); diff != "" {
+				t.Fatalf("rate missmatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(parms.Ceil, ceil); diff != "" {
+				t.Fatalf("ceil missmatch (-want +got):\n%s", diff)
+			}
+			data, err := rate.MarshalBinary()
+			if err != nil {
+				t.Fatalf("could not encode RateSpec: %v", err)
+			}
+			if diff := cmp.Diff(want[tcaHtbParms][:len(data)], data); diff != "" {
+				t.Fatalf("encoded rate missmatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(want[tcaHtbRtab], rateTable(t, rate, 1600)); diff != "" {
+				t.Fatalf("rate table missmatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(want[tcaHtbCtab], rateTable(t, ceil, 1600)); diff != "" {
+				t.Fatalf("ceil table missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func BenchmarkGenerateRateTable(b *testing.B) {
+	pol := &Policy{Mtu: 1514, Rate: RateSpec{Rate: 125000}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := generateRateTable(pol); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package tc
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/florianl/go-tc/internal/unix"
+	"github.com/mdlayher/netlink"
+)
+
+// loopbackIfindex is the index of the loopback device, which exists in every
+// network namespace.
+const loopbackIfindex = 1
+
+// Ping checks with a request for the loopback device, that the kernel
+// answers on the connection. An error of the kernel, like ENODEV, is an
+// answer as well. With Config.AutoReopen, a failed connection is reopened
+// and checked again.
+func (tc *Tc) Ping() error {
+	// The kernel does not answer requests for builtin qdiscs like noqueue of
+	// the loopback device, so the device itself is requested.
+	req, err := marshalMessage(unix.RTM_GETLINK, 0, unix.IfInfomsg{
+		Family: unix.AF_UNSPEC,
+		Index:  loopbackIfindex,
+	}, nil)
+	if err != nil {
+		return err
+	}
+	// The reply to a request for a single device contains no ACK.
+	req.Header.Flags = netlink.Request
+	if _, err := tc.query(req); err != nil {
+		var errno syscall.Errno
+		if errors.As(err, &errno) && !isSocketError(err) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// reopen reopens the connection, if Config.AutoReopen is enabled and err is
+// an error of the socket. It reports whether the connection works again.
+func (tc *Tc) reopen(err error) bool {
+	con, ok := tc.con.(*reopenConn)
+	return ok && isSocketError(err) && con.reopen()
+}
+
+// isSocketError reports whether err is an error of the socket instead of an
+// answer of the kernel to a request.
+func isSocketError(err error) bool {
+	for _, errno := range []syscall.Errno{syscall.ENOBUFS, syscall.EBADF, syscall.ENOTSOCK,
+		syscall.ENOTCONN, syscall.ECONNRESET, syscall.EPIPE} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return errors.Is(err, os.ErrClosed)
+}
+
+// isGetRequest reports whether req only reads and can be sent again.
+func isGetRequest(req netlink.Message) bool {
+	switch req.Header.Type {
+	case unix.RTM_GETQDISC, unix.RTM_GETTCLASS, unix.RTM_GETTFILTER,
+		unix.RTM_GETACTION, unix.RTM_GETCHAIN, unix.RTM_GETLINK:
+		return true
+	}
+	return false
+}
+
+// reopenConn is a tcConn, whose connection is replaced by a new one, after
+// it failed with an error of the socket. As all copies of a Tc share the
+// reopenConn, the new connection is used by all of them. The socket options
+// and groups are recorded to restore them on the new connection.
+type reopenConn struct {
+	dial func() (tcConn, error)
+
+	mu  sync.Mutex
+	con tcConn
+	// broken is set to con, when con failed with an error of the socket.
+	broken     tcConn
+	closed     bool
+	options    map[netlink.ConnOption]bool
+	readBuffer int
+	groups     map[uint32]bool
+}
+
+var (
+	_ tcConn        = &reopenConn{}
+	_ batchReceiver = &reopenConn{}
+)
+
+func newReopenConn(con tcConn, dial func() (tcConn, error)) *reopenConn {
+	return &reopenConn{
+		dial:    dial,
+		con:     con,
+		options: make(map[netlink.ConnOption]bool),
+		groups:  make(map[uint32]bool),
+	}
+}
+
+// current returns the connection to use.
+func (c *reopenConn) current() tcConn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.con
+}
+
+// failed marks con as broken, if err is an error of the socket.
+func (c *reopenConn) failed(con tcConn, err error) {
+	if err == nil || !isSocketError(err) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.con == con {
+		c.broken = con
+	}
+}
+
+// reopen replaces a broken connection with a new one. If a concurrent call
+// already replaced it, the new connection is kept. It reports whether a
+// working connection is available.
+func (c *reopenConn) reopen() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return false
+	}
+	if c.broken != c.con {
+		return true
+	}
+
+	con, err := c.dial()
+	if err != nil {
+		return false
+	}
+	if err := c.restore(con); err != nil {
+		con.Close()
+		return false
+	}
+	c.con.Close()
+	c.con, c.broken = con, nil
+	return true
+}
+
+// restore applies the recorded socket options and groups to con.
+func (c *reopenConn) restore(con tcConn) error {
+	for option, enable := range c.options {
+		if err := con.SetOption(option, enable); err != nil {
+			return err
+		}
+	}
+	if c.readBuffer > 0 {
+		if err := con.SetReadBuffer(c.readBuffer); err != nil {
+			return err
+		}
+	}
+	for group := range c.groups {
+		if err := con.JoinGroup(group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *reopenConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return c.con.Close()
+}
+
+func (c *reopenConn) JoinGroup(group uint32) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.con.JoinGroup(group); err != nil {
+		return err
+	}
+	c.groups[group] = true
+	return nil
+}
+
+func (c *reopenConn) LeaveGroup(group uint32) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.groups, group)
+	return c.con.LeaveGroup(group)
+}
+
+func (c *reopenConn) Receive() ([]netlink.Message, error) {
+	con := c.current()
+	msgs, err := con.Receive()
+	c.failed(con, err)
+	return msgs, err
+}
+
+// receiveBatch receives the next batch of messages. If the connection is not
+// a batchReceiver, the complete reply is returned as one batch.
+func (c *reopenConn) receiveBatch() ([]netlink.Message, error) {
+	con := c.current()
+	br, ok := con.(batchReceiver)
+	if !ok {
+		return c.Receive()
+	}
+	msgs, err := br.receiveBatch()
+	c.failed(con, err)
+	return msgs, err
+}
+
+func (c *reopenConn) Send(m netlink.Message) (netlink.Message, error) {
+	con := c.current()
+	msg, err := con.Send(m)
+	c.failed(con, err)
+	return msg, err
+}
+
+func (c *reopenConn) SetOption(option netlink.ConnOption, enable bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.con.SetOption(option, enable); err != nil {
+		return err
+	}
+	c.options[option] = enable
+	return nil
+}
+
+func (c *reopenConn) SetReadBuffer(bytes int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.con.SetReadBuffer(bytes); err != nil {
+		return err
+	}
+	c.readBuffer = bytes
+	return nil
+}
+
+func (c *reopenConn) SetReadDeadline(t time.Time) error {
+	return c.current().SetReadDeadline(t)
+}
@@ -0,0 +1,303 @@
+package tc
+
+import (
+	"errors"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/florianl/go-tc/internal/unix"
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nltest"
+)
+
+// flakyConn answers requests like a kernel with a single fq_codel qdisc.
+// Once sendErr or receiveErr is set, the socket is broken and every send or
+// receive fails.
+type flakyConn struct {
+	tcConn
+	sendErr    error
+	receiveErr error
+
+	requests   []netlink.HeaderType
+	closed     bool
+	options    map[netlink.ConnOption]bool
+	readBuffer int
+	groups     map[uint32]bool
+}
+
+func newFlakyConn(t *testing.T, answer error) *flakyConn {
+	t.Helper()
+	return &flakyConn{
+		tcConn: nltest.Dial(func(req []netlink.Message) ([]netlink.Message, error) {
+			if answer != nil {
+				return nltest.Error(int(answer.(syscall.Errno)), req)
+			}
+			switch req[0].Header.Type {
+			case unix.RTM_GETQDISC:
+				msg, err := MarshalObject(OpQdiscAdd, &Object{
+					Msg:       Msg{Family: unix.AF_UNSPEC, Ifindex: 1, Handle: 0x80010000, Parent: HandleRoot},
+					Attribute: Attribute{Kind: "fq_codel", FqCodel: &FqCodel{Limit: uint32Ptr(10240)}},
+				})
+				if err != nil {
+					t.Fatalf("could not marshal qdisc: %v", err)
+				}
+				return []netlink.Message{{Header: netlink.Header{Type: unix.RTM_NEWQDISC}, Data: msg.Data}}, nil
+			case unix.RTM_GETLINK:
+				return []netlink.Message{{Header: netlink.Header{Type: unix.RTM_NEWLINK}, Data: make([]byte, 16)}}, nil
+			}
+			return []netlink.Message{{Header: netlink.Header{Type: netlink.Error}, Data: make([]byte, 4)}}, nil
+		}),
+		options: make(map[netlink.ConnOption]bool),
+		groups:  make(map[uint32]bool),
+	}
+}
+
+func (c *flakyConn) Send(m netlink.Message) (netlink.Message, error) {
+	c.requests = append(c.requests, m.Header.Type)
+	if c.sendErr != nil {
+		return netlink.Message{}, &netlink.OpError{Op: "send", Err: c.sendErr}
+	}
+	return c.tcConn.Send(m)
+}
+
+func (c *flakyConn) Receive() ([]netlink.Message, error) {
+	if c.receiveErr != nil {
+		return nil, &netlink.OpError{Op: "receive", Err: c.receiveErr}
+	}
+	return c.tcConn.Receive()
+}
+
+func (c *flakyConn) Close() error {
+	c.closed = true
+	return c.tcConn.Close()
+}
+
+func (c *flakyConn) JoinGroup(group uint32) error {
+	c.groups[group] = true
+	return nil
+}
+
+func (c *flakyConn) SetOption(option netlink.ConnOption, enable bool) error {
+	c.options[option] = enable
+	return nil
+}
+
+func (c *flakyConn) SetReadBuffer(bytes int) error {
+	c.readBuffer = bytes
+	return nil
+}
+
+func TestPing(t *testing.T) {
+	tests := map[string]struct {
+		answer     error
+		receiveErr error
+		close      bool
+		err        error
+	}{
+		"alive":              {},
+		"answer of kernel":   {answer: syscall.ENODEV},
+		"broken socket":      {receiveErr: syscall.ENOBUFS, err: syscall.ENOBUFS},
+		"closed by the user": {close: true, err: syscall.EBADF},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			con := newFlakyConn(t, testcase.answer)
+			con.receiveErr = testcase.receiveErr
+			tcSocket := &Tc{con: con}
+			if testcase.close {
+				con.Close()
+				con.sendErr = syscall.EBADF
+			}
+			if err := tcSocket.Ping(); !errors.Is(err, testcase.err) {
+				t.Fatalf("expected error %v but got %v", testcase.err, err)
+			}
+			if diff := cmp.Diff([]netlink.HeaderType{unix.RTM_GETLINK}, con.requests); diff != "" {
+				t.Fatalf("requests missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAutoReopen(t *testing.T) {
+	qdisc := &Object{
+		Msg:       Msg{Family: unix.AF_UNSPEC, Ifindex: 1, Handle: 0x10000, Parent: HandleRoot},
+		Attribute: Attribute{Kind: "fq_codel", FqCodel: &FqCodel{Limit: uint32Ptr(10240)}},
+	}
+	get := func(tcSocket *Tc) error {
+		qdiscs, err := tcSocket.Qdisc().Get()
+		if err == nil && len(qdiscs) != 1 {
+			t.Fatalf("expected 1 qdisc but got %d", len(qdiscs))
+		}
+		return err
+	}
+	walk := func(tcSocket *Tc) error {
+		var n int
+		err := tcSocket.Qdisc().Walk(func(*Object) error {
+			n++
+			return nil
+		})
+		if err == nil && n != 1 {
+			t.Fatalf("expected 1 qdisc but got %d", n)
+		}
+		return err
+	}
+	add := func(tcSocket *Tc) error {
+		return tcSocket.Qdisc().Add(qdisc)
+	}
+
+	tests := map[string]struct {
+		sendErr    error
+		receiveErr error
+		answer     error
+		disabled   bool
+		closed     bool
+		dialErr    error
+		op         func(*Tc) error
+		err        error
+		// resent are the requests on the new connection.
+		resent []netlink.HeaderType
+		dials  int
+	}{
+		"dump is sent again": {
+			receiveErr: syscall.ENOBUFS,
+			op:         get,
+			resent:     []netlink.HeaderType{unix.RTM_GETQDISC},
+			dials:      1,
+		},
+		"walk is sent again": {
+			receiveErr: syscall.ENOBUFS,
+			op:         walk,
+			resent:     []netlink.HeaderType{unix.RTM_GETQDISC},
+			dials:      1,
+		},
+		"change is not sent again": {
+			receiveErr: syscall.ENOBUFS,
+			op:         add,
+			err:        syscall.ENOBUFS,
+			dials:      1,
+		},
+		"unsent change is sent again": {
+			sendErr: syscall.EBADF,
+			op:      add,
+			resent:  []netlink.HeaderType{unix.RTM_NEWQDISC},
+			dials:   1,
+		},
+		"answer of kernel": {
+			answer: syscall.EINVAL,
+			op:     add,
+			err:    syscall.EINVAL,
+		},
+		"dial fails": {
+			receiveErr: syscall.ENOBUFS,
+			dialErr:    syscall.EMFILE,
+			op:         get,
+			err:        syscall.ENOBUFS,
+			dials:      1,
+		},
+		"disabled": {
+			receiveErr: syscall.ENOBUFS,
+			disabled:   true,
+			op:         get,
+			err:        syscall.ENOBUFS,
+		},
+		"closed by the user": {
+			sendErr: syscall.EBADF,
+			closed:  true,
+			op:      get,
+			err:     syscall.EBADF,
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			first := newFlakyConn(t, testcase.answer)
+			first.sendErr, first.receiveErr = testcase.sendErr, testcase.receiveErr
+			var second *flakyConn
+			var dials int
+			var con tcConn = first
+			if !testcase.disabled {
+				con = newReopenConn(first, func() (tcConn, error) {
+					dials++
+					if testcase.dialErr != nil {
+						return nil, testcase.dialErr
+					}
+					second = newFlakyConn(t, nil)
+					return second, nil
+				})
+			}
+			tcSocket, err := newTc(con, &Config{SkipValidation: true, StrictCheck: true, ReadBuffer: 4096})
+			if err != nil {
+				t.Fatalf("could not configure connection: %v", err)
+			}
+			if err := tcSocket.con.JoinGroup(unix.RTNLGRP_TC); err != nil {
+				t.Fatalf("could not join group: %v", err)
+			}
+			if testcase.closed {
+				tcSocket.Close()
+			}
+
+			if err := testcase.op(tcSocket); !errors.Is(err, testcase.err) {
+				t.Fatalf("expected error %v but got %v", testcase.err, err)
+			}
+			if dials != testcase.dials {
+				t.Fatalf("expected %d dials but got %d", testcase.dials, dials)
+			}
+			if second == nil {
+				if len(testcase.resent) != 0 {
+					t.Fatalf("connection was not reopened")
+				}
+				return
+			}
+			if !first.closed {
+				t.Fatalf("broken connection was not closed")
+			}
+			if diff := cmp.Diff(testcase.resent, second.requests); diff != "" {
+				t.Fatalf("resent requests missmatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(first.options, second.options); diff != "" {
+				t.Fatalf("socket options missmatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(first.groups, second.groups); diff != "" {
+				t.Fatalf("groups missmatch (-want +got):\n%s", diff)
+			}
+			if second.readBuffer != first.readBuffer {
+				t.Fatalf("expected read buffer of %d but got %d", first.readBuffer, second.readBuffer)
+			}
+			// The new connection is used by all copies of the Tc.
+			if err := tcSocket.Class().Ping(); err != nil {
+				t.Fatalf("new connection is not used: %v", err)
+			}
+			if len(first.requests) != 1 {
+				t.Fatalf("broken connection was used again: %v", first.requests)
+			}
+		})
+	}
+}
+
+func TestReopenConcurrent(t *testing.T) {
+	first := newFlakyConn(t, nil)
+	var dials int
+	con := newReopenConn(first, func() (tcConn, error) {
+		dials++
+		return newFlakyConn(t, nil), nil
+	})
+	con.failed(first, syscall.ENOBUFS)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !con.reopen() {
+				t.Errorf("connection was not reopened")
+			}
+		}()
+	}
+	wg.Wait()
+	if dials != 1 {
+		t.Fatalf("expected 1 dial but got %d", dials)
+	}
+}
@@ -0,0 +1,187 @@
+package tc
+
+import (
+	"context"
+	"errors"
+	"math"
+	"syscall"
+	"time"
+)
+
+// SampleTarget identifies a class, whose statistics are sampled.
+type SampleTarget struct {
+	Ifindex uint32
+	Handle  uint32
+}
+
+// RateSample contains the rates of a target between two polls.
+type RateSample struct {
+	Target SampleTarget
+	// Time is the time of the poll and Interval the time since the previous one.
+	Time     time.Time
+	Interval time.Duration
+
+	// Bytes and Packets are the cumulative counters of the target.
+	Bytes   uint64
+	Packets uint64
+	// Bps and Pps are the rates in bytes and packets per second.
+	Bps float64
+	Pps float64
+	// Qlen and Backlog are the packets and bytes in the queue of the target
+	// at the time of the poll. They are zero, if the kernel did not report
+	// the queue statistics.
+	Qlen    uint32
+	Backlog uint32
+
+	// Err is set, if the statistics could not be fetched. If Deleted is set,
+	// the target does not exist anymore and is no longer polled.
+	Err     error
+	Deleted bool
+}
+
+// sampleState holds the counters of the previous poll of a target.
+type sampleState struct {
+	time    time.Time
+	bytes   uint64
+	packets uint64
+	// packets32 is set, if packets originates from the 32 bit counter.
+	packets32 bool
+	valid     bool
+}
+
+// Sampler polls the statistics of classes in a fixed interval and computes
+// their byte and packet rates. A Sampler must not be run more than once at
+// the same time.
+type Sampler struct {
+	interval time.Duration
+	targets  []SampleTarget
+	state    map[SampleTarget]*sampleState
+
+	fetch func(ifindex, handle uint32) (*Stats2, error)
+	now   func() time.Time
+}
+
+// NewSampler returns a Sampler, that polls the statistics of targets with
+// tcSocket every interval.
+func NewSampler(tcSocket *Tc, interval time.Duration, targets ...SampleTarget) *Sampler {
+	class := tcSocket.Class()
+	return newSampler(interval, targets, func(ifindex, handle uint32) (*Stats2, error) {
+		stats, _, err := class.Stats(ifindex, handle)
+		return stats, err
+	}, time.Now)
+}
+
+func newSampler(interval time.Duration, targets []SampleTarget,
+	fetch func(ifindex, handle uint32) (*Stats2, error), now func() time.Time) *Sampler {
+	s := &Sampler{
+		interval: interval,
+		targets:  append([]SampleTarget{}, targets...),
+		state:    make(map[SampleTarget]*sampleState),
+		fetch:    fetch,
+		now:      now,
+	}
+	for _, target := range s.targets {
+		s.state[target] = &sampleState{}
+	}
+	return s
+}
+
+// Run polls the targets until ctx is done or all targets are deleted and
+// calls fn for every sample. The first poll of a target only establishes the
+// baseline of the counters and is not reported.
+func (s *Sampler) Run(ctx context.Context, fn func(RateSample)) error {
+	if s.interval <= 0 {
+		return ErrInvalidArg
+	}
+	if !s.poll(fn) {
+		return nil
+	}
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if !s.poll(fn) {
+				return nil
+			}
+		}
+	}
+}
+
+// Samples runs the Sampler in the background and delivers the samples on the
+// returned channel, which is closed, when the Sampler stops.
+func (s *Sampler) Samples(ctx context.Context) <-chan RateSample {
+	samples := make(chan RateSample)
+	go func() {
+		defer close(samples)
+		_ = s.Run(ctx, func(sample RateSample) {
+			select {
+			case samples <- sample:
+			case <-ctx.Done():
+			}
+		})
+	}()
+	return samples
+}
+
+// poll fetches the statistics of all remaining targets once and reports
+// whether there are targets left.
+func (s *Sampler) poll(fn func(RateSample)) bool {
+	remaining := s.targets[:0]
+	for _, target := range s.targets {
+		stats, err := s.fetch(target.Ifindex, target.Handle)
+		now := s.now()
+		if err != nil {
+			deleted := errors.Is(err, syscall.ENOENT)
+			fn(RateSample{Target: target, Time: now, Err: err, Deleted: deleted})
+			if deleted {
+				delete(s.state, target)
+				continue
+			}
+			remaining = append(remaining, target)
+			continue
+		}
+		remaining = append(remaining, target)
+
+		state := s.state[target]
+		bytes, packets := stats.Bytes(), stats.Packets64()
+		packets32 := stats.Pkt64 == nil
+		if state.valid {
+			interval := now.Sub(state.time)
+			sample := RateSample{
+				Target:   target,
+				Time:     now,
+				Interval: interval,
+				Bytes:    bytes,
+				Packets:  packets,
+			}
+			if stats.Queue != nil {
+				sample.Qlen, sample.Backlog = stats.Queue.QueueLen, stats.Queue.Backlog
+			}
+			if seconds := interval.Seconds(); seconds > 0 {
+				sample.Bps = float64(counterDelta(state.bytes, bytes, false)) / seconds
+				sample.Pps = float64(counterDelta(state.packets, packets,
+					packets32 && state.packets32)) / seconds
+			}
+			fn(sample)
+		}
+		*state = sampleState{time: now, bytes: bytes, packets: packets, packets32: packets32, valid: true}
+	}
+	s.targets = remaining
+	return len(s.targets) > 0
+}
+
+// counterDelta returns the difference between the counters prev and cur.
+// A 32 bit counter, that is smaller than before, wrapped around. Other
+// counters, that are smaller than before, were reset.
+func counterDelta(prev, cur uint64, is32 bool) uint64 {
+	switch {
+	case cur >= prev:
+		return cur - prev
+	case is32:
+		return cur + math.MaxUint32 + 1 - prev
+	}
+	return cur
+}
@@ -0,0 +1,135 @@
+package tc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestSampler(t *testing.T) {
+	a := SampleTarget{Ifindex: 1, Handle: 0x10010}
+	b := SampleTarget{Ifindex: 1, Handle: 0x10020}
+	start := time.Unix(1000, 0)
+	errTemporary := errors.New("temporary")
+
+	// counters of the targets for every poll
+	counters := map[SampleTarget][]*Stats2{
+		a: {
+			{Basic: &GenBasic{Bytes: 1000, Packets: 0xFFFFFFF0}},
+			{Basic: &GenBasic{Bytes: 3000, Packets: 0x0000000A}, Queue: &GenQueue{QueueLen: 12, Backlog: 18000}},
+			{Basic: &GenBasic{Bytes: 4000, Packets: 0x0000001A}},
+		},
+		b: {
+			{Basic: &GenBasic{Bytes: 10}, Pkt64: uint64Ptr(1 << 33)},
+			nil,
+			{Basic: &GenBasic{Bytes: 10}, Pkt64: uint64Ptr(1<<33 + 100)},
+		},
+	}
+	errs := map[SampleTarget][]error{
+		a: {nil, nil, nil, syscall.ENOENT},
+		b: {nil, errTemporary, nil, fmt.Errorf("class: %w", syscall.ENOENT)},
+	}
+
+	polls := make(map[SampleTarget]int)
+	var now time.Time
+	s := newSampler(time.Second, []SampleTarget{a, b}, func(ifindex, handle uint32) (*Stats2, error) {
+		target := SampleTarget{Ifindex: ifindex, Handle: handle}
+		i := polls[target]
+		polls[target]++
+		if err := errs[target][i]; err != nil {
+			return nil, err
+		}
+		return counters[target][i], nil
+	}, func() time.Time { return now })
+
+	var samples []RateSample
+	for i := 0; ; i++ {
+		now = start.Add(time.Duration(i) * 2 * time.Second)
+		if !s.poll(func(sample RateSample) { samples = append(samples, sample) }) {
+			break
+		}
+		if i > 3 {
+			t.Fatalf("sampler does not stop")
+		}
+	}
+
+	want := []RateSample{
+		{Target: a, Time: start.Add(2 * time.Second), Interval: 2 * time.Second,
+			Bytes: 3000, Packets: 0xA, Bps: 1000, Pps: 13, Qlen: 12, Backlog: 18000},
+		{Target: b, Time: start.Add(2 * time.Second), Err: errTemporary},
+		{Target: a, Time: start.Add(4 * time.Second), Interval: 2 * time.Second,
+			Bytes: 4000, Packets: 0x1A, Bps: 500, Pps: 8},
+		{Target: b, Time: start.Add(4 * time.Second), Interval: 4 * time.Second,
+			Bytes: 10, Packets: 1<<33 + 100, Bps: 0, Pps: 25},
+		{Target: a, Time: start.Add(6 * time.Second), Err: syscall.ENOENT, Deleted: true},
+		{Target: b, Time: start.Add(6 * time.Second), Err: fmt.Errorf("class: %w", syscall.ENOENT), Deleted: true},
+	}
+	if diff := cmp.Diff(want, samples, cmpopts.IgnoreFields(RateSample{}, "Err")); diff != "" {
+		t.Fatalf("samples missmatch (-want +got):\n%s", diff)
+	}
+	for i := range want {
+		if (want[i].Err == nil) != (samples[i].Err == nil) {
+			t.Fatalf("sample %d: expected error %v but got %v", i, want[i].Err, samples[i].Err)
+		}
+	}
+}
+
+func TestSamplerSamples(t *testing.T) {
+	target := SampleTarget{Ifindex: 2, Handle: 0x10001}
+	var bytes uint64
+	s := newSampler(time.Millisecond, []SampleTarget{target}, func(ifindex, handle uint32) (*Stats2, error) {
+		bytes += 100
+		if bytes > 300 {
+			return nil, syscall.ENOENT
+		}
+		return &Stats2{Basic: &GenBasic{Bytes: bytes, Packets: uint32(bytes / 100)}}, nil
+	}, time.Now)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var samples []RateSample
+	for sample := range s.Samples(ctx) {
+		samples = append(samples, sample)
+	}
+	if len(samples) != 3 {
+		t.Fatalf("expected 3 samples but got %d: %v", len(samples), samples)
+	}
+	if !samples[2].Deleted {
+		t.Fatalf("expected last sample to report deletion: %v", samples[2])
+	}
+	for _, sample := range samples[:2] {
+		if sample.Bps <= 0 || sample.Pps <= 0 {
+			t.Fatalf("expected positive rates: %v", sample)
+		}
+	}
+}
+
+func TestCounterDelta(t *testing.T) {
+	tests := map[string]struct {
+		prev, cur uint64
+		is32      bool
+		want      uint64
+	}{
+		"increase": {prev: 10, cur: 20, want: 10},
+		"wrap":     {prev: 0xFFFFFFFE, cur: 1, is32: true, want: 3},
+		"reset":    {prev: 1 << 40, cur: 5, want: 5},
+	}
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := counterDelta(testcase.prev, testcase.cur, testcase.is32); got != testcase.want {
+				t.Fatalf("expected %d but got %d", testcase.want, got)
+			}
+		})
+	}
+
+	if err := newSampler(0, nil, nil, time.Now).Run(context.Background(), func(RateSample) {}); !errors.Is(err, ErrInvalidArg) {
+		t.Fatalf("expected ErrInvalidArg but got %v", err)
+	}
+}
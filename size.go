@@ -0,0 +1,104 @@
+package tc
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// nlmsgHeaderLen is the size of struct nlmsghdr.
+const nlmsgHeaderLen = 16
+
+// MessageSizeError is returned before a request is sent, if an attribute or
+// the complete message exceeds the size, that netlink can carry. It wraps
+// ErrMessageTooLarge and, for compatibility, ErrInvalidArg.
+type MessageSizeError struct {
+	// Kind is the kind of the object, if it is known.
+	Kind string
+	// Attribute is the type of the attribute, that is too large. It is zero,
+	// if the complete message is too large.
+	Attribute uint16
+	// Size is the size of the attribute or message including its header and
+	// Limit the maximum size.
+	Size  int
+	Limit int
+}
+
+func (e *MessageSizeError) Error() string {
+	var prefix string
+	if e.Kind != "" {
+		prefix = e.Kind + ": "
+	}
+	if e.Attribute == 0 {
+		return fmt.Sprintf("%smessage of %d bytes exceeds the send buffer of %d bytes: %v",
+			prefix, e.Size, e.Limit, ErrMessageTooLarge)
+	}
+	return fmt.Sprintf("%sattribute %d of %d bytes exceeds the maximum of %d bytes: %v",
+		prefix, e.Attribute, e.Size, e.Limit, ErrMessageTooLarge)
+}
+
+// Unwrap returns ErrMessageTooLarge.
+func (e *MessageSizeError) Unwrap() error {
+	return ErrMessageTooLarge
+}
+
+// Is reports whether target is ErrInvalidArg, which was returned for too
+// large attributes before ErrMessageTooLarge.
+func (e *MessageSizeError) Is(target error) bool {
+	return target == ErrInvalidArg
+}
+
+// withKind sets the kind of a *MessageSizeError in err to kind, so that the
+// error names the object, whose attribute is too large.
+func withKind(kind string, err error) error {
+	var sizeErr *MessageSizeError
+	if errors.As(err, &sizeErr) && sizeErr.Kind == "" {
+		sizeErr.Kind = kind
+	}
+	return err
+}
+
+// messageLimit returns the maximum size of a message, that the kernel
+// accepts on a socket with the default send buffer.
+// linux/net/netlink/af_netlink.c:netlink_sendmsg() refuses messages larger
+// than the send buffer minus 32 bytes with EMSGSIZE.
+var messageLimit = func() int {
+	sendBufferOnce.Do(func() {
+		sendBuffer = readSendBuffer()
+	})
+	return sendBuffer - 32
+}
+
+// readSendBuffer reads net.core.wmem_default. It returns defaultSendBuffer,
+// if the sysctl can not be read.
+func readSendBuffer() int {
+	data, err := ioutil.ReadFile(procFile("sys/net/core/wmem_default"))
+	if err != nil {
+		return defaultSendBuffer
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && n > 32 {
+		return n
+	}
+	return defaultSendBuffer
+}
+
+// defaultSendBuffer is the default of net.core.wmem_default on most systems.
+const defaultSendBuffer = 212992
+
+var (
+	sendBufferOnce sync.Once
+	sendBuffer     int
+)
+
+// checkMessageSize returns a *MessageSizeError, if a message with the
+// payload data of the object kind exceeds messageLimit.
+func checkMessageSize(kind string, data []byte) error {
+	size := nlmsgHeaderLen + len(data)
+	if limit := messageLimit(); size > limit {
+		return &MessageSizeError{Kind: kind, Size: size, Limit: limit}
+	}
+	return nil
+}
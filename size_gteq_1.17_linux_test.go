@@ -0,0 +1,48 @@
+//go:build linux && go1.17
+// +build linux,go1.17
+
+package tc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadSendBuffer(t *testing.T) {
+	tests := map[string]struct {
+		data   string
+		buffer int
+	}{
+		"wmem_default": {data: "4194304\n", buffer: 4194304},
+		"too small":    {data: "32\n", buffer: defaultSendBuffer},
+		"invalid":      {data: "default\n", buffer: defaultSendBuffer},
+	}
+	for name, testcase := range tests {
+		testcase := testcase
+		t.Run(name, func(t *testing.T) {
+			procRoot := t.TempDir()
+			if err := os.MkdirAll(filepath.Join(procRoot, "sys/net/core"), 0750); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(procRoot, "sys/net/core/wmem_default"),
+				[]byte(testcase.data), 0640); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("PROC_ROOT", procRoot)
+
+			if buffer := readSendBuffer(); buffer != testcase.buffer {
+				t.Fatalf("expected %d but got %d", testcase.buffer, buffer)
+			}
+		})
+	}
+
+	t.Run("sysctl does not exist", func(t *testing.T) {
+		t.Setenv("PROC_ROOT", t.TempDir())
+
+		if buffer := readSendBuffer(); buffer != defaultSendBuffer {
+			t.Fatalf("expected %d but got %d", defaultSendBuffer, buffer)
+		}
+	})
+}
@@ -0,0 +1,80 @@
+package tc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nltest"
+)
+
+func TestMessageSize(t *testing.T) {
+	origLimit := messageLimit
+	defer func() { messageLimit = origLimit }()
+
+	filterMsg := Msg{Family: unix.AF_UNSPEC, Ifindex: 2, Handle: 1, Parent: HandleClsactIngress,
+		Info: core.BuildFilterInfo(1, core.EthPAll)}
+	// A classic BPF program of 9000 instructions does not fit into the 16 bit
+	// length of an attribute.
+	ops := make([]byte, 9000*8)
+	bpf := Object{Msg: filterMsg, Attribute: Attribute{Kind: "bpf",
+		BPF: &Bpf{Ops: &ops, OpsLen: uint16Ptr(9000), ClassID: uint32Ptr(0x10001)}}}
+	dist := make([]int16, 16384)
+	netem := Object{Msg: Msg{Family: unix.AF_UNSPEC, Ifindex: 2, Handle: core.BuildHandle(0x1, 0x0), Parent: HandleRoot},
+		Attribute: Attribute{Kind: "netem", Netem: &Netem{Qopt: NetemQopt{Limit: 1000}, DelayDist: &dist}}}
+
+	tests := map[string]struct {
+		limit int
+		add   func(tc *Tc) error
+		want  MessageSizeError
+	}{
+		"attribute": {
+			limit: origLimit(),
+			add:   func(tc *Tc) error { return tc.Filter().Add(&bpf) },
+			want:  MessageSizeError{Kind: "bpf", Attribute: tcaBpfOps, Size: nlaHeaderLen + len(ops), Limit: nlaMaxLen},
+		},
+		"message": {
+			limit: 4096,
+			add:   func(tc *Tc) error { return tc.Qdisc().Add(&netem) },
+			want:  MessageSizeError{Kind: "netem", Limit: 4096},
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			messageLimit = func() int { return testcase.limit }
+			var sent int
+			tcSocket := &Tc{
+				con: nltest.Dial(func(req []netlink.Message) ([]netlink.Message, error) {
+					sent++
+					return []netlink.Message{{Header: netlink.Header{Type: netlink.Error}, Data: make([]byte, 4)}}, nil
+				}),
+			}
+			defer tcSocket.Close()
+
+			err := testcase.add(tcSocket)
+			if !errors.Is(err, ErrMessageTooLarge) || !errors.Is(err, ErrInvalidArg) {
+				t.Fatalf("expected ErrMessageTooLarge but got %v", err)
+			}
+			if sent != 0 {
+				t.Fatalf("expected no request but %d were sent", sent)
+			}
+			var sizeErr *MessageSizeError
+			if !errors.As(err, &sizeErr) {
+				t.Fatalf("expected a *MessageSizeError but got %v", err)
+			}
+			got := *sizeErr
+			if testcase.want.Attribute == 0 {
+				if got.Size <= got.Limit {
+					t.Fatalf("size %d does not exceed the limit %d", got.Size, got.Limit)
+				}
+				got.Size = 0
+			}
+			if got != testcase.want {
+				t.Fatalf("expected %#v but got %#v", testcase.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package tc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/florianl/go-tc/core"
+)
+
+// filterKinds contains the kinds of classifiers.
+var filterKinds = registeredKinds(ProbeFilter)
+
+// objectType returns whether obj is a "filter", "class" or "qdisc".
+func objectType(obj *Object) string {
+	if filterKinds[obj.Kind] {
+		return "filter"
+	}
+	if _, min := core.SplitHandle(obj.Handle); min != 0 {
+		return "class"
+	}
+	return "qdisc"
+}
+
+// familyNames contains the names of the address families from
+// include/linux/socket.h, that are used with traffic control.
+var familyNames = map[uint32]string{
+	0:  "unspec",
+	2:  "inet",
+	7:  "bridge",
+	10: "inet6",
+}
+
+// String returns the header of a message like
+// "family unspec ifindex 3 handle 1: parent root info 0x0". Messages of
+// shared blocks are rendered like "family unspec block 22 handle 0: info 0x0".
+func (m Msg) String() string {
+	family, ok := familyNames[m.Family]
+	if !ok {
+		family = fmt.Sprintf("%d", m.Family)
+	}
+	if block, ok := m.Block(); ok {
+		return fmt.Sprintf("family %s block %d handle %s info %#x",
+			family, block, core.FormatHandle(m.Handle), m.Info)
+	}
+	return fmt.Sprintf("family %s ifindex %d handle %s parent %s info %#x",
+		family, m.Ifindex, core.FormatHandle(m.Handle), core.FormatHandle(m.Parent), m.Info)
+}
+
+// formatDevice returns the network interface and the parent of m like
+// "dev ifindex 3 parent root" or the shared block like "block 22".
+func formatDevice(m Msg) string {
+	if block, ok := m.Block(); ok {
+		return fmt.Sprintf("block %d", block)
+	}
+	return fmt.Sprintf("dev ifindex %d parent %s", m.Ifindex, core.FormatHandle(m.Parent))
+}
+
+// String returns a compact summary of the object, that identifies it, like
+// "qdisc fq_codel 8001: dev ifindex 3 parent root". Unlike Sprint, no
+// options and statistics are included.
+func (o Object) String() string {
+	switch typ := objectType(&o); typ {
+	case "filter":
+		pref, _ := core.SplitHandle(o.Info)
+		return fmt.Sprintf("filter %s %#x %s pref %d", o.Kind, o.Handle, formatDevice(o.Msg), pref)
+	default:
+		return fmt.Sprintf("%s %s %s %s", typ, o.Kind, core.FormatHandle(o.Handle), formatDevice(o.Msg))
+	}
+}
+
+// Sprint returns a single line summary of obj comparable to the output of
+// `tc -s qdisc show`, `tc -s class show` or `tc -s filter show`. The salient
+// parameters of well known kinds are included. Other kinds are only
+// summarized by their handles and statistics.
+func Sprint(obj Object) string {
+	var b strings.Builder
+
+	typ := objectType(&obj)
+	switch typ {
+	case "filter":
+		pref, _ := core.SplitHandle(obj.Info)
+		if block, ok := obj.Block(); ok {
+			fmt.Fprintf(&b, "filter %s block %d pref %d handle %#x", obj.Kind, block, pref, obj.Handle)
+			break
+		}
+		fmt.Fprintf(&b, "filter %s ifindex %d parent %s pref %d handle %#x",
+			obj.Kind, obj.Ifindex, tcHandle(obj.Parent), pref, obj.Handle)
+	case "class":
+		fmt.Fprintf(&b, "class %s %s ifindex %d %s", obj.Kind, core.FormatHandle(obj.Handle),
+			obj.Ifindex, sprintParent(obj.Parent))
+		if obj.Info != 0 {
+			fmt.Fprintf(&b, " leaf %s", core.FormatHandle(obj.Info))
+		}
+	default:
+		fmt.Fprintf(&b, "qdisc %s %s ifindex %d %s", obj.Kind, core.FormatHandle(obj.Handle),
+			obj.Ifindex, sprintParent(obj.Parent))
+	}
+
+	if opts := sprintOptions(&obj.Attribute, typ == "class"); opts != "" {
+		b.WriteString(" ")
+		b.WriteString(opts)
+	}
+	if stats := sprintStats(&obj.Attribute); stats != "" {
+		b.WriteString(" ")
+		b.WriteString(stats)
+	}
+	return b.String()
+}
+
+// SprintTree returns the summaries of all nodes of root, one line per node and
+// indented by its depth. Orphans of root are listed at the end.
+func SprintTree(root *Node) string {
+	var lines []string
+	_ = root.Walk(func(depth int, n *Node) error {
+		lines = append(lines, strings.Repeat("  ", depth)+Sprint(n.Object))
+		return nil
+	})
+	for _, n := range root.Orphans {
+		lines = append(lines, "orphan "+Sprint(n.Object))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func sprintParent(parent uint32) string {
+	if parent == HandleRoot {
+		return "root"
+	}
+	return "parent " + tcHandle(parent)
+}
+
+// tcHandle returns handle like core.FormatHandle, but without the names of
+// the parents of ingress and clsact, that tc does not know.
+func tcHandle(handle uint32) string {
+	if maj, min := core.SplitHandle(handle); maj == 0xFFFF && min != 0 && handle != HandleRoot {
+		return fmt.Sprintf("%x:%x", maj, min)
+	}
+	return core.FormatHandle(handle)
+}
+
+// sprintTime implements iproute2/lib/utils.c:print_time() for a time in microseconds.
+func sprintTime(us uint32) string {
+	tmp := float64(us)
+	switch {
+	case tmp >= 1000000:
+		return fmt.Sprintf("%.3gs", tmp/1000000)
+	case tmp >= 1000:
+		return fmt.Sprintf("%.3gms", tmp/1000)
+	}
+	return fmt.Sprintf("%dus", us)
+}
+
+// sprintOptions returns the salient parameters of attr.
+func sprintOptions(attr *Attribute, class bool) string {
+	var opts []string
+	add := func(format string, a ...interface{}) {
+		opts = append(opts, fmt.Sprintf(format, a...))
+	}
+
+	switch {
+	case attr.FqCodel != nil:
+		fq := attr.FqCodel
+		if fq.Limit != nil {
+			add("limit %dp", *fq.Limit)
+		}
+		if fq.Flows != nil {
+			add("flows %d", *fq.Flows)
+		}
+		if fq.Quantum != nil {
+			add("quantum %d", *fq.Quantum)
+		}
+		if fq.Target != nil {
+			add("target %s", sprintTime(*fq.Target))
+		}
+		if fq.Interval != nil {
+			add("interval %s", sprintTime(*fq.Interval))
+		}
+		if fq.MemoryLimit != nil {
+			add("memory_limit %s", FormatSize(*fq.MemoryLimit))
+		}
+		if fq.ECN != nil && *fq.ECN != 0 {
+			add("ecn")
+		}
+	case attr.Codel != nil:
+		codel := attr.Codel
+		if codel.Limit != nil {
+			add("limit %dp", *codel.Limit)
+		}
+		if codel.Target != nil {
+			add("target %s", sprintTime(*codel.Target))
+		}
+		if codel.Interval != nil {
+			add("interval %s", sprintTime(*codel.Interval))
+		}
+		if codel.ECN != nil && *codel.ECN != 0 {
+			add("ecn")
+		}
+	case attr.Htb != nil && class:
+		if parms := attr.Htb.Parms; parms != nil {
+			rate := uint64(parms.Rate.Rate)
+			if attr.Htb.Rate64 != nil {
+				rate = *attr.Htb.Rate64
+			}
+			ceil := uint64(parms.Ceil.Rate)
+			if attr.Htb.Ceil64 != nil {
+				ceil = *attr.Htb.Ceil64
+			}
+			add("prio %d rate %s ceil %s", parms.Prio, FormatRate(rate), FormatRate(ceil))
+			add("burst %s cburst %s", FormatSize(core.XmitSize(rate, parms.Buffer)),
+				FormatSize(core.XmitSize(ceil, parms.Cbuffer)))
+		}
+	case attr.Htb != nil:
+		if init := attr.Htb.Init; init != nil {
+			add("r2q %d default %#x direct_packets_stat %d", init.Rate2Quantum, init.Defcls, init.DirectPkts)
+		}
+	case attr.Netem != nil:
+		qopt := attr.Netem.Qopt
+		add("limit %d", qopt.Limit)
+		if attr.Netem.Latency64 != nil {
+			add("delay %s", sprintTime(uint32(*attr.Netem.Latency64/1000)))
+		} else if qopt.Latency != 0 {
+			add("delay %s", sprintTime(core.Tick2Time(qopt.Latency)))
+		}
+		if attr.Netem.Jitter64 != nil {
+			add("%s", sprintTime(uint32(*attr.Netem.Jitter64/1000)))
+		} else if qopt.Jitter != 0 {
+			add("%s", sprintTime(core.Tick2Time(qopt.Jitter)))
+		}
+		if qopt.Loss != 0 {
+			add("loss %.6g%%", PercentageOf(qopt.Loss))
+		}
+		if qopt.Duplicate != 0 {
+			add("duplicate %.6g%%", PercentageOf(qopt.Duplicate))
+		}
+	case attr.Tbf != nil:
+		if parms := attr.Tbf.Parms; parms != nil {
+			rate := uint64(parms.Rate.Rate)
+			add("rate %s burst %s limit %s", FormatRate(rate),
+				FormatSize(core.XmitSize(rate, parms.Buffer)), FormatSize(parms.Limit))
+		}
+	case attr.Sfq != nil:
+		sfq := attr.Sfq
+		add("limit %dp quantum %s depth %d divisor %d", sfq.V0.Limit, FormatSize(sfq.V0.Quantum),
+			sfq.Depth, sfq.V0.Divisor)
+		if sfq.V0.PerturbPeriod != 0 {
+			add("perturb %dsec", sfq.V0.PerturbPeriod)
+		}
+	case attr.Prio != nil:
+		add("bands %d priomap %s", attr.Prio.Bands, attr.Prio.PrioMap)
+	case attr.Pfifo != nil:
+		add("limit %dp", attr.Pfifo.Limit)
+	case attr.Bfifo != nil:
+		add("limit %s", FormatSize(attr.Bfifo.Limit))
+	case attr.Red != nil:
+		if parms := attr.Red.Parms; parms != nil {
+			add("limit %s min %s max %s", FormatSize(parms.Limit), FormatSize(parms.QthMin),
+				FormatSize(parms.QthMax))
+		}
+	}
+	return strings.Join(opts, " ")
+}
+
+// sprintStats returns the statistics of attr. Stats2 is preferred over Stats.
+func sprintStats(attr *Attribute) string {
+	switch {
+	case attr.Stats2 != nil:
+		s := attr.Stats2
+		return fmt.Sprintf("sent %d bytes %d pkt (dropped %d, overlimits %d requeues %d) backlog %s %dp",
+			s.Bytes(), s.Packets64(), s.Drops(), s.Overlimits(), s.Requeues(), FormatSize(s.Backlog()), s.Qlen())
+	case attr.Stats != nil:
+		s := attr.Stats
+		return fmt.Sprintf("sent %d bytes %d pkt (dropped %d, overlimits %d) backlog %s %dp",
+			s.Bytes, s.Packets, s.Drops, s.Overlimits, FormatSize(s.Backlog), s.Qlen)
+	}
+	return ""
+}
@@ -0,0 +1,188 @@
+package tc
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+)
+
+func TestSprint(t *testing.T) {
+	restore, err := core.SetClock(0x3e8, 0x40, 0xf4240)
+	if err != nil {
+		t.Fatalf("could not set clock: %v", err)
+	}
+	defer restore()
+
+	htbClass, err := htbClassOptions(1250000, 12500000)
+	if err != nil {
+		t.Fatalf("could not create htb class options: %v", err)
+	}
+	netemQopt, err := NetemQoptFromDurations(100000000, 10000000, 1000)
+	if err != nil {
+		t.Fatalf("could not create netem options: %v", err)
+	}
+	netemQopt.Loss = 429496730
+
+	tests := map[string]struct {
+		obj  Object
+		want string
+	}{
+		"fq_codel": {
+			obj: Object{Msg{Ifindex: 3, Handle: 0x80010000, Parent: HandleRoot},
+				Attribute{Kind: "fq_codel", FqCodel: DefaultFqCodel(),
+					Stats2: &Stats2{Basic: &GenBasic{Bytes: 6529, Packets: 45},
+						Queue: &GenQueue{Requeues: 1, Backlog: 1514, QueueLen: 1}}}},
+			want: "qdisc fq_codel 8001: ifindex 3 root limit 10240p flows 1024 quantum 1514 target 5ms " +
+				"interval 100ms memory_limit 32Mb ecn sent 6529 bytes 45 pkt (dropped 0, overlimits 0 requeues 1) backlog 1514b 1p",
+		},
+		"64 bit packet counter": {
+			obj: Object{Msg{Ifindex: 3, Handle: 0x10000, Parent: HandleRoot},
+				Attribute{Kind: "clsact", Stats2: &Stats2{Basic: &GenBasic{Bytes: 1 << 42, Packets: 0x2A},
+					Pkt64: uint64Ptr(0x10000002A)}}},
+			want: "qdisc clsact 1: ifindex 3 root sent 4398046511104 bytes 4294967338 pkt " +
+				"(dropped 0, overlimits 0 requeues 0) backlog 0b 0p",
+		},
+		"codel": {
+			obj: Object{Msg{Ifindex: 3, Handle: 0x10000, Parent: 0x10010},
+				Attribute{Kind: "codel", Codel: DefaultCodel(),
+					Stats: &Stats{Bytes: 100, Packets: 1, Drops: 2}}},
+			want: "qdisc codel 1: ifindex 3 parent 1:10 limit 1000p target 5ms interval 100ms " +
+				"sent 100 bytes 1 pkt (dropped 2, overlimits 0) backlog 0b 0p",
+		},
+		"htb qdisc": {
+			obj: Object{Msg{Ifindex: 2, Handle: 0x10000, Parent: HandleRoot},
+				Attribute{Kind: "htb", Htb: &Htb{Init: &HtbGlob{Version: 3, Rate2Quantum: 10, Defcls: 0x30}}}},
+			want: "qdisc htb 1: ifindex 2 root r2q 10 default 0x30 direct_packets_stat 0",
+		},
+		"htb class": {
+			obj: Object{Msg{Ifindex: 2, Handle: 0x10010, Parent: 0x10001, Info: 0x100000},
+				Attribute{Kind: "htb", Htb: htbClass}},
+			want: "class htb 1:10 ifindex 2 parent 1:1 leaf 10: prio 0 rate 10Mbit ceil 100Mbit burst 1600b cburst 1600b",
+		},
+		"htb class 64bit": {
+			obj: Object{Msg{Ifindex: 2, Handle: 0x10010, Parent: HandleRoot},
+				Attribute{Kind: "htb", Htb: &Htb{Parms: &HtbOpt{Rate: RateSpec{Rate: 0xFFFFFFFF},
+					Ceil: RateSpec{Rate: 0xFFFFFFFF}}, Rate64: uint64Ptr(5000000000), Ceil64: uint64Ptr(5000000000)}}},
+			want: "class htb 1:10 ifindex 2 root prio 0 rate 40Gbit ceil 40Gbit burst 0b cburst 0b",
+		},
+		"netem": {
+			obj: Object{Msg{Ifindex: 4, Handle: 0x10000, Parent: HandleRoot},
+				Attribute{Kind: "netem", Netem: &Netem{Qopt: netemQopt}}},
+			want: "qdisc netem 1: ifindex 4 root limit 1000 delay 100ms 10ms loss 10%",
+		},
+		"tbf": {
+			obj: Object{Msg{Ifindex: 4, Handle: 0x10000, Parent: HandleRoot},
+				Attribute{Kind: "tbf", Tbf: &Tbf{Parms: &TbfQopt{Rate: RateSpec{Rate: 125000},
+					Buffer: core.XmitTime(125000, 32768), Limit: 30000}}}},
+			want: "qdisc tbf 1: ifindex 4 root rate 1Mbit burst 32Kb limit 30000b",
+		},
+		"sfq": {
+			obj: Object{Msg{Ifindex: 4, Handle: 0x200000, Parent: 0x10020},
+				Attribute{Kind: "sfq", Sfq: DefaultSfq()}},
+			want: "qdisc sfq 20: ifindex 4 parent 1:20 limit 127p quantum 1514b depth 127 divisor 1024",
+		},
+		"pfifo_fast": {
+			obj: Object{Msg{Ifindex: 1, Handle: 0, Parent: HandleRoot},
+				Attribute{Kind: "pfifo_fast", Prio: &Prio{Bands: 3, PrioMap: DefaultPriomap()}}},
+			want: "qdisc pfifo_fast none ifindex 1 root bands 3 priomap 1 2 2 2 1 2 0 0 1 1 1 1 1 1 1 1",
+		},
+		"clsact": {
+			obj:  Object{Msg{Ifindex: 2, Handle: 0xFFFF0000, Parent: HandleIngress}, Attribute{Kind: "clsact"}},
+			want: "qdisc clsact ffff: ifindex 2 parent ffff:fff1",
+		},
+		"generic": {
+			obj:  Object{Msg{Ifindex: 2, Handle: 0x50000, Parent: HandleRoot}, Attribute{Kind: "cake"}},
+			want: "qdisc cake 5: ifindex 2 root",
+		},
+		"filter": {
+			obj: Object{Msg{Ifindex: 2, Handle: 0x800, Parent: HandleClsactIngress, Info: 0xC0000300},
+				Attribute{Kind: "u32", U32: &U32{}}},
+			want: "filter u32 ifindex 2 parent ffff:fff2 pref 49152 handle 0x800",
+		},
+		"block filter": {
+			obj:  Object{Msg{Ifindex: MagicBlock, Handle: 0x1, Parent: 22, Info: 0x20008}, Attribute{Kind: "basic"}},
+			want: "filter basic block 22 pref 2 handle 0x1",
+		},
+	}
+
+	for name, testcase := range tests {
+		testcase := testcase
+		t.Run(name, func(t *testing.T) {
+			if got := Sprint(testcase.obj); got != testcase.want {
+				t.Fatalf("unexpected output\nwant: %s\ngot:  %s", testcase.want, got)
+			}
+		})
+	}
+}
+
+func TestSprintTree(t *testing.T) {
+	root, err := BuildTree([]Object{
+		{Msg{Ifindex: 2, Handle: 0x10000, Parent: HandleRoot}, Attribute{Kind: "htb"}},
+		{Msg{Ifindex: 2, Handle: 0x100000, Parent: 0x10010}, Attribute{Kind: "fq_codel"}},
+		{Msg{Ifindex: 2, Handle: 0x200000, Parent: 0x10020}, Attribute{Kind: "fq_codel"}},
+	}, []Object{
+		{Msg{Ifindex: 2, Handle: 0x10010, Parent: HandleRoot}, Attribute{Kind: "htb"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "qdisc htb 1: ifindex 2 root\n" +
+		"  class htb 1:10 ifindex 2 root\n" +
+		"    qdisc fq_codel 10: ifindex 2 parent 1:10\n" +
+		"orphan qdisc fq_codel 20: ifindex 2 parent 1:20"
+	if got := SprintTree(root); got != want {
+		t.Fatalf("unexpected output\nwant:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := map[string]struct {
+		value fmt.Stringer
+		want  string
+	}{
+		"msg": {value: Msg{Family: unix.AF_UNSPEC, Ifindex: 3, Handle: 0x10000, Parent: HandleRoot},
+			want: "family unspec ifindex 3 handle 1: parent root info 0x0"},
+		"msg clsact": {value: Msg{Ifindex: 3, Handle: 0xFFFF0000, Parent: HandleIngress, Info: 1},
+			want: "family unspec ifindex 3 handle ffff: parent ingress info 0x1"},
+		"msg unknown family": {value: Msg{Family: 42, Ifindex: 1, Handle: 0x10010, Parent: 0x10000},
+			want: "family 42 ifindex 1 handle 1:10 parent 1: info 0x0"},
+		"qdisc": {value: Object{Msg{Ifindex: 3, Handle: 0x80010000, Parent: HandleRoot},
+			Attribute{Kind: "fq_codel"}},
+			want: "qdisc fq_codel 8001: dev ifindex 3 parent root"},
+		"class": {value: Object{Msg{Ifindex: 3, Handle: 0x10010, Parent: 0x10001},
+			Attribute{Kind: "htb"}},
+			want: "class htb 1:10 dev ifindex 3 parent 1:1"},
+		"ingress filter": {value: Object{Msg{Ifindex: 3, Handle: 0x1, Parent: HandleClsactIngress, Info: 0x10300},
+			Attribute{Kind: "bpf"}},
+			want: "filter bpf 0x1 dev ifindex 3 parent clsact-ingress pref 1"},
+		"egress filter": {value: Object{Msg{Ifindex: 3, Handle: 0x800, Parent: HandleClsactEgress, Info: 0x310008},
+			Attribute{Kind: "u32"}},
+			want: "filter u32 0x800 dev ifindex 3 parent clsact-egress pref 49"},
+		"msg block": {value: Msg{Ifindex: MagicBlock, Parent: 22, Info: 0x20008},
+			want: "family unspec block 22 handle none info 0x20008"},
+		"block filter": {value: Object{Msg{Ifindex: MagicBlock, Handle: 0x1, Parent: 22, Info: 0x20008},
+			Attribute{Kind: "basic"}},
+			want: "filter basic 0x1 block 22 pref 2"},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := testcase.value.String(); got != testcase.want {
+				t.Fatalf("expected %q but got %q", testcase.want, got)
+			}
+			if got := fmt.Sprintf("%v", testcase.value); got != testcase.want {
+				t.Fatalf("expected %q with %%v but got %q", testcase.want, got)
+			}
+		})
+	}
+
+	t.Run("GoString", func(t *testing.T) {
+		msg := Msg{Ifindex: 3, Handle: 0x10000}
+		want := "tc.Msg{Family:0x0, Ifindex:0x3, Handle:0x10000, Parent:0x0, Info:0x0}"
+		if got := fmt.Sprintf("%#v", msg); got != want {
+			t.Fatalf("expected %q but got %q", want, got)
+		}
+	})
+}
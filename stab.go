@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaStabUnspec = iota
@@ -14,25 +10,25 @@ const (
 
 // SizeSpec implements tc_sizespec
 type SizeSpec struct {
-	CellLog   uint8
-	SizeLog   uint8
-	CellAlign int16
-	Overhead  int32
-	LinkLayer uint32
-	MPU       uint32
-	MTU       uint32
-	TSize     uint32
+	CellLog   uint8  `json:"cell_log,omitempty"`
+	SizeLog   uint8  `json:"size_log,omitempty"`
+	CellAlign int16  `json:"cell_align,omitempty"`
+	Overhead  int32  `json:"overhead,omitempty"`
+	LinkLayer uint32 `json:"link_layer,omitempty"`
+	MPU       uint32 `json:"mpu,omitempty"`
+	MTU       uint32 `json:"mtu,omitempty"`
+	TSize     uint32 `json:"t_size,omitempty"`
 }
 
 // Stab contains attributes of a stab
 // http://man7.org/linux/man-pages/man8/tc-stab.8.html
 type Stab struct {
-	Base *SizeSpec
-	Data *[]byte
+	Base *SizeSpec `json:"base,omitempty"`
+	Data *[]byte   `json:"data,omitempty"`
 }
 
 func unmarshalStab(data []byte, stab *Stab) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -48,7 +44,7 @@ func unmarshalStab(data []byte, stab *Stab) error {
 			tmp := ad.Bytes()
 			stab.Data = &tmp
 		default:
-			return fmt.Errorf("unmarshalStab()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("stab", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -1,10 +1,6 @@
 package tc
 
-import (
-	"fmt"
-
-	"github.com/mdlayher/netlink"
-)
+import "fmt"
 
 const (
 	tcaStatsUnspec = iota
@@ -20,43 +16,141 @@ const (
 
 // GenStats from include/uapi/linux/gen_stats.h
 type GenStats struct {
-	Basic     *GenBasic
-	RateEst   *GenRateEst
-	Queue     *GenQueue
-	RateEst64 *GenRateEst64
-	BasicHw   *GenBasic
+	Basic     *GenBasic     `json:"basic,omitempty"`
+	RateEst   *GenRateEst   `json:"rate_est,omitempty"`
+	Queue     *GenQueue     `json:"queue,omitempty"`
+	RateEst64 *GenRateEst64 `json:"rate_est64,omitempty"`
+	// BasicHw holds the traffic, that was processed in hardware. It is
+	// absent for objects, that are not offloaded.
+	BasicHw *GenBasic `json:"basic_hw,omitempty"`
+	// App contains the statistics specific to the kind in the same format as XStats.
+	App []byte `json:"app,omitempty"`
+	// Pkt64 holds the number of packets, if it exceeds the 32 bit of Basic.Packets.
+	Pkt64 *uint64 `json:"pkt64,omitempty"`
 }
 
+// Stats2 contains the nested statistics of TCA_STATS2. It uses the same
+// encoding as the statistics of actions.
+type Stats2 = GenStats
+
 // GenBasic from include/uapi/linux/gen_stats.h
 type GenBasic struct {
-	Bytes   uint64
-	Packets uint32
+	Bytes   uint64 `json:"bytes,omitempty"`
+	Packets uint32 `json:"packets,omitempty"`
 }
 
 // GenRateEst from include/uapi/linux/gen_stats.h
 type GenRateEst struct {
-	BytePerSecond   uint32
-	PacketPerSecond uint32
+	BytePerSecond   uint32 `json:"byte_per_second,omitempty"`
+	PacketPerSecond uint32 `json:"packet_per_second,omitempty"`
 }
 
 // GenRateEst64 from include/uapi/linux/gen_stats.h
 type GenRateEst64 struct {
-	BytePerSecond   uint64
-	PacketPerSecond uint64
+	BytePerSecond   uint64 `json:"byte_per_second,omitempty"`
+	PacketPerSecond uint64 `json:"packet_per_second,omitempty"`
 }
 
 // GenQueue from include/uapi/linux/gen_stats.h
 type GenQueue struct {
-	QueueLen   uint32
-	Backlog    uint32
-	Drops      uint32
-	Requeues   uint32
-	Overlimits uint32
+	QueueLen   uint32 `json:"queue_len,omitempty"`
+	Backlog    uint32 `json:"backlog,omitempty"`
+	Drops      uint32 `json:"drops,omitempty"`
+	Requeues   uint32 `json:"requeues,omitempty"`
+	Overlimits uint32 `json:"overlimits,omitempty"`
+}
+
+// Bytes returns the number of sent bytes.
+func (s *GenStats) Bytes() uint64 {
+	if s == nil || s.Basic == nil {
+		return 0
+	}
+	return s.Basic.Bytes
+}
+
+// Packets64 returns the number of sent packets. Pkt64 is preferred over
+// Basic.Packets, which wraps after 32 bit.
+func (s *GenStats) Packets64() uint64 {
+	if s == nil {
+		return 0
+	}
+	if s.Pkt64 != nil {
+		return *s.Pkt64
+	}
+	if s.Basic == nil {
+		return 0
+	}
+	return uint64(s.Basic.Packets)
 }
 
-// unmarshalGenStats parses the Pie-encoded data and stores the result in the value pointed to by info.
+// Software returns the traffic, that was processed in software. Basic
+// contains the traffic of software and hardware together.
+func (s *GenStats) Software() GenBasic {
+	if s == nil || s.Basic == nil {
+		return GenBasic{}
+	}
+	sw := *s.Basic
+	if s.BasicHw != nil {
+		sw.Bytes -= s.BasicHw.Bytes
+		sw.Packets -= s.BasicHw.Packets
+	}
+	return sw
+}
+
+// Bps returns the estimated rate in bytes per second. RateEst64 is preferred
+// over RateEst, as the kernel falls back to it, if the rate exceeds 32 bit.
+func (s *GenStats) Bps() uint64 {
+	switch {
+	case s == nil:
+		return 0
+	case s.RateEst64 != nil:
+		return s.RateEst64.BytePerSecond
+	case s.RateEst != nil:
+		return uint64(s.RateEst.BytePerSecond)
+	}
+	return 0
+}
+
+// Pps returns the estimated rate in packets per second. RateEst64 is
+// preferred over RateEst.
+func (s *GenStats) Pps() uint64 {
+	switch {
+	case s == nil:
+		return 0
+	case s.RateEst64 != nil:
+		return s.RateEst64.PacketPerSecond
+	case s.RateEst != nil:
+		return uint64(s.RateEst.PacketPerSecond)
+	}
+	return 0
+}
+
+// queue returns the queue statistics or zero values, if they are not present.
+func (s *GenStats) queue() GenQueue {
+	if s == nil || s.Queue == nil {
+		return GenQueue{}
+	}
+	return *s.Queue
+}
+
+// Qlen returns the length of the queue.
+func (s *GenStats) Qlen() uint32 { return s.queue().QueueLen }
+
+// Backlog returns the backlog of the queue in bytes.
+func (s *GenStats) Backlog() uint32 { return s.queue().Backlog }
+
+// Drops returns the number of dropped packets.
+func (s *GenStats) Drops() uint32 { return s.queue().Drops }
+
+// Requeues returns the number of requeued packets.
+func (s *GenStats) Requeues() uint32 { return s.queue().Requeues }
+
+// Overlimits returns the number of overlimit events.
+func (s *GenStats) Overlimits() uint32 { return s.queue().Overlimits }
+
+// unmarshalGenStats parses the nested gnet_stats attributes of TCA_STATS2 and stores the result in the value pointed to by info.
 func unmarshalGenStats(data []byte, info *GenStats) error {
-	ad, err := netlink.NewAttributeDecoder(data)
+	ad, err := newDecoder(data)
 	if err != nil {
 		return err
 	}
@@ -88,10 +182,14 @@ func unmarshalGenStats(data []byte, info *GenStats) error {
 			err = unmarshalStruct(ad.Bytes(), stat)
 			multiError = concatError(multiError, err)
 			info.BasicHw = stat
+		case tcaStatsApp:
+			info.App = ad.Bytes()
+		case tcaStatsPkt64:
+			info.Pkt64 = uint64Ptr(ad.Uint64())
 		case tcaStatsPad:
 			// padding does not contain data, we just skip it
 		default:
-			return fmt.Errorf("unmarshalGenStats()\t%d\n\t%v", ad.Type(), ad.Bytes())
+			multiError = concatError(multiError, unknownAttribute("stats", ad.Type(), ad.Bytes()))
 		}
 	}
 	return concatError(multiError, ad.Err())
@@ -132,6 +230,12 @@ func marshalGenStats(info *GenStats) ([]byte, error) {
 		multiError = concatError(multiError, err)
 		options = append(options, tcOption{Interpretation: vtBytes, Type: tcaStatsBasicHw, Data: data})
 	}
+	if info.App != nil {
+		options = append(options, tcOption{Interpretation: vtBytes, Type: tcaStatsApp, Data: info.App})
+	}
+	if info.Pkt64 != nil {
+		options = append(options, tcOption{Interpretation: vtUint64, Type: tcaStatsPkt64, Data: uint64Value(info.Pkt64)})
+	}
 
 	if multiError != nil {
 		return []byte{}, multiError
@@ -139,3 +243,119 @@ func marshalGenStats(info *GenStats) ([]byte, error) {
 
 	return marshalAttributes(options)
 }
+
+// StatsSource names the statistics, a value of UnifiedStats was taken from.
+type StatsSource int
+
+// Sources of UnifiedStats.
+const (
+	// StatsSourceNone marks values, that the kernel did not report at all.
+	StatsSourceNone StatsSource = iota
+	// StatsSourceStats2 marks values of the nested TCA_STATS2.
+	StatsSourceStats2
+	// StatsSourceStats marks values of the legacy TCA_STATS.
+	StatsSourceStats
+)
+
+func (s StatsSource) String() string {
+	switch s {
+	case StatsSourceNone:
+		return "none"
+	case StatsSourceStats2:
+		return "stats2"
+	case StatsSourceStats:
+		return "stats"
+	}
+	return fmt.Sprintf("StatsSource(%d)", int(s))
+}
+
+// UnifiedStats combines Stats and Stats2 of an object. Each group of values
+// is taken from Stats2, if it contains them, and from the legacy Stats
+// otherwise. The sources tell, where each group came from.
+type UnifiedStats struct {
+	Bytes   uint64
+	Packets uint64
+	// BasicSource is the source of Bytes and Packets.
+	BasicSource StatsSource
+
+	Qlen       uint32
+	Backlog    uint32
+	Drops      uint32
+	Overlimits uint32
+	// Requeues is only reported by Stats2 and is 0, if QueueSource is
+	// StatsSourceStats.
+	Requeues uint32
+	// QueueSource is the source of Qlen, Backlog, Drops, Overlimits and
+	// Requeues.
+	QueueSource StatsSource
+
+	// Bps and Pps are the estimated rates. Stats2 reports them only, if a
+	// rate estimator is attached, while Stats always contains them.
+	Bps uint64
+	Pps uint64
+	// RateSource is the source of Bps and Pps.
+	RateSource StatsSource
+}
+
+// Statistics returns the combined Stats and Stats2 of a. It returns nil, if
+// the kernel reported neither of them. With Config.LazyStats, DecodeStats
+// has to be called first.
+func (a *Attribute) Statistics() *UnifiedStats {
+	if a.Stats == nil && a.Stats2 == nil {
+		return nil
+	}
+	us := &UnifiedStats{}
+	s2, s := a.Stats2, a.Stats
+
+	switch {
+	case s2 != nil && (s2.Basic != nil || s2.Pkt64 != nil):
+		us.Bytes = s2.Bytes()
+		us.Packets = s2.Packets64()
+		us.BasicSource = StatsSourceStats2
+	case s != nil:
+		us.Bytes = s.Bytes
+		us.Packets = uint64(s.Packets)
+		us.BasicSource = StatsSourceStats
+	}
+
+	switch {
+	case s2 != nil && s2.Queue != nil:
+		us.Qlen = s2.Queue.QueueLen
+		us.Backlog = s2.Queue.Backlog
+		us.Drops = s2.Queue.Drops
+		us.Overlimits = s2.Queue.Overlimits
+		us.Requeues = s2.Queue.Requeues
+		us.QueueSource = StatsSourceStats2
+	case s != nil:
+		us.Qlen = s.Qlen
+		us.Backlog = s.Backlog
+		us.Drops = s.Drops
+		us.Overlimits = s.Overlimits
+		us.QueueSource = StatsSourceStats
+	}
+
+	switch {
+	case s2 != nil && (s2.RateEst != nil || s2.RateEst64 != nil):
+		us.Bps = s2.Bps()
+		us.Pps = s2.Pps()
+		us.RateSource = StatsSourceStats2
+	case s != nil:
+		us.Bps = uint64(s.Bps)
+		us.Pps = uint64(s.Pps)
+		us.RateSource = StatsSourceStats
+	}
+	return us
+}
+
+// Backlog returns the number of packets and bytes in the queue of a, like
+// the backlog of `tc -s qdisc show`. The queue statistics of Stats2 are
+// preferred over the legacy Stats. ok is false, if the kernel reported
+// neither of them. With Config.LazyStats, DecodeStats has to be called
+// first.
+func (a *Attribute) Backlog() (packets, bytes uint32, ok bool) {
+	us := a.Statistics()
+	if us == nil || us.QueueSource == StatsSourceNone {
+		return 0, 0, false
+	}
+	return us.Qlen, us.Backlog, true
+}
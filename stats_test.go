@@ -4,7 +4,9 @@ import (
 	"errors"
 	"testing"
 
+	"github.com/florianl/go-tc/internal/unix"
 	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/netlink"
 )
 
 func TestGenStats(t *testing.T) {
@@ -14,6 +16,9 @@ func TestGenStats(t *testing.T) {
 		err2 error
 	}{
 		"simple": {val: GenStats{Basic: &GenBasic{Bytes: 123}}},
+		"stats2": {val: Stats2{Basic: &GenBasic{Bytes: 123, Packets: 1}, Queue: &GenQueue{Drops: 2},
+			BasicHw: &GenBasic{Bytes: 100, Packets: 1},
+			RateEst: &GenRateEst{BytePerSecond: 42}, App: []byte{0x1, 0x2, 0x3, 0x4}, Pkt64: uint64Ptr(1 << 40)}},
 	}
 
 	for name, testcase := range tests {
@@ -33,6 +38,129 @@ func TestGenStats(t *testing.T) {
 			}
 		})
 	}
+	t.Run("order", func(t *testing.T) {
+		stats := Stats2{Basic: &GenBasic{Bytes: 1500, Packets: 1}, Pkt64: uint64Ptr(1 << 33),
+			Queue: &GenQueue{QueueLen: 3, Backlog: 4, Drops: 5, Requeues: 6, Overlimits: 7}}
+		data, err := marshalGenStats(&stats)
+		if err != nil {
+			t.Fatalf("could not marshal stats: %v", err)
+		}
+		// move the queue statistics in front of all other attributes
+		var queue, others []netlink.Attribute
+		ad, err := netlink.NewAttributeDecoder(data)
+		if err != nil {
+			t.Fatalf("could not decode attributes: %v", err)
+		}
+		for ad.Next() {
+			attr := netlink.Attribute{Type: ad.Type(), Data: ad.Bytes()}
+			if ad.Type() == tcaStatsQueue {
+				queue = append(queue, attr)
+			} else {
+				others = append(others, attr)
+			}
+		}
+		reordered, err := netlink.MarshalAttributes(append(queue, others...))
+		if err != nil {
+			t.Fatalf("could not encode attributes: %v", err)
+		}
+		val := Stats2{}
+		if err := unmarshalGenStats(reordered, &val); err != nil {
+			t.Fatalf("could not unmarshal stats: %v", err)
+		}
+		if diff := cmp.Diff(stats, val); diff != "" {
+			t.Fatalf("Stats2 missmatch (want +got):\n%s", diff)
+		}
+		got := []uint64{val.Bytes(), val.Packets64(), uint64(val.Qlen()), uint64(val.Backlog()),
+			uint64(val.Drops()), uint64(val.Requeues()), uint64(val.Overlimits())}
+		want := []uint64{1500, 1 << 33, 3, 4, 5, 6, 7}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Fatalf("accessors missmatch (want +got):\n%s", diff)
+		}
+	})
+	t.Run("rate estimator", func(t *testing.T) {
+		tests := map[string]struct {
+			val      Stats2
+			bps, pps uint64
+		}{
+			"none":   {},
+			"32 bit": {val: Stats2{RateEst: &GenRateEst{BytePerSecond: 1250, PacketPerSecond: 2}}, bps: 1250, pps: 2},
+			"64 bit": {val: Stats2{RateEst64: &GenRateEst64{BytePerSecond: 12500000000, PacketPerSecond: 8000000}},
+				bps: 12500000000, pps: 8000000},
+			"both": {val: Stats2{RateEst: &GenRateEst{BytePerSecond: 0xFFFFFFFF, PacketPerSecond: 3},
+				RateEst64: &GenRateEst64{BytePerSecond: 12500000000, PacketPerSecond: 8000000}},
+				bps: 12500000000, pps: 8000000},
+		}
+		for name, testcase := range tests {
+			t.Run(name, func(t *testing.T) {
+				data, err := marshalGenStats(&testcase.val)
+				if err != nil {
+					t.Fatalf("could not marshal stats: %v", err)
+				}
+				val := Stats2{}
+				if err := unmarshalGenStats(data, &val); err != nil {
+					t.Fatalf("could not unmarshal stats: %v", err)
+				}
+				if val.Bps() != testcase.bps || val.Pps() != testcase.pps {
+					t.Fatalf("expected %d bps and %d pps but got %d bps and %d pps",
+						testcase.bps, testcase.pps, val.Bps(), val.Pps())
+				}
+			})
+		}
+	})
+	t.Run("packet counter", func(t *testing.T) {
+		tests := map[string]struct {
+			val  Stats2
+			want uint64
+		}{
+			"32 bit": {val: Stats2{Basic: &GenBasic{Packets: 42}}, want: 42},
+			"wrapped": {val: Stats2{Basic: &GenBasic{Packets: 0x2A}, Pkt64: uint64Ptr(0x10000002A)},
+				want: 0x10000002A},
+			"only 64 bit": {val: Stats2{Pkt64: uint64Ptr(1337)}, want: 1337},
+		}
+		for name, testcase := range tests {
+			t.Run(name, func(t *testing.T) {
+				data, err := marshalGenStats(&testcase.val)
+				if err != nil {
+					t.Fatalf("could not marshal stats: %v", err)
+				}
+				val := Stats2{}
+				if err := unmarshalGenStats(data, &val); err != nil {
+					t.Fatalf("could not unmarshal stats: %v", err)
+				}
+				if got := val.Packets64(); got != testcase.want {
+					t.Fatalf("expected %d packets but got %d", testcase.want, got)
+				}
+			})
+		}
+	})
+	t.Run("hardware", func(t *testing.T) {
+		tests := map[string]struct {
+			val  Stats2
+			want GenBasic
+		}{
+			"none":     {},
+			"software": {val: Stats2{Basic: &GenBasic{Bytes: 100, Packets: 2}}, want: GenBasic{Bytes: 100, Packets: 2}},
+			"offloaded": {val: Stats2{Basic: &GenBasic{Bytes: 100, Packets: 2},
+				BasicHw: &GenBasic{Bytes: 60, Packets: 1}}, want: GenBasic{Bytes: 40, Packets: 1}},
+		}
+		for name, testcase := range tests {
+			t.Run(name, func(t *testing.T) {
+				if diff := cmp.Diff(testcase.want, testcase.val.Software()); diff != "" {
+					t.Fatalf("software stats missmatch (want +got):\n%s", diff)
+				}
+			})
+		}
+	})
+	t.Run("accessors without data", func(t *testing.T) {
+		var stats *Stats2
+		if stats.Bytes() != 0 || stats.Packets64() != 0 || stats.Drops() != 0 {
+			t.Fatalf("expected zero values")
+		}
+		stats = &Stats2{Basic: &GenBasic{Packets: 42}}
+		if stats.Packets64() != 42 || stats.Overlimits() != 0 {
+			t.Fatalf("unexpected values: %d packets, %d overlimits", stats.Packets64(), stats.Overlimits())
+		}
+	})
 	t.Run("nil", func(t *testing.T) {
 		_, err := marshalGenStats(nil)
 		if !errors.Is(err, ErrNoArg) {
@@ -40,3 +168,128 @@ func TestGenStats(t *testing.T) {
 		}
 	})
 }
+
+func TestStatistics(t *testing.T) {
+	legacy := &Stats{Bytes: 1500, Packets: 1, Drops: 2, Overlimits: 3, Bps: 4, Pps: 5, Qlen: 6, Backlog: 7}
+	queue := &GenQueue{QueueLen: 8, Backlog: 9, Drops: 10, Requeues: 11, Overlimits: 12}
+
+	tests := map[string]struct {
+		stats  *Stats
+		stats2 *Stats2
+		want   *UnifiedStats
+	}{
+		"none": {},
+		"stats only": {stats: legacy,
+			want: &UnifiedStats{Bytes: 1500, Packets: 1, BasicSource: StatsSourceStats,
+				Qlen: 6, Backlog: 7, Drops: 2, Overlimits: 3, QueueSource: StatsSourceStats,
+				Bps: 4, Pps: 5, RateSource: StatsSourceStats}},
+		"stats2 only": {stats2: &Stats2{Basic: &GenBasic{Bytes: 3000, Packets: 2}, Pkt64: uint64Ptr(1 << 33), Queue: queue},
+			want: &UnifiedStats{Bytes: 3000, Packets: 1 << 33, BasicSource: StatsSourceStats2,
+				Qlen: 8, Backlog: 9, Drops: 10, Overlimits: 12, Requeues: 11, QueueSource: StatsSourceStats2}},
+		"stats2 with rate": {stats2: &Stats2{Basic: &GenBasic{Bytes: 3000, Packets: 2},
+			RateEst: &GenRateEst{BytePerSecond: 13, PacketPerSecond: 14}},
+			want: &UnifiedStats{Bytes: 3000, Packets: 2, BasicSource: StatsSourceStats2,
+				Bps: 13, Pps: 14, RateSource: StatsSourceStats2}},
+		"both": {stats: legacy, stats2: &Stats2{Basic: &GenBasic{Bytes: 3000, Packets: 2}, Queue: queue,
+			RateEst64: &GenRateEst64{BytePerSecond: 1 << 40, PacketPerSecond: 1 << 35}},
+			want: &UnifiedStats{Bytes: 3000, Packets: 2, BasicSource: StatsSourceStats2,
+				Qlen: 8, Backlog: 9, Drops: 10, Overlimits: 12, Requeues: 11, QueueSource: StatsSourceStats2,
+				Bps: 1 << 40, Pps: 1 << 35, RateSource: StatsSourceStats2}},
+		"both without rate estimator": {stats: legacy, stats2: &Stats2{Basic: &GenBasic{Bytes: 3000, Packets: 2}, Queue: queue},
+			want: &UnifiedStats{Bytes: 3000, Packets: 2, BasicSource: StatsSourceStats2,
+				Qlen: 8, Backlog: 9, Drops: 10, Overlimits: 12, Requeues: 11, QueueSource: StatsSourceStats2,
+				Bps: 4, Pps: 5, RateSource: StatsSourceStats}},
+		// Actions do not report a queue in Stats2.
+		"both without queue": {stats: legacy, stats2: &Stats2{Basic: &GenBasic{Bytes: 3000, Packets: 2}},
+			want: &UnifiedStats{Bytes: 3000, Packets: 2, BasicSource: StatsSourceStats2,
+				Qlen: 6, Backlog: 7, Drops: 2, Overlimits: 3, QueueSource: StatsSourceStats,
+				Bps: 4, Pps: 5, RateSource: StatsSourceStats}},
+		"both with queue only": {stats: legacy, stats2: &Stats2{Queue: queue},
+			want: &UnifiedStats{Bytes: 1500, Packets: 1, BasicSource: StatsSourceStats,
+				Qlen: 8, Backlog: 9, Drops: 10, Overlimits: 12, Requeues: 11, QueueSource: StatsSourceStats2,
+				Bps: 4, Pps: 5, RateSource: StatsSourceStats}},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			attr := Attribute{Stats: testcase.stats, Stats2: testcase.stats2}
+			if diff := cmp.Diff(testcase.want, attr.Statistics()); diff != "" {
+				t.Fatalf("UnifiedStats missmatch (-want +got):\n%s", diff)
+			}
+
+			// The same statistics decoded lazily from their binary encoding.
+			raw := &RawStats{}
+			if testcase.stats != nil {
+				data, err := marshalStruct(testcase.stats)
+				if err != nil {
+					t.Fatalf("could not marshal Stats: %v", err)
+				}
+				raw.Stats = data
+			}
+			if testcase.stats2 != nil {
+				data, err := marshalGenStats(testcase.stats2)
+				if err != nil {
+					t.Fatalf("could not marshal Stats2: %v", err)
+				}
+				raw.Stats2 = data
+			}
+			lazy := Attribute{RawStats: raw}
+			if err := lazy.DecodeStats(); err != nil {
+				t.Fatalf("could not decode statistics: %v", err)
+			}
+			if diff := cmp.Diff(testcase.want, lazy.Statistics()); diff != "" {
+				t.Fatalf("decoded UnifiedStats missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+	t.Run("source", func(t *testing.T) {
+		for source, want := range map[StatsSource]string{StatsSourceNone: "none",
+			StatsSourceStats2: "stats2", StatsSourceStats: "stats", 42: "StatsSource(42)"} {
+			if got := source.String(); got != want {
+				t.Fatalf("expected %s but got %s", want, got)
+			}
+		}
+	})
+}
+
+func TestBacklog(t *testing.T) {
+	tests := map[string]struct {
+		stats   *Stats
+		stats2  *Stats2
+		packets uint32
+		bytes   uint32
+		ok      bool
+	}{
+		"none": {},
+		"stats": {stats: &Stats{Bytes: 1 << 20, Packets: 1000, Qlen: 17, Backlog: 25500},
+			packets: 17, bytes: 25500, ok: true},
+		"stats2": {stats2: &Stats2{Basic: &GenBasic{Bytes: 1 << 20, Packets: 1000},
+			Queue: &GenQueue{QueueLen: 42, Backlog: 63000, Drops: 3}},
+			packets: 42, bytes: 63000, ok: true},
+		"both": {stats: &Stats{Qlen: 17, Backlog: 25500},
+			stats2:  &Stats2{Queue: &GenQueue{QueueLen: 42, Backlog: 63000}},
+			packets: 42, bytes: 63000, ok: true},
+		"stats2 without queue": {stats2: &Stats2{Basic: &GenBasic{Bytes: 1 << 20, Packets: 1000}}},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			qdisc := Object{Msg: Msg{Ifindex: 2, Handle: 0x10000, Parent: HandleRoot},
+				Attribute: Attribute{Kind: "fq_codel", FqCodel: &FqCodel{Limit: uint32Ptr(10240)},
+					Stats: testcase.stats, Stats2: testcase.stats2}}
+			msg, err := MarshalReply(unix.RTM_NEWQDISC, &qdisc)
+			if err != nil {
+				t.Fatalf("could not marshal qdisc: %v", err)
+			}
+			got, err := UnmarshalObject(msg)
+			if err != nil {
+				t.Fatalf("could not decode qdisc: %v", err)
+			}
+			packets, bytes, ok := got.Backlog()
+			if packets != testcase.packets || bytes != testcase.bytes || ok != testcase.ok {
+				t.Fatalf("expected backlog %dp %db %v but got %dp %db %v", testcase.packets, testcase.bytes,
+					testcase.ok, packets, bytes, ok)
+			}
+		})
+	}
+}
@@ -4,9 +4,44 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
+	"sync"
 )
 
+// structEncoder is implemented by structs, that are encoded without
+// reflection.
+type structEncoder interface {
+	size() int
+	encode(b []byte)
+}
+
+// structDecoder is implemented by structs, that are decoded without
+// reflection.
+type structDecoder interface {
+	size() int
+	decode(b []byte)
+}
+
+// structSize returns the size of the encoding of s.
+func structSize(s interface{}) int {
+	if d, ok := s.(structDecoder); ok {
+		return d.size()
+	}
+	return binary.Size(s)
+}
+
 func unmarshalStruct(data []byte, s interface{}) error {
+	if d, ok := s.(structDecoder); ok {
+		// Return the same errors as binary.Read.
+		switch {
+		case len(data) == 0:
+			return io.EOF
+		case len(data) < d.size():
+			return io.ErrUnexpectedEOF
+		}
+		d.decode(data)
+		return nil
+	}
 	b := bytes.NewReader(data)
 	return binary.Read(b, nativeEndian, s)
 }
@@ -15,6 +50,19 @@ const (
 	rtaAlignTo = 4
 )
 
+// unmarshalStructAttrs decodes the fixed size struct s at the beginning of
+// data and returns the attributes, that follow s aligned to rtaAlignTo.
+func unmarshalStructAttrs(data []byte, s interface{}) ([]byte, error) {
+	if err := unmarshalStruct(data, s); err != nil {
+		return nil, fmt.Errorf("%T needs %d bytes, but got %d: %w", s, structSize(s), len(data), err)
+	}
+	offset := (structSize(s) + (rtaAlignTo - 1)) & ^(rtaAlignTo - 1)
+	if len(data) < offset {
+		return nil, nil
+	}
+	return data[offset:], nil
+}
+
 func marshalAndAlignStruct(s interface{}) ([]byte, error) {
 	var buf bytes.Buffer
 	err := binary.Write(&buf, nativeEndian, s)
@@ -31,211 +79,216 @@ func marshalAndAlignStruct(s interface{}) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// structBuffers holds the buffers, that marshalStruct encodes structs
+// without structEncoder into.
+var structBuffers = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
 func marshalStruct(s interface{}) ([]byte, error) {
-	var buf bytes.Buffer
-	err := binary.Write(&buf, nativeEndian, s)
-	return buf.Bytes(), err
+	if e, ok := s.(structEncoder); ok {
+		data := make([]byte, e.size())
+		e.encode(data)
+		return data, nil
+	}
+	buf := structBuffers.Get().(*bytes.Buffer)
+	defer structBuffers.Put(buf)
+	buf.Reset()
+	if n := binary.Size(s); n > 0 {
+		buf.Grow(n)
+	}
+	if err := binary.Write(buf, nativeEndian, s); err != nil {
+		return []byte{}, err
+	}
+	return append([]byte(nil), buf.Bytes()...), nil
 }
 
 // Stats from include/uapi/linux/pkt_sched.h
 type Stats struct {
-	Bytes      uint64 /* Number of enqueued bytes */
-	Packets    uint32 /* Number of enqueued packets	*/
-	Drops      uint32 /* Packets dropped because of lack of resources */
-	Overlimits uint32 /* Number of throttle events when this
+	Bytes      uint64 `json:"bytes,omitempty"`      /* Number of enqueued bytes */
+	Packets    uint32 `json:"packets,omitempty"`    /* Number of enqueued packets	*/
+	Drops      uint32 `json:"drops,omitempty"`      /* Packets dropped because of lack of resources */
+	Overlimits uint32 `json:"overlimits,omitempty"` /* Number of throttle events when this
 	 * flow goes out of allocated bandwidth */
-	Bps     uint32 /* Current flow byte rate */
-	Pps     uint32 /* Current flow packet rate */
-	Qlen    uint32
-	Backlog uint32
-}
-
-// Stats2 from include/uapi/linux/pkt_sched.h
-type Stats2 struct {
-	// gnet_stats_basic
-	Bytes   uint64
-	Packets uint32
-	// gnet_stats_queue
-	Qlen       uint32
-	Backlog    uint32
-	Drops      uint32
-	Requeues   uint32
-	Overlimits uint32
-}
-
-// Tcft from include/uapi/linux/pkt_sched.h
-type Tcft struct {
-	Install  uint64
-	LastUse  uint64
-	Expires  uint64
-	FirstUse uint64
+	Bps     uint32 `json:"bps,omitempty"` /* Current flow byte rate */
+	Pps     uint32 `json:"pps,omitempty"` /* Current flow packet rate */
+	Qlen    uint32 `json:"qlen,omitempty"`
+	Backlog uint32 `json:"backlog,omitempty"`
 }
 
 // RateSpec from include/uapi/linux/pkt_sched.h
 type RateSpec struct {
-	CellLog   uint8
-	Linklayer uint8
-	Overhead  uint16
-	CellAlign uint16
-	Mpu       uint16
-	Rate      uint32
+	CellLog   uint8  `json:"cell_log,omitempty"`
+	Linklayer uint8  `json:"linklayer,omitempty"`
+	Overhead  uint16 `json:"overhead,omitempty"`
+	CellAlign uint16 `json:"cell_align,omitempty"`
+	Mpu       uint16 `json:"mpu,omitempty"`
+	Rate      uint32 `json:"rate,omitempty"`
 }
 
 // Policy from include/uapi/linux/pkt_sched.h
 type Policy struct {
-	Index    uint32
-	Action   PolicyAction
-	Limit    uint32
-	Burst    uint32
-	Mtu      uint32
-	Rate     RateSpec
-	PeakRate RateSpec
-	RefCnt   uint32
-	BindCnt  uint32
-	Capab    uint32
+	Index    uint32       `json:"index,omitempty"`
+	Action   PolicyAction `json:"action,omitempty"`
+	Limit    uint32       `json:"limit,omitempty"`
+	Burst    uint32       `json:"burst,omitempty"`
+	Mtu      uint32       `json:"mtu,omitempty"`
+	Rate     RateSpec     `json:"rate,omitempty"`
+	PeakRate RateSpec     `json:"peak_rate,omitempty"`
+	RefCnt   uint32       `json:"ref_cnt,omitempty"`
+	BindCnt  uint32       `json:"bind_cnt,omitempty"`
+	Capab    uint32       `json:"capab,omitempty"`
 }
 
 // FifoOpt from include/uapi/linux/pkt_sched.h
 type FifoOpt struct {
-	Limit uint32
+	Limit uint32 `json:"limit,omitempty"`
 }
 
-// SfqXStats from include/uapi/linux/pkt_sched.h
+// SfqXStats from include/uapi/linux/pkt_sched.h. The kernel reports them for
+// the classes of a sfq qdisc, see SfqFlow.
 type SfqXStats struct {
-	Allot int32
+	Allot int32 `json:"allot,omitempty"`
 }
 
 // RedXStats from include/uapi/linux/pkt_sched.h
 type RedXStats struct {
-	Early  uint32
-	PDrop  uint32
-	Other  uint32
-	Marked uint32
+	Early  uint32 `json:"early,omitempty"`
+	PDrop  uint32 `json:"p_drop,omitempty"`
+	Other  uint32 `json:"other,omitempty"`
+	Marked uint32 `json:"marked,omitempty"`
 }
 
 // ChokeXStats from include/uapi/linux/pkt_sched.h
 type ChokeXStats struct {
-	Early   uint32
-	PDrop   uint32
-	Other   uint32
-	Marked  uint32
-	Matched uint32
+	Early   uint32 `json:"early,omitempty"`
+	PDrop   uint32 `json:"p_drop,omitempty"`
+	Other   uint32 `json:"other,omitempty"`
+	Marked  uint32 `json:"marked,omitempty"`
+	Matched uint32 `json:"matched,omitempty"`
 }
 
 // HtbXStats from include/uapi/linux/pkt_sched.h
 type HtbXStats struct {
-	Lends   uint32
-	Borrows uint32
-	Giants  uint32
-	Tokens  uint32
-	CTokens uint32
+	Lends   uint32 `json:"lends,omitempty"`
+	Borrows uint32 `json:"borrows,omitempty"`
+	Giants  uint32 `json:"giants,omitempty"`
+	// Tokens and CTokens are the remaining credit for rate and ceil.
+	// They become negative, if the class exceeds its rate or ceil.
+	Tokens  int32 `json:"tokens,omitempty"`
+	CTokens int32 `json:"c_tokens,omitempty"`
 }
 
 // CbqXStats from include/uapi/linux/pkt_sched.h
 type CbqXStats struct {
-	Borrows     uint32
-	Overactions uint32
-	AvgIdle     int32
-	Undertime   int32
+	Borrows     uint32 `json:"borrows,omitempty"`
+	Overactions uint32 `json:"overactions,omitempty"`
+	AvgIdle     int32  `json:"avg_idle,omitempty"`
+	Undertime   int32  `json:"undertime,omitempty"`
 }
 
 // SfbXStats from include/uapi/linux/pkt_sched.h
 type SfbXStats struct {
-	EarlyDrop   uint32
-	PenaltyDrop uint32
-	BucketDrop  uint32
-	QueueDrop   uint32
-	ChildDrop   uint32
-	Marked      uint32
-	MaxQlen     uint32
-	MaxProb     uint32
-	AvgProb     uint32
+	EarlyDrop   uint32 `json:"early_drop,omitempty"`
+	PenaltyDrop uint32 `json:"penalty_drop,omitempty"`
+	BucketDrop  uint32 `json:"bucket_drop,omitempty"`
+	QueueDrop   uint32 `json:"queue_drop,omitempty"`
+	ChildDrop   uint32 `json:"child_drop,omitempty"`
+	Marked      uint32 `json:"marked,omitempty"`
+	MaxQlen     uint32 `json:"max_qlen,omitempty"`
+	MaxProb     uint32 `json:"max_prob,omitempty"`
+	AvgProb     uint32 `json:"avg_prob,omitempty"`
 }
 
 // CodelXStats from include/uapi/linux/pkt_sched.h
 type CodelXStats struct {
-	MaxPacket     uint32
-	Count         uint32
-	LastCount     uint32
-	LDelay        uint32
-	DropNext      int32
-	DropOverlimit uint32
-	EcnMark       uint32
-	Dropping      uint32
-	CeMark        uint32
+	MaxPacket     uint32 `json:"max_packet,omitempty"`
+	Count         uint32 `json:"count,omitempty"`
+	LastCount     uint32 `json:"last_count,omitempty"`
+	LDelay        uint32 `json:"l_delay,omitempty"`
+	DropNext      int32  `json:"drop_next,omitempty"`
+	DropOverlimit uint32 `json:"drop_overlimit,omitempty"`
+	EcnMark       uint32 `json:"ecn_mark,omitempty"`
+	Dropping      uint32 `json:"dropping,omitempty"`
+	CeMark        uint32 `json:"ce_mark,omitempty"`
 }
 
 // HhfXStats from include/uapi/linux/pkt_sched.h
 type HhfXStats struct {
-	DropOverlimit uint32
-	HhOverlimit   uint32
-	HhTotCount    uint32
-	HhCurCount    uint32
+	DropOverlimit uint32 `json:"drop_overlimit,omitempty"`
+	HhOverlimit   uint32 `json:"hh_overlimit,omitempty"`
+	HhTotCount    uint32 `json:"hh_tot_count,omitempty"`
+	HhCurCount    uint32 `json:"hh_cur_count,omitempty"`
 }
 
 // PieXStats from include/uapi/linux/pkt_sched.h
 type PieXStats struct {
-	Prob      uint64
-	Delay     uint32
-	AvgDqRate uint32
-	PacketsIn uint32
-	Dropped   uint32
-	Overlimit uint32
-	Maxq      uint32
-	EcnMark   uint32
+	Prob      uint64 `json:"prob,omitempty"`
+	Delay     uint32 `json:"delay,omitempty"`
+	AvgDqRate uint32 `json:"avg_dq_rate,omitempty"`
+	PacketsIn uint32 `json:"packets_in,omitempty"`
+	Dropped   uint32 `json:"dropped,omitempty"`
+	Overlimit uint32 `json:"overlimit,omitempty"`
+	Maxq      uint32 `json:"maxq,omitempty"`
+	EcnMark   uint32 `json:"ecn_mark,omitempty"`
 }
 
 // HfscXStats from include/uapi/linux/pkt_sched.h
 type HfscXStats struct {
-	Work   uint64
-	RtWork uint64
-	Period uint32
-	Level  uint32
+	Work   uint64 `json:"work,omitempty"`
+	RtWork uint64 `json:"rt_work,omitempty"`
+	Period uint32 `json:"period,omitempty"`
+	Level  uint32 `json:"level,omitempty"`
 }
 
 // FqCodelQdStats from include/uapi/linux/pkt_sched.h
 type FqCodelQdStats struct {
-	MaxPacket      uint32
-	DropOverlimit  uint32
-	EcnMark        uint32
-	NewFlowCount   uint32
-	NewFlowsLen    uint32
-	OldFlowsLen    uint32
-	CeMark         uint32
-	MemoryUsage    uint32
-	DropOvermemory uint32
+	MaxPacket      uint32 `json:"max_packet,omitempty"`
+	DropOverlimit  uint32 `json:"drop_overlimit,omitempty"`
+	EcnMark        uint32 `json:"ecn_mark,omitempty"`
+	NewFlowCount   uint32 `json:"new_flow_count,omitempty"`
+	NewFlowsLen    uint32 `json:"new_flows_len,omitempty"`
+	OldFlowsLen    uint32 `json:"old_flows_len,omitempty"`
+	CeMark         uint32 `json:"ce_mark,omitempty"`
+	MemoryUsage    uint32 `json:"memory_usage,omitempty"`
+	DropOvermemory uint32 `json:"drop_overmemory,omitempty"`
 }
 
 // FqCodelClStats from include/uapi/linux/pkt_sched.h
 type FqCodelClStats struct {
-	Deficit   int32
-	LDelay    uint32
-	Count     uint32
-	LastCount uint32
-	Dropping  uint32
-	DropNext  int32
+	Deficit   int32  `json:"deficit,omitempty"`
+	LDelay    uint32 `json:"l_delay,omitempty"`
+	Count     uint32 `json:"count,omitempty"`
+	LastCount uint32 `json:"last_count,omitempty"`
+	Dropping  uint32 `json:"dropping,omitempty"`
+	DropNext  int32  `json:"drop_next,omitempty"`
 }
 
 // FqCodelXStats from include/uapi/linux/pkt_sched.h
 type FqCodelXStats struct {
-	Type uint32
-	Qd   *FqCodelQdStats
-	Cl   *FqCodelClStats
+	Type uint32          `json:"type,omitempty"`
+	Qd   *FqCodelQdStats `json:"qd,omitempty"`
+	Cl   *FqCodelClStats `json:"cl,omitempty"`
 }
 
+// unmarshalFqCodelXStats decodes the union tc_fq_codel_xstats. The qdisc
+// variant is returned for qdisc dumps and the class variant, that holds the
+// statistics of a single flow, for class dumps. Payloads of older kernels
+// with smaller structs leave the trailing fields zero.
 func unmarshalFqCodelXStats(data []byte, info *FqCodelXStats) error {
+	if len(data) < 4 {
+		return fmt.Errorf("unmarshalFqCodelXStats(): %d bytes are too short: %w", len(data), ErrInvalidArg)
+	}
 	info.Type = nativeEndian.Uint32(data[:4])
 	var err error
 	switch info.Type {
 	case tcaFqCodelXStatsQdisc:
-		b := bytes.NewReader(data[4:])
 		stats := &FqCodelQdStats{}
-		err = binary.Read(b, nativeEndian, stats)
+		err = unmarshalStruct(padStruct(data[4:], stats), stats)
 		info.Qd = stats
 	case tcaFqCodelXStatsClass:
-		b := bytes.NewReader(data[4:])
 		stats := &FqCodelClStats{}
-		err = binary.Read(b, nativeEndian, stats)
+		err = unmarshalStruct(padStruct(data[4:], stats), stats)
 		info.Cl = stats
 	default:
 		err = fmt.Errorf("extractFqCodelXStats(): unsupported type: %d: %w",
@@ -244,6 +297,17 @@ func unmarshalFqCodelXStats(data []byte, info *FqCodelXStats) error {
 	return err
 }
 
+// padStruct pads data with zeros to the size of s.
+func padStruct(data []byte, s interface{}) []byte {
+	size := binary.Size(s)
+	if len(data) >= size {
+		return data
+	}
+	padded := make([]byte, size)
+	copy(padded, data)
+	return padded
+}
+
 func marshalFqCodelXStats(v *FqCodelXStats) ([]byte, error) {
 	if v == nil {
 		return []byte{}, fmt.Errorf("FqCodelXStats: %w", ErrNoArg)
@@ -257,8 +321,14 @@ func marshalFqCodelXStats(v *FqCodelXStats) ([]byte, error) {
 	var subStat []byte
 	switch v.Type {
 	case tcaFqCodelXStatsQdisc:
+		if v.Qd == nil {
+			return []byte{}, fmt.Errorf("FqCodelXStats.Qd: %w", ErrNoArg)
+		}
 		subStat, err = marshalStruct(v.Qd)
 	case tcaFqCodelXStatsClass:
+		if v.Cl == nil {
+			return []byte{}, fmt.Errorf("FqCodelXStats.Cl: %w", ErrNoArg)
+		}
 		subStat, err = marshalStruct(v.Cl)
 	default:
 		err = fmt.Errorf("marshalFqCodelXStats(): unknown FqCodelXStat type: %d: %w",
@@ -270,23 +340,23 @@ func marshalFqCodelXStats(v *FqCodelXStats) ([]byte, error) {
 
 // FqQdStats from include/uapi/linux/pkt_sched.h
 type FqQdStats struct {
-	GcFlows             uint64
-	HighPrioPackets     uint64
-	TCPRetrans          uint64
-	Throttled           uint64
-	FlowsPlimit         uint64
-	PktsTooLong         uint64
-	AllocationErrors    uint64
-	TimeNextDelayedFlow int64
-	Flows               uint32
-	InactiveFlows       uint32
-	ThrottledFlows      uint32
-	UnthrottleLatencyNs uint32
-	CEMark              uint64
-	HorizonDrops        uint64
-	HorizonCaps         uint64
-	FastpathPackets     uint64
-	BandDrops           [3]uint64 // FQ_BANDS = 3
-	BandPktCount        [3]uint32 // FQ_BANDS = 3
+	GcFlows             uint64    `json:"gc_flows,omitempty"`
+	HighPrioPackets     uint64    `json:"high_prio_packets,omitempty"`
+	TCPRetrans          uint64    `json:"tcp_retrans,omitempty"`
+	Throttled           uint64    `json:"throttled,omitempty"`
+	FlowsPlimit         uint64    `json:"flows_plimit,omitempty"`
+	PktsTooLong         uint64    `json:"pkts_too_long,omitempty"`
+	AllocationErrors    uint64    `json:"allocation_errors,omitempty"`
+	TimeNextDelayedFlow int64     `json:"time_next_delayed_flow,omitempty"`
+	Flows               uint32    `json:"flows,omitempty"`
+	InactiveFlows       uint32    `json:"inactive_flows,omitempty"`
+	ThrottledFlows      uint32    `json:"throttled_flows,omitempty"`
+	UnthrottleLatencyNs uint32    `json:"unthrottle_latency_ns,omitempty"`
+	CEMark              uint64    `json:"ce_mark,omitempty"`
+	HorizonDrops        uint64    `json:"horizon_drops,omitempty"`
+	HorizonCaps         uint64    `json:"horizon_caps,omitempty"`
+	FastpathPackets     uint64    `json:"fastpath_packets,omitempty"`
+	BandDrops           [3]uint64 `json:"band_drops,omitempty"`     // FQ_BANDS = 3
+	BandPktCount        [3]uint32 `json:"band_pkt_count,omitempty"` // FQ_BANDS = 3
 	_                   uint32    // padding
 }
@@ -0,0 +1,195 @@
+package tc
+
+// The structs in this file are decoded for nearly every object of a dump.
+// They implement structEncoder and structDecoder, so that marshalStruct and
+// unmarshalStruct do not need the reflection of encoding/binary for them.
+// The encodings are the same as the ones of encoding/binary with
+// nativeEndian.
+
+const (
+	msgLen          = 20
+	statsLen        = 36
+	genBasicLen     = 12
+	genRateEstLen   = 8
+	genRateEst64Len = 16
+	genQueueLen     = 20
+	rateSpecLen     = 12
+	htbOptLen       = 2*rateSpecLen + 20
+	netemQoptLen    = 24
+)
+
+func (m Msg) size() int { return msgLen }
+
+func (m Msg) encode(b []byte) {
+	nativeEndian.PutUint32(b[0:], m.Family)
+	nativeEndian.PutUint32(b[4:], m.Ifindex)
+	nativeEndian.PutUint32(b[8:], m.Handle)
+	nativeEndian.PutUint32(b[12:], m.Parent)
+	nativeEndian.PutUint32(b[16:], m.Info)
+}
+
+func (m *Msg) decode(b []byte) {
+	m.Family = nativeEndian.Uint32(b[0:])
+	m.Ifindex = nativeEndian.Uint32(b[4:])
+	m.Handle = nativeEndian.Uint32(b[8:])
+	m.Parent = nativeEndian.Uint32(b[12:])
+	m.Info = nativeEndian.Uint32(b[16:])
+}
+
+func (s Stats) size() int { return statsLen }
+
+func (s Stats) encode(b []byte) {
+	nativeEndian.PutUint64(b[0:], s.Bytes)
+	nativeEndian.PutUint32(b[8:], s.Packets)
+	nativeEndian.PutUint32(b[12:], s.Drops)
+	nativeEndian.PutUint32(b[16:], s.Overlimits)
+	nativeEndian.PutUint32(b[20:], s.Bps)
+	nativeEndian.PutUint32(b[24:], s.Pps)
+	nativeEndian.PutUint32(b[28:], s.Qlen)
+	nativeEndian.PutUint32(b[32:], s.Backlog)
+}
+
+func (s *Stats) decode(b []byte) {
+	s.Bytes = nativeEndian.Uint64(b[0:])
+	s.Packets = nativeEndian.Uint32(b[8:])
+	s.Drops = nativeEndian.Uint32(b[12:])
+	s.Overlimits = nativeEndian.Uint32(b[16:])
+	s.Bps = nativeEndian.Uint32(b[20:])
+	s.Pps = nativeEndian.Uint32(b[24:])
+	s.Qlen = nativeEndian.Uint32(b[28:])
+	s.Backlog = nativeEndian.Uint32(b[32:])
+}
+
+func (g GenBasic) size() int { return genBasicLen }
+
+func (g GenBasic) encode(b []byte) {
+	nativeEndian.PutUint64(b[0:], g.Bytes)
+	nativeEndian.PutUint32(b[8:], g.Packets)
+}
+
+func (g *GenBasic) decode(b []byte) {
+	g.Bytes = nativeEndian.Uint64(b[0:])
+	g.Packets = nativeEndian.Uint32(b[8:])
+}
+
+func (g GenRateEst) size() int { return genRateEstLen }
+
+func (g GenRateEst) encode(b []byte) {
+	nativeEndian.PutUint32(b[0:], g.BytePerSecond)
+	nativeEndian.PutUint32(b[4:], g.PacketPerSecond)
+}
+
+func (g *GenRateEst) decode(b []byte) {
+	g.BytePerSecond = nativeEndian.Uint32(b[0:])
+	g.PacketPerSecond = nativeEndian.Uint32(b[4:])
+}
+
+func (g GenRateEst64) size() int { return genRateEst64Len }
+
+func (g GenRateEst64) encode(b []byte) {
+	nativeEndian.PutUint64(b[0:], g.BytePerSecond)
+	nativeEndian.PutUint64(b[8:], g.PacketPerSecond)
+}
+
+func (g *GenRateEst64) decode(b []byte) {
+	g.BytePerSecond = nativeEndian.Uint64(b[0:])
+	g.PacketPerSecond = nativeEndian.Uint64(b[8:])
+}
+
+func (g GenQueue) size() int { return genQueueLen }
+
+func (g GenQueue) encode(b []byte) {
+	nativeEndian.PutUint32(b[0:], g.QueueLen)
+	nativeEndian.PutUint32(b[4:], g.Backlog)
+	nativeEndian.PutUint32(b[8:], g.Drops)
+	nativeEndian.PutUint32(b[12:], g.Requeues)
+	nativeEndian.PutUint32(b[16:], g.Overlimits)
+}
+
+func (g *GenQueue) decode(b []byte) {
+	g.QueueLen = nativeEndian.Uint32(b[0:])
+	g.Backlog = nativeEndian.Uint32(b[4:])
+	g.Drops = nativeEndian.Uint32(b[8:])
+	g.Requeues = nativeEndian.Uint32(b[12:])
+	g.Overlimits = nativeEndian.Uint32(b[16:])
+}
+
+func (k U32Key) size() int { return u32KeyLen }
+
+func (k U32Key) encode(b []byte) {
+	nativeEndian.PutUint32(b[0:], k.Mask)
+	nativeEndian.PutUint32(b[4:], k.Val)
+	nativeEndian.PutUint32(b[8:], k.Off)
+	nativeEndian.PutUint32(b[12:], k.OffMask)
+}
+
+func (k *U32Key) decode(b []byte) {
+	k.Mask = nativeEndian.Uint32(b[0:])
+	k.Val = nativeEndian.Uint32(b[4:])
+	k.Off = nativeEndian.Uint32(b[8:])
+	k.OffMask = nativeEndian.Uint32(b[12:])
+}
+
+func (r RateSpec) size() int { return rateSpecLen }
+
+func (r RateSpec) encode(b []byte) {
+	b[0] = r.CellLog
+	b[1] = r.Linklayer
+	nativeEndian.PutUint16(b[2:], r.Overhead)
+	nativeEndian.PutUint16(b[4:], r.CellAlign)
+	nativeEndian.PutUint16(b[6:], r.Mpu)
+	nativeEndian.PutUint32(b[8:], r.Rate)
+}
+
+func (r *RateSpec) decode(b []byte) {
+	r.CellLog = b[0]
+	r.Linklayer = b[1]
+	r.Overhead = nativeEndian.Uint16(b[2:])
+	r.CellAlign = nativeEndian.Uint16(b[4:])
+	r.Mpu = nativeEndian.Uint16(b[6:])
+	r.Rate = nativeEndian.Uint32(b[8:])
+}
+
+func (h HtbOpt) size() int { return htbOptLen }
+
+func (h HtbOpt) encode(b []byte) {
+	h.Rate.encode(b[0:])
+	h.Ceil.encode(b[rateSpecLen:])
+	b = b[2*rateSpecLen:]
+	nativeEndian.PutUint32(b[0:], h.Buffer)
+	nativeEndian.PutUint32(b[4:], h.Cbuffer)
+	nativeEndian.PutUint32(b[8:], h.Quantum)
+	nativeEndian.PutUint32(b[12:], h.Level)
+	nativeEndian.PutUint32(b[16:], h.Prio)
+}
+
+func (h *HtbOpt) decode(b []byte) {
+	h.Rate.decode(b[0:])
+	h.Ceil.decode(b[rateSpecLen:])
+	b = b[2*rateSpecLen:]
+	h.Buffer = nativeEndian.Uint32(b[0:])
+	h.Cbuffer = nativeEndian.Uint32(b[4:])
+	h.Quantum = nativeEndian.Uint32(b[8:])
+	h.Level = nativeEndian.Uint32(b[12:])
+	h.Prio = nativeEndian.Uint32(b[16:])
+}
+
+func (n NetemQopt) size() int { return netemQoptLen }
+
+func (n NetemQopt) encode(b []byte) {
+	nativeEndian.PutUint32(b[0:], n.Latency)
+	nativeEndian.PutUint32(b[4:], n.Limit)
+	nativeEndian.PutUint32(b[8:], n.Loss)
+	nativeEndian.PutUint32(b[12:], n.Gap)
+	nativeEndian.PutUint32(b[16:], n.Duplicate)
+	nativeEndian.PutUint32(b[20:], n.Jitter)
+}
+
+func (n *NetemQopt) decode(b []byte) {
+	n.Latency = nativeEndian.Uint32(b[0:])
+	n.Limit = nativeEndian.Uint32(b[4:])
+	n.Loss = nativeEndian.Uint32(b[8:])
+	n.Gap = nativeEndian.Uint32(b[12:])
+	n.Duplicate = nativeEndian.Uint32(b[16:])
+	n.Jitter = nativeEndian.Uint32(b[20:])
+}
@@ -0,0 +1,97 @@
+package tc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// codecStructs contains a value of every struct, that implements
+// structEncoder and structDecoder.
+var codecStructs = []interface{}{
+	Msg{}, Stats{}, GenBasic{}, GenRateEst{}, GenRateEst64{}, GenQueue{},
+	U32Key{}, RateSpec{}, HtbOpt{}, NetemQopt{},
+}
+
+func TestStructCodec(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	for _, s := range codecStructs {
+		typ := reflect.TypeOf(s)
+		t.Run(typ.Name(), func(t *testing.T) {
+			if _, ok := reflect.New(typ).Interface().(structDecoder); !ok {
+				t.Fatalf("%s does not implement structDecoder", typ)
+			}
+			if size := structSize(reflect.New(typ).Interface()); size != binary.Size(s) {
+				t.Fatalf("expected size %d but got %d", binary.Size(s), size)
+			}
+			for i := 0; i < 100; i++ {
+				v, ok := quick.Value(typ, rnd)
+				if !ok {
+					t.Fatalf("could not generate %s", typ)
+				}
+				ptr := reflect.New(typ)
+				ptr.Elem().Set(v)
+
+				var want bytes.Buffer
+				if err := binary.Write(&want, nativeEndian, ptr.Interface()); err != nil {
+					t.Fatalf("could not encode %s: %v", typ, err)
+				}
+				got, err := marshalStruct(ptr.Interface())
+				if err != nil {
+					t.Fatalf("could not marshal %s: %v", typ, err)
+				}
+				if diff := cmp.Diff(want.Bytes(), got); diff != "" {
+					t.Fatalf("encoding missmatch (-want +got):\n%s", diff)
+				}
+
+				decoded := reflect.New(typ)
+				if err := unmarshalStruct(got, decoded.Interface()); err != nil {
+					t.Fatalf("could not unmarshal %s: %v", typ, err)
+				}
+				if diff := cmp.Diff(v.Interface(), decoded.Elem().Interface()); diff != "" {
+					t.Fatalf("decoding missmatch (-want +got):\n%s", diff)
+				}
+			}
+
+			for _, n := range []int{0, 1, binary.Size(s) - 1} {
+				data := make([]byte, n)
+				want := binary.Read(bytes.NewReader(data), nativeEndian, reflect.New(typ).Interface())
+				got := unmarshalStruct(data, reflect.New(typ).Interface())
+				if !errors.Is(got, want) {
+					t.Fatalf("%d bytes: expected %v but got %v", n, want, got)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkUnmarshalStruct(b *testing.B) {
+	data, err := marshalStruct(&Stats{Bytes: 1 << 40, Packets: 1 << 20, Drops: 42})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("codec", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var stats Stats
+			if err := unmarshalStruct(data, &stats); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("reflection", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var stats Stats
+			if err := binary.Read(bytes.NewReader(data), nativeEndian, &stats); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"io"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 )
 
 func TestFqCodelXStats(t *testing.T) {
@@ -15,9 +17,11 @@ func TestFqCodelXStats(t *testing.T) {
 		err1 error
 		err2 error
 	}{
-		"Qdisc":   {val: FqCodelXStats{Type: 0, Qd: &FqCodelQdStats{MaxPacket: 123}}},
-		"Class":   {val: FqCodelXStats{Type: 1, Cl: &FqCodelClStats{Deficit: -1}}},
-		"Unknown": {val: FqCodelXStats{Type: 2}, err1: ErrInvalidArg},
+		"Qdisc":               {val: FqCodelXStats{Type: 0, Qd: &FqCodelQdStats{MaxPacket: 123}}},
+		"Class":               {val: FqCodelXStats{Type: 1, Cl: &FqCodelClStats{Deficit: -1}}},
+		"Unknown":             {val: FqCodelXStats{Type: 2}, err1: ErrInvalidArg},
+		"Class without stats": {val: FqCodelXStats{Type: 1}, err1: ErrNoArg},
+		"Type mismatch":       {val: FqCodelXStats{Type: 0, Cl: &FqCodelClStats{Count: 1}}, err1: ErrNoArg},
 	}
 
 	for name, testcase := range tests {
@@ -42,6 +46,27 @@ func TestFqCodelXStats(t *testing.T) {
 			}
 		})
 	}
+	t.Run("short", func(t *testing.T) {
+		if err := unmarshalFqCodelXStats([]byte{0x1}, &FqCodelXStats{}); !errors.Is(err, ErrInvalidArg) {
+			t.Fatalf("expected ErrInvalidArg but got %v", err)
+		}
+	})
+	t.Run("older kernel", func(t *testing.T) {
+		data, err := marshalFqCodelXStats(&FqCodelXStats{Type: 0,
+			Qd: &FqCodelQdStats{MaxPacket: 1514, CeMark: 3, MemoryUsage: 42, DropOvermemory: 7}})
+		if err != nil {
+			t.Fatalf("could not marshal xstats: %v", err)
+		}
+		// memory_usage and drop_overmemory were added with Linux 4.14
+		val := FqCodelXStats{}
+		if err := unmarshalFqCodelXStats(data[:len(data)-8], &val); err != nil {
+			t.Fatalf("could not unmarshal xstats: %v", err)
+		}
+		want := FqCodelXStats{Type: 0, Qd: &FqCodelQdStats{MaxPacket: 1514, CeMark: 3}}
+		if diff := cmp.Diff(want, val); diff != "" {
+			t.Fatalf("FqCodelXStats missmatch (want +got):\n%s", diff)
+		}
+	})
 	t.Run("nil-marshalFqCodelXStats", func(t *testing.T) {
 		_, err := marshalFqCodelXStats(nil)
 		if !errors.Is(err, ErrNoArg) {
@@ -87,3 +112,103 @@ func TestMarshalAndAlignStruct(t *testing.T) {
 		t.Fatalf("Struct alignment missmatch (-want +got):\n%s", diff)
 	}
 }
+
+func TestMarshalStruct(t *testing.T) {
+	// Strings have no fixed size and can not be binary encoded.
+	data, err := marshalStruct(&struct {
+		Index uint32
+		Name  string
+	}{Index: 1, Name: "eth0"})
+	if err == nil {
+		t.Fatalf("expected error but got nil")
+	}
+	if len(data) != 0 {
+		t.Fatalf("expected no data but got %v", data)
+	}
+}
+
+func TestUnmarshalStructAttrs(t *testing.T) {
+	attrs := []byte{0x8, 0x0, 0x1, 0x0, 0x2a, 0x0, 0x0, 0x0}
+	tests := map[string]struct {
+		data  []byte
+		attrs []byte
+		err   error
+	}{
+		"empty":     {err: io.EOF},
+		"truncated": {data: make([]byte, 21), err: io.ErrUnexpectedEOF},
+		// ConnmarkParam holds 22 bytes, so the attributes follow after 24 bytes.
+		"without attributes": {data: make([]byte, 22)},
+		"aligned":            {data: make([]byte, 24)},
+		"with attributes":    {data: append(make([]byte, 24), attrs...), attrs: attrs},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := unmarshalStructAttrs(testcase.data, &ConnmarkParam{})
+			if testcase.err != nil {
+				if !errors.Is(err, testcase.err) {
+					t.Fatalf("expected %v but got %v", testcase.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(testcase.attrs, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Fatalf("attributes missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestXStatsLayout decodes extended statistics, that are packed in the order
+// of the structs in include/uapi/linux/pkt_sched.h, to catch drifting fields.
+func TestXStatsLayout(t *testing.T) {
+	tests := map[string]struct {
+		kind   string
+		kernel interface{}
+		want   XStats
+	}{
+		"sfb": {
+			kind: "sfb",
+			kernel: struct {
+				earlydrop, penaltydrop, bucketdrop, queuedrop, childdrop uint32
+				marked, maxqlen, maxprob, avgprob                        uint32
+			}{1, 2, 3, 4, 5, 6, 7, 8, 9},
+			want: XStats{Sfb: &SfbXStats{EarlyDrop: 1, PenaltyDrop: 2, BucketDrop: 3, QueueDrop: 4,
+				ChildDrop: 5, Marked: 6, MaxQlen: 7, MaxProb: 8, AvgProb: 9}},
+		},
+		"choke": {
+			kind: "choke",
+			kernel: struct {
+				early, pdrop, other, marked, matched uint32
+			}{1, 2, 3, 4, 5},
+			want: XStats{Choke: &ChokeXStats{Early: 1, PDrop: 2, Other: 3, Marked: 4, Matched: 5}},
+		},
+		"red": {
+			kind: "red",
+			kernel: struct {
+				early, pdrop, other, marked uint32
+			}{1, 2, 3, 4},
+			want: XStats{Red: &RedXStats{Early: 1, PDrop: 2, Other: 3, Marked: 4}},
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := binary.Write(&buf, nativeEndian, testcase.kernel); err != nil {
+				t.Fatalf("could not pack kernel struct: %v", err)
+			}
+			got := XStats{}
+			if err := extractXStats(buf.Bytes(), &got, testcase.kind); err != nil {
+				t.Fatalf("could not decode xstats: %v", err)
+			}
+			testcase.want.Kind = testcase.kind
+			testcase.want.Raw = buf.Bytes()
+			if diff := cmp.Diff(testcase.want, got); diff != "" {
+				t.Fatalf("XStats missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
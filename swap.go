@@ -0,0 +1,212 @@
+package tc
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+)
+
+const (
+	// swapPriority and swapHandle identify the filter of chain 0, that sends
+	// all packets to the chain with the rules of SwapRules.
+	swapPriority = 1
+	swapHandle   = 0x1
+
+	// actExtValMask masks the chain of ActGotoChain.
+	actExtValMask = 0x0FFFFFFF
+)
+
+// SwapRules replaces the rules of parent on ifindex without a gap, in which
+// packets are not classified. Like for Msg, ifindex is MagicBlock and
+// parent the index of the block for a shared block. The rules are kept in a
+// chain other than 0 and chain 0 holds a single basic filter with priority 1,
+// that sends all packets to this chain with ActGotoChain.
+//
+// SwapRules creates a new chain, that is not used yet by a filter or as
+// ActGotoChain by an action of the filters of parent, and adds the filters
+// returned by buildNew for this chain to it. Ifindex, Parent and Chain of the
+// filters may be left zero. Then the filter of chain 0 is replaced, so that
+// it sends the packets to the new chain, and the previous chain is deleted
+// together with its filters. If a step fails, the new chain is deleted and
+// the filter of chain 0 is restored. The first call adds the filter to chain
+// 0. Other filters of chain 0 are not changed, but are not reached anymore.
+func SwapRules(tcSocket *Tc, ifindex, parent uint32, buildNew func(chain uint32) []Object) error {
+	if tcSocket == nil || buildNew == nil {
+		return ErrNoArg
+	}
+	if ifindex == 0 {
+		return ErrInvalidDev
+	}
+	msg := Msg{Family: unix.AF_UNSPEC, Ifindex: ifindex, Parent: parent}
+	current, found, used, held, err := swapState(tcSocket, &msg)
+	if err != nil {
+		return err
+	}
+	chain := uint32(1)
+	for used[chain] || held[chain] || (found && chain == current) {
+		chain++
+	}
+	if chain > actExtValMask {
+		return fmt.Errorf("no free chain: %w", ErrInvalidArg)
+	}
+
+	filters := buildNew(chain)
+	for i := range filters {
+		filter := &filters[i]
+		if (filter.Ifindex != 0 && filter.Ifindex != ifindex) || (filter.Parent != 0 && filter.Parent != parent) ||
+			(filter.Chain != nil && *filter.Chain != chain) {
+			return fmt.Errorf("%s: filter is not for chain %d of %s: %w", filter, chain,
+				core.FormatHandle(parent), ErrInvalidArg)
+		}
+		filter.Ifindex, filter.Parent = ifindex, parent
+		filter.Chain = uint32Ptr(chain)
+	}
+
+	newChain := Object{Msg: msg, Attribute: Attribute{Chain: uint32Ptr(chain)}}
+	if err := tcSocket.Chain().Add(&newChain); err != nil {
+		return fmt.Errorf("could not add chain %d: %w", chain, err)
+	}
+	// undo reverts the changes in reverse order, once a step failed.
+	undo := []func() error{func() error { return tcSocket.Chain().Delete(&newChain) }}
+	rollback := func(err error) error {
+		for i := len(undo) - 1; i >= 0; i-- {
+			if rerr := undo[i](); rerr != nil {
+				err = concatError(err, fmt.Errorf("could not revert: %v", rerr))
+			}
+		}
+		return err
+	}
+
+	for i := range filters {
+		if err := tcSocket.Filter().Add(&filters[i]); err != nil {
+			return rollback(fmt.Errorf("could not add %s: %w", &filters[i], err))
+		}
+	}
+
+	next := swapFilter(&msg, chain)
+	if !found {
+		if err := tcSocket.Filter().Add(next); err != nil {
+			return rollback(fmt.Errorf("could not add goto chain %d: %w", chain, err))
+		}
+		return nil
+	}
+	if err := tcSocket.Filter().Replace(next); err != nil {
+		return rollback(fmt.Errorf("could not replace goto chain %d: %w", chain, err))
+	}
+	prev := swapFilter(&msg, current)
+	undo = append(undo, func() error { return tcSocket.Filter().Replace(prev) })
+
+	if current == 0 || !used[current] {
+		// The previous chain has neither filters nor was it created.
+		return nil
+	}
+	oldChain := Object{Msg: msg, Attribute: Attribute{Chain: uint32Ptr(current)}}
+	if err := tcSocket.Chain().Delete(&oldChain); err != nil {
+		return rollback(fmt.Errorf("could not delete chain %d: %w", current, err))
+	}
+	return nil
+}
+
+// swapState returns the chain, that the filter of chain 0 of SwapRules sends
+// the packets to, if the filter exists, the chains of msg, that exist or have
+// filters, and the chains, that other actions of the filters continue with.
+// The kernel does not dump chains, that are only held by actions, but adds
+// them without EEXIST.
+func swapState(tcSocket *Tc, msg *Msg) (uint32, bool, map[uint32]bool, map[uint32]bool, error) {
+	filters, err := tcSocket.Filter().Get(msg)
+	if err != nil {
+		return 0, false, nil, nil, fmt.Errorf("could not get filters: %w", err)
+	}
+	chains, err := tcSocket.Chain().Get(msg)
+	if err != nil {
+		return 0, false, nil, nil, fmt.Errorf("could not get chains: %w", err)
+	}
+	used := make(map[uint32]bool)
+	for i := range chains {
+		used[filterChain(&chains[i])] = true
+	}
+	held := make(map[uint32]bool)
+	var current uint32
+	var found bool
+	for i := range filters {
+		filter := &filters[i]
+		chain := filterChain(filter)
+		used[chain] = true
+		if prio, _ := core.SplitFilterInfo(filter.Info); chain != 0 || prio != swapPriority {
+			for _, action := range filterActions(filter) {
+				for _, verdict := range actionVerdicts(action) {
+					if verdict&^actExtValMask == ActGotoChain {
+						held[verdict&actExtValMask] = true
+					}
+				}
+			}
+			continue
+		}
+		if current, found = swapTarget(filter); !found {
+			return 0, false, nil, nil, fmt.Errorf("%s: priority %d of chain 0 is used: %w", filter, swapPriority,
+				ErrInvalidArg)
+		}
+	}
+	return current, found, used, held, nil
+}
+
+// actionVerdicts returns the verdicts of action, that may be ActGotoChain.
+// The kinds keep their verdict in Action of their Parms, that follow struct
+// tc_gen, except for the random verdict of gact and the verdicts of police.
+func actionVerdicts(action *Action) []uint32 {
+	if action == nil {
+		return nil
+	}
+	var verdicts []uint32
+	v := reflect.ValueOf(action).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		opts := v.Field(i)
+		if opts.Kind() != reflect.Ptr || opts.IsNil() || opts.Elem().Kind() != reflect.Struct {
+			continue
+		}
+		parms := opts.Elem().FieldByName("Parms")
+		if !parms.IsValid() || parms.Kind() != reflect.Ptr || parms.IsNil() {
+			continue
+		}
+		if verdict := parms.Elem().FieldByName("Action"); verdict.IsValid() && verdict.Kind() == reflect.Uint32 {
+			verdicts = append(verdicts, uint32(verdict.Uint()))
+		}
+	}
+	if action.Gact != nil && action.Gact.Prob != nil {
+		verdicts = append(verdicts, action.Gact.Prob.PAction)
+	}
+	if action.Police != nil {
+		conform, exceed := policeVerdicts(action.Police)
+		verdicts = append(verdicts, uint32(conform), uint32(exceed))
+	}
+	return verdicts
+}
+
+// swapTarget returns the chain, that filter sends all packets to, if it is
+// the filter of chain 0 of SwapRules.
+func swapTarget(filter *Object) (uint32, bool) {
+	if filter.Kind != "basic" || filter.Handle != swapHandle || filter.Basic == nil ||
+		filter.Basic.Ematch != nil || !hasActions(filter.Basic.Actions) || len(*filter.Basic.Actions) != 1 {
+		return 0, false
+	}
+	action := (*filter.Basic.Actions)[0]
+	if action == nil || action.Kind != "gact" || action.Gact == nil || action.Gact.Parms == nil ||
+		action.Gact.Parms.Action&^actExtValMask != ActGotoChain {
+		return 0, false
+	}
+	return action.Gact.Parms.Action & actExtValMask, true
+}
+
+// swapFilter returns the filter of chain 0 of SwapRules, that sends all
+// packets to chain.
+func swapFilter(msg *Msg, chain uint32) *Object {
+	return &Object{
+		Msg: Msg{Family: unix.AF_UNSPEC, Ifindex: msg.Ifindex, Parent: msg.Parent, Handle: swapHandle,
+			Info: core.BuildFilterInfo(swapPriority, core.EthPAll)},
+		Attribute: Attribute{Kind: "basic", Chain: uint32Ptr(0), Basic: &Basic{
+			Actions: &[]*Action{{Kind: "gact", Gact: &Gact{Parms: &GactParms{Action: ActGotoChain | chain}}}},
+		}},
+	}
+}
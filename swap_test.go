@@ -0,0 +1,405 @@
+package tc
+
+import (
+	"errors"
+	"sort"
+	"syscall"
+	"testing"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nltest"
+)
+
+// swapRequest describes a request of SwapRules, that changes a chain or
+// filter. Goto is the chain of the filter of chain 0 of SwapRules.
+type swapRequest struct {
+	Type   netlink.HeaderType
+	Flags  netlink.HeaderFlags
+	Chain  uint32
+	Handle uint32
+	Goto   uint32
+}
+
+// swapKernel keeps the chains and filters of a single parent like the kernel.
+type swapKernel struct {
+	chains   map[uint32]bool
+	filters  []Object
+	requests []swapRequest
+}
+
+func (k *swapKernel) handle(t *testing.T, req netlink.Message, fail func(swapRequest) syscall.Errno) ([]netlink.Message, error) {
+	t.Helper()
+	obj, err := UnmarshalObject(req)
+	if err != nil {
+		t.Fatalf("could not decode request: %v", err)
+	}
+	var msgs []netlink.Message
+	switch req.Header.Type {
+	case unix.RTM_GETTFILTER:
+		for i := range k.filters {
+			msg, err := MarshalReply(unix.RTM_NEWTFILTER, &k.filters[i])
+			if err != nil {
+				t.Fatalf("could not encode %s: %v", k.filters[i], err)
+			}
+			msgs = append(msgs, msg)
+		}
+		return swapDump(msgs), nil
+	case unix.RTM_GETCHAIN:
+		for chain := range k.chains {
+			msg, err := MarshalReply(unix.RTM_NEWCHAIN, &Object{Msg: obj.Msg, Attribute: Attribute{Chain: uint32Ptr(chain)}})
+			if err != nil {
+				t.Fatalf("could not encode chain %d: %v", chain, err)
+			}
+			msgs = append(msgs, msg)
+		}
+		return swapDump(msgs), nil
+	}
+
+	r := swapRequest{Type: req.Header.Type, Flags: req.Header.Flags &^ (netlink.Request | netlink.Acknowledge),
+		Chain: filterChain(obj), Handle: obj.Handle}
+	r.Goto, _ = swapTarget(obj)
+	k.requests = append(k.requests, r)
+	if errno := fail(r); errno != 0 {
+		return nltest.Error(int(errno), []netlink.Message{req})
+	}
+	switch req.Header.Type {
+	case unix.RTM_NEWCHAIN:
+		if k.chains[r.Chain] {
+			return nltest.Error(int(syscall.EEXIST), []netlink.Message{req})
+		}
+		k.chains[r.Chain] = true
+	case unix.RTM_DELCHAIN:
+		// Deleting a chain deletes its filters.
+		var filters []Object
+		for _, filter := range k.filters {
+			if filterChain(&filter) != r.Chain {
+				filters = append(filters, filter)
+			}
+		}
+		if !k.chains[r.Chain] && len(filters) == len(k.filters) {
+			return nltest.Error(int(syscall.EINVAL), []netlink.Message{req})
+		}
+		delete(k.chains, r.Chain)
+		k.filters = filters
+	case unix.RTM_NEWTFILTER:
+		for i := range k.filters {
+			if filterChain(&k.filters[i]) == r.Chain && k.filters[i].Handle == r.Handle {
+				if r.Flags&netlink.Excl != 0 {
+					return nltest.Error(int(syscall.EEXIST), []netlink.Message{req})
+				}
+				k.filters[i] = *obj
+				return swapAck(), nil
+			}
+		}
+		k.filters = append(k.filters, *obj)
+	}
+	return swapAck(), nil
+}
+
+// swapDump returns msgs as parts of a dump, that may be empty.
+func swapDump(msgs []netlink.Message) []netlink.Message {
+	for i := range msgs {
+		msgs[i].Header.Flags |= netlink.Multi
+	}
+	return append(msgs, netlink.Message{
+		Header: netlink.Header{Type: netlink.Done, Flags: netlink.Multi},
+		Data:   []byte{0, 0, 0, 0},
+	})
+}
+
+func swapAck() []netlink.Message {
+	return []netlink.Message{{Header: netlink.Header{Type: netlink.Error}, Data: make([]byte, 4)}}
+}
+
+// state returns the chains and filters of k in a stable order.
+func (k *swapKernel) state() ([]uint32, []swapRequest) {
+	var chains []uint32
+	for chain := range k.chains {
+		chains = append(chains, chain)
+	}
+	sort.Slice(chains, func(i, j int) bool { return chains[i] < chains[j] })
+	var filters []swapRequest
+	for i := range k.filters {
+		r := swapRequest{Chain: filterChain(&k.filters[i]), Handle: k.filters[i].Handle}
+		r.Goto, _ = swapTarget(&k.filters[i])
+		filters = append(filters, r)
+	}
+	sort.Slice(filters, func(i, j int) bool {
+		if filters[i].Chain != filters[j].Chain {
+			return filters[i].Chain < filters[j].Chain
+		}
+		return filters[i].Handle < filters[j].Handle
+	})
+	return chains, filters
+}
+
+func TestSwapRules(t *testing.T) {
+	parent := core.BuildHandle(0xFFFF, 0xFFF2)
+	rules := func(chain uint32) []Object {
+		var objs []Object
+		for _, handle := range []uint32{0x1, 0x2} {
+			objs = append(objs, Object{
+				Msg: Msg{Family: unix.AF_UNSPEC, Handle: handle, Info: core.BuildFilterInfo(10, core.EthPIP)},
+				Attribute: Attribute{Kind: "basic",
+					Basic: &Basic{ClassID: uint32Ptr(core.BuildHandle(0x1, handle))}},
+			})
+		}
+		return objs
+	}
+	// running returns a kernel, where the filter of chain 0 sends the packets
+	// to chain 1 with the rules.
+	running := func(chains ...uint32) *swapKernel {
+		k := &swapKernel{chains: map[uint32]bool{}}
+		for _, chain := range chains {
+			k.chains[chain] = true
+		}
+		dispatch := swapFilter(&Msg{Ifindex: 2, Parent: parent}, 1)
+		k.filters = append(k.filters, *dispatch)
+		for _, rule := range rules(1) {
+			rule.Ifindex, rule.Parent, rule.Chain = 2, parent, uint32Ptr(1)
+			k.filters = append(k.filters, rule)
+		}
+		return k
+	}
+	add := netlink.Create | netlink.Excl
+	replace := netlink.Create
+
+	tests := map[string]struct {
+		kernel   *swapKernel
+		build    func(chain uint32) []Object
+		fail     func(swapRequest) syscall.Errno
+		chain    uint32
+		requests []swapRequest
+		chains   []uint32
+		filters  []swapRequest
+		err      error
+	}{
+		"first": {
+			kernel: &swapKernel{chains: map[uint32]bool{}},
+			chain:  1,
+			requests: []swapRequest{{Type: unix.RTM_NEWCHAIN, Flags: add, Chain: 1},
+				{Type: unix.RTM_NEWTFILTER, Flags: add, Chain: 1, Handle: 0x1},
+				{Type: unix.RTM_NEWTFILTER, Flags: add, Chain: 1, Handle: 0x2},
+				{Type: unix.RTM_NEWTFILTER, Flags: add, Chain: 0, Handle: swapHandle, Goto: 1}},
+			chains: []uint32{1},
+			filters: []swapRequest{{Chain: 0, Handle: swapHandle, Goto: 1},
+				{Chain: 1, Handle: 0x1}, {Chain: 1, Handle: 0x2}},
+		},
+		"swap": {
+			kernel: running(1, 2),
+			chain:  3,
+			requests: []swapRequest{{Type: unix.RTM_NEWCHAIN, Flags: add, Chain: 3},
+				{Type: unix.RTM_NEWTFILTER, Flags: add, Chain: 3, Handle: 0x1},
+				{Type: unix.RTM_NEWTFILTER, Flags: add, Chain: 3, Handle: 0x2},
+				{Type: unix.RTM_NEWTFILTER, Flags: replace, Chain: 0, Handle: swapHandle, Goto: 3},
+				{Type: unix.RTM_DELCHAIN, Chain: 1}},
+			chains: []uint32{2, 3},
+			filters: []swapRequest{{Chain: 0, Handle: swapHandle, Goto: 3},
+				{Chain: 3, Handle: 0x1}, {Chain: 3, Handle: 0x2}},
+		},
+		"implicit chain": {
+			// Chain 1 was not created and exists only for its filters.
+			kernel: running(),
+			chain:  2,
+			requests: []swapRequest{{Type: unix.RTM_NEWCHAIN, Flags: add, Chain: 2},
+				{Type: unix.RTM_NEWTFILTER, Flags: add, Chain: 2, Handle: 0x1},
+				{Type: unix.RTM_NEWTFILTER, Flags: add, Chain: 2, Handle: 0x2},
+				{Type: unix.RTM_NEWTFILTER, Flags: replace, Chain: 0, Handle: swapHandle, Goto: 2},
+				{Type: unix.RTM_DELCHAIN, Chain: 1}},
+			chains: []uint32{2},
+			filters: []swapRequest{{Chain: 0, Handle: swapHandle, Goto: 2},
+				{Chain: 2, Handle: 0x1}, {Chain: 2, Handle: 0x2}},
+		},
+		"chain held by action": {
+			// The kernel does not dump chain 2, that is only held by the
+			// action of a filter.
+			kernel: func() *swapKernel {
+				k := running(1)
+				k.filters = append(k.filters, Object{
+					Msg: Msg{Family: unix.AF_UNSPEC, Ifindex: 2, Parent: parent, Handle: 0x3,
+						Info: core.BuildFilterInfo(swapPriority+1, core.EthPAll)},
+					Attribute: Attribute{Kind: "basic", Chain: uint32Ptr(0), Basic: &Basic{
+						Actions: &[]*Action{{Kind: "gact", Gact: &Gact{Parms: &GactParms{Action: ActGotoChain | 2}}}},
+					}},
+				})
+				return k
+			}(),
+			chain: 3,
+			requests: []swapRequest{{Type: unix.RTM_NEWCHAIN, Flags: add, Chain: 3},
+				{Type: unix.RTM_NEWTFILTER, Flags: add, Chain: 3, Handle: 0x1},
+				{Type: unix.RTM_NEWTFILTER, Flags: add, Chain: 3, Handle: 0x2},
+				{Type: unix.RTM_NEWTFILTER, Flags: replace, Chain: 0, Handle: swapHandle, Goto: 3},
+				{Type: unix.RTM_DELCHAIN, Chain: 1}},
+			chains: []uint32{3},
+			filters: []swapRequest{{Chain: 0, Handle: swapHandle, Goto: 3}, {Chain: 0, Handle: 0x3},
+				{Chain: 3, Handle: 0x1}, {Chain: 3, Handle: 0x2}},
+		},
+		"add fails": {
+			kernel: running(1),
+			fail: func(r swapRequest) syscall.Errno {
+				if r.Type == unix.RTM_NEWTFILTER && r.Chain == 2 && r.Handle == 0x2 {
+					return syscall.EINVAL
+				}
+				return 0
+			},
+			chain: 2,
+			requests: []swapRequest{{Type: unix.RTM_NEWCHAIN, Flags: add, Chain: 2},
+				{Type: unix.RTM_NEWTFILTER, Flags: add, Chain: 2, Handle: 0x1},
+				{Type: unix.RTM_NEWTFILTER, Flags: add, Chain: 2, Handle: 0x2},
+				{Type: unix.RTM_DELCHAIN, Chain: 2}},
+			chains: []uint32{1},
+			filters: []swapRequest{{Chain: 0, Handle: swapHandle, Goto: 1},
+				{Chain: 1, Handle: 0x1}, {Chain: 1, Handle: 0x2}},
+			err: syscall.EINVAL,
+		},
+		"replace fails": {
+			kernel: running(1),
+			fail: func(r swapRequest) syscall.Errno {
+				if r.Type == unix.RTM_NEWTFILTER && r.Chain == 0 {
+					return syscall.ENOMEM
+				}
+				return 0
+			},
+			chain: 2,
+			requests: []swapRequest{{Type: unix.RTM_NEWCHAIN, Flags: add, Chain: 2},
+				{Type: unix.RTM_NEWTFILTER, Flags: add, Chain: 2, Handle: 0x1},
+				{Type: unix.RTM_NEWTFILTER, Flags: add, Chain: 2, Handle: 0x2},
+				{Type: unix.RTM_NEWTFILTER, Flags: replace, Chain: 0, Handle: swapHandle, Goto: 2},
+				{Type: unix.RTM_DELCHAIN, Chain: 2}},
+			chains: []uint32{1},
+			filters: []swapRequest{{Chain: 0, Handle: swapHandle, Goto: 1},
+				{Chain: 1, Handle: 0x1}, {Chain: 1, Handle: 0x2}},
+			err: syscall.ENOMEM,
+		},
+		"delete fails": {
+			kernel: running(1),
+			fail: func(r swapRequest) syscall.Errno {
+				if r.Type == unix.RTM_DELCHAIN && r.Chain == 1 {
+					return syscall.EBUSY
+				}
+				return 0
+			},
+			chain: 2,
+			// The filter of chain 0 sends the packets to chain 1 again,
+			// before chain 2 is deleted.
+			requests: []swapRequest{{Type: unix.RTM_NEWCHAIN, Flags: add, Chain: 2},
+				{Type: unix.RTM_NEWTFILTER, Flags: add, Chain: 2, Handle: 0x1},
+				{Type: unix.RTM_NEWTFILTER, Flags: add, Chain: 2, Handle: 0x2},
+				{Type: unix.RTM_NEWTFILTER, Flags: replace, Chain: 0, Handle: swapHandle, Goto: 2},
+				{Type: unix.RTM_DELCHAIN, Chain: 1},
+				{Type: unix.RTM_NEWTFILTER, Flags: replace, Chain: 0, Handle: swapHandle, Goto: 1},
+				{Type: unix.RTM_DELCHAIN, Chain: 2}},
+			chains: []uint32{1},
+			filters: []swapRequest{{Chain: 0, Handle: swapHandle, Goto: 1},
+				{Chain: 1, Handle: 0x1}, {Chain: 1, Handle: 0x2}},
+			err: syscall.EBUSY,
+		},
+		"priority used": {
+			kernel: &swapKernel{chains: map[uint32]bool{}, filters: []Object{{
+				Msg: Msg{Family: unix.AF_UNSPEC, Ifindex: 2, Parent: parent, Handle: 0x1,
+					Info: core.BuildFilterInfo(swapPriority, core.EthPIP)},
+				Attribute: Attribute{Kind: "basic", Basic: &Basic{ClassID: uint32Ptr(0x10001)}}}}},
+			chains:  []uint32{},
+			filters: []swapRequest{{Chain: 0, Handle: 0x1}},
+			err:     ErrInvalidArg,
+		},
+		"other chain": {
+			kernel: running(1),
+			build: func(chain uint32) []Object {
+				objs := rules(chain)
+				objs[1].Chain = uint32Ptr(chain + 1)
+				return objs
+			},
+			chain:  2,
+			chains: []uint32{1},
+			filters: []swapRequest{{Chain: 0, Handle: swapHandle, Goto: 1},
+				{Chain: 1, Handle: 0x1}, {Chain: 1, Handle: 0x2}},
+			err: ErrInvalidArg,
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			fail := testcase.fail
+			if fail == nil {
+				fail = func(swapRequest) syscall.Errno { return 0 }
+			}
+			k := testcase.kernel
+			tcSocket := &Tc{
+				con: nltest.Dial(func(req []netlink.Message) ([]netlink.Message, error) {
+					return k.handle(t, req[0], fail)
+				}),
+			}
+			defer tcSocket.Close()
+
+			build := testcase.build
+			if build == nil {
+				build = rules
+			}
+			var chain uint32
+			err := SwapRules(tcSocket, 2, parent, func(c uint32) []Object {
+				chain = c
+				return build(c)
+			})
+			if !errors.Is(err, testcase.err) {
+				t.Fatalf("expected %v but got %v", testcase.err, err)
+			}
+			if chain != testcase.chain {
+				t.Fatalf("expected chain %d but got %d", testcase.chain, chain)
+			}
+			if diff := cmp.Diff(testcase.requests, k.requests); diff != "" {
+				t.Fatalf("requests missmatch (-want +got):\n%s", diff)
+			}
+			chains, filters := k.state()
+			if len(chains) == 0 {
+				chains = []uint32{}
+			}
+			if diff := cmp.Diff(testcase.chains, chains); diff != "" {
+				t.Fatalf("chains missmatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(testcase.filters, filters); diff != "" {
+				t.Fatalf("filters missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("no socket", func(t *testing.T) {
+		if err := SwapRules(nil, 2, parent, rules); !errors.Is(err, ErrNoArg) {
+			t.Fatalf("expected ErrNoArg but got %v", err)
+		}
+	})
+}
+
+func TestActionVerdicts(t *testing.T) {
+	exceed := PolicyAction(ActGotoChain | 3)
+	tests := map[string]struct {
+		action *Action
+		want   []uint32
+	}{
+		"nil": {},
+		"parms": {
+			action: &Action{Kind: "mirred", Mirred: &Mirred{Parms: &MirredParam{Action: ActGotoChain | 1}}},
+			want:   []uint32{ActGotoChain | 1},
+		},
+		"random": {
+			action: &Action{Kind: "gact", Gact: &Gact{Parms: &GactParms{Action: ActOk},
+				Prob: &GactProb{PAction: ActGotoChain | 2}}},
+			want: []uint32{ActOk, ActGotoChain | 2},
+		},
+		"police": {
+			action: &Action{Kind: "police", Police: &Police{Exceed: &exceed}},
+			want:   []uint32{uint32(PolicyOk), ActGotoChain | 3},
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(testcase.want, actionVerdicts(testcase.action)); diff != "" {
+				t.Fatalf("verdicts missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -2,9 +2,14 @@ package tc
 
 import (
 	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"syscall"
 	"time"
 
+	"github.com/florianl/go-tc/core"
 	"github.com/florianl/go-tc/internal/unix"
 	"github.com/josharian/native"
 	"github.com/mdlayher/netlink"
@@ -18,33 +23,98 @@ type tcConn interface {
 	Receive() ([]netlink.Message, error)
 	Send(m netlink.Message) (netlink.Message, error)
 	SetOption(option netlink.ConnOption, enable bool) error
+	SetReadBuffer(bytes int) error
 	SetReadDeadline(t time.Time) error
 }
 
 var _ tcConn = &netlink.Conn{}
 
+// batchReceiver is implemented by connections, that return a multipart reply
+// in batches as it arrives instead of buffering the complete reply. The
+// messages of a batch are only valid until the next call.
+type batchReceiver interface {
+	receiveBatch() ([]netlink.Message, error)
+}
+
 // Tc represents a RTNETLINK wrapper
 type Tc struct {
 	con tcConn
+
+	skipValidation bool
+	logger         io.Writer
+	timeout        time.Duration
+	lazyStats      bool
+	orphanCheck    bool
+	verifyWrites   bool
+
+	caps *capabilityCache
 }
 
 var nativeEndian = native.Endian
 
-// Open establishes a RTNETLINK socket for traffic control
-func Open(config *Config) (*Tc, error) {
-	var tc Tc
-
-	if config == nil {
-		config = &Config{}
+// Open establishes a RTNETLINK socket for traffic control. The options are
+// applied on top of config, which may be nil.
+// On other platforms than Linux, Open returns an error wrapping
+// ErrNotImplemented.
+func Open(config *Config, opts ...Option) (*Tc, error) {
+	cfg := applyOptions(config, opts)
+	con, err := dial(cfg.NetNS)
+	if err != nil {
+		return nil, err
 	}
-
-	con, err := netlink.Dial(unix.NETLINK_ROUTE, &netlink.Config{NetNS: config.NetNS})
+	if cfg.AutoReopen {
+		con = newReopenConn(con, func() (tcConn, error) {
+			return dial(cfg.NetNS)
+		})
+	}
+	tc, err := newTc(con, &cfg)
 	if err != nil {
+		con.Close()
 		return nil, err
 	}
-	tc.con = con
+	return tc, nil
+}
 
-	return &tc, nil
+// OpenConn uses con for traffic control instead of a new RTNETLINK socket, for
+// example the fake kernel of package tctest or a connection of nltest.Dial.
+// The options are applied on top of config, which may be nil. NetNS and
+// AutoReopen are ignored, as con is already connected. Close closes con.
+func OpenConn(con *netlink.Conn, config *Config, opts ...Option) (*Tc, error) {
+	if con == nil {
+		return nil, ErrNoArg
+	}
+	cfg := applyOptions(config, opts)
+	return newTc(con, &cfg)
+}
+
+// newTc configures con according to config.
+func newTc(con tcConn, config *Config) (*Tc, error) {
+	tc := &Tc{
+		con:            con,
+		skipValidation: config.SkipValidation,
+		logger:         config.Logger,
+		timeout:        config.Timeout,
+		lazyStats:      config.LazyStats,
+		orphanCheck:    config.OrphanCheck,
+		verifyWrites:   config.VerifyWrites,
+		caps:           &capabilityCache{},
+	}
+	if config.ExtendedAck {
+		if err := con.SetOption(netlink.ExtendedAcknowledge, true); err != nil {
+			return nil, err
+		}
+	}
+	if config.StrictCheck {
+		if err := con.SetOption(netlink.GetStrictCheck, true); err != nil {
+			return nil, err
+		}
+	}
+	if config.ReadBuffer > 0 {
+		if err := con.SetReadBuffer(config.ReadBuffer); err != nil {
+			return nil, err
+		}
+	}
+	return tc, nil
 }
 
 // SetOption allows to enable or disable netlink socket options.
@@ -58,38 +128,226 @@ func (tc *Tc) Close() error {
 }
 
 func (tc *Tc) query(req netlink.Message) ([]netlink.Message, error) {
-	verify, err := tc.con.Send(req)
+	msgs, sent, err := tc.queryOnce(req)
+	if err != nil && tc.reopen(err) && (!sent || isGetRequest(req)) {
+		msgs, _, err = tc.queryOnce(req)
+	}
+	return msgs, err
+}
+
+// queryOnce sends req and receives the reply. It reports whether req was
+// sent.
+func (tc *Tc) queryOnce(req netlink.Message) ([]netlink.Message, bool, error) {
+	if sent, err := tc.request(req); err != nil {
+		return nil, sent, err
+	}
+	msgs, err := tc.receive()
+	return msgs, true, err
+}
+
+// request sends req and prepares the connection to receive the reply. It
+// reports whether req was sent.
+func (tc *Tc) request(req netlink.Message) (bool, error) {
+	verify, err := tc.send(req)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
 
 	if err := netlink.Validate(req, []netlink.Message{verify}); err != nil {
-		return nil, err
+		return true, err
 	}
 
-	return tc.con.Receive()
+	if tc.timeout > 0 {
+		if err := tc.con.SetReadDeadline(time.Now().Add(tc.timeout)); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
 }
 
-func (tc *Tc) action(action int, flags netlink.HeaderFlags, msg interface{}, opts []tcOption) error {
+// queryEach sends req and calls fn for every message of the reply. If the
+// connection is a batchReceiver, the messages are passed to fn as they
+// arrive, so that large dumps are never kept in memory at once. If fn
+// returns an error, the remaining reply is drained and the error returned.
+func (tc *Tc) queryEach(req netlink.Message, fn func(netlink.Message) error) error {
+	br, ok := tc.con.(batchReceiver)
+	if !ok {
+		msgs, err := tc.query(req)
+		if err != nil {
+			return err
+		}
+		var interrupted bool
+		for _, msg := range msgs {
+			interrupted = interrupted || msg.Header.Flags&netlink.DumpInterrupted != 0
+			if err := fn(msg); err != nil {
+				return err
+			}
+		}
+		if interrupted {
+			return ErrDumpInterrupted
+		}
+		return nil
+	}
+
+	var delivered bool
+	sent, err := tc.queryEachOnce(br, req, func(msg netlink.Message) error {
+		delivered = true
+		return fn(msg)
+	})
+	// Once messages were passed to fn, the reply can not be received again.
+	if err != nil && !delivered && tc.reopen(err) && (!sent || isGetRequest(req)) {
+		_, err = tc.queryEachOnce(br, req, fn)
+	}
+	return err
+}
+
+// queryEachOnce sends req and passes the reply batch by batch to fn. It
+// reports whether req was sent.
+func (tc *Tc) queryEachOnce(br batchReceiver, req netlink.Message, fn func(netlink.Message) error) (bool, error) {
+	if sent, err := tc.request(req); err != nil {
+		return sent, err
+	}
+	var fnErr error
+	var started, interrupted bool
+	for {
+		msgs, err := br.receiveBatch()
+		// Like netlink.Conn.Receive, the reply is complete with the first
+		// batch, that does not end with a part of a multipart message.
+		var multi bool
+		for _, msg := range msgs {
+			if tc.logger != nil {
+				dumpMessage(tc.logger, "<", msg)
+			}
+			// The kernel marks the remaining messages of a dump, that
+			// became inconsistent, including NLMSG_DONE.
+			interrupted = interrupted || msg.Header.Flags&netlink.DumpInterrupted != 0
+			if msg.Header.Flags&netlink.Multi != 0 {
+				started = true
+				multi = msg.Header.Type != netlink.Done
+				if !multi {
+					continue
+				}
+			}
+			if fnErr == nil {
+				fnErr = fn(msg)
+			}
+		}
+		if err != nil {
+			var errno syscall.Errno
+			if fnErr == nil && started && errors.As(err, &errno) {
+				// The kernel ended the dump with an error, for example
+				// as the dumped qdisc was deleted.
+				return true, &dumpError{err: err}
+			}
+			return true, err
+		}
+		if !multi {
+			if fnErr == nil && interrupted {
+				fnErr = ErrDumpInterrupted
+			}
+			return true, fnErr
+		}
+	}
+}
+
+// send sends req and passes it with its sequence number to the logger.
+func (tc *Tc) send(req netlink.Message) (netlink.Message, error) {
+	msg, err := tc.con.Send(req)
+	if err == nil && tc.logger != nil {
+		dumpMessage(tc.logger, ">", msg)
+	}
+	return msg, err
+}
+
+// receive receives messages and passes them to the logger.
+func (tc *Tc) receive() ([]netlink.Message, error) {
+	msgs, err := tc.con.Receive()
+	if tc.logger != nil {
+		for _, msg := range msgs {
+			dumpMessage(tc.logger, "<", msg)
+		}
+	}
+	return msgs, err
+}
+
+// dumpMessage writes the header and the hex dump of the payload of msg to w.
+func dumpMessage(w io.Writer, dir string, msg netlink.Message) {
+	fmt.Fprintf(w, "%s seq=%d pid=%d type=%d flags=%#x len=%d\n%s", dir,
+		msg.Header.Sequence, msg.Header.PID, msg.Header.Type, uint16(msg.Header.Flags),
+		len(msg.Data), hex.Dump(msg.Data))
+}
+
+// validateDeleteObject returns the options of a request, that deletes info.
+// To identify the object only Msg, Kind and Chain are required, so all other
+// attributes are not sent. A filter without handle is refused, as the kernel
+// would delete all filters of its priority.
+func validateDeleteObject(action int, info *Object) ([]tcOption, error) {
+	options := []tcOption{}
+	if info.Ifindex == 0 {
+		return options, ErrInvalidDev
+	}
+	if err := validateKind(info.Kind); err != nil {
+		return options, err
+	}
+	if action == unix.RTM_DELTFILTER && info.Handle == 0 {
+		return options, fmt.Errorf("%s: Handle is zero and would delete all filters, use FlushAll instead: %w",
+			info.Kind, ErrInvalidArg)
+	}
+	return deleteOptions(action, info), nil
+}
+
+// deleteOptions returns the attributes, that identify info for action.
+func deleteOptions(action int, info *Object) []tcOption {
+	options := []tcOption{}
+	if info.Kind != "" {
+		options = append(options, tcOption{Interpretation: vtString, Type: tcaKind, Data: info.Kind})
+	}
+	if action == unix.RTM_DELTFILTER && info.Chain != nil {
+		options = append(options, tcOption{Interpretation: vtUint32, Type: tcaChain, Data: uint32Value(info.Chain)})
+	}
+	return options
+}
+
+// marshalMessage returns the request of action, that consists of the header
+// msg followed by the attributes opts.
+func marshalMessage(action int, flags netlink.HeaderFlags, msg interface{}, opts []tcOption) (netlink.Message, error) {
 	tcminfo, err := marshalStruct(msg)
 	if err != nil {
-		return err
+		return netlink.Message{}, err
 	}
 
-	var data []byte
-	data = append(data, tcminfo...)
-
 	attrs, err := marshalAttributes(opts)
 	if err != nil {
-		return err
+		return netlink.Message{}, err
 	}
-	data = append(data, attrs...)
-	req := netlink.Message{
+	data := make([]byte, 0, len(tcminfo)+len(attrs))
+	data = append(append(data, tcminfo...), attrs...)
+	if err := checkMessageSize(optionsKind(opts), data); err != nil {
+		return netlink.Message{}, err
+	}
+	return netlink.Message{
 		Header: netlink.Header{
 			Type:  netlink.HeaderType(action),
 			Flags: netlink.Request | netlink.Acknowledge | flags,
 		},
 		Data: data,
+	}, nil
+}
+
+// optionsKind returns the kind of the object of opts.
+func optionsKind(opts []tcOption) string {
+	for _, opt := range opts {
+		if kind, ok := opt.Data.(string); ok && opt.Type == tcaKind {
+			return kind
+		}
+	}
+	return ""
+}
+
+func (tc *Tc) action(action int, flags netlink.HeaderFlags, msg interface{}, opts []tcOption) error {
+	req, err := marshalMessage(action, flags, requestMsg(action, msg), opts)
+	if err != nil {
+		return err
 	}
 
 	msgs, err := tc.query(req)
@@ -113,42 +371,107 @@ func (tc *Tc) action(action int, flags netlink.HeaderFlags, msg interface{}, opt
 	return nil
 }
 
+// dumpAttempts limits the number of times get dumps the objects, if the
+// dump is interrupted by changes of them.
+const dumpAttempts = 3
+
 func (tc *Tc) get(action int, i *Msg) ([]Object, error) {
+	return tc.getWith(action, i, nil)
+}
+
+// getWith dumps the objects described by i with the additional attributes
+// opts of the request.
+func (tc *Tc) getWith(action int, i *Msg, opts []tcOption) ([]Object, error) {
 	var results []Object
+	var err error
+	for attempt := 0; attempt < dumpAttempts; attempt++ {
+		results = nil
+		err = tc.walkWith(action, i, opts, func(obj *Object) error {
+			results = append(results, *obj)
+			return nil
+		})
+		if !errors.Is(err, ErrDumpInterrupted) {
+			break
+		}
+	}
+	return results, err
+}
 
+// walk dumps the objects described by i and calls fn for each of them as
+// soon as it is received. The same Object is passed to every call of fn,
+// so fn must copy it to retain it.
+func (tc *Tc) walk(action int, i *Msg, fn func(*Object) error) error {
+	return tc.walkWith(action, i, nil, fn)
+}
+
+// walkWith is walk with the additional attributes opts of the request.
+func (tc *Tc) walkWith(action int, i *Msg, opts []tcOption, fn func(*Object) error) error {
 	tcminfo, err := marshalStruct(i)
 	if err != nil {
-		return results, err
+		return err
+	}
+	if len(opts) > 0 {
+		attrs, err := marshalAttributes(opts)
+		if err != nil {
+			return err
+		}
+		tcminfo = append(tcminfo, attrs...)
 	}
-
-	var data []byte
-	data = append(data, tcminfo...)
 
 	req := netlink.Message{
 		Header: netlink.Header{
 			Type:  netlink.HeaderType(action),
 			Flags: netlink.Request | netlink.Dump,
 		},
-		Data: data,
+		Data: tcminfo,
+	}
+
+	var obj Object
+	return tc.queryEach(req, func(msg netlink.Message) error {
+		obj = Object{}
+		var attrs []byte
+		var err error
+		obj.Msg, attrs, err = unmarshalTcmsg(msg.Data)
+		if err != nil {
+			return err
+		}
+		if err := extractTcmsg(action, attrs, &obj.Attribute, tc.lazyStats); err != nil {
+			return err
+		}
+		decodeInfo(int(msg.Header.Type), &obj)
+		return fn(&obj)
+	})
+}
+
+// getStats requests the single object described by i and decodes only its
+// statistics.
+func (tc *Tc) getStats(action int, i *Msg) (*Stats2, *XStats, error) {
+	tcminfo, err := marshalStruct(i)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req := netlink.Message{
+		Header: netlink.Header{
+			Type:  netlink.HeaderType(action),
+			Flags: netlink.Request,
+		},
+		Data: tcminfo,
 	}
 
 	msgs, err := tc.query(req)
 	if err != nil {
-		return results, err
+		return nil, nil, err
 	}
 
 	for _, msg := range msgs {
-		var result Object
-		if err := unmarshalStruct(msg.Data[:20], &result.Msg); err != nil {
-			return results, err
-		}
-		if err := extractTcmsgAttributes(action, msg.Data[20:], &result.Attribute); err != nil {
-			return results, err
+		_, attrs, err := unmarshalTcmsg(msg.Data)
+		if err != nil {
+			continue
 		}
-		results = append(results, result)
+		return extractTcmsgStats(attrs)
 	}
-
-	return results, nil
+	return nil, nil, fmt.Errorf("no statistics received: %w", syscall.ENOENT)
 }
 
 // Object represents a generic traffic control object
@@ -159,85 +482,205 @@ type Object struct {
 
 // Msg represents a Traffic Control Message
 type Msg struct {
-	Family  uint32
-	Ifindex uint32
-	Handle  uint32
-	Parent  uint32
-	Info    uint32
+	Family  uint32 `json:"family,omitempty"`
+	Ifindex uint32 `json:"ifindex,omitempty"`
+	Handle  uint32 `json:"handle,omitempty"`
+	Parent  uint32 `json:"parent,omitempty"`
+	Info    uint32 `json:"info,omitempty"`
+}
+
+// Block returns the index of the shared block, that m addresses instead of a
+// network interface. ok is false, if m addresses a network interface.
+func (m Msg) Block() (index uint32, ok bool) {
+	if m.Ifindex != MagicBlock {
+		return 0, false
+	}
+	return m.Parent, true
+}
+
+// unmarshalTcmsg decodes the struct tcmsg at the beginning of data and
+// returns the attributes, that follow it. Messages, that are too short to
+// hold a tcmsg, return an error.
+func unmarshalTcmsg(data []byte) (Msg, []byte, error) {
+	var msg Msg
+	attrs, err := unmarshalStructAttrs(data, &msg)
+	if err != nil {
+		return Msg{}, nil, fmt.Errorf("tcmsg: %w", err)
+	}
+	return msg, attrs, nil
+}
+
+// isQdiscMsg reports, whether messages of msgType carry a qdisc.
+func isQdiscMsg(msgType int) bool {
+	switch msgType {
+	case unix.RTM_NEWQDISC, unix.RTM_DELQDISC, unix.RTM_GETQDISC:
+		return true
+	}
+	return false
+}
+
+// isFilterMsg reports, whether messages of msgType carry a filter.
+func isFilterMsg(msgType int) bool {
+	switch msgType {
+	case unix.RTM_NEWTFILTER, unix.RTM_DELTFILTER, unix.RTM_GETTFILTER:
+		return true
+	}
+	return false
+}
+
+// decodeInfo sets the fields of obj, that are derived from Info of a message
+// of msgType. For qdiscs, linux/net/sched/sch_api.c:tc_fill_qdisc() reports
+// the reference counter in tcm_info. For filters,
+// linux/net/sched/cls_api.c:tcf_fill_node() reports the priority and the
+// protocol.
+func decodeInfo(msgType int, obj *Object) {
+	switch {
+	case isQdiscMsg(msgType):
+		obj.Refcnt = obj.Info
+	case isFilterMsg(msgType):
+		obj.Priority, obj.Protocol = core.SplitFilterInfo(obj.Info)
+	}
+}
+
+// requestMsg returns the tcmsg, that is sent for msg in a request of type
+// action. Info of a dumped qdisc holds its reference counter, which is not
+// sent back to the kernel.
+func requestMsg(action int, msg interface{}) interface{} {
+	if m, ok := msg.(*Msg); ok && isQdiscMsg(action) && m.Info != 0 {
+		tmp := *m
+		tmp.Info = 0
+		return &tmp
+	}
+	return msg
 }
 
 // Attribute contains various elements for traffic control
 type Attribute struct {
-	Kind         string
-	EgressBlock  *uint32
-	IngressBlock *uint32
-	HwOffload    *uint8
-	Chain        *uint32
-	Stats        *Stats
-	XStats       *XStats
-	Stats2       *Stats2
-	Stab         *Stab
-	ExtWarnMsg   string
+	Kind         string  `json:"kind,omitempty"`
+	EgressBlock  *uint32 `json:"egress_block,omitempty"`
+	IngressBlock *uint32 `json:"ingress_block,omitempty"`
+	HwOffload    *uint8  `json:"hw_offload,omitempty"`
+	Chain        *uint32 `json:"chain,omitempty"`
+	Stats        *Stats  `json:"stats,omitempty"`
+	XStats       *XStats `json:"x_stats,omitempty"`
+	Stats2       *Stats2 `json:"stats2,omitempty"`
+	Stab         *Stab   `json:"stab,omitempty"`
+	ExtWarnMsg   string  `json:"ext_warn_msg,omitempty"`
+	// RawStats holds the undecoded statistics, if Config.LazyStats is set.
+	RawStats *RawStats `json:"-"`
+	// Refcnt holds the reference counter of a received qdisc. The kernel
+	// reports it in Msg.Info, that has another meaning for classes and
+	// filters. It is never sent in a request.
+	Refcnt uint32 `json:"refcnt,omitempty"`
+	// Priority and Protocol hold the priority and the ethernet protocol in
+	// host byte order of a received filter, like core.SplitFilterInfo
+	// returns them for Msg.Info. Requests only use Msg.Info.
+	Priority uint16 `json:"priority,omitempty"`
+	Protocol uint16 `json:"protocol,omitempty"`
 
 	// Filters
-	Basic    *Basic
-	BPF      *Bpf
-	Cgroup   *Cgroup
-	U32      *U32
-	Rsvp     *Rsvp
-	Route4   *Route4
-	Fw       *Fw
-	Flow     *Flow
-	Flower   *Flower
-	Matchall *Matchall
-	TcIndex  *TcIndex
+	Basic    *Basic    `json:"basic,omitempty"`
+	BPF      *Bpf      `json:"bpf,omitempty"`
+	Cgroup   *Cgroup   `json:"cgroup,omitempty"`
+	U32      *U32      `json:"u32,omitempty"`
+	Rsvp     *Rsvp     `json:"rsvp,omitempty"`
+	Route4   *Route4   `json:"route4,omitempty"`
+	Fw       *Fw       `json:"fw,omitempty"`
+	Flow     *Flow     `json:"flow,omitempty"`
+	Flower   *Flower   `json:"flower,omitempty"`
+	Matchall *Matchall `json:"matchall,omitempty"`
+	TcIndex  *TcIndex  `json:"tc_index,omitempty"`
 
 	// Classless qdiscs
-	Cake    *Cake
-	FqCodel *FqCodel
-	Codel   *Codel
-	Fq      *Fq
-	Pie     *Pie
-	Hhf     *Hhf
-	Tbf     *Tbf
-	Sfb     *Sfb
-	Sfq     *Sfq
-	Red     *Red
-	MqPrio  *MqPrio
-	Pfifo   *FifoOpt
-	Bfifo   *FifoOpt
-	Choke   *Choke
-	Netem   *Netem
-	Plug    *Plug
+	Cake    *Cake    `json:"cake,omitempty"`
+	FqCodel *FqCodel `json:"fq_codel,omitempty"`
+	Codel   *Codel   `json:"codel,omitempty"`
+	Fq      *Fq      `json:"fq,omitempty"`
+	Pie     *Pie     `json:"pie,omitempty"`
+	Hhf     *Hhf     `json:"hhf,omitempty"`
+	Tbf     *Tbf     `json:"tbf,omitempty"`
+	Sfb     *Sfb     `json:"sfb,omitempty"`
+	Sfq     *Sfq     `json:"sfq,omitempty"`
+	Red     *Red     `json:"red,omitempty"`
+	MqPrio  *MqPrio  `json:"mq_prio,omitempty"`
+	Pfifo   *FifoOpt `json:"pfifo,omitempty"`
+	Bfifo   *FifoOpt `json:"bfifo,omitempty"`
+	Choke   *Choke   `json:"choke,omitempty"`
+	Netem   *Netem   `json:"netem,omitempty"`
+	Plug    *Plug    `json:"plug,omitempty"`
 
 	// Classful qdiscs
-	Cbs      *Cbs
-	Htb      *Htb
-	Hfsc     *Hfsc
-	HfscQOpt *HfscQOpt
-	Dsmark   *Dsmark
-	Drr      *Drr
-	Cbq      *Cbq
-	Atm      *Atm
-	Qfq      *Qfq
-	Prio     *Prio
-	TaPrio   *TaPrio
+	Cbs      *Cbs      `json:"cbs,omitempty"`
+	Htb      *Htb      `json:"htb,omitempty"`
+	Hfsc     *Hfsc     `json:"hfsc,omitempty"`
+	HfscQOpt *HfscQOpt `json:"hfsc_q_opt,omitempty"`
+	Dsmark   *Dsmark   `json:"dsmark,omitempty"`
+	Drr      *Drr      `json:"drr,omitempty"`
+	Cbq      *Cbq      `json:"cbq,omitempty"`
+	Atm      *Atm      `json:"atm,omitempty"`
+	Qfq      *Qfq      `json:"qfq,omitempty"`
+	Prio     *Prio     `json:"prio,omitempty"`
+	TaPrio   *TaPrio   `json:"ta_prio,omitempty"`
+}
+
+// RawStats contains the payload of TCA_STATS, TCA_STATS2 and TCA_XSTATS as
+// received from the kernel.
+type RawStats struct {
+	Stats  []byte
+	Stats2 []byte
+	XStats []byte
+}
+
+// Offloaded reports whether the kernel offloaded the object to hardware.
+// Qdiscs report it with HwOffload and filters with the flag InHw.
+func (a *Attribute) Offloaded() bool {
+	if a.HwOffload != nil {
+		return *a.HwOffload != 0
+	}
+	var flags *uint32
+	switch {
+	case a.Flower != nil:
+		flags = a.Flower.Flags
+	case a.Matchall != nil:
+		flags = a.Matchall.Flags
+	case a.U32 != nil:
+		flags = a.U32.Flags
+	case a.BPF != nil:
+		flags = a.BPF.FlagsGen
+	}
+	return uint32Value(flags)&InHw != 0
+}
+
+// InHwCount returns the number of hardware devices, a filter is offloaded to.
+func (a *Attribute) InHwCount() uint32 {
+	if a.Flower != nil {
+		return uint32Value(a.Flower.InHwCount)
+	}
+	return 0
 }
 
 // XStats contains further statistics to the TCA_KIND
 type XStats struct {
-	Sfb     *SfbXStats
-	Sfq     *SfqXStats
-	Red     *RedXStats
-	Choke   *ChokeXStats
-	Htb     *HtbXStats
-	Cbq     *CbqXStats
-	Codel   *CodelXStats
-	Hhf     *HhfXStats
-	Pie     *PieXStats
-	FqCodel *FqCodelXStats
-	Fq      *FqQdStats
-	Hfsc    *HfscXStats
+	Sfb     *SfbXStats     `json:"sfb,omitempty"`
+	Sfq     *SfqXStats     `json:"sfq,omitempty"`
+	Red     *RedXStats     `json:"red,omitempty"`
+	Choke   *ChokeXStats   `json:"choke,omitempty"`
+	Htb     *HtbXStats     `json:"htb,omitempty"`
+	Cbq     *CbqXStats     `json:"cbq,omitempty"`
+	Codel   *CodelXStats   `json:"codel,omitempty"`
+	Hhf     *HhfXStats     `json:"hhf,omitempty"`
+	Pie     *PieXStats     `json:"pie,omitempty"`
+	FqCodel *FqCodelXStats `json:"fq_codel,omitempty"`
+	Fq      *FqQdStats     `json:"fq,omitempty"`
+	Hfsc    *HfscXStats    `json:"hfsc,omitempty"`
+
+	// Kind and Raw hold the kind and the undecoded payload of TCA_XSTATS.
+	// They are set for every kind, even if it is not known to this package.
+	Kind string `json:"kind,omitempty"`
+	Raw  []byte `json:"raw,omitempty"`
+	// Custom holds the result of the decoder, that is registered for
+	// Kind with RegisterXStats.
+	Custom interface{} `json:"custom,omitempty"`
 }
 
 func marshalXStats(v XStats) ([]byte, error) {
@@ -261,6 +704,10 @@ func marshalXStats(v XStats) ([]byte, error) {
 		return marshalStruct(v.Pie)
 	} else if v.FqCodel != nil {
 		return marshalFqCodelXStats(v.FqCodel)
+	} else if v.Fq != nil {
+		return marshalStruct(v.Fq)
+	} else if v.Hfsc != nil {
+		return marshalStruct(v.Hfsc)
 	}
 	return []byte{}, fmt.Errorf("could not marshal XStat")
 }
@@ -326,7 +773,7 @@ func (tc *Tc) monitor(ctx context.Context, deadline time.Duration,
 		return err
 	}
 
-	verify, err := tc.con.Send(req)
+	verify, err := tc.send(req)
 	if err != nil {
 		tc.con.LeaveGroup(unix.RTNLGRP_TC)
 		return err
@@ -345,7 +792,7 @@ func (tc *Tc) monitor(ctx context.Context, deadline time.Duration,
 			tc.con.LeaveGroup(unix.RTNLGRP_TC)
 		}()
 		for {
-			msgs, err := tc.con.Receive()
+			msgs, err := tc.receive()
 			if err != nil {
 				if ret := errfn(err); ret != 0 {
 					return
@@ -357,13 +804,16 @@ func (tc *Tc) monitor(ctx context.Context, deadline time.Duration,
 			}
 			for _, msg := range msgs {
 				var monitored Object
-				if err := unmarshalStruct(msg.Data[:20], &monitored.Msg); err != nil {
+				var attrs []byte
+				monitored.Msg, attrs, err = unmarshalTcmsg(msg.Data)
+				if err != nil {
 					continue
 				}
-				if err := extractTcmsgAttributes(int(msg.Header.Type), msg.Data[20:],
+				if err := extractTcmsgAttributes(int(msg.Header.Type), attrs,
 					&monitored.Attribute); err != nil {
 					continue
 				}
+				decodeInfo(int(msg.Header.Type), &monitored)
 				if fn(uint16(msg.Header.Type), monitored) != 0 {
 					return
 				}
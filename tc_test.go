@@ -4,9 +4,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/florianl/go-tc/core"
 	"github.com/florianl/go-tc/internal/unix"
 	"github.com/google/go-cmp/cmp"
 	"github.com/mdlayher/netlink"
@@ -125,6 +132,7 @@ func (c *fakeConn) Receive() ([]netlink.Message, error) {
 func (c *fakeConn) JoinGroup(uint32) error                   { return nil }
 func (c *fakeConn) LeaveGroup(uint32) error                  { return nil }
 func (c *fakeConn) SetOption(netlink.ConnOption, bool) error { return nil }
+func (c *fakeConn) SetReadBuffer(int) error                  { return nil }
 func (c *fakeConn) SetReadDeadline(time.Time) error          { return nil }
 
 // fakeConn is a netlink.Conn used for testing.
@@ -170,6 +178,127 @@ func TestMonitor(t *testing.T) {
 	<-ctx.Done()
 }
 
+// eventConn delivers events once. Afterwards Receive fails, which ends the
+// monitor.
+type eventConn struct {
+	fakeConn
+	events []netlink.Message
+}
+
+func (c *eventConn) Receive() ([]netlink.Message, error) {
+	if c.events == nil {
+		return nil, io.EOF
+	}
+	events := c.events
+	c.events = nil
+	return events, nil
+}
+
+func TestMonitorBlock(t *testing.T) {
+	blockFilter := Object{
+		Msg: Msg{Family: unix.AF_UNSPEC, Ifindex: MagicBlock, Handle: 0x1, Parent: 22,
+			Info: core.BuildFilterInfo(1, core.EthPIP)},
+		Attribute: Attribute{Kind: "basic", Priority: 1, Protocol: core.EthPIP, Basic: &Basic{ClassID: uint32Ptr(0x10001)}},
+	}
+	// The interface of a deleted qdisc might not exist anymore.
+	deletedQdisc := Object{
+		Msg:       Msg{Family: unix.AF_UNSPEC, Ifindex: 4242, Handle: 0xFFFF0000, Parent: HandleIngress},
+		Attribute: Attribute{Kind: "ingress"},
+	}
+	conn := &eventConn{}
+	for _, event := range []struct {
+		action  int
+		obj     Object
+		options func(int, *Object) ([]tcOption, error)
+	}{
+		{unix.RTM_NEWTFILTER, blockFilter, validateFilterObject},
+		{unix.RTM_DELQDISC, deletedQdisc, validateQdiscObject},
+	} {
+		opts, err := event.options(event.action, &event.obj)
+		if err != nil {
+			t.Fatalf("could not marshal %s: %v", event.obj, err)
+		}
+		msg, err := marshalMessage(event.action, 0, event.obj.Msg, opts)
+		if err != nil {
+			t.Fatalf("could not marshal %s: %v", event.obj, err)
+		}
+		conn.events = append(conn.events, msg)
+	}
+	tcSocket := &Tc{con: conn}
+	defer tcSocket.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	var got []Object
+	err := tcSocket.MonitorWithErrorFunc(ctx, time.Millisecond, func(action uint16, obj Object) int {
+		got = append(got, obj)
+		return 0
+	}, func(err error) int {
+		close(done)
+		return 1
+	})
+	if err != nil {
+		t.Fatalf("could not start monitor: %v", err)
+	}
+	<-done
+
+	if diff := cmp.Diff([]Object{blockFilter, deletedQdisc}, got); diff != "" {
+		t.Fatalf("events missmatch (-want +got):\n%s", diff)
+	}
+	if block, ok := got[0].Block(); !ok || block != 22 {
+		t.Fatalf("expected event of block 22 but got %s", got[0])
+	}
+	if _, ok := got[1].Block(); ok {
+		t.Fatalf("unexpected block for %s", got[1])
+	}
+}
+
+func TestLogger(t *testing.T) {
+	tcSocket, done := testConn(t)
+	defer done()
+
+	var buf bytes.Buffer
+	tcSocket.logger = &buf
+
+	obj := Object{
+		Msg: Msg{Ifindex: 123, Handle: 0x10000, Parent: HandleRoot},
+		Attribute: Attribute{
+			Kind:  "pfifo",
+			Pfifo: &FifoOpt{Limit: 100},
+		},
+	}
+	if err := tcSocket.Qdisc().Add(&obj); err != nil {
+		t.Fatalf("could not add qdisc: %v", err)
+	}
+
+	lines := strings.Split(buf.String(), "\n")
+	var sent, received []string
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "> "):
+			sent = append(sent, line)
+		case strings.HasPrefix(line, "< "):
+			received = append(received, line)
+		}
+	}
+	if len(sent) != 1 || len(received) != 1 {
+		t.Fatalf("expected one sent and one received message but got:\n%s", buf.String())
+	}
+	for _, line := range []string{sent[0], received[0]} {
+		if !strings.Contains(line, " seq=") || strings.Contains(line, " seq=0 ") {
+			t.Fatalf("expected sequence number in %q", line)
+		}
+	}
+	if want := fmt.Sprintf("type=%d", unix.RTM_NEWQDISC); !strings.Contains(sent[0], want) {
+		t.Fatalf("expected %s in %q", want, sent[0])
+	}
+	// The kind of the qdisc is part of the payload.
+	if !strings.Contains(buf.String(), "70 66 69 66 6f") {
+		t.Fatalf("expected payload in dump:\n%s", buf.String())
+	}
+}
+
 func alterResponses(t *testing.T, cache *[]netlink.Message) []byte {
 	t.Helper()
 	var tmp []Object
@@ -178,22 +307,37 @@ func alterResponses(t *testing.T, cache *[]netlink.Message) []byte {
 	// Decode data from cache
 	for _, msg := range *cache {
 		var result Object
-		if err := extractTcmsgAttributes(0xCAFE, msg.Data[20:], &result.Attribute); err != nil {
+		_, attrs, err := unmarshalTcmsg(msg.Data)
+		if err != nil {
+			t.Fatalf("could not decode tcmsg: %v", err)
+		}
+		if err := extractTcmsgAttributes(0xCAFE, attrs, &result.Attribute); err != nil {
 			t.Fatalf("could not decode attributes: %v", err)
 		}
 		tmp = append(tmp, result)
 	}
 
-	var stats2 bytes.Buffer
-	if err := binary.Write(&stats2, nativeEndian, &Stats2{
-		Bytes:      42,
-		Packets:    1,
-		Qlen:       1,
-		Backlog:    0,
-		Drops:      0,
-		Requeues:   0,
-		Overlimits: 42,
-	}); err != nil {
+	basic, err := marshalStruct(&GenBasic{Bytes: 42, Packets: 1})
+	if err != nil {
+		t.Fatalf("could not encode basic stats: %v", err)
+	}
+	queue, err := marshalStruct(&GenQueue{QueueLen: 1, Overlimits: 42})
+	if err != nil {
+		t.Fatalf("could not encode queue stats: %v", err)
+	}
+	basicHw, err := marshalStruct(&GenBasic{Bytes: 40, Packets: 1})
+	if err != nil {
+		t.Fatalf("could not encode hardware stats: %v", err)
+	}
+	// TCA_STATS2 is a nested attribute and some kernels emit
+	// TCA_STATS_PKT64 before the queue statistics.
+	stats2, err := marshalAttributes([]tcOption{
+		{Interpretation: vtBytes, Type: tcaStatsBasic, Data: basic},
+		{Interpretation: vtUint64, Type: tcaStatsPkt64, Data: uint64(1)},
+		{Interpretation: vtBytes, Type: tcaStatsQueue, Data: queue},
+		{Interpretation: vtBytes, Type: tcaStatsBasicHw, Data: basicHw},
+	})
+	if err != nil {
 		t.Fatalf("could not encode stats2: %v", err)
 	}
 
@@ -217,7 +361,7 @@ func alterResponses(t *testing.T, cache *[]netlink.Message) []byte {
 		var err error
 		var attrs []tcOption
 		attrs = append(attrs, tcOption{Interpretation: vtString, Type: tcaKind, Data: obj.Kind})
-		attrs = append(attrs, tcOption{Interpretation: vtBytes, Type: tcaStats2, Data: stats2.Bytes()})
+		attrs = append(attrs, tcOption{Interpretation: vtBytes, Type: tcaStats2, Data: stats2})
 		attrs = append(attrs, tcOption{Interpretation: vtBytes, Type: tcaStats, Data: stats.Bytes()})
 		attrs = append(attrs, tcOption{Interpretation: vtUint8, Type: tcaHwOffload, Data: uint8(0)})
 
@@ -276,3 +420,372 @@ func alterResponses(t *testing.T, cache *[]netlink.Message) []byte {
 	}
 	return dataStream
 }
+
+func TestOffloaded(t *testing.T) {
+	tests := map[string]struct {
+		attr      Attribute
+		offloaded bool
+		inHwCount uint32
+	}{
+		"qdisc":                 {attr: Attribute{Kind: "mqprio", HwOffload: uint8Ptr(1)}, offloaded: true},
+		"qdisc not offloaded":   {attr: Attribute{Kind: "htb", HwOffload: uint8Ptr(0)}},
+		"no information":        {attr: Attribute{Kind: "fq_codel"}},
+		"flower":                {attr: Attribute{Kind: "flower", Flower: &Flower{Flags: uint32Ptr(SkipSw | InHw), InHwCount: uint32Ptr(2)}}, offloaded: true, inHwCount: 2},
+		"flower not offloaded":  {attr: Attribute{Kind: "flower", Flower: &Flower{Flags: uint32Ptr(NotInHw)}}},
+		"matchall":              {attr: Attribute{Kind: "matchall", Matchall: &Matchall{Flags: uint32Ptr(InHw)}}, offloaded: true},
+		"u32":                   {attr: Attribute{Kind: "u32", U32: &U32{Flags: uint32Ptr(InHw)}}, offloaded: true},
+		"bpf":                   {attr: Attribute{Kind: "bpf", BPF: &Bpf{FlagsGen: uint32Ptr(InHw)}}, offloaded: true},
+		"bpf with action flags": {attr: Attribute{Kind: "bpf", BPF: &Bpf{Flags: uint32Ptr(InHw)}}},
+	}
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := testcase.attr.Offloaded(); got != testcase.offloaded {
+				t.Fatalf("expected offloaded %v but got %v", testcase.offloaded, got)
+			}
+			if got := testcase.attr.InHwCount(); got != testcase.inHwCount {
+				t.Fatalf("expected InHwCount %d but got %d", testcase.inHwCount, got)
+			}
+		})
+	}
+
+	t.Run("not sent", func(t *testing.T) {
+		qdisc := Object{Msg{Ifindex: 42, Handle: 0x10000, Parent: HandleRoot},
+			Attribute{Kind: "qfq", HwOffload: uint8Ptr(1)}}
+		filter := Object{Msg{Ifindex: 42, Parent: HandleClsactIngress, Info: 0x300},
+			Attribute{Kind: "matchall", HwOffload: uint8Ptr(1), Matchall: &Matchall{ClassID: uint32Ptr(0x10001)}}}
+		qdiscOptions, err := validateQdiscObject(unix.RTM_NEWQDISC, &qdisc)
+		if err != nil {
+			t.Fatalf("could not marshal qdisc: %v", err)
+		}
+		filterOptions, err := validateFilterObject(unix.RTM_NEWTFILTER, &filter)
+		if err != nil {
+			t.Fatalf("could not marshal filter: %v", err)
+		}
+		for _, option := range append(qdiscOptions, filterOptions...) {
+			if option.Type == tcaHwOffload {
+				t.Fatalf("HwOffload must not be sent to the kernel")
+			}
+		}
+	})
+}
+
+func TestUnmarshalTcmsg(t *testing.T) {
+	header, err := marshalStruct(&Msg{Family: unix.AF_UNSPEC, Ifindex: 42, Handle: 0x10000, Parent: HandleRoot})
+	if err != nil {
+		t.Fatalf("could not marshal tcmsg: %v", err)
+	}
+	attrs, err := marshalAttributes([]tcOption{{Interpretation: vtString, Type: tcaKind, Data: "fq"}})
+	if err != nil {
+		t.Fatalf("could not marshal attributes: %v", err)
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		msg, rest, err := unmarshalTcmsg(append(header, attrs...))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if diff := cmp.Diff(Msg{Family: unix.AF_UNSPEC, Ifindex: 42, Handle: 0x10000, Parent: HandleRoot}, msg); diff != "" {
+			t.Fatalf("tcmsg missmatch (-want +got):\n%s", diff)
+		}
+		if diff := cmp.Diff(attrs, rest); diff != "" {
+			t.Fatalf("attributes missmatch (-want +got):\n%s", diff)
+		}
+	})
+
+	for _, size := range []int{0, 10, 19} {
+		t.Run(fmt.Sprintf("%d bytes", size), func(t *testing.T) {
+			if _, _, err := unmarshalTcmsg(header[:size]); err == nil {
+				t.Fatalf("expected error but got nil")
+			}
+		})
+	}
+
+	t.Run("short reply", func(t *testing.T) {
+		c := &Tc{
+			con: nltest.Dial(func(req []netlink.Message) ([]netlink.Message, error) {
+				if len(req) == 0 {
+					return nil, io.EOF
+				}
+				return []netlink.Message{{Data: header[:10]}}, nil
+			}),
+		}
+		defer c.Close()
+		if _, err := c.Qdisc().Get(); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("expected io.ErrUnexpectedEOF but got %v", err)
+		}
+	})
+}
+
+// dumpConn is a fakeConn, which replies to every request with a multipart
+// dump of total messages in batches of size. The messages are generated as
+// they are received.
+type dumpConn struct {
+	fakeConn
+	data    []byte
+	total   int
+	size    int
+	sent    int
+	batches int
+	dumps   int
+	// interrupted is the number of dumps, whose second half is marked with
+	// NLM_F_DUMP_INTR.
+	interrupted int
+	// errno ends every dump after its first half.
+	errno syscall.Errno
+}
+
+var _ batchReceiver = &dumpConn{}
+
+func (c *dumpConn) Send(m netlink.Message) (netlink.Message, error) {
+	c.sent = 0
+	c.dumps++
+	return m, nil
+}
+
+func (c *dumpConn) receiveBatch() ([]netlink.Message, error) {
+	c.batches++
+	var flags netlink.HeaderFlags
+	if c.dumps <= c.interrupted && c.sent >= c.total/2 {
+		flags = netlink.DumpInterrupted
+	}
+	var msgs []netlink.Message
+	for len(msgs) < c.size && c.sent < c.total {
+		if c.errno != 0 && c.sent == c.total/2 {
+			return msgs, &netlink.OpError{Op: "receive", Err: c.errno}
+		}
+		msgs = append(msgs, netlink.Message{
+			Header: netlink.Header{Type: unix.RTM_NEWTFILTER, Flags: netlink.Multi | flags},
+			Data:   c.data,
+		})
+		c.sent++
+	}
+	if c.sent == c.total && len(msgs) < c.size {
+		msgs = append(msgs, netlink.Message{
+			Header: netlink.Header{Type: netlink.Done, Flags: netlink.Multi | flags},
+			Data:   []byte{0, 0, 0, 0},
+		})
+	}
+	return msgs, nil
+}
+
+func newDumpConn(t testing.TB, total, size int) *dumpConn {
+	t.Helper()
+	tcmsg, err := marshalStruct(&Msg{Family: unix.AF_UNSPEC, Ifindex: 42, Handle: 1,
+		Parent: HandleClsactIngress, Info: 0x10300})
+	if err != nil {
+		t.Fatalf("could not marshal tcmsg: %v", err)
+	}
+	attrs, err := marshalAttributes([]tcOption{
+		{Interpretation: vtString, Type: tcaKind, Data: "matchall"},
+	})
+	if err != nil {
+		t.Fatalf("could not marshal attributes: %v", err)
+	}
+	return &dumpConn{data: append(tcmsg, attrs...), total: total, size: size}
+}
+
+func TestWalk(t *testing.T) {
+	con := newDumpConn(t, 1000, 64)
+	tcSocket := &Tc{con: con}
+
+	var walked int
+	if err := tcSocket.Filter().Walk(&Msg{Ifindex: 42}, func(obj *Object) error {
+		walked++
+		if obj.Kind != "matchall" || obj.Handle != 1 {
+			t.Fatalf("unexpected filter: %v", obj)
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("could not walk filters: %v", err)
+	}
+	if walked != con.total {
+		t.Fatalf("expected %d filters but got %d", con.total, walked)
+	}
+
+	filters, err := tcSocket.Filter().Get(&Msg{Ifindex: 42})
+	if err != nil {
+		t.Fatalf("could not get filters: %v", err)
+	}
+	if len(filters) != con.total {
+		t.Fatalf("expected %d filters but got %d", con.total, len(filters))
+	}
+
+	t.Run("stop", func(t *testing.T) {
+		errStop := errors.New("stop")
+		con.batches = 0
+		walked = 0
+		err := tcSocket.Qdisc().Walk(func(*Object) error {
+			walked++
+			return errStop
+		})
+		if !errors.Is(err, errStop) {
+			t.Fatalf("expected %v but got %v", errStop, err)
+		}
+		if walked != 1 {
+			t.Fatalf("expected fn to be called once but got %d calls", walked)
+		}
+		// The remaining reply is drained, so that it does not end up in the
+		// reply of the next request.
+		if want := con.total/con.size + 1; con.batches != want {
+			t.Fatalf("expected %d batches to be received but got %d", want, con.batches)
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		if err := tcSocket.Filter().Walk(nil, nil); !errors.Is(err, ErrNoArg) {
+			t.Fatalf("expected ErrNoArg but got %v", err)
+		}
+		if err := tcSocket.Class().Walk(nil, nil); !errors.Is(err, ErrNoArg) {
+			t.Fatalf("expected ErrNoArg but got %v", err)
+		}
+	})
+}
+
+func TestDumpInterrupted(t *testing.T) {
+	tests := map[string]struct {
+		total       int
+		interrupted int
+		errno       syscall.Errno
+		filters     int
+		dumps       int
+		err         error
+	}{
+		"consistent":    {total: 100, filters: 100, dumps: 1},
+		"retried":       {total: 100, interrupted: dumpAttempts - 1, filters: 100, dumps: dumpAttempts},
+		"inconsistent":  {total: 100, interrupted: dumpAttempts, filters: 100, dumps: dumpAttempts, err: ErrDumpInterrupted},
+		"qdisc deleted": {total: 100, errno: syscall.ENOENT, filters: 50, dumps: dumpAttempts, err: ErrDumpInterrupted},
+		// The error is received before the first message.
+		"error of the reply": {total: 1, errno: syscall.EINVAL, dumps: 1, err: syscall.EINVAL},
+	}
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			con := newDumpConn(t, testcase.total, 8)
+			con.interrupted = testcase.interrupted
+			con.errno = testcase.errno
+			tcSocket := &Tc{con: con}
+
+			filters, err := tcSocket.Filter().Get(&Msg{Ifindex: 42})
+			if !errors.Is(err, testcase.err) {
+				t.Fatalf("expected error %v but got %v", testcase.err, err)
+			}
+			if testcase.errno != 0 && !errors.Is(err, testcase.errno) {
+				t.Fatalf("expected error %v but got %v", testcase.errno, err)
+			}
+			if testcase.dumps == 1 && errors.Is(err, ErrDumpInterrupted) {
+				t.Fatalf("unexpected error %v", err)
+			}
+			// The partial result of the last dump is returned.
+			if len(filters) != testcase.filters {
+				t.Fatalf("expected %d filters but got %d", testcase.filters, len(filters))
+			}
+			if con.dumps != testcase.dumps {
+				t.Fatalf("expected %d dumps but got %d", testcase.dumps, con.dumps)
+			}
+		})
+	}
+
+	t.Run("walk", func(t *testing.T) {
+		con := newDumpConn(t, 100, 8)
+		con.interrupted = dumpAttempts
+		tcSocket := &Tc{con: con}
+
+		var walked int
+		err := tcSocket.Filter().Walk(&Msg{Ifindex: 42}, func(*Object) error {
+			walked++
+			return nil
+		})
+		if !errors.Is(err, ErrDumpInterrupted) {
+			t.Fatalf("expected ErrDumpInterrupted but got %v", err)
+		}
+		// Walk can not repeat the objects, that were passed to fn.
+		if walked != con.total || con.dumps != 1 {
+			t.Fatalf("expected %d filters of one dump but got %d of %d dumps", con.total, walked, con.dumps)
+		}
+	})
+
+	t.Run("done", func(t *testing.T) {
+		// The first batch holds all filters, so that only NLMSG_DONE in the
+		// second batch is marked.
+		con := newDumpConn(t, 8, 8)
+		con.interrupted = dumpAttempts
+		tcSocket := &Tc{con: con}
+
+		filters, err := tcSocket.Filter().Get(&Msg{Ifindex: 42})
+		if !errors.Is(err, ErrDumpInterrupted) || len(filters) != con.total {
+			t.Fatalf("expected %d filters with ErrDumpInterrupted but got %d with %v", con.total, len(filters), err)
+		}
+	})
+
+	t.Run("receive", func(t *testing.T) {
+		// Connections without batches receive the reply as a whole.
+		var dumps int
+		tcSocket := &Tc{
+			con: nltest.Dial(func(req []netlink.Message) ([]netlink.Message, error) {
+				dumps++
+				con := newDumpConn(t, 2, 2)
+				msgs := make([]netlink.Message, 0, con.total+1)
+				for i := 0; i < con.total; i++ {
+					msgs = append(msgs, netlink.Message{
+						Header: netlink.Header{Type: unix.RTM_NEWTFILTER, Flags: netlink.Multi},
+						Data:   con.data,
+					})
+				}
+				if dumps == 1 {
+					msgs[1].Header.Flags |= netlink.DumpInterrupted
+				}
+				return append(msgs, netlink.Message{
+					Header: netlink.Header{Type: netlink.Done, Flags: netlink.Multi},
+					Data:   []byte{0, 0, 0, 0},
+				}), nil
+			}),
+		}
+		defer tcSocket.Close()
+
+		filters, err := tcSocket.Filter().Get(&Msg{Ifindex: 42})
+		if err != nil {
+			t.Fatalf("could not get filters: %v", err)
+		}
+		if len(filters) != 2 || dumps != 2 {
+			t.Fatalf("expected 2 filters of 2 dumps but got %d of %d dumps", len(filters), dumps)
+		}
+	})
+}
+
+func TestWalkMemory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping dump of 1M messages in short mode")
+	}
+	// heapBudget is the maximum growth of the heap while walking the dump.
+	// Keeping all filters of the dump in memory needs several hundred MiB.
+	const heapBudget = 32 << 20
+
+	con := newDumpConn(t, 1000000, 128)
+	tcSocket := &Tc{con: con}
+
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	base := stats.HeapAlloc
+	var peak uint64
+
+	var walked int
+	if err := tcSocket.Filter().Walk(&Msg{Ifindex: 42}, func(obj *Object) error {
+		walked++
+		if walked%10000 == 0 {
+			runtime.ReadMemStats(&stats)
+			if stats.HeapAlloc > peak {
+				peak = stats.HeapAlloc
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("could not walk filters: %v", err)
+	}
+	if walked != con.total {
+		t.Fatalf("expected %d filters but got %d", con.total, walked)
+	}
+	if peak > base && peak-base > heapBudget {
+		t.Fatalf("heap grew by %d MiB, which exceeds the budget of %d MiB",
+			(peak-base)>>20, heapBudget>>20)
+	}
+}
@@ -0,0 +1,14 @@
+/*
+Package tcconst contains the numbering of the netlink attributes of traffic control,
+that are used by the package github.com/florianl/go-tc.
+
+The names follow the TCA_* constants of the UAPI headers of Linux without the TCA_ prefix,
+like HtbParms for TCA_HTB_PARMS or Kind for TCA_KIND. Only attributes, that are known to
+go-tc, are contained. The kernel never renumbers attributes, but keeps adding them, so
+UAPIVersion names the version of Linux, whose headers the constants were last compared with.
+*/
+package tcconst
+
+// UAPIVersion is the version of Linux, whose UAPI headers include/uapi/linux/pkt_sched.h,
+// include/uapi/linux/pkt_cls.h and include/uapi/linux/tc_act/*.h match the constants.
+const UAPIVersion = "6.13"
@@ -0,0 +1,827 @@
+package tcconst
+
+// Attributes of a request for actions (TCA_ROOT_*).
+const (
+	RootUnspec = iota
+	RootTab
+	RootFlags
+	RootCount
+	RootTimeDelta
+	RootExtWarnMsg
+)
+
+// Attributes of a tcmsg (TCA_*).
+const (
+	Unspec = iota
+	Kind
+	Options
+	Stats
+	Xstats
+	Rate
+	Fcnt
+	Stats2
+	Stab
+	Pad
+	DumpInvisible
+	Chain
+	HwOffload
+	IngressBlock
+	EgressBlock
+	DumpFlags
+	ExtWarnMsg
+)
+
+// Attributes of an ematch tree (TCA_EMATCH_TREE_*).
+const (
+	EmatchTreeUnspec = iota
+	EmatchTreeHdr
+	EmatchTreeList
+)
+
+// Attributes of the ipt ematch (TCA_EM_IPT_*).
+const (
+	EmIptUnspec = iota
+	EmIptHook
+	EmIptMatchName
+	EmIptMatchRevision
+	EmIptNFProto
+	EmIptMatchData
+)
+
+// Attributes of the basic filter (TCA_BASIC_*).
+const (
+	BasicUnspec = iota
+	BasicClassID
+	BasicEmatches
+	BasicAct
+	BasicPolice
+	BasicPCNT
+	BasicPad
+)
+
+// Attributes of the bpf filter (TCA_BPF_*).
+const (
+	BpfUnspec = iota
+	BpfAct
+	BpfPolice
+	BpfClassID
+	BpfOpsLen
+	BpfOps
+	BpfFd
+	BpfName
+	BpfFlags
+	BpfFlagsGen
+	BpfTag
+	BpfID
+)
+
+// Attributes of the cgroup filter (TCA_CGROUP_*).
+const (
+	CgroupUnspec = iota
+	CgroupAct
+	CgroupPolice
+	CgroupEmatches
+)
+
+// Attributes of the flow filter (TCA_FLOW_*).
+const (
+	FlowUnspec = iota
+	FlowKeys
+	FlowMode
+	FlowBaseClass
+	FlowRShift
+	FlowAddend
+	FlowMask
+	FlowXOR
+	FlowDivisor
+	FlowAct
+	FlowPolice
+	FlowEMatches
+	FlowPerTurb
+)
+
+// Attributes of the flower filter (TCA_FLOWER_*).
+const (
+	FlowerUnspec = iota
+	FlowerClassID
+	FlowerIndev
+	FlowerAct
+	FlowerKeyEthDst
+	FlowerKeyEthDstMask
+	FlowerKeyEthSrc
+	FlowerKeyEthSrcMask
+	FlowerKeyEthType
+	FlowerKeyIPProto
+	FlowerKeyIPv4Src
+	FlowerKeyIPv4SrcMask
+	FlowerKeyIPv4Dst
+	FlowerKeyIPv4DstMask
+	FlowerKeyIPv6Src
+	FlowerKeyIPv6SrcMask
+	FlowerKeyIPv6Dst
+	FlowerKeyIPV6DstMask
+	FlowerKeyTCPSrc
+	FlowerKeyTCPDst
+	FlowerKeyUDPSrc
+	FlowerKeyUDPDst
+	FlowerFlags
+	FlowerKeyVlanID
+	FlowerKeyVlanPrio
+	FlowerKeyVlanEthType
+	FlowerKeyEncKeyID
+	FlowerKeyEncIPv4Src
+	FlowerKeyEncIPv4SrcMask
+	FlowerKeyEncIPv4Dst
+	FlowerKeyEncIPv4DstMask
+	FlowerKeyEncIPv6Src
+	FlowerKeyEncIPv6SrcMask
+	FlowerKeyEncIPv6Dst
+	FlowerKeyEncIPv6DstMask
+	FlowerKeyTCPSrcMask
+	FlowerKeyTCPDstMask
+	FlowerKeyUDPSrcMask
+	FlowerKeyUDPDstMask
+	FlowerKeySCTPSrcMask
+	FlowerKeySCTPDstMask
+	FlowerKeySCTPSrc
+	FlowerKeySCTPDst
+	FlowerKeyEncUDPSrcPort
+	FlowerKeyEncUDPSrcPortMask
+	FlowerKeyEncUDPDstPort
+	FlowerKeyEncUDPDstPortMask
+	FlowerKeyFlags
+	FlowerKeyFlagsMask
+	FlowerKeyIcmpv4Code
+	FlowerKeyIcmpv4CodeMask
+	FlowerKeyIcmpv4Type
+	FlowerKeyIcmpv4TypeMask
+	FlowerKeyIcmpv6Code
+	FlowerKeyIcmpv6CodeMask
+	FlowerKeyIcmpv6Type
+	FlowerKeyIcmpv6TypeMask
+	FlowerKeyArpSIP
+	FlowerKeyArpSIPMask
+	FlowerKeyArpTIP
+	FlowerKeyArpTIPMask
+	FlowerKeyArpOp
+	FlowerKeyArpOpMask
+	FlowerKeyArpSha
+	FlowerKeyArpShaMask
+	FlowerKeyArpTha
+	FlowerKeyArpThaMask
+	FlowerKeyMplsTTL
+	FlowerKeyMplsBos
+	FlowerKeyMplsTc
+	FlowerKeyMplsLabel
+	FlowerKeyTCPFlags
+	FlowerKeyTCPFlagsMask
+	FlowerKeyIPTOS
+	FlowerKeyIPTOSMask
+	FlowerKeyIPTTL
+	FlowerKeyIPTTLMask
+	FlowerKeyCVlanID
+	FlowerKeyCVlanPrio
+	FlowerKeyCVlanEthType
+	FlowerKeyEncIPTOS
+	FlowerKeyEncIPTOSMask
+	FlowerKeyEncIPTTL
+	FlowerKeyEncIPTTLMask
+	FlowerKeyEncOpts
+	FlowerKeyEncOptsMask
+	FlowerInHwCount
+	FlowerKeyPortSrcMin
+	FlowerKeyPortSrcMax
+	FlowerKeyPortDstMin
+	FlowerKeyPortDstMax
+	FlowerKeyCtState
+	FlowerKeyCtStateMask
+	FlowerKeyCtZone
+	FlowerKeyCtZoneMask
+	FlowerKeyCtMark
+	FlowerKeyCtMarkMask
+	FlowerKeyCtLabels
+	FlowerKeyCtLabelsMask
+	FlowerKeyMplsOpts
+	FlowerKeyHash
+	FlowerKeyHashMask
+	FlowerKeyNumOfVLANS
+	FlowerKeyPppoeSID
+	FlowerKeyPppProto
+	FlowerKeyL2TPV3SID
+	FlowerL2Miss
+	FlowerKeyCFM
+	FlowerKeySPI
+	FlowerKeySPIMask
+	FlowerKeyEncFlags
+	FlowerKeyEncFlagsMask
+)
+
+// Attributes of the fw filter (TCA_FW_*).
+const (
+	FwUnspec = iota
+	FwClassID
+	FwPolice
+	FwInDev
+	FwAct
+	FwMask
+)
+
+// Attributes of the matchall filter (TCA_MATCHALL_*).
+const (
+	MatchallUnspec = iota
+	MatchallClassID
+	MatchallAct
+	MatchallFlags
+	MatchallPcnt
+	MatchallPad
+)
+
+// Attributes of the route4 filter (TCA_ROUTE4_*).
+const (
+	Route4Unspec = iota
+	Route4ClassID
+	Route4To
+	Route4From
+	Route4IIf
+	Route4Police
+	Route4Act
+)
+
+// Attributes of the rsvp filter (TCA_RSVP_*).
+const (
+	RsvpUnspec = iota
+	RsvpClassID
+	RsvpDst
+	RsvpSrc
+	RsvpPInfo
+	RsvpPolice
+	RsvpAct
+)
+
+// Attributes of the tcindex filter (TCA_TCINDEX_*).
+const (
+	TcIndexUnspec = iota
+	TcIndexHash
+	TcIndexMask
+	TcIndexShift
+	TcIndexFallThrough
+	TcIndexClassID
+	TcIndexPolice
+	TcIndexAct
+)
+
+// Attributes of the u32 filter (TCA_U32_*).
+const (
+	U32Unspec = iota
+	U32ClassID
+	U32Hash
+	U32Link
+	U32Divisor
+	U32Sel
+	U32Police
+	U32Act
+	U32InDev
+	U32Pcnt
+	U32Mark
+	U32Flags
+	U32Pad
+)
+
+// Attributes of an action (TCA_ACT_*).
+const (
+	ActUnspec = iota
+	ActKind
+	ActOptions
+	ActIndex
+	ActStats
+	ActPad
+	ActCookie
+	ActFlags
+	ActHwStats
+	ActUsedHwStats
+	ActInHwCount
+)
+
+// Attributes of the bpf action (TCA_ACT_BPF_*).
+const (
+	ActBpfUnspec = iota
+	ActBpfTm
+	ActBpfParms
+	ActBpfOpsLen
+	ActBpfOps
+	ActBpfFD
+	ActBpfName
+	ActBpfPad
+	ActBpfTag
+	ActBpfID
+)
+
+// Attributes of the connmark action (TCA_CONNMARK_*).
+const (
+	ConnmarkUnspec = iota
+	ConnmarkParms
+	ConnmarkTm
+	ConnmarkPad
+)
+
+// Attributes of the csum action (TCA_CSUM_*).
+const (
+	CsumUnspec = iota
+	CsumParms
+	CsumTm
+	CsumPad
+)
+
+// Attributes of the ct action (TCA_CT_*).
+const (
+	CtUnspec = iota
+	CtParms
+	CtTm
+	CtAction     /* u16 */
+	CtZone       /* u16 */
+	CtMark       /* u32 */
+	CtMarkMask   /* u32 */
+	CtLabels     /* u128 */
+	CtLabelsMask /* u128 */
+	CtNatIPv4Min /* be32 */
+	CtNatIPv4Max /* be32 */
+	CtNatIPv6Min /* struct in6_addr */
+	CtNatIPv6Max /* struct in6_addr */
+	CtNatPortMin /* be16 */
+	CtNatPortMax /* be16 */
+	CtPad
+	CtHelperName
+	CtHelperFamily
+	CtHelperProto
+)
+
+// Attributes of the ctinfo action (TCA_CTINFO_*).
+const (
+	CtInfoUnspec = iota
+	CtInfoPad
+	CtInfoTm
+	CtInfoAct
+	CtInfoZone
+	CtInfoParmsDscpMask
+	CtInfoParmsDscpStateMask
+	CtInfoParmsCpMarkMask
+	CtInfoStatsDscpSet
+	CtInfoStatsDscpError
+	CtInfoStatsCpMarkSet
+)
+
+// Attributes of the defact action (TCA_DEF_*).
+const (
+	DefUnspec = iota
+	DefTm
+	DefParms
+	DefData
+	DefPad
+)
+
+// Attributes of the gact action (TCA_GACT_*).
+const (
+	GactUnspec = iota
+	GactTm
+	GactParm
+	GactProb
+	GactPad
+)
+
+// Attributes of the gate action (TCA_GATE_*).
+const (
+	GateUnspec = iota
+	GateTm
+	GateParms
+	GatePad
+	GatePriority
+	GateEntryList
+	GateBaseTime
+	GateCycleTime
+	GateCycleTimeExt
+	GateFlags
+	GateClockID
+)
+
+// Attributes of the ife action (TCA_IFE_*).
+const (
+	IfeUnspec = iota
+	IfeParms
+	IfeTm
+	IfeDMac
+	IfeSMac
+	IfeType
+	IfeMetaList
+	IfePad
+)
+
+// Attributes of the ipt action (TCA_IPT_*).
+const (
+	IptUnspec = iota
+	IptTable
+	IptHook
+	IptIndex
+	IptCnt
+	IptTm
+	IptTarg
+	IptPad
+)
+
+// Attributes of the mirred action (TCA_MIRRED_*).
+const (
+	MirredUnspec = iota
+	MirredTm
+	MirredParms
+	MirredPad
+	MirredBlockID
+)
+
+// Attributes of the mpls action (TCA_MPLS_*).
+const (
+	MPLSUnspec = iota
+	MPLSTm
+	MPLSParms
+	MPLSPad
+	MPLSProto /* be16; eth_type of pushed or next (for pop) header. */
+	MPLSLabel
+	MPLSTC
+	MPLSTTL
+	MPLSBOS
+)
+
+// Attributes of the nat action (TCA_NAT_*).
+const (
+	NatUnspec = iota
+	NatParms
+	NatTm
+	NatPad
+)
+
+// Attributes of the police action (TCA_POLICE_*).
+const (
+	PoliceUnspec = iota
+	PoliceTbf
+	PoliceRate
+	PolicePeakRate
+	PoliceAvRate
+	PoliceResult
+	PoliceTm
+	PolicePad
+	PoliceRate64
+	PolicePeakRate64
+)
+
+// Attributes of the sample action (TCA_SAMPLE_*).
+const (
+	SampleUnspec = iota
+	SampleTm
+	SampleParms
+	SampleRate
+	SampleTruncSize
+	SamplePSampleGroup
+	SamplePad
+)
+
+// Attributes of the skbedit action (TCA_SKBEDIT_*).
+const (
+	SkbEditUnspec = iota
+	SkbEditTm
+	SkbEditParms
+	SkbEditPriority
+	SkbEditQueueMapping
+	SkbEditMark
+	SkbEditPad
+	SkbEditPtype
+	SkbEditMask
+	SkbEditFlags
+	SkbEditQueueMappingMax
+)
+
+// Attributes of the skbmod action (TCA_SKBMOD_*).
+const (
+	SkbModUnspec = iota
+	SkbModTm
+	SkbModParms
+	SkbModDMac
+	SkbModSMac
+	SkbModEType
+	SkbModPad
+)
+
+// Attributes of the tunnel_key action (TCA_TUNNEL_KEY_*).
+const (
+	TunnelUnspec = iota
+	TunnelKeyTm
+	TunnelKeyParms
+	TunnelKeyEncIPv4Src
+	TunnelKeyEncIPv4Dst
+	TunnelKeyEncIPv6Src
+	TunnelKeyEncIPv6Dst
+	TunnelKeyEncKeyID /* be32 */
+	TunnelKeyPad
+	TunnelKeyEncDstPort /* be16 */
+	TunnelKeyNoCSUM
+	TunnelKeyEncOpts
+	TunnelKeyEncTOS
+	TunnelKeyEncTTL
+	TunnelKeyNoFrag
+)
+
+// Attributes of the vlan action (TCA_VLAN_*).
+const (
+	VLanUnspec = iota
+	VLanTm
+	VLanParms
+	VLanPushVLanID
+	VLanPushVLanProtocol
+	VLanPad
+	VLanPushVLanPriority
+)
+
+// Attributes of the atm qdisc (TCA_ATM_*).
+const (
+	AtmUnspec = iota
+	AtmFD
+	AtmPtr
+	AtmHdr
+	AtmExcess
+	AtmAddr
+	AtmState
+)
+
+// Attributes of the cake qdisc (TCA_CAKE_*).
+const (
+	CakeUnspec = iota
+	CakePad
+	CakeBaseRate64
+	CakeDiffServMode
+	CakeAtm
+	CakeFlowMode
+	CakeOverhead
+	CakeRtt
+	CakeTarget
+	CakeAutorate
+	CakeMemory
+	CakeNat
+	CakeRaw
+	CakeWash
+	CakeMpu
+	CakeIngress
+	CakeAckFilter
+	CakeSplitGso
+	CakeFwMark
+)
+
+// Attributes of the cbq qdisc (TCA_CBQ_*).
+const (
+	CbqUnspec = iota
+	CbqLssOpt
+	CbqWrrOpt
+	CbqFOpt
+	CbqOVLStrategy
+	CbqRate
+	CbqRTab
+	CbqPolice
+)
+
+// Attributes of the cbs qdisc (TCA_CBS_*).
+const (
+	CbsUnspec = iota
+	CbsParms
+)
+
+// Attributes of the choke qdisc (TCA_CHOKE_*).
+const (
+	ChokeUnspec = iota
+	ChokeParms
+	ChokeStab
+	ChokeMaxP
+)
+
+// Attributes of the codel qdisc (TCA_CODEL_*).
+const (
+	CodelUnspec = iota
+	CodelTarget
+	CodelLimit
+	CodelInterval
+	CodelECN
+	CodelCEThreshold
+)
+
+// Attributes of the drr qdisc (TCA_DRR_*).
+const (
+	DrrUnspec = iota
+	DrrQuantum
+)
+
+// Attributes of the dsmark qdisc (TCA_DSMARK_*).
+const (
+	DsmarkUnspec = iota
+	DsmarkIndices
+	DsmarkDefaultIndex
+	DsmarkSetTCIndex
+	DsmarkMask
+	DsmarkValue
+)
+
+// Attributes of the ets qdisc (TCA_ETS_*).
+const (
+	EtsUnspec = iota
+	EtsNBands
+	EtsNStrict
+	EtsQuanta
+	EtsQuantaBand
+	EtsPrioMap
+	EtsPrioMapBand
+)
+
+// Attributes of the fq qdisc (TCA_FQ_*).
+const (
+	FqUnspec = iota
+	FqPLimit
+	FqFlowPLimit
+	FqQuantum
+	FqInitQuantum
+	FqRateEnable
+	FqFlowDefaultRate
+	FqFlowMaxRate
+	FqBucketsLog
+	FqFlowRefillDelay
+	FqOrphanMask
+	FqLowRateThreshold
+	FqCEThreshold
+	FqTimerSlack
+	FqHorizon
+	FqHorizonDrop
+	FqPrioMap
+	FqWeights
+	FqOffloadHorizon
+)
+
+// Attributes of the fq_codel qdisc (TCA_FQ_CODEL_*).
+const (
+	FqCodelUnspec = iota
+	FqCodelTarget
+	FqCodelLimit
+	FqCodelInterval
+	FqCodelEcn
+	FqCodelFlows
+	FqCodelQuantum
+	FqCodelCeThreshold
+	FqCodelDropBatchSize
+	FqCodelMemoryLimit
+	FqCodelCeThresholdSelector
+	FqCodelCeThresholdMask
+)
+
+// Types of the statistics of the fq_codel qdisc (TCA_FQ_CODEL_XSTATS_*).
+const (
+	FqCodelXStatsQdisc = iota
+	FqCodelXStatsClass
+)
+
+// Attributes of the hfsc qdisc (TCA_HFSC_*).
+const (
+	HfscUnspec = iota
+	HfscRsc
+	HfscFsc
+	HfscUsc
+)
+
+// Attributes of the hhf qdisc (TCA_HHF_*).
+const (
+	HhfUnspec = iota
+	HhfBacklogLimit
+	HhfQuantum
+	HhfHHFlowsLimit
+	HhfResetTimeout
+	HhfAdmitBytes
+	HhfEVICTTimeout
+	HhfNonHHWeight
+)
+
+// Attributes of the htb qdisc (TCA_HTB_*).
+const (
+	HtbUnspec = iota
+	HtbParms
+	HtbInit
+	HtbCtab
+	HtbRtab
+	HtbDirectQlen
+	HtbRate64
+	HtbCeil64
+	HtbPad
+	HtbOffload
+)
+
+// Attributes of the mqprio qdisc (TCA_MQPRIO_*).
+const (
+	MqPrioUnspec = iota
+	MqPrioMode
+	MqPrioShaper
+	MqPrioMinRate64
+	MqPrioMaxRate64
+)
+
+// Attributes of the netem qdisc (TCA_NETEM_*).
+const (
+	NetemUnspec = iota
+	NetemCorr
+	NetemDelayDist
+	NetemReorder
+	NetemCorrupt
+	NetemLoss
+	NetemRate
+	NetemEcn
+	NetemRate64
+	NetemPad
+	NetemLatency64
+	NetemJitter64
+	NetemSlot
+	NetemSlotDist
+	NetemPrngSeed
+)
+
+// Attributes of the pie qdisc (TCA_PIE_*).
+const (
+	PieUnspec = iota
+	PieTarget
+	PieLimit
+	PieTUpdate
+	PieAlpha
+	PieBeta
+	PieECN
+	PieBytemode
+	PieDqRateEstimator
+)
+
+// Attributes of the qfq qdisc (TCA_QFQ_*).
+const (
+	QfqUnspec = iota
+	QfqWeight
+	QfqLmax
+)
+
+// Attributes of the red qdisc (TCA_RED_*).
+const (
+	RedUnspec = iota
+	RedParms
+	RedStab
+	RedMaxP
+)
+
+// Attributes of the sfb qdisc (TCA_SFB_*).
+const (
+	SfbUnspec = iota
+	SfbParms
+)
+
+// Attributes of the taprio qdisc (TCA_TAPRIO_ATTR_*).
+const (
+	TaPrioUnspec           = iota
+	TaPrioPrioMap          /* struct tc_mqprio_qopt */
+	TaPrioSchedEntryList   /* nested of entry */
+	TaPrioSchedBaseTime    /* s64 */
+	TaPrioSchedSingleEntry /* single entry */
+	TaPrioSchedClockID     /* s32 */
+	TaPrioPad
+	TaPrioAdminSched              /* The admin sched, only used in dump */
+	TaPrioSchedCycleTime          /* s64 */
+	TaPrioSchedCycleTimeExtension /* s64 */
+	TaPrioFlags                   /* u32 */
+	TaPrioTxTimeDelay             /* u32 */
+	TaPrioTcEntry                 /* nest */
+)
+
+// Attributes of the tbf qdisc (TCA_TBF_*).
+const (
+	TbfUnspec = iota
+	TbfParms
+	TbfRtab
+	TbfPtab
+	TbfRate64
+	TbfPrate64
+	TbfBurst
+	TbfPburst
+	TbfPad
+)
+
+// Attributes of a size table (TCA_STAB_*).
+const (
+	StabUnspec = iota
+	StabBase
+	StabData
+)
+
+// Attributes of TCA_STATS2 (TCA_STATS_*).
+const (
+	StatsUnspec = iota
+	StatsBasic
+	StatsRateEst
+	StatsQueue
+	StatsApp
+	StatsRateEst64
+	StatsPad
+	StatsBasicHw
+	StatsPkt64
+)
@@ -0,0 +1,83 @@
+package tc
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// attributeConstants returns the values of the constants in the Go files of
+// dir, whose names start with prefix. The constants are expected to be
+// enumerated with iota, as the attributes in the UAPI headers are.
+func attributeConstants(t *testing.T, dir, prefix string) map[string]int {
+	t.Helper()
+	pkgs, err := parser.ParseDir(token.NewFileSet(), dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatalf("could not parse %s: %v", dir, err)
+	}
+	values := make(map[string]int)
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.CONST {
+					continue
+				}
+				var expr ast.Expr
+				for iota, spec := range gen.Specs {
+					vs := spec.(*ast.ValueSpec)
+					if len(vs.Values) > 0 {
+						expr = vs.Values[0]
+					}
+					for _, name := range vs.Names {
+						if !strings.HasPrefix(name.Name, prefix) || vs.Type != nil {
+							continue
+						}
+						switch v := expr.(type) {
+						case *ast.Ident:
+							if v.Name != "iota" {
+								t.Fatalf("%s: unexpected value %s", name.Name, v.Name)
+							}
+							values[name.Name] = iota
+						case *ast.BasicLit:
+							if v.Kind != token.INT {
+								continue
+							}
+							n, err := strconv.Atoi(v.Value)
+							if err != nil {
+								t.Fatalf("%s: unexpected value %s", name.Name, v.Value)
+							}
+							values[name.Name] = n
+						}
+					}
+				}
+			}
+		}
+	}
+	return values
+}
+
+func TestExportedConstants(t *testing.T) {
+	unexported := attributeConstants(t, ".", "tca")
+	if len(unexported) == 0 {
+		t.Fatalf("no attribute constants found")
+	}
+	want := make(map[string]int, len(unexported))
+	for name, value := range unexported {
+		want[strings.TrimPrefix(name, "tca")] = value
+	}
+	// tcconst exports the constants of package tc. Both have to be extended
+	// together.
+	exported := attributeConstants(t, "tcconst", "")
+	if diff := cmp.Diff(want, exported); diff != "" {
+		t.Fatalf("exported constants missmatch (-want +got):\n%s", diff)
+	}
+}
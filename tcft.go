@@ -0,0 +1,74 @@
+package tc
+
+import (
+	"reflect"
+	"time"
+)
+
+// tcftHz is USER_HZ, the unit of clock_t, in which the kernel reports the
+// values of tcf_t. It is part of the ABI of Linux and 100 on all
+// architectures, independent of the HZ the kernel is built with and of the
+// clock of the packet scheduler.
+const tcftHz = 100
+
+// Tcft from include/uapi/linux/pkt_sched.h
+//
+// The kernel does not report timestamps, but the age of each event at the
+// time of the dump in clock_t units (see tcf_tm_dump() in
+// include/net/act_api.h). Install is the time since the action was created,
+// LastUse and FirstUse the time since it was last and first used. FirstUse is
+// 0, if the action was never used.
+type Tcft struct {
+	Install  uint64 `json:"install,omitempty"`
+	LastUse  uint64 `json:"last_use,omitempty"`
+	Expires  uint64 `json:"expires,omitempty"`
+	FirstUse uint64 `json:"first_use,omitempty"`
+}
+
+// tcftDuration converts clock_t units into a duration.
+func tcftDuration(ticks uint64) time.Duration {
+	if ticks > uint64(1<<63-1)/uint64(time.Second/tcftHz) {
+		return time.Duration(1<<63 - 1)
+	}
+	return time.Duration(ticks) * (time.Second / tcftHz)
+}
+
+// InstallDuration returns the time since the action was installed.
+func (t Tcft) InstallDuration() time.Duration {
+	return tcftDuration(t.Install)
+}
+
+// LastUseDuration returns the time since the action was last used.
+func (t Tcft) LastUseDuration() time.Duration {
+	return tcftDuration(t.LastUse)
+}
+
+// ExpiresDuration returns Expires as duration.
+func (t Tcft) ExpiresDuration() time.Duration {
+	return tcftDuration(t.Expires)
+}
+
+// FirstUseDuration returns the time since the action was first used.
+func (t Tcft) FirstUseDuration() time.Duration {
+	return tcftDuration(t.FirstUse)
+}
+
+// Tm returns the Tcft of the options of the action, that are selected by its
+// Kind. It returns nil, if the kind is unknown or the kernel did not report
+// a Tcft.
+func (a *Action) Tm() *Tcft {
+	entry, ok := actionKinds[a.Kind]
+	if !ok || len(entry.fields) == 0 {
+		return nil
+	}
+	options := reflect.ValueOf(a).Elem().FieldByName(entry.fields[0])
+	if options.IsNil() {
+		return nil
+	}
+	field := options.Elem().FieldByName("Tm")
+	if !field.IsValid() {
+		return nil
+	}
+	tm, _ := field.Interface().(*Tcft)
+	return tm
+}
@@ -0,0 +1,80 @@
+package tc
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTcftDuration(t *testing.T) {
+	tests := map[string]struct {
+		tm   Tcft
+		want [4]time.Duration
+	}{
+		"zero": {},
+		"ticks": {
+			tm:   Tcft{Install: 12, LastUse: 34, Expires: 56, FirstUse: 78},
+			want: [4]time.Duration{120 * time.Millisecond, 340 * time.Millisecond, 560 * time.Millisecond, 780 * time.Millisecond},
+		},
+		"one day": {
+			tm:   Tcft{Install: 8640000, LastUse: 100, FirstUse: 8639999},
+			want: [4]time.Duration{24 * time.Hour, time.Second, 0, 24*time.Hour - 10*time.Millisecond},
+		},
+		"overflow": {
+			tm:   Tcft{Install: 1<<64 - 1, LastUse: 922337203685},
+			want: [4]time.Duration{1<<63 - 1, 9223372036850 * time.Millisecond, 0, 0},
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := [4]time.Duration{testcase.tm.InstallDuration(), testcase.tm.LastUseDuration(),
+				testcase.tm.ExpiresDuration(), testcase.tm.FirstUseDuration()}
+			if diff := cmp.Diff(testcase.want, got); diff != "" {
+				t.Fatalf("durations missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestActionTm(t *testing.T) {
+	tm := &Tcft{Install: 12, LastUse: 34}
+	tests := map[string]struct {
+		action Action
+		want   *Tcft
+	}{
+		"gact":          {action: Action{Kind: "gact", Gact: &Gact{Tm: tm}}, want: tm},
+		"mirred":        {action: Action{Kind: "mirred", Mirred: &Mirred{Tm: tm}}, want: tm},
+		"police":        {action: Action{Kind: "police", Police: &Police{Tm: tm}}, want: tm},
+		"without tm":    {action: Action{Kind: "gact", Gact: &Gact{}}},
+		"without opts":  {action: Action{Kind: "gact"}},
+		"other options": {action: Action{Kind: "gact", Mirred: &Mirred{Tm: tm}}},
+		"unknown kind":  {action: Action{Kind: "foo", Gact: &Gact{Tm: tm}}},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := testcase.action.Tm(); got != testcase.want {
+				t.Fatalf("expected %v but got %v", testcase.want, got)
+			}
+		})
+	}
+
+	// Every registered action has to report its Tcft.
+	for kind, entry := range actionKinds {
+		typ, _ := ActionKindOf(kind)
+		options := reflect.New(typ)
+		field := options.Elem().FieldByName("Tm")
+		if !field.IsValid() {
+			t.Fatalf("%s: options have no Tm", kind)
+		}
+		field.Set(reflect.ValueOf(tm))
+		action := Action{Kind: kind}
+		reflect.ValueOf(&action).Elem().FieldByName(entry.fields[0]).Set(options)
+		if got := action.Tm(); got != tm {
+			t.Fatalf("%s: expected %v but got %v", kind, tm, got)
+		}
+	}
+}
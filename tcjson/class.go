@@ -0,0 +1,81 @@
+package tcjson
+
+import (
+	"fmt"
+	"math"
+
+	tc "github.com/florianl/go-tc"
+	"github.com/florianl/go-tc/core"
+)
+
+// classKinds contains the supported kinds of classes.
+var classKinds = map[string]func(o *options, attr *tc.Attribute) error{
+	"drr": drrClass,
+	"htb": htbClass,
+	"qfq": qfqClass,
+}
+
+func convertClass(e *entry, o *options, obj *tc.Object) error {
+	fn, ok := classKinds[obj.Kind]
+	if !ok {
+		return fmt.Errorf("class %s: %w", obj.Kind, tc.ErrNotImplemented)
+	}
+	if e.Leaf != nil {
+		obj.Info = uint32(*e.Leaf)
+	}
+	return fn(o, &obj.Attribute)
+}
+
+func htbClass(o *options, attr *tc.Attribute) error {
+	// the level is calculated by the kernel
+	o.ignore("level")
+
+	rate := o.rate("rate")
+	if rate == nil {
+		return fmt.Errorf("htb: rate is required: %w", tc.ErrNoArg)
+	}
+	ceil := o.rate("ceil")
+	if ceil == nil {
+		ceil = rate
+	}
+	rspec, err := rateSpec(*rate)
+	if err != nil {
+		return err
+	}
+	cspec, err := rateSpec(*ceil)
+	if err != nil {
+		return err
+	}
+
+	htb := &tc.Htb{
+		Parms: &tc.HtbOpt{
+			Rate:    rspec,
+			Ceil:    cspec,
+			Buffer:  core.XmitTime(*rate, deref(o.size("burst"))),
+			Cbuffer: core.XmitTime(*ceil, deref(o.size("cburst"))),
+			Prio:    deref(o.uint32("prio")),
+			Quantum: deref(o.uint32("quantum")),
+		},
+	}
+	if *rate > math.MaxUint32 {
+		htb.Rate64 = rate
+	}
+	if *ceil > math.MaxUint32 {
+		htb.Ceil64 = ceil
+	}
+	attr.Htb = htb
+	return nil
+}
+
+func drrClass(o *options, attr *tc.Attribute) error {
+	attr.Drr = &tc.Drr{Quantum: o.size("quantum")}
+	return nil
+}
+
+func qfqClass(o *options, attr *tc.Attribute) error {
+	attr.Qfq = &tc.Qfq{
+		Weight: o.uint32("weight"),
+		Lmax:   o.size("maxpkt"),
+	}
+	return nil
+}
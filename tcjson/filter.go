@@ -0,0 +1,337 @@
+package tcjson
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	tc "github.com/florianl/go-tc"
+	"github.com/florianl/go-tc/core"
+)
+
+// Flags of classifiers from include/uapi/linux/pkt_cls.h
+const (
+	clsFlagsSkipHw = 1 << 0
+	clsFlagsSkipSw = 1 << 1
+)
+
+// filterKinds contains the supported kinds of filters.
+var filterKinds = map[string]func(o *options, obj *tc.Object) error{
+	"flower":   flowerFilter,
+	"matchall": matchallFilter,
+	"u32":      u32Filter,
+}
+
+// ipProtocols contains the names of IP protocols, as they are printed by iproute2/tc/f_flower.c.
+var ipProtocols = map[string]uint8{
+	"icmp":   1,
+	"tcp":    6,
+	"udp":    17,
+	"icmpv6": 58,
+	"sctp":   132,
+}
+
+func convertFilter(e *entry, o *options, obj *tc.Object) error {
+	if e.Options == nil {
+		// Each priority is listed once without options before its filters.
+		return errSkip
+	}
+	fn, ok := filterKinds[obj.Kind]
+	if !ok {
+		return fmt.Errorf("filter %s: %w", obj.Kind, tc.ErrNotImplemented)
+	}
+	var proto uint16
+	if e.Protocol != "" {
+		var err error
+		if proto, err = parseEthProtocol(e.Protocol); err != nil {
+			return err
+		}
+	}
+	obj.Info = core.BuildFilterInfo(uint16(e.Pref), proto)
+	obj.Chain = e.Chain
+
+	// The state of the offloading is reported by the kernel.
+	o.ignore("in_hw", "not_in_hw", "in_hw_count")
+	return fn(o, obj)
+}
+
+// clsFlags returns the flags of a classifier.
+func clsFlags(o *options) *uint32 {
+	var flags uint32
+	if o.flag("skip_hw") {
+		flags |= clsFlagsSkipHw
+	}
+	if o.flag("skip_sw") {
+		flags |= clsFlagsSkipSw
+	}
+	if flags == 0 {
+		return nil
+	}
+	return &flags
+}
+
+// parseU32Handle implements iproute2/tc/f_u32.c:get_u32_handle().
+func parseU32Handle(s string) (uint32, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("u32 handle %q: %w", s, tc.ErrInvalidArg)
+	}
+	limits := []uint64{0xFFF, 0xFF, 0xFFF}
+	shifts := []uint{20, 12, 0}
+	var h uint32
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		v, err := strconv.ParseUint(part, 16, 32)
+		if err != nil || v > limits[i] {
+			return 0, fmt.Errorf("u32 handle %q: %w", s, tc.ErrInvalidArg)
+		}
+		h |= uint32(v) << shifts[i]
+	}
+	return h, nil
+}
+
+func u32Filter(o *options, obj *tc.Object) error {
+	// the node id is part of the handle
+	o.ignore("order")
+
+	u32 := &tc.U32{}
+	if fh := o.string("fh"); fh != nil {
+		h, err := parseU32Handle(*fh)
+		if err != nil {
+			return err
+		}
+		obj.Handle = h
+	}
+	u32.Divisor = o.uint32("ht_divisor")
+	if keyHt := o.hex("key_ht"); keyHt != nil {
+		u32.Hash = uint32Ptr(*keyHt<<20 | deref(o.hex("bkt"))<<12)
+	}
+	if link := o.string("link"); link != nil {
+		h, err := parseU32Handle(*link)
+		if err != nil {
+			return err
+		}
+		u32.Link = &h
+	}
+	u32.ClassID = o.handle("flowid")
+	u32.InDev = o.string("input_dev")
+	u32.Flags = clsFlags(o)
+
+	var keys []tc.U32Key
+	o.each("match", func(value json.RawMessage) error {
+		var match struct {
+			Value   hex    `json:"value"`
+			Mask    hex    `json:"mask"`
+			Offmask string `json:"offmask"`
+			Off     int32  `json:"off"`
+		}
+		if err := json.Unmarshal(value, &match); err != nil {
+			return err
+		}
+		if match.Offmask != "" {
+			return fmt.Errorf("u32: offset relative to the next header: %w", tc.ErrNotImplemented)
+		}
+		keys = append(keys, tc.U32Key{
+			Val:  htonl(uint32(match.Value)),
+			Mask: htonl(uint32(match.Mask)),
+			Off:  uint32(match.Off),
+		})
+		return nil
+	})
+	if u32.Divisor == nil {
+		// Every filter, that is not a hash table, has a selector.
+		u32.Sel = &tc.U32Sel{NKeys: uint8(len(keys)), Keys: keys}
+		if u32.ClassID != nil {
+			u32.Sel.Flags = tc.U32Terminal
+		}
+	}
+	obj.U32 = u32
+	return nil
+}
+
+func matchallFilter(o *options, obj *tc.Object) error {
+	if h := o.uint32("handle"); h != nil {
+		obj.Handle = *h
+	}
+	obj.Matchall = &tc.Matchall{
+		ClassID: o.handle("flowid"),
+		Flags:   clsFlags(o),
+	}
+	return nil
+}
+
+func flowerFilter(o *options, obj *tc.Object) error {
+	if h := o.uint32("handle"); h != nil {
+		obj.Handle = *h
+	}
+	flower := &tc.Flower{
+		ClassID: o.handle("classid"),
+		Indev:   o.string("indev"),
+		Flags:   clsFlags(o),
+	}
+	obj.Flower = flower
+
+	var data json.RawMessage
+	if !o.get("keys", &data) {
+		return nil
+	}
+	keys, err := parseOptions(data)
+	if err != nil {
+		return err
+	}
+	if err := flowerKeys(keys, flower); err != nil {
+		return err
+	}
+	if err := keys.Err(); err != nil {
+		return err
+	}
+	if unused := keys.unused(); len(unused) != 0 {
+		return fmt.Errorf("flower: keys %s: %w", strings.Join(unused, ", "), tc.ErrNotImplemented)
+	}
+	return nil
+}
+
+func flowerKeys(keys *options, flower *tc.Flower) error {
+	var err error
+	if ethType := keys.string("eth_type"); ethType != nil {
+		proto, err := parseEthProtocol(*ethType)
+		if err != nil {
+			return err
+		}
+		flower.KeyEthType = &proto
+	}
+	if ipProto := keys.string("ip_proto"); ipProto != nil {
+		proto, ok := ipProtocols[*ipProto]
+		if !ok {
+			v, err := strconv.ParseUint(*ipProto, 16, 8)
+			if err != nil {
+				return fmt.Errorf("flower: ip_proto %q: %w", *ipProto, tc.ErrInvalidArg)
+			}
+			proto = uint8(v)
+		}
+		flower.KeyIPProto = &proto
+	}
+	if flower.KeyEthDst, flower.KeyEthDstMask, err = flowerMac(keys, "dst_mac"); err != nil {
+		return err
+	}
+	if flower.KeyEthSrc, flower.KeyEthSrcMask, err = flowerMac(keys, "src_mac"); err != nil {
+		return err
+	}
+	if flower.KeyIPv4Dst, flower.KeyIPv4DstMask, err = flowerIP(keys, "dst_ip"); err != nil {
+		return err
+	}
+	if flower.KeyIPv4Src, flower.KeyIPv4SrcMask, err = flowerIP(keys, "src_ip"); err != nil {
+		return err
+	}
+
+	for _, port := range []struct {
+		key            string
+		tcp, udp, sctp **uint16
+	}{
+		{"dst_port", &flower.KeyTCPDst, &flower.KeyUDPDst, &flower.KeySctpDst},
+		{"src_port", &flower.KeyTCPSrc, &flower.KeyUDPSrc, &flower.KeySctpSrc},
+	} {
+		var data json.RawMessage
+		if !keys.get(port.key, &data) {
+			continue
+		}
+		if s, ok := unquote(data); ok && strings.Contains(s, "-") {
+			return fmt.Errorf("flower: %s range %s: %w", port.key, s, tc.ErrNotImplemented)
+		}
+		var value number
+		if err := json.Unmarshal(data, &value); err != nil {
+			return fmt.Errorf("flower: %s: %w", port.key, err)
+		}
+		if value > 0xFFFF {
+			return fmt.Errorf("flower: %s %d: %w", port.key, value, tc.ErrInvalidArg)
+		}
+		v := uint16(value)
+		var proto uint8
+		if flower.KeyIPProto != nil {
+			proto = *flower.KeyIPProto
+		}
+		switch proto {
+		case ipProtocols["tcp"]:
+			*port.tcp = &v
+		case ipProtocols["udp"]:
+			*port.udp = &v
+		case ipProtocols["sctp"]:
+			*port.sctp = &v
+		default:
+			return fmt.Errorf("flower: %s for ip_proto %d: %w", port.key, proto, tc.ErrNotImplemented)
+		}
+	}
+
+	if vlanID := keys.uint32("vlan_id"); vlanID != nil {
+		id := uint16(*vlanID)
+		flower.KeyVlanID = &id
+	}
+	if vlanPrio := keys.uint32("vlan_prio"); vlanPrio != nil {
+		prio := uint8(*vlanPrio)
+		flower.KeyVlanPrio = &prio
+	}
+	if ethType := keys.string("vlan_ethtype"); ethType != nil {
+		proto, err := parseEthProtocol(*ethType)
+		if err != nil {
+			return err
+		}
+		flower.KeyVlanEthType = &proto
+	}
+	return nil
+}
+
+// flowerIP parses an IPv4 address with optional prefix length. As tc, it
+// returns a full mask for addresses without prefix length.
+func flowerIP(keys *options, key string) (*net.IP, *net.IP, error) {
+	s := keys.string(key)
+	if s == nil {
+		return nil, nil, nil
+	}
+	addr := *s
+	if !strings.Contains(addr, "/") {
+		addr += "/32"
+	}
+	ip, ipNet, err := net.ParseCIDR(addr)
+	if err != nil || ip.To4() == nil {
+		return nil, nil, fmt.Errorf("flower: %s %q: %w", key, *s, tc.ErrNotImplemented)
+	}
+	ip = ip.To4()
+	mask := net.IP(ipNet.Mask)
+	return &ip, &mask, nil
+}
+
+// flowerMac parses a MAC address with optional mask or prefix length. As tc,
+// it returns a full mask for addresses without mask.
+func flowerMac(keys *options, key string) (*net.HardwareAddr, *net.HardwareAddr, error) {
+	s := keys.string(key)
+	if s == nil {
+		return nil, nil, nil
+	}
+	addr, maskStr := *s, ""
+	if i := strings.IndexByte(addr, '/'); i >= 0 {
+		addr, maskStr = addr[:i], addr[i+1:]
+	}
+	mac, err := net.ParseMAC(addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("flower: %s %q: %w", key, *s, tc.ErrInvalidArg)
+	}
+	mask := net.HardwareAddr{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+	switch {
+	case maskStr == "":
+	case strings.Contains(maskStr, ":"):
+		if mask, err = net.ParseMAC(maskStr); err != nil {
+			return nil, nil, fmt.Errorf("flower: %s %q: %w", key, *s, tc.ErrInvalidArg)
+		}
+	default:
+		bits, err := strconv.Atoi(maskStr)
+		if err != nil || bits < 0 || bits > 48 {
+			return nil, nil, fmt.Errorf("flower: %s %q: %w", key, *s, tc.ErrInvalidArg)
+		}
+		mask = net.HardwareAddr(net.CIDRMask(bits, 48))
+	}
+	return &mac, &mask, nil
+}
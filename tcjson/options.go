@@ -0,0 +1,339 @@
+package tcjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	tc "github.com/florianl/go-tc"
+	"github.com/florianl/go-tc/core"
+)
+
+// options contains the members of a JSON object in the order of the input.
+// iproute2 emits some members, like the keys of a u32 selector, multiple times.
+// Members are marked as used when they are read, so the remaining members can
+// be reported. The first error is kept and returned by Err.
+type options struct {
+	keys   []string
+	values []json.RawMessage
+	used   []bool
+	err    error
+}
+
+func parseOptions(data json.RawMessage) (*options, error) {
+	o := &options{}
+	if len(data) == 0 {
+		return o, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("options are not an object: %w", tc.ErrInvalidArg)
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected token %v: %w", tok, tc.ErrInvalidArg)
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		o.keys = append(o.keys, key)
+		o.values = append(o.values, value)
+		o.used = append(o.used, false)
+	}
+	return o, nil
+}
+
+// Err returns the first error, that occurred while reading a member.
+func (o *options) Err() error {
+	return o.err
+}
+
+// get decodes the first unused member key into v and reports whether it was present.
+func (o *options) get(key string, v interface{}) bool {
+	for i := range o.keys {
+		if o.used[i] || o.keys[i] != key {
+			continue
+		}
+		o.used[i] = true
+		if o.err != nil {
+			return false
+		}
+		if err := json.Unmarshal(o.values[i], v); err != nil {
+			o.err = fmt.Errorf("%s: %w", key, err)
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// each calls fn for every unused member key.
+func (o *options) each(key string, fn func(value json.RawMessage) error) {
+	for i := range o.keys {
+		if o.used[i] || o.keys[i] != key {
+			continue
+		}
+		o.used[i] = true
+		if o.err != nil {
+			continue
+		}
+		if err := fn(o.values[i]); err != nil {
+			o.err = fmt.Errorf("%s: %w", key, err)
+		}
+	}
+}
+
+// ignore marks members as used, that are statistics or derived from other members.
+func (o *options) ignore(keys ...string) {
+	for _, key := range keys {
+		for i := range o.keys {
+			if o.keys[i] == key {
+				o.used[i] = true
+			}
+		}
+	}
+}
+
+// unused returns the keys of all members, that were not read.
+func (o *options) unused() []string {
+	var keys []string
+	for i, used := range o.used {
+		if !used {
+			keys = append(keys, o.keys[i])
+		}
+	}
+	return keys
+}
+
+func (o *options) uint32(key string) *uint32 {
+	var v number
+	if !o.get(key, &v) {
+		return nil
+	}
+	if v > math.MaxUint32 {
+		o.err = fmt.Errorf("%s: %d overflows: %w", key, v, tc.ErrInvalidArg)
+		return nil
+	}
+	return uint32Ptr(uint32(v))
+}
+
+func (o *options) hex(key string) *uint32 {
+	var v hex
+	if !o.get(key, &v) {
+		return nil
+	}
+	return uint32Ptr(uint32(v))
+}
+
+func (o *options) size(key string) *uint32 {
+	var v size
+	if !o.get(key, &v) {
+		return nil
+	}
+	return uint32Ptr(uint32(v))
+}
+
+func (o *options) time(key string) *uint32 {
+	var v duration
+	if !o.get(key, &v) {
+		return nil
+	}
+	return uint32Ptr(uint32(v))
+}
+
+func (o *options) rate(key string) *uint64 {
+	var v rate
+	if !o.get(key, &v) {
+		return nil
+	}
+	return uint64Ptr(uint64(v))
+}
+
+func (o *options) handle(key string) *uint32 {
+	var v handle
+	if !o.get(key, &v) {
+		return nil
+	}
+	return uint32Ptr(uint32(v))
+}
+
+func (o *options) string(key string) *string {
+	var v string
+	if !o.get(key, &v) {
+		return nil
+	}
+	return &v
+}
+
+func (o *options) flag(key string) bool {
+	var v bool
+	return o.get(key, &v) && v
+}
+
+// boolean returns the member key as 1 or 0.
+func (o *options) boolean(key string) *uint32 {
+	var v bool
+	if !o.get(key, &v) {
+		return nil
+	}
+	if v {
+		return uint32Ptr(1)
+	}
+	return uint32Ptr(0)
+}
+
+// unquote returns the content of data, if it is a JSON string.
+func unquote(data []byte) (string, bool) {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return "", false
+	}
+	return s, true
+}
+
+// number is an unsigned integer, that iproute2 prints as JSON number or as
+// decimal or hexadecimal string.
+type number uint64
+
+func (n *number) UnmarshalJSON(data []byte) error {
+	s, ok := unquote(data)
+	if !ok {
+		return json.Unmarshal(data, (*uint64)(n))
+	}
+	v, err := strconv.ParseUint(s, 0, 64)
+	if err != nil {
+		return fmt.Errorf("%q is not a number: %w", s, tc.ErrInvalidArg)
+	}
+	*n = number(v)
+	return nil
+}
+
+// hex is an unsigned integer, that iproute2 prints as hexadecimal string
+// with or without 0x prefix.
+type hex uint32
+
+func (h *hex) UnmarshalJSON(data []byte) error {
+	s, ok := unquote(data)
+	if !ok {
+		return json.Unmarshal(data, (*uint32)(h))
+	}
+	v, err := strconv.ParseUint(strings.TrimPrefix(s, "0x"), 16, 32)
+	if err != nil {
+		return fmt.Errorf("%q is not a hexadecimal number: %w", s, tc.ErrInvalidArg)
+	}
+	*h = hex(v)
+	return nil
+}
+
+// rate is a rate in bytes per second. Strings like "10Mbit" are converted.
+type rate uint64
+
+func (r *rate) UnmarshalJSON(data []byte) error {
+	s, ok := unquote(data)
+	if !ok {
+		return json.Unmarshal(data, (*uint64)(r))
+	}
+	v, err := tc.ParseRate(s)
+	if err != nil {
+		return err
+	}
+	*r = rate(v)
+	return nil
+}
+
+// size is a size in bytes. Strings like "32Kb" are converted.
+type size uint32
+
+func (sz *size) UnmarshalJSON(data []byte) error {
+	s, ok := unquote(data)
+	if !ok {
+		return json.Unmarshal(data, (*uint32)(sz))
+	}
+	v, err := tc.ParseSize(s)
+	if err != nil {
+		return err
+	}
+	*sz = size(v)
+	return nil
+}
+
+// duration is a time in microseconds. Strings like "5ms" are converted.
+type duration uint32
+
+// timeSuffixes from iproute2/tc/tc_util.c:get_time(). The scale converts the value into microseconds.
+var timeSuffixes = map[string]float64{
+	"":      1,
+	"s":     1000000,
+	"sec":   1000000,
+	"secs":  1000000,
+	"ms":    1000,
+	"msec":  1000,
+	"msecs": 1000,
+	"us":    1,
+	"usec":  1,
+	"usecs": 1,
+}
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	s, ok := unquote(data)
+	if !ok {
+		return json.Unmarshal(data, (*uint32)(d))
+	}
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if end < 0 {
+		end = len(s)
+	}
+	v, err := strconv.ParseFloat(s[:end], 64)
+	if err != nil {
+		return fmt.Errorf("%q is not a time: %w", s, tc.ErrInvalidArg)
+	}
+	scale, ok := timeSuffixes[strings.ToLower(s[end:])]
+	if !ok {
+		return fmt.Errorf("unknown time suffix %q: %w", s[end:], tc.ErrInvalidArg)
+	}
+	v *= scale
+	if v > math.MaxUint32 {
+		return fmt.Errorf("time %q overflows: %w", s, tc.ErrInvalidArg)
+	}
+	*d = duration(math.Round(v))
+	return nil
+}
+
+// handle is a handle in the form of "1:10".
+type handle uint32
+
+func (h *handle) UnmarshalJSON(data []byte) error {
+	s, ok := unquote(data)
+	if !ok {
+		return fmt.Errorf("handle %s is not a string: %w", data, tc.ErrInvalidArg)
+	}
+	v, err := core.ParseHandle(s)
+	if err != nil {
+		return fmt.Errorf("handle %q: %w", s, err)
+	}
+	*h = handle(v)
+	return nil
+}
+
+func uint32Ptr(v uint32) *uint32 {
+	return &v
+}
+
+func uint64Ptr(v uint64) *uint64 {
+	return &v
+}
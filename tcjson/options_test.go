@@ -0,0 +1,141 @@
+package tcjson
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	tc "github.com/florianl/go-tc"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestValues(t *testing.T) {
+	tests := map[string]struct {
+		data string
+		val  interface{}
+		want interface{}
+		err  error
+	}{
+		"number":            {data: `42`, val: new(number), want: number(42)},
+		"number string":     {data: `"42"`, val: new(number), want: number(42)},
+		"number hex string": {data: `"0x2a"`, val: new(number), want: number(42)},
+		"number invalid":    {data: `"forty"`, val: new(number), err: tc.ErrInvalidArg},
+		"hex":               {data: `"a000001"`, val: new(hex), want: hex(0xa000001)},
+		"hex prefix":        {data: `"0x30"`, val: new(hex), want: hex(0x30)},
+		"rate":              {data: `1250000`, val: new(rate), want: rate(1250000)},
+		"rate string":       {data: `"10Mbit"`, val: new(rate), want: rate(1250000)},
+		"rate invalid":      {data: `"10Mbeer"`, val: new(rate), err: tc.ErrInvalidArg},
+		"size":              {data: `1514`, val: new(size), want: size(1514)},
+		"size string":       {data: `"32Kb"`, val: new(size), want: size(32768)},
+		"time":              {data: `4999`, val: new(duration), want: duration(4999)},
+		"time ms":           {data: `"5.0ms"`, val: new(duration), want: duration(5000)},
+		"time s":            {data: `"1s"`, val: new(duration), want: duration(1000000)},
+		"time us":           {data: `"100us"`, val: new(duration), want: duration(100)},
+		"time invalid":      {data: `"5h"`, val: new(duration), err: tc.ErrInvalidArg},
+		"handle":            {data: `"1:10"`, val: new(handle), want: handle(0x10010)},
+		"handle root":       {data: `"root"`, val: new(handle), want: handle(tc.HandleRoot)},
+		"handle number":     {data: `1`, val: new(handle), err: tc.ErrInvalidArg},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := json.Unmarshal([]byte(testcase.data), testcase.val)
+			if testcase.err != nil {
+				if !errors.Is(err, testcase.err) {
+					t.Fatalf("expected error %v but got %v", testcase.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got interface{}
+			switch v := testcase.val.(type) {
+			case *number:
+				got = *v
+			case *hex:
+				got = *v
+			case *rate:
+				got = *v
+			case *size:
+				got = *v
+			case *duration:
+				got = *v
+			case *handle:
+				got = *v
+			}
+			if diff := cmp.Diff(testcase.want, got); diff != "" {
+				t.Fatalf("value missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestOptions(t *testing.T) {
+	o, err := parseOptions(json.RawMessage(`{"match":{"off":1},"limit":10,"match":{"off":2},"stats":1}`))
+	if err != nil {
+		t.Fatalf("could not parse options: %v", err)
+	}
+	if limit := o.uint32("limit"); limit == nil || *limit != 10 {
+		t.Fatalf("expected limit 10 but got %v", limit)
+	}
+	if missing := o.uint32("missing"); missing != nil {
+		t.Fatalf("expected no value for missing member but got %d", *missing)
+	}
+	var offs []int
+	o.each("match", func(value json.RawMessage) error {
+		var match struct {
+			Off int `json:"off"`
+		}
+		err := json.Unmarshal(value, &match)
+		offs = append(offs, match.Off)
+		return err
+	})
+	if diff := cmp.Diff([]int{1, 2}, offs); diff != "" {
+		t.Fatalf("matches missmatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"stats"}, o.unused()); diff != "" {
+		t.Fatalf("unused members missmatch (-want +got):\n%s", diff)
+	}
+	// stats is not a boolean
+	if o.flag("stats"); o.Err() == nil {
+		t.Fatalf("expected error for non-boolean member")
+	}
+
+	if _, err := parseOptions(json.RawMessage(`[1, 2]`)); !errors.Is(err, tc.ErrInvalidArg) {
+		t.Fatalf("expected ErrInvalidArg for array but got %v", err)
+	}
+}
+
+func TestParseU32Handle(t *testing.T) {
+	tests := map[string]struct {
+		handle string
+		want   uint32
+		err    error
+	}{
+		"table":        {handle: "800:", want: 0x80000000},
+		"node":         {handle: "800::800", want: 0x80000800},
+		"bucket":       {handle: "1:2:3", want: 0x00102003},
+		"hash":         {handle: "1:ff", want: 0x001FF000},
+		"too long":     {handle: "1:2:3:4", err: tc.ErrInvalidArg},
+		"out of range": {handle: "1000:", err: tc.ErrInvalidArg},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseU32Handle(testcase.handle)
+			if testcase.err != nil {
+				if !errors.Is(err, testcase.err) {
+					t.Fatalf("expected error %v but got %v", testcase.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != testcase.want {
+				t.Fatalf("expected %#x but got %#x", testcase.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package tcjson
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	tc "github.com/florianl/go-tc"
+	"github.com/florianl/go-tc/core"
+)
+
+// qdiscKinds contains the supported kinds of qdiscs.
+var qdiscKinds = map[string]func(o *options, attr *tc.Attribute) error{
+	"bfifo":      bfifoQdisc,
+	"clsact":     parameterless,
+	"codel":      codelQdisc,
+	"drr":        drrQdisc,
+	"fq_codel":   fqCodelQdisc,
+	"htb":        htbQdisc,
+	"ingress":    parameterless,
+	"mq":         parameterless,
+	"netem":      netemQdisc,
+	"noqueue":    parameterless,
+	"pfifo":      pfifoQdisc,
+	"pfifo_fast": prioQdisc,
+	"pie":        pieQdisc,
+	"prio":       prioQdisc,
+	"qfq":        parameterless,
+	"sfq":        sfqQdisc,
+	"tbf":        tbfQdisc,
+}
+
+func convertQdisc(e *entry, o *options, obj *tc.Object) error {
+	fn, ok := qdiscKinds[obj.Kind]
+	if !ok {
+		return fmt.Errorf("qdisc %s: %w", obj.Kind, tc.ErrNotImplemented)
+	}
+	obj.IngressBlock = e.IngressBlock
+	obj.EgressBlock = e.EgressBlock
+	return fn(o, &obj.Attribute)
+}
+
+func parameterless(o *options, attr *tc.Attribute) error {
+	return nil
+}
+
+func pfifoQdisc(o *options, attr *tc.Attribute) error {
+	attr.Pfifo = &tc.FifoOpt{Limit: deref(o.uint32("limit"))}
+	return nil
+}
+
+func bfifoQdisc(o *options, attr *tc.Attribute) error {
+	attr.Bfifo = &tc.FifoOpt{Limit: deref(o.size("limit"))}
+	return nil
+}
+
+func drrQdisc(o *options, attr *tc.Attribute) error {
+	attr.Drr = &tc.Drr{}
+	return nil
+}
+
+func fqCodelQdisc(o *options, attr *tc.Attribute) error {
+	attr.FqCodel = &tc.FqCodel{
+		Limit:         o.uint32("limit"),
+		Flows:         o.uint32("flows"),
+		Quantum:       o.uint32("quantum"),
+		Target:        o.time("target"),
+		Interval:      o.time("interval"),
+		CEThreshold:   o.time("ce_threshold"),
+		MemoryLimit:   o.size("memory_limit"),
+		DropBatchSize: o.uint32("drop_batch"),
+		ECN:           o.boolean("ecn"),
+	}
+	return nil
+}
+
+func codelQdisc(o *options, attr *tc.Attribute) error {
+	attr.Codel = &tc.Codel{
+		Limit:       o.uint32("limit"),
+		Target:      o.time("target"),
+		Interval:    o.time("interval"),
+		CEThreshold: o.time("ce_threshold"),
+		ECN:         o.boolean("ecn"),
+	}
+	return nil
+}
+
+func pieQdisc(o *options, attr *tc.Attribute) error {
+	attr.Pie = &tc.Pie{
+		Limit:           o.uint32("limit"),
+		Target:          o.time("target"),
+		TUpdate:         o.time("tupdate"),
+		Alpha:           o.uint32("alpha"),
+		Beta:            o.uint32("beta"),
+		ECN:             o.boolean("ecn"),
+		Bytemode:        o.boolean("bytemode"),
+		DqRateEstimator: o.boolean("dq_rate_estimator"),
+	}
+	return nil
+}
+
+func htbQdisc(o *options, attr *tc.Attribute) error {
+	// version and direct_packets_stat are reported by the kernel
+	o.ignore("version", "direct_packets_stat")
+
+	init := &tc.HtbGlob{Version: 3, Rate2Quantum: 10}
+	if r2q := o.uint32("r2q"); r2q != nil {
+		init.Rate2Quantum = *r2q
+	}
+	init.Defcls = deref(o.hex("default"))
+	attr.Htb = &tc.Htb{
+		Init:       init,
+		DirectQlen: o.uint32("direct_qlen"),
+	}
+	if o.flag("offload") {
+		offload := true
+		attr.Htb.Offload = &offload
+	}
+	return nil
+}
+
+func tbfQdisc(o *options, attr *tc.Attribute) error {
+	// the latency is an alternative representation of the limit
+	o.ignore("lat")
+
+	rate := o.rate("rate")
+	if rate == nil {
+		return fmt.Errorf("tbf: rate is required: %w", tc.ErrNoArg)
+	}
+	if *rate > math.MaxUint32 {
+		return fmt.Errorf("tbf: rate %d exceeds 32 bits: %w", *rate, tc.ErrNotImplemented)
+	}
+	spec, err := rateSpec(*rate)
+	if err != nil {
+		return err
+	}
+	qopt := &tc.TbfQopt{
+		Rate:   spec,
+		Limit:  deref(o.size("limit")),
+		Buffer: core.XmitTime(*rate, deref(o.size("burst"))),
+	}
+	mtu := o.size("mtu")
+	if peakrate := o.rate("peakrate"); peakrate != nil {
+		if *peakrate > math.MaxUint32 {
+			return fmt.Errorf("tbf: peakrate %d exceeds 32 bits: %w", *peakrate, tc.ErrNotImplemented)
+		}
+		if qopt.PeakRate, err = rateSpec(*peakrate); err != nil {
+			return err
+		}
+		qopt.Mtu = core.XmitTime(*peakrate, deref(mtu))
+	}
+	attr.Tbf = &tc.Tbf{Parms: qopt}
+	return nil
+}
+
+func netemQdisc(o *options, attr *tc.Attribute) error {
+	// the seed of the random number generator is chosen by the kernel
+	o.ignore("seed")
+
+	netem := &tc.Netem{}
+	limit := deref(o.uint32("limit"))
+
+	var delay struct {
+		Delay       float64 `json:"delay"`
+		Jitter      float64 `json:"jitter"`
+		Correlation float64 `json:"correlation"`
+	}
+	o.get("delay", &delay)
+	if delay.Correlation != 0 {
+		return fmt.Errorf("netem: delay correlation: %w", tc.ErrNotImplemented)
+	}
+	latency := time.Duration(math.Round(delay.Delay * float64(time.Second)))
+	jitter := time.Duration(math.Round(delay.Jitter * float64(time.Second)))
+	qopt, err := tc.NetemQoptFromDurations(latency, jitter, limit)
+	if err != nil {
+		return err
+	}
+	if latency != 0 || jitter != 0 {
+		latency64, jitter64 := latency.Nanoseconds(), jitter.Nanoseconds()
+		netem.Latency64 = &latency64
+		netem.Jitter64 = &jitter64
+	}
+
+	// probabilities are printed as fractions
+	var loss struct {
+		Loss        float64 `json:"loss"`
+		Correlation float64 `json:"correlation"`
+	}
+	if o.get("loss-random", &loss) {
+		if loss.Correlation != 0 {
+			return fmt.Errorf("netem: loss correlation: %w", tc.ErrNotImplemented)
+		}
+		if err := qopt.SetLossPercent(loss.Loss * 100); err != nil {
+			return err
+		}
+	}
+	var duplicate struct {
+		Duplicate   float64 `json:"duplicate"`
+		Correlation float64 `json:"correlation"`
+	}
+	if o.get("duplicate", &duplicate) {
+		if duplicate.Correlation != 0 {
+			return fmt.Errorf("netem: duplicate correlation: %w", tc.ErrNotImplemented)
+		}
+		if err := qopt.SetDuplicatePercent(duplicate.Duplicate * 100); err != nil {
+			return err
+		}
+	}
+	netem.Qopt = qopt
+
+	if o.flag("ecn") {
+		netem.Ecn = uint32Ptr(1)
+	}
+
+	var netemRate struct {
+		Rate           rate  `json:"rate"`
+		PacketOverhead int32 `json:"packetoverhead"`
+		CellSize       int32 `json:"cellsize"`
+		CellOverhead   int32 `json:"celloverhead"`
+	}
+	if o.get("rate", &netemRate) {
+		netem.Rate = &tc.NetemRate{
+			Rate:           math.MaxUint32,
+			PacketOverhead: netemRate.PacketOverhead,
+			CellSize:       netemRate.CellSize,
+			CellOverhead:   netemRate.CellOverhead,
+		}
+		if netemRate.Rate < math.MaxUint32 {
+			netem.Rate.Rate = uint32(netemRate.Rate)
+		} else {
+			netem.Rate64 = uint64Ptr(uint64(netemRate.Rate))
+		}
+	}
+
+	attr.Netem = netem
+	return nil
+}
+
+func sfqQdisc(o *options, attr *tc.Attribute) error {
+	sfq := &tc.Sfq{}
+	sfq.V0.Limit = deref(o.uint32("limit"))
+	sfq.V0.Quantum = deref(o.size("quantum"))
+	sfq.V0.Divisor = deref(o.uint32("divisor"))
+	sfq.V0.Flows = deref(o.uint32("flows"))
+	sfq.V0.PerturbPeriod = int32(deref(o.uint32("perturb")))
+	sfq.Depth = deref(o.uint32("depth"))
+	if o.flag("headdrop") {
+		sfq.Headdrop = 1
+	}
+	attr.Sfq = sfq
+	return nil
+}
+
+func prioQdisc(o *options, attr *tc.Attribute) error {
+	if o.flag("multiqueue") {
+		return fmt.Errorf("%s: multiqueue: %w", attr.Kind, tc.ErrNotImplemented)
+	}
+	prio := &tc.Prio{Bands: deref(o.uint32("bands"))}
+	o.get("priomap", &prio.PrioMap)
+	attr.Prio = prio
+	return nil
+}
@@ -0,0 +1,212 @@
+/*
+Package tcjson imports the JSON output of iproute2, as it is printed by
+`tc -j qdisc show`, `tc -j class show` and `tc -j filter show`, into tc.Object.
+
+Rates, sizes and times are accepted both as numbers, as printed by recent
+versions of iproute2, and as strings with units like "10Mbit", as printed by
+older versions.
+Entries, that can not be imported completely, for example because of an
+unsupported kind or option, are skipped and reported as Warning.
+*/
+package tcjson
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	tc "github.com/florianl/go-tc"
+	"github.com/florianl/go-tc/core"
+	"github.com/josharian/native"
+)
+
+// Config contains options for the import.
+type Config struct {
+	// Ifindex is used for entries without "dev", like the output of
+	// `tc -j qdisc show dev eth0`.
+	Ifindex uint32
+
+	// Block is used for entries without "dev" and "block", like the output
+	// of `tc -j filter show block 22`. The filters are imported with
+	// tc.MagicBlock as Ifindex and the index of the block as Parent.
+	Block uint32
+
+	// InterfaceByName resolves the "dev" of an entry to an interface index.
+	// If it is not set, net.InterfaceByName() is used.
+	InterfaceByName func(name string) (uint32, error)
+}
+
+// Warning describes an entry of the input, that was skipped.
+type Warning struct {
+	// Index is the position of the entry in the JSON array.
+	Index  int
+	Kind   string
+	Reason string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("entry %d (%s): %s", w.Index, w.Kind, w.Reason)
+}
+
+// entry contains the members of qdiscs, classes and filters, that are
+// common to all kinds.
+type entry struct {
+	Kind         string          `json:"kind"`
+	Class        string          `json:"class"`
+	Dev          string          `json:"dev"`
+	Block        *uint32         `json:"block"`
+	Handle       *handle         `json:"handle"`
+	Parent       *handle         `json:"parent"`
+	Root         bool            `json:"root"`
+	Leaf         *handle         `json:"leaf"`
+	Protocol     string          `json:"protocol"`
+	Pref         uint32          `json:"pref"`
+	Chain        *uint32         `json:"chain"`
+	IngressBlock *uint32         `json:"ingress_block"`
+	EgressBlock  *uint32         `json:"egress_block"`
+	Options      json.RawMessage `json:"options"`
+}
+
+// errSkip is returned by converters for entries, that do not represent an object.
+var errSkip = errors.New("skip entry")
+
+type converter func(e *entry, o *options, obj *tc.Object) error
+
+// Qdiscs converts the output of `tc -j qdisc show` into qdiscs.
+func Qdiscs(data []byte, config *Config) ([]tc.Object, []Warning, error) {
+	return convert(data, config, convertQdisc)
+}
+
+// Classes converts the output of `tc -j class show` into classes.
+func Classes(data []byte, config *Config) ([]tc.Object, []Warning, error) {
+	return convert(data, config, convertClass)
+}
+
+// Filters converts the output of `tc -j filter show` into filters.
+// Entries, that only list the priority of following filters, are skipped
+// without warning.
+func Filters(data []byte, config *Config) ([]tc.Object, []Warning, error) {
+	return convert(data, config, convertFilter)
+}
+
+func convert(data []byte, config *Config, fn converter) ([]tc.Object, []Warning, error) {
+	if config == nil {
+		config = &Config{}
+	}
+	var entries []entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, nil, err
+	}
+
+	var objs []tc.Object
+	var warnings []Warning
+	for i := range entries {
+		e := &entries[i]
+		kind := e.Kind
+		if kind == "" {
+			kind = e.Class
+		}
+
+		block := e.Block
+		if block == nil && e.Dev == "" && config.Block != 0 {
+			block = &config.Block
+		}
+		ifindex := uint32(tc.MagicBlock)
+		if block == nil {
+			var err error
+			if ifindex, err = config.ifindex(e.Dev); err != nil {
+				return nil, nil, fmt.Errorf("entry %d: %w", i, err)
+			}
+		}
+		o, err := parseOptions(e.Options)
+		if err != nil {
+			return nil, nil, fmt.Errorf("entry %d: %w", i, err)
+		}
+
+		obj := tc.Object{
+			Msg:       tc.Msg{Ifindex: ifindex},
+			Attribute: tc.Attribute{Kind: kind},
+		}
+		if e.Handle != nil {
+			obj.Handle = uint32(*e.Handle)
+		}
+		switch {
+		case block != nil:
+			obj.Parent = *block
+		case e.Root:
+			obj.Parent = tc.HandleRoot
+		case e.Parent != nil:
+			obj.Parent = uint32(*e.Parent)
+		}
+
+		err = fn(e, o, &obj)
+		if err == nil {
+			err = o.Err()
+		}
+		switch {
+		case errors.Is(err, errSkip):
+			continue
+		case errors.Is(err, tc.ErrNotImplemented):
+			warnings = append(warnings, Warning{Index: i, Kind: kind, Reason: err.Error()})
+			continue
+		case err != nil:
+			return nil, nil, fmt.Errorf("entry %d: %w", i, err)
+		}
+		if unused := o.unused(); len(unused) != 0 {
+			warnings = append(warnings, Warning{Index: i, Kind: kind,
+				Reason: fmt.Sprintf("options %s are not supported", strings.Join(unused, ", "))})
+			continue
+		}
+		objs = append(objs, obj)
+	}
+	return objs, warnings, nil
+}
+
+func (c *Config) ifindex(dev string) (uint32, error) {
+	if dev == "" {
+		if c.Ifindex == 0 {
+			return 0, tc.ErrInvalidDev
+		}
+		return c.Ifindex, nil
+	}
+	if c.InterfaceByName != nil {
+		return c.InterfaceByName(dev)
+	}
+	iface, err := net.InterfaceByName(dev)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(iface.Index), nil
+}
+
+// parseEthProtocol converts the name or the numerical value of an ethernet protocol.
+func parseEthProtocol(s string) (uint16, error) {
+	proto, err := core.ParseProtocol(s)
+	if err != nil {
+		return 0, fmt.Errorf("protocol %q: %w", s, tc.ErrNotImplemented)
+	}
+	return proto, nil
+}
+
+// htonl converts v into network byte order and returns it in native byte order.
+func htonl(v uint32) uint32 {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return native.Endian.Uint32(b)
+}
+
+// rateSpec returns the RateSpec of rate in the same way tc does. Rates, that
+// exceed 32 bits, are capped and have to be passed as 64 bit values.
+func rateSpec(rate uint64) (tc.RateSpec, error) {
+	return tc.NewRateSpec(rate, 0, tc.LinklayerEthernet, 0, 0)
+}
+
+func deref(v *uint32) uint32 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
@@ -0,0 +1,375 @@
+package tcjson
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	tc "github.com/florianl/go-tc"
+	"github.com/florianl/go-tc/core"
+	"github.com/google/go-cmp/cmp"
+)
+
+func testConfig() *Config {
+	return &Config{
+		Ifindex: 2,
+		InterfaceByName: func(name string) (uint32, error) {
+			switch name {
+			case "eth0":
+				return 2, nil
+			case "eth1":
+				return 3, nil
+			}
+			return 0, fmt.Errorf("unknown interface %s", name)
+		},
+	}
+}
+
+func mustRateSpec(t *testing.T, rate uint64) tc.RateSpec {
+	t.Helper()
+	spec, err := rateSpec(rate)
+	if err != nil {
+		t.Fatalf("could not create rate spec: %v", err)
+	}
+	return spec
+}
+
+func TestQdiscs(t *testing.T) {
+	restore, err := core.SetClock(0x3e8, 0x40, 0xf4240)
+	if err != nil {
+		t.Fatalf("could not set clock: %v", err)
+	}
+	defer restore()
+
+	netemQopt, err := tc.NetemQoptFromDurations(100000000, 10000000, 1000)
+	if err != nil {
+		t.Fatalf("could not create netem options: %v", err)
+	}
+	if err := netemQopt.SetLossPercent(10); err != nil {
+		t.Fatalf("could not set loss: %v", err)
+	}
+
+	tests := map[string]struct {
+		data     string
+		want     []tc.Object
+		warnings []Warning
+		err      error
+	}{
+		"htb hierarchy": {
+			data: `[{"kind":"htb","handle":"1:","dev":"eth0","root":true,"refcnt":2,"options":{"r2q":10,"default":"0x30","direct_packets_stat":0,"direct_qlen":1000}},
+				{"kind":"fq_codel","handle":"10:","dev":"eth0","parent":"1:10","options":{"limit":10240,"flows":1024,"quantum":1514,"target":4999,"interval":99999,"memory_limit":33554432,"ecn":true,"drop_batch":64}},
+				{"kind":"sfq","handle":"30:","dev":"eth1","parent":"1:30","options":{"limit":127,"quantum":1514,"depth":127,"divisor":1024}}]`,
+			want: []tc.Object{
+				{Msg: tc.Msg{Ifindex: 2, Handle: 0x10000, Parent: tc.HandleRoot},
+					Attribute: tc.Attribute{Kind: "htb", Htb: &tc.Htb{
+						Init:       &tc.HtbGlob{Version: 3, Rate2Quantum: 10, Defcls: 0x30},
+						DirectQlen: uint32Ptr(1000)}}},
+				{Msg: tc.Msg{Ifindex: 2, Handle: 0x100000, Parent: 0x10010},
+					Attribute: tc.Attribute{Kind: "fq_codel", FqCodel: &tc.FqCodel{
+						Limit: uint32Ptr(10240), Flows: uint32Ptr(1024), Quantum: uint32Ptr(1514),
+						Target: uint32Ptr(4999), Interval: uint32Ptr(99999), MemoryLimit: uint32Ptr(33554432),
+						ECN: uint32Ptr(1), DropBatchSize: uint32Ptr(64)}}},
+				{Msg: tc.Msg{Ifindex: 3, Handle: 0x300000, Parent: 0x10030},
+					Attribute: tc.Attribute{Kind: "sfq", Sfq: &tc.Sfq{
+						V0:    tc.SfqQopt{Limit: 127, Quantum: 1514, Divisor: 1024},
+						Depth: 127}}},
+			},
+		},
+		"units as strings": {
+			data: `[{"kind":"codel","handle":"8001:","root":true,"options":{"limit":1000,"target":"5.0ms","interval":"100.0ms"}},
+				{"kind":"bfifo","handle":"8002:","parent":"1:20","options":{"limit":"10Kb"}},
+				{"kind":"tbf","handle":"8003:","root":true,"options":{"rate":"1Mbit","burst":"5000b","lat":"50ms","limit":"10000b"}}]`,
+			want: []tc.Object{
+				{Msg: tc.Msg{Ifindex: 2, Handle: 0x80010000, Parent: tc.HandleRoot},
+					Attribute: tc.Attribute{Kind: "codel", Codel: &tc.Codel{
+						Limit: uint32Ptr(1000), Target: uint32Ptr(5000), Interval: uint32Ptr(100000)}}},
+				{Msg: tc.Msg{Ifindex: 2, Handle: 0x80020000, Parent: 0x10020},
+					Attribute: tc.Attribute{Kind: "bfifo", Bfifo: &tc.FifoOpt{Limit: 10240}}},
+				{Msg: tc.Msg{Ifindex: 2, Handle: 0x80030000, Parent: tc.HandleRoot},
+					Attribute: tc.Attribute{Kind: "tbf", Tbf: &tc.Tbf{Parms: &tc.TbfQopt{
+						Rate:   mustRateSpec(t, 125000),
+						Limit:  10000,
+						Buffer: core.XmitTime(125000, 5000)}}}},
+			},
+		},
+		"netem": {
+			data: `[{"kind":"netem","handle":"1:","root":true,"options":{"limit":1000,"delay":{"delay":0.1,"jitter":0.01,"correlation":0},"loss-random":{"loss":0.1,"correlation":0},"ecn":false}}]`,
+			want: []tc.Object{
+				{Msg: tc.Msg{Ifindex: 2, Handle: 0x10000, Parent: tc.HandleRoot},
+					Attribute: tc.Attribute{Kind: "netem", Netem: &tc.Netem{
+						Qopt:      netemQopt,
+						Latency64: int64Ptr(100000000),
+						Jitter64:  int64Ptr(10000000)}}},
+			},
+		},
+		"prio and parameterless kinds": {
+			data: `[{"kind":"prio","handle":"1:","root":true,"options":{"bands":3,"priomap":[1,2,2,2,1,2,0,0,1,1,1,1,1,1,1,1],"multiqueue":false}},
+				{"kind":"clsact","handle":"ffff:","parent":"ffff:fff1"},
+				{"kind":"mq","handle":"0:","dev":"eth1","root":true}]`,
+			want: []tc.Object{
+				{Msg: tc.Msg{Ifindex: 2, Handle: 0x10000, Parent: tc.HandleRoot},
+					Attribute: tc.Attribute{Kind: "prio", Prio: &tc.Prio{Bands: 3, PrioMap: tc.DefaultPriomap()}}},
+				{Msg: tc.Msg{Ifindex: 2, Handle: 0xFFFF0000, Parent: tc.HandleIngress},
+					Attribute: tc.Attribute{Kind: "clsact"}},
+				{Msg: tc.Msg{Ifindex: 3, Parent: tc.HandleRoot},
+					Attribute: tc.Attribute{Kind: "mq"}},
+			},
+		},
+		"unsupported kinds and options": {
+			data: `[{"kind":"cake","handle":"1:","root":true,"options":{"bandwidth":"unlimited"}},
+				{"kind":"fq_codel","handle":"2:","root":true,"options":{"limit":10240,"new_option":1}},
+				{"kind":"netem","handle":"3:","root":true,"options":{"limit":1000,"delay":{"delay":0.1,"jitter":0.01,"correlation":0.25}}},
+				{"kind":"pfifo","handle":"4:","root":true,"options":{"limit":100}}]`,
+			want: []tc.Object{
+				{Msg: tc.Msg{Ifindex: 2, Handle: 0x40000, Parent: tc.HandleRoot},
+					Attribute: tc.Attribute{Kind: "pfifo", Pfifo: &tc.FifoOpt{Limit: 100}}},
+			},
+			warnings: []Warning{
+				{Index: 0, Kind: "cake", Reason: "qdisc cake: functionality not yet implemented"},
+				{Index: 1, Kind: "fq_codel", Reason: "options new_option are not supported"},
+				{Index: 2, Kind: "netem", Reason: "netem: delay correlation: functionality not yet implemented"},
+			},
+		},
+		"unknown interface": {
+			data: `[{"kind":"pfifo","handle":"4:","dev":"eth9","root":true,"options":{"limit":100}}]`,
+			err:  errors.New("entry 0: unknown interface eth9"),
+		},
+		"invalid time": {
+			data: `[{"kind":"codel","handle":"1:","root":true,"options":{"target":"5parsec"}}]`,
+			err:  tc.ErrInvalidArg,
+		},
+		"invalid json": {
+			data: `{"kind":"codel"}`,
+			err:  errors.New("json: cannot unmarshal object into Go value of type []tcjson.entry"),
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, warnings, err := Qdiscs([]byte(testcase.data), testConfig())
+			if testcase.err != nil {
+				if err == nil {
+					t.Fatalf("expected error %v", testcase.err)
+				}
+				if !errors.Is(err, testcase.err) && err.Error() != testcase.err.Error() {
+					t.Fatalf("expected error %v but got %v", testcase.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(testcase.want, got); diff != "" {
+				t.Fatalf("objects missmatch (-want +got):\n%s", diff)
+			}
+			if len(warnings) != 0 || len(testcase.warnings) != 0 {
+				if diff := cmp.Diff(testcase.warnings, warnings); diff != "" {
+					t.Fatalf("warnings missmatch (-want +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestClasses(t *testing.T) {
+	restore, err := core.SetClock(0x3e8, 0x40, 0xf4240)
+	if err != nil {
+		t.Fatalf("could not set clock: %v", err)
+	}
+	defer restore()
+
+	data := `[` +
+		`{"class":"htb","handle":"1:1","root":true,"options":{"rate":12500000,"ceil":12500000,"burst":1600,"cburst":1600}},` +
+		`{"class":"htb","handle":"1:10","parent":"1:1","leaf":"10:","options":{"prio":1,"rate":"10Mbit","ceil":"100Mbit","burst":"1600b","cburst":"1600b","level":0}},` +
+		`{"class":"htb","handle":"1:20","parent":"1:1","options":{"prio":0,"rate":5000000000,"ceil":5000000000,"burst":1600,"cburst":1600}},` +
+		`{"class":"drr","handle":"2:1","parent":"2:","options":{"quantum":1514}},` +
+		`{"class":"qfq","handle":"3:1","parent":"3:","options":{"weight":10,"maxpkt":1514}},` +
+		`{"class":"mq","handle":":1","root":true}]`
+
+	got, warnings, err := Classes([]byte(data), testConfig())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spec := func(rate uint64) tc.RateSpec { return mustRateSpec(t, rate) }
+	want := []tc.Object{
+		{Msg: tc.Msg{Ifindex: 2, Handle: 0x10001, Parent: tc.HandleRoot},
+			Attribute: tc.Attribute{Kind: "htb", Htb: &tc.Htb{Parms: &tc.HtbOpt{
+				Rate: spec(12500000), Ceil: spec(12500000),
+				Buffer: core.XmitTime(12500000, 1600), Cbuffer: core.XmitTime(12500000, 1600)}}}},
+		{Msg: tc.Msg{Ifindex: 2, Handle: 0x10010, Parent: 0x10001, Info: 0x100000},
+			Attribute: tc.Attribute{Kind: "htb", Htb: &tc.Htb{Parms: &tc.HtbOpt{
+				Rate: spec(1250000), Ceil: spec(12500000), Prio: 1,
+				Buffer: core.XmitTime(1250000, 1600), Cbuffer: core.XmitTime(12500000, 1600)}}}},
+		{Msg: tc.Msg{Ifindex: 2, Handle: 0x10020, Parent: 0x10001},
+			Attribute: tc.Attribute{Kind: "htb", Htb: &tc.Htb{Parms: &tc.HtbOpt{
+				Rate: spec(5000000000), Ceil: spec(5000000000),
+				Buffer: core.XmitTime(5000000000, 1600), Cbuffer: core.XmitTime(5000000000, 1600)},
+				Rate64: uint64Ptr(5000000000), Ceil64: uint64Ptr(5000000000)}}},
+		{Msg: tc.Msg{Ifindex: 2, Handle: 0x20001, Parent: 0x20000},
+			Attribute: tc.Attribute{Kind: "drr", Drr: &tc.Drr{Quantum: uint32Ptr(1514)}}},
+		{Msg: tc.Msg{Ifindex: 2, Handle: 0x30001, Parent: 0x30000},
+			Attribute: tc.Attribute{Kind: "qfq", Qfq: &tc.Qfq{Weight: uint32Ptr(10), Lmax: uint32Ptr(1514)}}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("objects missmatch (-want +got):\n%s", diff)
+	}
+	wantWarnings := []Warning{{Index: 5, Kind: "mq", Reason: "class mq: functionality not yet implemented"}}
+	if diff := cmp.Diff(wantWarnings, warnings); diff != "" {
+		t.Fatalf("warnings missmatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFilters(t *testing.T) {
+	dstIP := net.ParseIP("10.0.0.0").To4()
+	dstMask := net.IP(net.CIDRMask(24, 32))
+	srcIP := net.ParseIP("192.168.1.1").To4()
+	srcMask := net.IP(net.CIDRMask(32, 32))
+	dstMac, _ := net.ParseMAC("00:11:22:33:44:55")
+	fullMac := net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+	tests := map[string]struct {
+		data     string
+		want     []tc.Object
+		warnings []Warning
+	}{
+		"u32": {
+			data: `[{"parent":"1:","protocol":"ip","pref":49152,"kind":"u32","chain":0},
+				{"parent":"1:","protocol":"ip","pref":49152,"kind":"u32","chain":0,"options":{"fh":"800:","ht_divisor":1}},
+				{"parent":"1:","protocol":"ip","pref":49152,"kind":"u32","chain":0,"options":{"fh":"800::800","order":2048,"key_ht":"800","bkt":"0","flowid":"1:10","not_in_hw":true,
+					"match":{"value":"a000001","mask":"ffffffff","offmask":"","off":16},
+					"match":{"value":"50","mask":"ffff","offmask":"","off":20}}}]`,
+			want: []tc.Object{
+				{Msg: tc.Msg{Ifindex: 2, Handle: 0x80000000, Parent: 0x10000, Info: core.BuildFilterInfo(49152, 0x0800)},
+					Attribute: tc.Attribute{Kind: "u32", Chain: uint32Ptr(0), U32: &tc.U32{Divisor: uint32Ptr(1)}}},
+				{Msg: tc.Msg{Ifindex: 2, Handle: 0x80000800, Parent: 0x10000, Info: core.BuildFilterInfo(49152, 0x0800)},
+					Attribute: tc.Attribute{Kind: "u32", Chain: uint32Ptr(0), U32: &tc.U32{
+						Hash:    uint32Ptr(0x80000000),
+						ClassID: uint32Ptr(0x10010),
+						Sel: &tc.U32Sel{Flags: tc.U32Terminal, NKeys: 2, Keys: []tc.U32Key{
+							{Val: htonl(0x0a000001), Mask: htonl(0xffffffff), Off: 16},
+							{Val: htonl(0x50), Mask: htonl(0xffff), Off: 20},
+						}}}}},
+			},
+		},
+		"flower": {
+			data: `[{"dev":"eth1","parent":"ffff:fff2","protocol":"ip","pref":1,"kind":"flower","chain":0,"options":{"handle":1,"classid":"1:10",
+				"keys":{"dst_mac":"00:11:22:33:44:55","eth_type":"ipv4","ip_proto":"tcp","dst_ip":"10.0.0.0/24","src_ip":"192.168.1.1","dst_port":80},
+				"skip_hw":true,"not_in_hw":true}}]`,
+			want: []tc.Object{
+				{Msg: tc.Msg{Ifindex: 3, Handle: 0x1, Parent: tc.HandleClsactIngress, Info: core.BuildFilterInfo(1, 0x0800)},
+					Attribute: tc.Attribute{Kind: "flower", Chain: uint32Ptr(0), Flower: &tc.Flower{
+						ClassID:        uint32Ptr(0x10010),
+						Flags:          uint32Ptr(clsFlagsSkipHw),
+						KeyEthDst:      &dstMac,
+						KeyEthDstMask:  &fullMac,
+						KeyEthType:     uint16Ptr(0x0800),
+						KeyIPProto:     uint8Ptr(6),
+						KeyIPv4Dst:     &dstIP,
+						KeyIPv4DstMask: &dstMask,
+						KeyIPv4Src:     &srcIP,
+						KeyIPv4SrcMask: &srcMask,
+						KeyTCPDst:      uint16Ptr(80)}}},
+			},
+		},
+		"matchall": {
+			data: `[{"parent":"1:","protocol":"all","pref":2,"kind":"matchall","chain":0,"options":{"handle":"0x1","flowid":"1:20","skip_sw":true,"in_hw":true,"in_hw_count":1}}]`,
+			want: []tc.Object{
+				{Msg: tc.Msg{Ifindex: 2, Handle: 0x1, Parent: 0x10000, Info: core.BuildFilterInfo(2, 0x0003)},
+					Attribute: tc.Attribute{Kind: "matchall", Chain: uint32Ptr(0), Matchall: &tc.Matchall{
+						ClassID: uint32Ptr(0x10020),
+						Flags:   uint32Ptr(clsFlagsSkipSw)}}},
+			},
+		},
+		"unsupported": {
+			data: `[{"parent":"1:","protocol":"ip","pref":1,"kind":"bpf","chain":0,"options":{"handle":"0x1","bpf_name":"prog"}},
+				{"parent":"1:","protocol":"ip","pref":2,"kind":"matchall","chain":0,"options":{"handle":"0x1","actions":[{"order":1,"kind":"gact","control_action":{"type":"drop"}}]}},
+				{"parent":"1:","protocol":"ipv6","pref":3,"kind":"flower","chain":0,"options":{"handle":"0x1","keys":{"dst_ip":"2001:db8::1"}}},
+				{"parent":"1:","protocol":"ip","pref":4,"kind":"flower","chain":0,"options":{"handle":"0x1","keys":{"dst_port":"80-90","ip_proto":"tcp"}}},
+				{"parent":"1:","protocol":"ip","pref":5,"kind":"u32","chain":0,"options":{"fh":"800::800","match":{"value":"6","mask":"ff","offmask":"nexthdr+","off":0}}}]`,
+			warnings: []Warning{
+				{Index: 0, Kind: "bpf", Reason: "filter bpf: functionality not yet implemented"},
+				{Index: 1, Kind: "matchall", Reason: "options actions are not supported"},
+				{Index: 2, Kind: "flower", Reason: `flower: dst_ip "2001:db8::1": functionality not yet implemented`},
+				{Index: 3, Kind: "flower", Reason: "flower: dst_port range 80-90: functionality not yet implemented"},
+				{Index: 4, Kind: "u32", Reason: "match: u32: offset relative to the next header: functionality not yet implemented"},
+			},
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, warnings, err := Filters([]byte(testcase.data), testConfig())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(testcase.want, got); diff != "" {
+				t.Fatalf("objects missmatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(testcase.warnings, warnings); diff != "" {
+				t.Fatalf("warnings missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFiltersBlock(t *testing.T) {
+	infoIP := core.BuildFilterInfo(1, 0x0800)
+	tests := map[string]struct {
+		data  string
+		block uint32
+		want  []tc.Object
+	}{
+		// Output of `tc -j filter show block 22`
+		"show block": {
+			data: `[{"protocol":"ip","pref":1,"kind":"u32","chain":0},
+				{"protocol":"ip","pref":1,"kind":"u32","chain":0,"options":{"fh":"800:","ht_divisor":1}}]`,
+			block: 22,
+			want: []tc.Object{
+				{Msg: tc.Msg{Ifindex: tc.MagicBlock, Handle: 0x80000000, Parent: 22, Info: infoIP},
+					Attribute: tc.Attribute{Kind: "u32", Chain: uint32Ptr(0), U32: &tc.U32{Divisor: uint32Ptr(1)}}},
+			},
+		},
+		"block member": {
+			data: `[{"block":22,"protocol":"ip","pref":1,"kind":"u32","chain":0,"options":{"fh":"800:","ht_divisor":1}},
+				{"dev":"eth1","parent":"1:","protocol":"ip","pref":1,"kind":"u32","chain":0,"options":{"fh":"800:","ht_divisor":1}}]`,
+			want: []tc.Object{
+				{Msg: tc.Msg{Ifindex: tc.MagicBlock, Handle: 0x80000000, Parent: 22, Info: infoIP},
+					Attribute: tc.Attribute{Kind: "u32", Chain: uint32Ptr(0), U32: &tc.U32{Divisor: uint32Ptr(1)}}},
+				{Msg: tc.Msg{Ifindex: 3, Handle: 0x80000000, Parent: 0x10000, Info: infoIP},
+					Attribute: tc.Attribute{Kind: "u32", Chain: uint32Ptr(0), U32: &tc.U32{Divisor: uint32Ptr(1)}}},
+			},
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := testConfig()
+			config.Ifindex = 0
+			config.Block = testcase.block
+			got, warnings, err := Filters([]byte(testcase.data), config)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(warnings) != 0 {
+				t.Fatalf("unexpected warnings: %v", warnings)
+			}
+			if diff := cmp.Diff(testcase.want, got); diff != "" {
+				t.Fatalf("objects missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func uint8Ptr(v uint8) *uint8 {
+	return &v
+}
+
+func uint16Ptr(v uint16) *uint16 {
+	return &v
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
@@ -0,0 +1,30 @@
+/*
+Package tctest provides FakeTc, a fake of the traffic control subsystem of
+the kernel, to test code, that uses github.com/florianl/go-tc, without
+privileges, network namespaces or Linux.
+
+FakeTc keeps the qdiscs, classes, filters and chains, that are added, and
+returns them in dumps. Requests, that the kernel refuses for the current
+state, fail with the errno of the kernel, for example adding a qdisc with
+tc.Qdisc.Add twice with EEXIST or deleting a class, that does not exist,
+with ENOENT. Deleting a qdisc or class removes the objects beneath it.
+
+Some details of the kernel are simplified:
+  - A change replaces all options of an object instead of merging them.
+  - Filters without handle get the smallest unused one, starting at 1,
+    independent of the classifier.
+  - Devices are not known, so every Ifindex is accepted and no default
+    qdiscs exist.
+  - Actions, that are not bound to a filter, are refused with EOPNOTSUPP.
+  - Events for monitors are only sent with EmitEvent and EmitRaw, not when
+    objects change.
+
+Connections, that monitor with tc.Tc.MonitorWithErrorFunc, join RTNLGRP_TC
+and buffer events until they are received. Like in the kernel, events, that
+exceed Config.ReadBuffer of the connection, are dropped and the next receive
+fails with ENOBUFS.
+
+FakeTc is supported API. Its behaviour only changes, where it differs from
+the kernel without being documented above.
+*/
+package tctest
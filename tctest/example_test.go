@@ -0,0 +1,56 @@
+package tctest_test
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+
+	tc "github.com/florianl/go-tc"
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/tctest"
+)
+
+// This example shows how code, that adds a qdisc, is tested with a fake
+// kernel.
+func ExampleNewFakeTc() {
+	fake := tctest.NewFakeTc()
+	defer fake.Close()
+
+	// Report statistics for every object, like the kernel.
+	fake.SetStatsHook(func(obj *tc.Object) {
+		obj.Stats = &tc.Stats{Packets: 42}
+	})
+
+	limit := uint32(1000)
+	qdisc := tc.Object{
+		Msg: tc.Msg{
+			Ifindex: 2,
+			Handle:  core.BuildHandle(0x1, 0x0),
+			Parent:  tc.HandleRoot,
+		},
+		Attribute: tc.Attribute{
+			Kind:    "fq_codel",
+			FqCodel: &tc.FqCodel{Limit: &limit},
+		},
+	}
+	// The code under test would receive fake.Tc instead of a Tc of tc.Open.
+	if err := fake.Qdisc().Add(&qdisc); err != nil {
+		fmt.Printf("could not add qdisc: %v\n", err)
+		return
+	}
+	if err := fake.Qdisc().Add(&qdisc); errors.Is(err, syscall.EEXIST) {
+		fmt.Println("the qdisc already exists")
+	}
+
+	qdiscs, err := fake.Qdisc().Get()
+	if err != nil {
+		fmt.Printf("could not get qdiscs: %v\n", err)
+		return
+	}
+	for _, q := range qdiscs {
+		fmt.Printf("%s with %d packets\n", q, q.Stats.Packets)
+	}
+	// Output:
+	// the qdisc already exists
+	// qdisc fq_codel 1: dev ifindex 2 parent root with 42 packets
+}
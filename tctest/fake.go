@@ -0,0 +1,904 @@
+package tctest
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"syscall"
+
+	tc "github.com/florianl/go-tc"
+	"github.com/florianl/go-tc/internal/unix"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nlenc"
+)
+
+// Request is a request, that was sent to a FakeTc.
+type Request struct {
+	// Type is the type of the netlink message, like RTM_NEWQDISC.
+	Type netlink.HeaderType
+	// Flags are the flags of the netlink message, like netlink.Create.
+	Flags netlink.HeaderFlags
+	// Object is the decoded request. Requests, that dump objects, only set
+	// the Msg, that selects them.
+	Object tc.Object
+}
+
+// family is the type of the objects, that a message refers to.
+type family int
+
+const (
+	familyQdisc family = iota
+	familyClass
+	familyFilter
+	familyChain
+	families
+)
+
+type verb int
+
+const (
+	verbNew verb = iota
+	verbDel
+	verbGet
+)
+
+type messageType struct {
+	family family
+	verb   verb
+}
+
+var messageTypes = map[netlink.HeaderType]messageType{
+	unix.RTM_NEWQDISC:   {familyQdisc, verbNew},
+	unix.RTM_DELQDISC:   {familyQdisc, verbDel},
+	unix.RTM_GETQDISC:   {familyQdisc, verbGet},
+	unix.RTM_NEWTCLASS:  {familyClass, verbNew},
+	unix.RTM_DELTCLASS:  {familyClass, verbDel},
+	unix.RTM_GETTCLASS:  {familyClass, verbGet},
+	unix.RTM_NEWTFILTER: {familyFilter, verbNew},
+	unix.RTM_DELTFILTER: {familyFilter, verbDel},
+	unix.RTM_GETTFILTER: {familyFilter, verbGet},
+	unix.RTM_NEWCHAIN:   {familyChain, verbNew},
+	unix.RTM_DELCHAIN:   {familyChain, verbDel},
+	unix.RTM_GETCHAIN:   {familyChain, verbGet},
+}
+
+// replyTypes are the types of the messages, that carry the objects of a
+// family in replies.
+var replyTypes = [families]netlink.HeaderType{
+	familyQdisc:  unix.RTM_NEWQDISC,
+	familyClass:  unix.RTM_NEWTCLASS,
+	familyFilter: unix.RTM_NEWTFILTER,
+	familyChain:  unix.RTM_NEWCHAIN,
+}
+
+const (
+	// autoPrio is the priority of the first filter of a chain, that is
+	// added without priority, see tcf_auto_prio() in net/sched/cls_api.c.
+	autoPrio = 0xC000
+	// firstAutoHandle is the major of the first handle, that is assigned to
+	// a qdisc without handle, see qdisc_alloc_handle() in
+	// net/sched/sch_api.c.
+	firstAutoHandle = 0x8001
+	// ingressMajor is the major of the handle of ingress and clsact.
+	ingressMajor = 0xFFFF0000
+)
+
+// FakeTc is a fake kernel, that keeps the objects of traffic control in
+// memory. It is safe for concurrent use.
+type FakeTc struct {
+	// Tc is connected to the fake. Like a Tc with an own socket, it must not
+	// be used by several goroutines at once. Use Open for further
+	// connections.
+	*tc.Tc
+
+	mu        sync.Mutex
+	objects   [families][]tc.Object
+	errorHook func(Request) error
+	statsHook func(*tc.Object)
+	sockets   map[*socket]struct{}
+	lastPID   uint32
+}
+
+// NewFakeTc returns a FakeTc without objects.
+func NewFakeTc() *FakeTc {
+	f := &FakeTc{sockets: make(map[*socket]struct{})}
+	tcnl, err := f.Open(nil)
+	if err != nil {
+		// Without config, no option of the connection is set, that could
+		// fail.
+		panic(err)
+	}
+	f.Tc = tcnl
+	return f
+}
+
+// Open returns another Tc, that is connected to f. The options are applied on
+// top of config, which may be nil. Config.ReadBuffer limits the events, that
+// are buffered for the monitors of the Tc. Other options of the socket, like
+// Config.ExtendedAck, are not supported and return an error.
+func (f *FakeTc) Open(config *tc.Config, opts ...tc.Option) (*tc.Tc, error) {
+	f.mu.Lock()
+	f.lastPID++
+	sock := newSocket(f, f.lastPID)
+	f.sockets[sock] = struct{}{}
+	f.mu.Unlock()
+	tcnl, err := tc.OpenConn(netlink.NewConn(sock, sock.pid), config, opts...)
+	if err != nil {
+		sock.Close()
+		return nil, err
+	}
+	return tcnl, nil
+}
+
+func (f *FakeTc) removeSocket(sock *socket) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.sockets, sock)
+}
+
+// EmitEvent sends obj as event of type typ, like RTM_NEWQDISC or
+// RTM_DELTFILTER, to the connections, that monitor f. Like the kernel, f does
+// not emit events on its own, when objects change. The stats hook is not
+// applied to obj.
+func (f *FakeTc) EmitEvent(typ netlink.HeaderType, obj *tc.Object) error {
+	msg, err := tc.MarshalReply(typ, obj)
+	if err != nil {
+		return err
+	}
+	f.EmitRaw(msg)
+	return nil
+}
+
+// EmitRaw sends msgs unchanged as one event to the connections, that monitor
+// f, for example to test the handling of malformed events. Only a Length of
+// 0 is set to the size of the message.
+func (f *FakeTc) EmitRaw(msgs ...netlink.Message) {
+	event := make([]netlink.Message, len(msgs))
+	copy(event, msgs)
+	for i := range event {
+		if event[i].Header.Length == 0 {
+			event[i].Header.Length = uint32(nlmsgHeaderLen + len(event[i].Data))
+		}
+	}
+
+	f.mu.Lock()
+	sockets := make([]*socket, 0, len(f.sockets))
+	for sock := range f.sockets {
+		sockets = append(sockets, sock)
+	}
+	f.mu.Unlock()
+	for _, sock := range sockets {
+		sock.deliver(event)
+	}
+}
+
+// SetErrorHook calls fn for every request, before it is applied. If fn
+// returns an error, the request is refused and the objects stay unchanged.
+// A syscall.Errno fails the request like the kernel. Other errors are
+// returned as error of the socket. nil removes the hook.
+func (f *FakeTc) SetErrorHook(fn func(Request) error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errorHook = fn
+}
+
+// SetStatsHook calls fn for a copy of every object, before it is sent in a
+// reply, to set Stats, Stats2 and XStats, like the kernel reports them. nil
+// removes the hook.
+func (f *FakeTc) SetStatsHook(fn func(*tc.Object)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statsHook = fn
+}
+
+// Qdiscs returns a copy of the qdiscs in the order they were added.
+func (f *FakeTc) Qdiscs() []tc.Object {
+	return f.copyObjects(familyQdisc)
+}
+
+// Classes returns a copy of the classes in the order they were added.
+func (f *FakeTc) Classes() []tc.Object {
+	return f.copyObjects(familyClass)
+}
+
+// Filters returns a copy of the filters in the order they were added.
+func (f *FakeTc) Filters() []tc.Object {
+	return f.copyObjects(familyFilter)
+}
+
+// Chains returns a copy of the chains, that were added with tc.Chain.Add.
+// Chains, that only exist because of their filters, are not contained.
+func (f *FakeTc) Chains() []tc.Object {
+	return f.copyObjects(familyChain)
+}
+
+func (f *FakeTc) copyObjects(fam family) []tc.Object {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return copyObjects(f.objects[fam])
+}
+
+func copyObjects(objs []tc.Object) []tc.Object {
+	copies := make([]tc.Object, 0, len(objs))
+	for i := range objs {
+		copies = append(copies, *objs[i].Copy())
+	}
+	return copies
+}
+
+// handle answers a request of a connection like the kernel.
+func (f *FakeTc) handle(msg netlink.Message) ([]netlink.Message, error) {
+	if msg.Header.Type == unix.RTM_GETLINK && msg.Header.Flags&netlink.Dump != 0 {
+		// tc.Tc.Monitor dumps the devices, when it starts. As devices are not
+		// known, the dump is empty.
+		return []netlink.Message{doneReply(msg)}, nil
+	}
+	mt, ok := messageTypes[msg.Header.Type]
+	if !ok {
+		return errorReply(msg, syscall.EOPNOTSUPP), nil
+	}
+	obj, err := tc.UnmarshalObject(msg)
+	if err != nil {
+		return errorReply(msg, syscall.EINVAL), nil
+	}
+
+	f.mu.Lock()
+	errorHook := f.errorHook
+	f.mu.Unlock()
+	// The hooks are called without lock, so that they can use f.
+	if errorHook != nil {
+		if err := errorHook(Request{Type: msg.Header.Type, Flags: msg.Header.Flags, Object: *obj.Copy()}); err != nil {
+			var errno syscall.Errno
+			if errors.As(err, &errno) {
+				return errorReply(msg, errno), nil
+			}
+			return nil, err
+		}
+	}
+
+	if mt.verb == verbGet {
+		return f.get(msg, mt.family, obj)
+	}
+	f.mu.Lock()
+	var errno syscall.Errno
+	if mt.verb == verbNew {
+		errno = f.add(mt.family, msg.Header.Flags, obj)
+	} else {
+		errno = f.remove(mt.family, obj)
+	}
+	f.mu.Unlock()
+	if errno != 0 {
+		return errorReply(msg, errno), nil
+	}
+	return errorReply(msg, 0), nil
+}
+
+// errorReply returns the acknowledgement of req, that fails with errno or
+// succeeds, if errno is 0. The flags of req are not copied, as the dump flags
+// would be interpreted as extended acknowledgement.
+func errorReply(req netlink.Message, errno syscall.Errno) []netlink.Message {
+	data := nlenc.Int32Bytes(-int32(errno))
+	if errno != 0 {
+		// Like the kernel, the failed request follows the error code.
+		if hdr, err := req.MarshalBinary(); err == nil {
+			data = append(data, hdr...)
+		}
+	}
+	return []netlink.Message{{
+		Header: netlink.Header{Type: netlink.Error, Sequence: req.Header.Sequence, PID: req.Header.PID},
+		Data:   data,
+	}}
+}
+
+// doneReply returns the message, that ends the dump of req.
+func doneReply(req netlink.Message) netlink.Message {
+	return netlink.Message{
+		Header: netlink.Header{Type: netlink.Done, Flags: netlink.Multi, Sequence: req.Header.Sequence, PID: req.Header.PID},
+		Data:   make([]byte, 4),
+	}
+}
+
+// get answers a dump or the request of a single object.
+func (f *FakeTc) get(msg netlink.Message, fam family, sel *tc.Object) ([]netlink.Message, error) {
+	f.mu.Lock()
+	var objs []tc.Object
+	var errno syscall.Errno
+	if msg.Header.Flags&netlink.Dump != 0 {
+		objs = copyObjects(f.dump(fam, sel))
+	} else {
+		var obj *tc.Object
+		if obj, errno = f.lookup(fam, sel); obj != nil {
+			objs = copyObjects([]tc.Object{*obj})
+		}
+	}
+	statsHook := f.statsHook
+	f.mu.Unlock()
+	if errno != 0 {
+		return errorReply(msg, errno), nil
+	}
+
+	replies := make([]netlink.Message, 0, len(objs)+1)
+	for i := range objs {
+		if statsHook != nil {
+			statsHook(&objs[i])
+		}
+		reply, err := tc.MarshalReply(replyTypes[fam], &objs[i])
+		if err != nil {
+			return nil, fmt.Errorf("tctest: %s: %w", objs[i].String(), err)
+		}
+		replies = append(replies, reply)
+	}
+	if msg.Header.Flags&netlink.Dump != 0 {
+		for i := range replies {
+			replies[i].Header.Flags |= netlink.Multi
+		}
+		replies = append(replies, doneReply(msg))
+	}
+	for i := range replies {
+		replies[i].Header.Sequence = msg.Header.Sequence
+		replies[i].Header.PID = msg.Header.PID
+	}
+	return replies, nil
+}
+
+// dump returns the objects of fam, that are selected by sel.
+func (f *FakeTc) dump(fam family, sel *tc.Object) []tc.Object {
+	var objs []tc.Object
+	switch fam {
+	case familyQdisc:
+		for _, q := range f.objects[familyQdisc] {
+			if sel.Ifindex == 0 || q.Ifindex == sel.Ifindex {
+				objs = append(objs, q)
+			}
+		}
+	case familyClass:
+		for _, c := range f.objects[familyClass] {
+			if c.Ifindex == sel.Ifindex && (sel.Parent == 0 || major(c.Handle) == major(sel.Parent)) {
+				objs = append(objs, c)
+			}
+		}
+	case familyFilter:
+		parent, errno := f.filterParent(sel)
+		if errno != 0 {
+			return nil
+		}
+		for _, flt := range f.objects[familyFilter] {
+			if flt.Ifindex == sel.Ifindex && flt.Parent == parent &&
+				(sel.Chain == nil || chain(&flt) == *sel.Chain) {
+				objs = append(objs, flt)
+			}
+		}
+		sort.SliceStable(objs, func(i, j int) bool {
+			if chain(&objs[i]) != chain(&objs[j]) {
+				return chain(&objs[i]) < chain(&objs[j])
+			}
+			if prio(objs[i].Info) != prio(objs[j].Info) {
+				return prio(objs[i].Info) < prio(objs[j].Info)
+			}
+			return objs[i].Handle < objs[j].Handle
+		})
+	case familyChain:
+		parent, errno := f.filterParent(sel)
+		if errno != 0 {
+			return nil
+		}
+		for _, index := range f.chains(sel.Ifindex, parent) {
+			obj, _ := f.findChain(sel.Ifindex, parent, index)
+			objs = append(objs, obj)
+		}
+	}
+	return objs
+}
+
+// lookup returns the object of fam, that is requested by sel.
+func (f *FakeTc) lookup(fam family, sel *tc.Object) (*tc.Object, syscall.Errno) {
+	switch fam {
+	case familyQdisc:
+		i := f.findQdisc(sel)
+		if i < 0 {
+			return nil, syscall.ENOENT
+		}
+		if sel.Handle != 0 && f.objects[familyQdisc][i].Handle != sel.Handle {
+			return nil, syscall.EINVAL
+		}
+		return &f.objects[familyQdisc][i], 0
+	case familyClass:
+		handle, errno := f.classHandle(sel)
+		if errno != 0 {
+			return nil, errno
+		}
+		i := f.findClass(sel.Ifindex, handle)
+		if i < 0 {
+			return nil, syscall.ENOENT
+		}
+		return &f.objects[familyClass][i], 0
+	case familyFilter:
+		parent, errno := f.filterParent(sel)
+		if errno != 0 {
+			return nil, errno
+		}
+		if prio(sel.Info) == 0 {
+			return nil, syscall.EINVAL
+		}
+		i := f.findFilter(sel.Ifindex, parent, chain(sel), prio(sel.Info), sel.Handle)
+		if i < 0 {
+			return nil, syscall.ENOENT
+		}
+		return &f.objects[familyFilter][i], 0
+	default:
+		parent, errno := f.filterParent(sel)
+		if errno != 0 {
+			return nil, errno
+		}
+		obj, ok := f.findChain(sel.Ifindex, parent, chain(sel))
+		if !ok {
+			return nil, syscall.ENOENT
+		}
+		return &obj, 0
+	}
+}
+
+// add applies a request of type RTM_NEW* with flags, following
+// tc_modify_qdisc(), tc_ctl_tclass(), tc_new_tfilter() and tc_ctl_chain() of
+// the kernel.
+func (f *FakeTc) add(fam family, flags netlink.HeaderFlags, obj *tc.Object) syscall.Errno {
+	switch fam {
+	case familyQdisc:
+		return f.addQdisc(flags, obj)
+	case familyClass:
+		handle, errno := f.classHandle(obj)
+		if errno != 0 {
+			return errno
+		}
+		obj.Handle = handle
+		if i := f.findClass(obj.Ifindex, handle); i >= 0 {
+			if flags&netlink.Excl != 0 {
+				return syscall.EEXIST
+			}
+			f.objects[familyClass][i] = *obj
+			return 0
+		}
+		if flags&netlink.Create == 0 {
+			return syscall.ENOENT
+		}
+		f.objects[familyClass] = append(f.objects[familyClass], *obj)
+		return 0
+	case familyFilter:
+		return f.addFilter(flags, obj)
+	default:
+		parent, errno := f.filterParent(obj)
+		if errno != 0 {
+			return errno
+		}
+		if _, ok := f.findChain(obj.Ifindex, parent, chain(obj)); ok {
+			return syscall.EEXIST
+		}
+		if flags&netlink.Create == 0 {
+			return syscall.ENOENT
+		}
+		obj.Parent = parent
+		obj.Chain = uint32Ptr(chain(obj))
+		f.objects[familyChain] = append(f.objects[familyChain], *obj)
+		return 0
+	}
+}
+
+func (f *FakeTc) addQdisc(flags netlink.HeaderFlags, obj *tc.Object) syscall.Errno {
+	i := f.findQdisc(obj)
+	if obj.Parent != 0 && (i < 0 || obj.Handle == 0 || f.objects[familyQdisc][i].Handle != obj.Handle) {
+		if obj.Handle != 0 {
+			if i >= 0 && flags&netlink.Replace == 0 {
+				return syscall.EEXIST
+			}
+			if minor(obj.Handle) != 0 {
+				return syscall.EINVAL
+			}
+			if f.findQdiscHandle(obj.Ifindex, obj.Handle) >= 0 && flags&netlink.Excl != 0 {
+				return syscall.EEXIST
+			}
+			return f.createQdisc(flags, i, obj)
+		}
+		if i < 0 || flags&netlink.Create != 0 && flags&netlink.Replace != 0 &&
+			(flags&netlink.Excl != 0 || obj.Kind != f.objects[familyQdisc][i].Kind) {
+			return f.createQdisc(flags, i, obj)
+		}
+	}
+	if i < 0 {
+		return syscall.ENOENT
+	}
+	if flags&netlink.Excl != 0 {
+		return syscall.EEXIST
+	}
+	old := &f.objects[familyQdisc][i]
+	if obj.Kind != old.Kind {
+		return syscall.EINVAL
+	}
+	obj.Handle, obj.Parent = old.Handle, old.Parent
+	*old = *obj
+	return 0
+}
+
+// createQdisc adds obj in place of the qdisc at index old, if old is not
+// negative.
+func (f *FakeTc) createQdisc(flags netlink.HeaderFlags, old int, obj *tc.Object) syscall.Errno {
+	if flags&netlink.Create == 0 {
+		return syscall.ENOENT
+	}
+	if obj.Handle == 0 {
+		obj.Handle = f.allocQdiscHandle(obj.Ifindex)
+	} else if i := f.findQdiscHandle(obj.Ifindex, obj.Handle); i >= 0 && i != old {
+		// The kernel moves the existing qdisc, which is not supported.
+		return syscall.EINVAL
+	}
+	if old >= 0 {
+		f.removeQdisc(old)
+	}
+	f.objects[familyQdisc] = append(f.objects[familyQdisc], *obj)
+	return 0
+}
+
+func (f *FakeTc) addFilter(flags netlink.HeaderFlags, obj *tc.Object) syscall.Errno {
+	parent, errno := f.filterParent(obj)
+	if errno != 0 {
+		return errno
+	}
+	obj.Parent = parent
+	index := chain(obj)
+	p, protocol := prio(obj.Info), obj.Info&0xFFFF
+	if p == 0 {
+		if flags&netlink.Create == 0 {
+			return syscall.ENOENT
+		}
+		p = autoPrio
+		for _, flt := range f.objects[familyFilter] {
+			if sameChain(&flt, obj.Ifindex, parent, index) && prio(flt.Info) <= p {
+				p = prio(flt.Info) - 1
+			}
+		}
+		obj.Info = p<<16 | protocol
+	}
+
+	// Filters of the same priority share the kind and the protocol.
+	var handles []uint32
+	for _, flt := range f.objects[familyFilter] {
+		if !sameChain(&flt, obj.Ifindex, parent, index) || prio(flt.Info) != p {
+			continue
+		}
+		if flt.Kind != obj.Kind || protocol != 0 && flt.Info&0xFFFF != protocol {
+			return syscall.EINVAL
+		}
+		handles = append(handles, flt.Handle)
+	}
+	if len(handles) == 0 && flags&netlink.Create == 0 {
+		return syscall.ENOENT
+	}
+
+	if obj.Handle != 0 {
+		if i := f.findFilter(obj.Ifindex, parent, index, p, obj.Handle); i >= 0 {
+			if flags&netlink.Excl != 0 {
+				return syscall.EEXIST
+			}
+			f.objects[familyFilter][i] = *obj
+			return 0
+		}
+	}
+	if flags&netlink.Create == 0 {
+		return syscall.ENOENT
+	}
+	if obj.Handle == 0 {
+		obj.Handle = unusedHandle(handles)
+	}
+	f.objects[familyFilter] = append(f.objects[familyFilter], *obj)
+	return 0
+}
+
+// remove applies a request of type RTM_DEL*.
+func (f *FakeTc) remove(fam family, obj *tc.Object) syscall.Errno {
+	switch fam {
+	case familyQdisc:
+		if obj.Parent == 0 {
+			return syscall.EINVAL
+		}
+		i := f.findQdisc(obj)
+		if i < 0 {
+			return syscall.ENOENT
+		}
+		if obj.Handle != 0 && f.objects[familyQdisc][i].Handle != obj.Handle {
+			return syscall.EINVAL
+		}
+		f.removeQdisc(i)
+		return 0
+	case familyClass:
+		handle, errno := f.classHandle(obj)
+		if errno != 0 {
+			return errno
+		}
+		i := f.findClass(obj.Ifindex, handle)
+		if i < 0 {
+			return syscall.ENOENT
+		}
+		f.removeClass(i)
+		return 0
+	case familyFilter:
+		return f.removeFilters(obj)
+	default:
+		parent, errno := f.filterParent(obj)
+		if errno != 0 {
+			return errno
+		}
+		index := chain(obj)
+		if _, ok := f.findChain(obj.Ifindex, parent, index); !ok {
+			return syscall.ENOENT
+		}
+		f.objects[familyFilter] = filterObjects(f.objects[familyFilter], func(flt *tc.Object) bool {
+			return sameChain(flt, obj.Ifindex, parent, index)
+		})
+		f.objects[familyChain] = filterObjects(f.objects[familyChain], func(c *tc.Object) bool {
+			return sameChain(c, obj.Ifindex, parent, index)
+		})
+		return 0
+	}
+}
+
+// removeFilters follows tc_del_tfilter(). Without priority, the chain is
+// flushed and without handle, all filters of the priority are removed.
+func (f *FakeTc) removeFilters(obj *tc.Object) syscall.Errno {
+	parent, errno := f.filterParent(obj)
+	if errno != 0 {
+		return errno
+	}
+	index, p := chain(obj), prio(obj.Info)
+	if p == 0 {
+		if _, ok := f.findChain(obj.Ifindex, parent, index); !ok {
+			return syscall.ENOENT
+		}
+		f.objects[familyFilter] = filterObjects(f.objects[familyFilter], func(flt *tc.Object) bool {
+			return sameChain(flt, obj.Ifindex, parent, index)
+		})
+		return 0
+	}
+
+	var found bool
+	for _, flt := range f.objects[familyFilter] {
+		if sameChain(&flt, obj.Ifindex, parent, index) && prio(flt.Info) == p {
+			if obj.Kind != "" && flt.Kind != obj.Kind {
+				return syscall.EINVAL
+			}
+			found = true
+		}
+	}
+	if !found {
+		return syscall.ENOENT
+	}
+	if obj.Handle != 0 {
+		i := f.findFilter(obj.Ifindex, parent, index, p, obj.Handle)
+		if i < 0 {
+			return syscall.ENOENT
+		}
+		f.objects[familyFilter] = append(f.objects[familyFilter][:i], f.objects[familyFilter][i+1:]...)
+		return 0
+	}
+	f.objects[familyFilter] = filterObjects(f.objects[familyFilter], func(flt *tc.Object) bool {
+		return sameChain(flt, obj.Ifindex, parent, index) && prio(flt.Info) == p
+	})
+	return 0
+}
+
+// removeQdisc removes the qdisc at index i with its classes, filters, chains
+// and the qdiscs of its classes.
+func (f *FakeTc) removeQdisc(i int) {
+	q := f.objects[familyQdisc][i]
+	f.objects[familyQdisc] = append(f.objects[familyQdisc][:i], f.objects[familyQdisc][i+1:]...)
+	f.removeBeneath(q.Ifindex, func(parent uint32) bool { return major(parent) == major(q.Handle) })
+	f.objects[familyClass] = filterObjects(f.objects[familyClass], func(c *tc.Object) bool {
+		return c.Ifindex == q.Ifindex && major(c.Handle) == major(q.Handle)
+	})
+}
+
+// removeClass removes the class at index i with its filters, chains and
+// qdisc.
+func (f *FakeTc) removeClass(i int) {
+	c := f.objects[familyClass][i]
+	f.objects[familyClass] = append(f.objects[familyClass][:i], f.objects[familyClass][i+1:]...)
+	f.removeBeneath(c.Ifindex, func(parent uint32) bool { return parent == c.Handle })
+}
+
+// removeBeneath removes the filters, chains and qdiscs on ifindex, whose
+// parent is matched by beneath.
+func (f *FakeTc) removeBeneath(ifindex uint32, beneath func(parent uint32) bool) {
+	for _, fam := range []family{familyFilter, familyChain} {
+		f.objects[fam] = filterObjects(f.objects[fam], func(obj *tc.Object) bool {
+			return obj.Ifindex == ifindex && beneath(obj.Parent)
+		})
+	}
+	for i := 0; i < len(f.objects[familyQdisc]); i++ {
+		q := &f.objects[familyQdisc][i]
+		if q.Ifindex == ifindex && major(q.Parent) != ingressMajor && beneath(q.Parent) {
+			f.removeQdisc(i)
+			// Removing the qdisc might have removed any other one.
+			i = -1
+		}
+	}
+}
+
+// findQdisc returns the index of the qdisc at the parent of obj or, without
+// parent, of the qdisc with the handle of obj. It returns -1, if there is no
+// such qdisc.
+func (f *FakeTc) findQdisc(obj *tc.Object) int {
+	if obj.Parent == 0 {
+		return f.findQdiscHandle(obj.Ifindex, obj.Handle)
+	}
+	for i, q := range f.objects[familyQdisc] {
+		if q.Ifindex == obj.Ifindex && q.Parent == obj.Parent {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *FakeTc) findQdiscHandle(ifindex, handle uint32) int {
+	for i, q := range f.objects[familyQdisc] {
+		if q.Ifindex == ifindex && major(q.Handle) == major(handle) {
+			return i
+		}
+	}
+	return -1
+}
+
+// allocQdiscHandle returns an unused handle for a qdisc on ifindex.
+func (f *FakeTc) allocQdiscHandle(ifindex uint32) uint32 {
+	handle := uint32(firstAutoHandle << 16)
+	for f.findQdiscHandle(ifindex, handle) >= 0 {
+		handle += 1 << 16
+	}
+	return handle
+}
+
+// classHandle returns the complete class ID of obj and checks, that its qdisc
+// exists.
+func (f *FakeTc) classHandle(obj *tc.Object) (uint32, syscall.Errno) {
+	qid := major(obj.Handle)
+	if obj.Parent != tc.HandleRoot && major(obj.Parent) != 0 {
+		if qid != 0 && qid != major(obj.Parent) {
+			return 0, syscall.EINVAL
+		}
+		qid = major(obj.Parent)
+	}
+	if qid == 0 {
+		i := f.findQdisc(&tc.Object{Msg: tc.Msg{Ifindex: obj.Ifindex, Parent: tc.HandleRoot}})
+		if i < 0 {
+			return 0, syscall.ENOENT
+		}
+		qid = major(f.objects[familyQdisc][i].Handle)
+	}
+	if f.findQdiscHandle(obj.Ifindex, qid) < 0 {
+		return 0, syscall.ENOENT
+	}
+	return qid | minor(obj.Handle), 0
+}
+
+func (f *FakeTc) findClass(ifindex, handle uint32) int {
+	for i, c := range f.objects[familyClass] {
+		if c.Ifindex == ifindex && c.Handle == handle {
+			return i
+		}
+	}
+	return -1
+}
+
+// filterParent returns the parent, that the filters and chains of obj are
+// kept with. Without parent, filters belong to the root qdisc. Filters of a
+// qdisc, that does not exist, are refused.
+func (f *FakeTc) filterParent(obj *tc.Object) (uint32, syscall.Errno) {
+	if _, ok := obj.Block(); ok {
+		return obj.Parent, 0
+	}
+	if obj.Parent == 0 {
+		i := f.findQdisc(&tc.Object{Msg: tc.Msg{Ifindex: obj.Ifindex, Parent: tc.HandleRoot}})
+		if i < 0 {
+			return 0, syscall.EINVAL
+		}
+		return f.objects[familyQdisc][i].Handle, 0
+	}
+	if f.findQdiscHandle(obj.Ifindex, obj.Parent) < 0 {
+		return 0, syscall.EINVAL
+	}
+	return obj.Parent, 0
+}
+
+func (f *FakeTc) findFilter(ifindex, parent, index, p, handle uint32) int {
+	for i, flt := range f.objects[familyFilter] {
+		if sameChain(&flt, ifindex, parent, index) && prio(flt.Info) == p && flt.Handle == handle {
+			return i
+		}
+	}
+	return -1
+}
+
+// chains returns the sorted indexes of the chains of parent, that were added
+// or contain filters.
+func (f *FakeTc) chains(ifindex, parent uint32) []uint32 {
+	seen := make(map[uint32]bool)
+	var indexes []uint32
+	for _, fam := range []family{familyChain, familyFilter} {
+		for _, obj := range f.objects[fam] {
+			if obj.Ifindex == ifindex && obj.Parent == parent && !seen[chain(&obj)] {
+				seen[chain(&obj)] = true
+				indexes = append(indexes, chain(&obj))
+			}
+		}
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+	return indexes
+}
+
+// findChain returns the chain index of parent on ifindex, like it is reported
+// in dumps. It reports, whether the chain exists.
+func (f *FakeTc) findChain(ifindex, parent, index uint32) (tc.Object, bool) {
+	for _, c := range f.objects[familyChain] {
+		if sameChain(&c, ifindex, parent, index) {
+			return c, true
+		}
+	}
+	for _, flt := range f.objects[familyFilter] {
+		if sameChain(&flt, ifindex, parent, index) {
+			return tc.Object{
+				Msg:       tc.Msg{Ifindex: ifindex, Parent: parent},
+				Attribute: tc.Attribute{Chain: uint32Ptr(index)},
+			}, true
+		}
+	}
+	return tc.Object{}, false
+}
+
+func sameChain(obj *tc.Object, ifindex, parent, index uint32) bool {
+	return obj.Ifindex == ifindex && obj.Parent == parent && chain(obj) == index
+}
+
+// filterObjects returns objs without the objects, that are matched by remove.
+func filterObjects(objs []tc.Object, remove func(*tc.Object) bool) []tc.Object {
+	kept := objs[:0]
+	for i := range objs {
+		if !remove(&objs[i]) {
+			kept = append(kept, objs[i])
+		}
+	}
+	return kept
+}
+
+// unusedHandle returns the smallest handle, that is not contained in handles.
+func unusedHandle(handles []uint32) uint32 {
+	used := make(map[uint32]bool, len(handles))
+	for _, handle := range handles {
+		used[handle] = true
+	}
+	handle := uint32(1)
+	for used[handle] {
+		handle++
+	}
+	return handle
+}
+
+func chain(obj *tc.Object) uint32 {
+	if obj.Chain == nil {
+		return 0
+	}
+	return *obj.Chain
+}
+
+func prio(info uint32) uint32 {
+	return info >> 16
+}
+
+func major(handle uint32) uint32 {
+	return handle & 0xFFFF0000
+}
+
+func minor(handle uint32) uint32 {
+	return handle & 0x0000FFFF
+}
+
+func uint32Ptr(v uint32) *uint32 {
+	return &v
+}
@@ -0,0 +1,477 @@
+package tctest
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"syscall"
+	"testing"
+
+	tc "github.com/florianl/go-tc"
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+	"github.com/google/go-cmp/cmp"
+)
+
+func uint64Ptr(v uint64) *uint64 {
+	return &v
+}
+
+func uint16Ptr(v uint16) *uint16 {
+	return &v
+}
+
+func qdisc(ifindex, handle, parent uint32, attr tc.Attribute) tc.Object {
+	return tc.Object{Msg: tc.Msg{Family: unix.AF_UNSPEC, Ifindex: ifindex, Handle: handle, Parent: parent},
+		Attribute: attr}
+}
+
+// filter returns the filter with info, as the kernel reports it.
+func filter(ifindex, handle, parent, info uint32, attr tc.Attribute) tc.Object {
+	attr.Priority, attr.Protocol = core.SplitFilterInfo(info)
+	return tc.Object{Msg: tc.Msg{Family: unix.AF_UNSPEC, Ifindex: ifindex, Handle: handle, Parent: parent, Info: info},
+		Attribute: attr}
+}
+
+func TestQdisc(t *testing.T) {
+	tests := map[string]tc.Attribute{
+		"clsact":   {Kind: "clsact"},
+		"fq_codel": {Kind: "fq_codel", FqCodel: &tc.FqCodel{Target: uint32Ptr(42), Limit: uint32Ptr(0xCAFE)}},
+		"fq":       {Kind: "fq", Fq: &tc.Fq{PLimit: uint32Ptr(10000), Quantum: uint32Ptr(3028)}},
+		"sfq":      {Kind: "sfq", Sfq: &tc.Sfq{V0: tc.SfqQopt{PerturbPeriod: 64, Limit: 3000, Flows: 512}}},
+		"htb":      {Kind: "htb", Htb: &tc.Htb{Rate64: uint64Ptr(96)}},
+	}
+
+	for name, attr := range tests {
+		t.Run(name, func(t *testing.T) {
+			fake := NewFakeTc()
+			defer fake.Close()
+			parent := tc.HandleRoot
+			handle := core.BuildHandle(0x1, 0x0)
+			if attr.Kind == "clsact" {
+				parent, handle = tc.HandleIngress, core.BuildHandle(0xFFFF, 0x0)
+			}
+			obj := qdisc(123, handle, parent, attr)
+
+			if err := fake.Qdisc().Add(&obj); err != nil {
+				t.Fatalf("could not add qdisc: %v", err)
+			}
+			if err := fake.Qdisc().Add(&obj); !errors.Is(err, syscall.EEXIST) {
+				t.Fatalf("expected EEXIST for a second qdisc but got %v", err)
+			}
+			for op, fn := range map[string]func(*tc.Object) error{
+				"change":  fake.Qdisc().Change,
+				"replace": fake.Qdisc().Replace,
+				"link":    fake.Qdisc().Link,
+			} {
+				if err := fn(&obj); err != nil {
+					t.Fatalf("could not %s qdisc: %v", op, err)
+				}
+			}
+
+			qdiscs, err := fake.Qdisc().Get()
+			if err != nil {
+				t.Fatalf("could not get qdiscs: %v", err)
+			}
+			if diff := cmp.Diff([]tc.Object{obj}, qdiscs); diff != "" {
+				t.Fatalf("qdiscs missmatch (-want +got):\n%s", diff)
+			}
+
+			if err := fake.Qdisc().Delete(&obj); err != nil {
+				t.Fatalf("could not delete qdisc: %v", err)
+			}
+			if err := fake.Qdisc().Delete(&obj); !errors.Is(err, syscall.ENOENT) {
+				t.Fatalf("expected ENOENT for a deleted qdisc but got %v", err)
+			}
+			if qdiscs, err := fake.Qdisc().Get(); err != nil || len(qdiscs) != 0 {
+				t.Fatalf("expected no qdiscs but got %v, %v", qdiscs, err)
+			}
+		})
+	}
+}
+
+func TestQdiscHandle(t *testing.T) {
+	fake := NewFakeTc()
+	defer fake.Close()
+	root := qdisc(1, 0, tc.HandleRoot, tc.Attribute{Kind: "htb", Htb: &tc.Htb{Init: &tc.HtbGlob{Defcls: 0x30}}})
+	if err := fake.Qdisc().Add(&root); err != nil {
+		t.Fatalf("could not add qdisc: %v", err)
+	}
+	other := qdisc(2, 0, tc.HandleRoot, root.Attribute)
+	if err := fake.Qdisc().Add(&other); err != nil {
+		t.Fatalf("could not add qdisc: %v", err)
+	}
+	// Changing a missing qdisc fails.
+	missing := qdisc(3, 0, tc.HandleRoot, root.Attribute)
+	if err := fake.Qdisc().Change(&missing); !errors.Is(err, syscall.ENOENT) {
+		t.Fatalf("expected ENOENT but got %v", err)
+	}
+
+	want := []tc.Object{
+		qdisc(1, core.BuildHandle(0x8001, 0x0), tc.HandleRoot, root.Attribute),
+		qdisc(2, core.BuildHandle(0x8001, 0x0), tc.HandleRoot, root.Attribute),
+	}
+	if diff := cmp.Diff(want, fake.Qdiscs()); diff != "" {
+		t.Fatalf("qdiscs missmatch (-want +got):\n%s", diff)
+	}
+	qdiscs, err := fake.Qdisc().Get()
+	if err != nil {
+		t.Fatalf("could not get qdiscs: %v", err)
+	}
+	if diff := cmp.Diff(want, qdiscs); diff != "" {
+		t.Fatalf("dumped qdiscs missmatch (-want +got):\n%s", diff)
+	}
+
+	// Replacing the root with another kind creates a new qdisc.
+	fq := qdisc(1, 0, tc.HandleRoot, tc.Attribute{Kind: "fq_codel", FqCodel: &tc.FqCodel{Limit: uint32Ptr(42)}})
+	if err := fake.Qdisc().Replace(&fq); err != nil {
+		t.Fatalf("could not replace qdisc: %v", err)
+	}
+	want = []tc.Object{want[1], qdisc(1, core.BuildHandle(0x8002, 0x0), tc.HandleRoot, fq.Attribute)}
+	if diff := cmp.Diff(want, fake.Qdiscs()); diff != "" {
+		t.Fatalf("qdiscs missmatch after replace (-want +got):\n%s", diff)
+	}
+}
+
+func TestClass(t *testing.T) {
+	fake := NewFakeTc()
+	defer fake.Close()
+
+	htb := qdisc(1337, core.BuildHandle(0x1, 0x0), tc.HandleRoot,
+		tc.Attribute{Kind: "htb", Htb: &tc.Htb{Init: &tc.HtbGlob{Defcls: 0x30}}})
+	class := qdisc(1337, core.BuildHandle(0x1, 0x1), core.BuildHandle(0x1, 0x0),
+		tc.Attribute{Kind: "htb", Htb: &tc.Htb{DirectQlen: uint32Ptr(4455)}})
+
+	// Classes require their qdisc.
+	if err := fake.Class().Add(&class); !errors.Is(err, syscall.ENOENT) {
+		t.Fatalf("expected ENOENT without qdisc but got %v", err)
+	}
+	if err := fake.Qdisc().Add(&htb); err != nil {
+		t.Fatalf("could not add qdisc: %v", err)
+	}
+	if err := fake.Class().Add(&class); err != nil {
+		t.Fatalf("could not add class: %v", err)
+	}
+	if err := fake.Class().Add(&class); !errors.Is(err, syscall.EEXIST) {
+		t.Fatalf("expected EEXIST for a second class but got %v", err)
+	}
+	class.Htb.DirectQlen = uint32Ptr(10)
+	if err := fake.Class().Replace(&class); err != nil {
+		t.Fatalf("could not replace class: %v", err)
+	}
+	child := qdisc(1337, core.BuildHandle(0x1, 0x2), core.BuildHandle(0x1, 0x0),
+		tc.Attribute{Kind: "htb", Htb: &tc.Htb{DirectQlen: uint32Ptr(1)}})
+	if err := fake.Class().Add(&child); err != nil {
+		t.Fatalf("could not add class: %v", err)
+	}
+
+	classes, err := fake.Class().Get(&tc.Msg{Ifindex: 1337})
+	if err != nil {
+		t.Fatalf("could not get classes: %v", err)
+	}
+	if diff := cmp.Diff([]tc.Object{class, child}, classes); diff != "" {
+		t.Fatalf("classes missmatch (-want +got):\n%s", diff)
+	}
+	if classes, err := fake.Class().Get(&tc.Msg{Ifindex: 1}); err != nil || len(classes) != 0 {
+		t.Fatalf("expected no classes of another device but got %v, %v", classes, err)
+	}
+
+	if err := fake.Class().Delete(&child); err != nil {
+		t.Fatalf("could not delete class: %v", err)
+	}
+	if err := fake.Class().Delete(&child); !errors.Is(err, syscall.ENOENT) {
+		t.Fatalf("expected ENOENT for a deleted class but got %v", err)
+	}
+	// Deleting the qdisc removes its classes.
+	if err := fake.Qdisc().Delete(&htb); err != nil {
+		t.Fatalf("could not delete qdisc: %v", err)
+	}
+	if classes := fake.Classes(); len(classes) != 0 {
+		t.Fatalf("expected no classes but got %v", classes)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	fake := NewFakeTc()
+	defer fake.Close()
+
+	ingress := tc.HandleClsactIngress
+	u32 := tc.Attribute{Kind: "u32", U32: &tc.U32{ClassID: uint32Ptr(13), Sel: &tc.U32Sel{
+		Flags: 0x1,
+		NKeys: 0x1,
+		Keys:  []tc.U32Key{{Mask: 0xFFFFFFFF, Val: 0x0100000A, Off: 16}},
+	}}}
+	matchall := tc.Attribute{Kind: "matchall", Matchall: &tc.Matchall{ClassID: uint32Ptr(1)}}
+	flower := tc.Attribute{Kind: "flower", Flower: &tc.Flower{ClassID: uint32Ptr(13), KeyVlanID: uint16Ptr(1),
+		KeyEthType: uint16Ptr(0x8100)}}
+	// ETH_P_ALL in network byte order.
+	const all = 0x0300
+
+	first := filter(1337, 0x1, ingress, core.BuildHandle(2, all), u32)
+	if err := fake.Filter().Add(&first); !errors.Is(err, syscall.EINVAL) {
+		t.Fatalf("expected EINVAL without qdisc but got %v", err)
+	}
+	clsact := qdisc(1337, core.BuildHandle(0xFFFF, 0x0), tc.HandleIngress, tc.Attribute{Kind: "clsact"})
+	if err := fake.Qdisc().Add(&clsact); err != nil {
+		t.Fatalf("could not add qdisc: %v", err)
+	}
+
+	added := []tc.Object{
+		first,
+		filter(1337, 0, ingress, core.BuildHandle(2, all), u32),
+		filter(1337, 0x1, ingress, all, matchall),
+		filter(1337, 0x1, ingress, core.BuildHandle(1, all), tc.Attribute{Kind: "flower", Chain: uint32Ptr(3),
+			Flower: flower.Flower}),
+	}
+	for _, obj := range added {
+		obj := obj
+		if err := fake.Filter().Add(&obj); err != nil {
+			t.Fatalf("could not add filter %s: %v", obj, err)
+		}
+	}
+	if err := fake.Filter().Add(&first); !errors.Is(err, syscall.EEXIST) {
+		t.Fatalf("expected EEXIST for a second filter but got %v", err)
+	}
+	other := filter(1337, 0x5, ingress, core.BuildHandle(2, all), matchall)
+	if err := fake.Filter().Add(&other); !errors.Is(err, syscall.EINVAL) {
+		t.Fatalf("expected EINVAL for another kind of the same priority but got %v", err)
+	}
+
+	// The dump is sorted by chain, priority and handle. The kernel assigns
+	// the missing priority and handles.
+	want := []tc.Object{
+		// Without priority, the filter comes before the first one.
+		filter(1337, 0x1, ingress, core.BuildHandle(1, all), matchall),
+		added[0],
+		filter(1337, 0x2, ingress, core.BuildHandle(2, all), u32),
+		added[3],
+	}
+	filters, err := fake.Filter().Get(&tc.Msg{Ifindex: 1337, Parent: ingress})
+	if err != nil {
+		t.Fatalf("could not get filters: %v", err)
+	}
+	if diff := cmp.Diff(want, filters); diff != "" {
+		t.Fatalf("filters missmatch (-want +got):\n%s", diff)
+	}
+
+	chains, err := fake.Chain().Get(&tc.Msg{Ifindex: 1337, Parent: ingress})
+	if err != nil {
+		t.Fatalf("could not get chains: %v", err)
+	}
+	if diff := cmp.Diff([]tc.Object{
+		filter(1337, 0, ingress, 0, tc.Attribute{Chain: uint32Ptr(0)}),
+		filter(1337, 0, ingress, 0, tc.Attribute{Chain: uint32Ptr(3)}),
+	}, chains); diff != "" {
+		t.Fatalf("chains missmatch (-want +got):\n%s", diff)
+	}
+
+	if err := fake.Filter().Delete(&want[1]); err != nil {
+		t.Fatalf("could not delete filter: %v", err)
+	}
+	if err := fake.Filter().Delete(&want[1]); !errors.Is(err, syscall.ENOENT) {
+		t.Fatalf("expected ENOENT for a deleted filter but got %v", err)
+	}
+	// Without priority, FlushAll removes all filters of the chain.
+	if err := fake.Filter().FlushAll(&tc.Object{Msg: tc.Msg{Ifindex: 1337, Parent: ingress}}); err != nil {
+		t.Fatalf("could not flush filters: %v", err)
+	}
+	if diff := cmp.Diff([]tc.Object{added[3]}, fake.Filters()); diff != "" {
+		t.Fatalf("filters missmatch after flush (-want +got):\n%s", diff)
+	}
+	// Deleting the qdisc removes its filters.
+	if err := fake.Qdisc().Delete(&clsact); err != nil {
+		t.Fatalf("could not delete qdisc: %v", err)
+	}
+	if filters := fake.Filters(); len(filters) != 0 {
+		t.Fatalf("expected no filters but got %v", filters)
+	}
+}
+
+func TestFilterBlock(t *testing.T) {
+	fake := NewFakeTc()
+	defer fake.Close()
+	obj := filter(tc.MagicBlock, 0x1, 22, core.BuildHandle(1, 0x0300),
+		tc.Attribute{Kind: "matchall", Matchall: &tc.Matchall{ClassID: uint32Ptr(1)}})
+	if err := fake.Filter().Add(&obj); err != nil {
+		t.Fatalf("could not add filter: %v", err)
+	}
+	filters, err := fake.Filter().Get(&tc.Msg{Ifindex: tc.MagicBlock, Parent: 22})
+	if err != nil {
+		t.Fatalf("could not get filters: %v", err)
+	}
+	if diff := cmp.Diff([]tc.Object{obj}, filters); diff != "" {
+		t.Fatalf("filters missmatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestChain(t *testing.T) {
+	fake := NewFakeTc()
+	defer fake.Close()
+	htb := qdisc(42, core.BuildHandle(0x1, 0x0), tc.HandleRoot,
+		tc.Attribute{Kind: "htb", Htb: &tc.Htb{Init: &tc.HtbGlob{Defcls: 0x30}}})
+	if err := fake.Qdisc().Add(&htb); err != nil {
+		t.Fatalf("could not add qdisc: %v", err)
+	}
+	chain := qdisc(42, 0, core.BuildHandle(0x1, 0x0), tc.Attribute{Chain: uint32Ptr(7)})
+	if err := fake.Chain().Add(&chain); err != nil {
+		t.Fatalf("could not add chain: %v", err)
+	}
+	if err := fake.Chain().Add(&chain); !errors.Is(err, syscall.EEXIST) {
+		t.Fatalf("expected EEXIST for a second chain but got %v", err)
+	}
+	chains, err := fake.Chain().Get(&tc.Msg{Ifindex: 42, Parent: core.BuildHandle(0x1, 0x0)})
+	if err != nil {
+		t.Fatalf("could not get chains: %v", err)
+	}
+	if diff := cmp.Diff([]tc.Object{chain}, chains); diff != "" {
+		t.Fatalf("chains missmatch (-want +got):\n%s", diff)
+	}
+	if err := fake.Chain().Delete(&chain); err != nil {
+		t.Fatalf("could not delete chain: %v", err)
+	}
+	if err := fake.Chain().Delete(&chain); !errors.Is(err, syscall.ENOENT) {
+		t.Fatalf("expected ENOENT for a deleted chain but got %v", err)
+	}
+}
+
+func TestErrorHook(t *testing.T) {
+	fake := NewFakeTc()
+	defer fake.Close()
+	obj := qdisc(1, core.BuildHandle(0x1, 0x0), tc.HandleRoot, tc.Attribute{Kind: "fq_codel",
+		FqCodel: &tc.FqCodel{Limit: uint32Ptr(42)}})
+	errSocket := errors.New("socket failed")
+
+	tests := map[string]struct {
+		hook func(Request) error
+		err  error
+	}{
+		"errno":           {hook: func(Request) error { return syscall.EBUSY }, err: syscall.EBUSY},
+		"wrapped errno":   {hook: func(Request) error { return fmt.Errorf("busy: %w", syscall.EPERM) }, err: syscall.EPERM},
+		"error of socket": {hook: func(Request) error { return errSocket }, err: errSocket},
+		"other request":   {hook: func(r Request) error { return nil }},
+		"state of the fake": {hook: func(r Request) error {
+			// The hooks may inspect the fake.
+			if len(fake.Qdiscs()) != 0 {
+				return syscall.EEXIST
+			}
+			if r.Type != unix.RTM_NEWQDISC || r.Object.Kind != "fq_codel" {
+				return fmt.Errorf("unexpected request %v", r)
+			}
+			return nil
+		}},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer fake.Qdisc().Delete(&obj)
+			fake.SetErrorHook(testcase.hook)
+			defer fake.SetErrorHook(nil)
+
+			if err := fake.Qdisc().Add(&obj); !errors.Is(err, testcase.err) {
+				t.Fatalf("expected error %v but got %v", testcase.err, err)
+			}
+			if len(fake.Qdiscs()) != 0 && testcase.err != nil {
+				t.Fatalf("refused request changed the qdiscs: %v", fake.Qdiscs())
+			}
+		})
+	}
+}
+
+func TestStatsHook(t *testing.T) {
+	fake := NewFakeTc()
+	defer fake.Close()
+	htb := qdisc(1, core.BuildHandle(0x1, 0x0), tc.HandleRoot,
+		tc.Attribute{Kind: "htb", Htb: &tc.Htb{Init: &tc.HtbGlob{Defcls: 0x30}}})
+	class := qdisc(1, core.BuildHandle(0x1, 0x1), core.BuildHandle(0x1, 0x0),
+		tc.Attribute{Kind: "htb", Htb: &tc.Htb{DirectQlen: uint32Ptr(1)}})
+	for _, add := range []func(*tc.Object) error{fake.Qdisc().Add, fake.Class().Add} {
+		if err := add(&htb); err != nil && !errors.Is(err, syscall.EEXIST) {
+			t.Fatal(err)
+		}
+	}
+	if err := fake.Class().Add(&class); err != nil {
+		t.Fatalf("could not add class: %v", err)
+	}
+
+	stats2 := &tc.Stats2{Basic: &tc.GenBasic{Bytes: 42, Packets: 1}, Queue: &tc.GenQueue{QueueLen: 1, Overlimits: 42}}
+	fake.SetStatsHook(func(obj *tc.Object) {
+		obj.Stats = &tc.Stats{Bytes: 32, Packets: 1}
+		obj.Stats2 = stats2
+		obj.XStats = &tc.XStats{Htb: &tc.HtbXStats{Lends: 1, Borrows: 2, Giants: 3}}
+	})
+
+	gotStats2, xstats, err := fake.Class().Stats(1, class.Handle)
+	if err != nil {
+		t.Fatalf("could not get stats of class: %v", err)
+	}
+	if diff := cmp.Diff(stats2, gotStats2); diff != "" {
+		t.Fatalf("stats2 missmatch (-want +got):\n%s", diff)
+	}
+	if xstats == nil || xstats.Htb == nil || xstats.Htb.Borrows != 2 {
+		t.Fatalf("could not decode xstats: %#v", xstats)
+	}
+	if _, _, err := fake.Class().Stats(1, core.BuildHandle(0x1, 0x2)); !errors.Is(err, syscall.ENOENT) {
+		t.Fatalf("expected ENOENT for a missing class but got %v", err)
+	}
+
+	qdiscs, err := fake.Qdisc().Get()
+	if err != nil {
+		t.Fatalf("could not get qdiscs: %v", err)
+	}
+	if len(qdiscs) != 1 || qdiscs[0].Stats == nil || qdiscs[0].Stats.Bytes != 32 {
+		t.Fatalf("could not decode stats: %v", qdiscs)
+	}
+	// The stats are only added to the replies.
+	if stored := fake.Qdiscs(); stored[0].Stats != nil {
+		t.Fatalf("stats were stored: %v", stored[0].Stats)
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	fake := NewFakeTc()
+	defer fake.Close()
+	const conns = 8
+
+	var wg sync.WaitGroup
+	errs := make(chan error, conns)
+	for i := uint32(1); i <= conns; i++ {
+		wg.Add(1)
+		go func(ifindex uint32) {
+			defer wg.Done()
+			rtnl, err := fake.Open(nil)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer rtnl.Close()
+			obj := qdisc(ifindex, core.BuildHandle(0x1, 0x0), tc.HandleRoot, tc.Attribute{Kind: "fq_codel",
+				FqCodel: &tc.FqCodel{Limit: uint32Ptr(ifindex)}})
+			if err := rtnl.Qdisc().Add(&obj); err != nil {
+				errs <- err
+				return
+			}
+			if _, err := rtnl.Qdisc().Get(); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if qdiscs := fake.Qdiscs(); len(qdiscs) != conns {
+		t.Fatalf("expected %d qdiscs but got %d", conns, len(qdiscs))
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	fake := NewFakeTc()
+	defer fake.Close()
+	if _, err := fake.Actions().Get(nil); !errors.Is(err, syscall.EOPNOTSUPP) {
+		t.Fatalf("expected EOPNOTSUPP for actions but got %v", err)
+	}
+	if _, err := fake.Open(&tc.Config{ExtendedAck: true}); err == nil {
+		t.Fatalf("expected error for extended acknowledgements")
+	}
+}
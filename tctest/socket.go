@@ -0,0 +1,218 @@
+package tctest
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/florianl/go-tc/internal/unix"
+	"github.com/mdlayher/netlink"
+)
+
+// defaultReadBuffer is the receive buffer of a socket, if Config.ReadBuffer is
+// not set. It is the default of net.core.rmem_default.
+const defaultReadBuffer = 212992
+
+// datagram is a part of the receive queue of a socket.
+type datagram struct {
+	msgs []netlink.Message
+	err  error
+	size int
+}
+
+// socket is the netlink.Socket of a connection to a FakeTc. Like a netlink
+// socket of the kernel, it queues the replies to requests and the events of
+// the joined multicast group in its receive buffer. Events, that do not fit
+// into the buffer, are dropped and the next Receive fails with ENOBUFS.
+type socket struct {
+	fake *FakeTc
+	pid  uint32
+
+	mu       sync.Mutex
+	queue    []datagram
+	queued   int
+	buffer   int
+	joined   bool
+	overrun  bool
+	closed   bool
+	deadline time.Time
+	// wake is signalled, when one of the fields above changes.
+	wake chan struct{}
+}
+
+var _ netlink.Socket = &socket{}
+
+func newSocket(fake *FakeTc, pid uint32) *socket {
+	return &socket{fake: fake, pid: pid, buffer: defaultReadBuffer, wake: make(chan struct{}, 1)}
+}
+
+// signal wakes a blocked Receive. It has to be called with s.mu held.
+func (s *socket) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *socket) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return os.ErrClosed
+	}
+	s.closed = true
+	s.signal()
+	s.mu.Unlock()
+	s.fake.removeSocket(s)
+	return nil
+}
+
+func (s *socket) Send(m netlink.Message) error {
+	return s.SendMessages([]netlink.Message{m})
+}
+
+func (s *socket) SendMessages(msgs []netlink.Message) error {
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return os.ErrClosed
+	}
+	for _, m := range msgs {
+		replies, err := s.fake.handle(m)
+		s.mu.Lock()
+		// Replies are never dropped, only events are.
+		s.queue = append(s.queue, datagram{msgs: replies, err: err, size: size(replies)})
+		s.queued += size(replies)
+		s.signal()
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+func (s *socket) Receive() ([]netlink.Message, error) {
+	for {
+		s.mu.Lock()
+		switch {
+		case s.closed:
+			s.mu.Unlock()
+			return nil, os.ErrClosed
+		case s.overrun:
+			// Like sock_error(), the overrun is reported once before the
+			// datagrams, that are still queued.
+			s.overrun = false
+			s.mu.Unlock()
+			return nil, os.NewSyscallError("recvmsg", syscall.ENOBUFS)
+		case len(s.queue) > 0:
+			d := s.queue[0]
+			s.queue = s.queue[1:]
+			s.queued -= d.size
+			s.mu.Unlock()
+			return d.msgs, d.err
+		}
+		var timeout <-chan time.Time
+		if !s.deadline.IsZero() {
+			wait := time.Until(s.deadline)
+			if wait <= 0 {
+				s.mu.Unlock()
+				return nil, os.NewSyscallError("recvmsg", timeoutError{})
+			}
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-s.wake:
+		case <-timeout:
+		}
+	}
+}
+
+// deliver queues the event msgs, if s joined RTNLGRP_TC.
+func (s *socket) deliver(msgs []netlink.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.joined || s.closed {
+		return
+	}
+	if s.queued+size(msgs) > s.buffer {
+		s.overrun = true
+	} else {
+		s.queue = append(s.queue, datagram{msgs: msgs, size: size(msgs)})
+		s.queued += size(msgs)
+	}
+	s.signal()
+}
+
+func (s *socket) JoinGroup(group uint32) error {
+	return s.setGroup(group, true)
+}
+
+func (s *socket) LeaveGroup(group uint32) error {
+	return s.setGroup(group, false)
+}
+
+func (s *socket) setGroup(group uint32, joined bool) error {
+	if group != unix.RTNLGRP_TC {
+		return syscall.EINVAL
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.joined = joined
+	return nil
+}
+
+func (s *socket) SetDeadline(t time.Time) error {
+	return s.SetReadDeadline(t)
+}
+
+func (s *socket) SetReadDeadline(t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deadline = t
+	s.signal()
+	return nil
+}
+
+func (s *socket) SetWriteDeadline(t time.Time) error {
+	// Requests are answered at once and never block.
+	return nil
+}
+
+// SetReadBuffer sets the size of the receive buffer in bytes. Unlike the
+// kernel, the size is not doubled for the overhead of the bookkeeping.
+func (s *socket) SetReadBuffer(bytes int) error {
+	if bytes <= 0 {
+		return syscall.EINVAL
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buffer = bytes
+	return nil
+}
+
+func (s *socket) SetWriteBuffer(bytes int) error {
+	return nil
+}
+
+// size returns the number of bytes, that msgs occupy in the receive buffer.
+func size(msgs []netlink.Message) int {
+	var n int
+	for _, m := range msgs {
+		n += nlmsgHeaderLen + len(m.Data)
+	}
+	return n
+}
+
+// nlmsgHeaderLen is the size of struct nlmsghdr.
+const nlmsgHeaderLen = 16
+
+// timeoutError is returned by Receive, when the read deadline passed.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
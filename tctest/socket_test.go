@@ -0,0 +1,221 @@
+package tctest
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	tc "github.com/florianl/go-tc"
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/netlink"
+)
+
+type event struct {
+	action uint16
+	obj    tc.Object
+}
+
+// monitor starts to monitor fake on a new connection. The hook blocks until
+// release is readable.
+func monitor(t *testing.T, fake *FakeTc, config *tc.Config, release <-chan struct{}) (*tc.Tc, <-chan event, <-chan error, context.CancelFunc) {
+	t.Helper()
+	rtnl, err := fake.Open(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan event, 16)
+	errs := make(chan error, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	hook := func(action uint16, obj tc.Object) int {
+		events <- event{action: action, obj: obj}
+		<-release
+		return 0
+	}
+	errfn := func(err error) int {
+		errs <- err
+		return 0
+	}
+	if err := rtnl.MonitorWithErrorFunc(ctx, 10*time.Millisecond, hook, errfn); err != nil {
+		cancel()
+		rtnl.Close()
+		t.Fatal(err)
+	}
+	return rtnl, events, errs, cancel
+}
+
+func nextEvent(t *testing.T, events <-chan event, errs <-chan error) event {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case err := <-errs:
+		t.Fatalf("expected event but got %v", err)
+	case <-time.After(time.Second):
+		t.Fatalf("expected event but got none")
+	}
+	return event{}
+}
+
+func nextError(t *testing.T, events <-chan event, errs <-chan error) error {
+	t.Helper()
+	select {
+	case e := <-events:
+		t.Fatalf("expected error but got event %d for %s", e.action, e.obj.String())
+	case err := <-errs:
+		return err
+	case <-time.After(time.Second):
+		t.Fatalf("expected error but got none")
+	}
+	return nil
+}
+
+func TestEmitEvent(t *testing.T) {
+	tests := map[string]struct {
+		typ netlink.HeaderType
+		obj tc.Object
+	}{
+		"new qdisc": {typ: unix.RTM_NEWQDISC, obj: qdisc(2, core.BuildHandle(0x1, 0x0), tc.HandleRoot,
+			tc.Attribute{Kind: "fq_codel", FqCodel: &tc.FqCodel{Limit: uint32Ptr(1000)}})},
+		"deleted class": {typ: unix.RTM_DELTCLASS, obj: tc.Object{Msg: tc.Msg{Family: unix.AF_UNSPEC, Ifindex: 2,
+			Handle: core.BuildHandle(0x1, 0x1), Parent: core.BuildHandle(0x1, 0x0)},
+			Attribute: tc.Attribute{Kind: "htb", Htb: &tc.Htb{Rate64: uint64Ptr(96)}}}},
+		"new filter": {typ: unix.RTM_NEWTFILTER, obj: filter(2, 1, core.BuildHandle(0x1, 0x0), 0x10300,
+			tc.Attribute{Kind: "matchall", Chain: uint32Ptr(0), Matchall: &tc.Matchall{ClassID: uint32Ptr(0x10001)}})},
+	}
+
+	fake := NewFakeTc()
+	defer fake.Close()
+	release := make(chan struct{})
+	close(release)
+	rtnl, events, errs, cancel := monitor(t, fake, nil, release)
+	defer rtnl.Close()
+	defer cancel()
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			obj := testcase.obj
+			if err := fake.EmitEvent(testcase.typ, &obj); err != nil {
+				t.Fatal(err)
+			}
+			got := nextEvent(t, events, errs)
+			if got.action != uint16(testcase.typ) {
+				t.Fatalf("expected action %d but got %d", testcase.typ, got.action)
+			}
+			if diff := cmp.Diff(testcase.obj, got.obj); diff != "" {
+				t.Fatalf("event does not match (-want +got):\n%s", diff)
+			}
+		})
+	}
+	t.Run("invalid object", func(t *testing.T) {
+		if err := fake.EmitEvent(unix.RTM_GETQDISC, &tc.Object{}); !errors.Is(err, tc.ErrInvalidArg) {
+			t.Fatalf("expected ErrInvalidArg but got %v", err)
+		}
+	})
+}
+
+func TestEmitRaw(t *testing.T) {
+	fake := NewFakeTc()
+	defer fake.Close()
+	release := make(chan struct{})
+	close(release)
+	rtnl, events, errs, cancel := monitor(t, fake, nil, release)
+	defer rtnl.Close()
+	defer cancel()
+
+	t.Run("truncated tcmsg", func(t *testing.T) {
+		// Objects, that can not be decoded, are skipped by the monitor.
+		fake.EmitRaw(netlink.Message{Header: netlink.Header{Type: unix.RTM_NEWQDISC}, Data: []byte{0x0}})
+		obj := qdisc(2, core.BuildHandle(0x1, 0x0), tc.HandleRoot, tc.Attribute{Kind: "fq_codel", FqCodel: &tc.FqCodel{Limit: uint32Ptr(1000)}})
+		if err := fake.EmitEvent(unix.RTM_NEWQDISC, &obj); err != nil {
+			t.Fatal(err)
+		}
+		if got := nextEvent(t, events, errs); got.obj.Handle != obj.Handle {
+			t.Fatalf("expected event for %s but got %s", obj.String(), got.obj.String())
+		}
+	})
+	t.Run("truncated error", func(t *testing.T) {
+		fake.EmitRaw(netlink.Message{Header: netlink.Header{Type: netlink.Error}, Data: []byte{0x0}})
+		if err := nextError(t, events, errs); err == nil {
+			t.Fatalf("expected error for truncated error message")
+		}
+	})
+}
+
+func TestSubscription(t *testing.T) {
+	fake := NewFakeTc()
+	defer fake.Close()
+	obj := qdisc(2, core.BuildHandle(0x1, 0x0), tc.HandleRoot, tc.Attribute{Kind: "fq_codel", FqCodel: &tc.FqCodel{Limit: uint32Ptr(1000)}})
+	if err := fake.EmitEvent(unix.RTM_NEWQDISC, &obj); err != nil {
+		t.Fatal(err)
+	}
+	// An event in the receive buffer of fake.Tc would be received as reply
+	// with a wrong sequence number.
+	if _, err := fake.Qdisc().Get(); err != nil {
+		t.Fatalf("expected no event without monitor but got %v", err)
+	}
+
+	release := make(chan struct{})
+	close(release)
+	rtnl, events, errs, cancel := monitor(t, fake, nil, release)
+	defer rtnl.Close()
+	cancel()
+	// After the deadline, the monitor stops with a timeout.
+	var timeout interface{ Timeout() bool }
+	if err := nextError(t, events, errs); !errors.As(err, &timeout) || !timeout.Timeout() {
+		t.Fatalf("expected timeout but got %v", err)
+	}
+}
+
+func TestOverrun(t *testing.T) {
+	fake := NewFakeTc()
+	defer fake.Close()
+	newEvent := func(major uint32) tc.Object {
+		return qdisc(2, core.BuildHandle(major, 0x0), tc.HandleRoot, tc.Attribute{Kind: "fq_codel", FqCodel: &tc.FqCodel{Limit: uint32Ptr(1000)}})
+	}
+	obj := newEvent(0x1)
+	msg, err := tc.MarshalReply(unix.RTM_NEWQDISC, &obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The receive buffer holds one event and the end of the dump of the
+	// devices, that the monitor requests, when it starts.
+	release := make(chan struct{})
+	config := &tc.Config{ReadBuffer: 2*nlmsgHeaderLen + 4 + len(msg.Data)}
+	rtnl, events, errs, cancel := monitor(t, fake, config, release)
+	defer rtnl.Close()
+	defer cancel()
+
+	emit := func(major uint32) {
+		t.Helper()
+		obj := newEvent(major)
+		if err := fake.EmitEvent(unix.RTM_NEWQDISC, &obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect := func(major uint32) {
+		t.Helper()
+		if got := nextEvent(t, events, errs); got.obj.Handle != core.BuildHandle(major, 0x0) {
+			t.Fatalf("expected event for %x: but got %s", major, got.obj.String())
+		}
+	}
+
+	emit(0x1)
+	// The monitor blocks in the hook and does not receive further events.
+	expect(0x1)
+	emit(0x2)
+	emit(0x3)
+	close(release)
+
+	if err := nextError(t, events, errs); !errors.Is(err, syscall.ENOBUFS) {
+		t.Fatalf("expected ENOBUFS but got %v", err)
+	}
+	// The event, that was buffered before the overrun, is still received.
+	expect(0x2)
+	emit(0x4)
+	expect(0x4)
+}
@@ -0,0 +1,161 @@
+package tc
+
+import (
+	"fmt"
+
+	"github.com/florianl/go-tc/core"
+)
+
+// Node is a qdisc or class in the hierarchy returned by BuildTree.
+type Node struct {
+	Object   Object
+	Parent   *Node
+	Children []*Node
+
+	// Orphans is only set on the node returned by BuildTree and contains all
+	// qdiscs and classes, whose parent is not part of the dump.
+	Orphans []*Node
+
+	virtual bool
+}
+
+// IsClass reports whether the node is a class.
+func (n *Node) IsClass() bool {
+	return !n.virtual && n.isClass()
+}
+
+func (n *Node) isClass() bool {
+	_, min := core.SplitHandle(n.Object.Handle)
+	return min != 0
+}
+
+type treeKey struct {
+	ifindex uint32
+	handle  uint32
+}
+
+// multiQueueKinds contain the kinds, that create a qdisc per transmit queue, whose
+// parent is a class of the multiqueue qdisc.
+var multiQueueKinds = map[string]bool{
+	"mq":     true,
+	"mqprio": true,
+	"taprio": true,
+}
+
+// BuildTree reconstructs the hierarchy of qdiscs and classes from the flat results
+// of Qdisc().Get() and Class().Get(). The returned node does not represent an object
+// itself. Its children are the root and ingress/clsact qdiscs of all interfaces.
+// Objects, whose parent can not be found, are collected in Orphans of the returned node.
+func BuildTree(qdiscs, classes []Object) (*Node, error) {
+	root := &Node{virtual: true}
+
+	qdiscNodes := make(map[treeKey]*Node)
+	classNodes := make(map[treeKey]*Node)
+
+	var qNodes, cNodes []*Node
+	for _, qdisc := range qdiscs {
+		n := &Node{Object: qdisc}
+		qNodes = append(qNodes, n)
+		if n.isClass() {
+			return nil, fmt.Errorf("qdisc %s on %d: handle %x with non-zero minor: %w",
+				qdisc.Kind, qdisc.Ifindex, qdisc.Handle, ErrInvalidArg)
+		}
+		if qdisc.Handle == 0 {
+			// qdiscs without handle, like the per queue qdiscs of mq, can not be a parent
+			continue
+		}
+		key := treeKey{qdisc.Ifindex, qdisc.Handle}
+		if _, ok := qdiscNodes[key]; ok {
+			return nil, fmt.Errorf("qdisc %x on %d is part of the dump multiple times: %w",
+				qdisc.Handle, qdisc.Ifindex, ErrInvalidArg)
+		}
+		qdiscNodes[key] = n
+	}
+	for _, class := range classes {
+		n := &Node{Object: class}
+		cNodes = append(cNodes, n)
+		key := treeKey{class.Ifindex, class.Handle}
+		if _, ok := classNodes[key]; ok {
+			return nil, fmt.Errorf("class %x on %d is part of the dump multiple times: %w",
+				class.Handle, class.Ifindex, ErrInvalidArg)
+		}
+		classNodes[key] = n
+	}
+
+	// qdiscOf returns the qdisc, a class or qdisc with handle belongs to.
+	qdiscOf := func(ifindex, handle uint32) *Node {
+		maj, _ := core.SplitHandle(handle)
+		return qdiscNodes[treeKey{ifindex, core.BuildHandle(maj, 0)}]
+	}
+
+	for _, n := range cNodes {
+		var parent *Node
+		_, min := core.SplitHandle(n.Object.Parent)
+		if n.Object.Parent == HandleRoot || min == 0 {
+			// Top level classes refer to root or the qdisc itself as parent
+			parent = qdiscOf(n.Object.Ifindex, n.Object.Handle)
+		} else {
+			parent = classNodes[treeKey{n.Object.Ifindex, n.Object.Parent}]
+		}
+		root.adopt(parent, n)
+	}
+
+	for _, n := range qNodes {
+		var parent *Node
+		switch n.Object.Parent {
+		case HandleRoot, HandleIngress:
+			parent = root
+		default:
+			parent = classNodes[treeKey{n.Object.Ifindex, n.Object.Parent}]
+			if parent != nil {
+				break
+			}
+			// The per queue qdiscs of multiqueue qdiscs are attached to classes,
+			// that might not be part of the dump.
+			if mq := qdiscOf(n.Object.Ifindex, n.Object.Parent); mq != nil && multiQueueKinds[mq.Object.Kind] {
+				parent = mq
+			}
+		}
+		root.adopt(parent, n)
+	}
+
+	return root, nil
+}
+
+// adopt attaches child to parent or, if parent is nil, to the orphans of n.
+func (n *Node) adopt(parent, child *Node) {
+	if parent == nil {
+		n.Orphans = append(n.Orphans, child)
+		return
+	}
+	child.Parent = parent
+	parent.Children = append(parent.Children, child)
+}
+
+// Walk calls fn for n and all its descendants in depth-first order. depth is
+// the distance to n. If n is the node returned by BuildTree, only its
+// descendants are visited and its children have a depth of 0.
+// If fn returns an error, Walk stops and returns this error.
+func (n *Node) Walk(fn func(depth int, n *Node) error) error {
+	if n.virtual {
+		for _, child := range n.Children {
+			if err := child.walk(0, fn); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return n.walk(0, fn)
+}
+
+func (n *Node) walk(depth int, fn func(depth int, n *Node) error) error {
+	if err := fn(depth, n); err != nil {
+		return err
+	}
+	for _, child := range n.Children {
+		if err := child.walk(depth+1, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
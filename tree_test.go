@@ -0,0 +1,123 @@
+package tc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func treeObject(ifindex, handle, parent uint32, kind string) Object {
+	return Object{Msg{Ifindex: ifindex, Handle: handle, Parent: parent}, Attribute{Kind: kind}}
+}
+
+func TestBuildTree(t *testing.T) {
+	qdiscs := []Object{
+		treeObject(2, 0x10000, HandleRoot, "htb"),
+		treeObject(2, 0x100000, 0x10010, "fq_codel"),
+		treeObject(2, 0x200000, 0x10020, "fq_codel"),
+		treeObject(2, 0xFFFF0000, HandleIngress, "clsact"),
+		treeObject(3, 0x80010000, HandleRoot, "mq"),
+		treeObject(3, 0x0, 0x80010001, "fq_codel"),
+		treeObject(3, 0x0, 0x80010002, "fq_codel"),
+		// Leaf of a class, that is not part of the dump
+		treeObject(2, 0x300000, 0x10030, "sfq"),
+		treeObject(1, 0x0, HandleRoot, "noqueue"),
+	}
+	classes := []Object{
+		treeObject(2, 0x10001, HandleRoot, "htb"),
+		treeObject(2, 0x10010, 0x10001, "htb"),
+		treeObject(2, 0x10020, 0x10001, "htb"),
+		treeObject(3, 0x80010001, HandleRoot, "mq"),
+		// Class of a qdisc, that is not part of the dump
+		treeObject(2, 0x50001, HandleRoot, "htb"),
+	}
+
+	root, err := BuildTree(qdiscs, classes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got []string
+	if err := root.Walk(func(depth int, n *Node) error {
+		typ := "qdisc"
+		if n.IsClass() {
+			typ = "class"
+		}
+		got = append(got, fmt.Sprintf("%s%d %s %s %x", strings.Repeat("  ", depth),
+			n.Object.Ifindex, typ, n.Object.Kind, n.Object.Handle))
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		"2 qdisc htb 10000",
+		"  2 class htb 10001",
+		"    2 class htb 10010",
+		"      2 qdisc fq_codel 100000",
+		"    2 class htb 10020",
+		"      2 qdisc fq_codel 200000",
+		"2 qdisc clsact ffff0000",
+		"3 qdisc mq 80010000",
+		"  3 class mq 80010001",
+		"    3 qdisc fq_codel 0",
+		"  3 qdisc fq_codel 0",
+		"1 qdisc noqueue 0",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("tree missmatch (-want +got):\n%s", diff)
+	}
+
+	var orphans []uint32
+	for _, n := range root.Orphans {
+		orphans = append(orphans, n.Object.Handle)
+	}
+	if diff := cmp.Diff([]uint32{0x50001, 0x300000}, orphans); diff != "" {
+		t.Fatalf("orphans missmatch (-want +got):\n%s", diff)
+	}
+
+	htb := root.Children[0]
+	if htb.Parent != root || htb.Children[0].Parent != htb {
+		t.Fatal("unexpected parent relation")
+	}
+
+	// Walk from an inner node and stop on error
+	errStop := errors.New("stop")
+	var visited int
+	err = htb.Walk(func(depth int, n *Node) error {
+		visited++
+		if n.Object.Handle == 0x10010 {
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Is(err, errStop) || visited != 3 {
+		t.Fatalf("expected to stop after 3 nodes with errStop, but visited %d and got %v", visited, err)
+	}
+}
+
+func TestBuildTreeErrors(t *testing.T) {
+	tests := map[string]struct {
+		qdiscs  []Object
+		classes []Object
+	}{
+		"duplicate qdisc": {qdiscs: []Object{
+			treeObject(2, 0x10000, HandleRoot, "htb"),
+			treeObject(2, 0x10000, HandleRoot, "htb"),
+		}},
+		"duplicate class": {classes: []Object{
+			treeObject(2, 0x10001, HandleRoot, "htb"),
+			treeObject(2, 0x10001, HandleRoot, "htb"),
+		}},
+		"qdisc with minor": {qdiscs: []Object{treeObject(2, 0x10001, HandleRoot, "htb")}},
+	}
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := BuildTree(testcase.qdiscs, testcase.classes); !errors.Is(err, ErrInvalidArg) {
+				t.Fatalf("expected ErrInvalidArg but got %v", err)
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package tc
 
 import (
 	"errors"
+	"io"
+	"time"
 )
 
 // Various errors
@@ -20,24 +22,113 @@ var (
 
 	// ErrUnknownKind is returned for unknown qdisc, filter or class types.
 	ErrUnknownKind = errors.New("unknown kind")
+
+	// ErrUnknownAttribute is returned for attributes, that can not be decoded
+	// as they are not known for the kind of the object.
+	ErrUnknownAttribute = errors.New("unknown attribute")
+
+	// ErrNoFreeHandle is returned if all handles of a HandlePool are in use.
+	ErrNoFreeHandle = errors.New("no free handle")
+
+	// ErrWouldOrphan is wrapped by an *OrphanError, that is returned if
+	// replacing or deleting a qdisc would destroy the objects beneath it.
+	ErrWouldOrphan = errors.New("objects beneath it would be destroyed")
+
+	// ErrDumpInterrupted is returned together with the partial result of a
+	// dump, that the kernel marked as inconsistent with NLM_F_DUMP_INTR or
+	// ended with an error after parts of it were received. Get repeats such
+	// dumps a few times before it returns the error. Walk returns it after fn
+	// was called for all received objects.
+	ErrDumpInterrupted = errors.New("dump interrupted")
+
+	// ErrVerificationMismatch is wrapped by a *VerificationError, that is
+	// returned if an object, that was read back after it was written,
+	// differs from the requested one.
+	ErrVerificationMismatch = errors.New("kernel object differs from the request")
+
+	// ErrMessageTooLarge is wrapped by a *MessageSizeError, that is returned
+	// if a request does not fit into a netlink message.
+	ErrMessageTooLarge = errors.New("netlink message too large")
 )
 
 // Config contains options for RTNETLINK
 type Config struct {
 	// NetNS defines the network namespace
 	NetNS int
+
+	// SkipValidation disables the validation of Objects with Object.Validate()
+	// and the checks of their handles and parents before they are added,
+	// replaced or changed.
+	SkipValidation bool
+
+	// Timeout limits the time to wait for the response of the kernel to a
+	// request. Zero means no timeout.
+	Timeout time.Duration
+
+	// ExtendedAck enables extended acknowledgements, which let the kernel
+	// report the reason of an error.
+	ExtendedAck bool
+
+	// StrictCheck enables the strict checking of dump requests by the kernel.
+	StrictCheck bool
+
+	// ReadBuffer sets the size of the receive buffer of the socket in bytes.
+	// Zero keeps the default of the operating system.
+	ReadBuffer int
+
+	// Logger receives a dump of every netlink message, that is sent to or
+	// received from the kernel. Sent messages are prefixed with ">" and
+	// received messages with "<". While monitoring, messages are written
+	// from a separate goroutine.
+	Logger io.Writer
+
+	// LazyStats keeps the statistics of dumped objects undecoded in
+	// Attribute.RawStats. They are decoded with Attribute.DecodeStats and
+	// Attribute.DecodeXStats on demand.
+	LazyStats bool
+
+	// AutoReopen replaces the socket with a new one, after an operation
+	// failed with an error of the socket like ENOBUFS. The socket options
+	// and joined groups are restored on the new socket. Requests, that only
+	// read, or were not sent yet, are sent again once. Other requests return
+	// the error, as they may have been applied by the kernel.
+	AutoReopen bool
+
+	// OrphanCheck refuses to replace or delete a qdisc, if the kernel would
+	// destroy classes, filters or qdiscs beneath it, and returns an
+	// *OrphanError listing them instead. The objects are dumped before each
+	// replace or delete. Qdisc.Force skips the check.
+	OrphanCheck bool
+
+	// VerifyWrites reads qdiscs, classes and filters back after they were
+	// added or replaced and returns a *VerificationError, if the kernel
+	// adjusted the requested configuration. If the kernel refuses to add a
+	// qdisc with EEXIST, the qdiscs are dumped and a *ConflictError names the
+	// existing one. Verify enables it for single calls.
+	VerifyWrites bool
 }
 
-// Constants to define the direction
+// Constants to define the direction. core.ParseHandle and core.FormatHandle
+// name them "root", "ingress", "clsact-ingress" and "clsact-egress".
 const (
-	HandleRoot    uint32 = 0xFFFFFFFF
+	HandleRoot uint32 = 0xFFFFFFFF
+	// HandleIngress is the parent of the ingress qdisc.
 	HandleIngress uint32 = 0xFFFFFFF1
+	// HandleClsact is the parent of the clsact qdisc, which shares it with
+	// the ingress qdisc.
+	HandleClsact = HandleIngress
+	// HandleClsactIngress and HandleClsactEgress are the parents of the
+	// filters of the ingress and egress hook of clsact, like
+	// `tc filter add dev eth0 ingress`.
+	HandleClsactIngress uint32 = 0xFFFFFFF2
+	HandleClsactEgress  uint32 = 0xFFFFFFF3
 
 	HandleMinPriority uint32 = 0xFFE0
 	HandleMinIngress  uint32 = 0xFFF2
 	HandleMinEgress   uint32 = 0xFFF3
 
 	// To alter filter in shared blocks, set Msg.Ifindex to MagicBlock
+	// (TCM_IFINDEX_MAGIC_BLOCK) and Msg.Parent to the index of the block.
 	MagicBlock = 0xFFFFFFFF
 )
 
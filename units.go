@@ -0,0 +1,166 @@
+package tc
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// rateSuffixes from iproute2/tc/tc_util.c. The scale converts the value into bits per second.
+var rateSuffixes = []struct {
+	name  string
+	scale float64
+}{
+	{"bit", 1.},
+	{"Kibit", 1024.},
+	{"kbit", 1000.},
+	{"mibit", 1024. * 1024.},
+	{"mbit", 1000000.},
+	{"gibit", 1024. * 1024. * 1024.},
+	{"gbit", 1000000000.},
+	{"tibit", 1024. * 1024. * 1024. * 1024.},
+	{"tbit", 1000000000000.},
+	{"Bps", 8.},
+	{"KiBps", 8. * 1024.},
+	{"KBps", 8000.},
+	{"MiBps", 8. * 1024. * 1024.},
+	{"MBps", 8000000.},
+	{"GiBps", 8. * 1024. * 1024. * 1024.},
+	{"GBps", 8000000000.},
+	{"TiBps", 8. * 1024. * 1024. * 1024. * 1024.},
+	{"TBps", 8000000000000.},
+}
+
+// sizeSuffixes from iproute2/tc/tc_util.c:get_size(). The scale converts the value into bytes.
+var sizeSuffixes = []struct {
+	name  string
+	scale float64
+}{
+	{"b", 1},
+	{"k", 1024},
+	{"kb", 1024},
+	{"kbit", 1024 / 8},
+	{"m", 1024 * 1024},
+	{"mb", 1024 * 1024},
+	{"mbit", 1024 * 1024 / 8},
+	{"g", 1024 * 1024 * 1024},
+	{"gb", 1024 * 1024 * 1024},
+	{"gbit", 1024 * 1024 * 1024 / 8},
+}
+
+// splitNumber mimics strtod(3) and splits s into its leading floating point
+// value and the remaining suffix.
+func splitNumber(s string) (float64, string, error) {
+	end := 0
+	for end < len(s) && strings.IndexByte("0123456789.eE+-", s[end]) >= 0 {
+		end++
+	}
+	for ; end > 0; end-- {
+		if v, err := strconv.ParseFloat(s[:end], 64); err == nil {
+			return v, s[end:], nil
+		}
+	}
+	return 0, "", fmt.Errorf("%q is not a number: %w", s, ErrInvalidArg)
+}
+
+// ParseRate implements iproute2/tc/tc_util.c:get_rate64().
+// It converts a rate like "10mbit" or "1gbps" into bytes per second. Suffixes are
+// matched case insensitive and a value without suffix is interpreted as bits per
+// second. As in iproute2 the bps suffixes are bytes per second.
+func ParseRate(s string) (uint64, error) {
+	bps, suffix, err := splitNumber(s)
+	if err != nil {
+		return 0, err
+	}
+	if suffix != "" {
+		var found bool
+		for _, rs := range rateSuffixes {
+			if strings.EqualFold(rs.name, suffix) {
+				bps *= rs.scale
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown rate suffix %q: %w", suffix, ErrInvalidArg)
+		}
+	}
+	if bps < 0 || math.IsNaN(bps) {
+		return 0, fmt.Errorf("invalid rate %q: %w", s, ErrInvalidArg)
+	}
+
+	// bits per second -> bytes per second
+	bps /= 8
+	if bps >= math.MaxUint64 {
+		return 0, fmt.Errorf("rate %q overflows: %w", s, ErrInvalidArg)
+	}
+	return uint64(bps), nil
+}
+
+// ParseSize implements iproute2/tc/tc_util.c:get_size().
+// It converts a size like "1500b" or "32k" into bytes. Suffixes are matched
+// case insensitive, k, m and g are powers of 1024 and a value without suffix
+// is interpreted as bytes.
+func ParseSize(s string) (uint32, error) {
+	sz, suffix, err := splitNumber(s)
+	if err != nil {
+		return 0, err
+	}
+	if suffix != "" {
+		var found bool
+		for _, ss := range sizeSuffixes {
+			if strings.EqualFold(ss.name, suffix) {
+				sz *= ss.scale
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown size suffix %q: %w", suffix, ErrInvalidArg)
+		}
+	}
+	if sz < 0 || math.IsNaN(sz) {
+		return 0, fmt.Errorf("invalid size %q: %w", s, ErrInvalidArg)
+	}
+	// detect if an overflow happened
+	if sz != math.Floor(sz) || sz > math.MaxUint32 {
+		return 0, fmt.Errorf("size %q can not be represented: %w", s, ErrInvalidArg)
+	}
+	return uint32(sz), nil
+}
+
+// FormatRate implements iproute2/tc/tc_util.c:print_rate().
+// It returns the human readable form of a rate given in bytes per second, e.g. "10Mbit".
+func FormatRate(rate uint64) string {
+	units := []string{"", "K", "M", "G", "T"}
+	const kilo = 1000
+
+	// bytes per second -> bits per second
+	rate <<= 3
+
+	var i int
+	for i = 0; i < len(units)-1; i++ {
+		if rate < kilo {
+			break
+		}
+		if (rate%kilo) != 0 && rate < 1000*kilo {
+			break
+		}
+		rate /= kilo
+	}
+	return fmt.Sprintf("%d%sbit", rate, units[i])
+}
+
+// FormatSize implements iproute2/tc/tc_util.c:print_size().
+// It returns the human readable form of a size given in bytes, e.g. "32Kb".
+func FormatSize(sz uint32) string {
+	tmp := float64(sz)
+
+	if sz >= 1024*1024 && math.Abs(1024*1024*math.RoundToEven(tmp/(1024*1024))-tmp) < 1024 {
+		return fmt.Sprintf("%gMb", math.RoundToEven(tmp/(1024*1024)))
+	} else if sz >= 1024 && math.Abs(1024*math.RoundToEven(tmp/1024)-tmp) < 16 {
+		return fmt.Sprintf("%gKb", math.RoundToEven(tmp/1024))
+	}
+	return fmt.Sprintf("%db", sz)
+}
@@ -0,0 +1,129 @@
+package tc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := map[string]struct {
+		rate uint64
+		err  error
+	}{
+		"10mbit":   {rate: 1250000},
+		"10Mbit":   {rate: 1250000},
+		"512kbit":  {rate: 64000},
+		"1kibit":   {rate: 128},
+		"1gbit":    {rate: 125000000},
+		"1gbps":    {rate: 1000000000},
+		"1GiBps":   {rate: 1073741824},
+		"1.5mbit":  {rate: 187500},
+		"800":      {rate: 100},
+		"800bit":   {rate: 100},
+		"100bps":   {rate: 100},
+		"1tbit":    {rate: 125000000000},
+		"10mbits":  {err: ErrInvalidArg},
+		"mbit":     {err: ErrInvalidArg},
+		"-10mbit":  {err: ErrInvalidArg},
+		"":         {err: ErrInvalidArg},
+		"10 mbit":  {err: ErrInvalidArg},
+		"1e3kbit":  {rate: 125000},
+		"0.5kbit":  {rate: 62},
+		"1000Kbit": {rate: 125000},
+	}
+
+	for input, testcase := range tests {
+		input := input
+		testcase := testcase
+		t.Run(input, func(t *testing.T) {
+			rate, err := ParseRate(input)
+			if !errors.Is(err, testcase.err) {
+				t.Fatalf("expected %v but got %v", testcase.err, err)
+			}
+			if rate != testcase.rate {
+				t.Fatalf("expected %d but got %d", testcase.rate, rate)
+			}
+		})
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]struct {
+		size uint32
+		err  error
+	}{
+		"1500":    {size: 1500},
+		"1500b":   {size: 1500},
+		"32k":     {size: 32768},
+		"32kb":    {size: 32768},
+		"32K":     {size: 32768},
+		"8kbit":   {size: 1024},
+		"1m":      {size: 1048576},
+		"1mbit":   {size: 131072},
+		"1g":      {size: 1073741824},
+		"1gbit":   {size: 134217728},
+		"1.5k":    {size: 1536},
+		"4g":      {err: ErrInvalidArg},
+		"1.5b":    {err: ErrInvalidArg},
+		"10kbyte": {err: ErrInvalidArg},
+		"k":       {err: ErrInvalidArg},
+		"-1":      {err: ErrInvalidArg},
+	}
+
+	for input, testcase := range tests {
+		input := input
+		testcase := testcase
+		t.Run(input, func(t *testing.T) {
+			size, err := ParseSize(input)
+			if !errors.Is(err, testcase.err) {
+				t.Fatalf("expected %v but got %v", testcase.err, err)
+			}
+			if size != testcase.size {
+				t.Fatalf("expected %d but got %d", testcase.size, size)
+			}
+		})
+	}
+}
+
+func TestFormatRate(t *testing.T) {
+	tests := map[uint64]string{
+		0:          "0bit",
+		100:        "800bit",
+		125:        "1Kbit",
+		64000:      "512Kbit",
+		187500:     "1500Kbit",
+		1250000:    "10Mbit",
+		125000000:  "1Gbit",
+		1000000000: "8Gbit",
+	}
+	for rate, expected := range tests {
+		if got := FormatRate(rate); got != expected {
+			t.Fatalf("FormatRate(%d): expected %s but got %s", rate, expected, got)
+		}
+		parsed, err := ParseRate(expected)
+		if err != nil {
+			t.Fatalf("ParseRate(%s): %v", expected, err)
+		}
+		if parsed != rate {
+			t.Fatalf("ParseRate(%s): expected %d but got %d", expected, rate, parsed)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := map[uint32]string{
+		0:       "0b",
+		1500:    "1500b",
+		1024:    "1Kb",
+		1030:    "1Kb",
+		32768:   "32Kb",
+		1048576: "1Mb",
+		1049000: "1Mb",
+		1600000: "1600000b",
+	}
+	for size, expected := range tests {
+		if got := FormatSize(size); got != expected {
+			t.Fatalf("FormatSize(%d): expected %s but got %s", size, expected, got)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package tc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/florianl/go-tc/core"
+)
+
+// kindOption maps an option struct of Attribute to the kinds, that use it.
+type kindOption struct {
+	name  string
+	kinds []string
+	isSet func(a *Attribute) bool
+}
+
+// kindOptions are derived from attributeKinds.
+var kindOptions = attributeOptions()
+
+func (ko kindOption) usedBy(kind string) bool {
+	for _, k := range ko.kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the Object for inconsistencies, that the kernel would either
+// reject with a non-descriptive error or, even worse, silently accept.
+// It verifies that exactly one option struct is populated, that it matches
+// Kind and that fields, which are required for a specific kind, are set.
+// All found problems are returned together.
+func (o *Object) Validate() error {
+	if o == nil {
+		return ErrNoArg
+	}
+	if o.Ifindex == 0 {
+		return ErrInvalidDev
+	}
+	if o.Kind == "" {
+		return fmt.Errorf("Kind: %w", ErrNoArg)
+	}
+
+	var multiError error
+	var known bool
+	var expected, populated, set []string
+	for _, ko := range kindOptions {
+		if ko.usedBy(o.Kind) {
+			known = true
+			expected = append(expected, ko.name)
+			if ko.isSet(&o.Attribute) {
+				populated = append(populated, ko.name)
+			}
+		}
+		if ko.isSet(&o.Attribute) {
+			set = append(set, ko.name)
+		}
+	}
+	for _, ko := range kindOptions {
+		if ko.usedBy(o.Kind) || !ko.isSet(&o.Attribute) {
+			continue
+		}
+		if len(expected) == 0 {
+			multiError = concatError(multiError, fmt.Errorf("%s: %s is set, but is not used by this kind: %w",
+				o.Kind, ko.name, ErrInvalidArg))
+			continue
+		}
+		multiError = concatError(multiError, fmt.Errorf("%s: %s is set, but this kind uses %s: %w",
+			o.Kind, ko.name, strings.Join(expected, " or "), ErrInvalidArg))
+	}
+	if len(set) > 1 {
+		// The marshal dispatch only encodes the options for Kind, so all other
+		// option structs would be dropped silently.
+		multiError = concatError(multiError, fmt.Errorf("%s: only one option struct can be set, but got %s: %w",
+			o.Kind, strings.Join(set, ", "), ErrInvalidArg))
+	}
+	if !known && !attributeKinds[o.Kind].parameterless {
+		// Unknown kinds are rejected when marshaling the options.
+		return multiError
+	}
+	if len(populated) == 0 && len(expected) > 0 && !attributeKinds[o.Kind].parameterless {
+		multiError = concatError(multiError, fmt.Errorf("%s: %s is required: %w",
+			o.Kind, strings.Join(expected, " or "), ErrNoArg))
+	}
+
+	return concatError(multiError, validateKindOptions(o))
+}
+
+// validateKindOptions checks fields, that are required by the kernel for a specific kind.
+func validateKindOptions(o *Object) error {
+	a := &o.Attribute
+	var multiError error
+	switch a.Kind {
+	case "sfb":
+		if a.Sfb != nil && a.Sfb.Parms == nil {
+			multiError = concatError(multiError, fmt.Errorf("sfb: Sfb.Parms is required: %w", ErrNoArg))
+		}
+	case "cbs":
+		if a.Cbs != nil && a.Cbs.Parms == nil {
+			multiError = concatError(multiError, fmt.Errorf("cbs: Cbs.Parms is required: %w", ErrNoArg))
+		}
+	case "tbf":
+		if a.Tbf != nil && a.Tbf.Parms == nil {
+			multiError = concatError(multiError, fmt.Errorf("tbf: Tbf.Parms is required: %w", ErrNoArg))
+		}
+	case "u32":
+		// linux/net/sched/cls_u32.c:u32_change() requires a selector
+		// for every filter, that does not create a hash table.
+		if a.U32 != nil && a.U32.Sel == nil && a.U32.Divisor == nil {
+			multiError = concatError(multiError, fmt.Errorf("u32: U32.Sel or U32.Divisor is required: %w", ErrNoArg))
+		}
+		// The classid of a selector, that does not link to another hash
+		// table, is only used, if the selector is terminal.
+		if u32 := a.U32; u32 != nil && u32.Sel != nil && u32.ClassID != nil && u32.Link == nil &&
+			u32.Sel.Flags&U32Terminal == 0 {
+			multiError = concatError(multiError, fmt.Errorf("u32: U32.ClassID is set, but U32.Sel.Flags lacks U32Terminal: %w", ErrInvalidArg))
+		}
+	case "tcindex":
+		// The handle of a tcindex filter is the key, that is compared with
+		// skb->tc_index, which has 16 bits. Filters with a larger handle are
+		// accepted by linux/net/sched/cls_tcindex.c, but never match.
+		if o.Handle > 0xFFFF {
+			multiError = concatError(multiError, fmt.Errorf("tcindex: Handle 0x%x does not fit into the 16 bit tc_index: %w",
+				o.Handle, ErrInvalidArg))
+		}
+	case "bpf":
+		if a.BPF != nil && a.BPF.FD == nil && a.BPF.Ops == nil {
+			multiError = concatError(multiError, fmt.Errorf("bpf: BPF.FD or BPF.Ops is required: %w", ErrNoArg))
+		}
+	case "flower":
+		if a.Flower != nil {
+			multiError = concatError(multiError, validateFlower(a.Flower, o.Info))
+		}
+	}
+	return multiError
+}
+
+// validateQdisc checks info before it is sent to the kernel as qdisc.
+func (tc *Tc) validateQdisc(info *Object) error {
+	if tc.skipValidation {
+		return nil
+	}
+	if err := info.Validate(); err != nil {
+		return err
+	}
+	if _, min := core.SplitHandle(info.Handle); min != 0 {
+		maj, _ := core.SplitHandle(info.Handle)
+		return fmt.Errorf("%s: Handle %x:%x: minor of a qdisc handle must be zero: %w",
+			info.Kind, maj, min, ErrInvalidArg)
+	}
+	switch {
+	case info.Kind == "clsact" || info.Kind == "ingress":
+		if info.Parent != HandleIngress {
+			return fmt.Errorf("%s: Parent %s: this kind must use HandleIngress as parent: %w",
+				info.Kind, core.FormatHandle(info.Parent), ErrInvalidArg)
+		}
+	case info.Parent == HandleIngress:
+		// linux/net/sched/sch_api.c:tc_modify_qdisc() reserves the parent
+		// for the ingress and clsact qdisc.
+		return fmt.Errorf("%s: Parent %s is reserved for the ingress and clsact qdisc: %w",
+			info.Kind, core.FormatHandle(info.Parent), ErrInvalidArg)
+	case info.Parent == 0:
+		return fmt.Errorf("%s: Parent is required, use HandleRoot for a root qdisc: %w",
+			info.Kind, ErrNoArg)
+	case info.Parent != HandleRoot:
+		// A qdisc below the root is the leaf of a class. Its handle must not
+		// refer to the qdisc of the class, see check_loop() in
+		// linux/net/sched/sch_api.c.
+		parentMaj, parentMin := core.SplitHandle(info.Parent)
+		if parentMin == 0 {
+			return fmt.Errorf("%s: Parent %s is a qdisc, use the handle of a class: %w",
+				info.Kind, core.FormatHandle(info.Parent), ErrInvalidArg)
+		}
+		if maj, _ := core.SplitHandle(info.Handle); info.Handle != 0 && maj == parentMaj {
+			return fmt.Errorf("%s: Handle %s is the qdisc of Parent %s: %w",
+				info.Kind, core.FormatHandle(info.Handle), core.FormatHandle(info.Parent), ErrInvalidArg)
+		}
+	}
+	return nil
+}
+
+// validateClass checks info before it is sent to the kernel as class.
+func (tc *Tc) validateClass(info *Object) error {
+	if tc.skipValidation {
+		return nil
+	}
+	if err := info.Validate(); err != nil {
+		return err
+	}
+	if info.Parent == 0 {
+		return fmt.Errorf("%s: Parent is required: %w", info.Kind, ErrNoArg)
+	}
+	// linux/net/sched/sch_api.c:tc_ctl_tclass() requires the class and its
+	// parent to belong to the same qdisc.
+	parentMaj, _ := core.SplitHandle(info.Parent)
+	if maj, _ := core.SplitHandle(info.Handle); info.Handle != 0 && info.Parent != HandleRoot &&
+		maj != parentMaj {
+		return fmt.Errorf("%s: Handle %s: major of the class must match major of Parent %s: %w",
+			info.Kind, core.FormatHandle(info.Handle), core.FormatHandle(info.Parent), ErrInvalidArg)
+	}
+	return nil
+}
+
+// validateFilter checks info before it is sent to the kernel as filter.
+func (tc *Tc) validateFilter(info *Object) error {
+	if tc.skipValidation {
+		return nil
+	}
+	if err := info.Validate(); err != nil {
+		return err
+	}
+	if info.Parent == 0 {
+		return fmt.Errorf("%s: Parent is required: %w", info.Kind, ErrNoArg)
+	}
+	// The lower 16 bits of Info hold the protocol in network byte order.
+	if _, protocol := core.SplitHandle(info.Info); protocol == 0 {
+		return fmt.Errorf("%s: Info: protocol is required: %w", info.Kind, ErrNoArg)
+	}
+	return nil
+}
@@ -0,0 +1,355 @@
+package tc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+)
+
+func TestValidate(t *testing.T) {
+	tcMsg := Msg{
+		Family:  unix.AF_UNSPEC,
+		Ifindex: 42,
+		Handle:  core.BuildHandle(0x1, 0x0),
+		Parent:  HandleRoot,
+	}
+
+	tests := map[string]struct {
+		msg  *Msg
+		attr Attribute
+		err  error
+		// errs must all match the error, if there are several problems.
+		errs []error
+		msg2 string
+	}{
+		"valid fq_codel":   {attr: Attribute{Kind: "fq_codel", FqCodel: &FqCodel{}}},
+		"valid clsact":     {attr: Attribute{Kind: "clsact"}},
+		"valid qfq":        {attr: Attribute{Kind: "qfq"}},
+		"valid hfsc":       {attr: Attribute{Kind: "hfsc", HfscQOpt: &HfscQOpt{}}},
+		"valid pfifo_fast": {attr: Attribute{Kind: "pfifo_fast", Prio: &Prio{Bands: 3}}},
+		"unknown kind":     {attr: Attribute{Kind: "foobar"}},
+		"no device": {
+			msg:  &Msg{},
+			attr: Attribute{Kind: "fq_codel", FqCodel: &FqCodel{}},
+			err:  ErrInvalidDev,
+			msg2: "invalid device ID",
+		},
+		"no kind": {
+			attr: Attribute{FqCodel: &FqCodel{}},
+			err:  ErrNoArg,
+			msg2: "Kind: missing argument",
+		},
+		"codel with FqCodel": {
+			attr: Attribute{Kind: "codel", FqCodel: &FqCodel{}},
+			msg2: "codel: FqCodel is set, but this kind uses Codel: invalid argument\n" +
+				"codel: Codel is required: missing argument",
+		},
+		"codel with Codel and FqCodel": {
+			attr: Attribute{Kind: "codel", Codel: &Codel{}, FqCodel: &FqCodel{}},
+			msg2: "codel: FqCodel is set, but this kind uses Codel: invalid argument\n" +
+				"codel: only one option struct can be set, but got FqCodel, Codel: invalid argument",
+		},
+		"missing options": {
+			attr: Attribute{Kind: "netem"},
+			err:  ErrNoArg,
+			msg2: "netem: Netem is required: missing argument",
+		},
+		"missing hfsc options": {
+			attr: Attribute{Kind: "hfsc"},
+			err:  ErrNoArg,
+			msg2: "hfsc: Hfsc or HfscQOpt is required: missing argument",
+		},
+		"clsact with options": {
+			attr: Attribute{Kind: "clsact", Htb: &Htb{}},
+			err:  ErrInvalidArg,
+			msg2: "clsact: Htb is set, but is not used by this kind: invalid argument",
+		},
+		"filter options on qdisc": {
+			attr: Attribute{Kind: "htb", Htb: &Htb{}, U32: &U32{}},
+			msg2: "htb: U32 is set, but this kind uses Htb: invalid argument\n" +
+				"htb: only one option struct can be set, but got U32, Htb: invalid argument",
+		},
+		"fq_codel with Codel": {
+			attr: Attribute{Kind: "fq_codel", Codel: &Codel{}},
+			msg2: "fq_codel: Codel is set, but this kind uses FqCodel: invalid argument\n" +
+				"fq_codel: FqCodel is required: missing argument",
+		},
+		"hfsc with Hfsc and HfscQOpt": {
+			attr: Attribute{Kind: "hfsc", Hfsc: &Hfsc{}, HfscQOpt: &HfscQOpt{}},
+			err:  ErrInvalidArg,
+			msg2: "hfsc: only one option struct can be set, but got Hfsc, HfscQOpt: invalid argument",
+		},
+		"pfifo_fast with Pfifo": {
+			attr: Attribute{Kind: "pfifo_fast", Pfifo: &FifoOpt{Limit: 100}},
+			msg2: "pfifo_fast: Pfifo is set, but this kind uses Prio: invalid argument\n" +
+				"pfifo_fast: Prio is required: missing argument",
+		},
+		"filter with qdisc options": {
+			attr: Attribute{Kind: "u32", U32: &U32{Divisor: uint32Ptr(256)}, Netem: &Netem{}},
+			msg2: "u32: Netem is set, but this kind uses U32: invalid argument\n" +
+				"u32: only one option struct can be set, but got U32, Netem: invalid argument",
+		},
+		"unknown kind with options": {
+			attr: Attribute{Kind: "foobar", Htb: &Htb{}, Fq: &Fq{}},
+			msg2: "foobar: Fq is set, but is not used by this kind: invalid argument\n" +
+				"foobar: Htb is set, but is not used by this kind: invalid argument\n" +
+				"foobar: only one option struct can be set, but got Fq, Htb: invalid argument",
+		},
+		"sfb without Parms": {
+			attr: Attribute{Kind: "sfb", Sfb: &Sfb{}},
+			err:  ErrNoArg,
+			msg2: "sfb: Sfb.Parms is required: missing argument",
+		},
+		"cbs without Parms": {
+			attr: Attribute{Kind: "cbs", Cbs: &Cbs{}},
+			err:  ErrNoArg,
+			msg2: "cbs: Cbs.Parms is required: missing argument",
+		},
+		"tbf without Parms": {
+			attr: Attribute{Kind: "tbf", Tbf: &Tbf{Burst: uint32Ptr(1500)}},
+			err:  ErrNoArg,
+			msg2: "tbf: Tbf.Parms is required: missing argument",
+		},
+		"u32 without Sel": {
+			attr: Attribute{Kind: "u32", U32: &U32{ClassID: uint32Ptr(0x10010)}},
+			err:  ErrNoArg,
+			msg2: "u32: U32.Sel or U32.Divisor is required: missing argument",
+		},
+		"u32 hash table": {
+			attr: Attribute{Kind: "u32", U32: &U32{Divisor: uint32Ptr(256)}},
+		},
+		"u32 classid without terminal": {
+			attr: Attribute{Kind: "u32", U32: &U32{ClassID: uint32Ptr(0x10010),
+				Sel: &U32Sel{Keys: []U32Key{{Mask: 0xff, Val: 0x6}}}}},
+			err:  ErrInvalidArg,
+			msg2: "u32: U32.ClassID is set, but U32.Sel.Flags lacks U32Terminal: invalid argument",
+		},
+		"u32 classid": {
+			attr: Attribute{Kind: "u32", U32: &U32{ClassID: uint32Ptr(0x10010),
+				Sel: &U32Sel{Flags: U32Terminal, Keys: []U32Key{{Mask: 0xff, Val: 0x6}}}}},
+		},
+		"u32 classid with link": {
+			attr: Attribute{Kind: "u32", U32: &U32{ClassID: uint32Ptr(0x10010), Link: uint32Ptr(0x100000),
+				Sel: &U32Sel{Keys: []U32Key{{Mask: 0xff, Val: 0x6}}}}},
+		},
+		"bpf without program": {
+			attr: Attribute{Kind: "bpf", BPF: &Bpf{ClassID: uint32Ptr(0x10010)}},
+			err:  ErrNoArg,
+			msg2: "bpf: BPF.FD or BPF.Ops is required: missing argument",
+		},
+		"tcindex key": {
+			msg:  &Msg{Family: unix.AF_UNSPEC, Ifindex: 42, Handle: 0xB8, Parent: 0xFFFF0000},
+			attr: Attribute{Kind: "tcindex", TcIndex: &TcIndex{ClassID: uint32Ptr(0x10001)}},
+		},
+		"tcindex key too large": {
+			msg:  &Msg{Family: unix.AF_UNSPEC, Ifindex: 42, Handle: 0x100B8, Parent: 0xFFFF0000},
+			attr: Attribute{Kind: "tcindex", TcIndex: &TcIndex{ClassID: uint32Ptr(0x10001)}},
+			err:  ErrInvalidArg,
+			msg2: "tcindex: Handle 0x100b8 does not fit into the 16 bit tc_index: invalid argument",
+		},
+		"multiple problems": {
+			attr: Attribute{Kind: "sfb", Sfb: &Sfb{}, Red: &Red{}, Choke: &Choke{}},
+			errs: []error{ErrInvalidArg, ErrNoArg},
+			msg2: "sfb: Red is set, but this kind uses Sfb: invalid argument\n" +
+				"sfb: Choke is set, but this kind uses Sfb: invalid argument\n" +
+				"sfb: only one option struct can be set, but got Sfb, Red, Choke: invalid argument\n" +
+				"sfb: Sfb.Parms is required: missing argument",
+		},
+	}
+
+	for name, testcase := range tests {
+		testcase := testcase
+		t.Run(name, func(t *testing.T) {
+			obj := Object{Msg: tcMsg, Attribute: testcase.attr}
+			if testcase.msg != nil {
+				obj.Msg = *testcase.msg
+			}
+			err := obj.Validate()
+			if testcase.msg2 == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected error %q but got nil", testcase.msg2)
+			}
+			if testcase.err != nil && !errors.Is(err, testcase.err) {
+				t.Fatalf("expected %v but got %v", testcase.err, err)
+			}
+			for _, want := range testcase.errs {
+				if !errors.Is(err, want) {
+					t.Fatalf("expected %v but got %v", want, err)
+				}
+			}
+			if err.Error() != testcase.msg2 {
+				t.Fatalf("expected error message\n%q\nbut got\n%q", testcase.msg2, err.Error())
+			}
+		})
+	}
+
+	t.Run("nil", func(t *testing.T) {
+		var obj *Object
+		if err := obj.Validate(); !errors.Is(err, ErrNoArg) {
+			t.Fatalf("expected ErrNoArg but got %v", err)
+		}
+	})
+}
+
+func TestValidateOnAdd(t *testing.T) {
+	tcSocket, done := testConn(t)
+	defer done()
+
+	qdisc := Object{
+		Msg{
+			Family:  unix.AF_UNSPEC,
+			Ifindex: 42,
+			Handle:  core.BuildHandle(0x1, 0x1),
+			Parent:  HandleRoot,
+		},
+		Attribute{
+			Kind:    "fq_codel",
+			FqCodel: &FqCodel{Target: uint32Ptr(42)},
+		},
+	}
+
+	if err := tcSocket.Qdisc().Add(&qdisc); !errors.Is(err, ErrInvalidArg) {
+		t.Fatalf("expected ErrInvalidArg but got %v", err)
+	}
+	if err := tcSocket.Qdisc().Replace(&qdisc); !errors.Is(err, ErrInvalidArg) {
+		t.Fatalf("expected ErrInvalidArg but got %v", err)
+	}
+
+	mismatch := Object{qdisc.Msg, Attribute{Kind: "codel", FqCodel: &FqCodel{}}}
+	mismatch.Handle = core.BuildHandle(0x1, 0x0)
+	if err := tcSocket.Qdisc().Add(&mismatch); err == nil {
+		t.Fatal("expected an error for mismatching kind and options")
+	}
+
+	multiple := Object{mismatch.Msg, Attribute{Kind: "hfsc", Hfsc: &Hfsc{}, HfscQOpt: &HfscQOpt{}}}
+	if err := tcSocket.Qdisc().Add(&multiple); !errors.Is(err, ErrInvalidArg) {
+		t.Fatalf("expected ErrInvalidArg for multiple option structs but got %v", err)
+	}
+
+	// linux/net/sched/cls_flower.c ignores the customer VLAN without an
+	// inner VLAN ethernet type instead of refusing it.
+	qinq, err := NewFlower(FlowerQinQ(100, 200))
+	if err != nil {
+		t.Fatalf("could not build flower: %v", err)
+	}
+	qinq.KeyVlanEthType = uint16Ptr(0x0800)
+	filter := Object{
+		Msg{Family: unix.AF_UNSPEC, Ifindex: 42, Parent: HandleIngress, Info: core.BuildFilterInfo(1, core.EthP8021AD)},
+		Attribute{Kind: "flower", Flower: qinq},
+	}
+	if err := tcSocket.Filter().Add(&filter); !errors.Is(err, ErrInvalidArg) {
+		t.Fatalf("expected ErrInvalidArg for an inconsistent QinQ filter but got %v", err)
+	}
+
+	tcSocket.skipValidation = true
+	if err := tcSocket.Qdisc().Add(&qdisc); err != nil {
+		t.Fatalf("unexpected error with disabled validation: %v", err)
+	}
+}
+
+func TestValidateHandles(t *testing.T) {
+	fqCodel := Attribute{Kind: "fq_codel", FqCodel: &FqCodel{}}
+	htb := Attribute{Kind: "htb", Htb: &Htb{Parms: &HtbOpt{}}}
+	u32 := Attribute{Kind: "u32", U32: &U32{Divisor: uint32Ptr(1)}}
+
+	tests := map[string]struct {
+		validate func(tc *Tc, info *Object) error
+		obj      Object
+		err      error
+	}{
+		"root qdisc": {
+			validate: (*Tc).validateQdisc,
+			obj:      Object{Msg{Ifindex: 42, Handle: core.BuildHandle(0x1, 0x0), Parent: HandleRoot}, fqCodel},
+		},
+		"qdisc with minor": {
+			validate: (*Tc).validateQdisc,
+			obj:      Object{Msg{Ifindex: 42, Handle: core.BuildHandle(0x1, 0x1), Parent: HandleRoot}, fqCodel},
+			err:      ErrInvalidArg,
+		},
+		"qdisc without parent": {
+			validate: (*Tc).validateQdisc,
+			obj:      Object{Msg{Ifindex: 42, Handle: core.BuildHandle(0x1, 0x0)}, fqCodel},
+			err:      ErrNoArg,
+		},
+		"clsact": {
+			validate: (*Tc).validateQdisc,
+			obj:      Object{Msg{Ifindex: 42, Handle: core.BuildHandle(0xffff, 0x0), Parent: HandleIngress}, Attribute{Kind: "clsact"}},
+		},
+		"leaf qdisc": {
+			validate: (*Tc).validateQdisc,
+			obj:      Object{Msg{Ifindex: 42, Handle: core.BuildHandle(0x10, 0x0), Parent: core.BuildHandle(0x1, 0x10)}, fqCodel},
+		},
+		"leaf qdisc below a qdisc": {
+			validate: (*Tc).validateQdisc,
+			obj:      Object{Msg{Ifindex: 42, Handle: core.BuildHandle(0x10, 0x0), Parent: core.BuildHandle(0x1, 0x0)}, fqCodel},
+			err:      ErrInvalidArg,
+		},
+		"leaf qdisc with the handle of its parent": {
+			validate: (*Tc).validateQdisc,
+			obj:      Object{Msg{Ifindex: 42, Handle: core.BuildHandle(0x1, 0x0), Parent: core.BuildHandle(0x1, 0x10)}, fqCodel},
+			err:      ErrInvalidArg,
+		},
+		"ingress below root": {
+			validate: (*Tc).validateQdisc,
+			obj:      Object{Msg{Ifindex: 42, Parent: HandleRoot}, Attribute{Kind: "ingress"}},
+			err:      ErrInvalidArg,
+		},
+		"fq_codel below ingress": {
+			validate: (*Tc).validateQdisc,
+			obj:      Object{Msg{Ifindex: 42, Handle: core.BuildHandle(0x1, 0x0), Parent: HandleIngress}, fqCodel},
+			err:      ErrInvalidArg,
+		},
+		"class": {
+			validate: (*Tc).validateClass,
+			obj:      Object{Msg{Ifindex: 42, Handle: core.BuildHandle(0x1, 0x10), Parent: core.BuildHandle(0x1, 0x1)}, htb},
+		},
+		"class without parent": {
+			validate: (*Tc).validateClass,
+			obj:      Object{Msg{Ifindex: 42, Handle: core.BuildHandle(0x1, 0x10)}, htb},
+			err:      ErrNoArg,
+		},
+		"class of another qdisc": {
+			validate: (*Tc).validateClass,
+			obj:      Object{Msg{Ifindex: 42, Handle: core.BuildHandle(0x2, 0x10), Parent: core.BuildHandle(0x1, 0x0)}, htb},
+			err:      ErrInvalidArg,
+		},
+		"filter": {
+			validate: (*Tc).validateFilter,
+			obj:      Object{Msg{Ifindex: 42, Parent: core.BuildHandle(0x1, 0x0), Info: core.BuildHandle(0x1, 0x300)}, u32},
+		},
+		"filter without parent": {
+			validate: (*Tc).validateFilter,
+			obj:      Object{Msg{Ifindex: 42, Info: core.BuildHandle(0x1, 0x300)}, u32},
+			err:      ErrNoArg,
+		},
+		"filter without protocol": {
+			validate: (*Tc).validateFilter,
+			obj:      Object{Msg{Ifindex: 42, Parent: core.BuildHandle(0x1, 0x0), Info: core.BuildHandle(0x1, 0x0)}, u32},
+			err:      ErrNoArg,
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := testcase.validate(&Tc{}, &testcase.obj)
+			if testcase.err == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			} else if !errors.Is(err, testcase.err) {
+				t.Fatalf("expected %v but got %v", testcase.err, err)
+			}
+			// Disabled validation accepts everything.
+			if err := testcase.validate(&Tc{skipValidation: true}, &testcase.obj); err != nil {
+				t.Fatalf("unexpected error with disabled validation: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package tc
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+)
+
+// VerificationError is returned with Config.VerifyWrites or Verify, if an
+// object, that was read back after a successful Add or Replace, differs from
+// the requested one. Usually the kernel clamped a value without an error,
+// like the limit of fq_codel. It wraps ErrVerificationMismatch.
+type VerificationError struct {
+	// Requested is the written object.
+	Requested Object
+	// Found is the object, that was read back. It is nil, if the object was
+	// not found.
+	Found *Object
+	// Diffs are the fields of Requested, that the kernel did not apply as
+	// requested. A holds the requested and B the applied value.
+	Diffs []FieldDiff
+}
+
+func (e *VerificationError) Error() string {
+	if e.Found == nil {
+		return fmt.Sprintf("%s: not found: %v", e.Requested, ErrVerificationMismatch)
+	}
+	diffs := make([]string, 0, len(e.Diffs))
+	for _, d := range e.Diffs {
+		diffs = append(diffs, d.String())
+	}
+	return fmt.Sprintf("%s: %v: %s", e.Requested, ErrVerificationMismatch, strings.Join(diffs, ", "))
+}
+
+// Unwrap returns ErrVerificationMismatch.
+func (e *VerificationError) Unwrap() error {
+	return ErrVerificationMismatch
+}
+
+// Verify returns a Qdisc, that reads queueing disciplines back after Add and
+// Replace like with Config.VerifyWrites.
+func (qd *Qdisc) Verify() *Qdisc {
+	verify := *qd
+	verify.verifyWrites = true
+	return &verify
+}
+
+// Verify returns a Class, that reads classes back after Add and Replace like
+// with Config.VerifyWrites.
+func (c *Class) Verify() *Class {
+	verify := *c
+	verify.verifyWrites = true
+	return &verify
+}
+
+// Verify returns a Filter, that reads filters back after Add and Replace like
+// with Config.VerifyWrites.
+func (f *Filter) Verify() *Filter {
+	verify := *f
+	verify.verifyWrites = true
+	return &verify
+}
+
+// verifyWrite reads info back with a dump of getType and compares it with
+// the found object. Of several candidates, like filters without a handle,
+// the one with the fewest differences is reported.
+func (tc *Tc) verifyWrite(getType int, info *Object) error {
+	if !tc.verifyWrites {
+		return nil
+	}
+	req := &Msg{Family: unix.AF_UNSPEC, Ifindex: info.Ifindex}
+	if getType == unix.RTM_GETTFILTER {
+		req.Parent = info.Parent
+	}
+	objs, err := tc.get(getType, req)
+	if err != nil {
+		return fmt.Errorf("read back %s: %w", info, err)
+	}
+	verr := &VerificationError{Requested: *info}
+	for i := range objs {
+		found := &objs[i]
+		if !isWrittenObject(getType, info, found) {
+			continue
+		}
+		diffs := requestedDiffs(info, found)
+		if len(diffs) == 0 {
+			return nil
+		}
+		if verr.Found == nil || len(diffs) < len(verr.Diffs) {
+			verr.Found = found
+			verr.Diffs = diffs
+		}
+	}
+	return verr
+}
+
+// isWrittenObject reports whether found is a candidate for the written object
+// info. Handles and priorities, that were left to the kernel, match any.
+func isWrittenObject(getType int, info, found *Object) bool {
+	if found.Ifindex != info.Ifindex || found.Kind != info.Kind {
+		return false
+	}
+	if info.Handle != 0 && found.Handle != info.Handle {
+		return false
+	}
+	switch getType {
+	case unix.RTM_GETQDISC:
+		return found.Parent == info.Parent
+	case unix.RTM_GETTFILTER:
+		prio, proto := core.SplitFilterInfo(info.Info)
+		foundPrio, foundProto := core.SplitFilterInfo(found.Info)
+		return (prio == 0 || prio == foundPrio) && proto == foundProto &&
+			uint32Value(info.Chain) == uint32Value(found.Chain)
+	}
+	return true
+}
+
+// requestedDiffs returns the differences between info and found in the
+// fields, that are set in info. Fields, that are unset or zero in info, are
+// filled in by the kernel and not compared.
+func requestedDiffs(info, found *Object) []FieldDiff {
+	want := info.Copy()
+	if want.Handle == 0 {
+		want.Handle = found.Handle
+	}
+	if prio, proto := core.SplitFilterInfo(want.Info); objectType(want) == "filter" && prio == 0 {
+		foundPrio, _ := core.SplitFilterInfo(found.Info)
+		want.Info = core.BuildFilterInfo(foundPrio, proto)
+	}
+	var diffs []FieldDiff
+	for _, d := range Diff(want, found) {
+		if d.A == nil || reflect.ValueOf(d.A).IsZero() {
+			continue
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs
+}
@@ -0,0 +1,230 @@
+package tc
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/florianl/go-tc/core"
+	"github.com/florianl/go-tc/internal/unix"
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nltest"
+)
+
+func TestVerifyWrites(t *testing.T) {
+	qdiscMsg := Msg{Family: unix.AF_UNSPEC, Ifindex: 1, Handle: core.BuildHandle(0x1, 0x0), Parent: HandleRoot}
+	classMsg := Msg{Family: unix.AF_UNSPEC, Ifindex: 1, Handle: core.BuildHandle(0x1, 0x1),
+		Parent: core.BuildHandle(0x1, 0x0)}
+	filterMsg := Msg{Family: unix.AF_UNSPEC, Ifindex: 1, Parent: core.BuildHandle(0x1, 0x0),
+		Info: core.BuildFilterInfo(0, core.EthPIP)}
+
+	fqCodel := func(limit uint32) Object {
+		return Object{Msg: qdiscMsg, Attribute: Attribute{Kind: "fq_codel", FqCodel: &FqCodel{Limit: uint32Ptr(limit)}}}
+	}
+	red := func(wlog byte) Object {
+		return Object{Msg: qdiscMsg, Attribute: Attribute{Kind: "red", Red: &Red{
+			Parms: &RedQOpt{Limit: 400000, QthMin: 30000, QthMax: 90000, Wlog: wlog, Plog: 21, ScellLog: 10},
+			Stab:  bytesPtr(make([]byte, 256)), MaxP: uint32Ptr(1 << 30)}}}
+	}
+	htb := Object{Msg: classMsg, Attribute: Attribute{Kind: "htb", Htb: &Htb{
+		Parms: &HtbOpt{Rate: RateSpec{Rate: 125000}, Ceil: RateSpec{Rate: 125000}, Buffer: 1600, Cbuffer: 1600}}}}
+	matchall := Object{Msg: filterMsg, Attribute: Attribute{Kind: "matchall", Matchall: &Matchall{ClassID: uint32Ptr(0x10001)}}}
+	assigned := func(obj Object) Object {
+		obj.Handle = 1
+		obj.Info = core.BuildFilterInfo(49152, core.EthPIP)
+		return obj
+	}
+
+	tests := map[string]struct {
+		op string
+		// config enables Config.VerifyWrites and call Verify.
+		config  bool
+		call    bool
+		request Object
+		dump    []Object
+		found   *Object
+		diffs   []FieldDiff
+		err     error
+	}{
+		"applied": {
+			op:      "qdisc add",
+			config:  true,
+			request: fqCodel(10000),
+			dump:    []Object{fqCodel(10000)},
+		},
+		"clamped limit": {
+			op:      "qdisc add",
+			config:  true,
+			request: fqCodel(10000),
+			dump:    []Object{fqCodel(1000)},
+			found:   objectPtr(fqCodel(1000)),
+			diffs:   []FieldDiff{{Path: "FqCodel.Limit", A: uint32(10000), B: uint32(1000)}},
+			err:     ErrVerificationMismatch,
+		},
+		"clamped wlog": {
+			op:      "qdisc replace",
+			call:    true,
+			request: red(32),
+			dump:    []Object{red(31)},
+			found:   objectPtr(red(31)),
+			diffs:   []FieldDiff{{Path: "Red.Parms.Wlog", A: byte(32), B: byte(31)}},
+			err:     ErrVerificationMismatch,
+		},
+		"not found": {
+			op:      "qdisc replace",
+			config:  true,
+			request: fqCodel(10000),
+			dump: []Object{{Msg: Msg{Ifindex: 2, Handle: qdiscMsg.Handle, Parent: HandleRoot},
+				Attribute: Attribute{Kind: "fq_codel", FqCodel: &FqCodel{Limit: uint32Ptr(10000)}}}},
+			err: ErrVerificationMismatch,
+		},
+		"disabled": {
+			op:      "qdisc add",
+			request: fqCodel(10000),
+			dump:    []Object{fqCodel(1000)},
+		},
+		"class filled in": {
+			op:      "class add",
+			config:  true,
+			request: htb,
+			dump: []Object{{Msg: classMsg, Attribute: Attribute{Kind: "htb", Htb: &Htb{
+				Parms: &HtbOpt{Rate: RateSpec{Rate: 125000}, Ceil: RateSpec{Rate: 125000}, Buffer: 1600, Cbuffer: 1600,
+					Quantum: 12500}}}}},
+		},
+		"class clamped": {
+			op:      "class replace",
+			call:    true,
+			request: htb,
+			dump: []Object{{Msg: classMsg, Attribute: Attribute{Kind: "htb", Htb: &Htb{
+				Parms: &HtbOpt{Rate: RateSpec{Rate: 125000}, Ceil: RateSpec{Rate: 125000}, Buffer: 1600, Cbuffer: 800}}}}},
+			found: &Object{Msg: classMsg, Attribute: Attribute{Kind: "htb", Htb: &Htb{
+				Parms: &HtbOpt{Rate: RateSpec{Rate: 125000}, Ceil: RateSpec{Rate: 125000}, Buffer: 1600, Cbuffer: 800}}}},
+			diffs: []FieldDiff{{Path: "Htb.Parms.Cbuffer", A: uint32(1600), B: uint32(800)}},
+			err:   ErrVerificationMismatch,
+		},
+		"filter handle of kernel": {
+			op:      "filter add",
+			config:  true,
+			request: matchall,
+			dump:    []Object{assigned(matchall)},
+		},
+		"filter of other protocol": {
+			op:      "filter replace",
+			config:  true,
+			request: matchall,
+			dump: []Object{{Msg: Msg{Family: unix.AF_UNSPEC, Ifindex: 1, Handle: 1, Parent: filterMsg.Parent,
+				Info: core.BuildFilterInfo(49152, core.EthPIPv6)}, Attribute: matchall.Attribute}},
+			err: ErrVerificationMismatch,
+		},
+		"closest filter": {
+			op:   "filter add",
+			call: true,
+			request: Object{Msg: filterMsg, Attribute: Attribute{Kind: "matchall", Matchall: &Matchall{
+				ClassID: uint32Ptr(0x10001), Flags: uint32Ptr(SkipHw)}}},
+			dump: []Object{
+				{Msg: assigned(matchall).Msg, Attribute: Attribute{Kind: "matchall", Matchall: &Matchall{
+					ClassID: uint32Ptr(0x10002), Flags: uint32Ptr(SkipSw)}}},
+				{Msg: assigned(matchall).Msg, Attribute: Attribute{Kind: "matchall", Matchall: &Matchall{
+					ClassID: uint32Ptr(0x10002), Flags: uint32Ptr(SkipHw)}}},
+			},
+			found: &Object{Msg: assigned(matchall).Msg, Attribute: Attribute{Priority: 49152, Protocol: core.EthPIP,
+				Kind: "matchall", Matchall: &Matchall{ClassID: uint32Ptr(0x10002), Flags: uint32Ptr(SkipHw)}}},
+			diffs: []FieldDiff{{Path: "Matchall.ClassID", A: uint32(0x10001), B: uint32(0x10002)}},
+			err:   ErrVerificationMismatch,
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			var dumps int
+			tcSocket := &Tc{
+				con: nltest.Dial(func(req []netlink.Message) ([]netlink.Message, error) {
+					var reply netlink.HeaderType
+					switch req[0].Header.Type {
+					case unix.RTM_GETQDISC:
+						reply = unix.RTM_NEWQDISC
+					case unix.RTM_GETTCLASS:
+						reply = unix.RTM_NEWTCLASS
+					case unix.RTM_GETTFILTER:
+						reply = unix.RTM_NEWTFILTER
+					default:
+						return []netlink.Message{{Header: netlink.Header{Type: netlink.Error}, Data: make([]byte, 4)}}, nil
+					}
+					dumps++
+					var msgs []netlink.Message
+					for i := range testcase.dump {
+						msg, err := MarshalReply(reply, &testcase.dump[i])
+						if err != nil {
+							t.Fatalf("could not marshal reply: %v", err)
+						}
+						msgs = append(msgs, msg)
+					}
+					return msgs, nil
+				}),
+				verifyWrites: testcase.config,
+			}
+			defer tcSocket.Close()
+
+			written := testcase.request
+			var err error
+			qdisc, class, filter := tcSocket.Qdisc(), tcSocket.Class(), tcSocket.Filter()
+			if testcase.call {
+				qdisc, class, filter = qdisc.Verify(), class.Verify(), filter.Verify()
+			}
+			switch testcase.op {
+			case "qdisc add":
+				err = qdisc.Add(&written)
+			case "qdisc replace":
+				err = qdisc.Replace(&written)
+			case "class add":
+				err = class.Add(&written)
+			case "class replace":
+				err = class.Replace(&written)
+			case "filter add":
+				err = filter.Add(&written)
+			case "filter replace":
+				err = filter.Replace(&written)
+			}
+			if !errors.Is(err, testcase.err) {
+				t.Fatalf("expected error %v but got %v", testcase.err, err)
+			}
+			if testcase.err == nil {
+				if !testcase.config && !testcase.call && dumps != 0 {
+					t.Fatalf("expected no read back but got %d dumps", dumps)
+				}
+				return
+			}
+			var verr *VerificationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("expected a *VerificationError but got %v", err)
+			}
+			if diff := cmp.Diff(testcase.found, verr.Found); diff != "" {
+				t.Fatalf("found object missmatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(testcase.diffs, verr.Diffs); diff != "" {
+				t.Fatalf("diffs missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("dump error", func(t *testing.T) {
+		tcSocket := &Tc{
+			con: nltest.Dial(func(req []netlink.Message) ([]netlink.Message, error) {
+				if req[0].Header.Type == unix.RTM_GETQDISC {
+					return nltest.Error(int(syscall.EPERM), req)
+				}
+				return []netlink.Message{{Header: netlink.Header{Type: netlink.Error}, Data: make([]byte, 4)}}, nil
+			}),
+		}
+		defer tcSocket.Close()
+		qdisc := fqCodel(10000)
+		if err := tcSocket.Qdisc().Verify().Add(&qdisc); !errors.Is(err, syscall.EPERM) {
+			t.Fatalf("expected EPERM but got %v", err)
+		}
+	})
+}
+
+func objectPtr(obj Object) *Object {
+	return &obj
+}
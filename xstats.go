@@ -0,0 +1,33 @@
+package tc
+
+import "sync"
+
+// XStatsDecoder decodes the payload of TCA_XSTATS for a specific kind.
+type XStatsDecoder func(data []byte) (interface{}, error)
+
+var (
+	xStatsDecodersMu sync.RWMutex
+	xStatsDecoders   = map[string]XStatsDecoder{}
+)
+
+// RegisterXStats registers fn as decoder for the extended statistics of kind.
+// The result of fn is stored in XStats.Custom. This allows to decode the
+// statistics of kinds, that are not known to this package, or to replace the
+// decoding of a known kind. Registering a nil fn removes the decoder of kind.
+func RegisterXStats(kind string, fn XStatsDecoder) {
+	xStatsDecodersMu.Lock()
+	defer xStatsDecodersMu.Unlock()
+	if fn == nil {
+		delete(xStatsDecoders, kind)
+		return
+	}
+	xStatsDecoders[kind] = fn
+}
+
+// lookupXStats returns the registered decoder of kind.
+func lookupXStats(kind string) (XStatsDecoder, bool) {
+	xStatsDecodersMu.RLock()
+	defer xStatsDecodersMu.RUnlock()
+	fn, ok := xStatsDecoders[kind]
+	return fn, ok
+}
@@ -0,0 +1,145 @@
+package tc
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestXStats(t *testing.T) {
+	type fooXStats struct {
+		Drops uint32
+	}
+	errDecode := errors.New("payload too short")
+	RegisterXStats("foo", func(data []byte) (interface{}, error) {
+		if len(data) < 4 {
+			return nil, errDecode
+		}
+		return &fooXStats{Drops: binary.LittleEndian.Uint32(data)}, nil
+	})
+	defer RegisterXStats("foo", nil)
+
+	tests := map[string]struct {
+		kind string
+		data []byte
+		want XStats
+		err  error
+	}{
+		"known kind": {
+			kind: "sfq",
+			data: []byte{0x2a, 0x0, 0x0, 0x0},
+			want: XStats{Kind: "sfq", Raw: []byte{0x2a, 0x0, 0x0, 0x0}, Sfq: &SfqXStats{Allot: 42}},
+		},
+		"unknown kind": {
+			kind: "bar",
+			data: []byte{0x1, 0x2},
+			want: XStats{Kind: "bar", Raw: []byte{0x1, 0x2}},
+		},
+		"registered kind": {
+			kind: "foo",
+			data: []byte{0x2a, 0x0, 0x0, 0x0},
+			want: XStats{Kind: "foo", Raw: []byte{0x2a, 0x0, 0x0, 0x0}, Custom: &fooXStats{Drops: 42}},
+		},
+		"registered kind with error": {
+			kind: "foo",
+			data: []byte{0x1},
+			err:  errDecode,
+		},
+	}
+
+	for name, testcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			if name == "known kind" && nativeLayout.bigEndian() {
+				t.Skip("test data is little endian")
+			}
+			got := XStats{}
+			err := extractXStats(testcase.data, &got, testcase.kind)
+			if testcase.err != nil {
+				if !errors.Is(err, testcase.err) {
+					t.Fatalf("expected %v but got %v", testcase.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(testcase.want, got); diff != "" {
+				t.Fatalf("XStats missmatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("fq", func(t *testing.T) {
+		fq := FqQdStats{GcFlows: 1, HighPrioPackets: 2, Throttled: 3, Flows: 4, InactiveFlows: 5,
+			CEMark: 6, HorizonDrops: 7, HorizonCaps: 8, BandDrops: [3]uint64{9, 10, 11}}
+		data, err := marshalXStats(XStats{Fq: &fq})
+		if err != nil {
+			t.Fatalf("could not marshal fq xstats: %v", err)
+		}
+		// Before Linux 5.7, tc_fq_qd_stats ended with ce_mark after 88 bytes.
+		old := data[:88]
+		tests := map[string]struct {
+			data []byte
+			want FqQdStats
+		}{
+			"current": {data: data, want: fq},
+			"old kernel": {data: old, want: FqQdStats{GcFlows: 1, HighPrioPackets: 2, Throttled: 3, Flows: 4,
+				InactiveFlows: 5, CEMark: 6}},
+		}
+		for name, testcase := range tests {
+			t.Run(name, func(t *testing.T) {
+				got := XStats{}
+				if err := extractXStats(testcase.data, &got, "fq"); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if diff := cmp.Diff(&testcase.want, got.Fq); diff != "" {
+					t.Fatalf("fq xstats missmatch (-want +got):\n%s", diff)
+				}
+			})
+		}
+	})
+
+	t.Run("htb", func(t *testing.T) {
+		htb := HtbXStats{Lends: 1, Borrows: 2, Giants: 3, Tokens: -4000, CTokens: 5000}
+		data, err := marshalStruct(&htb)
+		if err != nil {
+			t.Fatalf("could not marshal htb xstats: %v", err)
+		}
+		if len(data) != 20 {
+			t.Fatalf("expected tc_htb_xstats of 20 bytes but got %d", len(data))
+		}
+		tests := map[string]struct {
+			data []byte
+			want HtbXStats
+		}{
+			"20 bytes": {data: data, want: htb},
+			"padded":   {data: append(append([]byte{}, data...), 0x0, 0x0, 0x0, 0x0), want: htb},
+			"short":    {data: data[:12], want: HtbXStats{Lends: 1, Borrows: 2, Giants: 3}},
+		}
+		for name, testcase := range tests {
+			t.Run(name, func(t *testing.T) {
+				got := XStats{}
+				if err := extractXStats(testcase.data, &got, "htb"); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if diff := cmp.Diff(&testcase.want, got.Htb); diff != "" {
+					t.Fatalf("htb xstats missmatch (-want +got):\n%s", diff)
+				}
+			})
+		}
+	})
+
+	t.Run("unregister", func(t *testing.T) {
+		RegisterXStats("baz", func([]byte) (interface{}, error) { return 42, nil })
+		RegisterXStats("baz", nil)
+		got := XStats{}
+		if err := extractXStats([]byte{0x1}, &got, "baz"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Custom != nil {
+			t.Fatalf("expected no custom statistics but got %v", got.Custom)
+		}
+	})
+}